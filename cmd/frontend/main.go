@@ -9,7 +9,9 @@ import (
 	"context"
 	"flag"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/profiler"
@@ -19,6 +21,7 @@ import (
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/dcensus"
+	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/frontend"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
@@ -36,7 +39,8 @@ var (
 	thirdPartyPath = flag.String("third_party", "third_party", "path to folder containing third-party libraries")
 	devMode        = flag.Bool("dev", false, "enable developer mode (reload templates on each page load, serve non-minified JS/CSS, etc.)")
 	disableCSP     = flag.Bool("nocsp", false, "disable Content Security Policy")
-	proxyURL       = flag.String("proxy_url", "https://proxy.golang.org", "Uses the module proxy referred to by this URL "+
+	cspHashes      = flag.Bool("csp_script_hashes", false, "allow inline scripts in the Content Security Policy by their hashes, instead of by a nonce")
+	proxyURL       = flag.String("proxy_url", "https://proxy.golang.org", "Uses the module proxies referred to by this comma-separated list of URLs "+
 		"for direct proxy mode and frontend fetches")
 	directProxy = flag.Bool("direct_proxy", false, "if set to true, uses the module proxy referred to by this URL "+
 		"as a direct backend, bypassing the database")
@@ -59,6 +63,12 @@ func main() {
 	}
 
 	log.SetLevel(cfg.LogLevel)
+	log.SetFormat(cfg.LogFormat)
+	internal.AddDefaultBranches(cfg.DefaultBranches...)
+	// Frontend fetches, and the proxy datasource, process module zips too.
+	if err := fetch.SetZipLimits(*cmdconfig.ZipLimits(cfg)); err != nil {
+		log.Fatal(ctx, err)
+	}
 
 	var (
 		dsg        func(context.Context) internal.DataSource
@@ -72,11 +82,15 @@ func main() {
 	expg := cmdconfig.ExperimentGetter(ctx, cfg)
 	log.Infof(ctx, "cmd/frontend: initialized cmdconfig.ExperimentGetter")
 
-	proxyClient, err := proxy.New(*proxyURL)
+	proxyClient, err := proxy.New(proxy.SplitURLs(*proxyURL))
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
 
+	// The proxy datasource also fetches modules, so it needs the templates too.
+	cmdconfig.SourceTemplates(ctx, cfg)
+
+	var healthChecks []middleware.HealthCheck
 	if *directProxy {
 		var pds *proxydatasource.DataSource
 		if *bypassLicenseCheck {
@@ -92,6 +106,7 @@ func main() {
 		}
 		defer db.Close()
 		dsg = func(context.Context) internal.DataSource { return db }
+		healthChecks = append(healthChecks, middleware.DatabaseHealthCheck(db.Underlying()))
 		sourceClient := source.NewClient(config.SourceTimeout)
 		// The closure passed to queue.New is only used for testing and local
 		// execution, not in production. So it's okay that it doesn't use a
@@ -118,11 +133,20 @@ func main() {
 		CompletionClient:     haClient,
 		TaskIDChangeInterval: config.TaskIDChangeIntervalFrontend,
 		StaticPath:           template.TrustedSourceFromFlag(flag.Lookup("static").Value),
+		StaticCDNURL:         cfg.StaticCDNURL,
+		CanonicalURL:         cfg.CanonicalURL,
 		ThirdPartyPath:       *thirdPartyPath,
 		DevMode:              *devMode,
 		AppVersionLabel:      cfg.AppVersionLabel(),
 		GoogleTagManagerID:   cfg.GoogleTagManagerID,
 		ServeStats:           cfg.ServeStats,
+		ServeAPI:             cfg.ServeAPI,
+		LabelUnstableV0:      cfg.LabelUnstableV0,
+		MaintenanceMode:      cfg.MaintenanceMode,
+		ImportedByLimit:      cfg.ImportedByLimit,
+		APIImportedByLimit:   cfg.APIImportedByLimit,
+		ShowInternalPackages: cfg.ShowInternalPackages,
+		VetStdlibVersions:    cfg.VetStdlibVersions,
 		ReportingClient:      rc,
 	})
 	if err != nil {
@@ -137,6 +161,22 @@ func main() {
 		})
 	}
 	server.Install(router.Handle, cacheClient, cfg.AuthValues)
+	if haClient != nil {
+		healthChecks = append(healthChecks, middleware.RedisHealthCheck("redis-ha", haClient))
+	}
+	if cacheClient != nil {
+		healthChecks = append(healthChecks, middleware.RedisHealthCheck("redis-cache", cacheClient))
+	}
+	if cfg.HealthCheckProxy {
+		healthChecks = append(healthChecks, middleware.HealthCheck{Name: "proxy", Required: true, Check: proxyClient.Ping})
+	}
+	router.Handle("/healthz", middleware.HealthHandler(healthChecks...))
+	if strings.HasPrefix(cfg.CSPReportURI, "/") {
+		router.Handle(cfg.CSPReportURI, middleware.CSPReportHandler())
+	}
+	if cfg.CSPReportOnly && cfg.CSPReportURI == "" {
+		log.Warning(ctx, "CSP is report-only, but there is no report URI")
+	}
 	views := append(dcensus.ServerViews,
 		postgres.SearchLatencyDistribution,
 		postgres.SearchResponseCount,
@@ -148,6 +188,8 @@ func main() {
 		middleware.CacheErrorCount,
 		middleware.CacheLatency,
 		middleware.QuotaResultCount,
+		queue.QueueDepth,
+		queue.RefetchCount,
 	)
 	if err := dcensus.Init(cfg, views...); err != nil {
 		log.Fatal(ctx, err)
@@ -169,17 +211,39 @@ func main() {
 	experimenter := cmdconfig.Experimenter(ctx, cfg, expg, rc)
 	log.Infof(ctx, "cmd/frontend: initialized cmdconfig.Experimenter")
 
+	var staticHosts []string
+	if cfg.StaticCDNURL != "" {
+		u, err := url.Parse(cfg.StaticCDNURL)
+		if err != nil {
+			log.Fatalf(ctx, "parsing static CDN URL: %v", err)
+		}
+		staticHosts = append(staticHosts, u.Scheme+"://"+u.Host)
+	}
+	ccmw := middleware.Identity()
+	if !*devMode {
+		ccmw = middleware.CacheControl(middleware.DefaultCacheControlPolicies()...)
+	}
 	ermw := middleware.Identity()
 	if rc != nil {
 		ermw = middleware.ErrorReporting(rc.Report)
 	}
 	mw := middleware.Chain(
-		middleware.RequestLog(cmdconfig.Logger(ctx, cfg, "frontend-log")),
+		middleware.RequestID(cfg.TrustRequestIDHeader),
+		middleware.RequestLog(cmdconfig.Logger(ctx, cfg, "frontend-log"), cfg.RateLimit.ClientIPHop),
 		middleware.AcceptRequests(http.MethodGet, http.MethodPost, http.MethodHead), // accept only GETs, POSTs and HEADs
 		middleware.BetaPkgGoDevRedirect(),
+		middleware.TrailingSlash(server.SubtreeRoots(), "/_ah/", "/play/", "/static/", "/third_party/"), // these handlers serve directories
 		middleware.Quota(cfg.Quota, cacheClient),
-		middleware.SecureHeaders(!*disableCSP), // must come before any caching for nonces to work
-		middleware.Experiment(experimenter),
+		middleware.RateLimit(cfg.RateLimit, "/static/", "/third_party/"),
+		middleware.SecureHeadersWithSettings(middleware.CSPSettings{
+			Enable:       !*disableCSP,
+			ScriptHashes: *cspHashes,
+			ReportURI:    cfg.CSPReportURI,
+			ReportOnly:   cfg.CSPReportOnly,
+			StaticHosts:  staticHosts,
+		}), // must come before any caching for nonces to work
+		ccmw,
+		middleware.StickyExperiment(experimenter, cfg.ExperimentCookieKey),
 		middleware.Panic(panicHandler),
 		ermw,
 		middleware.Timeout(54*time.Second),
@@ -19,9 +19,11 @@ import (
 	"golang.org/x/pkgsite/internal/config/dynconfig"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/source"
 )
 
 // Logger configures a middleware.Logger.
@@ -63,6 +65,40 @@ func Experimenter(ctx context.Context, cfg *config.Config, getter middleware.Exp
 	return e
 }
 
+// SourceTemplates registers the source URL templates in
+// cfg.SourceTemplatesFile, if any.
+func SourceTemplates(ctx context.Context, cfg *config.Config) {
+	if cfg.SourceTemplatesFile == "" {
+		return
+	}
+	ts, err := source.ReadHostTemplates(cfg.SourceTemplatesFile)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	if err := source.RegisterHostTemplates(ts); err != nil {
+		log.Fatal(ctx, err)
+	}
+	log.Infof(ctx, "using %d source URL templates from %s", len(ts), cfg.SourceTemplatesFile)
+}
+
+// ZipLimits returns the limits on the sizes of module zips in
+// cfg.MaxInFlightZipMi and cfg.MaxModuleZipMi. Negative values mean there is
+// no limit.
+func ZipLimits(cfg *config.Config) *fetch.ZipLimits {
+	return &fetch.ZipLimits{
+		MaxInFlightMi: nonNegative(cfg.MaxInFlightZipMi),
+		MaxModuleMi:   nonNegative(cfg.MaxModuleZipMi),
+	}
+}
+
+// nonNegative returns n, or zero if n is negative.
+func nonNegative(n int) uint64 {
+	if n < 0 {
+		return 0
+	}
+	return uint64(n)
+}
+
 // ExperimentGetter returns an ExperimentGetter using the config.
 func ExperimentGetter(ctx context.Context, cfg *config.Config) middleware.ExperimentGetter {
 	if cfg.DynamicConfigLocation == "" {
@@ -213,6 +213,7 @@ func main() {
 	cfg.Dump(os.Stderr)
 
 	log.SetLevel(cfg.LogLevel)
+	log.SetFormat(cfg.LogFormat)
 	if cfg.OnGCP() {
 		if _, err := log.UseStackdriver(ctx, cfg, "prober-log"); err != nil {
 			log.Fatal(ctx, err)
@@ -12,7 +12,9 @@ import (
 	"flag"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/profiler"
@@ -20,6 +22,7 @@ import (
 	"github.com/google/safehtml/template"
 	_ "github.com/jackc/pgx/v4/stdlib" // for pgx driver
 	"golang.org/x/pkgsite/cmd/internal/cmdconfig"
+	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/dcensus"
 	"golang.org/x/pkgsite/internal/fetch"
@@ -40,6 +43,7 @@ var (
 	// flag used in call to safehtml/template.TrustedSourceFromFlag
 	_                  = flag.String("static", "content/static", "path to folder containing static files served")
 	bypassLicenseCheck = flag.Bool("bypass_license_check", false, "insert all data into the DB, even for non-redistributable paths")
+	moduleCacheDir     = flag.String("module_cache", "", "if set, read modules from this directory, laid out like a module cache, instead of from the module proxy")
 )
 
 func main() {
@@ -54,6 +58,7 @@ func main() {
 	cfg.Dump(os.Stdout)
 
 	log.SetLevel(cfg.LogLevel)
+	log.SetFormat(cfg.LogFormat)
 
 	if cfg.UseProfiler {
 		if err := profiler.Start(profiler.Config{}); err != nil {
@@ -75,10 +80,33 @@ func main() {
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
-	proxyClient, err := proxy.New(cfg.ProxyURL)
+	var proxyClient *proxy.Client
+	if *moduleCacheDir != "" {
+		proxyClient, err = proxy.NewLocalClient(*moduleCacheDir)
+	} else {
+		proxyClient, err = proxy.New(proxy.SplitURLs(cfg.ProxyURL))
+		if err == nil && cfg.SumDBURL != "off" {
+			proxyClient = proxyClient.WithChecksumDB(proxy.NewSumDB(cfg.SumDBURL), cfg.NoSumCheck)
+		}
+	}
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	redisHAClient := getHARedis(ctx, cfg)
+	redisCacheClient := getCacheRedis(ctx, cfg)
+	switch cfg.ProxyCache {
+	case "":
+	case "memory":
+		proxyClient = proxyClient.WithCache(proxy.NewMemoryCache(cfg.ProxyCacheSize))
+	case "redis":
+		if redisCacheClient == nil {
+			log.Fatal(ctx, "proxy cache: GO_DISCOVERY_REDIS_HOST is not set")
+		}
+		proxyClient = proxyClient.WithCache(cache.New(redisCacheClient))
+	default:
+		log.Fatalf(ctx, "unknown proxy cache %q", cfg.ProxyCache)
+	}
+	cmdconfig.SourceTemplates(ctx, cfg)
 	sourceClient := source.NewClient(config.SourceTimeout)
 	expg := cmdconfig.ExperimentGetter(ctx, cfg)
 	fetchQueue, err := queue.New(ctx, cfg, queueName, *workers, expg,
@@ -87,6 +115,12 @@ func main() {
 				ProxyClient:  proxyClient,
 				SourceClient: sourceClient,
 				DB:           db,
+				Options: fetch.FetchOptions{
+					AllowMajorVersionMismatch:      cfg.AllowMajorVersionMismatch,
+					NoExportedAPILabel:             cfg.NoExportedAPILabel,
+					ExcludeNoExportedAPIFromSearch: cfg.ExcludeNoExportedAPIFromSearch,
+					LicenseCoverageThreshold:       cfg.LicenseCoverageThreshold,
+				},
 			}
 			code, _, err := f.FetchAndUpdateState(ctx, modulePath, version, cfg.AppVersionLabel())
 			return code, err
@@ -96,8 +130,6 @@ func main() {
 	}
 
 	reportingClient := cmdconfig.ReportingClient(ctx, cfg)
-	redisHAClient := getHARedis(ctx, cfg)
-	redisCacheClient := getCacheRedis(ctx, cfg)
 	experimenter := cmdconfig.Experimenter(ctx, cfg, expg, reportingClient)
 	server, err := worker.NewServer(cfg, worker.ServerConfig{
 		DB:               db,
@@ -110,18 +142,25 @@ func main() {
 		ReportingClient:  reportingClient,
 		StaticPath:       template.TrustedSourceFromFlag(flag.Lookup("static").Value),
 		GetExperiments:   experimenter.Experiments,
+		ZipLimits:        cmdconfig.ZipLimits(cfg),
 	})
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
 	router := dcensus.NewRouter(nil)
 	server.Install(router.Handle)
+	go drainOnSIGTERM(ctx, server)
 
 	views := append(dcensus.ServerViews,
 		worker.EnqueueResponseCount,
 		worker.ProcessingLag,
+		worker.FetchOutcomeCount,
+		queue.QueueDepth,
+		queue.RefetchCount,
 		fetch.FetchLatencyDistribution,
 		fetch.FetchResponseCount,
+		fetch.FetchLatencyByModuleGroup,
+		fetch.FetchZipSizeByModuleGroup,
 		fetch.SheddedFetchCount,
 		fetch.FetchPackageCount)
 	if err := dcensus.Init(cfg, views...); err != nil {
@@ -134,7 +173,12 @@ func main() {
 		if err != nil {
 			log.Fatal(ctx, err)
 		}
-		go http.ListenAndServe(cfg.DebugAddr("localhost:8001"), dcensusServer)
+		// The worker status is served only on the debug address, which is
+		// not exposed externally.
+		debugMux := http.NewServeMux()
+		debugMux.Handle("/", dcensusServer)
+		debugMux.Handle("/status", server.StatusHandler())
+		go http.ListenAndServe(cfg.DebugAddr("localhost:8001"), debugMux)
 	}
 
 	iap := middleware.Identity()
@@ -143,7 +187,8 @@ func main() {
 	}
 
 	mw := middleware.Chain(
-		middleware.RequestLog(cmdconfig.Logger(ctx, cfg, "worker-log")),
+		middleware.RequestID(cfg.TrustRequestIDHeader),
+		middleware.RequestLog(cmdconfig.Logger(ctx, cfg, "worker-log"), cfg.RateLimit.ClientIPHop),
 		middleware.Timeout(time.Duration(timeout)*time.Minute),
 		iap,
 		middleware.Experiment(experimenter),
@@ -155,6 +200,22 @@ func main() {
 	log.Fatal(ctx, http.ListenAndServe(addr, nil))
 }
 
+// drainOnSIGTERM waits for SIGTERM, which is sent before an instance is shut
+// down, and then drains the server and exits.
+func drainOnSIGTERM(ctx context.Context, server *worker.Server) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	<-sigs
+	log.Infof(ctx, "received SIGTERM; draining")
+	ctx, cancel := context.WithTimeout(ctx, worker.DrainTimeout)
+	err := server.Drain(ctx)
+	cancel()
+	if err != nil {
+		log.Error(ctx, err)
+	}
+	os.Exit(0)
+}
+
 func getHARedis(ctx context.Context, cfg *config.Config) *redis.Client {
 	// We update completions with one big pipeline, so we need long write
 	// timeouts. ReadTimeout is increased only to be consistent with
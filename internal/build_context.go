@@ -4,7 +4,11 @@
 
 package internal
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // A BuildContext describes a build context for the Go tool: information needed
 // to build a Go package. For our purposes, we only care about the information
@@ -40,8 +44,84 @@ var BuildContexts = []BuildContext{
 	BuildContextJS,
 }
 
+// Ports are the GOOS/GOARCH combinations supported by the go command, as
+// reported by "go tool dist list", with the linux ports first.
+// Besides BuildContexts, we load a package for the first port of each GOOS
+// and GOARCH that its files mention in their names or build constraints (see
+// internal/fetch/load.go).
+var Ports = []BuildContext{
+	{"linux", "386"},
+	{"linux", "amd64"},
+	{"linux", "arm"},
+	{"linux", "arm64"},
+	{"linux", "loong64"},
+	{"linux", "mips"},
+	{"linux", "mips64"},
+	{"linux", "mips64le"},
+	{"linux", "mipsle"},
+	{"linux", "ppc64"},
+	{"linux", "ppc64le"},
+	{"linux", "riscv64"},
+	{"linux", "s390x"},
+	{"aix", "ppc64"},
+	{"android", "386"},
+	{"android", "amd64"},
+	{"android", "arm"},
+	{"android", "arm64"},
+	{"darwin", "amd64"},
+	{"darwin", "arm64"},
+	{"dragonfly", "amd64"},
+	{"freebsd", "386"},
+	{"freebsd", "amd64"},
+	{"freebsd", "arm"},
+	{"freebsd", "arm64"},
+	{"illumos", "amd64"},
+	{"ios", "amd64"},
+	{"ios", "arm64"},
+	{"js", "wasm"},
+	{"netbsd", "386"},
+	{"netbsd", "amd64"},
+	{"netbsd", "arm"},
+	{"netbsd", "arm64"},
+	{"openbsd", "386"},
+	{"openbsd", "amd64"},
+	{"openbsd", "arm"},
+	{"openbsd", "arm64"},
+	{"openbsd", "ppc64"},
+	{"openbsd", "riscv64"},
+	{"plan9", "386"},
+	{"plan9", "amd64"},
+	{"plan9", "arm"},
+	{"solaris", "amd64"},
+	{"wasip1", "wasm"},
+	{"windows", "386"},
+	{"windows", "amd64"},
+	{"windows", "arm64"},
+}
+
+// ParseBuildContext parses s, which has the form "GOOS/GOARCH" written by
+// BuildContext.String, except that either element may be empty. It reports
+// false if s does not have that form, or if a non-empty element is not the
+// GOOS or GOARCH of one of Ports.
+func ParseBuildContext(s string) (BuildContext, bool) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return BuildContext{}, false
+	}
+	bc := BuildContext{GOOS: s[:i], GOARCH: s[i+1:]}
+	goosOK, goarchOK := bc.GOOS == "", bc.GOARCH == ""
+	for _, p := range Ports {
+		goosOK = goosOK || p.GOOS == bc.GOOS
+		goarchOK = goarchOK || p.GOARCH == bc.GOARCH
+	}
+	if !goosOK || !goarchOK {
+		return BuildContext{}, false
+	}
+	return bc, true
+}
+
 // CompareBuildContexts returns a negative number, 0, or a positive number depending on
-// the relative positions of c1 and c2 in BuildContexts.
+// the relative positions of c1 and c2 in BuildContexts, followed by Ports.
 func CompareBuildContexts(c1, c2 BuildContext) int {
 	if c1 == c2 {
 		return 0
@@ -57,11 +137,27 @@ func CompareBuildContexts(c1, c2 BuildContext) int {
 				return i
 			}
 		}
-		return len(BuildContexts) // unknowns sort last
+		for i, d := range Ports {
+			if c == d {
+				return len(BuildContexts) + i
+			}
+		}
+		return len(BuildContexts) + len(Ports) // unknowns sort last
 	}
 	return pos(c1) - pos(c2)
 }
 
+// SortBuildContexts sorts bcs in the order of CompareBuildContexts, except
+// that BuildContextAll, which may appear in bcs, comes first.
+func SortBuildContexts(bcs []BuildContext) {
+	sort.Slice(bcs, func(i, j int) bool {
+		if bcs[i] == BuildContextAll || bcs[j] == BuildContextAll {
+			return bcs[i] == BuildContextAll && bcs[j] != BuildContextAll
+		}
+		return CompareBuildContexts(bcs[i], bcs[j]) < 0
+	})
+}
+
 // BuildContext returns the BuildContext for d.
 func (d *Documentation) BuildContext() BuildContext {
 	return BuildContext{GOOS: d.GOOS, GOARCH: d.GOARCH}
@@ -4,7 +4,11 @@
 
 package internal
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
 
 func TestCompareBuildContexts(t *testing.T) {
 	check := func(c1, c2 BuildContext, want int) {
@@ -35,5 +39,40 @@ func TestCompareBuildContexts(t *testing.T) {
 	}
 
 	// Special cases.
-	check(BuildContext{"?", "?"}, BuildContexts[len(BuildContexts)-1], 1) // unknown is last
+	check(BuildContext{"?", "?"}, BuildContexts[len(BuildContexts)-1], 1)       // unknown is last
+	check(BuildContext{"linux", "arm"}, BuildContexts[len(BuildContexts)-1], 1) // ports follow BuildContexts
+	check(BuildContext{"linux", "arm"}, BuildContext{"plan9", "arm"}, -1)
+	check(BuildContext{"?", "?"}, Ports[len(Ports)-1], 1)
+}
+
+func TestParseBuildContext(t *testing.T) {
+	for _, test := range []struct {
+		in     string
+		want   BuildContext
+		wantOK bool
+	}{
+		{"linux/amd64", BuildContext{"linux", "amd64"}, true},
+		{"windows/", BuildContext{GOOS: "windows"}, true},
+		{"/arm64", BuildContext{GOARCH: "arm64"}, true},
+		{"/", BuildContext{}, true},
+		{"", BuildContext{}, false},
+		{"linux", BuildContext{}, false},
+		{"plan10/amd64", BuildContext{}, false},
+		{"linux/amd64/x", BuildContext{}, false},
+		{"linux/amd64\x00junk", BuildContext{}, false},
+	} {
+		got, ok := ParseBuildContext(test.in)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("ParseBuildContext(%q) = %v, %t; want %v, %t", test.in, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestSortBuildContexts(t *testing.T) {
+	got := []BuildContext{{"linux", "arm"}, BuildContextWindows, BuildContextAll, BuildContextLinux}
+	SortBuildContexts(got)
+	want := []BuildContext{BuildContextAll, BuildContextLinux, BuildContextWindows, {"linux", "arm"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
 }
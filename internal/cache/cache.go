@@ -8,6 +8,7 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -44,6 +45,32 @@ func (c *Cache) Put(ctx context.Context, key string, data []byte, ttl time.Durat
 	return err
 }
 
+// PutInSet is like Put, but also adds key to the set named setKey, so that
+// all the keys in the set can be deleted at once with DeleteSet. The set
+// expires with the last key put in it.
+func (c *Cache) PutInSet(ctx context.Context, setKey, key string, data []byte, ttl time.Duration) (err error) {
+	defer derrors.Wrap(&err, "PutInSet(%q, %q, data, %s)", setKey, key, ttl)
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, key, data, ttl)
+	pipe.SAdd(ctx, setKey, key)
+	if ttl > 0 {
+		pipe.Expire(ctx, setKey, ttl)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// DeleteSet deletes the keys added to the set named setKey by PutInSet, and
+// the set itself. Unlike DeletePrefix, it does not scan the keyspace.
+func (c *Cache) DeleteSet(ctx context.Context, setKey string) (err error) {
+	defer derrors.Wrap(&err, "DeleteSet(%q)", setKey)
+	keys, err := c.client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return err
+	}
+	return c.Delete(ctx, append(keys, setKey)...)
+}
+
 // Clear deletes all entries from the cache.
 func (c *Cache) Clear(ctx context.Context) (err error) {
 	defer derrors.Wrap(&err, "Clear()")
@@ -87,3 +114,17 @@ func (c *Cache) DeletePrefix(ctx context.Context, prefix string) (err error) {
 // Also used as the batch size for Delete calls in DeletePrefix.
 // var for testing.
 var scanCount = 100
+
+// MainDetailsKey returns the key under which the frontend caches the main tab
+// details of the unit at fullPath in the given module version, for the
+// build context goos/goarch requested by the user.
+func MainDetailsKey(fullPath, modulePath, resolvedVersion, goos, goarch string) string {
+	return fmt.Sprintf("main-details/%s@%s/%s?GOOS=%s&GOARCH=%s", modulePath, resolvedVersion, fullPath, goos, goarch)
+}
+
+// MainDetailsSetKey returns the key of the set of the keys of all the main
+// tab details cached for the module version, so that they can be deleted
+// with DeleteSet when it is fetched again.
+func MainDetailsSetKey(modulePath, resolvedVersion string) string {
+	return "main-details-keys/" + modulePath + "@" + resolvedVersion
+}
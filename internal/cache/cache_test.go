@@ -8,6 +8,7 @@ import (
 	"context"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
@@ -79,6 +80,33 @@ func TestDeletePrefix(t *testing.T) {
 	check([]string{})
 }
 
+func TestDeleteSet(t *testing.T) {
+	ctx := context.Background()
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c := New(redis.NewClient(&redis.Options{Addr: s.Addr()}))
+
+	must(t, c.PutInSet(ctx, "set1", "a", []byte("value"), time.Hour))
+	must(t, c.PutInSet(ctx, "set1", "b", []byte("value"), time.Hour))
+	must(t, c.PutInSet(ctx, "set2", "c", []byte("value"), time.Hour))
+	must(t, c.Put(ctx, "d", []byte("value"), 0))
+
+	must(t, c.DeleteSet(ctx, "set1"))
+	got, err := c.client.Keys(ctx, "*").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if want := []string{"c", "d", "set2"}; !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Deleting a set that does not exist is not an error.
+	must(t, c.DeleteSet(ctx, "set1"))
+}
+
 func must(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
@@ -27,6 +27,7 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/ghodss/yaml"
+	"golang.org/x/mod/semver"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/secrets"
@@ -106,6 +107,11 @@ const (
 	// BypassErrorReportingHeader is the header key used by the ErrorReporting middleware
 	// to avoid calling the errorreporting service.
 	BypassErrorReportingHeader = "X-Go-Discovery-Bypass-Error-Reporting"
+
+	// AdminAuthHeader is the header key used by the worker to authorize
+	// requests to its /admin/ endpoints. Its value must be one of the
+	// AuthValues.
+	AdminAuthHeader = "X-Go-Discovery-Auth-Admin"
 )
 
 // Config holds shared configuration values used in instantiating our server
@@ -137,10 +143,25 @@ type Config struct {
 	// IAP that is gating access to the worker.
 	QueueAudience string
 
+	// HighPriorityQueueID is the name of the Cloud Tasks queue for
+	// high-priority fetches, such as those requested from the frontend. If it
+	// is empty, they are put on the same queue as other fetches.
+	HighPriorityQueueID string
+
 	// GoogleTagManagerID is the ID used for GoogleTagManager. It has the
 	// structure GTM-XXXX.
 	GoogleTagManagerID string
 
+	// StaticCDNURL is the base URL of a CDN that serves the contents of the
+	// static directory, for example "https://cdn.example.com". If empty,
+	// static assets are served from the same origin as the frontend.
+	StaticCDNURL string
+
+	// CanonicalURL is the base URL of the frontend's canonical pages, like
+	// "https://pkg.go.dev". Absolute links in sitemaps and feeds use it
+	// rather than the host of the request.
+	CanonicalURL string
+
 	// MonitoredResource represents the resource that is running the current binary.
 	// It might be a Google AppEngine app or a Kubernetes pod.
 	// See https://cloud.google.com/monitoring/api/resources for more
@@ -166,16 +187,70 @@ type Config struct {
 	// cache instance as it has different availability requirements.
 	RedisHAHost, RedisHAPort string
 
+	// ProxyCache selects where the worker caches the responses to proxy .info
+	// and .mod requests for resolved versions: "memory" for an in-memory LRU
+	// cache, "redis" for the redis page cache, or empty for no cache.
+	ProxyCache string
+
+	// ProxyCacheSize is the number of responses held by the in-memory proxy
+	// cache.
+	ProxyCacheSize int
+
+	// SumDBURL is the URL of the checksum database that the worker verifies
+	// module zips against, or "off" to skip verification for all modules.
+	SumDBURL string
+
+	// NoSumCheck holds glob patterns for module path prefixes whose zips are
+	// not verified against the checksum database, such as internal modules
+	// that are not recorded there. The patterns have the same form as those
+	// in GONOSUMDB; see "go help module-private".
+	NoSumCheck []string
+
+	// SourceTemplatesFile is the name of a YAML file with source URL templates
+	// for code hosts that are not otherwise known, such as self-hosted Gitea
+	// or GitLab instances. See source.HostTemplate.
+	SourceTemplatesFile string
+
+	// AllowMajorVersionMismatch determines whether the worker accepts modules
+	// whose go.mod path differs from the module path only in its major version
+	// suffix. See fetch.FetchOptions.AllowMajorVersionMismatch.
+	AllowMajorVersionMismatch bool
+
+	// NoExportedAPILabel and ExcludeNoExportedAPIFromSearch control how the
+	// worker treats packages that have a package comment but export nothing.
+	// See the fetch.FetchOptions fields of the same names.
+	NoExportedAPILabel             string
+	ExcludeNoExportedAPIFromSearch bool
+
+	// LicenseCoverageThreshold is the minimum percentage of a license file
+	// that must match a known license. See
+	// fetch.FetchOptions.LicenseCoverageThreshold.
+	LicenseCoverageThreshold float64
+
 	// UseProfiler specifies whether to enable Stackdriver Profiler.
 	UseProfiler bool
 
 	Quota QuotaSettings
 
+	// RateLimit configures the in-memory, per-IP rate limiter of the
+	// frontend. See middleware.RateLimit.
+	RateLimit RateLimitSettings
+
+	// ExperimentCookieKey is the key used to sign the cookie that makes
+	// experiment assignments sticky. If empty, experiments are assigned by
+	// IP on every request. See middleware.StickyExperiment.
+	ExperimentCookieKey []byte `json:"-"`
+
 	// Minimum log level below which no logs will be printed.
 	// Possible values are [debug, info, error, fatal].
 	// In case of invalid/empty value, all logs will be printed.
 	LogLevel string
 
+	// LogFormat is the format of the logs that are not sent to Stackdriver.
+	// It is either "json", for one JSON object per line, or empty, for
+	// plain text.
+	LogFormat string
+
 	// DynamicConfigLocation is the location (either a file or gs://bucket/object) for
 	// dynamic configuration.
 	DynamicConfigLocation string
@@ -184,8 +259,74 @@ type Config struct {
 	// benchmarking or other purposes.
 	ServeStats bool
 
+	// ServeAPI determines whether the frontend serves the JSON API.
+	ServeAPI bool
+
+	// MaintenanceMode determines whether the frontend serves only cached
+	// pages, without accessing the database.
+	MaintenanceMode bool
+
+	// ImportedByLimit is the maximum number of importers the frontend shows on
+	// the imported by tab. If zero, the frontend's default is used.
+	ImportedByLimit int
+
+	// APIImportedByLimit is the maximum number of importers the frontend
+	// serves from /api/imported-by. If zero, the frontend's default is used.
+	APIImportedByLimit int
+
+	// MaxInFlightZipMi is the total size, in mebibytes, of the module zips
+	// that the worker, or the frontend when it fetches, processes at once.
+	// MaxModuleZipMi is the size of the largest module zip it processes. If
+	// zero or negative, there is no limit.
+	MaxInFlightZipMi, MaxModuleZipMi int
+
+	// ShowInternalPackages determines whether the frontend lists nested
+	// internal packages in directory listings, and shows the importers of an
+	// internal package from within its own module.
+	ShowInternalPackages bool
+
+	// HealthCheckProxy determines whether /healthz checks that the module
+	// proxy can be reached, in addition to the database and redis.
+	HealthCheckProxy bool
+
+	// TrustRequestIDHeader determines whether the servers use the request ID
+	// in the X-Request-ID header of incoming requests, instead of generating
+	// one. It should be set only when the header comes from a trusted source,
+	// like a load balancer.
+	TrustRequestIDHeader bool
+
+	// CSPReportURI is the URI to which browsers report violations of the
+	// frontend's content-security-policy. If it is a path, like
+	// "/csp-report", the frontend serves it and logs the reports. If empty,
+	// violations are not reported.
+	CSPReportURI string
+
+	// CSPReportOnly determines whether the frontend's content-security-policy
+	// only reports violations to CSPReportURI instead of enforcing the
+	// policy.
+	CSPReportOnly bool
+
+	// TraceSampleRate is the fraction of requests whose traces are sampled.
+	// Responses with a server error are always traced.
+	TraceSampleRate float64
+
+	// DefaultBranches are branch names that are treated like master and main:
+	// requests for a unit at one of them schedule a fetch, so that the page
+	// does not go stale.
+	DefaultBranches []string
+
 	// DisableErrorReporting disables sending errors to the GCP ErrorReporting system.
 	DisableErrorReporting bool
+
+	// LabelUnstableV0 determines whether the frontend labels v0 versions of
+	// modules as unstable, and points users of a v0 version to the v1 release
+	// of its module when there is one.
+	LabelUnstableV0 bool
+
+	// VetStdlibVersions are the Go versions, like "go1.16", whose standard
+	// library the frontend loads at startup so that the playground's fmt
+	// endpoint can vet programs against them.
+	VetStdlibVersions []string
 }
 
 // AppVersionLabel returns the version label for the current instance.  This is
@@ -332,6 +473,20 @@ type QuotaSettings struct {
 	HMACKey    []byte `json:"-"` // key for obfuscating IPs
 }
 
+// RateLimitSettings is config for internal/middleware/ratelimit.go.
+type RateLimitSettings struct {
+	Enable     bool
+	QPS        int // tokens added to each IP block's bucket per second
+	Burst      int // the size of each token bucket
+	MaxEntries int // maximum number of IP blocks to keep track of
+
+	// ClientIPHop is the position, counting from 1 at the end, of the entry
+	// of the X-Forwarded-For header that holds the client IP, as appended by
+	// the load balancer. If it is zero, the address of the connection is
+	// used. It also determines the client IP in request logs.
+	ClientIPHop int
+}
+
 // Init resolves all configuration values provided by the config package. It
 // must be called before any configuration values are used.
 func Init(ctx context.Context) (_ *Config, err error) {
@@ -345,13 +500,16 @@ func Init(ctx context.Context) (_ *Config, err error) {
 		Port:       os.Getenv("PORT"),
 		DebugPort:  os.Getenv("DEBUG_PORT"),
 		// Resolve AppEngine identifiers
-		ProjectID:          os.Getenv("GOOGLE_CLOUD_PROJECT"),
-		ServiceID:          GetEnv("GAE_SERVICE", os.Getenv("GO_DISCOVERY_SERVICE")),
-		VersionID:          GetEnv("GAE_VERSION", os.Getenv("DOCKER_IMAGE")),
-		InstanceID:         GetEnv("GAE_INSTANCE", os.Getenv("GO_DISCOVERY_INSTANCE")),
-		GoogleTagManagerID: os.Getenv("GO_DISCOVERY_GOOGLE_TAG_MANAGER_ID"),
-		QueueURL:           os.Getenv("GO_DISCOVERY_QUEUE_URL"),
-		QueueAudience:      os.Getenv("GO_DISCOVERY_QUEUE_AUDIENCE"),
+		ProjectID:           os.Getenv("GOOGLE_CLOUD_PROJECT"),
+		ServiceID:           GetEnv("GAE_SERVICE", os.Getenv("GO_DISCOVERY_SERVICE")),
+		VersionID:           GetEnv("GAE_VERSION", os.Getenv("DOCKER_IMAGE")),
+		InstanceID:          GetEnv("GAE_INSTANCE", os.Getenv("GO_DISCOVERY_INSTANCE")),
+		GoogleTagManagerID:  os.Getenv("GO_DISCOVERY_GOOGLE_TAG_MANAGER_ID"),
+		StaticCDNURL:        os.Getenv("GO_DISCOVERY_STATIC_CDN_URL"),
+		CanonicalURL:        GetEnv("GO_DISCOVERY_CANONICAL_URL", "https://pkg.go.dev"),
+		QueueURL:            os.Getenv("GO_DISCOVERY_QUEUE_URL"),
+		QueueAudience:       os.Getenv("GO_DISCOVERY_QUEUE_AUDIENCE"),
+		HighPriorityQueueID: os.Getenv("GO_DISCOVERY_HIGH_PRIORITY_TASK_QUEUE"),
 
 		// LocationID is essentially hard-coded until we figure out a good way to
 		// determine it programmatically, but we check an environment variable in
@@ -371,6 +529,10 @@ func Init(ctx context.Context) (_ *Config, err error) {
 		RedisCachePort:       GetEnv("GO_DISCOVERY_REDIS_PORT", "6379"),
 		RedisHAHost:          os.Getenv("GO_DISCOVERY_REDIS_HA_HOST"),
 		RedisHAPort:          GetEnv("GO_DISCOVERY_REDIS_HA_PORT", "6379"),
+		ProxyCache:           os.Getenv("GO_DISCOVERY_PROXY_CACHE"),
+		ProxyCacheSize:       GetEnvInt("GO_DISCOVERY_PROXY_CACHE_SIZE", 10000),
+		SumDBURL:             GetEnv("GO_DISCOVERY_SUMDB_URL", "https://sum.golang.org"),
+		NoSumCheck:           parseCommaList(os.Getenv("GO_DISCOVERY_NOSUMCHECK")),
 		Quota: QuotaSettings{
 			Enable:     os.Getenv("GO_DISCOVERY_ENABLE_QUOTA") == "true",
 			QPS:        GetEnvInt("GO_DISCOVERY_QUOTA_QPS", 10),
@@ -382,10 +544,44 @@ func Init(ctx context.Context) (_ *Config, err error) {
 			}(),
 			AuthValues: parseCommaList(os.Getenv("GO_DISCOVERY_AUTH_VALUES")),
 		},
-		UseProfiler:           os.Getenv("GO_DISCOVERY_USE_PROFILER") == "true",
-		LogLevel:              os.Getenv("GO_DISCOVERY_LOG_LEVEL"),
-		ServeStats:            os.Getenv("GO_DISCOVERY_SERVE_STATS") == "true",
-		DisableErrorReporting: os.Getenv("GO_DISCOVERY_DISABLE_ERROR_REPORTING") == "true",
+		RateLimit: RateLimitSettings{
+			Enable:      os.Getenv("GO_DISCOVERY_ENABLE_RATE_LIMIT") == "true",
+			QPS:         GetEnvInt("GO_DISCOVERY_RATE_LIMIT_QPS", 5),
+			Burst:       GetEnvInt("GO_DISCOVERY_RATE_LIMIT_BURST", 20),
+			MaxEntries:  10000,
+			ClientIPHop: GetEnvInt("GO_DISCOVERY_RATE_LIMIT_CLIENT_IP_HOP", 1),
+		},
+		UseProfiler:                    os.Getenv("GO_DISCOVERY_USE_PROFILER") == "true",
+		LogLevel:                       os.Getenv("GO_DISCOVERY_LOG_LEVEL"),
+		LogFormat:                      os.Getenv("GO_DISCOVERY_LOG_FORMAT"),
+		ServeStats:                     os.Getenv("GO_DISCOVERY_SERVE_STATS") == "true",
+		ServeAPI:                       os.Getenv("GO_DISCOVERY_SERVE_API") == "true",
+		DisableErrorReporting:          os.Getenv("GO_DISCOVERY_DISABLE_ERROR_REPORTING") == "true",
+		LabelUnstableV0:                os.Getenv("GO_DISCOVERY_LABEL_UNSTABLE_V0") == "true",
+		AllowMajorVersionMismatch:      os.Getenv("GO_DISCOVERY_ALLOW_MAJOR_VERSION_MISMATCH") == "true",
+		MaintenanceMode:                os.Getenv("GO_DISCOVERY_MAINTENANCE_MODE") == "true",
+		ImportedByLimit:                GetEnvInt("GO_DISCOVERY_IMPORTED_BY_LIMIT", 0),
+		APIImportedByLimit:             GetEnvInt("GO_DISCOVERY_API_IMPORTED_BY_LIMIT", 0),
+		MaxInFlightZipMi:               GetEnvInt("GO_DISCOVERY_MAX_IN_FLIGHT_ZIP_MI", 0),
+		MaxModuleZipMi:                 GetEnvInt("GO_DISCOVERY_MAX_MODULE_ZIP_MI", 0),
+		ShowInternalPackages:           os.Getenv("GO_DISCOVERY_SHOW_INTERNAL_PACKAGES") == "true",
+		HealthCheckProxy:               os.Getenv("GO_DISCOVERY_HEALTH_CHECK_PROXY") == "true",
+		TrustRequestIDHeader:           os.Getenv("GO_DISCOVERY_TRUST_REQUEST_ID_HEADER") == "true",
+		CSPReportURI:                   os.Getenv("GO_DISCOVERY_CSP_REPORT_URI"),
+		CSPReportOnly:                  os.Getenv("GO_DISCOVERY_CSP_REPORT_ONLY") == "true",
+		NoExportedAPILabel:             os.Getenv("GO_DISCOVERY_NO_EXPORTED_API_LABEL"),
+		ExcludeNoExportedAPIFromSearch: os.Getenv("GO_DISCOVERY_EXCLUDE_NO_EXPORTED_API_FROM_SEARCH") == "true",
+		LicenseCoverageThreshold:       GetEnvFloat64("GO_DISCOVERY_LICENSE_COVERAGE_THRESHOLD", 0),
+		TraceSampleRate:                GetEnvFloat64("GO_DISCOVERY_TRACE_SAMPLE_RATE", 0.01),
+		DefaultBranches:                parseCommaList(os.Getenv("GO_DISCOVERY_DEFAULT_BRANCHES")),
+		VetStdlibVersions:              parseCommaList(os.Getenv("GO_DISCOVERY_VET_STDLIB_VERSIONS")),
+		SourceTemplatesFile:            os.Getenv("GO_DISCOVERY_SOURCE_TEMPLATES_FILE"),
+	}
+	for _, b := range cfg.DefaultBranches {
+		// Versions and "latest" can't also be branches.
+		if b == "latest" || semver.IsValid(b) {
+			return nil, fmt.Errorf("GO_DISCOVERY_DEFAULT_BRANCHES: %q is not a branch name", b)
+		}
 	}
 	bucket := os.Getenv("GO_DISCOVERY_CONFIG_BUCKET")
 	object := os.Getenv("GO_DISCOVERY_CONFIG_DYNAMIC")
@@ -470,6 +666,16 @@ func Init(ctx context.Context) (_ *Config, err error) {
 	} else {
 		log.Print("quota enforcement disabled")
 	}
+	if k := os.Getenv("GO_DISCOVERY_EXPERIMENT_COOKIE_KEY"); k != "" {
+		key, err := hex.DecodeString(k)
+		if err != nil {
+			return nil, fmt.Errorf("GO_DISCOVERY_EXPERIMENT_COOKIE_KEY: %v", err)
+		}
+		if len(key) < 16 {
+			return nil, errors.New("experiment cookie key must be at least 16 bytes")
+		}
+		cfg.ExperimentCookieKey = key
+	}
 
 	// If GO_DISCOVERY_CONFIG_OVERRIDE is set, it should point to a file in a
 	// configured bucket which provides overrides for selected configuration.
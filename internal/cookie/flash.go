@@ -18,6 +18,14 @@ import (
 // a request was redirected from.
 const AlternativeModuleFlash = "tmp-redirected-from-alternative-module"
 
+// BuildContext holds the build context, as "GOOS/GOARCH", that the user last
+// selected on a unit page.
+const BuildContext = "build-context"
+
+// Experiments holds the experiments that a user was assigned to by IP
+// rollout, so that the assignment survives changes of IP address.
+const Experiments = "experiments"
+
 // Extract returns the value of the cookie at name and deletes the cookie.
 func Extract(w http.ResponseWriter, r *http.Request, name string) (_ string, err error) {
 	defer derrors.Wrap(&err, "Extract")
@@ -32,14 +40,24 @@ func Extract(w http.ResponseWriter, r *http.Request, name string) (_ string, err
 	if err != nil {
 		return "", err
 	}
-	http.SetCookie(w, &http.Cookie{
-		Name:    name,
-		Path:    r.URL.Path,
-		Expires: time.Unix(0, 0),
-	})
+	Delete(w, name, r.URL.Path)
 	return val, nil
 }
 
+// Get returns the value of the cookie at name, or the empty string if the
+// cookie is not set. Unlike Extract, it does not delete the cookie.
+func Get(r *http.Request, name string) (_ string, err error) {
+	defer derrors.Wrap(&err, "Get")
+	c, err := r.Cookie(name)
+	if err == http.ErrNoCookie {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("r.Cookie(%q): %v", name, err)
+	}
+	return Base64Value(c)
+}
+
 // Base64Value decodes  the value of c using the Base64 URL encoding and returns it as a string.
 func Base64Value(c *http.Cookie) (string, error) {
 	val, err := base64.URLEncoding.DecodeString(c.Value)
@@ -54,3 +72,22 @@ func Set(w http.ResponseWriter, name, val, urlPath string) {
 	value := base64.URLEncoding.EncodeToString([]byte(val))
 	http.SetCookie(w, &http.Cookie{Name: name, Value: value, Path: urlPath})
 }
+
+// Delete deletes the cookie at the urlPath with name.
+func Delete(w http.ResponseWriter, name, urlPath string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:    name,
+		Path:    urlPath,
+		Expires: time.Unix(0, 0),
+	})
+}
+
+// SetBuildContext remembers the build context goos/goarch in the BuildContext
+// cookie, or deletes the cookie if goos and goarch are both empty.
+func SetBuildContext(w http.ResponseWriter, goos, goarch string) {
+	if goos == "" && goarch == "" {
+		Delete(w, BuildContext, "/")
+		return
+	}
+	Set(w, BuildContext, goos+"/"+goarch, "/")
+}
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 const (
@@ -33,3 +34,35 @@ func TestFlashMessages(t *testing.T) {
 		t.Errorf("got %q, want %q", got, testVal)
 	}
 }
+
+func TestGetAndDelete(t *testing.T) {
+	w := httptest.NewRecorder()
+	Set(w, testName, testVal, "/")
+	r := &http.Request{
+		Header: http.Header{"Cookie": w.Header()["Set-Cookie"]},
+		URL:    &url.URL{Path: "/foo"},
+	}
+	// Get doesn't delete the cookie.
+	for i := 0; i < 2; i++ {
+		got, err := Get(r, testName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != testVal {
+			t.Errorf("got %q, want %q", got, testVal)
+		}
+	}
+	if got, err := Get(r, "other"); err != nil || got != "" {
+		t.Errorf("Get of missing cookie = %q, %v; want empty string, nil", got, err)
+	}
+
+	w = httptest.NewRecorder()
+	Delete(w, testName, "/")
+	resp := w.Result()
+	if len(resp.Cookies()) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(resp.Cookies()))
+	}
+	if c := resp.Cookies()[0]; c.Name != testName || c.Path != "/" || c.Expires.After(time.Unix(1, 0)) {
+		t.Errorf("got cookie %+v, want expired cookie %q at /", c, testName)
+	}
+}
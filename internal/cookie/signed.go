@@ -0,0 +1,52 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// SetSigned is like Set, but signs val with key so that GetSigned can detect
+// values that were not set by the server.
+func SetSigned(w http.ResponseWriter, name, val, urlPath string, key []byte) {
+	Set(w, name, val+"."+sign(name, val, key), urlPath)
+}
+
+// GetSigned returns the value of the cookie at name that was set by
+// SetSigned with the same key. It returns the empty string if there is no
+// such cookie, and an error wrapping derrors.InvalidArgument if the cookie
+// was not signed with key.
+func GetSigned(r *http.Request, name string, key []byte) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetSigned")
+	s, err := Get(r, name)
+	if err != nil || s == "" {
+		return "", err
+	}
+	i := strings.LastIndexByte(s, '.')
+	if i < 0 {
+		return "", fmt.Errorf("%w: unsigned cookie %q", derrors.InvalidArgument, name)
+	}
+	val, sig := s[:i], s[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(sign(name, val, key))) {
+		return "", fmt.Errorf("%w: bad signature for cookie %q", derrors.InvalidArgument, name)
+	}
+	return val, nil
+}
+
+// sign returns the hex-encoded HMAC of the cookie name and value. Including
+// the name prevents the value of one signed cookie from being used as
+// another.
+func sign(name, val string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s=%s", name, val)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestSigned(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	w := httptest.NewRecorder()
+	SetSigned(w, testName, "a.b,c", "/", key)
+	r := &http.Request{
+		Header: http.Header{"Cookie": w.Header()["Set-Cookie"]},
+		URL:    &url.URL{Path: "/foo"},
+	}
+	got, err := GetSigned(r, testName, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a.b,c"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := GetSigned(r, testName, []byte("other key")); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("GetSigned with wrong key: got error %v, want InvalidArgument", err)
+	}
+
+	// An unsigned cookie is rejected.
+	w = httptest.NewRecorder()
+	Set(w, testName, "a.b,c", "/")
+	r.Header = http.Header{"Cookie": w.Header()["Set-Cookie"]}
+	if _, err := GetSigned(r, testName, key); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("GetSigned of unsigned cookie: got error %v, want InvalidArgument", err)
+	}
+
+	if got, err := GetSigned(r, "other", key); err != nil || got != "" {
+		t.Errorf("GetSigned of missing cookie = %q, %v; want empty string, nil", got, err)
+	}
+}
@@ -21,10 +21,56 @@ type DataSource interface {
 	GetUnitMeta(ctx context.Context, path, requestedModulePath, requestedVersion string) (_ *UnitMeta, err error)
 	// GetModuleReadme gets the readme for the module.
 	GetModuleReadme(ctx context.Context, modulePath, resolvedVersion string) (*Readme, error)
+	// GetImportedByCount returns the number of packages outside of modulePath
+	// that import pkgPath.
+	GetImportedByCount(ctx context.Context, pkgPath, modulePath string) (int, error)
+	// GetModuleBuildContexts returns the union of the build contexts of the
+	// packages in the module. Packages that build on all platforms
+	// contribute BuildContextAll.
+	GetModuleBuildContexts(ctx context.Context, modulePath, resolvedVersion string) ([]BuildContext, error)
+	// GetSymbols returns the exported symbols of the package at path in the
+	// given module version, keyed by build context. Symbols of types, such as
+	// methods, are the children of the type's symbol. The map is empty if no
+	// symbols are known for the version.
+	GetSymbols(ctx context.Context, path, modulePath, resolvedVersion string) (map[BuildContext][]*Symbol, error)
+	// GetUnitPaths returns the sorted paths of the packages in the given
+	// module version that can be imported from outside it. Main and
+	// internal packages are omitted.
+	GetUnitPaths(ctx context.Context, modulePath, resolvedVersion string) ([]string, error)
+	// GetSymbolUsers returns the sorted paths of at most limit packages, in
+	// any version, that refer to the exported symbol of the package pkgPath.
+	GetSymbolUsers(ctx context.Context, pkgPath, symbol string, limit int) ([]string, error)
+	// GetPathRedirect returns the path that fullPath is served under, if it
+	// is a vanity import path for a module served under a different path. It
+	// returns the empty string otherwise.
+	GetPathRedirect(ctx context.Context, fullPath string) (string, error)
+	// GetModFile returns the raw contents of the go.mod file of the given
+	// module version, or an error wrapping derrors.NotFound if there is none.
+	GetModFile(ctx context.Context, modulePath, resolvedVersion string) ([]byte, error)
+	// GetUnitFiles returns the sorted names, relative to the unit's
+	// directory, of the non-test .go files that make up the package at
+	// fullPath in the given module version.
+	GetUnitFiles(ctx context.Context, fullPath, modulePath, resolvedVersion string) ([]string, error)
+	// GetRequirements returns the direct requirements listed in the go.mod
+	// file of the given module version.
+	GetRequirements(ctx context.Context, modulePath, resolvedVersion string) ([]*Requirement, error)
+	// GetModulesByLicenseType returns at most limit modules that have a
+	// license of the given type, ordered by path and starting after cursor,
+	// along with the cursor for the next page, which is empty if there is
+	// none.
+	GetModulesByLicenseType(ctx context.Context, licenseType string, limit int, cursor string) (_ []*ModuleInfo, nextCursor string, err error)
 
 	// GetLatestInfo gets information about the latest versions of a unit and module.
 	// See LatestInfo for documentation.
 	GetLatestInfo(ctx context.Context, unitPath, modulePath string) (LatestInfo, error)
+	// GetLatestInfoBatch is like GetLatestInfo, but for many units at once.
+	// Units that cannot be found are missing from the returned map.
+	GetLatestInfoBatch(ctx context.Context, paths []UnitModulePath) (map[UnitModulePath]LatestInfo, error)
+}
+
+// UnitModulePath identifies a unit by its path and the path of its module.
+type UnitModulePath struct {
+	Path, ModulePath string
 }
 
 // LatestInfo holds information about the latest versions and paths.
@@ -59,13 +59,71 @@ func NewRouter(tagger RouteTagger) *Router {
 
 // Handle registers handler with the given route. It has the same routing
 // semantics as http.ServeMux.
+//
+// If the trace of a request was not sampled and the response is a server
+// error, a span describing the error is recorded anyway, so that errors can
+// be investigated whatever the sampling rate.
 func (r *Router) Handle(route string, handler http.Handler) {
 	r.mux.HandleFunc(route, func(w http.ResponseWriter, req *http.Request) {
 		tag := r.tagger(route, req)
-		ochttp.WithRouteTag(handler, tag).ServeHTTP(w, req)
+		if p, ok := req.Context().Value(routeRecorderKey{}).(*string); ok {
+			*p = tag
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		ochttp.WithRouteTag(handler, tag).ServeHTTP(sw, req)
+		if sw.status >= 500 {
+			traceError(req, tag, sw.status)
+		}
 	})
 }
 
+// routeRecorderKey is the context key for the *string in which a Router
+// records the route tag of a request.
+type routeRecorderKey struct{}
+
+// WithRouteRecorder returns a context derived from ctx in which a Router
+// records the route tag of the request it serves, along with a function that
+// returns the tag once the request has been served. The tag is empty if the
+// request was not served by a Router.
+func WithRouteRecorder(ctx context.Context) (context.Context, func() string) {
+	var route string
+	return context.WithValue(ctx, routeRecorderKey{}, &route), func() string { return route }
+}
+
+// ForceSample is a trace.StartOption that samples the span regardless of the
+// sampling rate and of whether its parent was sampled.
+func ForceSample() trace.StartOption {
+	return trace.WithSampler(trace.AlwaysSample())
+}
+
+// traceError records a forced span for a request that resulted in a server
+// error, unless the request's trace was already sampled.
+func traceError(r *http.Request, route string, status int) {
+	if span := trace.FromContext(r.Context()); span != nil && span.SpanContext().IsSampled() {
+		return
+	}
+	_, span := trace.StartSpan(r.Context(), "server error", ForceSample())
+	span.AddAttributes(
+		trace.StringAttribute("http.method", r.Method),
+		trace.StringAttribute("http.path", r.URL.Path),
+		trace.StringAttribute("http.route", route),
+		trace.Int64Attribute("http.status_code", int64(status)))
+	span.SetStatus(ochttp.TraceStatus(status, http.StatusText(status)))
+	span.End()
+}
+
+// statusWriter is an http.ResponseWriter that records the status of the
+// response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
 // HandleFunc is a wrapper around Handle for http.HandlerFuncs.
 func (r *Router) HandleFunc(route string, handler http.HandlerFunc) {
 	r.Handle(route, handler)
@@ -81,9 +139,9 @@ const debugPage = `
 // running on GCP, Init also configures exporting to StackDriver.
 func Init(cfg *config.Config, views ...*view.View) error {
 	// The default trace sampler samples with probability 1e-4. That's too
-	// infrequent for our traffic levels. In the future we may want to decrease
-	// this sampling rate.
-	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(0.01)})
+	// infrequent for our traffic levels, so sample at the configured rate.
+	// Server errors are traced regardless; see Router.Handle.
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(cfg.TraceSampleRate)})
 	if err := view.Register(views...); err != nil {
 		return fmt.Errorf("dcensus.Init(views): view.Register: %v", err)
 	}
@@ -13,6 +13,9 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
 )
 
 func TestRouter(t *testing.T) {
@@ -63,3 +66,47 @@ func TestRouter(t *testing.T) {
 		t.Errorf("unexpected route tag counts (-want +got):\n%s", diff)
 	}
 }
+
+func TestTraceSampleRate(t *testing.T) {
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+	if err := Init(&config.Config{TraceSampleRate: 0}); err != nil {
+		t.Fatal(err)
+	}
+	rec := &testhelper.SpanRecorder{}
+	trace.RegisterExporter(rec)
+	defer trace.UnregisterExporter(rec)
+
+	router := NewRouter(nil)
+	router.HandleFunc("/ok/", func(w http.ResponseWriter, r *http.Request) {
+		_, span := trace.StartSpan(r.Context(), "child")
+		span.End()
+	})
+	router.HandleFunc("/forced/", func(w http.ResponseWriter, r *http.Request) {
+		_, span := trace.StartSpan(r.Context(), "forced", ForceSample())
+		span.End()
+	})
+	router.HandleFunc("/error/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	})
+
+	for _, test := range []struct {
+		path      string
+		wantSpans []string
+	}{
+		{"/ok/", nil},
+		{"/forced/", []string{"forced"}},
+		{"/error/", []string{"server error"}},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			rec.Reset()
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, test.path, nil))
+			var got []string
+			for _, s := range rec.Spans() {
+				got = append(got, s.Name)
+			}
+			if !cmp.Equal(got, test.wantSpans) {
+				t.Errorf("got spans %q, want %q", got, test.wantSpans)
+			}
+		})
+	}
+}
@@ -80,6 +80,10 @@ var (
 	// example, if the .go files fail to parse or declare different package
 	// names.
 	PackageInvalidContents = errors.New("package invalid contents")
+	// PackageInternalError indicates that processing a package failed
+	// because of a bug in pkgsite, such as a panic, rather than because of
+	// the package's contents.
+	PackageInternalError = errors.New("package internal error")
 
 	// DBModuleInsertInvalid represents a module that was successfully
 	// fetched but could not be inserted due to invalid arguments to
@@ -139,6 +143,7 @@ var codes = []struct {
 	{PackageDocumentationHTMLTooLarge, 603},
 	{PackageInvalidContents, 604},
 	{PackageBadImportPath, 605},
+	{PackageInternalError, 606},
 }
 
 // FromStatus generates an error according for the given status code. It uses
@@ -34,11 +34,21 @@ const (
 )
 
 // DefaultBranches are default branches that are supported by pkgsite.
+// More can be added with AddDefaultBranches.
 var DefaultBranches = map[string]bool{
 	MainVersion:   true,
 	MasterVersion: true,
 }
 
+// AddDefaultBranches adds branches, like "develop" or "trunk", to
+// DefaultBranches. It is not safe to call concurrently with uses of
+// DefaultBranches, so it should be called during program initialization.
+func AddDefaultBranches(branches ...string) {
+	for _, b := range branches {
+		DefaultBranches[b] = true
+	}
+}
+
 // ModuleInfo holds metadata associated with a module.
 type ModuleInfo struct {
 	ModulePath        string
@@ -48,6 +58,13 @@ type ModuleInfo struct {
 	// HasGoMod describes whether the module zip has a go.mod file.
 	HasGoMod   bool
 	SourceInfo *source.Info
+	// CommitHash is the hash of the commit the version refers to, if the
+	// proxy reported it.
+	CommitHash string
+	// Origin describes the repository the version comes from. It is the
+	// origin reported by the proxy if there is one, and otherwise the origin
+	// inferred from SourceInfo.
+	Origin *source.Origin
 
 	// Deprecated describes whether the module is deprecated.
 	Deprecated bool
@@ -118,6 +135,52 @@ type Module struct {
 	// that may be contained in nested subdirectories.
 	Licenses []*licenses.License
 	Units    []*Unit
+
+	// GoVersion is the version from the go directive of the module's go.mod
+	// file, if any.
+	GoVersion string
+	// Requirements are the direct requirements listed in the module's go.mod
+	// file.
+	Requirements []*Requirement
+	// Maintainers summarizes the file that lists the module's authors,
+	// maintainers or owners, if there is one.
+	Maintainers *Maintainers
+	// GoModFile holds the raw contents of the module's go.mod file, as
+	// served by the proxy. It is nil for the standard library.
+	GoModFile []byte
+}
+
+// Maintainers is a summary of a file that lists the people responsible for a
+// module, like AUTHORS, MAINTAINERS, OWNERS or CODEOWNERS.
+type Maintainers struct {
+	// Filepath is the path of the file, relative to the module root.
+	Filepath string
+	// Names holds the names and handles listed in the file, in order and
+	// without duplicates. Email addresses are omitted.
+	Names []string
+}
+
+// A Requirement is a module version required by another module's go.mod
+// file.
+type Requirement struct {
+	ModulePath string
+	Version    string
+}
+
+// ImpliedGoVersion describes the minimum Go version that is implied by a
+// module's go directive and the go directives of its direct dependencies.
+type ImpliedGoVersion struct {
+	// GoVersion is the version from the module's own go directive.
+	GoVersion string
+	// MinGoVersion is the highest Go version among the module's go directive
+	// and the go directives of its indexed direct dependencies.
+	MinGoVersion string
+	// RequiredBy is the path of the dependency whose go directive determines
+	// MinGoVersion. It is empty if MinGoVersion comes from the module itself.
+	RequiredBy string
+	// Unindexed holds the direct dependencies that have not been processed
+	// by pkgsite. If it is non-empty, MinGoVersion may be too low.
+	Unindexed []*Requirement
 }
 
 // Packages returns all of the units for a module that are packages.
@@ -218,4 +281,15 @@ type SearchResult struct {
 	// can be approximate if search scanned only a subset of documents, and
 	// result count is estimated using the hyperloglog algorithm.
 	Approximate bool
+
+	// Snippet is an excerpt of the synopsis or README that matched the
+	// query. It is only set by documentation search.
+	Snippet []*SnippetSegment
+}
+
+// A SnippetSegment is a piece of the text of a search result snippet.
+type SnippetSegment struct {
+	Text string
+	// Match reports whether Text matched a term of the search query.
+	Match bool
 }
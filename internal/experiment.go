@@ -34,6 +34,12 @@ type Experiment struct {
 	// Rollout is the percentage of requests enrolled in the experiment.
 	Rollout uint
 
+	// PathPrefixes are path prefixes, like "github.com/a/b", for which the
+	// experiment is always active, regardless of Rollout. A prefix matches
+	// the path of a request for it or anything beneath it, with or without a
+	// version.
+	PathPrefixes []string
+
 	// Description provides a description of the experiment.
 	Description string
 }
@@ -16,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opencensus.io/plugin/ochttp"
@@ -24,6 +25,7 @@ import (
 	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/dcensus"
 	"golang.org/x/pkgsite/internal/derrors"
@@ -32,6 +34,7 @@ import (
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/pkgsite/internal/version"
 )
 
 var (
@@ -55,6 +58,15 @@ var (
 		"Count of successfully fetched packages.",
 		stats.UnitDimensionless,
 	)
+	fetchZipSize = stats.Int64(
+		"go-discovery/worker/fetch-zip-size",
+		"Size of the zip of a fetched module.",
+		stats.UnitBytes,
+	)
+
+	// keyModuleGroup is a coarse grouping of module paths, computed by
+	// moduleGroup.
+	keyModuleGroup = tag.MustNewKey("fetch.module_group")
 
 	// FetchLatencyDistribution aggregates frontend fetch request
 	// latency by status code. It does not count shedded requests.
@@ -80,6 +92,23 @@ var (
 		Aggregation: view.Count(),
 		Description: "Count of packages successfully fetched",
 	}
+	// FetchLatencyByModuleGroup aggregates fetch latency by module group.
+	FetchLatencyByModuleGroup = &view.View{
+		Name:        "go-discovery/worker/fetch-latency-by-module-group",
+		Measure:     fetchLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+		Description: "Fetch latency by module group.",
+		TagKeys:     []tag.Key{keyModuleGroup},
+	}
+	// FetchZipSizeByModuleGroup aggregates the zip sizes of fetched modules
+	// by module group. Zip sizes are only known when load shedding is enabled.
+	FetchZipSizeByModuleGroup = &view.View{
+		Name:        "go-discovery/worker/fetch-zip-size-by-module-group",
+		Measure:     fetchZipSize,
+		Aggregation: view.Distribution(0, 1*mib, 5*mib, 10*mib, 50*mib, 100*mib, 250*mib, 500*mib, 1000*mib),
+		Description: "Fetched zip size by module group.",
+		TagKeys:     []tag.Key{keyModuleGroup},
+	}
 	// SheddedFetchCount counts the number of fetches that were shedded.
 	SheddedFetchCount = &view.View{
 		Name:        "go-discovery/worker/fetch-shedded",
@@ -105,6 +134,10 @@ type FetchResult struct {
 	Defer                func() // caller must defer this on all code paths
 	Module               *internal.Module
 	PackageVersionStates []*internal.PackageVersionState
+	// CommitHash is the hash of the commit that ResolvedVersion refers to,
+	// if the proxy reported it. Like HasGoMod, it is populated even if
+	// Module is nil.
+	CommitHash string
 }
 
 // FetchModule queries the proxy or the Go repo for the requested module
@@ -117,7 +150,17 @@ type FetchResult struct {
 //   defer fr.Defer()
 // immediately after the call.
 func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client) (fr *FetchResult) {
+	return FetchModuleWithOptions(ctx, modulePath, requestedVersion, proxyClient, sourceClient, FetchOptions{})
+}
+
+// FetchModuleWithOptions is like FetchModule, but uses the limits in opts
+// instead of the defaults.
+func FetchModuleWithOptions(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, opts FetchOptions) (fr *FetchResult) {
 	start := time.Now()
+	ctx, err := tag.New(ctx, tag.Upsert(keyModuleGroup, moduleGroup(modulePath)))
+	if err != nil {
+		log.Errorf(ctx, "tag.New: %v", err)
+	}
 	defer func() {
 		latency := float64(time.Since(start).Seconds())
 		dcensus.RecordWithTag(ctx, dcensus.KeyStatus, strconv.Itoa(fr.Status), fetchLatency.M(latency))
@@ -133,7 +176,7 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 	}
 	defer derrors.Wrap(&fr.Error, "FetchModule(%q, %q)", modulePath, requestedVersion)
 
-	fi, err := fetchModule(ctx, fr, proxyClient, sourceClient)
+	fi, err := fetchModule(ctx, fr, proxyClient, sourceClient, opts)
 	fr.Error = err
 	if err != nil {
 		fr.Status = derrors.ToStatus(fr.Error)
@@ -147,21 +190,25 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 	return fr
 }
 
-func fetchModule(ctx context.Context, fr *FetchResult, proxyClient *proxy.Client, sourceClient *source.Client) (*FetchInfo, error) {
+func fetchModule(ctx context.Context, fr *FetchResult, proxyClient *proxy.Client, sourceClient *source.Client, opts FetchOptions) (*FetchInfo, error) {
 	info, err := GetInfo(ctx, fr.ModulePath, fr.RequestedVersion, proxyClient)
 	if err != nil {
 		return nil, err
 	}
 	fr.ResolvedVersion = info.Version
+	if info.Origin != nil {
+		fr.CommitHash = info.Origin.Hash
+	}
 	commitTime := info.Time
 
 	var zipSize int64
-	if zipLoadShedder != nil {
+	if zipLimitsSet() {
 		var err error
 		zipSize, err = getZipSize(ctx, fr.ModulePath, fr.ResolvedVersion, proxyClient)
 		if err != nil {
 			return nil, err
 		}
+		stats.Record(ctx, fetchZipSize.M(zipSize))
 		// Load shed or mark module as too large.
 		// We treat zip size as a proxy for the total memory consumed by
 		// processing a module, and use it to decide whether we can currently
@@ -172,9 +219,9 @@ func fetchModule(ctx context.Context, fr *FetchResult, proxyClient *proxy.Client
 			stats.Record(ctx, fetchesShedded.M(1))
 			return nil, fmt.Errorf("%w: size=%dMi", derrors.SheddingLoad, zipSize/mib)
 		}
-		if zipSize > maxModuleZipSize {
+		if max := atomic.LoadInt64(&maxModuleZipSize); zipSize > max {
 			log.Warningf(ctx, "FetchModule: %s@%s zip size %dMi exceeds max %dMi",
-				fr.ModulePath, fr.ResolvedVersion, zipSize/mib, maxModuleZipSize/mib)
+				fr.ModulePath, fr.ResolvedVersion, zipSize/mib, max/mib)
 			return nil, derrors.ModuleTooLarge
 		}
 	}
@@ -200,10 +247,12 @@ func fetchModule(ctx context.Context, fr *FetchResult, proxyClient *proxy.Client
 		fr.ResolvedVersion = resolvedVersion
 		fi.Version = resolvedVersion
 	} else {
-		zipReader, err = proxyClient.Zip(ctx, fr.ModulePath, fr.ResolvedVersion)
+		var proxyURL string
+		zipReader, proxyURL, err = proxyClient.ZipWithProxyURL(ctx, fr.ModulePath, fr.ResolvedVersion)
 		if err != nil {
 			return fi, err
 		}
+		setFetchInfoProxyURL(fi, proxyURL)
 	}
 
 	// Set fr.HasGoMod as early as possible, because the go command uses it to
@@ -218,20 +267,23 @@ func fetchModule(ctx context.Context, fr *FetchResult, proxyClient *proxy.Client
 	// getGoModPath may return a non-empty goModPath even if the error is
 	// non-nil, if the module version is an alternative module.
 	var goModBytes []byte
-	fr.GoModPath, goModBytes, err = getGoModPath(ctx, fr.ModulePath, fr.ResolvedVersion, proxyClient)
+	fr.GoModPath, goModBytes, err = getGoModPath(ctx, fr.ModulePath, fr.ResolvedVersion, proxyClient, opts)
 	if err != nil {
 		return fi, err
 	}
 
-	mod, pvs, err := processZipFile(ctx, fr.ModulePath, fr.ResolvedVersion, commitTime, zipReader, sourceClient)
+	mod, pvs, err := processZipFile(ctx, fr.ModulePath, fr.ResolvedVersion, commitTime, zipReader, sourceClient, opts)
 	if err != nil {
 		return fi, err
 	}
 	mod.HasGoMod = fr.HasGoMod
+	mod.CommitHash = fr.CommitHash
+	mod.Origin = moduleOrigin(info.Origin, mod.SourceInfo, fr.ResolvedVersion)
 	if goModBytes != nil {
 		if err := processGoModFile(goModBytes, mod); err != nil {
 			return fi, fmt.Errorf("%v: %w", err.Error(), derrors.BadModule)
 		}
+		mod.GoModFile = goModBytes
 	}
 	fr.Module = mod
 	fr.PackageVersionStates = pvs
@@ -243,6 +295,50 @@ func fetchModule(ctx context.Context, fr *FetchResult, proxyClient *proxy.Client
 	return fi, nil
 }
 
+// moduleGroupHosts are the code hosts whose names appear in module groups.
+// The modules of all other hosts share a group.
+var moduleGroupHosts = map[string]bool{
+	"bitbucket.org":       true,
+	"github.com":          true,
+	"gitlab.com":          true,
+	"go.googlesource.com": true,
+	"golang.org":          true,
+	"gopkg.in":            true,
+	"k8s.io":              true,
+}
+
+// moduleGroupPrefixes are the first two elements of the module paths of
+// owners whose modules get a group of their own. The modules of other owners
+// on the same host share the host's group.
+var moduleGroupPrefixes = map[string]bool{
+	"github.com/Azure":      true,
+	"github.com/aws":        true,
+	"github.com/docker":     true,
+	"github.com/google":     true,
+	"github.com/hashicorp":  true,
+	"github.com/kubernetes": true,
+	"github.com/prometheus": true,
+	"golang.org/x":          true,
+}
+
+// moduleGroup returns a coarse grouping of modulePath for metrics. It is the
+// first two elements of the path if they are one of moduleGroupPrefixes, the
+// first element if it is one of moduleGroupHosts, and "other" otherwise. The
+// number of groups is bounded, so that it can be used as a tag.
+func moduleGroup(modulePath string) string {
+	if modulePath == stdlib.ModulePath {
+		return stdlib.ModulePath
+	}
+	parts := strings.SplitN(modulePath, "/", 3)
+	if len(parts) >= 2 && moduleGroupPrefixes[parts[0]+"/"+parts[1]] {
+		return parts[0] + "/" + parts[1]
+	}
+	if moduleGroupHosts[parts[0]] {
+		return parts[0]
+	}
+	return "other"
+}
+
 // GetInfo returns the result of a request to the proxy .info endpoint. If
 // the modulePath is "std", a request to @master will return an empty
 // commit time.
@@ -258,6 +354,16 @@ func GetInfo(ctx context.Context, modulePath, requestedVersion string, proxyClie
 	return proxyClient.Info(ctx, modulePath, requestedVersion)
 }
 
+// moduleOrigin returns the origin of a module version that the proxy reported,
+// or if it didn't report where the version is, the origin inferred from the
+// module's source info.
+func moduleOrigin(o *proxy.Origin, info *source.Info, resolvedVersion string) *source.Origin {
+	if o == nil || o.VCS == "" || o.URL == "" {
+		return info.InferOrigin(resolvedVersion)
+	}
+	return &source.Origin{VCS: o.VCS, URL: o.URL, Ref: o.Ref, Subdir: o.Subdir}
+}
+
 func getZipSize(ctx context.Context, modulePath, resolvedVersion string, proxyClient *proxy.Client) (_ int64, err error) {
 	if modulePath == stdlib.ModulePath {
 		return stdlib.EstimatedZipSize, nil
@@ -267,7 +373,7 @@ func getZipSize(ctx context.Context, modulePath, resolvedVersion string, proxyCl
 
 // getGoModPath returns the module path from the go.mod file, as well as the contents of the file obtained from the proxy.
 // If modulePath is the standardl library, then the contents will be nil.
-func getGoModPath(ctx context.Context, modulePath, resolvedVersion string, proxyClient *proxy.Client) (string, []byte, error) {
+func getGoModPath(ctx context.Context, modulePath, resolvedVersion string, proxyClient *proxy.Client, opts FetchOptions) (string, []byte, error) {
 	if modulePath == stdlib.ModulePath {
 		return stdlib.ModulePath, nil, nil
 	}
@@ -279,36 +385,70 @@ func getGoModPath(ctx context.Context, modulePath, resolvedVersion string, proxy
 	if goModPath == "" {
 		return "", nil, fmt.Errorf("go.mod has no module path: %w", derrors.BadModule)
 	}
-	if goModPath != modulePath {
+	if err := checkGoModPath(modulePath, goModPath, resolvedVersion, opts); err != nil {
 		// The module path in the go.mod file doesn't match the path of the
 		// zip file. Don't insert the module. Store an AlternativeModule
 		// status in module_version_states.
-		return goModPath, goModBytes, fmt.Errorf("module path=%s, go.mod path=%s: %w", modulePath, goModPath, derrors.AlternativeModule)
+		return goModPath, goModBytes, err
 	}
 	return goModPath, goModBytes, nil
 }
 
+// checkGoModPath returns an error wrapping derrors.AlternativeModule if
+// goModPath, the path in the go.mod file of modulePath@resolvedVersion, is
+// not modulePath.
+//
+// Paths that differ only in their major version suffix, like example.com/m
+// and example.com/m/v2, are also mismatches, as they are for the go command,
+// unless opts.AllowMajorVersionMismatch is set and the suffix of goModPath
+// agrees with the major version of resolvedVersion.
+func checkGoModPath(modulePath, goModPath, resolvedVersion string, opts FetchOptions) error {
+	if goModPath == modulePath {
+		return nil
+	}
+	prefix, pathMajor, ok := module.SplitPathVersion(modulePath)
+	goModPrefix, goModPathMajor, goModOK := module.SplitPathVersion(goModPath)
+	if !ok || !goModOK || prefix != goModPrefix {
+		return fmt.Errorf("module path=%s, go.mod path=%s: %w", modulePath, goModPath, derrors.AlternativeModule)
+	}
+	// An +incompatible version cannot have a go.mod file with a major version
+	// suffix.
+	if opts.AllowMajorVersionMismatch && !version.IsIncompatible(resolvedVersion) &&
+		module.CheckPathMajor(resolvedVersion, goModPathMajor) == nil {
+		return nil
+	}
+	return fmt.Errorf("module path=%s, go.mod path=%s: major version suffix %q does not match %q: %w",
+		modulePath, goModPath, goModPathMajor, pathMajor, derrors.AlternativeModule)
+}
+
 // processZipFile extracts information from the module version zip.
-func processZipFile(ctx context.Context, modulePath string, resolvedVersion string, commitTime time.Time, zipReader *zip.Reader, sourceClient *source.Client) (_ *internal.Module, _ []*internal.PackageVersionState, err error) {
+func processZipFile(ctx context.Context, modulePath string, resolvedVersion string, commitTime time.Time, zipReader *zip.Reader, sourceClient *source.Client, opts FetchOptions) (_ *internal.Module, _ []*internal.PackageVersionState, err error) {
 	defer derrors.Wrap(&err, "processZipFile(%q, %q)", modulePath, resolvedVersion)
 
 	ctx, span := trace.StartSpan(ctx, "fetch.processZipFile")
 	defer span.End()
 
+	if err := checkZipPaths(zipReader, modulePath, resolvedVersion); err != nil {
+		return nil, nil, err
+	}
 	sourceInfo, err := source.ModuleInfo(ctx, sourceClient, modulePath, resolvedVersion)
 	if err != nil {
 		log.Infof(ctx, "error getting source info: %v", err)
 	}
-	readmes, err := extractReadmesFromZip(modulePath, resolvedVersion, zipReader)
+	readmes, err := extractReadmesFromZip(modulePath, resolvedVersion, zipReader, opts.maxFileSize())
 	if err != nil {
 		return nil, nil, fmt.Errorf("extractReadmesFromZip(%q, %q, zipReader): %v", modulePath, resolvedVersion, err)
 	}
+	maintainers, err := extractMaintainersFromZip(modulePath, resolvedVersion, zipReader, opts.maxFileSize())
+	if err != nil {
+		log.Warningf(ctx, "error getting maintainers: %v", err)
+	}
 	logf := func(format string, args ...interface{}) {
 		log.Infof(ctx, format, args...)
 	}
-	d := licenses.NewDetector(modulePath, resolvedVersion, zipReader, logf)
+	d := licenses.NewDetector(modulePath, resolvedVersion, zipReader, opts.LicenseCoverageThreshold, logf)
 	allLicenses := d.AllLicenses()
-	packages, packageVersionStates, err := extractPackagesFromZip(ctx, modulePath, resolvedVersion, zipReader, d, sourceInfo)
+	packages, packageVersionStates, err := extractPackagesFromZip(ctx, modulePath, resolvedVersion, zipReader, d, sourceInfo, opts)
 	if errors.Is(err, ErrModuleContainsNoPackages) || errors.Is(err, errMalformedZip) {
 		return nil, nil, fmt.Errorf("%v: %w", err.Error(), derrors.BadModule)
 	}
@@ -324,8 +464,9 @@ func processZipFile(ctx context.Context, modulePath string, resolvedVersion stri
 			SourceInfo:        sourceInfo,
 			// HasGoMod is populated by the caller.
 		},
-		Licenses: allLicenses,
-		Units:    moduleUnits(modulePath, resolvedVersion, packages, readmes, d),
+		Licenses:    allLicenses,
+		Units:       moduleUnits(modulePath, resolvedVersion, packages, readmes, d, opts),
+		Maintainers: maintainers,
 	}, packageVersionStates, nil
 }
 
@@ -342,6 +483,18 @@ func processGoModFile(goModBytes []byte, mod *internal.Module) (err error) {
 		return err
 	}
 	mod.Deprecated, mod.DeprecationComment = extractDeprecatedComment(mf)
+	if mf.Go != nil {
+		mod.GoVersion = mf.Go.Version
+	}
+	for _, r := range mf.Require {
+		if r.Indirect {
+			continue
+		}
+		mod.Requirements = append(mod.Requirements, &internal.Requirement{
+			ModulePath: r.Mod.Path,
+			Version:    r.Mod.Version,
+		})
+	}
 	return nil
 }
 
@@ -369,6 +522,25 @@ func moduleVersionDir(modulePath, version string) string {
 	return fmt.Sprintf("%s@%s", modulePath, version)
 }
 
+// checkZipPaths returns an error wrapping derrors.BadModule if the name of a
+// file in r is not under the "<module>@<version>/" directory required by the
+// module zip format, or if it has a ".." element. Names like that could refer
+// to files outside the module.
+func checkZipPaths(r *zip.Reader, modulePath, version string) error {
+	prefix := moduleVersionDir(modulePath, version) + "/"
+	for _, f := range r.File {
+		if !strings.HasPrefix(f.Name, prefix) {
+			return fmt.Errorf("zip file %q does not have prefix %q: %w", f.Name, prefix, derrors.BadModule)
+		}
+		for _, elem := range strings.Split(f.Name[len(prefix):], "/") {
+			if elem == ".." {
+				return fmt.Errorf("zip file %q has a %q element: %w", f.Name, elem, derrors.BadModule)
+			}
+		}
+	}
+	return nil
+}
+
 // zipFile returns the file in r whose name matches the given name, or nil
 // if there isn't one.
 func zipFile(r *zip.Reader, name string) *zip.File {
@@ -384,10 +556,12 @@ type FetchInfo struct {
 	ModulePath string
 	Version    string
 	ZipSize    uint64
-	Start      time.Time
-	Finish     time.Time
-	Status     int
-	Error      error
+	// ProxyURL is the URL of the proxy that served the module zip.
+	ProxyURL string
+	Start    time.Time
+	Finish   time.Time
+	Status   int
+	Error    error
 }
 
 var (
@@ -418,6 +592,12 @@ func startFetchInfo(fi *FetchInfo) {
 	fetchInfoMap[fi] = struct{}{}
 }
 
+func setFetchInfoProxyURL(fi *FetchInfo, proxyURL string) {
+	fetchInfoMu.Lock()
+	defer fetchInfoMu.Unlock()
+	fi.ProxyURL = proxyURL
+}
+
 func finishFetchInfo(fi *FetchInfo, status int, err error) {
 	fetchInfoMu.Lock()
 	defer fetchInfoMu.Unlock()
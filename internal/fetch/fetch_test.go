@@ -5,6 +5,8 @@
 package fetch
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -19,6 +21,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/safehtml/template"
+	"go.opencensus.io/stats/view"
 	"golang.org/x/mod/modfile"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
@@ -29,6 +32,7 @@ import (
 	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/testing/sample"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
 )
 
 var testTimeout = 30 * time.Second
@@ -70,6 +74,7 @@ func TestFetchModule(t *testing.T) {
 	}{
 		{name: "single", mod: moduleOnePackage},
 		{name: "wasm", mod: moduleWasm},
+		{name: "cgo", mod: moduleCgo},
 		{name: "no go.mod file", mod: moduleNoGoMod},
 		{name: "multi", mod: moduleMultiPackage},
 		{name: "bad packages", mod: moduleBadPackages},
@@ -129,6 +134,18 @@ func TestFetchModule(t *testing.T) {
 						cmpopts.IgnoreFields(internal.Documentation{}, "Source"),
 						cmpopts.IgnoreFields(internal.PackageVersionState{}, "Error"),
 						cmpopts.IgnoreFields(FetchResult{}, "Defer"),
+						// The test proxy serves a go.mod file with a go
+						// directive even for modules that don't have one.
+						// See TestProcessGoModFile.
+						cmpopts.IgnoreFields(internal.Module{}, "GoVersion"),
+						// See TestFetchModuleGoModFile.
+						cmpopts.IgnoreFields(internal.Module{}, "GoModFile"),
+						// See TestFetchModuleImportedSymbols.
+						cmpopts.IgnoreFields(internal.Unit{}, "ImportedSymbols"),
+						// See TestFetchModuleFiles.
+						cmpopts.IgnoreFields(internal.Unit{}, "Files"),
+						// See TestFetchModuleOrigin.
+						cmpopts.IgnoreFields(internal.ModuleInfo{}, "Origin"),
 						cmp.AllowUnexported(source.Info{}),
 						cmpopts.EquateEmpty(),
 					}
@@ -233,6 +250,661 @@ func TestFetchModule_Errors(t *testing.T) {
 	}
 }
 
+func TestFetchModuleWithOptions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// Lower the global limit, to check that a per-call limit overrides it.
+	defer func(oldmax int) { godoc.MaxDocumentationHTML = oldmax }(godoc.MaxDocumentationHTML)
+	godoc.MaxDocumentationHTML = megabyte / 2
+
+	// The root package of the module has a single file of about 1.2MB, whose
+	// documentation exceeds the global limit. The small package keeps the
+	// module valid when the root package cannot be processed.
+	mod := moduleDocTooLarge.mod
+	files := map[string]string{"small/small.go": "package small"}
+	for name, contents := range mod.Files {
+		files[name] = contents
+	}
+	proxyClient, teardownProxy := proxy.SetupTestClient(t, []*proxy.Module{{
+		ModulePath: mod.ModulePath,
+		Version:    sample.VersionString,
+		Files:      files,
+	}})
+	defer teardownProxy()
+
+	for _, test := range []struct {
+		name          string
+		opts          FetchOptions
+		wantStatus    int
+		wantPkgStatus int
+		wantDoc       bool
+	}{
+		{
+			name:          "default limits",
+			wantStatus:    derrors.ToStatus(derrors.HasIncompletePackages),
+			wantPkgStatus: derrors.ToStatus(derrors.PackageDocumentationHTMLTooLarge),
+			wantDoc:       true,
+		},
+		{
+			name:          "tiny file size",
+			opts:          FetchOptions{MaxFileSize: 1000},
+			wantStatus:    derrors.ToStatus(derrors.HasIncompletePackages),
+			wantPkgStatus: derrors.ToStatus(derrors.PackageMaxFileSizeLimitExceeded),
+		},
+		{
+			name:          "tiny documentation",
+			opts:          FetchOptions{MaxDocumentationHTML: 1000},
+			wantStatus:    derrors.ToStatus(derrors.HasIncompletePackages),
+			wantPkgStatus: derrors.ToStatus(derrors.PackageDocumentationHTMLTooLarge),
+			wantDoc:       true,
+		},
+		{
+			// The documentation is not rendered, so its size is not checked.
+			name:          "skip documentation HTML",
+			opts:          FetchOptions{MaxDocumentationHTML: 1000, SkipDocumentationHTML: true},
+			wantStatus:    http.StatusOK,
+			wantPkgStatus: http.StatusOK,
+			wantDoc:       true,
+		},
+		{
+			name:          "huge limits",
+			opts:          FetchOptions{MaxFileSize: 100 * megabyte, MaxDocumentationHTML: 100 * megabyte},
+			wantStatus:    http.StatusOK,
+			wantPkgStatus: http.StatusOK,
+			wantDoc:       true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := FetchModuleWithOptions(ctx, mod.ModulePath, sample.VersionString, proxyClient, source.NewClientForTesting(), test.opts)
+			defer got.Defer()
+			if got.Status != test.wantStatus {
+				t.Fatalf("got status %d, want %d (error: %v)", got.Status, test.wantStatus, got.Error)
+			}
+			gotPkgStatus := -1
+			for _, pvs := range got.PackageVersionStates {
+				if pvs.PackagePath == mod.ModulePath {
+					gotPkgStatus = pvs.Status
+				}
+			}
+			if gotPkgStatus != test.wantPkgStatus {
+				t.Errorf("package status: got %d, want %d", gotPkgStatus, test.wantPkgStatus)
+			}
+			var docs []*internal.Documentation
+			for _, u := range got.Module.Units {
+				if u.Path == mod.ModulePath {
+					docs = u.Documentation
+				}
+			}
+			if gotDoc := len(docs) > 0; gotDoc != test.wantDoc {
+				t.Fatalf("got documentation: %t, want %t", gotDoc, test.wantDoc)
+			}
+			if test.wantDoc {
+				if g, w := docs[0].Synopsis, "This documentation is big."; g != w {
+					t.Errorf("got synopsis %q, want %q", g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchModuleMajorVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	module := func(modulePath, version, goModPath string) *proxy.Module {
+		return &proxy.Module{
+			ModulePath: modulePath,
+			Version:    version,
+			Files: map[string]string{
+				"go.mod": "module " + goModPath,
+				"p.go":   "// Package p is a package.\npackage p",
+			},
+		}
+	}
+	proxyClient, teardownProxy := proxy.SetupTestClient(t, []*proxy.Module{
+		module("example.com/good/v2", "v2.0.0", "example.com/good/v2"),
+		module("example.com/nov2/v2", "v2.0.0", "example.com/nov2"),
+		module("example.com/extrav2", "v2.0.0+incompatible", "example.com/extrav2/v2"),
+		module("example.com/quirk/v2", "v1.0.0", "example.com/quirk"),
+		module("example.com/other/v2", "v2.0.0", "example.com/different/v2"),
+	})
+	defer teardownProxy()
+
+	for _, test := range []struct {
+		modulePath, version string
+		allowMismatch       bool
+		wantErr             error
+	}{
+		{"example.com/good/v2", "v2.0.0", false, nil},
+		{"example.com/nov2/v2", "v2.0.0", false, derrors.AlternativeModule},
+		{"example.com/nov2/v2", "v2.0.0", true, derrors.AlternativeModule},
+		{"example.com/extrav2", "v2.0.0+incompatible", true, derrors.AlternativeModule},
+		{"example.com/quirk/v2", "v1.0.0", false, derrors.AlternativeModule},
+		{"example.com/quirk/v2", "v1.0.0", true, nil},
+		{"example.com/other/v2", "v2.0.0", true, derrors.AlternativeModule},
+	} {
+		t.Run(fmt.Sprintf("%s@%s,allow=%t", test.modulePath, test.version, test.allowMismatch), func(t *testing.T) {
+			opts := FetchOptions{AllowMajorVersionMismatch: test.allowMismatch}
+			got := FetchModuleWithOptions(ctx, test.modulePath, test.version, proxyClient, source.NewClientForTesting(), opts)
+			defer got.Defer()
+			if test.wantErr == nil {
+				if got.Error != nil {
+					t.Fatalf("got error %v, want nil", got.Error)
+				}
+			} else if !errors.Is(got.Error, test.wantErr) {
+				t.Fatalf("got error %v, want %v", got.Error, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchModuleImportable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	mod := &proxy.Module{
+		ModulePath: "importable.test",
+		Files: map[string]string{
+			"LICENSE":           testhelper.MITLicense,
+			"foo/foo.go":        "package foo",
+			"internal/bar.go":   "package internal",
+			"foo/internal/b.go": "package b",
+			"cmd/tool/main.go":  "package main",
+		},
+	}
+	got, _ := proxyFetcher(t, false, ctx, mod, "")
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	want := map[string]bool{
+		"importable.test/foo":          true,
+		"importable.test/internal":     false,
+		"importable.test/foo/internal": false,
+		"importable.test/cmd/tool":     false,
+	}
+	gotImportable := map[string]bool{}
+	for _, u := range got.Module.Units {
+		if u.IsPackage() {
+			gotImportable[u.Path] = u.IsImportable
+		}
+	}
+	if diff := cmp.Diff(want, gotImportable); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchModuleGoModFile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const goMod = "module gomodfile.test\n\ngo 1.16\n\nrequire golang.org/x/text v0.3.0\n"
+	mod := &proxy.Module{
+		ModulePath: "gomodfile.test",
+		Files: map[string]string{
+			"go.mod":     goMod,
+			"LICENSE":    testhelper.MITLicense,
+			"foo/foo.go": "package foo",
+		},
+	}
+	got, _ := proxyFetcher(t, false, ctx, mod, "")
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	if diff := cmp.Diff(goMod, string(got.Module.GoModFile)); diff != "" {
+		t.Errorf("GoModFile mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestModuleGroup(t *testing.T) {
+	for _, test := range []struct {
+		modulePath, want string
+	}{
+		{"std", "std"},
+		{"github.com/a/b", "github.com"},
+		{"github.com/google/go-cmp", "github.com/google"},
+		{"github.com/google/go-cmp/v2", "github.com/google"},
+		{"golang.org/x/tools", "golang.org/x"},
+		{"golang.org/y/tools", "golang.org"},
+		{"k8s.io/api", "k8s.io"},
+		{"example.com/a", "other"},
+		{"example.com", "other"},
+	} {
+		if got := moduleGroup(test.modulePath); got != test.want {
+			t.Errorf("moduleGroup(%q) = %q, want %q", test.modulePath, got, test.want)
+		}
+	}
+}
+
+func TestFetchModuleRecordsModuleGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := view.Register(FetchLatencyByModuleGroup); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(FetchLatencyByModuleGroup)
+
+	mod := &proxy.Module{
+		ModulePath: "github.com/group/module",
+		Files: map[string]string{
+			"LICENSE": testhelper.MITLicense,
+			"foo.go":  "package module",
+		},
+	}
+	got, _ := proxyFetcher(t, false, ctx, mod, "")
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	rows, err := view.RetrieveData(FetchLatencyByModuleGroup.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := moduleGroup(mod.ModulePath)
+	for _, row := range rows {
+		for _, tg := range row.Tags {
+			if tg.Key == keyModuleGroup && tg.Value == want {
+				return
+			}
+		}
+	}
+	t.Errorf("no fetch latency recorded for module group %q; got rows %v", want, rows)
+}
+
+func TestFetchModuleImportedSymbols(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	mod := &proxy.Module{
+		ModulePath: "example.com/uses",
+		Files: map[string]string{
+			"LICENSE": testhelper.MITLicense,
+			"uses.go": `package uses
+
+import (
+	"context"
+	str "strings"
+	"time"
+
+	"example.com/uses/internal/yaml.v2"
+)
+
+// Wait waits for d.
+func Wait(ctx context.Context, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	<-ctx.Done()
+	_ = yaml.Marshal
+	return ctx.Err()
+}
+
+// Upper is strings.ToUpper.
+func Upper(s string) string { return str.ToUpper(s) }
+`,
+			"uses_test.go": `package uses
+
+import "testing"
+
+func TestWait(t *testing.T) { testing.Short() }
+`,
+			"internal/yaml.v2/yaml.go": `package yaml
+
+func Marshal() {}
+`,
+		},
+	}
+	got, _ := proxyFetcher(t, false, ctx, mod, "")
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	var syms []string
+	for _, u := range got.Module.Units {
+		if u.Path == "example.com/uses" {
+			syms = u.ImportedSymbols
+		}
+	}
+	// Methods and fields, like ctx.Done, are not recorded, nor are the uses
+	// in test files.
+	want := []string{
+		"context.Context",
+		"context.WithTimeout",
+		"example.com/uses/internal/yaml.v2.Marshal",
+		"strings.ToUpper",
+		"time.Duration",
+	}
+	if diff := cmp.Diff(want, syms); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchModuleFiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	mod := &proxy.Module{
+		ModulePath: "example.com/files",
+		Files: map[string]string{
+			"LICENSE":           testhelper.MITLicense,
+			"files.go":          "// Package files has files.\npackage files\n\nconst A = 1",
+			"files_linux.go":    "package files\n\nconst L = 1",
+			"files_test.go":     "package files\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}",
+			"ignored.go":        "// +build ignore\n\npackage main",
+			"sub/sub.go":        "package sub",
+			"sub/sub_darwin.go": "package sub",
+		},
+	}
+	got, _ := proxyFetcher(t, false, ctx, mod, "")
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	want := map[string][]string{
+		"example.com/files":     {"files.go", "files_linux.go"},
+		"example.com/files/sub": {"sub.go", "sub_darwin.go"},
+	}
+	for _, u := range got.Module.Units {
+		if diff := cmp.Diff(want[u.Path], u.Files); diff != "" {
+			t.Errorf("%s: Files mismatch (-want +got):\n%s", u.Path, diff)
+		}
+	}
+}
+
+func TestFetchModuleCommitHash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const hash = "d50f0e9b25068a1e5b8c3f6f14e8a0c2b7a5e3f1"
+	for _, test := range []struct {
+		name   string
+		origin *proxy.Origin
+		want   string
+	}{
+		{"origin", &proxy.Origin{Hash: hash}, hash},
+		{"no origin", nil, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			mod := &proxy.Module{
+				ModulePath: "example.com/hash",
+				Version:    "v0.0.0-20181115181204-d50f0e9b2506",
+				Files: map[string]string{
+					"LICENSE": testhelper.MITLicense,
+					"hash.go": "// Package hash has a hash.\npackage hash",
+				},
+				Origin: test.origin,
+			}
+			got, _ := proxyFetcher(t, false, ctx, mod, "")
+			defer got.Defer()
+			if got.Error != nil {
+				t.Fatal(got.Error)
+			}
+			if got.CommitHash != test.want {
+				t.Errorf("FetchResult.CommitHash = %q, want %q", got.CommitHash, test.want)
+			}
+			if got.Module.CommitHash != test.want {
+				t.Errorf("Module.CommitHash = %q, want %q", got.Module.CommitHash, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchModuleOrigin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, test := range []struct {
+		name    string
+		version string
+		origin  *proxy.Origin
+		want    *source.Origin
+	}{
+		{
+			name:    "from proxy",
+			version: "v1.2.0",
+			origin: &proxy.Origin{
+				VCS:    "hg",
+				URL:    "https://hg.example.org/origin",
+				Subdir: "go",
+				Ref:    "refs/tags/go/v1.2.0",
+				Hash:   "d50f0e9b25068a1e5b8c3f6f14e8a0c2b7a5e3f1",
+			},
+			want: &source.Origin{
+				VCS:    "hg",
+				URL:    "https://hg.example.org/origin",
+				Subdir: "go",
+				Ref:    "refs/tags/go/v1.2.0",
+			},
+		},
+		{
+			name:    "inferred",
+			version: "v1.2.0",
+			want: &source.Origin{
+				VCS: "git",
+				URL: "https://example.com/origin",
+				Ref: "refs/tags/v1.2.0",
+			},
+		},
+		{
+			name:    "inferred pseudo-version",
+			version: "v0.0.0-20181115181204-d50f0e9b2506",
+			want: &source.Origin{
+				VCS: "git",
+				URL: "https://example.com/origin",
+			},
+		},
+		{
+			// An origin without a VCS or URL says nothing about where the
+			// version is.
+			name:    "proxy reports hash only",
+			version: "v1.2.0",
+			origin:  &proxy.Origin{Hash: "d50f0e9b25068a1e5b8c3f6f14e8a0c2b7a5e3f1"},
+			want: &source.Origin{
+				VCS: "git",
+				URL: "https://example.com/origin",
+				Ref: "refs/tags/v1.2.0",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			mod := &proxy.Module{
+				ModulePath: "example.com/origin",
+				Version:    test.version,
+				Files: map[string]string{
+					"LICENSE":   testhelper.MITLicense,
+					"origin.go": "// Package origin has an origin.\npackage origin",
+				},
+				Origin: test.origin,
+			}
+			got, _ := proxyFetcher(t, false, ctx, mod, "")
+			defer got.Defer()
+			if got.Error != nil {
+				t.Fatal(got.Error)
+			}
+			if diff := cmp.Diff(test.want, got.Module.Origin); diff != "" {
+				t.Errorf("Origin mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFetchModuleNoExportedAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const modulePath = "noapi.test"
+	proxyClient, teardownProxy := proxy.SetupTestClient(t, []*proxy.Module{{
+		ModulePath: modulePath,
+		Version:    sample.VersionString,
+		Files: map[string]string{
+			"LICENSE": testhelper.MITLicense,
+			// glue has a package comment but exports nothing.
+			"glue/glue.go": "// Package glue wires things together.\npackage glue\n\nfunc init() {}\n",
+			"api/api.go":   "// Package api has an API.\npackage api\n\nfunc F() {}\n",
+			// nodoc exports nothing, but has no package comment either.
+			"nodoc/nodoc.go": "package nodoc\n",
+		},
+	}})
+	defer teardownProxy()
+
+	type result struct {
+		Label             string
+		ExcludeFromSearch bool
+	}
+	for _, test := range []struct {
+		name string
+		opts FetchOptions
+		want result
+	}{
+		{
+			name: "default",
+			want: result{Label: DefaultNoExportedAPILabel},
+		},
+		{
+			name: "custom label and exclusion",
+			opts: FetchOptions{NoExportedAPILabel: "glue code", ExcludeNoExportedAPIFromSearch: true},
+			want: result{Label: "glue code", ExcludeFromSearch: true},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := FetchModuleWithOptions(ctx, modulePath, sample.VersionString, proxyClient, source.NewClientForTesting(), test.opts)
+			defer got.Defer()
+			if got.Error != nil {
+				t.Fatal(got.Error)
+			}
+			want := map[string]result{
+				modulePath + "/glue":  test.want,
+				modulePath + "/api":   {},
+				modulePath + "/nodoc": {},
+			}
+			gotResults := map[string]result{}
+			for _, u := range got.Module.Units {
+				if u.IsPackage() {
+					gotResults[u.Path] = result{u.Label, u.ExcludeFromSearch}
+				}
+			}
+			if diff := cmp.Diff(want, gotResults); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFetchModuleBuildContexts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	got, _ := proxyFetcher(t, false, ctx, moduleBuildConstraints.modfunc(), "")
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	var cpu *internal.Unit
+	for _, u := range got.Module.Units {
+		if u.Path == "example.com/build-constraints/cpu" {
+			cpu = u
+		}
+	}
+	if cpu == nil {
+		t.Fatal("no cpu package")
+	}
+	for _, test := range []struct {
+		goarch string
+		want   string
+	}{
+		{"arm", "const CacheLinePadSize = 1"},
+		{"arm64", "const CacheLinePadSize = 2"},
+		{"386", "const CacheLinePadSize = 3"},
+	} {
+		t.Run(test.goarch, func(t *testing.T) {
+			bc := internal.BuildContext{GOOS: "linux", GOARCH: test.goarch}
+			doc := internal.DocumentationForBuildContext(cpu.Documentation, bc)
+			if doc == nil || doc.BuildContext() != bc {
+				t.Fatalf("no documentation for %s", bc)
+			}
+			u := *cpu
+			u.Documentation = []*internal.Documentation{doc}
+			parts, err := godoc.RenderPartsFromUnit(ctx, &u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body := parts.Body.String(); !strings.Contains(body, test.want) {
+				t.Errorf("doc for %s: missing %q; got\n%q", bc, test.want, body)
+			}
+		})
+	}
+}
+
+func TestFetchModuleSupportedBuildContexts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// Keep only cpu_arm.go in the cpu package, so that it only builds for arm.
+	mod := moduleBuildConstraints.modfunc()
+	files := map[string]string{}
+	for name, contents := range mod.Files {
+		if !strings.HasPrefix(name, "cpu/") || name == "cpu/cpu_arm.go" {
+			files[name] = contents
+		}
+	}
+	mod = &proxy.Module{ModulePath: mod.ModulePath, Version: mod.Version, Files: files}
+
+	got, _ := proxyFetcher(t, false, ctx, mod, "")
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	for _, u := range got.Module.Units {
+		var want []internal.BuildContext
+		if u.Path == "example.com/build-constraints/cpu" {
+			want = []internal.BuildContext{{GOOS: "linux", GOARCH: "arm"}}
+		}
+		if diff := cmp.Diff(want, u.SupportedBuildContexts); diff != "" {
+			t.Errorf("%s: SupportedBuildContexts mismatch (-want +got):\n%s", u.Path, diff)
+		}
+	}
+}
+
+func TestFetchModuleLocalClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	dir := t.TempDir()
+	mod := moduleOnePackage.modfunc()
+	if err := proxy.WriteModuleCache(dir, []*proxy.Module{{
+		ModulePath: mod.ModulePath,
+		Version:    sample.VersionString,
+		Files:      mod.Files,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	proxyClient, err := proxy.NewLocalClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := FetchModule(ctx, mod.ModulePath, internal.LatestVersion, proxyClient, source.NewClientForTesting())
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	if got.ResolvedVersion != sample.VersionString {
+		t.Errorf("got resolved version %q, want %q", got.ResolvedVersion, sample.VersionString)
+	}
+	var gotPaths []string
+	for _, u := range got.Module.Units {
+		gotPaths = append(gotPaths, u.Path)
+	}
+	var wantPaths []string
+	for _, u := range moduleOnePackage.fr.Module.Units {
+		wantPaths = append(wantPaths, u.Path)
+	}
+	if diff := cmp.Diff(wantPaths, gotPaths, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("unit paths mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestExtractDeprecatedComment(t *testing.T) {
 	for _, test := range []struct {
 		name        string
@@ -283,3 +955,68 @@ func TestExtractDeprecatedComment(t *testing.T) {
 		}
 	}
 }
+
+func TestProcessGoModFile(t *testing.T) {
+	const goMod = `
+		module m
+
+		go 1.15
+
+		require (
+			example.com/a v1.2.3
+			example.com/b v0.1.0 // indirect
+		)
+
+		require example.com/c v2.0.0+incompatible
+	`
+	var mod internal.Module
+	if err := processGoModFile([]byte(goMod), &mod); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mod.GoVersion, "1.15"; got != want {
+		t.Errorf("GoVersion = %q, want %q", got, want)
+	}
+	wantReqs := []*internal.Requirement{
+		{ModulePath: "example.com/a", Version: "v1.2.3"},
+		{ModulePath: "example.com/c", Version: "v2.0.0+incompatible"},
+	}
+	if diff := cmp.Diff(wantReqs, mod.Requirements); diff != "" {
+		t.Errorf("Requirements mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestProcessZipFilePathTraversal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const (
+		modulePath = "example.com/evil"
+		version    = "v1.0.0"
+		prefix     = modulePath + "@" + version + "/"
+	)
+	for _, name := range []string{
+		prefix + "../../etc/passwd",
+		prefix + "p/../../other@v1.0.0/p.go",
+		"/etc/passwd",
+		"other.com/m@v1.0.0/p.go",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := testhelper.ZipContents(map[string]string{
+				prefix + "go.mod": "module " + modulePath,
+				prefix + "p.go":   "package evil",
+				name:              "package evil",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = processZipFile(ctx, modulePath, version, time.Time{}, r, source.NewClientForTesting(), FetchOptions{})
+			if !errors.Is(err, derrors.BadModule) {
+				t.Errorf("got error %v, want BadModule", err)
+			}
+		})
+	}
+}
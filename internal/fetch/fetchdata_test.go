@@ -352,6 +352,45 @@ var moduleBuildConstraints = &testModule{
 							GOARCH:   "wasm",
 							Synopsis: "Package cpu implements processor feature detection used by the Go standard library.",
 						},
+						{
+							GOOS:     "linux",
+							GOARCH:   "386",
+							Synopsis: "Package cpu implements processor feature detection used by the Go standard library.",
+							API: []*internal.Symbol{
+								{
+									Name:     "CacheLinePadSize",
+									Synopsis: "const CacheLinePadSize",
+									Section:  "Constants",
+									Kind:     "Constant",
+								},
+							},
+						},
+						{
+							GOOS:     "linux",
+							GOARCH:   "arm",
+							Synopsis: "Package cpu implements processor feature detection used by the Go standard library.",
+							API: []*internal.Symbol{
+								{
+									Name:     "CacheLinePadSize",
+									Synopsis: "const CacheLinePadSize",
+									Section:  "Constants",
+									Kind:     "Constant",
+								},
+							},
+						},
+						{
+							GOOS:     "linux",
+							GOARCH:   "arm64",
+							Synopsis: "Package cpu implements processor feature detection used by the Go standard library.",
+							API: []*internal.Symbol{
+								{
+									Name:     "CacheLinePadSize",
+									Synopsis: "const CacheLinePadSize",
+									Section:  "Constants",
+									Kind:     "Constant",
+								},
+							},
+						},
 					},
 				},
 			},
@@ -372,7 +411,7 @@ var moduleBuildConstraints = &testModule{
 		},
 	},
 	docStrings: map[string][]string{
-		"build.constraints/module/cpu": {"const CacheLinePadSize = 3"},
+		"example.com/build-constraints/cpu": {"const CacheLinePadSize = 3"},
 	},
 }
 
@@ -543,6 +582,7 @@ var moduleDocTest = &testModule{
 						Name: "permalink",
 						Path: "doc.test/permalink",
 					},
+					Label: DefaultNoExportedAPILabel,
 					Documentation: []*internal.Documentation{{
 						GOOS:     internal.All,
 						GOARCH:   internal.All,
@@ -586,6 +626,7 @@ var moduleDocTooLarge = &testModule{
 						Name: "bigdoc",
 						Path: "bigdoc.test",
 					},
+					Label: DefaultNoExportedAPILabel,
 					Documentation: []*internal.Documentation{{
 						GOOS:     internal.All,
 						GOARCH:   internal.All,
@@ -650,10 +691,77 @@ var moduleWasm = &testModule{
 						GOARCH:   "wasm",
 						API:      []*internal.Symbol{{Name: "Value", Synopsis: "type Value int", Section: "Types", Kind: "Type"}},
 					}},
+					SupportedBuildContexts: []internal.BuildContext{{GOOS: "js", GOARCH: "wasm"}},
+				},
+			},
+		},
+	},
+}
+
+var moduleCgo = &testModule{
+	mod: &proxy.Module{
+		ModulePath: "github.com/my/module/cgo",
+		Files: map[string]string{
+			"LICENSE": testhelper.BSD0License,
+			"sqlite/sqlite.go": `
+			// Package sqlite wraps a C library.
+			package sqlite
+
+			/*
+			#include <stdlib.h>
+			int add(int a, int b) { return a + b; }
+			*/
+			import "C"
+
+			import "unsafe"
+
+			// Add adds two numbers in C.
+			func Add(a, b int) int { return int(C.add(C.int(a), C.int(b))) }
+
+			// Free frees s.
+			func Free(s *C.char) { C.free(unsafe.Pointer(s)) }
+
+			// String copies s to C.
+			func String(s string) *C.char { return C.CString(s) }`,
+		},
+	},
+	fr: &FetchResult{
+		Module: &internal.Module{
+			ModuleInfo: internal.ModuleInfo{
+				ModulePath:        "github.com/my/module/cgo",
+				SourceInfo:        source.NewGitHubInfo("https://github.com/my/module", "cgo", "cgo/v1.0.0"),
+				IsRedistributable: true,
+			},
+			Units: []*internal.Unit{
+				{
+					UnitMeta: internal.UnitMeta{
+						Path: "github.com/my/module/cgo",
+					},
+				},
+				{
+					UnitMeta: internal.UnitMeta{
+						Name: "sqlite",
+						Path: "github.com/my/module/cgo/sqlite",
+					},
+					Documentation: []*internal.Documentation{{
+						GOOS:     internal.All,
+						GOARCH:   internal.All,
+						Synopsis: "Package sqlite wraps a C library.",
+						API: []*internal.Symbol{
+							{Name: "Add", Synopsis: "func Add(a, b int) int", Section: "Functions", Kind: "Function"},
+							{Name: "Free", Synopsis: "func Free(s *C.char)", Section: "Functions", Kind: "Function"},
+							{Name: "String", Synopsis: "func String(s string) *C.char", Section: "Functions", Kind: "Function"},
+						},
+					}},
+					Imports: []string{"unsafe"},
+					UsesCgo: true,
 				},
 			},
 		},
 	},
+	docStrings: map[string][]string{
+		"github.com/my/module/cgo/sqlite": {"Add adds two numbers in C.", "func Free(s *C.char)", "func String(s"},
+	},
 }
 
 var moduleAlternative = &testModule{
@@ -1067,7 +1175,8 @@ var moduleStd = &testModule{
 					},
 					// cmd/pprof has a file with a build constraint that does not include js/wasm.
 					// Since the set files isn't the same across all build contexts, we represent
-					// every build context.
+					// every build context, including a port for each of the other GOOS values
+					// that the constraint mentions.
 					Documentation: []*internal.Documentation{
 						{
 							GOOS:     "linux",
@@ -1089,6 +1198,36 @@ var moduleStd = &testModule{
 							GOARCH:   "wasm",
 							Synopsis: "Pprof interprets and displays profiles of Go programs.",
 						},
+						{
+							GOOS:     "android",
+							GOARCH:   "386",
+							Synopsis: "Pprof interprets and displays profiles of Go programs.",
+						},
+						{
+							GOOS:     "dragonfly",
+							GOARCH:   "amd64",
+							Synopsis: "Pprof interprets and displays profiles of Go programs.",
+						},
+						{
+							GOOS:     "freebsd",
+							GOARCH:   "386",
+							Synopsis: "Pprof interprets and displays profiles of Go programs.",
+						},
+						{
+							GOOS:     "netbsd",
+							GOARCH:   "386",
+							Synopsis: "Pprof interprets and displays profiles of Go programs.",
+						},
+						{
+							GOOS:     "openbsd",
+							GOARCH:   "386",
+							Synopsis: "Pprof interprets and displays profiles of Go programs.",
+						},
+						{
+							GOOS:     "solaris",
+							GOARCH:   "amd64",
+							Synopsis: "Pprof interprets and displays profiles of Go programs.",
+						},
 					},
 					Imports: []string{
 						"cmd/internal/objfile",
@@ -2388,6 +2527,10 @@ var moduleLatest = &testModule{
 // of substrings that should appear in the generated documentation.
 // The substrings are separated by a '~' character.
 func moduleWithExamples(path string, api []*internal.Symbol, source, test string, docSubstrings ...string) *testModule {
+	var label string
+	if len(api) == 0 {
+		label = DefaultNoExportedAPILabel
+	}
 	return &testModule{
 		mod: &proxy.Module{
 			ModulePath: path,
@@ -2422,6 +2565,7 @@ package example_test
 							Name: "example",
 							Path: path + "/example",
 						},
+						Label: label,
 						Documentation: []*internal.Documentation{{
 							GOOS:     internal.All,
 							GOARCH:   internal.All,
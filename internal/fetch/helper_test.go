@@ -63,6 +63,9 @@ func cleanFetchResult(t *testing.T, fr *FetchResult) *FetchResult {
 			IsRedistributable: u.IsRedistributable,
 			Licenses:          u.Licenses,
 		}
+		if u.IsPackage() {
+			u.IsImportable = internal.IsImportable(u.Path, u.Name)
+		}
 		if u.IsPackage() && shouldSetPVS {
 			fr.PackageVersionStates = append(
 				fr.PackageVersionStates, &internal.PackageVersionState{
@@ -149,6 +152,7 @@ func proxyFetcher(t *testing.T, withLicenseDetector bool, ctx context.Context, m
 		ModulePath: modulePath,
 		Version:    version,
 		Files:      mod.Files,
+		Origin:     mod.Origin,
 	}})
 	defer teardownProxy()
 	got := FetchModule(ctx, modulePath, fetchVersion, proxyClient, source.NewClientForTesting())
@@ -182,7 +186,7 @@ func localFetcher(t *testing.T, withLicenseDetector bool, ctx context.Context, m
 	if err != nil {
 		t.Fatal("couldn't create zip reader")
 	}
-	d := licenses.NewDetector(modulePath, LocalVersion, zipReader, func(format string, args ...interface{}) {
+	d := licenses.NewDetector(modulePath, LocalVersion, zipReader, 0, func(format string, args ...interface{}) {
 		log.Infof(ctx, format, args...)
 	})
 	return got, d
@@ -208,7 +212,7 @@ func licenseDetector(ctx context.Context, t *testing.T, modulePath, version stri
 	logf := func(format string, args ...interface{}) {
 		log.Infof(ctx, format, args...)
 	}
-	return licenses.NewDetector(modulePath, version, zipReader, logf)
+	return licenses.NewDetector(modulePath, version, zipReader, 0, logf)
 }
 
 func sortFetchResult(fr *FetchResult) {
@@ -4,11 +4,22 @@
 
 package fetch
 
+import (
+	"runtime"
+
+	"golang.org/x/pkgsite/internal/godoc"
+)
+
 // Limits for discovery worker.
 const (
 	maxPackagesPerModule = 10000
 	maxImportsPerPackage = 1000
 
+	// maxImportedSymbolsPerPackage is the maximum number of symbols of
+	// imported packages recorded for a package. Symbols beyond it are
+	// dropped.
+	maxImportedSymbolsPerPackage = 1000
+
 	// MaxFileSize is the maximum filesize that is allowed for reading.
 	// The fetch process should fail if it encounters a file exceeding
 	// this limit.
@@ -16,3 +27,82 @@ const (
 )
 
 const megabyte = 1000 * 1000
+
+// FetchOptions lets callers of FetchModuleWithOptions override the default
+// limits used when processing a module. A zero field means that the default
+// limit is used.
+type FetchOptions struct {
+	// MaxFileSize is the maximum size of a file in the module zip that will
+	// be read. Packages containing a larger .go file are marked incomplete.
+	// The default is MaxFileSize.
+	MaxFileSize uint64
+
+	// MaxDocumentationHTML is the maximum size of the rendered documentation
+	// HTML of a package. Larger documentation is replaced with a notice.
+	// The default is godoc.MaxDocumentationHTML.
+	MaxDocumentationHTML int
+
+	// MaxExtractWorkers is the maximum number of packages of a module that
+	// are loaded concurrently. The default is runtime.GOMAXPROCS(0).
+	MaxExtractWorkers int
+
+	// AllowMajorVersionMismatch makes a module whose go.mod path differs from
+	// its module path only in the major version suffix acceptable, as long as
+	// the suffix in go.mod agrees with the version being fetched. Some
+	// proxies serve such modules, although the go command rejects them. By
+	// default, they are alternative modules.
+	AllowMajorVersionMismatch bool
+
+	// SkipDocumentationHTML skips rendering the documentation HTML of
+	// packages, which is the most expensive part of processing a module. The
+	// other information about the packages, including their synopses, is
+	// still computed. Since the HTML is not rendered, MaxDocumentationHTML is
+	// not enforced. It is meant for indexing module metadata.
+	SkipDocumentationHTML bool
+
+	// NoExportedAPILabel is the label given to packages that have a package
+	// comment but export nothing. Such packages are usually glue code that is
+	// not useful to import. The default is DefaultNoExportedAPILabel.
+	NoExportedAPILabel string
+
+	// ExcludeNoExportedAPIFromSearch keeps the packages that are given the
+	// NoExportedAPILabel out of search.
+	ExcludeNoExportedAPIFromSearch bool
+
+	// LicenseCoverageThreshold is the minimum percentage of a license file
+	// that must contain license text for the file to be classified as a known
+	// license. The default is licenses.DefaultCoverageThreshold.
+	LicenseCoverageThreshold float64
+}
+
+// DefaultNoExportedAPILabel is the default value of
+// FetchOptions.NoExportedAPILabel.
+const DefaultNoExportedAPILabel = "no exported API"
+
+func (o FetchOptions) maxFileSize() uint64 {
+	if o.MaxFileSize == 0 {
+		return MaxFileSize
+	}
+	return o.MaxFileSize
+}
+
+func (o FetchOptions) maxDocumentationHTML() int {
+	if o.MaxDocumentationHTML == 0 {
+		return godoc.MaxDocumentationHTML
+	}
+	return o.MaxDocumentationHTML
+}
+
+func (o FetchOptions) maxExtractWorkers() int {
+	if o.MaxExtractWorkers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return o.MaxExtractWorkers
+}
+
+func (o FetchOptions) noExportedAPILabel() string {
+	if o.NoExportedAPILabel == "" {
+		return DefaultNoExportedAPILabel
+	}
+	return o.NoExportedAPILabel
+}
@@ -23,13 +23,13 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"unicode"
 
 	"go.opencensus.io/trace"
 	"golang.org/x/pkgsite/internal"
-	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/godoc"
-	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/stdlib"
 )
@@ -54,7 +54,7 @@ func (bpe *BadPackageError) Error() string { return bpe.Err.Error() }
 // If a package is fine except that its documentation is too large, loadPackage
 // returns a goPackage whose err field is a non-nil error with godoc.ErrTooLarge in its chain.
 func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath string,
-	sourceInfo *source.Info, modInfo *godoc.ModuleInfo) (_ *goPackage, err error) {
+	sourceInfo *source.Info, modInfo *godoc.ModuleInfo, opts FetchOptions) (_ *goPackage, err error) {
 	defer derrors.Wrap(&err, "loadPackage(ctx, zipGoFiles, %q, sourceInfo, modInfo)", innerPath)
 	ctx, span := trace.StartSpan(ctx, "fetch.loadPackage")
 	defer span.End()
@@ -63,7 +63,7 @@ func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath string,
 	files := make(map[string][]byte)
 	for _, f := range zipGoFiles {
 		_, name := path.Split(f.Name)
-		b, err := readZipFile(f, MaxFileSize)
+		b, err := readZipFile(f, int64(opts.maxFileSize()))
 		if err != nil {
 			return nil, err
 		}
@@ -77,12 +77,19 @@ func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath string,
 	}
 	v1path := internal.V1Path(importPath, modulePath)
 
+	cgo := usesCgo(files)
 	var pkg *goPackage
 	// Parse the package for each build context.
 	// The documentation is determined by the set of matching files, so keep
 	// track of those to avoid duplication.
 	docsByFiles := map[string]*internal.Documentation{}
-	for _, bc := range internal.BuildContexts {
+	bcs := buildContexts(files)
+	// Keep track of the build contexts the package builds in, to record
+	// them if it does not build in all of bcs.
+	var supported []internal.BuildContext
+	// Keep track of the files that make up the package in any of them.
+	loadedFiles := map[string]bool{}
+	for _, bc := range bcs {
 		mfiles, err := matchingFiles(bc.GOOS, bc.GOARCH, files)
 		if err != nil {
 			return nil, err
@@ -110,10 +117,11 @@ func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath string,
 				doc2.API = append(doc2.API, &s2)
 			}
 			pkg.docs = append(pkg.docs, &doc2)
+			supported = append(supported, bc)
 			continue
 		}
-		name, imports, synopsis, source, api, err := loadPackageForBuildContext(ctx,
-			mfiles, innerPath, sourceInfo, modInfo)
+		name, imports, syms, synopsis, source, api, err := loadPackageForBuildContext(ctx,
+			mfiles, innerPath, sourceInfo, modInfo, opts)
 		for _, s := range api {
 			s.GOOS = bc.GOOS
 			s.GOARCH = bc.GOARCH
@@ -131,12 +139,18 @@ func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath string,
 			// The doc for this build context is too large. To keep things
 			// simple, return a single package with this error that will be used
 			// for all build contexts, and ignore the others.
+			for name := range mfiles {
+				loadedFiles[name] = true
+			}
 			return &goPackage{
-				err:     err,
-				path:    importPath,
-				v1path:  v1path,
-				name:    name,
-				imports: imports,
+				err:             err,
+				path:            importPath,
+				v1path:          v1path,
+				name:            name,
+				imports:         imports,
+				importedSymbols: syms,
+				usesCgo:         cgo,
+				files:           nonTestFileNames(loadedFiles),
 				docs: []*internal.Documentation{{
 					GOOS:     internal.All,
 					GOARCH:   internal.All,
@@ -152,10 +166,12 @@ func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath string,
 			// No error.
 			if pkg == nil {
 				pkg = &goPackage{
-					path:    importPath,
-					v1path:  v1path,
-					name:    name,
-					imports: imports, // Use the imports from the first successful build context.
+					path:            importPath,
+					v1path:          v1path,
+					name:            name,
+					imports:         imports, // Use the imports from the first successful build context.
+					importedSymbols: syms,
+					usesCgo:         cgo,
 				}
 			}
 			// All the build contexts should use the same package name. Although
@@ -175,12 +191,22 @@ func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath string,
 			}
 			docsByFiles[filesKey] = doc
 			pkg.docs = append(pkg.docs, doc)
+			supported = append(supported, bc)
+			for name := range mfiles {
+				loadedFiles[name] = true
+			}
 		}
 	}
+	if pkg != nil {
+		pkg.files = nonTestFileNames(loadedFiles)
+	}
+	if pkg != nil && len(supported) < len(bcs) {
+		pkg.supportedBuildContexts = supported
+	}
 	// If all the build contexts succeeded and had the same set of files, then
 	// assume that the package doc is valid for all build contexts. Represent
 	// this with a single Documentation whose GOOS and GOARCH are both "all".
-	if len(docsByFiles) == 1 && len(pkg.docs) == len(internal.BuildContexts) {
+	if len(docsByFiles) == 1 && len(pkg.docs) == len(bcs) {
 		pkg.docs = pkg.docs[:1]
 		pkg.docs[0].GOOS = internal.All
 		pkg.docs[0].GOARCH = internal.All
@@ -196,6 +222,118 @@ func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath string,
 	return pkg, nil
 }
 
+// buildContexts returns the build contexts to load a package made of files
+// for: internal.BuildContexts, followed by the first of internal.Ports for
+// each GOOS and GOARCH that is mentioned by the files and not covered by an
+// earlier build context.
+func buildContexts(files map[string][]byte) []internal.BuildContext {
+	mentioned := map[string]bool{}
+	for name, contents := range files {
+		for _, t := range buildTags(name, contents) {
+			mentioned[t] = true
+		}
+	}
+	bcs := append([]internal.BuildContext(nil), internal.BuildContexts...)
+	covered := map[string]bool{}
+	for _, bc := range bcs {
+		covered[bc.GOOS] = true
+		covered[bc.GOARCH] = true
+	}
+	for _, p := range internal.Ports {
+		if (mentioned[p.GOOS] && !covered[p.GOOS]) || (mentioned[p.GOARCH] && !covered[p.GOARCH]) {
+			bcs = append(bcs, p)
+			covered[p.GOOS] = true
+			covered[p.GOARCH] = true
+		}
+	}
+	return bcs
+}
+
+// buildTags returns the words in the GOOS and GOARCH suffixes of the file
+// name, and in the build constraints at the top of the file contents. Not all
+// of them need be GOOS or GOARCH values.
+func buildTags(name string, contents []byte) []string {
+	var tags []string
+	parts := strings.Split(strings.TrimSuffix(strings.TrimSuffix(name, ".go"), "_test"), "_")
+	if n := len(parts); n > 2 {
+		tags = append(tags, parts[n-2:]...)
+	} else if n == 2 {
+		tags = append(tags, parts[1])
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			// Build constraints must precede the package clause.
+			break
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "//"))
+		var expr string
+		switch {
+		case strings.HasPrefix(line, "+build"):
+			expr = strings.TrimPrefix(line, "+build")
+		case strings.HasPrefix(line, "go:build"):
+			expr = strings.TrimPrefix(line, "go:build")
+		default:
+			continue
+		}
+		tags = append(tags, strings.FieldsFunc(expr, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.'
+		})...)
+	}
+	return tags
+}
+
+// usesCgo reports whether any of the non-test files imports "C", the
+// pseudo-package through which Go code refers to C code. Such files are
+// documented like any other: the C code in the comment preceding the import
+// is not part of the documentation, and references to C declarations are
+// shown as written.
+func usesCgo(files map[string][]byte) bool {
+	fset := token.NewFileSet()
+	for name, contents := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, contents, parser.ImportsOnly)
+		if err != nil {
+			// The error is reported when the file is loaded.
+			continue
+		}
+		for _, spec := range f.Imports {
+			if spec.Path.Value == `"C"` {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withoutCgoImport returns imports without "C", which is not a package.
+func withoutCgoImport(imports []string) []string {
+	for i, p := range imports {
+		if p == "C" {
+			return append(imports[:i:i], imports[i+1:]...)
+		}
+	}
+	return imports
+}
+
+// nonTestFileNames returns the sorted names in files that are not the names
+// of test files.
+func nonTestFileNames(files map[string]bool) []string {
+	var names []string
+	for name := range files {
+		if !strings.HasSuffix(name, "_test.go") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // mapKeyForFiles generates a value that corresponds to the given set of file
 // names and can be used as a map key.
 // It assumes the filenames do not contain spaces.
@@ -219,8 +357,8 @@ var httpPost = http.Post
 // .go files that have been verified to be of reasonable size and that match
 // the build context.
 //
-// It returns the package name, list of imports, the package synopsis, and the
-// serialized source (AST) for the package.
+// It returns the package name, list of imports, the symbols it uses from
+// them, the package synopsis, and the serialized source (AST) for the package.
 //
 // It returns an error with NotFound in its chain if the directory doesn't
 // contain a Go package or all .go files have been excluded by constraints. A
@@ -229,15 +367,17 @@ var httpPost = http.Post
 //
 // If it returns an error with ErrTooLarge in its chain, the other return values
 // are still valid.
-func loadPackageForBuildContext(ctx context.Context, files map[string][]byte, innerPath string, sourceInfo *source.Info, modInfo *godoc.ModuleInfo) (
-	name string, imports []string, synopsis string, source []byte, api []*internal.Symbol, err error) {
+func loadPackageForBuildContext(ctx context.Context, files map[string][]byte, innerPath string, sourceInfo *source.Info, modInfo *godoc.ModuleInfo, opts FetchOptions) (
+	name string, imports, importedSyms []string, synopsis string, source []byte, api []*internal.Symbol, err error) {
 	modulePath := modInfo.ModulePath
 	defer derrors.Wrap(&err, "loadPackageWithBuildContext(files, %q, %q, %+v)", innerPath, modulePath, sourceInfo)
 
 	packageName, goFiles, fset, err := loadFilesWithBuildContext(innerPath, files)
 	if err != nil {
-		return "", nil, "", nil, nil, err
+		return "", nil, nil, "", nil, nil, err
 	}
+	// Find the symbol uses before the ASTs are modified for documentation.
+	importedSyms = importedSymbols(goFiles)
 	docPkg := godoc.NewPackage(fset, modInfo.ModulePackages)
 	for _, pf := range goFiles {
 		removeNodes := true
@@ -252,14 +392,21 @@ func loadPackageForBuildContext(ctx context.Context, files map[string][]byte, in
 	// Encode first, because Render messes with the AST.
 	src, err := docPkg.Encode(ctx)
 	if err != nil {
-		return "", nil, "", nil, nil, err
+		return "", nil, nil, "", nil, nil, err
 	}
 
-	synopsis, imports, _, api, err = docPkg.Render(ctx, innerPath, sourceInfo, modInfo)
+	if opts.SkipDocumentationHTML {
+		synopsis, imports, api, err = docPkg.RenderMetadata(ctx, innerPath, modInfo)
+		if err != nil {
+			return "", nil, nil, "", nil, nil, err
+		}
+		return packageName, withoutCgoImport(imports), importedSyms, synopsis, src, api, nil
+	}
+	synopsis, imports, _, api, err = docPkg.RenderWithLimit(ctx, innerPath, sourceInfo, modInfo, opts.maxDocumentationHTML())
 	if err != nil && !errors.Is(err, godoc.ErrTooLarge) {
-		return "", nil, "", nil, nil, err
+		return "", nil, nil, "", nil, nil, err
 	}
-	return packageName, imports, synopsis, src, api, err
+	return packageName, withoutCgoImport(imports), importedSyms, synopsis, src, api, err
 }
 
 // loadFilesWithBuildContext loads all the given Go files at innerPath. It
@@ -386,30 +533,63 @@ const mib = 1024 * 1024
 
 // The largest module zip size we can comfortably process.
 // We probably will OOM if we process a module whose zip is larger.
+// It is accessed atomically, because SetZipLimits can change it at any time.
 var maxModuleZipSize int64 = math.MaxInt64
 
-func init() {
-	v := config.GetEnvInt("GO_DISCOVERY_MAX_MODULE_ZIP_MI", -1)
-	if v > 0 {
-		maxModuleZipSize = int64(v) * mib
+// zipLoadShedder sheds fetches when the total size of the zips being
+// processed would exceed its limit. It does nothing while its limit is zero.
+var zipLoadShedder = &loadShedder{}
+
+// maxZipLimitMi is the largest limit, in mebibytes, that SetZipLimits
+// accepts, so that the limit in bytes fits in an int64.
+const maxZipLimitMi = math.MaxInt64 / mib
+
+// ZipLimits are the limits on the sizes of module zips that are processed.
+type ZipLimits struct {
+	// MaxInFlightMi is the total size, in mebibytes, of the zips that can be
+	// processed at once. Fetches that would exceed it are shed. Zero means
+	// there is no limit.
+	MaxInFlightMi uint64
+	// MaxModuleMi is the size, in mebibytes, of the largest zip that is
+	// processed. Larger modules fail with derrors.ModuleTooLarge. Zero means
+	// there is no limit.
+	MaxModuleMi uint64
+}
+
+// SetZipLimits changes the limits on the sizes of module zips. It affects
+// fetches that start after it returns. There are no limits until it is
+// called. It returns an error, and changes nothing, if a limit is larger than
+// maxZipLimitMi.
+func SetZipLimits(l ZipLimits) error {
+	if l.MaxInFlightMi > maxZipLimitMi || l.MaxModuleMi > maxZipLimitMi {
+		return fmt.Errorf("%w: zip limits %+v exceed %dMi", derrors.InvalidArgument, l, uint64(maxZipLimitMi))
 	}
+	zipLoadShedder.setMaxSizeInFlight(l.MaxInFlightMi * mib)
+	max := int64(math.MaxInt64)
+	if l.MaxModuleMi > 0 {
+		max = int64(l.MaxModuleMi) * mib
+	}
+	atomic.StoreInt64(&maxModuleZipSize, max)
+	return nil
 }
 
-var zipLoadShedder *loadShedder
+// zipLimitsSet reports whether any limit on the sizes of module zips is set,
+// in which case the size of a zip must be known before it is processed.
+func zipLimitsSet() bool {
+	return zipLoadShedder.enabled() || atomic.LoadInt64(&maxModuleZipSize) != math.MaxInt64
+}
 
-func init() {
-	ctx := context.Background()
-	mebis := config.GetEnvInt("GO_DISCOVERY_MAX_IN_FLIGHT_ZIP_MI", -1)
-	if mebis > 0 {
-		log.Infof(ctx, "shedding load over %dMi", mebis)
-		zipLoadShedder = &loadShedder{maxSizeInFlight: uint64(mebis) * mib}
+// CurrentZipLimits returns the limits on the sizes of module zips.
+func CurrentZipLimits() ZipLimits {
+	var l ZipLimits
+	l.MaxInFlightMi = zipLoadShedder.stats().MaxSizeInFlight / mib
+	if max := atomic.LoadInt64(&maxModuleZipSize); max != math.MaxInt64 {
+		l.MaxModuleMi = uint64(max / mib)
 	}
+	return l
 }
 
 // ZipLoadShedStats returns a snapshot of the current LoadShedStats for zip files.
 func ZipLoadShedStats() LoadShedStats {
-	if zipLoadShedder != nil {
-		return zipLoadShedder.stats()
-	}
-	return LoadShedStats{}
+	return zipLoadShedder.stats()
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/testing/testhelper"
 )
 
@@ -89,3 +90,53 @@ func TestMatchingFiles(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildContexts(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		files map[string]string
+		want  []internal.BuildContext
+	}{
+		{
+			name:  "no constraints",
+			files: map[string]string{"p.go": "package p"},
+			want:  internal.BuildContexts,
+		},
+		{
+			name: "file names",
+			files: map[string]string{
+				"p.go":             "package p",
+				"p_arm64.go":       "package p",
+				"p_plan9.go":       "package p",
+				"p_windows_arm.go": "package p",
+				"p_test.go":        "package p",
+			},
+			want: append(append([]internal.BuildContext(nil), internal.BuildContexts...),
+				internal.BuildContext{GOOS: "linux", GOARCH: "arm"},
+				internal.BuildContext{GOOS: "linux", GOARCH: "arm64"},
+				internal.BuildContext{GOOS: "plan9", GOARCH: "386"}),
+		},
+		{
+			name: "build constraints",
+			files: map[string]string{
+				"a.go": "// +build 386 amd64p32\n\npackage p",
+				"b.go": "//go:build !(s390x || unknown)\n\npackage p",
+				"c.go": "package p\n\n// +build mips\n",
+			},
+			want: append(append([]internal.BuildContext(nil), internal.BuildContexts...),
+				internal.BuildContext{GOOS: "linux", GOARCH: "386"},
+				internal.BuildContext{GOOS: "linux", GOARCH: "s390x"}),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			files := map[string][]byte{}
+			for name, contents := range test.files {
+				files[name] = []byte(contents)
+			}
+			got := buildContexts(files)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -9,15 +9,15 @@ import (
 )
 
 type loadShedder struct {
-	// The maximum size of requests that can be processed at once. If an
-	// incoming request would cause sizeInFlight to exceed this value, it won't
-	// be processed.
-	maxSizeInFlight uint64
-
 	// Protects the variables below, and also serializes shedding decisions so
 	// multiple simultaneous requests are handled properly.
 	mu sync.Mutex
 
+	// The maximum size of requests that can be processed at once. If an
+	// incoming request would cause sizeInFlight to exceed this value, it won't
+	// be processed. Zero means there is no maximum.
+	maxSizeInFlight uint64
+
 	sizeInFlight     uint64 // size of requests currently in progress.
 	requestsInFlight int    // number of request currently in progress
 	requestsTotal    int    // total fetch requests ever seen
@@ -33,7 +33,7 @@ func (ls *loadShedder) shouldShed(size uint64) (_ bool, deferFunc func()) {
 	ls.requestsTotal++
 	// Shed if size exceeds our limit--except that if nothing is being
 	// processed, accept this request to avoid starving it forever.
-	if ls.sizeInFlight > 0 && ls.sizeInFlight+size > ls.maxSizeInFlight {
+	if ls.maxSizeInFlight > 0 && ls.sizeInFlight > 0 && ls.sizeInFlight+size > ls.maxSizeInFlight {
 		ls.requestsShed++
 		return true, func() {}
 	}
@@ -47,6 +47,22 @@ func (ls *loadShedder) shouldShed(size uint64) (_ bool, deferFunc func()) {
 	}
 }
 
+// enabled reports whether the load shedder has a maximum size.
+func (ls *loadShedder) enabled() bool {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.maxSizeInFlight > 0
+}
+
+// setMaxSizeInFlight changes the maximum size of requests that can be
+// processed at once. Zero disables load shedding. Requests already in flight
+// are not affected.
+func (ls *loadShedder) setMaxSizeInFlight(max uint64) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.maxSizeInFlight = max
+}
+
 // LoadShedStats holds statistics about load shedding.
 type LoadShedStats struct {
 	SizeInFlight     uint64
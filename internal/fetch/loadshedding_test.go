@@ -5,8 +5,13 @@
 package fetch
 
 import (
+	"context"
+	"errors"
 	"math"
 	"testing"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/proxy"
 )
 
 func TestDecideToShed(t *testing.T) {
@@ -57,3 +62,58 @@ func TestDecideToShed(t *testing.T) {
 		t.Fatalf("got %d, want %d", got, want)
 	}
 }
+
+func TestSetZipLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer SetZipLimits(CurrentZipLimits())
+
+	mod := &proxy.Module{
+		ModulePath: "example.com/shed",
+		Files:      map[string]string{"foo/foo.go": "package foo"},
+	}
+	// Another fetch of 1Mi is in flight.
+	_, release := zipLoadShedder.shouldShed(1 * mib)
+	defer release()
+
+	if err := SetZipLimits(ZipLimits{MaxInFlightMi: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := CurrentZipLimits(), (ZipLimits{MaxInFlightMi: 1}); got != want {
+		t.Errorf("CurrentZipLimits() = %+v, want %+v", got, want)
+	}
+	got, _ := proxyFetcher(t, false, ctx, mod, "")
+	got.Defer()
+	if !errors.Is(got.Error, derrors.SheddingLoad) {
+		t.Fatalf("with a tiny limit: got error %v, want SheddingLoad", got.Error)
+	}
+
+	if err := SetZipLimits(ZipLimits{MaxInFlightMi: 10}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = proxyFetcher(t, false, ctx, mod, "")
+	got.Defer()
+	if got.Error != nil {
+		t.Fatalf("with a larger limit: got error %v, want nil", got.Error)
+	}
+}
+
+func TestSetZipLimitsOverflow(t *testing.T) {
+	defer SetZipLimits(CurrentZipLimits())
+
+	want := ZipLimits{MaxInFlightMi: 1, MaxModuleMi: 2}
+	if err := SetZipLimits(want); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []ZipLimits{
+		{MaxInFlightMi: maxZipLimitMi + 1},
+		{MaxModuleMi: maxZipLimitMi + 1},
+	} {
+		if err := SetZipLimits(l); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("SetZipLimits(%+v) = %v, want InvalidArgument", l, err)
+		}
+	}
+	if got := CurrentZipLimits(); got != want {
+		t.Errorf("CurrentZipLimits() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"archive/zip"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// maintainersFiles are the base names of the files that list the people
+// responsible for a module, without extension, in order of preference.
+var maintainersFiles = []string{"MAINTAINERS", "OWNERS", "CODEOWNERS", "AUTHORS"}
+
+// maintainersDirs are the directories, relative to the module root, where
+// maintainersFiles are looked for.
+var maintainersDirs = []string{"", ".github", "docs"}
+
+// maintainersExts are the extensions that maintainersFiles may have.
+var maintainersExts = map[string]bool{"": true, ".md": true, ".txt": true}
+
+const (
+	// maxMaintainers is the maximum number of names kept from a maintainers
+	// file.
+	maxMaintainers = 100
+
+	// maxMaintainerLen is the maximum length of a name kept from a
+	// maintainers file.
+	maxMaintainerLen = 100
+)
+
+// extractMaintainersFromZip returns a summary of the file in r that lists the
+// people responsible for the module, or nil if there is none. If there is
+// more than one, the one that comes first in maintainersFiles is used. Files
+// larger than maxFileSize are ignored.
+func extractMaintainersFromZip(modulePath, resolvedVersion string, r *zip.Reader, maxFileSize uint64) (_ *internal.Maintainers, err error) {
+	defer derrors.Wrap(&err, "extractMaintainersFromZip(%q, %q, r)", modulePath, resolvedVersion)
+
+	var (
+		best     *zip.File
+		bestName string
+		bestRank = len(maintainersFiles)
+	)
+	prefix := moduleVersionDir(modulePath, resolvedVersion) + "/"
+	for _, f := range r.File {
+		name := strings.TrimPrefix(f.Name, prefix)
+		rank := maintainersFileRank(name)
+		if rank < bestRank && f.UncompressedSize64 <= maxFileSize {
+			best, bestName, bestRank = f, name, rank
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	c, err := readZipFile(best, int64(maxFileSize))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if strings.EqualFold(trimExt(path.Base(bestName)), "CODEOWNERS") {
+		names = parseCodeOwners(string(c))
+	} else {
+		names = parseMaintainers(string(c))
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return &internal.Maintainers{Filepath: bestName, Names: names}, nil
+}
+
+// maintainersFileRank returns the position in maintainersFiles of the file
+// with the given path relative to the module root, or len(maintainersFiles)
+// if it is not a maintainers file.
+func maintainersFileRank(file string) int {
+	dir, base := path.Split(file)
+	dir = strings.TrimSuffix(dir, "/")
+	if !maintainersExts[strings.ToLower(path.Ext(base))] {
+		return len(maintainersFiles)
+	}
+	inDir := false
+	for _, d := range maintainersDirs {
+		if dir == d {
+			inDir = true
+			break
+		}
+	}
+	if !inDir {
+		return len(maintainersFiles)
+	}
+	for i, m := range maintainersFiles {
+		if strings.EqualFold(trimExt(base), m) {
+			return i
+		}
+	}
+	return len(maintainersFiles)
+}
+
+func trimExt(file string) string {
+	return strings.TrimSuffix(file, path.Ext(file))
+}
+
+var (
+	// emailRE matches an email address, optionally in angle brackets.
+	emailRE = regexp.MustCompile(`<?[^\s<>@]+@[^\s<>@]+\.[^\s<>@]+>?`)
+
+	// markdownLinkRE matches a Markdown link. The first submatch is the
+	// link text.
+	markdownLinkRE = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+)
+
+// parseMaintainers returns the names in an AUTHORS, MAINTAINERS or OWNERS
+// file. Such files usually have one person per line, possibly in a Markdown
+// or YAML list. Comments, headings, YAML keys and email addresses are
+// omitted.
+func parseMaintainers(contents string) []string {
+	var names []string
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasSuffix(line, ":") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimLeft(line, "-*+"))
+		line = markdownLinkRE.ReplaceAllString(line, "$1")
+		line = emailRE.ReplaceAllString(line, "")
+		names = append(names, strings.Join(strings.Fields(line), " "))
+	}
+	return cleanMaintainers(names)
+}
+
+// parseCodeOwners returns the owners in a CODEOWNERS file. Each line of such a
+// file is a file pattern followed by owners, which are @user or @org/team
+// handles or email addresses. Email addresses are omitted.
+func parseCodeOwners(contents string) []string {
+	var names []string
+	for _, line := range strings.Split(contents, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, f := range fields[1:] {
+			if strings.HasPrefix(f, "@") {
+				names = append(names, f)
+			}
+		}
+	}
+	return cleanMaintainers(names)
+}
+
+// cleanMaintainers removes empty, overlong and duplicate names, and keeps at
+// most maxMaintainers of the rest.
+func cleanMaintainers(names []string) []string {
+	var cleaned []string
+	seen := map[string]bool{}
+	for _, n := range names {
+		if n == "" || len(n) > maxMaintainerLen || seen[n] {
+			continue
+		}
+		seen[n] = true
+		cleaned = append(cleaned, n)
+		if len(cleaned) == maxMaintainers {
+			break
+		}
+	}
+	return cleaned
+}
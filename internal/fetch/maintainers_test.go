@@ -0,0 +1,162 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+)
+
+func TestFetchModuleMaintainers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const modulePath = "example.com/maintained"
+	proxyClient, teardownProxy := proxy.SetupTestClient(t, []*proxy.Module{{
+		ModulePath: modulePath,
+		Version:    "v1.0.0",
+		Files: map[string]string{
+			"LICENSE": testhelper.MITLicense,
+			"p/p.go":  "package p",
+			"AUTHORS": `# This is the list of authors of the module.
+
+Jane Doe <jane@example.com>
+John Smith
+bob@example.com
+`,
+		},
+	}})
+	defer teardownProxy()
+
+	got := FetchModule(ctx, modulePath, "v1.0.0", proxyClient, source.NewClientForTesting())
+	defer got.Defer()
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	want := &internal.Maintainers{
+		Filepath: "AUTHORS",
+		Names:    []string{"Jane Doe", "John Smith"},
+	}
+	if diff := cmp.Diff(want, got.Module.Maintainers); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestProcessZipFileBadMaintainers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const (
+		modulePath = "example.com/maintained"
+		version    = "v1.0.0"
+		prefix     = modulePath + "@" + version + "/"
+	)
+	data, err := testhelper.ZipContents(map[string]string{
+		prefix + "go.mod":  "module " + modulePath,
+		prefix + "LICENSE": testhelper.MITLicense,
+		prefix + "p/p.go":  "package p",
+		prefix + "AUTHORS": "Jane Doe\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt the checksum of the AUTHORS file so that reading it fails.
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "/AUTHORS") {
+			f.CRC32 ^= 1
+		}
+	}
+	mod, _, err := processZipFile(ctx, modulePath, version, time.Time{}, r, source.NewClientForTesting(), FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod.Maintainers != nil {
+		t.Errorf("got maintainers %+v, want nil", mod.Maintainers)
+	}
+}
+
+func TestExtractMaintainersFromZip(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, test := range []struct {
+		name  string
+		files map[string]string
+		want  *internal.Maintainers
+	}{
+		{
+			name:  "none",
+			files: map[string]string{"p/AUTHORS": "Jane Doe"},
+		},
+		{
+			name: "prefer MAINTAINERS",
+			files: map[string]string{
+				"AUTHORS":        "Jane Doe",
+				"MAINTAINERS.md": "# Maintainers\n\n- [Jane Doe](https://example.com/jane)\n* Bob (@bob)\n",
+			},
+			want: &internal.Maintainers{
+				Filepath: "MAINTAINERS.md",
+				Names:    []string{"Jane Doe", "Bob (@bob)"},
+			},
+		},
+		{
+			name: "OWNERS list",
+			files: map[string]string{
+				"OWNERS": "approvers:\n  - alice\n  - bob\nreviewers:\n  - alice\n",
+			},
+			want: &internal.Maintainers{
+				Filepath: "OWNERS",
+				Names:    []string{"alice", "bob"},
+			},
+		},
+		{
+			name: "CODEOWNERS",
+			files: map[string]string{
+				".github/CODEOWNERS": "# Owners.\n* @alice @org/team\n/docs/ docs@example.com @alice # Docs.\n",
+			},
+			want: &internal.Maintainers{
+				Filepath: ".github/CODEOWNERS",
+				Names:    []string{"@alice", "@org/team"},
+			},
+		},
+		{
+			name:  "only emails",
+			files: map[string]string{"AUTHORS": "jane@example.com\n"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			const modulePath = "example.com/m"
+			test.files["p/p.go"] = "package p"
+			proxyClient, teardownProxy := proxy.SetupTestClient(t, []*proxy.Module{
+				{ModulePath: modulePath, Files: test.files}})
+			defer teardownProxy()
+			reader, err := proxyClient.Zip(ctx, modulePath, "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := extractMaintainersFromZip(modulePath, "v1.0.0", reader, MaxFileSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
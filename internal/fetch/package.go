@@ -12,7 +12,9 @@ import (
 	"fmt"
 	"path"
 	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
 
 	"go.opencensus.io/trace"
 	"golang.org/x/mod/module"
@@ -30,6 +32,7 @@ type goPackage struct {
 	path              string
 	name              string
 	imports           []string
+	importedSymbols   []string // see importedSymbols
 	isRedistributable bool
 	licenseMeta       []*licenses.Metadata // metadata of applicable licenses
 	// v1path is the package path of a package with major version 1 in a given
@@ -37,6 +40,10 @@ type goPackage struct {
 	v1path string
 	docs   []*internal.Documentation // doc for different build contexts
 	err    error                     // non-fatal error when loading the package (e.g. documentation is too large)
+	// supportedBuildContexts is internal.Unit.SupportedBuildContexts.
+	supportedBuildContexts []internal.BuildContext
+	usesCgo                bool     // whether the package imports "C"
+	files                  []string // see internal.Unit.Files
 }
 
 // extractPackagesFromZip returns a slice of packages from the module zip r.
@@ -45,10 +52,10 @@ type goPackage struct {
 // The second return value says whether any packages are "incomplete," meaning
 // that they contained .go files but couldn't be processed due to current
 // limitations of this site. The limitations are:
-// * a maximum file size (MaxFileSize)
+// * a maximum file size (opts.MaxFileSize, or MaxFileSize by default)
 // * the particular set of build contexts we consider (goEnvs)
 // * whether the import path is valid.
-func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion string, r *zip.Reader, d *licenses.Detector, sourceInfo *source.Info) (_ []*goPackage, _ []*internal.PackageVersionState, err error) {
+func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion string, r *zip.Reader, d *licenses.Detector, sourceInfo *source.Info, opts FetchOptions) (_ []*goPackage, _ []*internal.PackageVersionState, err error) {
 	defer derrors.Wrap(&err, "extractPackagesFromZip(ctx, %q, %q, r, d)", modulePath, resolvedVersion)
 	ctx, span := trace.StartSpan(ctx, "fetch.extractPackagesFromZip")
 	defer span.End()
@@ -98,6 +105,8 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 		// prevent processing of other packages in the module.
 		incompleteDirs       = make(map[string]bool)
 		packageVersionStates = []*internal.PackageVersionState{}
+
+		maxFileSize = opts.maxFileSize()
 	)
 
 	// Phase 1.
@@ -147,11 +156,11 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 			})
 			continue
 		}
-		if f.UncompressedSize64 > MaxFileSize {
+		if f.UncompressedSize64 > maxFileSize {
 			incompleteDirs[innerPath] = true
 			status := derrors.ToStatus(derrors.PackageMaxFileSizeLimitExceeded)
 			err := fmt.Sprintf("Unable to process %s: file size %d exceeds max limit %d",
-				f.Name, f.UncompressedSize64, MaxFileSize)
+				f.Name, f.UncompressedSize64, maxFileSize)
 			packageVersionStates = append(packageVersionStates, &internal.PackageVersionState{
 				ModulePath:  modulePath,
 				PackagePath: importPath,
@@ -173,20 +182,49 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 	// Phase 2.
 	// If we got this far, the file metadata was okay.
 	// Start reading the file contents now to extract information
-	// about Go packages.
-	var pkgs []*goPackage
-	for innerPath, goFiles := range dirs {
+	// about Go packages. Packages are loaded concurrently, but their results
+	// are processed in order of their directories, so the output does not
+	// depend on the order in which the loads finish. Each result is processed
+	// as soon as it and those before it are available, rather than after the
+	// whole module is loaded.
+	var innerPaths []string
+	for innerPath := range dirs {
 		if incompleteDirs[innerPath] {
 			// Something went wrong when processing this directory, so we skip.
 			log.Infof(ctx, "Skipping %q because it is incomplete", innerPath)
 			continue
 		}
+		innerPaths = append(innerPaths, innerPath)
+	}
+	sort.Strings(innerPaths)
+	results, stop := loadPackages(ctx, innerPaths, dirs, sourceInfo, modInfo, opts)
+	// Stop loading if we return early, and wait for the loads in progress,
+	// so that none outlive this call.
+	defer stop()
 
+	var pkgs []*goPackage
+	for i, innerPath := range innerPaths {
+		goFiles := dirs[innerPath]
 		var (
 			status error
 			errMsg string
 		)
-		pkg, err := loadPackage(ctx, goFiles, innerPath, sourceInfo, modInfo)
+		res := <-results[i]
+		pkg, err := res.pkg, res.err
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if errors.Is(err, derrors.PackageInternalError) {
+			incompleteDirs[innerPath] = true
+			packageVersionStates = append(packageVersionStates, &internal.PackageVersionState{
+				ModulePath:  modulePath,
+				PackagePath: path.Join(modulePath, innerPath),
+				Version:     resolvedVersion,
+				Status:      derrors.ToStatus(derrors.PackageInternalError),
+				Error:       err.Error(),
+			})
+			continue
+		}
 		if bpe := (*BadPackageError)(nil); errors.As(err, &bpe) {
 			incompleteDirs[innerPath] = true
 			status = derrors.PackageInvalidContents
@@ -211,6 +249,8 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 				// ErrTooLarge is the only valid value of pkg.err.
 				return nil, nil, fmt.Errorf("bad package error for %s: %v", pkg.path, pkg.err)
 			}
+			// d is not safe for concurrent use, so it is consulted here,
+			// after the packages have been loaded.
 			if d != nil { //  should only be nil for tests
 				isRedist, lics := d.PackageInfo(innerPath)
 				pkg.isRedistributable = isRedist
@@ -235,6 +275,81 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 	return pkgs, packageVersionStates, nil
 }
 
+// loadResult holds the results of a call to loadPackage.
+type loadResult struct {
+	pkg *goPackage
+	err error
+}
+
+// loadPackageFunc is loadPackage. It is a variable for testing.
+var loadPackageFunc = loadPackage
+
+// loadPackages calls loadPackage for the Go files of each directory in
+// innerPaths, running at most opts.MaxExtractWorkers calls at a time. It
+// returns immediately, with a channel for each of innerPaths, in the same
+// order, on which the result of its call is sent, and a function that
+// cancels the calls that have not finished, starts no more of them, and waits
+// for those in progress to return. The caller must call stop. If ctx is done
+// before a call is started, ctx.Err() is sent as its result instead.
+//
+// The calls share modInfo and sourceInfo, which they only read.
+func loadPackages(ctx context.Context, innerPaths []string, dirs map[string][]*zip.File,
+	sourceInfo *source.Info, modInfo *godoc.ModuleInfo, opts FetchOptions) (_ []<-chan loadResult, stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	results := make([]<-chan loadResult, len(innerPaths))
+	goFiles := make([][]*zip.File, len(innerPaths))
+	chans := make([]chan loadResult, len(innerPaths))
+	for i, innerPath := range innerPaths {
+		// The channels are buffered so that loads never wait for their
+		// results to be received.
+		chans[i] = make(chan loadResult, 1)
+		results[i] = chans[i]
+		goFiles[i] = dirs[innerPath]
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sem := make(chan struct{}, opts.maxExtractWorkers())
+		for i, innerPath := range innerPaths {
+			i, innerPath := i, innerPath
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if err := ctx.Err(); err != nil {
+				// Fail the loads that were never started, so that no
+				// receiver waits for them.
+				for _, c := range chans[i:] {
+					c <- loadResult{err: err}
+				}
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var res loadResult
+				defer func() {
+					// The recover in extractPackagesFromZip does not apply to
+					// this goroutine, so convert panics to errors here. They
+					// affect only this package, not the whole module.
+					if e := recover(); e != nil {
+						log.Errorf(ctx, "panic loading package %q: %v\n\n%s", innerPath, e, debug.Stack())
+						res = loadResult{err: fmt.Errorf("%w: panic: %v", derrors.PackageInternalError, e)}
+					}
+					chans[i] <- res
+					<-sem
+				}()
+				res.pkg, res.err = loadPackageFunc(ctx, goFiles[i], innerPath, sourceInfo, modInfo, opts)
+			}()
+		}
+	}()
+	return results, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
 // ignoredByGoTool reports whether the given import path corresponds
 // to a directory that would be ignored by the go tool.
 //
@@ -0,0 +1,378 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/godoc"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+)
+
+// syntheticModuleZip returns the zip of a module with n packages, each of
+// which has some documented declarations.
+func syntheticModuleZip(t testing.TB, modulePath, version string, n int) *zip.Reader {
+	t.Helper()
+	return syntheticModuleZipWithIgnored(t, modulePath, version, n, 0)
+}
+
+// syntheticModuleZipWithIgnored is like syntheticModuleZip, but each package
+// also has a file of about the given size that is excluded by a build
+// constraint, so it is read but is not part of the output.
+func syntheticModuleZipWithIgnored(t testing.TB, modulePath, version string, n, ignoredSize int) *zip.Reader {
+	t.Helper()
+	prefix := modulePath + "@" + version + "/"
+	files := map[string]string{
+		prefix + "go.mod":  "module " + modulePath,
+		prefix + "LICENSE": testhelper.MITLicense,
+	}
+	for i := 0; i < n; i++ {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "// Package p%d is package number %d.\npackage p%d\n\n", i, i, i)
+		if i > 0 {
+			fmt.Fprintf(&b, "import %q\n\n", fmt.Sprintf("%s/p%d", modulePath, i-1))
+		}
+		for j := 0; j < 20; j++ {
+			fmt.Fprintf(&b, "// F%d returns %d.\nfunc F%d() int { return %d }\n\n", j, j, j, j)
+			fmt.Fprintf(&b, "// T%d is a type.\ntype T%d struct{ X, Y int }\n\n", j, j)
+			fmt.Fprintf(&b, "// M is a method.\nfunc (T%d) M(s string) string { return s }\n\n", j)
+		}
+		files[fmt.Sprintf("%sp%d/p.go", prefix, i)] = b.String()
+		if ignoredSize > 0 {
+			b.Reset()
+			fmt.Fprintf(&b, "// +build ignore\n\npackage p%d\n\n", i)
+			for b.Len() < ignoredSize {
+				fmt.Fprintf(&b, "var _ = %q\n", "data that is read but never part of the documentation")
+			}
+			files[fmt.Sprintf("%sp%d/ignored.go", prefix, i)] = b.String()
+		}
+	}
+	data, err := testhelper.ZipContents(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestExtractPackagesFromZipParallel(t *testing.T) {
+	ctx := context.Background()
+	const (
+		modulePath = "example.com/synthetic"
+		version    = "v1.0.0"
+	)
+	r := syntheticModuleZip(t, modulePath, version, 50)
+	serialPkgs, serialStates, err := extractPackagesFromZip(ctx, modulePath, version, r, nil, nil, FetchOptions{MaxExtractWorkers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serialPkgs) != 50 {
+		t.Fatalf("got %d packages, want 50", len(serialPkgs))
+	}
+	for _, workers := range []int{2, 8, 64} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			pkgs, states, err := extractPackagesFromZip(ctx, modulePath, version, r, nil, nil, FetchOptions{MaxExtractWorkers: workers})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The encoded source of a package depends on the iteration order of the
+			// maps in its AST, so it varies from run to run even when the packages
+			// are loaded serially. Compare its length only.
+			for i, p := range pkgs {
+				for j, doc := range p.docs {
+					if got, want := len(doc.Source), len(serialPkgs[i].docs[j].Source); got != want {
+						t.Errorf("%s: got %d bytes of source, want %d", p.path, got, want)
+					}
+				}
+			}
+			opts := []cmp.Option{
+				cmp.AllowUnexported(goPackage{}),
+				cmpopts.IgnoreFields(internal.Documentation{}, "Source"),
+			}
+			if diff := cmp.Diff(serialPkgs, pkgs, opts...); diff != "" {
+				t.Errorf("packages mismatch (-serial +parallel):\n%s", diff)
+			}
+			if diff := cmp.Diff(serialStates, states); diff != "" {
+				t.Errorf("package version states mismatch (-serial +parallel):\n%s", diff)
+			}
+		})
+	}
+}
+
+func BenchmarkExtractPackagesFromZip(b *testing.B) {
+	ctx := context.Background()
+	const (
+		modulePath = "example.com/synthetic"
+		version    = "v1.0.0"
+	)
+	r := syntheticModuleZip(b, modulePath, version, 500)
+	for _, test := range []struct {
+		name string
+		opts FetchOptions
+	}{
+		{"serial", FetchOptions{MaxExtractWorkers: 1}},
+		{"parallel", FetchOptions{}},
+		{"parallel skip documentation HTML", FetchOptions{SkipDocumentationHTML: true}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := extractPackagesFromZip(ctx, modulePath, version, r, nil, nil, test.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractPackagesFromZipIsolatesFailures(t *testing.T) {
+	ctx := context.Background()
+	const (
+		modulePath = "example.com/isolated"
+		version    = "v1.0.0"
+	)
+	prefix := modulePath + "@" + version + "/"
+	data, err := testhelper.ZipContents(map[string]string{
+		prefix + "go.mod":       "module " + modulePath,
+		prefix + "LICENSE":      testhelper.MITLicense,
+		prefix + "good/good.go": "// Package good is fine.\npackage good\n\n// F is a function.\nfunc F() {}\n",
+		prefix + "broken/b.go":  "package broken\n\nfunc F( {\n",
+		prefix + "panics/p.go":  "// Package panics makes loading panic.\npackage panics\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(f func(context.Context, []*zip.File, string, *source.Info, *godoc.ModuleInfo, FetchOptions) (*goPackage, error)) {
+		loadPackageFunc = f
+	}(loadPackageFunc)
+	loadPackageFunc = func(ctx context.Context, zipGoFiles []*zip.File, innerPath string, sourceInfo *source.Info, modInfo *godoc.ModuleInfo, opts FetchOptions) (*goPackage, error) {
+		if innerPath == "panics" {
+			panic("boom")
+		}
+		return loadPackage(ctx, zipGoFiles, innerPath, sourceInfo, modInfo, opts)
+	}
+
+	pkgs, states, err := extractPackagesFromZip(ctx, modulePath, version, r, nil, nil, FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotPaths []string
+	for _, p := range pkgs {
+		gotPaths = append(gotPaths, p.path)
+	}
+	if diff := cmp.Diff([]string{modulePath + "/good"}, gotPaths); diff != "" {
+		t.Errorf("packages mismatch (-want +got):\n%s", diff)
+	}
+	gotStatus := map[string]int{}
+	for _, s := range states {
+		gotStatus[s.PackagePath] = s.Status
+	}
+	if got := gotStatus[modulePath+"/good"]; got != 200 {
+		t.Errorf("good: got status %d, want 200", got)
+	}
+	if got := gotStatus[modulePath+"/broken"]; got < 600 {
+		t.Errorf("broken: got status %d, want a package error status", got)
+	}
+	if got, want := gotStatus[modulePath+"/panics"], derrors.ToStatus(derrors.PackageInternalError); got != want {
+		t.Errorf("panics: got status %d, want %d", got, want)
+	}
+}
+
+func TestExtractPackagesFromZipStopsLoadingOnError(t *testing.T) {
+	ctx := context.Background()
+	const (
+		modulePath = "example.com/synthetic"
+		version    = "v1.0.0"
+	)
+	r := syntheticModuleZip(t, modulePath, version, 20)
+
+	defer func(f func(context.Context, []*zip.File, string, *source.Info, *godoc.ModuleInfo, FetchOptions) (*goPackage, error)) {
+		loadPackageFunc = f
+	}(loadPackageFunc)
+	var calls int32
+	loadPackageFunc = func(ctx context.Context, zipGoFiles []*zip.File, innerPath string, sourceInfo *source.Info, modInfo *godoc.ModuleInfo, opts FetchOptions) (*goPackage, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, errors.New("unexpected")
+		}
+		// Any other load runs until it is canceled.
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	if _, _, err := extractPackagesFromZip(ctx, modulePath, version, r, nil, nil, FetchOptions{MaxExtractWorkers: 1}); err == nil {
+		t.Fatal("got nil error, want one")
+	}
+	// The first load fails, and at most one more was started before the
+	// error was seen. No load may start after extractPackagesFromZip returns.
+	got := atomic.LoadInt32(&calls)
+	if got > 2 {
+		t.Errorf("got %d loads, want at most 2", got)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if after := atomic.LoadInt32(&calls); after != got {
+		t.Errorf("got %d loads after extractPackagesFromZip returned, want %d", after, got)
+	}
+}
+
+func TestExtractPackagesFromZipCanceled(t *testing.T) {
+	const (
+		modulePath = "example.com/synthetic"
+		version    = "v1.0.0"
+	)
+	r := syntheticModuleZip(t, modulePath, version, 20)
+
+	defer func(f func(context.Context, []*zip.File, string, *source.Info, *godoc.ModuleInfo, FetchOptions) (*goPackage, error)) {
+		loadPackageFunc = f
+	}(loadPackageFunc)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int32
+	loadPackageFunc = func(ctx context.Context, zipGoFiles []*zip.File, innerPath string, sourceInfo *source.Info, modInfo *godoc.ModuleInfo, opts FetchOptions) (*goPackage, error) {
+		if atomic.AddInt32(&calls, 1) == 3 {
+			// Cancel the fetch partway through, and ignore the cancellation
+			// in this load, as a load that is nearly done would.
+			cancel()
+		}
+		return loadPackage(ctx, zipGoFiles, innerPath, sourceInfo, modInfo, opts)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := extractPackagesFromZip(ctx, modulePath, version, r, nil, nil, FetchOptions{MaxExtractWorkers: 1})
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("extractPackagesFromZip did not return after its context was canceled")
+	}
+	if got := atomic.LoadInt32(&calls); got >= 20 {
+		t.Errorf("got %d loads, want fewer than 20", got)
+	}
+}
+
+// BenchmarkExtractPackagesFromZipMemory reports the most live heap that
+// extractPackagesFromZip uses beyond its output, for modules of increasing
+// size whose files are mostly excluded by build constraints, and so are read
+// but not part of the output.
+//
+// The "streaming" runs are extractPackagesFromZip as it is: each directory's
+// files are read, rendered and dropped in turn, so the heap beyond the output
+// (transient-heap-B) depends on the largest packages, not on the size of the
+// module. The "whole module" runs read every Go file of the module before
+// extracting it and hold the contents until it returns, as processing the
+// module all at once would. Their transient heap grows with the size of the
+// module's files (module-files-B).
+//
+// The live heap is sampled after each package is loaded. Packages are loaded
+// one at a time, so that the samples do not depend on scheduling.
+func BenchmarkExtractPackagesFromZipMemory(b *testing.B) {
+	ctx := context.Background()
+	const (
+		modulePath  = "example.com/synthetic"
+		version     = "v1.0.0"
+		ignoredSize = 100 * 1024
+	)
+	defer func(f func(context.Context, []*zip.File, string, *source.Info, *godoc.ModuleInfo, FetchOptions) (*goPackage, error)) {
+		loadPackageFunc = f
+	}(loadPackageFunc)
+
+	liveHeap := func() uint64 {
+		runtime.GC()
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return ms.HeapAlloc
+	}
+	var (
+		base, peak uint64
+		output     []*goPackage
+	)
+	loadPackageFunc = func(ctx context.Context, zipGoFiles []*zip.File, innerPath string, sourceInfo *source.Info, modInfo *godoc.ModuleInfo, opts FetchOptions) (*goPackage, error) {
+		pkg, err := loadPackage(ctx, zipGoFiles, innerPath, sourceInfo, modInfo, opts)
+		// Keep the packages loaded so far live, as extractPackagesFromZip does,
+		// so that they count as output in every run.
+		output = append(output, pkg)
+		if h := liveHeap(); h > peak {
+			peak = h
+		}
+		return pkg, err
+	}
+	for _, n := range []int{50, 200} {
+		r := syntheticModuleZipWithIgnored(b, modulePath, version, n, ignoredSize)
+		var moduleFiles uint64
+		for _, f := range r.File {
+			if strings.HasSuffix(f.Name, ".go") {
+				moduleFiles += f.UncompressedSize64
+			}
+		}
+		for _, whole := range []bool{false, true} {
+			name := "streaming"
+			if whole {
+				name = "whole module"
+			}
+			b.Run(fmt.Sprintf("%s/packages=%d", name, n), func(b *testing.B) {
+				var maxTransient, maxRetained uint64
+				for i := 0; i < b.N; i++ {
+					output = nil
+					base = liveHeap()
+					peak = base
+					var contents [][]byte
+					if whole {
+						for _, f := range r.File {
+							if strings.HasSuffix(f.Name, ".go") {
+								c, err := readZipFile(f, MaxFileSize)
+								if err != nil {
+									b.Fatal(err)
+								}
+								contents = append(contents, c)
+							}
+						}
+					}
+					pkgs, _, err := extractPackagesFromZip(ctx, modulePath, version, r, nil, nil, FetchOptions{MaxExtractWorkers: 1})
+					if err != nil {
+						b.Fatal(err)
+					}
+					runtime.KeepAlive(contents)
+					contents = nil
+					retained := liveHeap() - base
+					if retained > maxRetained {
+						maxRetained = retained
+					}
+					if peak-base > retained && peak-base-retained > maxTransient {
+						maxTransient = peak - base - retained
+					}
+					runtime.KeepAlive(pkgs)
+				}
+				b.ReportMetric(float64(maxRetained), "retained-heap-B")
+				b.ReportMetric(float64(maxTransient), "transient-heap-B")
+				b.ReportMetric(float64(moduleFiles), "module-files-B")
+			})
+		}
+	}
+}
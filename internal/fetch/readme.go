@@ -16,8 +16,8 @@ import (
 )
 
 // extractReadmesFromZip returns the file path and contents of all files from r
-// that are README files.
-func extractReadmesFromZip(modulePath, resolvedVersion string, r *zip.Reader) (_ []*internal.Readme, err error) {
+// that are README files. It fails if a README is larger than maxFileSize.
+func extractReadmesFromZip(modulePath, resolvedVersion string, r *zip.Reader, maxFileSize uint64) (_ []*internal.Readme, err error) {
 	defer derrors.Wrap(&err, "extractReadmesFromZip(ctx, %q, %q, r)", modulePath, resolvedVersion)
 
 	// The key is the README directory. Since we only store one README file per
@@ -25,10 +25,10 @@ func extractReadmesFromZip(modulePath, resolvedVersion string, r *zip.Reader) (_
 	readmes := map[string]*internal.Readme{}
 	for _, zipFile := range r.File {
 		if isReadme(zipFile.Name) {
-			if zipFile.UncompressedSize64 > MaxFileSize {
-				return nil, fmt.Errorf("file size %d exceeds max limit %d", zipFile.UncompressedSize64, MaxFileSize)
+			if zipFile.UncompressedSize64 > maxFileSize {
+				return nil, fmt.Errorf("file size %d exceeds max limit %d", zipFile.UncompressedSize64, maxFileSize)
 			}
-			c, err := readZipFile(zipFile, MaxFileSize)
+			c, err := readZipFile(zipFile, int64(maxFileSize))
 			if err != nil {
 				return nil, err
 			}
@@ -36,10 +36,10 @@ func extractReadmesFromZip(modulePath, resolvedVersion string, r *zip.Reader) (_
 			f := strings.TrimPrefix(zipFile.Name, moduleVersionDir(modulePath, resolvedVersion)+"/")
 			key := path.Dir(f)
 			if r, ok := readmes[key]; ok {
-				// Prefer READMEs written in markdown, since we style these on
-				// the frontend.
-				ext := path.Ext(r.Filepath)
-				if ext == ".md" || ext == ".markdown" {
+				// Prefer READMEs written in markdown, and then those written
+				// in other markup languages, since we style these on the
+				// frontend.
+				if p := readmePriority(r.Filepath); p > 0 && p >= readmePriority(f) {
 					continue
 				}
 			}
@@ -57,6 +57,20 @@ func extractReadmesFromZip(modulePath, resolvedVersion string, r *zip.Reader) (_
 	return rs, nil
 }
 
+// readmePriority returns the priority of the README file when there is more
+// than one in a directory. Markdown is preferred over AsciiDoc and
+// reStructuredText, which are preferred over all other formats.
+func readmePriority(file string) int {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".md", ".markdown":
+		return 2
+	case ".adoc", ".asciidoc", ".rst":
+		return 1
+	default:
+		return 0
+	}
+}
+
 var excludedReadmeExts = map[string]bool{".go": true, ".vendor": true}
 
 // isReadme reports whether file is README or if the base name of file, with or
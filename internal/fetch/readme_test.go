@@ -30,7 +30,7 @@ func TestExtractReadmesFromZip(t *testing.T) {
 
 	for _, test := range []struct {
 		name, modulePath, version string
-		files                     map[string]string
+		mod                       *proxy.Module // if nil, the standard library at version
 		want                      []*internal.Readme
 	}{
 		{
@@ -45,12 +45,13 @@ func TestExtractReadmesFromZip(t *testing.T) {
 			},
 		},
 		{
-			name:       "prefer README.md",
-			modulePath: "github.com/my/module",
-			version:    "v1.0.0",
-			files: map[string]string{
-				"foo/README":    "README",
-				"foo/README.md": "README",
+			name: "prefer README.md",
+			mod: &proxy.Module{
+				ModulePath: "github.com/my/module",
+				Files: map[string]string{
+					"foo/README":    "README",
+					"foo/README.md": "README",
+				},
 			},
 			want: []*internal.Readme{
 				{
@@ -60,12 +61,13 @@ func TestExtractReadmesFromZip(t *testing.T) {
 			},
 		},
 		{
-			name:       "prefer readme.markdown",
-			modulePath: "github.com/my/module",
-			version:    "v1.0.0",
-			files: map[string]string{
-				"foo/README.markdown": "README",
-				"foo/readme.rst":      "README",
+			name: "prefer readme.markdown",
+			mod: &proxy.Module{
+				ModulePath: "github.com/my/module",
+				Files: map[string]string{
+					"foo/README.markdown": "README",
+					"foo/readme.rst":      "README",
+				},
 			},
 			want: []*internal.Readme{
 				{
@@ -75,10 +77,39 @@ func TestExtractReadmesFromZip(t *testing.T) {
 			},
 		},
 		{
-			name:       "no readme",
-			modulePath: "emp.ty/module",
-			version:    "v1.0.0",
-			files:      map[string]string{},
+			name: "asciidoc and restructuredtext readmes",
+			mod: &proxy.Module{
+				ModulePath: "github.com/my/module",
+				Files: map[string]string{
+					"README.adoc":     "= Module",
+					"README":          "Module",
+					"foo/README.rst":  "Foo\n===",
+					"foo/README.txt":  "Foo",
+					"bar/README.adoc": "= Bar",
+					"bar/README.md":   "# Bar",
+				},
+			},
+			want: []*internal.Readme{
+				{
+					Filepath: "README.adoc",
+					Contents: "= Module",
+				},
+				{
+					Filepath: "bar/README.md",
+					Contents: "# Bar",
+				},
+				{
+					Filepath: "foo/README.rst",
+					Contents: "Foo\n===",
+				},
+			},
+		},
+		{
+			name: "no readme",
+			mod: &proxy.Module{
+				ModulePath: "emp.ty/module",
+				Files:      map[string]string{},
+			},
 		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
@@ -86,22 +117,23 @@ func TestExtractReadmesFromZip(t *testing.T) {
 				reader *zip.Reader
 				err    error
 			)
-			if test.modulePath == stdlib.ModulePath {
-				reader, _, _, err = stdlib.Zip(test.version)
+			modulePath, version := test.modulePath, test.version
+			if test.mod == nil {
+				reader, _, _, err = stdlib.Zip(version)
 				if err != nil {
 					t.Fatal(err)
 				}
 			} else {
-				proxyClient, teardownProxy := proxy.SetupTestClient(t, []*proxy.Module{
-					{ModulePath: test.modulePath, Files: test.files}})
+				proxyClient, teardownProxy := proxy.SetupTestClient(t, []*proxy.Module{test.mod})
 				defer teardownProxy()
-				reader, err = proxyClient.Zip(ctx, test.modulePath, "v1.0.0")
+				modulePath, version = test.mod.ModulePath, "v1.0.0"
+				reader, err = proxyClient.Zip(ctx, modulePath, version)
 				if err != nil {
 					t.Fatal(err)
 				}
 			}
 
-			got, err := extractReadmesFromZip(test.modulePath, test.version, reader)
+			got, err := extractReadmesFromZip(modulePath, version, reader, MaxFileSize)
 			if err != nil {
 				t.Fatal(err)
 			}
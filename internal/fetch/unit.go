@@ -17,7 +17,8 @@ import (
 func moduleUnits(modulePath, version string,
 	pkgs []*goPackage,
 	readmes []*internal.Readme,
-	d *licenses.Detector) []*internal.Unit {
+	d *licenses.Detector,
+	opts FetchOptions) []*internal.Unit {
 	pkgLookup := map[string]*goPackage{}
 	for _, pkg := range pkgs {
 		pkgLookup[pkg.path] = pkg
@@ -64,13 +65,38 @@ func moduleUnits(modulePath, version string,
 		if pkg, ok := pkgLookup[dirPath]; ok {
 			dir.Name = pkg.name
 			dir.Imports = pkg.imports
+			dir.ImportedSymbols = pkg.importedSymbols
 			dir.Documentation = pkg.docs
+			dir.SupportedBuildContexts = pkg.supportedBuildContexts
+			dir.UsesCgo = pkg.usesCgo
+			dir.Files = pkg.files
+			dir.IsImportable = internal.IsImportable(dirPath, pkg.name)
+			if pkg.name != "main" && hasDocButNoAPI(pkg.docs) {
+				dir.Label = opts.noExportedAPILabel()
+				dir.ExcludeFromSearch = opts.ExcludeNoExportedAPIFromSearch
+			}
 		}
 		units = append(units, dir)
 	}
 	return units
 }
 
+// hasDocButNoAPI reports whether a package with the given documentation has a
+// package comment but no exported symbols in any build context. Commands are
+// not expected to export anything, so callers should not use it for them.
+func hasDocButNoAPI(docs []*internal.Documentation) bool {
+	hasDoc := false
+	for _, doc := range docs {
+		if len(doc.API) > 0 {
+			return false
+		}
+		if doc.Synopsis != "" {
+			hasDoc = true
+		}
+	}
+	return hasDoc
+}
+
 // unitPaths returns the paths for all the units in a module.
 func unitPaths(modulePath string, packages []*goPackage) []string {
 	shouldContinue := func(p string) bool {
@@ -0,0 +1,101 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// importedSymbols returns the exported symbols of imported packages that the
+// non-test files refer to, as "<import path>.<symbol>", sorted. Only
+// references qualified by the name of an imported package, like
+// context.WithTimeout, are found; methods and fields cannot be attributed to
+// a package without type-checking.
+//
+// At most maxImportedSymbolsPerPackage symbols are returned.
+func importedSymbols(files map[string]*ast.File) []string {
+	set := map[string]bool{}
+	for name, f := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		// Map the names by which the file refers to imported packages to
+		// their import paths.
+		names := map[string]string{}
+		for _, spec := range f.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			var n string
+			if spec.Name != nil {
+				n = spec.Name.Name
+			} else {
+				n = assumedPackageName(importPath)
+			}
+			// C is not a package; see usesCgo.
+			if n == "_" || n == "." || n == "" || importPath == "C" {
+				continue
+			}
+			names[n] = importPath
+		}
+		if len(names) == 0 {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			id, ok := sel.X.(*ast.Ident)
+			// An identifier that the parser resolved to a declaration in the
+			// file is not a package name, even if it has the same name as one.
+			if !ok || id.Obj != nil || !ast.IsExported(sel.Sel.Name) {
+				return true
+			}
+			if importPath, ok := names[id.Name]; ok {
+				set[importPath+"."+sel.Sel.Name] = true
+			}
+			return true
+		})
+	}
+	var syms []string
+	for s := range set {
+		syms = append(syms, s)
+	}
+	sort.Strings(syms)
+	if len(syms) > maxImportedSymbolsPerPackage {
+		syms = syms[:maxImportedSymbolsPerPackage]
+	}
+	return syms
+}
+
+// assumedPackageName returns the name that a package with the given import
+// path probably has: the last element of the path, skipping a major version
+// suffix, without a "go-" prefix, and up to the first character that cannot
+// appear in an identifier. For example, it is "yaml" for gopkg.in/yaml.v2 and
+// "redis" for github.com/go-redis/redis/v8.
+func assumedPackageName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil {
+			if dir := path.Dir(importPath); dir != "." {
+				base = path.Base(dir)
+			}
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
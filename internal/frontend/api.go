@@ -0,0 +1,492 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/godoc"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/symbol"
+	"golang.org/x/pkgsite/internal/version"
+)
+
+// installAPI registers the handlers of the JSON API, which are served under
+// /api/.
+func (s *Server) installAPI(handle func(string, http.Handler), redisClient *redis.Client) {
+	if redisClient != nil {
+		s.apiCache = cache.New(redisClient)
+	}
+	handle("/api/unit/", s.apiHandler(s.serveAPIUnit))
+	handle("/api/symbol-history/", s.apiHandler(s.serveAPISymbolHistory))
+	handle("/api/imported-by/", s.apiHandler(s.serveAPIImportedBy))
+	handle("/api/symbol-users/", s.apiHandler(s.serveAPISymbolUsers))
+	handle("/api/versions/", s.apiHandler(s.serveAPIVersions))
+	handle("/api/doc/", s.apiHandler(s.serveAPIDoc))
+}
+
+// apiHandler is like errorHandler, but for handlers of the JSON API. If the
+// API is disabled, it responds with 404 Not Found, so that the API is
+// invisible.
+func (s *Server) apiHandler(f func(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error) http.Handler {
+	h := s.errorHandler(f)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.serveAPI {
+			s.serveError(w, r, &serverError{status: http.StatusNotFound})
+			return
+		}
+		h(w, r)
+	})
+}
+
+// apiUnit is the JSON representation of a unit served by /api/unit.
+type apiUnit struct {
+	Path              string
+	Name              string `json:",omitempty"`
+	ModulePath        string
+	Version           string
+	CommitTime        time.Time
+	IsRedistributable bool
+	Licenses          []string
+	// License is the SPDX license expression for the license files that
+	// apply to the unit. It is empty if there are none.
+	License string `json:",omitempty"`
+}
+
+// serveAPIUnit serves information about a unit as JSON. It expects paths of
+// the form "/api/unit/<path>[@<version>]".
+func (s *Server) serveAPIUnit(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return &serverError{status: http.StatusMethodNotAllowed}
+	}
+	ctx := r.Context()
+	urlInfo, err := extractURLPathInfo(strings.TrimPrefix(r.URL.Path, "/api/unit"))
+	if err != nil {
+		return &serverError{status: http.StatusBadRequest, err: err}
+	}
+	if !isSupportedVersion(urlInfo.fullPath, urlInfo.requestedVersion) {
+		return &serverError{status: http.StatusBadRequest}
+	}
+	if err := checkExcluded(ctx, ds, urlInfo.fullPath); err != nil {
+		return err
+	}
+	um, err := ds.GetUnitMeta(ctx, urlInfo.fullPath, urlInfo.modulePath, urlInfo.requestedVersion)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{status: http.StatusNotFound, err: err}
+		}
+		return err
+	}
+	u := apiUnit{
+		Path:              um.Path,
+		Name:              um.Name,
+		ModulePath:        um.ModulePath,
+		Version:           um.Version,
+		CommitTime:        um.CommitTime,
+		IsRedistributable: um.IsRedistributable,
+		Licenses:          []string{},
+	}
+	for _, l := range um.Licenses {
+		u.Licenses = append(u.Licenses, l.Types...)
+	}
+	if len(um.Licenses) > 0 {
+		u.License = licenses.JoinSPDXExpressions(um.Licenses)
+	}
+	return writeJSON(w, u)
+}
+
+// apiSymbolHistory is the JSON representation of the history of a symbol,
+// served by /api/symbol-history.
+type apiSymbolHistory struct {
+	Path       string
+	ModulePath string
+	Symbol     string
+	// Introduced is the version where the symbol first appeared.
+	Introduced string
+	// Removed is the version where the symbol was last removed, if it is not
+	// in the latest version of the package.
+	Removed string `json:",omitempty"`
+	// Changes lists all the versions where the symbol was added or removed.
+	Changes []symbol.Change
+}
+
+// symbolHistoryTTL is how long the symbol changes of a package are cached.
+const symbolHistoryTTL = defaultTTL
+
+// serveAPISymbolHistory serves the versions of a package in which a symbol was
+// added or removed as JSON. It expects paths of the form
+// "/api/symbol-history/<path>?name=<symbol>". The versions are those of the
+// module that contains the latest version of the package.
+func (s *Server) serveAPISymbolHistory(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return &serverError{status: http.StatusMethodNotAllowed}
+	}
+	ctx := r.Context()
+	name := r.FormValue("name")
+	if name == "" {
+		return &serverError{status: http.StatusBadRequest, err: errors.New("missing name")}
+	}
+	urlInfo, err := extractURLPathInfo(strings.TrimPrefix(r.URL.Path, "/api/symbol-history"))
+	if err != nil {
+		return &serverError{status: http.StatusBadRequest, err: err}
+	}
+	if err := checkExcluded(ctx, ds, urlInfo.fullPath); err != nil {
+		return err
+	}
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return proxydatasourceNotSupportedErr()
+	}
+	um, err := ds.GetUnitMeta(ctx, urlInfo.fullPath, urlInfo.modulePath, internal.LatestVersion)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{status: http.StatusNotFound, err: err}
+		}
+		return err
+	}
+	changes, err := s.symbolChanges(ctx, db, um.Path, um.ModulePath, um.Version)
+	if err != nil {
+		return err
+	}
+	h := apiSymbolHistory{
+		Path:       um.Path,
+		ModulePath: um.ModulePath,
+		Symbol:     name,
+		Changes:    changes[name],
+	}
+	if len(h.Changes) == 0 {
+		return &serverError{status: http.StatusNotFound}
+	}
+	h.Introduced = h.Changes[0].Version
+	if last := h.Changes[len(h.Changes)-1]; last.Removed {
+		h.Removed = last.Version
+	}
+	return writeJSON(w, h)
+}
+
+// symbolChanges returns the changes to the API of the package at fullPath in
+// the module, by symbol name. Computing them requires the symbols of every
+// version of the package, so they are cached if possible. The cache key
+// includes resolvedVersion, the latest version of the module, so that a new
+// version invalidates it.
+func (s *Server) symbolChanges(ctx context.Context, db *postgres.DB, fullPath, modulePath, resolvedVersion string) (_ map[string][]symbol.Change, err error) {
+	defer derrors.Wrap(&err, "symbolChanges(ctx, db, %q, %q, %q)", fullPath, modulePath, resolvedVersion)
+
+	key := "symbol-history/" + modulePath + "@" + resolvedVersion + "/" + fullPath
+	if s.apiCache != nil {
+		data, err := s.apiCache.Get(ctx, key)
+		if err != nil {
+			log.Warningf(ctx, "symbol history cache: %v", err)
+		} else if data != nil {
+			var changes map[string][]symbol.Change
+			if err := json.Unmarshal(data, &changes); err == nil {
+				return changes, nil
+			}
+		}
+	}
+
+	mis, err := db.GetVersionsForPath(ctx, fullPath)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, mi := range mis {
+		if mi.ModulePath == modulePath {
+			versions = append(versions, mi.Version)
+		}
+	}
+	versionToNameToUnitSymbol, err := db.GetPackageSymbols(ctx, fullPath, modulePath)
+	if err != nil {
+		return nil, err
+	}
+	changes := symbol.Changes(versions, versionToNameToUnitSymbol)
+
+	if s.apiCache != nil {
+		data, err := json.Marshal(changes)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.apiCache.Put(ctx, key, data, symbolHistoryTTL); err != nil {
+			log.Warningf(ctx, "symbol history cache: %v", err)
+		}
+	}
+	return changes, nil
+}
+
+// apiImportedBy is the JSON representation of the importers of a package,
+// served by /api/imported-by.
+type apiImportedBy struct {
+	Path       string
+	ModulePath string
+	ImportedBy []string
+	// Total is the number of importers, including those not in ImportedBy.
+	Total int
+	// Truncated reports whether only some of the importers are in ImportedBy.
+	Truncated bool
+}
+
+// serveAPIImportedBy serves the paths of the packages that import a package
+// as JSON. It expects paths of the form "/api/imported-by/<path>?limit=<n>".
+// At most s.apiImportedByLimit importers are served, even if limit is larger.
+func (s *Server) serveAPIImportedBy(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return &serverError{status: http.StatusMethodNotAllowed}
+	}
+	ctx := r.Context()
+	limit := s.apiImportedByLimit
+	if l := r.FormValue("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return &serverError{status: http.StatusBadRequest, err: fmt.Errorf("invalid limit %q", l)}
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+	urlInfo, err := extractURLPathInfo(strings.TrimPrefix(r.URL.Path, "/api/imported-by"))
+	if err != nil {
+		return &serverError{status: http.StatusBadRequest, err: err}
+	}
+	if err := checkExcluded(ctx, ds, urlInfo.fullPath); err != nil {
+		return err
+	}
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return proxydatasourceNotSupportedErr()
+	}
+	um, err := ds.GetUnitMeta(ctx, urlInfo.fullPath, urlInfo.modulePath, internal.LatestVersion)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{status: http.StatusNotFound, err: err}
+		}
+		return err
+	}
+	importedBy, err := db.GetImportedBy(ctx, um.Path, um.ModulePath, limit)
+	if err != nil {
+		return err
+	}
+	total, err := db.GetImportedByCount(ctx, um.Path, um.ModulePath)
+	if err != nil {
+		return err
+	}
+	if importedBy == nil {
+		importedBy = []string{}
+	}
+	return writeJSON(w, apiImportedBy{
+		Path:       um.Path,
+		ModulePath: um.ModulePath,
+		ImportedBy: importedBy,
+		Total:      total,
+		Truncated:  total > len(importedBy),
+	})
+}
+
+// apiSymbolUsers is the JSON representation of the packages that refer to a
+// symbol of another package, served by /api/symbol-users.
+type apiSymbolUsers struct {
+	Path   string
+	Symbol string
+	Users  []string
+}
+
+// serveAPISymbolUsers serves the paths of the packages that refer to an
+// exported symbol of a package as JSON. It expects paths of the form
+// "/api/symbol-users/<path>?symbol=<name>&limit=<n>". At most
+// s.apiImportedByLimit packages are served, even if limit is larger.
+func (s *Server) serveAPISymbolUsers(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return &serverError{status: http.StatusMethodNotAllowed}
+	}
+	ctx := r.Context()
+	sym := r.FormValue("symbol")
+	if !token.IsIdentifier(sym) || !token.IsExported(sym) {
+		return &serverError{status: http.StatusBadRequest, err: fmt.Errorf("invalid symbol %q", sym)}
+	}
+	limit := s.apiImportedByLimit
+	if l := r.FormValue("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return &serverError{status: http.StatusBadRequest, err: fmt.Errorf("invalid limit %q", l)}
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+	pkgPath := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/symbol-users"), "/")
+	if pkgPath == "" {
+		return &serverError{status: http.StatusBadRequest, err: errors.New("missing package path")}
+	}
+	if err := checkExcluded(ctx, ds, pkgPath); err != nil {
+		return err
+	}
+	users, err := ds.GetSymbolUsers(ctx, pkgPath, sym, limit)
+	if err != nil {
+		return err
+	}
+	if users == nil {
+		users = []string{}
+	}
+	return writeJSON(w, apiSymbolUsers{Path: pkgPath, Symbol: sym, Users: users})
+}
+
+// apiVersion is the JSON representation of a module version, served by
+// /api/versions.
+type apiVersion struct {
+	Version string
+	// Type is one of "release", "prerelease" or "pseudo".
+	Type       string
+	CommitTime time.Time
+	Retracted  bool
+	Deprecated bool
+}
+
+// apiVersions is the JSON representation of the versions of a module, served
+// by /api/versions.
+type apiVersions struct {
+	ModulePath string
+	// Versions are in descending semver order.
+	Versions []apiVersion
+}
+
+// serveAPIVersions serves the versions of a module as JSON. It expects paths
+// of the form "/api/versions/<module path>".
+func (s *Server) serveAPIVersions(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return &serverError{status: http.StatusMethodNotAllowed}
+	}
+	ctx := r.Context()
+	modulePath := strings.TrimPrefix(r.URL.Path, "/api/versions/")
+	if modulePath == "" || strings.Contains(modulePath, "@") {
+		return &serverError{status: http.StatusBadRequest, err: fmt.Errorf("invalid module path %q", modulePath)}
+	}
+	if err := checkExcluded(ctx, ds, modulePath); err != nil {
+		return err
+	}
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return proxydatasourceNotSupportedErr()
+	}
+	mis, err := db.GetModuleVersions(ctx, modulePath)
+	if err != nil {
+		return err
+	}
+	if len(mis) == 0 {
+		return &serverError{status: http.StatusNotFound}
+	}
+	vs := apiVersions{ModulePath: modulePath, Versions: []apiVersion{}}
+	for _, mi := range mis {
+		typ, err := version.ParseType(mi.Version)
+		if err != nil {
+			return err
+		}
+		vs.Versions = append(vs.Versions, apiVersion{
+			Version:    mi.Version,
+			Type:       typ.String(),
+			CommitTime: mi.CommitTime,
+			Retracted:  mi.Retracted,
+			Deprecated: mi.Deprecated,
+		})
+	}
+	return writeJSON(w, vs)
+}
+
+// apiDoc is the JSON representation of the documentation of a package,
+// served by /api/doc.
+type apiDoc struct {
+	Path       string
+	Name       string
+	ModulePath string
+	Version    string
+	// GOOS and GOARCH are the build context of the documentation. They are
+	// "all" if the documentation is the same for all build contexts.
+	GOOS, GOARCH string
+	Synopsis     string
+	// Doc is the package comment, as plain text.
+	Doc     string
+	Symbols []*godoc.SymbolText
+}
+
+// serveAPIDoc serves the documentation of a package as JSON. It expects paths
+// of the form "/api/doc/<path>[@<version>]". The GOOS and GOARCH query
+// parameters select a build context, as on the unit page.
+func (s *Server) serveAPIDoc(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return &serverError{status: http.StatusMethodNotAllowed}
+	}
+	ctx := r.Context()
+	urlInfo, err := extractURLPathInfo(strings.TrimPrefix(r.URL.Path, "/api/doc"))
+	if err != nil {
+		return &serverError{status: http.StatusBadRequest, err: err}
+	}
+	if !isSupportedVersion(urlInfo.fullPath, urlInfo.requestedVersion) {
+		return &serverError{status: http.StatusBadRequest}
+	}
+	if err := checkExcluded(ctx, ds, urlInfo.fullPath); err != nil {
+		return err
+	}
+	um, err := ds.GetUnitMeta(ctx, urlInfo.fullPath, urlInfo.modulePath, urlInfo.requestedVersion)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{status: http.StatusNotFound, err: err}
+		}
+		return err
+	}
+	if !um.IsPackage() {
+		return &serverError{status: http.StatusNotFound, err: fmt.Errorf("%s is not a package", um.Path)}
+	}
+	unit, err := ds.GetUnit(ctx, um, internal.WithMain)
+	if err != nil {
+		return err
+	}
+	bc := internal.BuildContext{GOOS: r.FormValue("GOOS"), GOARCH: r.FormValue("GOARCH")}
+	doc := internal.DocumentationForBuildContext(unit.Documentation, bc)
+	if doc == nil || len(doc.Source) == 0 {
+		// The documentation is missing, not available for the build
+		// context, or not redistributable.
+		return &serverError{status: http.StatusNotFound}
+	}
+	dt, err := renderDocText(ctx, um, doc)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, apiDoc{
+		Path:       um.Path,
+		Name:       um.Name,
+		ModulePath: um.ModulePath,
+		Version:    um.Version,
+		GOOS:       doc.GOOS,
+		GOARCH:     doc.GOARCH,
+		Synopsis:   dt.Synopsis,
+		Doc:        dt.Doc,
+		Symbols:    dt.Symbols,
+	})
+}
+
+// writeJSON writes v to w as JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("w.Write: %v", err)
+	}
+	return nil
+}
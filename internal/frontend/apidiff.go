@@ -0,0 +1,165 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/version"
+)
+
+// APIDiffPage contains the data needed to render the page comparing the
+// exported API of a package at two versions.
+type APIDiffPage struct {
+	basePage
+
+	// Path is the path of the package.
+	Path string
+
+	// OldVersion and NewVersion are the versions being compared, formatted
+	// for display, and OldURL and NewURL link to the package at them.
+	OldVersion, NewVersion string
+	OldURL, NewURL         string
+
+	// BuildContext is the build context in which the versions are compared.
+	BuildContext internal.BuildContext
+
+	// APIDiff is nil if the versions cannot be compared, because the
+	// symbols of one of them are not known in a shared build context.
+	*APIDiff
+}
+
+// APIDiff is the difference between the exported symbols of a package at two
+// versions.
+type APIDiff struct {
+	Added   []*internal.Symbol
+	Removed []*internal.Symbol
+	Changed []*SymbolChange
+}
+
+// SymbolChange is a symbol whose signature differs between two versions.
+type SymbolChange struct {
+	Name        string
+	OldSynopsis string
+	NewSynopsis string
+}
+
+// serveAPIDiff serves a page listing the exported symbols of the package um
+// that were added, removed or changed since compareVersion. It is served for
+// unit URLs with a "compare" query parameter, like
+// /example.com/m@v1.3.0/pkg?compare=v1.2.0.
+func (s *Server) serveAPIDiff(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	ds internal.DataSource, um *internal.UnitMeta, compareVersion string) (err error) {
+	defer derrors.Wrap(&err, "serveAPIDiff(%q, %q, %q)", um.Path, um.Version, compareVersion)
+
+	if !um.IsPackage() {
+		return &serverError{status: http.StatusBadRequest, err: fmt.Errorf("%s is not a package", um.Path)}
+	}
+	oldUM, err := ds.GetUnitMeta(ctx, um.Path, um.ModulePath, compareVersion)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{status: http.StatusNotFound, err: err}
+		}
+		return err
+	}
+	// Symbols are only recorded for release versions.
+	for _, v := range []string{um.Version, oldUM.Version} {
+		if vt, err := version.ParseType(v); err != nil || vt != version.TypeRelease {
+			return &serverError{
+				status: http.StatusBadRequest,
+				err:    fmt.Errorf("%s is not a release version", v),
+			}
+		}
+	}
+	newSyms, err := ds.GetSymbols(ctx, um.Path, um.ModulePath, um.Version)
+	if err != nil {
+		return err
+	}
+	oldSyms, err := ds.GetSymbols(ctx, um.Path, um.ModulePath, oldUM.Version)
+	if err != nil {
+		return err
+	}
+	page := &APIDiffPage{
+		basePage:   s.newBasePage(r, um.Path+" API changes"),
+		Path:       um.Path,
+		OldVersion: linkVersion(oldUM.Version, um.ModulePath),
+		NewVersion: linkVersion(um.Version, um.ModulePath),
+		OldURL:     constructUnitURL(um.Path, um.ModulePath, oldUM.Version),
+		NewURL:     constructUnitURL(um.Path, um.ModulePath, um.Version),
+	}
+	if bc, ok := sharedBuildContext(oldSyms, newSyms); ok {
+		page.BuildContext = bc
+		page.APIDiff = diffSymbols(oldSyms[bc], newSyms[bc])
+	}
+	s.servePage(ctx, w, "api_diff.tmpl", page)
+	return nil
+}
+
+// sharedBuildContext returns the first build context, in the order of
+// internal.SortBuildContexts, for which both old and new have symbols. It
+// returns false if there is none.
+func sharedBuildContext(old, new map[internal.BuildContext][]*internal.Symbol) (internal.BuildContext, bool) {
+	var bcs []internal.BuildContext
+	for bc := range new {
+		if _, ok := old[bc]; ok {
+			bcs = append(bcs, bc)
+		}
+	}
+	if len(bcs) == 0 {
+		return internal.BuildContext{}, false
+	}
+	internal.SortBuildContexts(bcs)
+	return bcs[0], true
+}
+
+// diffSymbols returns the symbols that were added to, removed from, or
+// changed between old and new. Symbols are matched by name, including the
+// children of types, and are changed if their synopses differ. Each list is
+// sorted by name.
+func diffSymbols(old, new []*internal.Symbol) *APIDiff {
+	oldByName := symbolsByName(old)
+	newByName := symbolsByName(new)
+	d := &APIDiff{}
+	for name, newSym := range newByName {
+		oldSym, ok := oldByName[name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, newSym)
+		case oldSym.Synopsis != newSym.Synopsis:
+			d.Changed = append(d.Changed, &SymbolChange{
+				Name:        name,
+				OldSynopsis: oldSym.Synopsis,
+				NewSynopsis: newSym.Synopsis,
+			})
+		}
+	}
+	for name, oldSym := range oldByName {
+		if _, ok := newByName[name]; !ok {
+			d.Removed = append(d.Removed, oldSym)
+		}
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Name < d.Added[j].Name })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Name < d.Removed[j].Name })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Name < d.Changed[j].Name })
+	return d
+}
+
+// symbolsByName returns the symbols in syms and their children, keyed by name.
+func symbolsByName(syms []*internal.Symbol) map[string]*internal.Symbol {
+	m := map[string]*internal.Symbol{}
+	for _, s := range syms {
+		m[s.Name] = s
+		for _, c := range s.Children {
+			m[c.Name] = c
+		}
+	}
+	return m
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+// apiDiffSymbols returns the API of a package at two versions: v1.3.0 adds
+// the function G and changes the signature of the method T.M.
+func apiDiffSymbols() (v120, v130 []*internal.Symbol) {
+	sym := func(name, parent, synopsis string, kind internal.SymbolKind, section internal.SymbolSection, children ...*internal.Symbol) *internal.Symbol {
+		return &internal.Symbol{
+			Name:       name,
+			ParentName: parent,
+			Synopsis:   synopsis,
+			Kind:       kind,
+			Section:    section,
+			GOOS:       internal.All,
+			GOARCH:     internal.All,
+			Children:   children,
+		}
+	}
+	f := sym("F", "", "func F() error", internal.SymbolKindFunction, internal.SymbolSectionFunctions)
+	g := sym("G", "", "func G(x int)", internal.SymbolKindFunction, internal.SymbolSectionFunctions)
+	v120 = []*internal.Symbol{
+		f,
+		sym("T", "", "type T struct", internal.SymbolKindType, internal.SymbolSectionTypes,
+			sym("T.M", "T", "func (T) M()", internal.SymbolKindMethod, internal.SymbolSectionTypes)),
+	}
+	v130 = []*internal.Symbol{
+		f,
+		g,
+		sym("T", "", "type T struct", internal.SymbolKindType, internal.SymbolSectionTypes,
+			sym("T.M", "T", "func (T) M(ctx context.Context)", internal.SymbolKindMethod, internal.SymbolSectionTypes)),
+	}
+	return v120, v130
+}
+
+func TestDiffSymbols(t *testing.T) {
+	v120, v130 := apiDiffSymbols()
+	type summary struct {
+		Added, Removed []string
+		Changed        []SymbolChange
+	}
+	summarize := func(d *APIDiff) summary {
+		var s summary
+		for _, sym := range d.Added {
+			s.Added = append(s.Added, sym.Name)
+		}
+		for _, sym := range d.Removed {
+			s.Removed = append(s.Removed, sym.Name)
+		}
+		for _, c := range d.Changed {
+			s.Changed = append(s.Changed, *c)
+		}
+		return s
+	}
+
+	got := summarize(diffSymbols(v120, v130))
+	want := summary{
+		Added:   []string{"G"},
+		Changed: []SymbolChange{{Name: "T.M", OldSynopsis: "func (T) M()", NewSynopsis: "func (T) M(ctx context.Context)"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("v1.2.0 to v1.3.0 mismatch (-want +got):\n%s", diff)
+	}
+
+	// Going backwards, G is removed.
+	got = summarize(diffSymbols(v130, v120))
+	want = summary{
+		Removed: []string{"G"},
+		Changed: []SymbolChange{{Name: "T.M", OldSynopsis: "func (T) M(ctx context.Context)", NewSynopsis: "func (T) M()"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("v1.3.0 to v1.2.0 mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := summarize(diffSymbols(v120, v120)); !cmp.Equal(got, summary{}) {
+		t.Errorf("same version: got %+v, want no changes", got)
+	}
+}
+
+func TestSharedBuildContext(t *testing.T) {
+	syms := func(bcs ...internal.BuildContext) map[internal.BuildContext][]*internal.Symbol {
+		m := map[internal.BuildContext][]*internal.Symbol{}
+		for _, bc := range bcs {
+			m[bc] = nil
+		}
+		return m
+	}
+	for _, test := range []struct {
+		name     string
+		old, new map[internal.BuildContext][]*internal.Symbol
+		want     internal.BuildContext
+		wantOK   bool
+	}{
+		{"all", syms(internal.BuildContextAll), syms(internal.BuildContextAll), internal.BuildContextAll, true},
+		{"first shared",
+			syms(internal.BuildContextWindows, internal.BuildContextDarwin),
+			syms(internal.BuildContextLinux, internal.BuildContextWindows, internal.BuildContextDarwin),
+			internal.BuildContextWindows, true},
+		{"none shared", syms(internal.BuildContextAll), syms(internal.BuildContextLinux), internal.BuildContext{}, false},
+		{"old missing", syms(), syms(internal.BuildContextAll), internal.BuildContext{}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := sharedBuildContext(test.old, test.new)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("got (%v, %t), want (%v, %t)", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
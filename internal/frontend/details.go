@@ -42,6 +42,15 @@ func (s *Server) serveDetails(w http.ResponseWriter, r *http.Request, ds interna
 		return
 	}
 
+	if u := buildContextSegmentRedirectURL(r.URL); u != "" {
+		http.Redirect(w, r, u, http.StatusMovedPermanently)
+		return nil
+	}
+
+	if strings.HasSuffix(r.URL.Path, feedSuffix) {
+		return s.serveModuleFeed(w, r, ds)
+	}
+
 	ctx := r.Context()
 	// If page statistics are enabled, use the "exp" query param to adjust
 	// the active experiments.
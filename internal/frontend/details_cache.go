@@ -0,0 +1,92 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/safehtml/uncheckedconversions"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// mainDetailsTTL is how long the main tab details of a unit are cached.
+// Rendering documentation is expensive and a module version never changes,
+// but the details also hold importer counts, which do. The worker deletes the
+// entries for a module version whenever it fetches it again.
+const mainDetailsTTL = 1 * time.Hour
+
+// cachedMainDetails is the form in which MainDetails are stored in the cache.
+// The safehtml values do not survive JSON encoding, so they are held
+// separately as strings.
+type cachedMainDetails struct {
+	Details       *MainDetails
+	Readme        string
+	DocBody       string
+	DocOutline    string
+	MobileOutline string
+}
+
+// mainDetailsKey returns the cache key for the main tab details of um in the
+// build context bc.
+func mainDetailsKey(um *internal.UnitMeta, bc internal.BuildContext) string {
+	return cache.MainDetailsKey(um.Path, um.ModulePath, um.Version, bc.GOOS, bc.GOARCH)
+}
+
+// getCachedMainDetails returns the main tab details of um in the build
+// context bc from the cache, or nil if they are not there.
+func (s *Server) getCachedMainDetails(ctx context.Context, um *internal.UnitMeta, bc internal.BuildContext) *MainDetails {
+	if s.detailsCache == nil {
+		return nil
+	}
+	data, err := s.detailsCache.Get(ctx, mainDetailsKey(um, bc))
+	if err != nil {
+		log.Warningf(ctx, "main details cache: %v", err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	var c cachedMainDetails
+	if err := json.Unmarshal(data, &c); err != nil || c.Details == nil {
+		log.Warningf(ctx, "main details cache: bad entry for %s@%s: %v", um.Path, um.Version, err)
+		return nil
+	}
+	d := c.Details
+	d.Readme = uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract(c.Readme)
+	d.DocBody = uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract(c.DocBody)
+	d.DocOutline = uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract(c.DocOutline)
+	d.MobileOutline = uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract(c.MobileOutline)
+	// The license anchors are safehtml.Identifiers, so compute them again
+	// rather than storing them.
+	d.Licenses = transformLicenseMetadata(um.Licenses)
+	return d
+}
+
+// putCachedMainDetails stores the main tab details of um in the build context
+// bc in the cache.
+func (s *Server) putCachedMainDetails(ctx context.Context, um *internal.UnitMeta, bc internal.BuildContext, d *MainDetails) {
+	if s.detailsCache == nil {
+		return
+	}
+	data, err := json.Marshal(cachedMainDetails{
+		Details:       d,
+		Readme:        d.Readme.String(),
+		DocBody:       d.DocBody.String(),
+		DocOutline:    d.DocOutline.String(),
+		MobileOutline: d.MobileOutline.String(),
+	})
+	if err != nil {
+		log.Warningf(ctx, "main details cache: %v", err)
+		return
+	}
+	setKey := cache.MainDetailsSetKey(um.ModulePath, um.Version)
+	if err := s.detailsCache.PutInSet(ctx, setKey, mainDetailsKey(um, bc), data, mainDetailsTTL); err != nil {
+		log.Warningf(ctx, "main details cache: %v", err)
+	}
+}
@@ -48,8 +48,9 @@ type DirectoryInfo struct {
 }
 
 // unitDirectories zips the subdirectories and nested modules together in a two
-// level tree hierarchy.
-func unitDirectories(directories []*DirectoryInfo) *Directories {
+// level tree hierarchy. Internal directories below the top level are omitted,
+// unless showInternal is true.
+func unitDirectories(directories []*DirectoryInfo, showInternal bool) *Directories {
 	if len(directories) == 0 {
 		return nil
 	}
@@ -63,7 +64,7 @@ func unitDirectories(directories []*DirectoryInfo) *Directories {
 		// Skip internal directories that are not in the top level internal
 		// directory. For example, foo/internal and foo/internal/bar should
 		// be skipped, but internal/foo should be included.
-		if prefix != "internal" && (strings.HasSuffix(d.Suffix, "/internal") ||
+		if !showInternal && prefix != "internal" && (strings.HasSuffix(d.Suffix, "/internal") ||
 			strings.Contains(d.Suffix, "/internal/")) {
 			continue
 		}
@@ -92,6 +93,8 @@ func unitDirectories(directories []*DirectoryInfo) *Directories {
 	return section
 }
 
+// getNestedModules returns the modules nested under um that are not already
+// listed in sds.
 func getNestedModules(ctx context.Context, ds internal.DataSource, um *internal.UnitMeta, sds []*DirectoryInfo) ([]*DirectoryInfo, error) {
 	nestedModules, err := ds.GetNestedModules(ctx, um.ModulePath)
 	if err != nil {
@@ -103,7 +106,10 @@ func getNestedModules(ctx context.Context, ds internal.DataSource, um *internal.
 	for _, dir := range sds {
 		excludedSuffixes[dir.Suffix] = true
 	}
-	var mods []*DirectoryInfo
+	var (
+		mods  []*DirectoryInfo
+		paths []internal.UnitModulePath
+	)
 	for _, m := range nestedModules {
 		if m.SeriesPath() == internal.SeriesPathForModule(um.ModulePath) {
 			continue
@@ -120,6 +126,21 @@ func getNestedModules(ctx context.Context, ds internal.DataSource, um *internal.
 			Suffix:   suffix,
 			IsModule: true,
 		})
+		paths = append(paths, internal.UnitModulePath{Path: m.ModulePath, ModulePath: m.ModulePath})
+	}
+	if len(paths) == 0 {
+		return mods, nil
+	}
+	// Link each nested module to its latest major version, looking them all
+	// up at once.
+	latest, err := ds.GetLatestInfoBatch(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range paths {
+		if li, ok := latest[p]; ok && li.MajorModulePath != "" {
+			mods[i].URL = constructUnitURL(li.MajorUnitPath, li.MajorModulePath, internal.LatestVersion)
+		}
 	}
 	return mods, nil
 }
@@ -121,23 +121,28 @@ func TestGetNestedModules(t *testing.T) {
 }
 
 func TestUnitDirectories(t *testing.T) {
-	subdirectories := []*DirectoryInfo{
-		{Suffix: "accessapproval"},
-		{Suffix: "accessapproval/internal"},
-		{Suffix: "accessapproval/cgi"},
-		{Suffix: "accessapproval/cookiejar"},
-		{Suffix: "accessapproval/cookiejar/internal"},
-		{Suffix: "fgci"},
-		{Suffix: "httptrace"},
-		{Suffix: "internal/bytesconv"},
-		{Suffix: "internal/json"},
-		{Suffix: "zoltan"},
+	// unitDirectories modifies its argument, so each call gets new
+	// directories.
+	directories := func() []*DirectoryInfo {
+		subdirectories := []*DirectoryInfo{
+			{Suffix: "accessapproval"},
+			{Suffix: "accessapproval/internal"},
+			{Suffix: "accessapproval/cgi"},
+			{Suffix: "accessapproval/cookiejar"},
+			{Suffix: "accessapproval/cookiejar/internal"},
+			{Suffix: "fgci"},
+			{Suffix: "httptrace"},
+			{Suffix: "internal/bytesconv"},
+			{Suffix: "internal/json"},
+			{Suffix: "zoltan"},
+		}
+		nestedModules := []*DirectoryInfo{
+			{Suffix: "httptest", IsModule: true},
+			{Suffix: "pubsub/internal", IsModule: true},
+		}
+		return append(subdirectories, nestedModules...)
 	}
-	nestedModules := []*DirectoryInfo{
-		{Suffix: "httptest", IsModule: true},
-		{Suffix: "pubsub/internal", IsModule: true},
-	}
-	got := unitDirectories(append(subdirectories, nestedModules...))
+	got := unitDirectories(directories(), false)
 	want := &Directories{
 		External: []*Directory{
 			{
@@ -177,4 +182,23 @@ func TestUnitDirectories(t *testing.T) {
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Errorf("unitDirectories mismatch (-want +got):\n%s", diff)
 	}
+
+	// With showInternal, nested internal directories are listed too.
+	got = unitDirectories(directories(), true)
+	want.External[0].Subdirectories = []*DirectoryInfo{
+		{Suffix: "internal"},
+		{Suffix: "cgi"},
+		{Suffix: "cookiejar"},
+		{Suffix: "cookiejar/internal"},
+	}
+	pubsub := &Directory{
+		Prefix: "pubsub",
+		Subdirectories: []*DirectoryInfo{
+			{Suffix: "internal", IsModule: true},
+		},
+	}
+	want.External = append(want.External[:4], pubsub, want.External[4])
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unitDirectories with showInternal mismatch (-want +got):\n%s", diff)
+	}
 }
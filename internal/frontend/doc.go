@@ -20,14 +20,17 @@ import (
 	"golang.org/x/pkgsite/internal/stdlib"
 )
 
-func renderDocParts(ctx context.Context, u *internal.Unit, docPkg *godoc.Package) (_ *dochtml.Parts, err error) {
+// renderDocParts renders the documentation of u. References to the packages
+// in nonImportable are not linked.
+func renderDocParts(ctx context.Context, u *internal.Unit, docPkg *godoc.Package, nonImportable map[string]bool) (_ *dochtml.Parts, err error) {
 	defer derrors.Wrap(&err, "renderDocParts")
 	defer middleware.ElapsedStat(ctx, "renderDocParts")()
 
 	modInfo := &godoc.ModuleInfo{
-		ModulePath:      u.ModulePath,
-		ResolvedVersion: u.Version,
-		ModulePackages:  nil, // will be provided by docPkg
+		ModulePath:            u.ModulePath,
+		ResolvedVersion:       u.Version,
+		ModulePackages:        nil, // will be provided by docPkg
+		NonImportablePackages: nonImportable,
 	}
 	var innerPath string
 	if u.ModulePath == stdlib.ModulePath {
@@ -38,6 +41,26 @@ func renderDocParts(ctx context.Context, u *internal.Unit, docPkg *godoc.Package
 	return docPkg.RenderParts(ctx, innerPath, u.SourceInfo, modInfo)
 }
 
+// renderDocText returns the documentation of the package um as plain text.
+func renderDocText(ctx context.Context, um *internal.UnitMeta, doc *internal.Documentation) (_ *godoc.DocText, err error) {
+	defer derrors.Wrap(&err, "renderDocText")
+
+	docPkg, err := godoc.DecodePackage(doc.Source)
+	if err != nil {
+		return nil, err
+	}
+	var innerPath string
+	if um.ModulePath == stdlib.ModulePath {
+		innerPath = um.Path
+	} else if um.Path != um.ModulePath {
+		innerPath = um.Path[len(um.ModulePath)+1:]
+	}
+	return docPkg.RenderText(ctx, innerPath, &godoc.ModuleInfo{
+		ModulePath:      um.ModulePath,
+		ResolvedVersion: um.Version,
+	})
+}
+
 // sourceFiles returns the .go files for a package.
 func sourceFiles(u *internal.Unit, docPkg *godoc.Package) []*File {
 	var files []*File
@@ -54,6 +77,22 @@ func sourceFiles(u *internal.Unit, docPkg *godoc.Package) []*File {
 	return files
 }
 
+// unitSourceFiles returns the source files of u for all build contexts. Units
+// fetched before their files were recorded fall back to the files of docPkg.
+func unitSourceFiles(u *internal.Unit, docPkg *godoc.Package) []*File {
+	if len(u.Files) == 0 {
+		return sourceFiles(u, docPkg)
+	}
+	var files []*File
+	for _, name := range u.Files {
+		files = append(files, &File{
+			Name: name,
+			URL:  u.SourceInfo.FileURL(path.Join(internal.Suffix(u.Path, u.ModulePath), name)),
+		})
+	}
+	return files
+}
+
 // fileSource returns the original filepath in the module zip where the given
 // filePath can be found. For std, the corresponding URL in
 // go.google.source.com/go is returned.
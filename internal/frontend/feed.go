@@ -0,0 +1,119 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/version"
+)
+
+const (
+	// feedSuffix is the suffix of the URL path of the Atom feed of a module.
+	feedSuffix = "/feed.atom"
+
+	// maxFeedEntries is the maximum number of versions in a feed.
+	maxFeedEntries = 20
+)
+
+// atomFeed is an Atom feed, as described in RFC 4287.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+// serveModuleFeed serves an Atom feed of the tagged versions of a module,
+// newest first. It expects paths of the form "/<module path>/feed.atom".
+func (s *Server) serveModuleFeed(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
+	ctx := r.Context()
+	modulePath := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), feedSuffix)
+	if modulePath == "" || strings.Contains(modulePath, "@") {
+		return &serverError{status: http.StatusBadRequest, err: fmt.Errorf("invalid module path %q", modulePath)}
+	}
+	if err := checkExcluded(ctx, ds, modulePath); err != nil {
+		return err
+	}
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return proxydatasourceNotSupportedErr()
+	}
+	mis, err := db.GetModuleVersions(ctx, modulePath)
+	if err != nil {
+		return err
+	}
+	var tagged []*internal.ModuleInfo
+	for _, mi := range mis {
+		if version.IsPseudo(mi.Version) {
+			continue
+		}
+		tagged = append(tagged, mi)
+		if len(tagged) == maxFeedEntries {
+			break
+		}
+	}
+	if len(tagged) == 0 {
+		return &serverError{status: http.StatusNotFound}
+	}
+	// The feed is cached for all hosts, so its links must not depend on the
+	// host of the request.
+	data, err := xml.MarshalIndent(moduleFeed(modulePath, s.canonicalURL, tagged), "", "  ")
+	if err != nil {
+		return fmt.Errorf("xml.MarshalIndent: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// moduleFeed returns the Atom feed for the given versions of a module. The
+// links in the feed are relative to baseURL.
+func moduleFeed(modulePath, baseURL string, mis []*internal.ModuleInfo) *atomFeed {
+	modURL := baseURL + "/" + modulePath
+	feed := &atomFeed{
+		ID:    modURL,
+		Title: modulePath + " versions",
+		Link:  atomLink{Rel: "self", Href: modURL + feedSuffix},
+	}
+	var updated time.Time
+	for _, mi := range mis {
+		if mi.CommitTime.After(updated) {
+			updated = mi.CommitTime
+		}
+		u := modURL + "@" + mi.Version
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      u,
+			Title:   mi.Version,
+			Updated: mi.CommitTime.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: u},
+		})
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+	return feed
+}
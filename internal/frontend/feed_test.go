@@ -0,0 +1,75 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestServeModuleFeed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	commitTime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, v := range []string{"v1.0.0", "v1.2.0", "v1.1.0", "v0.0.0-20210105000000-000000000000"} {
+		m := sample.Module(sample.ModulePath, v, "foo")
+		m.CommitTime = commitTime.AddDate(0, 0, i)
+		postgres.MustInsertModule(ctx, t, testDB, m)
+	}
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	urlPath := "/" + sample.ModulePath + "/feed.atom"
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", urlPath, nil)
+	r.Host = "forged.example.org"
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %q = %d, want %d", urlPath, w.Code, http.StatusOK)
+	}
+	if got, want := w.Header().Get("Content-Type"), "application/atom+xml; charset=utf-8"; got != want {
+		t.Errorf("got Content-Type %q, want %q", got, want)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	// The pseudo-version is omitted, and the versions are newest first.
+	type entry struct{ Title, Updated string }
+	var got []entry
+	for _, e := range feed.Entries {
+		got = append(got, entry{e.Title, e.Updated})
+	}
+	want := []entry{
+		{"v1.2.0", "2021-01-02T00:00:00Z"},
+		{"v1.1.0", "2021-01-03T00:00:00Z"},
+		{"v1.0.0", "2021-01-01T00:00:00Z"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if want := "https://pkg.go.dev/" + sample.ModulePath + "@v1.2.0"; feed.Entries[0].Link.Href != want {
+		t.Errorf("got link %q, want %q", feed.Entries[0].Link.Href, want)
+	}
+	if want := "https://pkg.go.dev/" + sample.ModulePath + "/feed.atom"; feed.Link.Href != want {
+		t.Errorf("got self link %q, want %q", feed.Link.Href, want)
+	}
+
+	urlPath = "/example.com/unknown/feed.atom"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET %q = %d, want %d", urlPath, w.Code, http.StatusNotFound)
+	}
+}
@@ -20,6 +20,10 @@ const (
 	pageTypeCommand   = "command"
 	pageTypeModuleStd = "std"
 	pageTypeStdlib    = "standard library"
+
+	// pageLabelUnstableV0 is the label for units at a v0 version, when
+	// enabled by ServerConfig.LabelUnstableV0.
+	pageLabelUnstableV0 = "v0 (unstable)"
 )
 
 // pageTitle determines the pageTitles for a given unit.
@@ -6,11 +6,16 @@ package frontend
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/godoc"
+	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/stdlib"
 )
@@ -30,24 +35,51 @@ type ImportsDetails struct {
 	// StdLib is an array of packages representing the package's imports
 	// that are in the Go standard library.
 	StdLib []string
+
+	// PlatformImports are the imports that appear in only some of the build
+	// contexts the package is documented for.
+	PlatformImports []*PlatformImport
+}
+
+// A PlatformImport is an import of a package that depends on the build
+// context.
+type PlatformImport struct {
+	// Path is the import path.
+	Path string
+
+	// BuildContexts are the build contexts, like "linux/amd64", whose files
+	// import Path.
+	BuildContexts []string
 }
 
 // fetchImportsDetails fetches imports for the package version specified by
 // pkgPath, modulePath and version from the database and returns a ImportsDetails.
-func fetchImportsDetails(ctx context.Context, ds internal.DataSource, pkgPath, modulePath, resolvedVersion string) (_ *ImportsDetails, err error) {
-	u, err := ds.GetUnit(ctx, &internal.UnitMeta{
+// If bc selects a build context, the imports are those of the package's files
+// for that build context. Otherwise they are the imports recorded when the
+// package was fetched.
+func (s *Server) fetchImportsDetails(ctx context.Context, ds internal.DataSource, pkgPath, modulePath, resolvedVersion string, bc internal.BuildContext) (_ *ImportsDetails, err error) {
+	um := &internal.UnitMeta{
 		Path: pkgPath,
 		ModuleInfo: internal.ModuleInfo{
 			ModulePath: modulePath,
 			Version:    resolvedVersion,
 		},
-	}, internal.WithImports)
+	}
+	u, err := ds.GetUnit(ctx, um, internal.WithImports)
 	if err != nil {
 		return nil, err
 	}
+	dis, err := s.documentationImports(ctx, ds, um)
+	if err != nil {
+		return nil, err
+	}
+	imports := u.Imports
+	if bc != (internal.BuildContext{}) {
+		imports = buildContextImports(u.Imports, dis, bc)
+	}
 
 	var externalImports, moduleImports, std []string
-	for _, p := range u.Imports {
+	for _, p := range imports {
 		if stdlib.Contains(p) {
 			std = append(std, p)
 		} else if strings.HasPrefix(p+"/", modulePath+"/") {
@@ -62,9 +94,120 @@ func fetchImportsDetails(ctx context.Context, ds internal.DataSource, pkgPath, m
 		ExternalImports: externalImports,
 		InternalImports: moduleImports,
 		StdLib:          std,
+		PlatformImports: platformImports(dis),
 	}, nil
 }
 
+// docImports are the imports of a package in the build context of one of its
+// documentations.
+type docImports struct {
+	GOOS, GOARCH string
+	// Imports are the imports of the package's files in the build context.
+	// They are unknown if the documentation has no source.
+	Imports []string
+	Unknown bool
+}
+
+// docImportsKey returns the cache key for the docImports of the unit.
+func docImportsKey(um *internal.UnitMeta) string {
+	return "doc-imports/" + um.ModulePath + "@" + um.Version + "/" + um.Path
+}
+
+// documentationImports returns the imports of the unit's package for each of
+// its documentations. Finding them requires decoding the source of every
+// documentation, so they are cached like the main details, if there is a
+// cache.
+func (s *Server) documentationImports(ctx context.Context, ds internal.DataSource, um *internal.UnitMeta) ([]*docImports, error) {
+	key := docImportsKey(um)
+	if s.detailsCache != nil {
+		data, err := s.detailsCache.Get(ctx, key)
+		if err != nil {
+			log.Warningf(ctx, "documentation imports cache: %v", err)
+		} else if data != nil {
+			var dis []*docImports
+			if err := json.Unmarshal(data, &dis); err == nil {
+				return dis, nil
+			}
+		}
+	}
+	u, err := ds.GetUnit(ctx, um, internal.WithMain)
+	if err != nil {
+		return nil, err
+	}
+	var dis []*docImports
+	for _, doc := range u.Documentation {
+		di := &docImports{GOOS: doc.GOOS, GOARCH: doc.GOARCH, Unknown: len(doc.Source) == 0}
+		if !di.Unknown {
+			docPkg, err := godoc.DecodePackage(doc.Source)
+			if err != nil {
+				return nil, err
+			}
+			di.Imports = docPkg.Imports()
+		}
+		dis = append(dis, di)
+	}
+	if s.detailsCache != nil {
+		data, err := json.Marshal(dis)
+		if err != nil {
+			return nil, err
+		}
+		setKey := cache.MainDetailsSetKey(um.ModulePath, um.Version)
+		if err := s.detailsCache.PutInSet(ctx, setKey, key, data, mainDetailsTTL); err != nil {
+			log.Warningf(ctx, "documentation imports cache: %v", err)
+		}
+	}
+	return dis, nil
+}
+
+// buildContextImports returns the imports of a package in the build context
+// bc, or the recorded imports if the package has no documentation specific to
+// bc. The documentation for bc is chosen by
+// internal.DocumentationForBuildContext.
+func buildContextImports(recorded []string, dis []*docImports, bc internal.BuildContext) []string {
+	docs := make([]*internal.Documentation, len(dis))
+	byDoc := make(map[*internal.Documentation]*docImports, len(dis))
+	for i, di := range dis {
+		docs[i] = &internal.Documentation{GOOS: di.GOOS, GOARCH: di.GOARCH}
+		byDoc[docs[i]] = di
+	}
+	d := internal.DocumentationForBuildContext(docs, bc)
+	if d == nil {
+		return recorded
+	}
+	di := byDoc[d]
+	if (di.GOOS == internal.All && di.GOARCH == internal.All) || di.Unknown {
+		return recorded
+	}
+	return di.Imports
+}
+
+// platformImports returns the imports that appear in some but not all of the
+// build contexts of dis, sorted by path. It returns nil if there is only one
+// build context, or if the imports of some build context are unknown.
+func platformImports(dis []*docImports) []*PlatformImport {
+	if len(dis) < 2 {
+		return nil
+	}
+	byPath := map[string][]string{}
+	for _, di := range dis {
+		if di.Unknown {
+			return nil
+		}
+		bc := internal.BuildContext{GOOS: di.GOOS, GOARCH: di.GOARCH}
+		for _, p := range di.Imports {
+			byPath[p] = append(byPath[p], bc.String())
+		}
+	}
+	var pis []*PlatformImport
+	for p, bcs := range byPath {
+		if len(bcs) < len(dis) {
+			pis = append(pis, &PlatformImport{Path: p, BuildContexts: bcs})
+		}
+	}
+	sort.Slice(pis, func(i, j int) bool { return pis[i].Path < pis[j].Path })
+	return pis
+}
+
 // ImportedByDetails contains information for the collection of packages that
 // import a given package.
 type ImportedByDetails struct {
@@ -82,41 +225,68 @@ type ImportedByDetails struct {
 
 	// Total is the total number of importers.
 	Total int
+
+	// Truncated reports whether only some of the importers are in ImportedBy.
+	Truncated bool
 }
 
-var (
-	// tabImportedByLimit is the maximum number of importers displayed on the imported
-	// by page.
-	tabImportedByLimit = 20001
+const (
+	// defaultImportedByLimit is the maximum number of importers displayed on
+	// the imported by page, unless the server is configured otherwise.
+	defaultImportedByLimit = 20000
+
+	// defaultAPIImportedByLimit is the maximum number of importers served by
+	// /api/imported-by, unless the server is configured otherwise.
+	defaultAPIImportedByLimit = 1000
 )
 
-// fetchImportedByDetails fetches importers for the package version specified by
-// path and version from the database and returns a ImportedByDetails.
-func fetchImportedByDetails(ctx context.Context, ds internal.DataSource, pkgPath, modulePath string) (*ImportedByDetails, error) {
+// fetchImportedByDetails fetches at most limit importers for the package
+// version specified by path and version from the database and returns a
+// ImportedByDetails.
+//
+// If showInternal is true and the package is internal, the importers are the
+// packages in its own module, since those are the only ones that can import
+// it. Otherwise they are the packages in other modules.
+func fetchImportedByDetails(ctx context.Context, ds internal.DataSource, pkgPath, modulePath string, limit int, showInternal bool) (*ImportedByDetails, error) {
 	db, ok := ds.(*postgres.DB)
 	if !ok {
 		// The proxydatasource does not support the imported by page.
 		return nil, proxydatasourceNotSupportedErr()
 	}
 
-	importedBy, err := db.GetImportedBy(ctx, pkgPath, modulePath, tabImportedByLimit)
-	if err != nil {
-		return nil, err
+	var (
+		importedBy    []string
+		numImportedBy int
+		err           error
+	)
+	if showInternal && internal.IsInternalPath(pkgPath) {
+		importedBy, err = db.GetImportedByWithinModule(ctx, pkgPath, modulePath, limit)
+		if err != nil {
+			return nil, err
+		}
+		numImportedBy, err = db.GetImportedByCountWithinModule(ctx, pkgPath, modulePath)
+	} else {
+		importedBy, err = db.GetImportedBy(ctx, pkgPath, modulePath, limit)
+		if err != nil {
+			return nil, err
+		}
+		numImportedBy, err = ds.GetImportedByCount(ctx, pkgPath, modulePath)
 	}
-	numImportedBy, err := db.GetImportedByCount(ctx, pkgPath, modulePath)
 	if err != nil {
 		return nil, err
 	}
 	sections := Sections(importedBy, nextPrefixAccount)
 
 	display := strconv.Itoa(numImportedBy)
-	if numImportedBy >= tabImportedByLimit {
-		display += fmt.Sprintf(" (displaying %d packages)", tabImportedByLimit-1)
+	truncated := numImportedBy > len(importedBy)
+	if truncated {
+		display += fmt.Sprintf(" (displaying %d packages)", len(importedBy))
 	}
 	return &ImportedByDetails{
 		ModulePath:           modulePath,
 		ImportedBy:           sections,
 		NumImportedByDisplay: display,
 		Total:                numImportedBy,
+		Truncated:            truncated,
 	}, nil
 }
@@ -6,13 +6,18 @@ package frontend
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
@@ -58,7 +63,7 @@ func TestFetchImportsDetails(t *testing.T) {
 
 			postgres.MustInsertModule(ctx, t, testDB, module)
 
-			got, err := fetchImportsDetails(ctx, testDB, pkg.Path, pkg.ModulePath, pkg.Version)
+			got, err := (&Server{}).fetchImportsDetails(ctx, testDB, pkg.Path, pkg.ModulePath, pkg.Version, internal.BuildContext{})
 			if err != nil {
 				t.Fatalf("fetchImportsDetails(ctx, db, %q, %q) = %v err = %v, want %v",
 					module.Units[1].Path, module.Version, got, err, test.wantDetails)
@@ -72,6 +77,198 @@ func TestFetchImportsDetails(t *testing.T) {
 	}
 }
 
+func TestFetchImportsDetails_BuildContext(t *testing.T) {
+	defer postgres.ResetTestDB(testDB, t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	module := sample.Module(sample.ModulePath, sample.VersionString, sample.Suffix)
+	pkg := module.Units[1]
+	pkg.Imports = []string{"os", "syscall"}
+	pkg.Documentation = []*internal.Documentation{
+		sample.Documentation("linux", "amd64", `package foo; import ("os"; "syscall")`),
+		sample.Documentation("windows", "amd64", `package foo; import ("os"; "golang.org/x/sys/windows")`),
+	}
+	postgres.MustInsertModule(ctx, t, testDB, module)
+
+	// The platform-specific imports are the same for every build context.
+	platformImports := []*PlatformImport{
+		{Path: "golang.org/x/sys/windows", BuildContexts: []string{"windows/amd64"}},
+		{Path: "syscall", BuildContexts: []string{"linux/amd64"}},
+	}
+	for _, test := range []struct {
+		bc   internal.BuildContext
+		want *ImportsDetails
+	}{
+		{
+			internal.BuildContext{},
+			&ImportsDetails{StdLib: []string{"os", "syscall"}, PlatformImports: platformImports},
+		},
+		{
+			internal.BuildContext{GOOS: "linux", GOARCH: "amd64"},
+			&ImportsDetails{StdLib: []string{"os", "syscall"}, PlatformImports: platformImports},
+		},
+		{
+			internal.BuildContext{GOOS: "windows", GOARCH: "amd64"},
+			&ImportsDetails{
+				ExternalImports: []string{"golang.org/x/sys/windows"},
+				StdLib:          []string{"os"},
+				PlatformImports: platformImports,
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", test.bc.GOOS, test.bc.GOARCH), func(t *testing.T) {
+			got, err := (&Server{}).fetchImportsDetails(ctx, testDB, pkg.Path, pkg.ModulePath, pkg.Version, test.bc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.want.ModulePath = module.ModulePath
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFetchImportsDetails_Cached(t *testing.T) {
+	defer postgres.ResetTestDB(testDB, t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	module := sample.Module(sample.ModulePath, sample.VersionString, sample.Suffix)
+	pkg := module.Units[1]
+	pkg.Imports = []string{"os", "syscall"}
+	pkg.Documentation = []*internal.Documentation{
+		sample.Documentation("linux", "amd64", `package foo; import ("os"; "syscall")`),
+		sample.Documentation("windows", "amd64", `package foo; import ("os"; "golang.org/x/sys/windows")`),
+	}
+	postgres.MustInsertModule(ctx, t, testDB, module)
+
+	rs, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	c := cache.New(redis.NewClient(&redis.Options{Addr: rs.Addr()}))
+	s := &Server{detailsCache: c}
+
+	bc := internal.BuildContext{GOOS: "windows", GOARCH: "amd64"}
+	if _, err := s.fetchImportsDetails(ctx, testDB, pkg.Path, pkg.ModulePath, pkg.Version, bc); err != nil {
+		t.Fatal(err)
+	}
+	key := docImportsKey(&pkg.UnitMeta)
+	data, err := c.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data == nil {
+		t.Fatalf("no cache entry for %q", key)
+	}
+
+	// Replace the cached imports. If the second call returns them, the
+	// documentation was not decoded again.
+	var dis []*docImports
+	if err := json.Unmarshal(data, &dis); err != nil {
+		t.Fatal(err)
+	}
+	dis[1].Imports = []string{"os", "cached.com/windows"}
+	data, err = json.Marshal(dis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(ctx, key, data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.fetchImportsDetails(ctx, testDB, pkg.Path, pkg.ModulePath, pkg.Version, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cached.com/windows"}; !cmp.Equal(got.ExternalImports, want) {
+		t.Errorf("got external imports %q, want %q", got.ExternalImports, want)
+	}
+}
+
+func TestBuildContextImports(t *testing.T) {
+	recorded := []string{"os"}
+	dis := []*docImports{
+		{GOOS: "linux", GOARCH: "amd64", Imports: []string{"os", "syscall"}},
+		{GOOS: "windows", GOARCH: internal.All, Imports: []string{"golang.org/x/sys/windows", "os"}},
+		{GOOS: "js", GOARCH: "wasm", Unknown: true},
+	}
+	for _, test := range []struct {
+		bc   internal.BuildContext
+		want []string
+	}{
+		{internal.BuildContext{}, []string{"os", "syscall"}},
+		{internal.BuildContext{GOOS: "windows", GOARCH: "arm64"}, []string{"golang.org/x/sys/windows", "os"}},
+		{internal.BuildContext{GOOS: "js"}, recorded},
+		{internal.BuildContext{GOOS: "darwin", GOARCH: "amd64"}, recorded},
+	} {
+		got := buildContextImports(recorded, dis, test.bc)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.bc, diff)
+		}
+	}
+	all := []*docImports{{GOOS: internal.All, GOARCH: internal.All, Imports: []string{"fmt"}}}
+	if got := buildContextImports(recorded, all, internal.BuildContext{GOOS: "linux"}); !cmp.Equal(got, recorded) {
+		t.Errorf("all/all: got %q, want the recorded imports %q", got, recorded)
+	}
+}
+
+func TestPlatformImports(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		dis  []*docImports
+		want []*PlatformImport
+	}{
+		{
+			name: "one build context",
+			dis: []*docImports{
+				{GOOS: internal.All, GOARCH: internal.All, Imports: []string{"os"}},
+			},
+			want: nil,
+		},
+		{
+			name: "same imports",
+			dis: []*docImports{
+				{GOOS: "linux", GOARCH: "amd64", Imports: []string{"os"}},
+				{GOOS: "windows", GOARCH: "amd64", Imports: []string{"os"}},
+			},
+			want: nil,
+		},
+		{
+			name: "unknown imports",
+			dis: []*docImports{
+				{GOOS: "linux", GOARCH: "amd64", Imports: []string{"os", "syscall"}},
+				{GOOS: "windows", GOARCH: "amd64", Unknown: true},
+			},
+			want: nil,
+		},
+		{
+			name: "different imports",
+			dis: []*docImports{
+				{GOOS: "darwin", GOARCH: "amd64", Imports: []string{"golang.org/x/sys/unix", "os"}},
+				{GOOS: "linux", GOARCH: "amd64", Imports: []string{"golang.org/x/sys/unix", "os", "syscall"}},
+				{GOOS: "windows", GOARCH: "amd64", Imports: []string{"golang.org/x/sys/windows", "os", "syscall"}},
+			},
+			want: []*PlatformImport{
+				{Path: "golang.org/x/sys/unix", BuildContexts: []string{"darwin/amd64", "linux/amd64"}},
+				{Path: "golang.org/x/sys/windows", BuildContexts: []string{"windows/amd64"}},
+				{Path: "syscall", BuildContexts: []string{"linux/amd64", "windows/amd64"}},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := platformImports(test.dis)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestFetchImportedByDetails(t *testing.T) {
 	defer postgres.ResetTestDB(testDB, t)
 
@@ -117,7 +314,8 @@ func TestFetchImportedByDetails(t *testing.T) {
 			pkg: pkg2,
 			wantDetails: &ImportedByDetails{
 				ImportedBy:           []*Section{{Prefix: pkg3.Path, NumLines: 0}},
-				NumImportedByDisplay: "0",
+				NumImportedByDisplay: "1",
+				Total:                1,
 			},
 		},
 		{
@@ -127,7 +325,8 @@ func TestFetchImportedByDetails(t *testing.T) {
 					{Prefix: pkg2.Path, NumLines: 0},
 					{Prefix: pkg3.Path, NumLines: 0},
 				},
-				NumImportedByDisplay: "0",
+				NumImportedByDisplay: "2",
+				Total:                2,
 			},
 		},
 	}
@@ -143,34 +342,107 @@ func TestFetchImportedByDetails(t *testing.T) {
 }
 
 func TestFetchImportedByDetails_ExceedsTabLimit(t *testing.T) {
-	defer postgres.ResetTestDB(testDB, t)
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
+	const limit = 3
 
-	for _, count := range []int{tabImportedByLimit, 30000} {
-		t.Run(strconv.Itoa(count), func(t *testing.T) {
-			args := postgres.UpsertSearchDocumentArgs{
-				PackagePath: sample.PackagePath,
-				ModulePath:  sample.ModulePath,
-			}
+	for _, test := range []struct {
+		count       int
+		wantDisplay string
+	}{
+		{limit, "3"},
+		{5, "5 (displaying 3 packages)"},
+	} {
+		t.Run(strconv.Itoa(test.count), func(t *testing.T) {
+			defer postgres.ResetTestDB(testDB, t)
 			postgres.MustInsertModule(ctx, t, testDB, sample.Module(sample.ModulePath, sample.VersionString, sample.PackageName))
-			if err := testDB.UpsertSearchDocumentWithImportedByCount(ctx, args, count); err != nil {
-				t.Fatal(err)
+			var importers []string
+			for i := 0; i < test.count; i++ {
+				m := sample.Module(fmt.Sprintf("importer%d.com/m", i), sample.VersionString, "p")
+				m.Units[1].Imports = []string{sample.PackagePath}
+				postgres.MustInsertModule(ctx, t, testDB, m)
+				importers = append(importers, m.Units[1].Path)
 			}
 
 			pkg := sample.UnitForPackage(sample.PackagePath, sample.ModulePath, sample.VersionString, sample.PackageName, true)
 			wantDetails := &ImportedByDetails{
 				ModulePath:           sample.ModulePath,
-				NumImportedByDisplay: fmt.Sprintf("%d (displaying 20000 packages)", count),
-				Total:                count,
+				ImportedBy:           Sections(importers[:limit], nextPrefixAccount),
+				NumImportedByDisplay: test.wantDisplay,
+				Total:                test.count,
+				Truncated:            test.count > limit,
+			}
+			got, err := fetchImportedByDetails(ctx, testDB, pkg.Path, pkg.ModulePath, limit, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(wantDetails, got); diff != "" {
+				t.Errorf("fetchImportedByDetails(ctx, db, %q, %d) mismatch (-want +got):\n%s", pkg.Path, limit, diff)
 			}
-			checkFetchImportedByDetails(ctx, t, pkg, wantDetails)
 		})
 	}
 }
 
+func TestFetchImportedByDetails_Internal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	// example.com/m/internal/util is imported by its sibling example.com/m/p,
+	// and, improperly, by a package in another module.
+	const (
+		modulePath   = "example.com/m"
+		internalPath = modulePath + "/internal/util"
+		siblingPath  = modulePath + "/p"
+	)
+	m := sample.Module(modulePath, sample.VersionString, "internal/util", "p")
+	for _, u := range m.Units {
+		if u.Path == siblingPath {
+			u.Imports = []string{internalPath}
+		}
+	}
+	postgres.MustInsertModule(ctx, t, testDB, m)
+	other := sample.Module("example.com/other", sample.VersionString, "q")
+	other.Units[1].Imports = []string{internalPath}
+	postgres.MustInsertModule(ctx, t, testDB, other)
+
+	for _, test := range []struct {
+		showInternal bool
+		want         []string
+	}{
+		{false, []string{"example.com/other/q"}},
+		{true, []string{siblingPath}},
+	} {
+		t.Run(strconv.FormatBool(test.showInternal), func(t *testing.T) {
+			got, err := fetchImportedByDetails(ctx, testDB, internalPath, modulePath, defaultImportedByLimit, test.showInternal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &ImportedByDetails{
+				ModulePath:           modulePath,
+				ImportedBy:           Sections(test.want, nextPrefixAccount),
+				NumImportedByDisplay: "1",
+				Total:                1,
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// The flag does not change the importers of a package that is not
+	// internal, which are always in other modules.
+	got, err := fetchImportedByDetails(ctx, testDB, siblingPath, modulePath, defaultImportedByLimit, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Total != 0 {
+		t.Errorf("got %d importers of %s, want 0", got.Total, siblingPath)
+	}
+}
+
 func checkFetchImportedByDetails(ctx context.Context, t *testing.T, pkg *internal.Unit, wantDetails *ImportedByDetails) {
-	got, err := fetchImportedByDetails(ctx, testDB, pkg.Path, pkg.ModulePath)
+	got, err := fetchImportedByDetails(ctx, testDB, pkg.Path, pkg.ModulePath, defaultImportedByLimit, false)
 	if err != nil {
 		t.Fatalf("fetchImportedByDetails(ctx, db, %q) = %v err = %v, want %v",
 			pkg.Path, got, err, wantDetails)
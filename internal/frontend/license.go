@@ -7,12 +7,15 @@ package frontend
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"path"
 	"sort"
 	"strconv"
 
 	"github.com/google/safehtml"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/stdlib"
 )
 
 // License contains information used for a single license section.
@@ -20,11 +23,30 @@ type License struct {
 	*licenses.License
 	Anchor safehtml.Identifier
 	Source string
+	// Directory is the directory of the license file, relative to the module
+	// root. It is empty for licenses at the module root.
+	Directory string
+	// Matches holds the license types of the file, in the order of Types,
+	// with their confidences.
+	Matches []LicenseMatch
+}
+
+// LicenseMatch is a license type that a license file matches.
+type LicenseMatch struct {
+	Type string
+	// Confidence is the confidence of the match as a percentage, such as
+	// "96%". It is empty if the confidence is not known.
+	Confidence string
 }
 
 // LicensesDetails contains license information for a package or module.
 type LicensesDetails struct {
+	// Licenses are the licenses that apply to the unit: those at the module
+	// root and those in the unit's directory and the directories between.
 	Licenses []License
+	// DiffersFromModule reports whether the licenses of the unit differ from
+	// those of the module root, because some are in a nested directory.
+	DiffersFromModule bool
 }
 
 // LicenseMetadata contains license metadata that is used in the package
@@ -41,7 +63,19 @@ func fetchLicensesDetails(ctx context.Context, ds internal.DataSource, um *inter
 	if err != nil {
 		return nil, err
 	}
-	return &LicensesDetails{Licenses: transformLicenses(um.ModulePath, um.Version, u.LicenseContents)}, nil
+	return licensesDetails(um.ModulePath, um.Version, u.LicenseContents), nil
+}
+
+// licensesDetails returns the LicensesDetails for the given licenses of a unit
+// in the module version.
+func licensesDetails(modulePath, requestedVersion string, dbLicenses []*licenses.License) *LicensesDetails {
+	ld := &LicensesDetails{Licenses: transformLicenses(modulePath, requestedVersion, dbLicenses)}
+	for _, l := range ld.Licenses {
+		if l.Directory != "" {
+			ld.DiffersFromModule = true
+		}
+	}
+	return ld
 }
 
 // transformLicenses transforms licenses.License into a License
@@ -59,11 +93,28 @@ func transformLicenses(modulePath, requestedVersion string, dbLicenses []*licens
 			Anchor:  anchors[i],
 			License: l,
 			Source:  fileSource(modulePath, requestedVersion, l.FilePath),
+			Matches: licenseMatches(l.Metadata),
+		}
+		if dir := path.Dir(l.FilePath); dir != "." && modulePath != stdlib.ModulePath {
+			licenses[i].Directory = dir
 		}
 	}
 	return licenses
 }
 
+// licenseMatches returns the license types of md with their confidences.
+func licenseMatches(md *licenses.Metadata) []LicenseMatch {
+	var ms []LicenseMatch
+	for i, t := range md.Types {
+		m := LicenseMatch{Type: t}
+		if len(md.Confidence) == len(md.Types) {
+			m.Confidence = fmt.Sprintf("%.0f%%", md.Confidence[i])
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
 // transformLicenseMetadata transforms licenses.Metadata into a LicenseMetadata
 // by adding an anchor field.
 func transformLicenseMetadata(dbLicenses []*licenses.Metadata) []LicenseMetadata {
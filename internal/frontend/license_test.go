@@ -7,6 +7,7 @@ package frontend
 import (
 	"bytes"
 	"context"
+	"path"
 	"sort"
 	"strings"
 	"testing"
@@ -43,14 +44,38 @@ func TestLicenseAnchors(t *testing.T) {
 	}
 }
 
+func TestLicenseMatches(t *testing.T) {
+	for _, test := range []struct {
+		md   *licenses.Metadata
+		want []LicenseMatch
+	}{
+		{
+			&licenses.Metadata{Types: []string{"MIT"}},
+			[]LicenseMatch{{Type: "MIT"}},
+		},
+		{
+			&licenses.Metadata{Types: []string{"BSD-2-Clause", "BSD-3-Clause"}, Confidence: []float64{100, 91.6}},
+			[]LicenseMatch{{Type: "BSD-2-Clause", Confidence: "100%"}, {Type: "BSD-3-Clause", Confidence: "92%"}},
+		},
+	} {
+		if diff := cmp.Diff(test.want, licenseMatches(test.md)); diff != "" {
+			t.Errorf("%v: mismatch (-want +got):\n%s", test.md.Types, diff)
+		}
+	}
+}
+
 func TestFetchLicensesDetails(t *testing.T) {
-	testModule := sample.Module(sample.ModulePath, "v1.2.3", "A/B")
+	testModule := sample.Module(sample.ModulePath, "v1.2.3", "A/B", "A/BC")
 	stdlibModule := sample.Module(stdlib.ModulePath, "v1.13.0", "cmd/go")
 	crlfPath := "github.com/crlf/module_name"
 	crlfModule := sample.Module(crlfPath, "v1.2.3", "A")
 
 	mit := &licenses.Metadata{Types: []string{"MIT"}, FilePath: "LICENSE"}
-	bsd := &licenses.Metadata{Types: []string{"BSD-3-Clause"}, FilePath: "A/B/LICENSE"}
+	bsd := &licenses.Metadata{
+		Types:      []string{"BSD-3-Clause", "BSD-3-Clause-Clear"},
+		Confidence: []float64{100, 91.5},
+		FilePath:   "A/B/LICENSE",
+	}
 
 	mitLicense := &licenses.License{
 		Metadata: mit,
@@ -77,6 +102,8 @@ func TestFetchLicensesDetails(t *testing.T) {
 	testModule.Units[1].Licenses = []*licenses.Metadata{mit}
 	// github.com/valid/module_name/A/B
 	testModule.Units[2].Licenses = []*licenses.Metadata{mit, bsd}
+	// github.com/valid/module_name/A/BC
+	testModule.Units[3].Licenses = []*licenses.Metadata{mit}
 
 	defer postgres.ResetTestDB(testDB, t)
 	ctx := context.Background()
@@ -87,6 +114,7 @@ func TestFetchLicensesDetails(t *testing.T) {
 		err                                 error
 		name, fullPath, modulePath, version string
 		want                                []*licenses.License
+		wantDiffersFromModule               bool
 	}{
 		{
 			name:       "module root",
@@ -103,11 +131,19 @@ func TestFetchLicensesDetails(t *testing.T) {
 			want:       []*licenses.License{testModule.Licenses[1]},
 		},
 		{
-			name:       "package with additional license",
-			fullPath:   sample.ModulePath + "/A/B",
+			name:                  "package with additional license",
+			fullPath:              sample.ModulePath + "/A/B",
+			modulePath:            sample.ModulePath,
+			version:               testModule.Version,
+			want:                  testModule.Licenses,
+			wantDiffersFromModule: true,
+		},
+		{
+			name:       "package with a path prefix of a licensed directory",
+			fullPath:   sample.ModulePath + "/A/BC",
 			modulePath: sample.ModulePath,
 			version:    testModule.Version,
-			want:       testModule.Licenses,
+			want:       []*licenses.License{testModule.Licenses[1]},
 		},
 		{
 			name:       "stdlib directory",
@@ -139,8 +175,10 @@ func TestFetchLicensesDetails(t *testing.T) {
 		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
-			wantDetails := &LicensesDetails{Licenses: transformLicenses(
-				test.modulePath, test.version, test.want)}
+			wantDetails := &LicensesDetails{
+				Licenses:          transformLicenses(test.modulePath, test.version, test.want),
+				DiffersFromModule: test.wantDiffersFromModule,
+			}
 			got, err := fetchLicensesDetails(ctx, testDB, &internal.UnitMeta{
 				Path: test.fullPath,
 				ModuleInfo: internal.ModuleInfo{
@@ -157,6 +195,9 @@ func TestFetchLicensesDetails(t *testing.T) {
 				t.Errorf("mismatch (-want +got):\n%s", diff)
 			}
 			for _, l := range got.Licenses {
+				if want := path.Dir(l.FilePath); want != "." && l.Directory != want {
+					t.Errorf("license %s: got directory %q, want %q", l.FilePath, l.Directory, want)
+				}
 				if bytes.Contains(l.Contents, []byte("\r")) {
 					t.Errorf("license %s contains \\r line terminators", l.Metadata.FilePath)
 				}
@@ -17,6 +17,7 @@ import (
 	"golang.org/x/pkgsite/internal/godoc/dochtml"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/version"
 )
 
@@ -76,6 +77,19 @@ type MainDetails struct {
 	// BuildContexts holds the values for build contexts available for the doc.
 	BuildContexts []internal.BuildContext
 
+	// SupportedBuildContexts is internal.Unit.SupportedBuildContexts.
+	SupportedBuildContexts []internal.BuildContext
+
+	// UsesCgo reports whether the package imports "C".
+	UsesCgo bool
+
+	// Label is a short note shown with the unit. See internal.Unit.
+	Label string
+
+	// Examples lists the examples in the doc, with the symbols they are
+	// associated with.
+	Examples []*dochtml.Example
+
 	// SourceFiles contains .go files for the package.
 	SourceFiles []*File
 
@@ -96,6 +110,16 @@ type MainDetails struct {
 
 	// IsStableVersion is true if the major version is v1 or greater.
 	IsStableVersion bool
+
+	// ImpliedGoVersion is the minimum Go version implied by the go directives
+	// of the module and its direct dependencies. It is only set for module
+	// pages, and is nil if it is not known.
+	ImpliedGoVersion *internal.ImpliedGoVersion
+
+	// Maintainers summarizes the module's AUTHORS, MAINTAINERS, OWNERS or
+	// CODEOWNERS file. It is only set for module pages, and is nil if the
+	// module has no such file.
+	Maintainers *internal.Maintainers
 }
 
 // File is a source file for a package.
@@ -104,7 +128,11 @@ type File struct {
 	URL  string
 }
 
-func fetchMainDetails(ctx context.Context, ds internal.DataSource, um *internal.UnitMeta, expandReadme bool, bc internal.BuildContext) (_ *MainDetails, err error) {
+// fetchMainDetails returns the details of the main tab of the unit page, with
+// the documentation for bc. If remembered is true, bc is the build context the
+// user selected on another page, and the documentation for the default build
+// context is used if the unit has none for bc.
+func fetchMainDetails(ctx context.Context, ds internal.DataSource, um *internal.UnitMeta, expandReadme bool, bc internal.BuildContext, remembered, showInternal bool) (_ *MainDetails, err error) {
 	defer middleware.ElapsedStat(ctx, "fetchMainDetails")()
 
 	unit, err := ds.GetUnit(ctx, um, internal.WithMain)
@@ -133,6 +161,9 @@ func fetchMainDetails(ctx context.Context, ds internal.DataSource, um *internal.
 	)
 
 	doc := internal.DocumentationForBuildContext(unit.Documentation, bc)
+	if doc == nil && remembered {
+		doc = internal.DocumentationForBuildContext(unit.Documentation, internal.BuildContext{})
+	}
 	if doc != nil {
 		synopsis = doc.Synopsis
 		goos = doc.GOOS
@@ -169,7 +200,11 @@ func fetchMainDetails(ctx context.Context, ds internal.DataSource, um *internal.
 			}
 			return nil, err
 		}
-		docParts, err = getHTML(ctx, unit, docPkg)
+		nonImportable, err := getNonImportablePackages(ctx, ds, um, docPkg.Imports())
+		if err != nil {
+			return nil, err
+		}
+		docParts, err = getHTML(ctx, unit, docPkg, nonImportable)
 		// If err  is ErrTooLarge, then docBody will have an appropriate message.
 		if err != nil && !errors.Is(err, dochtml.ErrTooLarge) {
 			return nil, err
@@ -178,7 +213,7 @@ func fetchMainDetails(ctx context.Context, ds internal.DataSource, um *internal.
 			docLinks = append(docLinks, link{Href: l.Href, Body: l.Text})
 		}
 		end = middleware.ElapsedStat(ctx, "sourceFiles")
-		files = sourceFiles(unit, docPkg)
+		files = unitSourceFiles(unit, docPkg)
 		end()
 	}
 	// If the unit is not a module, fetch the module readme to extract its
@@ -199,6 +234,21 @@ func fetchMainDetails(ctx context.Context, ds internal.DataSource, um *internal.
 		}
 	}
 
+	var (
+		igv         *internal.ImpliedGoVersion
+		maintainers *internal.Maintainers
+	)
+	if unit.Path == unit.ModulePath {
+		igv, err = getImpliedGoVersion(ctx, ds, um)
+		if err != nil {
+			return nil, err
+		}
+		maintainers, err = getMaintainers(ctx, ds, um)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	versionType, err := version.ParseType(um.Version)
 	if err != nil {
 		return nil, err
@@ -206,34 +256,94 @@ func fetchMainDetails(ctx context.Context, ds internal.DataSource, um *internal.
 	isTaggedVersion := versionType != version.TypePseudo
 	isStableVersion := semver.Major(um.Version) != "v0" && versionType == version.TypeRelease
 	return &MainDetails{
-		ExpandReadme:      expandReadme,
-		Directories:       unitDirectories(append(subdirectories, nestedModules...)),
-		Licenses:          transformLicenseMetadata(um.Licenses),
-		CommitTime:        absoluteTime(um.CommitTime),
-		Readme:            readme.HTML,
-		ReadmeOutline:     readme.Outline,
-		ReadmeLinks:       readme.Links,
-		DocLinks:          docLinks,
-		ModuleReadmeLinks: modLinks,
-		DocOutline:        docParts.Outline,
-		DocBody:           docParts.Body,
-		DocSynopsis:       synopsis,
-		GOOS:              goos,
-		GOARCH:            goarch,
-		BuildContexts:     buildContexts,
-		SourceFiles:       files,
-		RepositoryURL:     um.SourceInfo.RepoURL(),
-		SourceURL:         um.SourceInfo.DirectoryURL(internal.Suffix(um.Path, um.ModulePath)),
-		MobileOutline:     docParts.MobileOutline,
-		NumImports:        unit.NumImports,
-		ImportedByCount:   unit.NumImportedBy,
-		IsPackage:         unit.IsPackage(),
-		ModFileURL:        um.SourceInfo.ModuleURL() + "/go.mod",
-		IsTaggedVersion:   isTaggedVersion,
-		IsStableVersion:   isStableVersion,
+		ExpandReadme:           expandReadme,
+		Directories:            unitDirectories(append(subdirectories, nestedModules...), showInternal),
+		Licenses:               transformLicenseMetadata(um.Licenses),
+		CommitTime:             absoluteTime(um.CommitTime),
+		Readme:                 readme.HTML,
+		ReadmeOutline:          readme.Outline,
+		ReadmeLinks:            readme.Links,
+		DocLinks:               docLinks,
+		ModuleReadmeLinks:      modLinks,
+		DocOutline:             docParts.Outline,
+		DocBody:                docParts.Body,
+		DocSynopsis:            synopsis,
+		GOOS:                   goos,
+		GOARCH:                 goarch,
+		BuildContexts:          buildContexts,
+		SupportedBuildContexts: unit.SupportedBuildContexts,
+		UsesCgo:                unit.UsesCgo,
+		Label:                  unit.Label,
+		Examples:               docParts.Examples,
+		SourceFiles:            files,
+		RepositoryURL:          um.SourceInfo.RepoURL(),
+		SourceURL:              um.SourceInfo.DirectoryURL(internal.Suffix(um.Path, um.ModulePath)),
+		MobileOutline:          docParts.MobileOutline,
+		NumImports:             unit.NumImports,
+		ImportedByCount:        unit.NumImportedBy,
+		IsPackage:              unit.IsPackage(),
+		ModFileURL:             um.SourceInfo.ModuleURL() + "/go.mod",
+		IsTaggedVersion:        isTaggedVersion,
+		IsStableVersion:        isStableVersion,
+		ImpliedGoVersion:       igv,
+		Maintainers:            maintainers,
 	}, nil
 }
 
+// getImpliedGoVersion returns the minimum Go version implied by the module of
+// um and its direct dependencies, or nil if it is not known.
+func getImpliedGoVersion(ctx context.Context, ds internal.DataSource, um *internal.UnitMeta) (_ *internal.ImpliedGoVersion, err error) {
+	defer derrors.Wrap(&err, "getImpliedGoVersion(%q, %q)", um.ModulePath, um.Version)
+
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		// Requirements are only stored in the database.
+		return nil, nil
+	}
+	igv, err := db.GetImpliedGoVersion(ctx, um.ModulePath, um.Version)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if igv.MinGoVersion == "" {
+		return nil, nil
+	}
+	return igv, nil
+}
+
+// getNonImportablePackages returns the set of imports that are packages of
+// the module of um but cannot be imported from outside it, so that the
+// documentation does not link to them.
+func getNonImportablePackages(ctx context.Context, ds internal.DataSource, um *internal.UnitMeta, imports []string) (_ map[string]bool, err error) {
+	defer derrors.Wrap(&err, "getNonImportablePackages(%q, %q)", um.ModulePath, um.Version)
+
+	db, ok := ds.(*postgres.DB)
+	if !ok || len(imports) == 0 {
+		// Whether a unit is importable is only stored in the database.
+		return nil, nil
+	}
+	return db.GetNonImportablePackages(ctx, um.ModulePath, um.Version, imports)
+}
+
+// getMaintainers returns the summary of the maintainers file of the module of
+// um, or nil if there is none.
+func getMaintainers(ctx context.Context, ds internal.DataSource, um *internal.UnitMeta) (_ *internal.Maintainers, err error) {
+	defer derrors.Wrap(&err, "getMaintainers(%q, %q)", um.ModulePath, um.Version)
+
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		// Maintainers are only stored in the database.
+		return nil, nil
+	}
+	m, err := db.GetModuleMaintainers(ctx, um.ModulePath, um.Version)
+	if err != nil && !errors.Is(err, derrors.NotFound) {
+		return nil, err
+	}
+	return m, nil
+}
+
 // readmeContent renders the readme to html and collects the headings
 // into an outline.
 func readmeContent(ctx context.Context, u *internal.Unit) (_ *Readme, err error) {
@@ -247,11 +357,11 @@ func readmeContent(ctx context.Context, u *internal.Unit) (_ *Readme, err error)
 
 const missingDocReplacement = `<p>Documentation is missing.</p>`
 
-func getHTML(ctx context.Context, u *internal.Unit, docPkg *godoc.Package) (_ *dochtml.Parts, err error) {
+func getHTML(ctx context.Context, u *internal.Unit, docPkg *godoc.Package, nonImportable map[string]bool) (_ *dochtml.Parts, err error) {
 	defer derrors.Wrap(&err, "getHTML(%s)", u.Path)
 
 	if len(u.Documentation[0].Source) > 0 {
-		return renderDocParts(ctx, u, docPkg)
+		return renderDocParts(ctx, u, docPkg, nonImportable)
 	}
 	log.Errorf(ctx, "unit %s (%s@%s) missing documentation source", u.Path, u.ModulePath, u.Version)
 	return &dochtml.Parts{Body: template.MustParseAndExecuteToHTML(missingDocReplacement)}, nil
@@ -0,0 +1,585 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file converts READMEs written in AsciiDoc and reStructuredText to
+// Markdown, so that they can be rendered by the same code that renders
+// Markdown READMEs. Only the constructs that are commonly used in READMEs are
+// supported: headings, paragraphs, lists, code blocks, links and images.
+// Everything else is passed through as text.
+
+// isMarkup reports whether filename says that the file contains AsciiDoc or
+// reStructuredText.
+func isMarkup(filename string) bool {
+	return isAsciiDoc(filename) || isReStructuredText(filename)
+}
+
+func isAsciiDoc(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".adoc" || ext == ".asciidoc"
+}
+
+func isReStructuredText(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".rst"
+}
+
+// markupToMarkdown converts contents, which is AsciiDoc or reStructuredText
+// according to filename, to Markdown. It returns an error if contents is
+// malformed in a way that the conversion cannot handle.
+func markupToMarkdown(filename, contents string) (string, error) {
+	lines := strings.Split(strings.ReplaceAll(contents, "\r\n", "\n"), "\n")
+	var (
+		out []string
+		err error
+	)
+	switch {
+	case isAsciiDoc(filename):
+		out, err = asciiDocToMarkdown(lines)
+	case isReStructuredText(filename):
+		out, err = newRSTConverter(lines).convert(lines)
+	default:
+		return "", fmt.Errorf("%q is not AsciiDoc or reStructuredText", filename)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// codeFence returns the lines of a fenced Markdown code block with the given
+// language and contents.
+func codeFence(lang string, lines []string) []string {
+	fence := "```"
+	for _, l := range lines {
+		for strings.Contains(l, fence) {
+			fence += "`"
+		}
+	}
+	out := []string{fence + lang}
+	out = append(out, lines...)
+	return append(out, fence, "")
+}
+
+// dedent removes the common leading whitespace from lines, and the blank lines
+// at their start and end.
+func dedent(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	min := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if n := indentation(l); min < 0 || n < min {
+			min = n
+		}
+	}
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if len(l) >= min && min > 0 {
+			out[i] = l[min:]
+		} else {
+			out[i] = strings.TrimLeft(l, " \t")
+		}
+	}
+	return out
+}
+
+// indentation returns the number of leading spaces of line, counting a tab as
+// a single space.
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// upperFirst returns s with its first letter in upper case.
+func upperFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	if n == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+// escapeMarkdownText escapes the characters of s, which is text outside of
+// code, that Markdown would otherwise interpret as HTML.
+func escapeMarkdownText(s string) string {
+	return strings.ReplaceAll(s, "<", "&lt;")
+}
+
+// convertOutsideCode applies convert to the parts of s that are not inside
+// code spans, which are matched by codeRE. The first submatch of codeRE that
+// matched is the text of the code span.
+func convertOutsideCode(s string, codeRE *regexp.Regexp, convert func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range codeRE.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(convert(s[last:m[0]]))
+		var code string
+		for g := 2; g < len(m); g += 2 {
+			if m[g] >= 0 {
+				code = s[m[g]:m[g+1]]
+				break
+			}
+		}
+		if strings.Contains(code, "`") {
+			b.WriteString("`` " + code + " ``")
+		} else {
+			b.WriteString("`" + code + "`")
+		}
+		last = m[1]
+	}
+	b.WriteString(convert(s[last:]))
+	return b.String()
+}
+
+var (
+	adocHeadingRE   = regexp.MustCompile(`^(={1,6}) +(\S.*?)(?: +=+)?$`)
+	adocAttributeRE = regexp.MustCompile(`^:!?[\w-]+!?:`)
+	adocBlockAttrRE = regexp.MustCompile(`^\[([^\]]*)\]$`)
+	adocBulletRE    = regexp.MustCompile(`^(\*{1,5}|-) +(.*)$`)
+	adocOrderedRE   = regexp.MustCompile(`^(\.{1,5}) +(.*)$`)
+	adocTitleRE     = regexp.MustCompile(`^\.([^.\s].*)$`)
+	adocAdmonRE     = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION): +(.*)$`)
+	adocCodeRE      = regexp.MustCompile("`([^`]+)`")
+	adocImageRE     = regexp.MustCompile(`image::?(\S+?)\[([^\]]*)\]`)
+	adocLinkRE      = regexp.MustCompile(`(?:link:)?((?:https?|mailto):[^\s\[]+|link:\S+?)\[([^\]]*)\]`)
+	adocXrefRE      = regexp.MustCompile(`<<([^,>]+)(?:, *([^>]+))?>>`)
+	adocStrongRE    = regexp.MustCompile(`\*\*(.+?)\*\*|\B\*([^*\s](?:[^*]*[^*\s])?)\*\B`)
+)
+
+// asciiDocToMarkdown converts the lines of an AsciiDoc document to Markdown.
+func asciiDocToMarkdown(lines []string) ([]string, error) {
+	var (
+		out  []string
+		lang string // language of the next source block, from [source,lang]
+	)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		switch {
+		case line == "////":
+			// Comment block.
+			end := findLine(lines, i+1, line)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment block", i+1)
+			}
+			i = end
+		case isAsciiDocDelimiter(line):
+			// Listing or literal block.
+			end := findLine(lines, i+1, line)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated %q block", i+1, line)
+			}
+			out = append(out, codeFence(lang, lines[i+1:end])...)
+			lang = ""
+			i = end
+		case strings.HasPrefix(line, "//"):
+			// Comment line.
+		case adocAttributeRE.MatchString(line):
+			// Document attributes are not rendered.
+		case adocBlockAttrRE.MatchString(line):
+			attrs := strings.Split(adocBlockAttrRE.FindStringSubmatch(line)[1], ",")
+			if len(attrs) > 1 && strings.TrimSpace(attrs[0]) == "source" {
+				lang = strings.TrimSpace(attrs[1])
+			}
+		case adocHeadingRE.MatchString(line):
+			m := adocHeadingRE.FindStringSubmatch(line)
+			out = append(out, strings.Repeat("#", len(m[1]))+" "+asciiDocInline(m[2]), "")
+		case adocBulletRE.MatchString(line):
+			m := adocBulletRE.FindStringSubmatch(line)
+			depth := 0
+			if m[1] != "-" {
+				depth = len(m[1]) - 1
+			}
+			out = append(out, strings.Repeat("    ", depth)+"- "+asciiDocInline(m[2]))
+		case adocOrderedRE.MatchString(line):
+			m := adocOrderedRE.FindStringSubmatch(line)
+			out = append(out, strings.Repeat("    ", len(m[1])-1)+"1. "+asciiDocInline(m[2]))
+		case adocTitleRE.MatchString(line):
+			out = append(out, "**"+asciiDocInline(adocTitleRE.FindStringSubmatch(line)[1])+"**", "")
+		case adocAdmonRE.MatchString(line):
+			m := adocAdmonRE.FindStringSubmatch(line)
+			out = append(out, "**"+upperFirst(strings.ToLower(m[1]))+":** "+asciiDocInline(m[2]))
+		case line == "+":
+			// List continuation.
+			out = append(out, "")
+		default:
+			if strings.HasPrefix(line, "#") {
+				line = `\` + line
+			}
+			out = append(out, asciiDocInline(line))
+		}
+	}
+	return out, nil
+}
+
+// isAsciiDocDelimiter reports whether line delimits a listing or literal block.
+func isAsciiDocDelimiter(line string) bool {
+	if len(line) < 4 || (line[0] != '-' && line[0] != '.') {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// findLine returns the index of the first line at or after start that is
+// equal to want, ignoring trailing whitespace, or -1 if there is none.
+func findLine(lines []string, start int, want string) int {
+	for j := start; j < len(lines); j++ {
+		if strings.TrimRight(lines[j], " \t") == want {
+			return j
+		}
+	}
+	return -1
+}
+
+// asciiDocInline converts the inline markup of an AsciiDoc line to Markdown.
+func asciiDocInline(s string) string {
+	return convertOutsideCode(s, adocCodeRE, func(s string) string {
+		s = adocImageRE.ReplaceAllString(s, "![$2]($1)")
+		s = adocLinkRE.ReplaceAllStringFunc(s, func(m string) string {
+			sm := adocLinkRE.FindStringSubmatch(m)
+			url := strings.TrimPrefix(sm[1], "link:")
+			text := sm[2]
+			if text == "" {
+				text = url
+			}
+			return "[" + text + "](" + url + ")"
+		})
+		s = adocXrefRE.ReplaceAllStringFunc(s, func(m string) string {
+			sm := adocXrefRE.FindStringSubmatch(m)
+			if sm[2] != "" {
+				return sm[2]
+			}
+			return sm[1]
+		})
+		s = adocStrongRE.ReplaceAllStringFunc(s, func(m string) string {
+			sm := adocStrongRE.FindStringSubmatch(m)
+			return "**" + sm[1] + sm[2] + "**"
+		})
+		return escapeMarkdownText(s)
+	})
+}
+
+var (
+	rstDirectiveRE    = regexp.MustCompile(`^\.\. +([\w-]+):: *(.*)$`)
+	rstTargetRE       = regexp.MustCompile(`^\.\. +_([^:]+|` + "`[^`]+`" + `): +(\S+)$`)
+	rstSubstitutionRE = regexp.MustCompile(`^\.\. +\|([^|]+)\| +image:: +(\S+)$`)
+	rstOptionRE       = regexp.MustCompile(`^:([\w-]+):(?: +(.*))?$`)
+	rstBulletRE       = regexp.MustCompile(`^( *)[-*+•] +(.*)$`)
+	rstEnumeratedRE   = regexp.MustCompile(`^( *)(?:\d+|#)[.)] +(.*)$`)
+	rstSimpleTableRE  = regexp.MustCompile(`^=+( +=+)+ *$`)
+	rstCodeRE         = regexp.MustCompile("``(.+?)``|:[\\w-]+:`([^`]+)`")
+	rstLinkRE         = regexp.MustCompile("`([^`<]*?) *<([^`>]+)>`__?")
+	rstRefRE          = regexp.MustCompile("`([^`]+)`__?")
+	rstSimpleRefRE    = regexp.MustCompile(`(^|[\s(])(\w[\w.-]*?)__?([\s.,;:!?)]|$)`)
+	rstSubRefRE       = regexp.MustCompile(`\|([^|\s][^|]*)\|`)
+)
+
+// rstAdmonitions are the directives that are rendered as block quotes.
+var rstAdmonitions = map[string]bool{
+	"admonition": true, "attention": true, "caution": true, "danger": true,
+	"error": true, "hint": true, "important": true, "note": true, "tip": true,
+	"warning": true,
+}
+
+// rstConverter converts a reStructuredText document to Markdown.
+type rstConverter struct {
+	targets map[string]string // hyperlink targets, by normalized name
+	images  map[string]string // Markdown for image substitutions, by name
+	levels  []string          // section title adornments, in order of appearance
+}
+
+// newRSTConverter returns a converter for the document made up of lines,
+// collecting the hyperlink targets and substitutions that it defines.
+func newRSTConverter(lines []string) *rstConverter {
+	c := &rstConverter{targets: map[string]string{}, images: map[string]string{}}
+	for i, line := range lines {
+		if m := rstTargetRE.FindStringSubmatch(line); m != nil {
+			c.targets[rstName(strings.Trim(m[1], "`"))] = m[2]
+		}
+		if m := rstSubstitutionRE.FindStringSubmatch(line); m != nil {
+			alt, target := m[1], ""
+			for _, opt := range lines[i+1:] {
+				om := rstOptionRE.FindStringSubmatch(strings.TrimSpace(opt))
+				if om == nil || indentation(opt) == 0 {
+					break
+				}
+				switch om[1] {
+				case "alt":
+					alt = om[2]
+				case "target":
+					target = om[2]
+				}
+			}
+			img := "![" + alt + "](" + m[2] + ")"
+			if target != "" {
+				img = "[" + img + "](" + target + ")"
+			}
+			c.images[m[1]] = img
+		}
+	}
+	return c
+}
+
+// rstName normalizes a reference name, which is case insensitive and
+// whitespace neutral.
+func rstName(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// convert converts lines, which are part of the converter's document.
+func (c *rstConverter) convert(lines []string) ([]string, error) {
+	var (
+		out    []string
+		inList bool // whether the last paragraph was a list item
+	)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		next := ""
+		if i+1 < len(lines) {
+			next = strings.TrimRight(lines[i+1], " \t")
+		}
+		switch {
+		case line == "":
+			out = append(out, "")
+			continue
+		case isRSTAdornment(line) && next != "" && !isRSTAdornment(next):
+			// Section title with an overline.
+			if i+2 >= len(lines) || strings.TrimRight(lines[i+2], " \t") != line {
+				return nil, fmt.Errorf("line %d: section title overline without matching underline", i+1)
+			}
+			out = append(out, c.heading("over"+line[:1], strings.TrimSpace(next))...)
+			i += 2
+		case isRSTAdornment(line):
+			// Transition.
+			if len(line) >= 4 {
+				out = append(out, "", "***", "")
+			}
+		case isRSTAdornment(next) && indentation(line) == 0 &&
+			utf8.RuneCountInString(next) >= utf8.RuneCountInString(line):
+			// Section title with an underline.
+			out = append(out, c.heading(next[:1], line)...)
+			i++
+		case strings.HasPrefix(line, ".. "):
+			block, end := indentedBlock(lines, i+1, 0)
+			md, err := c.explicitMarkup(line, block)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			out = append(out, md...)
+			i = end - 1
+		case line == "::" || strings.HasSuffix(line, "::"):
+			// Paragraph followed by a literal block.
+			switch {
+			case line == "::":
+			case strings.HasSuffix(line, " ::"):
+				out = append(out, c.inline(strings.TrimSuffix(line, " ::")))
+			default:
+				out = append(out, c.inline(strings.TrimSuffix(line, ":")))
+			}
+			block, end := indentedBlock(lines, i+1, indentation(line))
+			if len(block) > 0 {
+				out = append(out, "")
+				out = append(out, codeFence("", dedent(block))...)
+			}
+			i = end - 1
+		case strings.HasPrefix(line, "+-") || strings.HasPrefix(line, "+="):
+			// Grid table.
+			end := i
+			for end < len(lines) && (strings.HasPrefix(lines[end], "+") || strings.HasPrefix(lines[end], "|")) {
+				end++
+			}
+			out = append(out, codeFence("", lines[i:end])...)
+			i = end - 1
+		case rstSimpleTableRE.MatchString(line):
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+				end++
+			}
+			out = append(out, codeFence("", lines[i:end])...)
+			i = end - 1
+		case rstBulletRE.MatchString(line):
+			m := rstBulletRE.FindStringSubmatch(line)
+			out = append(out, m[1]+"- "+c.inline(m[2]))
+			inList = true
+			continue
+		case rstEnumeratedRE.MatchString(line):
+			m := rstEnumeratedRE.FindStringSubmatch(line)
+			out = append(out, m[1]+"1. "+c.inline(m[2]))
+			inList = true
+			continue
+		case indentation(line) > 0 && inList:
+			// Continuation of a list item.
+			out = append(out, line[:indentation(line)]+c.inline(strings.TrimSpace(line)))
+			continue
+		case indentation(line) > 0:
+			// Block quote.
+			block, end := indentedBlock(lines, i, 0)
+			for _, l := range dedent(block) {
+				out = append(out, strings.TrimSpace("> "+c.inline(l)))
+			}
+			out = append(out, "")
+			i = end - 1
+		default:
+			if strings.HasPrefix(line, "#") {
+				line = `\` + line
+			}
+			out = append(out, c.inline(line))
+		}
+		inList = false
+	}
+	return out, nil
+}
+
+// heading returns the Markdown for a section title with the given adornment
+// style. The level of a section is determined by the order in which the
+// adornment styles first appear in the document.
+func (c *rstConverter) heading(style, title string) []string {
+	level := 0
+	for level < len(c.levels) && c.levels[level] != style {
+		level++
+	}
+	if level == len(c.levels) {
+		c.levels = append(c.levels, style)
+	}
+	if level > 5 {
+		level = 5
+	}
+	return []string{"", strings.Repeat("#", level+1) + " " + c.inline(title), ""}
+}
+
+// explicitMarkup converts an explicit markup block: a directive, hyperlink
+// target, substitution definition or comment, whose first line is line and
+// whose indented content is block.
+func (c *rstConverter) explicitMarkup(line string, block []string) ([]string, error) {
+	m := rstDirectiveRE.FindStringSubmatch(line)
+	if m == nil {
+		// Hyperlink targets and substitution definitions were collected by
+		// newRSTConverter. Comments and footnotes are not rendered.
+		return nil, nil
+	}
+	name, arg := strings.ToLower(m[1]), m[2]
+	options := map[string]string{}
+	content := dedent(block)
+	for len(content) > 0 {
+		om := rstOptionRE.FindStringSubmatch(content[0])
+		if om == nil {
+			break
+		}
+		options[om[1]] = om[2]
+		content = content[1:]
+	}
+	content = dedent(content)
+
+	switch {
+	case name == "code-block" || name == "code" || name == "sourcecode":
+		return codeFence(arg, content), nil
+	case name == "image" || name == "figure":
+		img := "![" + options["alt"] + "](" + arg + ")"
+		if t := options["target"]; t != "" {
+			img = "[" + img + "](" + t + ")"
+		}
+		out := []string{img, ""}
+		if name == "figure" && len(content) > 0 {
+			caption, err := c.convert(content)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, caption...)
+			out = append(out, "")
+		}
+		return out, nil
+	case rstAdmonitions[name]:
+		title := upperFirst(name)
+		if name == "admonition" {
+			title = arg
+		} else if arg != "" {
+			content = append([]string{arg}, content...)
+		}
+		body, err := c.convert(content)
+		if err != nil {
+			return nil, err
+		}
+		out := []string{"> **" + c.inline(title) + "**", ">"}
+		for _, l := range body {
+			out = append(out, strings.TrimSpace("> "+l))
+		}
+		return append(out, ""), nil
+	default:
+		// Other directives, like contents, raw and include, are not rendered.
+		return nil, nil
+	}
+}
+
+// indentedBlock returns the lines starting at start that are indented more
+// than indent, including blank lines between them, and the index of the
+// first line after them.
+func indentedBlock(lines []string, start, indent int) ([]string, int) {
+	end := start
+	for end < len(lines) {
+		l := lines[end]
+		if strings.TrimSpace(l) != "" && indentation(l) <= indent {
+			break
+		}
+		end++
+	}
+	return lines[start:end], end
+}
+
+// isRSTAdornment reports whether line consists of a single repeated
+// punctuation character, as used for section titles and transitions.
+func isRSTAdornment(line string) bool {
+	if len(line) < 2 || !strings.ContainsRune("=-`:'\"~^_*+#<>", rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// inline converts the inline markup of a reStructuredText line to Markdown.
+func (c *rstConverter) inline(s string) string {
+	return convertOutsideCode(s, rstCodeRE, func(s string) string {
+		s = rstLinkRE.ReplaceAllStringFunc(s, func(m string) string {
+			sm := rstLinkRE.FindStringSubmatch(m)
+			text := sm[1]
+			if text == "" {
+				text = sm[2]
+			}
+			return "[" + text + "](" + sm[2] + ")"
+		})
+		s = rstRefRE.ReplaceAllStringFunc(s, func(m string) string {
+			text := rstRefRE.FindStringSubmatch(m)[1]
+			if url, ok := c.targets[rstName(text)]; ok {
+				return "[" + text + "](" + url + ")"
+			}
+			return text
+		})
+		s = rstSimpleRefRE.ReplaceAllStringFunc(s, func(m string) string {
+			sm := rstSimpleRefRE.FindStringSubmatch(m)
+			if url, ok := c.targets[rstName(sm[2])]; ok {
+				return sm[1] + "[" + sm[2] + "](" + url + ")" + sm[3]
+			}
+			return m
+		})
+		s = rstSubRefRE.ReplaceAllStringFunc(s, func(m string) string {
+			if img, ok := c.images[rstSubRefRE.FindStringSubmatch(m)[1]]; ok {
+				return img
+			}
+			return m
+		})
+		return escapeMarkdownText(s)
+	})
+}
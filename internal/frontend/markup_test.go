@@ -0,0 +1,125 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarkupToMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		name, filename string
+		lines          []string
+		want           []string
+	}{
+		{
+			name:     "asciidoc inline markup",
+			filename: "README.adoc",
+			lines: []string{
+				"A *bold* link:docs/intro.adoc[intro], a `*literal*` and <<install,Install>>.",
+				"image:https://ci.example.com/badge.svg[Build]",
+			},
+			want: []string{
+				"A **bold** [intro](docs/intro.adoc), a `*literal*` and Install.",
+				"![Build](https://ci.example.com/badge.svg)",
+			},
+		},
+		{
+			name:     "asciidoc comments and attributes",
+			filename: "README.asciidoc",
+			lines:    []string{":toc:", "// comment", "////", "block comment", "////", "NOTE: Text <here>."},
+			want:     []string{"**Note:** Text &lt;here>."},
+		},
+		{
+			name:     "rst section levels by first appearance",
+			filename: "README.rst",
+			lines:    []string{"#####", "Title", "#####", "", "One", "~~~", "", "Two", "^^^", "", "Three", "~~~~~"},
+			want: []string{
+				"", "# Title", "", "",
+				"", "## One", "", "",
+				"", "### Two", "", "",
+				"", "## Three", "",
+			},
+		},
+		{
+			name:     "rst substitutions and references",
+			filename: "README.rst",
+			lines: []string{
+				"|badge| See the Docs_, ``x <y>`` and :code:`z`.",
+				"",
+				".. |badge| image:: https://ci.example.com/badge.svg",
+				"   :alt: CI",
+				".. _docs: https://docs.example.com",
+			},
+			want: []string{
+				"![CI](https://ci.example.com/badge.svg) See the [Docs](https://docs.example.com), `x <y>` and `z`.",
+				"",
+			},
+		},
+		{
+			name:     "rst directives",
+			filename: "README.rst",
+			lines: []string{
+				".. contents::",
+				"   :depth: 2",
+				"",
+				".. code-block:: go",
+				"   :linenos:",
+				"",
+				"   fmt.Println()",
+				"",
+				".. warning:: Experimental.",
+			},
+			want: []string{
+				"```go", "fmt.Println()", "```", "",
+				"> **Warning**", ">", "> Experimental.", "",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := markupToMarkdown(test.filename, strings.Join(test.lines, "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, strings.Split(got, "\n")); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarkupToMarkdownErrors(t *testing.T) {
+	for _, test := range []struct {
+		filename, contents string
+	}{
+		{"README.adoc", "----\nunterminated listing"},
+		{"README.adoc", "////\nunterminated comment"},
+		{"README.rst", "=====\nTitle\n-----"},
+		{"README.txt", "not markup"},
+	} {
+		if _, err := markupToMarkdown(test.filename, test.contents); err == nil {
+			t.Errorf("markupToMarkdown(%q, %q): got nil error, want error", test.filename, test.contents)
+		}
+	}
+}
+
+func TestUpperFirst(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"note", "Note"},
+		{"Tip", "Tip"},
+		{"été", "Été"},
+		{"seealso", "Seealso"},
+	} {
+		if got := upperFirst(test.in); got != test.want {
+			t.Errorf("upperFirst(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
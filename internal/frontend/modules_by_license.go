@@ -0,0 +1,77 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// modulesByLicenseLimit is the number of modules listed on each page of
+// /licenses/<type>.
+const modulesByLicenseLimit = 100
+
+// ModulesByLicensePage contains the data needed to render the page listing
+// the modules that have a license of a given type.
+type ModulesByLicensePage struct {
+	basePage
+
+	// LicenseType is the license type, like "MIT".
+	LicenseType string
+
+	// Modules are the modules on this page.
+	Modules []*LicensedModule
+
+	// NextURL is the URL of the next page, or empty if this is the last one.
+	NextURL string
+}
+
+// LicensedModule is a module listed on a ModulesByLicensePage.
+type LicensedModule struct {
+	ModulePath     string
+	DisplayVersion string
+	URL            string
+}
+
+// serveModulesByLicense serves a page listing the modules that have a license
+// of the type at the end of the URL path, like /licenses/MIT. The "cursor"
+// query parameter selects pages after the first.
+func (s *Server) serveModulesByLicense(w http.ResponseWriter, r *http.Request, ds internal.DataSource) (err error) {
+	defer derrors.Wrap(&err, "serveModulesByLicense(%q)", r.URL.Path)
+
+	licenseType := strings.TrimPrefix(r.URL.Path, "/licenses/")
+	if licenseType == "" || strings.Contains(licenseType, "/") {
+		return &serverError{status: http.StatusNotFound}
+	}
+	ctx := r.Context()
+	modules, nextCursor, err := ds.GetModulesByLicenseType(ctx, licenseType, modulesByLicenseLimit, r.FormValue("cursor"))
+	if err != nil {
+		if errors.Is(err, derrors.InvalidArgument) {
+			return &serverError{status: http.StatusBadRequest, err: err}
+		}
+		return err
+	}
+	page := &ModulesByLicensePage{
+		basePage:    s.newBasePage(r, "Modules licensed under "+licenseType),
+		LicenseType: licenseType,
+	}
+	for _, m := range modules {
+		page.Modules = append(page.Modules, &LicensedModule{
+			ModulePath:     m.ModulePath,
+			DisplayVersion: displayVersion(m.Version, m.ModulePath),
+			URL:            constructUnitURL(m.ModulePath, m.ModulePath, m.Version),
+		})
+	}
+	if nextCursor != "" {
+		page.NextURL = r.URL.Path + "?" + url.Values{"cursor": {nextCursor}}.Encode()
+	}
+	s.servePage(ctx, w, "modules_by_license.tmpl", page)
+	return nil
+}
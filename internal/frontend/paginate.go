@@ -40,6 +40,27 @@ func (p pagination) PageURL(page int) string {
 	return p.baseURL.String()
 }
 
+// cursorPagination holds information for displaying results that are paginated
+// with opaque cursors rather than page numbers. It is intended to be part of a
+// view model struct.
+type cursorPagination struct {
+	baseURL     *url.URL // URL common to all pages
+	ResultCount int      // number of results on this page
+	TotalCount  int      // total number of results
+	PrevCursor  string   // cursor of the previous page, or empty on the first page
+	NextCursor  string   // cursor of the next page, or empty on the last page
+}
+
+// CursorURL constructs a URL that displays the page starting at the given
+// cursor. It sets the "cursor" query parameter of the base URL.
+func (p cursorPagination) CursorURL(cursor string) string {
+	newQuery := p.baseURL.Query()
+	newQuery.Set("cursor", cursor)
+	u := *p.baseURL
+	u.RawQuery = newQuery.Encode()
+	return u.String()
+}
+
 // newPagination constructs a pagination. Call it after some results have been
 // obtained.
 // resultCount is the number of results in the current page.
@@ -5,22 +5,44 @@
 package frontend
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go/format"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
+	"path"
 	"strconv"
 	"strings"
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/tools/txtar"
 )
 
-// playgroundURL is the playground endpoint used for share links.
-const playgroundURL = "https://play.golang.org"
+const (
+	// playgroundURL is the playground endpoint used for share links.
+	playgroundURL = "https://play.golang.org"
+
+	// maxPlaygroundShareSize is the maximum size, in bytes, of a snippet
+	// with several files that can be shared, which matches the limit
+	// enforced by the playground. Larger multi-file requests are rejected
+	// with a 413 (Request Entity Too Large) without being read further.
+	maxPlaygroundShareSize = 64 * 1024
+
+	// playgroundMainFile is the name the playground gives to the unnamed
+	// first file of a snippet.
+	playgroundMainFile = "prog.go"
+)
 
 var (
 	keyPlaygroundShareStatus = tag.MustNewKey("playground.share.status")
@@ -54,7 +76,28 @@ func makeFetchPlayRequest(w http.ResponseWriter, r *http.Request, pgURL string)
 		httpErrorStatus(w, http.StatusMethodNotAllowed)
 		return
 	}
-	req, err := http.NewRequest("POST", pgURL+"/share", r.Body)
+	// Read enough of the snippet to tell whether it has several files.
+	// Snippets with a single file are forwarded as they are.
+	prefix, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPlaygroundShareSize+1))
+	if err != nil {
+		log.Errorf(ctx, "ERROR share error: %v", err)
+		httpErrorStatus(w, http.StatusInternalServerError)
+		return
+	}
+	body := io.MultiReader(bytes.NewReader(prefix), r.Body)
+	if a := txtar.Parse(prefix); numPlaygroundFiles(a) > 1 {
+		if len(prefix) > maxPlaygroundShareSize {
+			httpErrorStatus(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+		encoded, err := encodePlaygroundFiles(a)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest("POST", pgURL+"/share", body)
 	if err != nil {
 		log.Errorf(ctx, "ERROR share error: %v", err)
 		httpErrorStatus(w, http.StatusInternalServerError)
@@ -86,6 +129,56 @@ func makeFetchPlayRequest(w http.ResponseWriter, r *http.Request, pgURL string)
 	}
 }
 
+// numPlaygroundFiles returns the number of files in a snippet. The text
+// before the first file marker counts as a file unless it is blank.
+func numPlaygroundFiles(a *txtar.Archive) int {
+	n := len(a.Files)
+	if len(bytes.TrimSpace(a.Comment)) > 0 {
+		n++
+	}
+	return n
+}
+
+// encodePlaygroundFiles returns the snippet with several files in a in the
+// form expected by the playground's share endpoint.
+//
+// Such a snippet is in txtar format, like
+//
+//	-- prog.go --
+//	package main
+//	...
+//	-- go.mod --
+//	module example
+//
+// The playground treats the text before the first file marker as prog.go, so
+// a prog.go section is moved there; the other files are kept in order.
+func encodePlaygroundFiles(a *txtar.Archive) ([]byte, error) {
+	var main []byte
+	if len(bytes.TrimSpace(a.Comment)) > 0 {
+		main = a.Comment
+	}
+	var others []txtar.File
+	seen := map[string]bool{}
+	if main != nil {
+		seen[playgroundMainFile] = true
+	}
+	for _, f := range a.Files {
+		if f.Name == "" || path.IsAbs(f.Name) || path.Clean(f.Name) != f.Name || strings.HasPrefix(f.Name, "../") {
+			return nil, fmt.Errorf("invalid file name %q", f.Name)
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("duplicate file %q", f.Name)
+		}
+		seen[f.Name] = true
+		if f.Name == playgroundMainFile {
+			main = f.Data
+			continue
+		}
+		others = append(others, f)
+	}
+	return txtar.Format(&txtar.Archive{Comment: main, Files: others}), nil
+}
+
 // proxyPlayground is a handler that proxies playground requests to play.golang.org.
 func (s *Server) proxyPlayground(w http.ResponseWriter, r *http.Request) {
 	makePlaygroundProxy().ServeHTTP(w, r)
@@ -114,10 +207,17 @@ func makePlaygroundProxy() *httputil.ReverseProxy {
 type fmtResponse struct {
 	Body  string
 	Error string
+
+	// Vet holds the problems found by vet in the formatted program, if vet
+	// was requested.
+	Vet []vetDiagnostic `json:",omitempty"`
 }
 
 // fmtHandler takes a Go program in its "body" form value, formats it with
 // standard gofmt formatting, and writes a fmtResponse as a JSON object.
+// If the "vet" form value is "true", the formatted program is also checked
+// with vet, against the version of the standard library in the "version"
+// form value, or the latest one if there is none.
 func (s *Server) handleFmt(w http.ResponseWriter, r *http.Request) {
 	resp := new(fmtResponse)
 	body, err := format.Source([]byte(r.FormValue("body")))
@@ -125,7 +225,47 @@ func (s *Server) handleFmt(w http.ResponseWriter, r *http.Request) {
 		resp.Error = err.Error()
 	} else {
 		resp.Body = string(body)
+		if r.FormValue("vet") == "true" {
+			resp.Vet, err = s.vetWithStdlib(r.Context(), body, r.FormValue("version"))
+			if err != nil {
+				resp.Error = err.Error()
+			}
+		}
 	}
 	w.Header().Set("Content-type", "application/json; charset=utf-8")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// vetWithStdlib runs vetSource on src against the standard library at
+// requestedVersion, which may be a Go tag like "go1.16", a semantic version,
+// or empty for the latest version. The version is resolved to one that has
+// been processed by the server's DataSource, and it must be one of the
+// versions preloaded for vet.
+func (s *Server) vetWithStdlib(ctx context.Context, src []byte, requestedVersion string) ([]vetDiagnostic, error) {
+	if s.maintenanceMode {
+		// Don't touch the DataSource; see errorHandler.
+		return nil, errors.New("vet is unavailable during maintenance")
+	}
+	switch {
+	case requestedVersion == "":
+		requestedVersion = internal.LatestVersion
+	case strings.HasPrefix(requestedVersion, "go"):
+		v := stdlib.VersionForTag(requestedVersion)
+		if v == "" {
+			return nil, fmt.Errorf("%w: invalid Go version %q", derrors.InvalidArgument, requestedVersion)
+		}
+		requestedVersion = v
+	}
+	um, err := s.getDataSource(ctx).GetUnitMeta(ctx, stdlib.ModulePath, stdlib.ModulePath, requestedVersion)
+	if err != nil {
+		return nil, err
+	}
+	var imp *stdImporter
+	if s.vetStdlib != nil {
+		imp = s.vetStdlib.importer(um.Version)
+	}
+	if imp == nil {
+		return nil, fmt.Errorf("vet is not available for the standard library at %s", um.Version)
+	}
+	return vetSource(ctx, src, imp)
+}
@@ -5,13 +5,25 @@
 package frontend
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
 )
 
 var playground = flag.Bool("playground", false, "Make a request to https://play.golang.org/")
@@ -109,3 +121,350 @@ func main() {
 		})
 	}
 }
+
+func TestPlaygroundShareMultipleFiles(t *testing.T) {
+	var gotBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotBody = string(b)
+		if _, err := io.WriteString(w, testShareID); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	const (
+		prog  = "package main\n\nfunc main() {}\n"
+		gomod = "module example.com/m\n"
+		util  = "package main\n\nfunc f() {}\n"
+	)
+	for _, test := range []struct {
+		desc, body string
+		code       int
+		want       string // body forwarded to the playground
+	}{
+		{
+			desc: "single file is unchanged",
+			body: prog,
+			code: http.StatusOK,
+			want: prog,
+		},
+		{
+			desc: "single named file is unchanged",
+			body: "-- prog.go --\n" + prog,
+			code: http.StatusOK,
+			want: "-- prog.go --\n" + prog,
+		},
+		{
+			desc: "main file first",
+			body: prog + "-- go.mod --\n" + gomod + "-- util.go --\n" + util,
+			code: http.StatusOK,
+			want: prog + "-- go.mod --\n" + gomod + "-- util.go --\n" + util,
+		},
+		{
+			desc: "named main file",
+			body: "-- go.mod --\n" + gomod + "-- prog.go --\n" + prog + "-- util.go --\n" + util,
+			code: http.StatusOK,
+			want: prog + "-- go.mod --\n" + gomod + "-- util.go --\n" + util,
+		},
+		{
+			desc: "duplicate main file",
+			body: prog + "-- prog.go --\n" + prog,
+			code: http.StatusBadRequest,
+		},
+		{
+			desc: "duplicate file",
+			body: prog + "-- go.mod --\n" + gomod + "-- go.mod --\n" + gomod,
+			code: http.StatusBadRequest,
+		},
+		{
+			desc: "invalid file name",
+			body: prog + "-- ../go.mod --\n" + gomod,
+			code: http.StatusBadRequest,
+		},
+		{
+			desc: "largest snippet",
+			body: prog + "-- go.mod --\n" + gomod + "//" + strings.Repeat("x", maxPlaygroundShareSize-len(prog)-len(gomod)-15),
+			code: http.StatusOK,
+			want: prog + "-- go.mod --\n" + gomod + "//" + strings.Repeat("x", maxPlaygroundShareSize-len(prog)-len(gomod)-15) + "\n",
+		},
+		{
+			desc: "snippet too large",
+			body: prog + "-- go.mod --\n" + gomod + "//" + strings.Repeat("x", maxPlaygroundShareSize-len(prog)-len(gomod)-14),
+			code: http.StatusRequestEntityTooLarge,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			gotBody = ""
+			req := httptest.NewRequest(http.MethodPost, "/play/share", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+			makeFetchPlayRequest(w, req, ts.URL)
+			if w.Code != test.code {
+				t.Fatalf("Status Code = %d; want %d", w.Code, test.code)
+			}
+			if gotBody != test.want {
+				t.Errorf("forwarded body:\n%s\nwant:\n%s", gotBody, test.want)
+			}
+			if test.code == http.StatusOK && w.Body.String() != testShareID {
+				t.Errorf("body = %s; want %s", w.Body.String(), testShareID)
+			}
+		})
+	}
+}
+
+func TestPlaygroundShareSingleFile(t *testing.T) {
+	type forwarded struct {
+		body             string
+		contentType      string
+		contentLength    int64
+		transferEncoding []string
+	}
+	var got forwarded
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = forwarded{string(b), r.Header.Get("Content-Type"), r.ContentLength, r.TransferEncoding}
+		if _, err := io.WriteString(w, testShareID); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	const prog = "package main\n\nfunc main() {}\n"
+	// Snippets with a single file are streamed to the playground exactly as
+	// they were received, whatever their size or contents.
+	for _, body := range []string{
+		prog,
+		"-- prog.go --\n" + prog,
+		"\n\n-- go.mod --\nmodule example.com/m\n",
+		"-- ../prog.go --\n" + prog,
+		"-- prog.go --",
+		prog + "//" + strings.Repeat("x", 2*maxPlaygroundShareSize),
+		"-- prog.go --\n" + prog + "//" + strings.Repeat("x", 2*maxPlaygroundShareSize),
+		"package main\n\n// \xff\xfe\r\n",
+	} {
+		got = forwarded{}
+		req := httptest.NewRequest(http.MethodPost, "/play/share", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		makeFetchPlayRequest(w, req, ts.URL)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%.40q: status code = %d; want %d", body, w.Code, http.StatusOK)
+		}
+		want := forwarded{body, "text/plain; charset=utf-8", -1, []string{"chunked"}}
+		if diff := cmp.Diff(want, got, cmp.AllowUnexported(forwarded{})); diff != "" {
+			t.Errorf("%.40q: forwarded request mismatch (-want +got):\n%s", body, diff)
+		}
+	}
+}
+
+// stdlibDataSource is a DataSource that only knows about the standard
+// library module, at the given versions. The last one is the latest.
+type stdlibDataSource struct {
+	internal.DataSource
+	versions []string
+}
+
+func (ds stdlibDataSource) GetUnitMeta(_ context.Context, path, _, requestedVersion string) (*internal.UnitMeta, error) {
+	if path != stdlib.ModulePath {
+		return nil, derrors.NotFound
+	}
+	if requestedVersion == internal.LatestVersion {
+		requestedVersion = ds.versions[len(ds.versions)-1]
+	}
+	for _, v := range ds.versions {
+		if v == requestedVersion {
+			return &internal.UnitMeta{
+				Path:       path,
+				ModuleInfo: internal.ModuleInfo{ModulePath: stdlib.ModulePath, Version: v},
+			}, nil
+		}
+	}
+	return nil, derrors.NotFound
+}
+
+const testStdlibFmt = `package fmt
+
+func Printf(format string, a ...interface{}) (n int, err error) { return 0, nil }
+`
+
+// useTestStdlib makes vet read the standard library from a zip containing a
+// stub of package fmt, at any version, and returns the versions that are
+// read.
+func useTestStdlib(t *testing.T) *[]string {
+	t.Helper()
+	var read []string
+	origZip := stdlibZip
+	t.Cleanup(func() { stdlibZip = origZip })
+	stdlibZip = func(v string) (*zip.Reader, error) {
+		read = append(read, v)
+		data, err := testhelper.ZipContents(map[string]string{"std@" + v + "/fmt/print.go": testStdlibFmt})
+		if err != nil {
+			return nil, err
+		}
+		return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	}
+	return &read
+}
+
+// testStdImporter returns an importer for the stub standard library of
+// useTestStdlib.
+func testStdImporter(t *testing.T) *stdImporter {
+	t.Helper()
+	useTestStdlib(t)
+	v := newVetStdlib([]string{"v1.15.2"})
+	v.preload(context.Background())
+	return v.importer("v1.15.2")
+}
+
+func TestHandleFmtVet(t *testing.T) {
+	const src = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Printf("%d\n", "hello")
+}
+`
+	read := useTestStdlib(t)
+	vs := newVetStdlib([]string{"go1.15.2", "not-a-version"})
+	vs.preload(context.Background())
+	ds := stdlibDataSource{versions: []string{"v1.14.0", "v1.15.2"}}
+	s := &Server{
+		getDataSource: func(context.Context) internal.DataSource { return ds },
+		vetStdlib:     vs,
+	}
+	for _, test := range []struct {
+		vet, version string
+		maintenance  bool
+		want         []vetDiagnostic
+		wantErr      bool
+	}{
+		{vet: "", want: nil},
+		{vet: "true", want: []vetDiagnostic{{
+			Line:    6,
+			Message: "Printf format %d has arg \"hello\" of wrong type string",
+		}}},
+		{vet: "true", version: "go1.15.2", want: []vetDiagnostic{{
+			Line:    6,
+			Message: "Printf format %d has arg \"hello\" of wrong type string",
+		}}},
+		// Processed, but not preloaded.
+		{vet: "true", version: "go1.14", wantErr: true},
+		// Not processed.
+		{vet: "true", version: "go1.13", wantErr: true},
+		{vet: "true", version: "go1.bad", wantErr: true},
+		// Formatting works during maintenance, but vet doesn't.
+		{vet: "", maintenance: true, want: nil},
+		{vet: "true", maintenance: true, wantErr: true},
+	} {
+		form := url.Values{"body": {src}}
+		if test.vet != "" {
+			form.Set("vet", test.vet)
+		}
+		if test.version != "" {
+			form.Set("version", test.version)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/fmt", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.maintenanceMode = test.maintenance
+		s.handleFmt(w, req)
+
+		var got fmtResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if (got.Error != "") != test.wantErr {
+			t.Fatalf("vet=%q, version=%q: got error %q, want error: %t", test.vet, test.version, got.Error, test.wantErr)
+		}
+		if got.Body != src {
+			t.Errorf("vet=%q, version=%q: got body %q, want %q", test.vet, test.version, got.Body, src)
+		}
+		if diff := cmp.Diff(test.want, got.Vet); diff != "" {
+			t.Errorf("vet=%q, version=%q: mismatch (-want +got):\n%s", test.vet, test.version, diff)
+		}
+	}
+	// The standard library is only read by preload, never while serving.
+	if want := []string{"v1.15.2"}; !cmp.Equal(*read, want) {
+		t.Errorf("read standard library at %v, want %v", *read, want)
+	}
+}
+
+func TestVetSourceLimits(t *testing.T) {
+	ctx := context.Background()
+	imp := testStdImporter(t)
+	big := []byte("package main\n\n// " + strings.Repeat("x", maxVetSourceSize) + "\n")
+	if _, err := vetSource(ctx, big, imp); err != errVetSourceTooLarge {
+		t.Errorf("large program: got error %v, want %v", err, errVetSourceTooLarge)
+	}
+
+	const src = `package main
+
+import "example.com/foo"
+
+func main() { foo.F() }
+`
+	diags, err := vetSource(ctx, []byte(src), imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) == 0 || diags[0].Line != 3 || !strings.Contains(diags[0].Message, "only standard library imports") {
+		t.Errorf("non-standard import: got %+v, want an error on line 3", diags)
+	}
+}
+
+func TestVetSourceCanceled(t *testing.T) {
+	imp := testStdImporter(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	const src = `package main
+
+import "fmt"
+
+func main() { fmt.Printf("%d", "x") }
+`
+	if _, err := vetSource(ctx, []byte(src), imp); err == nil {
+		t.Fatal("got no error, want one")
+	}
+	if _, err := imp.forContext(ctx).Import("fmt"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Import with canceled context: got error %v, want %v", err, context.Canceled)
+	}
+	// Packages are not kept when their import is abandoned.
+	diags, err := vetSource(context.Background(), []byte(src), imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "Printf format %d") {
+		t.Errorf("got %+v, want a Printf diagnostic", diags)
+	}
+}
+
+func TestVetSourceStdlibZip(t *testing.T) {
+	origUseTestData := stdlib.UseTestData
+	t.Cleanup(func() { stdlib.UseTestData = origUseTestData })
+	stdlib.UseTestData = true
+	vs := newVetStdlib([]string{"go1.12.5"})
+	vs.preload(context.Background())
+
+	const src = `package main
+
+import "errors"
+
+func main() {
+	errors.New("unused")
+}
+`
+	diags, err := vetSource(context.Background(), []byte(src), vs.importer("v1.12.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []vetDiagnostic{{Line: 6, Message: "result of errors.New call not used"}}
+	if diff := cmp.Diff(want, diags); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
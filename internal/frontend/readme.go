@@ -24,6 +24,7 @@ import (
 	"github.com/yuin/goldmark/util"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/source"
 )
 
@@ -69,13 +70,21 @@ func processReadme(readme *internal.Readme, sourceInfo *source.Info) (_ *Readme,
 	if readme == nil || readme.Contents == "" {
 		return &Readme{}, nil
 	}
-	if !isMarkdown(readme.Filepath) {
-		t := template.Must(template.New("").Parse(`<pre class="readme">{{.}}</pre>`))
-		h, err := t.ExecuteToHTML(readme.Contents)
+	contents := []byte(readme.Contents)
+	switch {
+	case isMarkdown(readme.Filepath):
+	case isMarkup(readme.Filepath):
+		// AsciiDoc and reStructuredText are converted to Markdown, so that they
+		// are rendered like Markdown READMEs. If that fails, they are shown as
+		// plain text.
+		md, err := markupToMarkdown(readme.Filepath, readme.Contents)
 		if err != nil {
-			return nil, err
+			log.Infof(context.Background(), "markupToMarkdown(%q): %v", readme.Filepath, err)
+			return preformattedReadme(readme.Contents)
 		}
-		return &Readme{HTML: h}, nil
+		contents = []byte(md)
+	default:
+		return preformattedReadme(readme.Contents)
 	}
 
 	// Sets priority value so that we always use our custom transformer
@@ -118,7 +127,6 @@ func processReadme(readme *internal.Readme, sourceInfo *source.Info) (_ *Readme,
 			util.Prioritized(newHTMLRenderer(sourceInfo, readme), 100),
 		),
 	)
-	contents := []byte(readme.Contents)
 	gdParser := gdMarkdown.Parser()
 	reader := gmtext.NewReader(contents)
 	pctx := parser.NewContext(parser.WithIDs(newIDs()))
@@ -136,6 +144,16 @@ func processReadme(readme *internal.Readme, sourceInfo *source.Info) (_ *Readme,
 	}, nil
 }
 
+// preformattedReadme returns a Readme that displays contents as plain text.
+func preformattedReadme(contents string) (*Readme, error) {
+	t := template.Must(template.New("").Parse(`<pre class="readme">{{.}}</pre>`))
+	h, err := t.ExecuteToHTML(contents)
+	if err != nil {
+		return nil, err
+	}
+	return &Readme{HTML: h}, nil
+}
+
 // sanitizeHTML sanitizes HTML from a bytes.Buffer so that it is safe.
 func sanitizeHTML(b *bytes.Buffer) safehtml.HTML {
 	p := bluemonday.UGCPolicy()
@@ -162,7 +162,7 @@ func TestReadme(t *testing.T) {
 			name: "not markdown readme",
 			unit: &internal.Unit{},
 			readme: &internal.Readme{
-				Filepath: "README.rst",
+				Filepath: "README.txt",
 				Contents: "This package collects pithy sayings.\n\n" +
 					"It's part of a demonstration of\n" +
 					"[package versioning in Go](https://research.swtch.com/vgo1).",
@@ -171,6 +171,63 @@ func TestReadme(t *testing.T) {
 				"It&#39;s part of a demonstration of\n[package versioning in Go](https://research.swtch.com/vgo1).</pre>",
 			wantOutline: nil,
 		},
+		{
+			name: "asciidoc readme",
+			unit: &internal.Unit{},
+			readme: &internal.Readme{
+				Filepath: "README.adoc",
+				Contents: "= Sayings\n:toc:\n\n" +
+					"This package collects *pithy* sayings.\n\n" +
+					"== Usage\n\n" +
+					"[source,go]\n----\nfmt.Println(quote.Go())\n----\n\n" +
+					"* See https://research.swtch.com/vgo1[package versioning in Go].",
+			},
+			wantHTML: `<h3 class="h1" id="readme-sayings">Sayings</h3>` + "\n" +
+				"<p>This package collects <strong>pithy</strong> sayings.</p>\n" +
+				`<h4 class="h2" id="readme-usage">Usage</h4>` + "\n" +
+				"<pre><code>fmt.Println(quote.Go())\n</code></pre>\n" +
+				"<ul>\n" +
+				`<li>See <a href="https://research.swtch.com/vgo1" rel="nofollow">package versioning in Go</a>.</li>` + "\n" +
+				"</ul>",
+			wantOutline: []*Heading{
+				{Level: 1, Text: "Sayings", ID: "readme-sayings"},
+				{Level: 2, Text: "Usage", ID: "readme-usage"},
+			},
+		},
+		{
+			name: "restructuredtext readme",
+			unit: &internal.Unit{},
+			readme: &internal.Readme{
+				Filepath: "README.rst",
+				Contents: "Sayings\n=======\n\n" +
+					"This package collects **pithy** sayings.\n\n" +
+					"Usage\n-----\n\n" +
+					"Print a saying::\n\n    fmt.Println(quote.Go())\n\n" +
+					"- See `package versioning in Go <https://research.swtch.com/vgo1>`_.",
+			},
+			wantHTML: `<h3 class="h1" id="readme-sayings">Sayings</h3>` + "\n" +
+				"<p>This package collects <strong>pithy</strong> sayings.</p>\n" +
+				`<h4 class="h2" id="readme-usage">Usage</h4>` + "\n" +
+				"<p>Print a saying:</p>\n" +
+				"<pre><code>fmt.Println(quote.Go())\n</code></pre>\n" +
+				"<ul>\n" +
+				`<li>See <a href="https://research.swtch.com/vgo1" rel="nofollow">package versioning in Go</a>.</li>` + "\n" +
+				"</ul>",
+			wantOutline: []*Heading{
+				{Level: 1, Text: "Sayings", ID: "readme-sayings"},
+				{Level: 2, Text: "Usage", ID: "readme-usage"},
+			},
+		},
+		{
+			name: "malformed asciidoc readme",
+			unit: &internal.Unit{},
+			readme: &internal.Readme{
+				Filepath: "README.adoc",
+				Contents: "Example:\n\n----\nfmt.Println(<unterminated>)",
+			},
+			wantHTML:    "<pre class=\"readme\">Example:\n\n----\nfmt.Println(&lt;unterminated&gt;)</pre>",
+			wantOutline: nil,
+		},
 		{
 			name:        "empty readme",
 			unit:        &internal.Unit{},
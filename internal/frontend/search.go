@@ -29,7 +29,10 @@ const defaultSearchLimit = 10
 type SearchPage struct {
 	basePage
 	Pagination pagination
-	Results    []*SearchResult
+	// CursorPagination is used instead of Pagination for documentation
+	// search, whose pages are selected by cursors.
+	CursorPagination *cursorPagination
+	Results          []*SearchResult
 }
 
 // SearchResult contains data needed to display a single search result.
@@ -43,6 +46,10 @@ type SearchResult struct {
 	CommitTime     string
 	NumImportedBy  int
 	Approximate    bool
+
+	// Snippet is the text of the synopsis or README that matched a
+	// documentation search.
+	Snippet []*internal.SnippetSegment
 }
 
 // fetchSearchPage fetches data matching the search query from the database and
@@ -90,6 +97,43 @@ func fetchSearchPage(ctx context.Context, db *postgres.DB, query string, pagePar
 	}, nil
 }
 
+// fetchDocSearchPage fetches the packages whose documentation matches the
+// search query from the database and returns a SearchPage. The page starts at
+// the given cursor, which is empty for the first page.
+func fetchDocSearchPage(ctx context.Context, db *postgres.DB, query, cursor string, pageParams paginationParams) (*SearchPage, error) {
+	sr, err := db.SearchDocumentation(ctx, query, pageParams.limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+	var results []*SearchResult
+	for _, r := range sr.Results {
+		results = append(results, &SearchResult{
+			Name:           r.Name,
+			PackagePath:    r.PackagePath,
+			ModulePath:     r.ModulePath,
+			Synopsis:       r.Synopsis,
+			DisplayVersion: displayVersion(r.Version, r.ModulePath),
+			Licenses:       r.Licenses,
+			CommitTime:     elapsedTime(r.CommitTime),
+			NumImportedBy:  int(r.NumImportedBy),
+			Snippet:        r.Snippet,
+		})
+	}
+	cp := &cursorPagination{
+		baseURL:     pageParams.baseURL,
+		ResultCount: len(results),
+		PrevCursor:  sr.PrevCursor,
+		NextCursor:  sr.NextCursor,
+	}
+	if len(sr.Results) > 0 {
+		cp.TotalCount = int(sr.Results[0].NumResults)
+	}
+	return &SearchPage{
+		Results:          results,
+		CursorPagination: cp,
+	}, nil
+}
+
 // approximateNumber returns an approximation of the estimate, calibrated by
 // the statistical estimate of standard error.
 // i.e., a number that isn't misleading when we say '1-10 of approximately N
@@ -119,9 +163,17 @@ const (
 	maxSearchPageSize = 100
 )
 
+// searchModeDocs is the value of the "m" query parameter that selects a
+// full-text search of package synopses and READMEs.
+const searchModeDocs = "docs"
+
 // serveSearch applies database data to the search template. Handles endpoint
 // /search?q=<query>. If <query> is an exact match for a package path, the user
 // will be redirected to the details page.
+//
+// With /search?q=<query>&m=docs, only the synopses and READMEs of packages
+// are searched, and each result shows the text that matched. Those results are
+// paged with the cursor query parameter rather than page.
 func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		return &serverError{status: http.StatusMethodNotAllowed}
@@ -170,6 +222,18 @@ func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request, ds internal
 		}
 	}
 
+	if r.FormValue("m") == searchModeDocs {
+		page, err := fetchDocSearchPage(ctx, db, query, r.FormValue("cursor"), pageParams)
+		if err != nil {
+			if errors.Is(err, derrors.InvalidArgument) {
+				return &serverError{status: http.StatusBadRequest, err: err}
+			}
+			return fmt.Errorf("fetchDocSearchPage(ctx, db, %q): %v", query, err)
+		}
+		page.basePage = s.newBasePage(r, fmt.Sprintf("%s - Documentation Search Results", query))
+		s.servePage(ctx, w, "search.tmpl", page)
+		return nil
+	}
 	if path := searchRequestRedirectPath(ctx, ds, query); path != "" {
 		http.Redirect(w, r, path, http.StatusFound)
 		return nil
@@ -6,6 +6,9 @@ package frontend
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -175,6 +178,33 @@ func TestFetchSearchPage(t *testing.T) {
 	}
 }
 
+func TestServeDocSearch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	m := sample.Module("example.com/parse", sample.VersionString, "pkg")
+	for _, u := range m.Units {
+		for _, d := range u.Documentation {
+			d.Synopsis = "Package pkg is a streaming parser."
+		}
+	}
+	postgres.MustInsertModule(ctx, t, testDB, m)
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/search?q=parser&m=docs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	for _, want := range []string{`href="/example.com/parse/pkg"`, "<b>parser</b>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q", want)
+		}
+	}
+}
+
 func TestApproximateNumber(t *testing.T) {
 	tests := []struct {
 		estimate int
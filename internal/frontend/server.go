@@ -20,7 +20,9 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/google/safehtml"
 	"github.com/google/safehtml/template"
+	"github.com/google/safehtml/uncheckedconversions"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
@@ -34,7 +36,8 @@ import (
 
 // Server can be installed to serve the go discovery frontend.
 type Server struct {
-	// getDataSource should never be called from a handler. It is called only in Server.errorHandler.
+	// getDataSource should never be called from a handler. It is called only
+	// in Server.errorHandler, and by handleFmt when vet is requested.
 	getDataSource func(context.Context) internal.DataSource
 	queue         queue.Queue
 	// cmplClient is a redis client that has access to the "completions" sorted
@@ -42,6 +45,8 @@ type Server struct {
 	cmplClient           *redis.Client
 	taskIDChangeInterval time.Duration
 	staticPath           template.TrustedSource
+	staticCDNURL         string
+	canonicalURL         string
 	thirdPartyPath       string
 	templateDir          template.TrustedSource
 	devMode              bool
@@ -49,7 +54,25 @@ type Server struct {
 	appVersionLabel      string
 	googleTagManagerID   string
 	serveStats           bool
+	serveAPI             bool
 	reportingClient      *errorreporting.Client
+	labelUnstableV0      bool
+	maintenanceMode      bool
+	importedByLimit      int
+	apiImportedByLimit   int
+	showInternalPackages bool
+	// apiCache caches the results of expensive API requests. It is nil if
+	// there is no redis client.
+	apiCache *cache.Cache
+	// detailsCache caches the main tab details of units. It is nil if there
+	// is no redis client.
+	detailsCache *cache.Cache
+	// subtreeRoots are the patterns registered by Install that end in a
+	// slash.
+	subtreeRoots []string
+	// vetStdlib holds the standard library versions that /play/fmt can vet
+	// against.
+	vetStdlib *vetStdlib
 
 	mu        sync.Mutex // Protects all fields below
 	templates map[string]*template.Template
@@ -64,19 +87,54 @@ type ServerConfig struct {
 	CompletionClient     *redis.Client
 	TaskIDChangeInterval time.Duration
 	StaticPath           template.TrustedSource
+	StaticCDNURL         string
 	ThirdPartyPath       string
 	DevMode              bool
 	AppVersionLabel      string
 	GoogleTagManagerID   string
 	ServeStats           bool
-	ReportingClient      *errorreporting.Client
+	// ServeAPI enables the JSON API under /api/. When it is false, the API
+	// endpoints respond with 404 Not Found.
+	ServeAPI        bool
+	ReportingClient *errorreporting.Client
+	// LabelUnstableV0 enables the "v0 (unstable)" label on unit pages at v0
+	// versions. See unstableV0.
+	LabelUnstableV0 bool
+	// MaintenanceMode makes the server serve pages that need a DataSource
+	// only from the cache, and a maintenance page when they are not cached.
+	// It is meant for times when the database is unavailable, like during
+	// migrations.
+	MaintenanceMode bool
+	// ImportedByLimit is the maximum number of importers shown on the
+	// imported by tab. If zero, defaultImportedByLimit is used.
+	ImportedByLimit int
+	// APIImportedByLimit is the maximum number of importers served by
+	// /api/imported-by, whatever the request asks for. If zero,
+	// defaultAPIImportedByLimit is used.
+	APIImportedByLimit int
+	// ShowInternalPackages makes unit pages list nested internal
+	// directories, and makes the imported by tab of an internal package show
+	// the packages of its own module that import it.
+	ShowInternalPackages bool
+	// CanonicalURL is the base URL of the site, like "https://pkg.go.dev",
+	// used for absolute links in sitemaps and feeds. If empty,
+	// defaultCanonicalURL is used.
+	CanonicalURL string
+	// VetStdlibVersions are the versions of the standard library, like
+	// "go1.16", that /play/fmt can vet programs against. They are read in
+	// the background when the server starts. If empty, vet is unavailable.
+	VetStdlibVersions []string
 }
 
+// defaultCanonicalURL is the base URL of the site's links when none is
+// configured.
+const defaultCanonicalURL = "https://pkg.go.dev"
+
 // NewServer creates a new Server for the given database and template directory.
 func NewServer(scfg ServerConfig) (_ *Server, err error) {
 	defer derrors.Wrap(&err, "NewServer(...)")
 	templateDir := template.TrustedSourceJoin(scfg.StaticPath, template.TrustedSourceFromConstant("html"))
-	ts, err := parsePageTemplates(templateDir)
+	ts, err := parsePageTemplates(templateDir, scfg.StaticCDNURL)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing templates: %v", err)
 	}
@@ -87,6 +145,8 @@ func NewServer(scfg ServerConfig) (_ *Server, err error) {
 		queue:                scfg.Queue,
 		cmplClient:           scfg.CompletionClient,
 		staticPath:           scfg.StaticPath,
+		staticCDNURL:         scfg.StaticCDNURL,
+		canonicalURL:         strings.TrimSuffix(scfg.CanonicalURL, "/"),
 		thirdPartyPath:       scfg.ThirdPartyPath,
 		templateDir:          templateDir,
 		devMode:              scfg.DevMode,
@@ -95,13 +155,30 @@ func NewServer(scfg ServerConfig) (_ *Server, err error) {
 		appVersionLabel:      scfg.AppVersionLabel,
 		googleTagManagerID:   scfg.GoogleTagManagerID,
 		serveStats:           scfg.ServeStats,
+		serveAPI:             scfg.ServeAPI,
 		reportingClient:      scfg.ReportingClient,
+		labelUnstableV0:      scfg.LabelUnstableV0,
+		maintenanceMode:      scfg.MaintenanceMode,
+		importedByLimit:      scfg.ImportedByLimit,
+		apiImportedByLimit:   scfg.APIImportedByLimit,
+		showInternalPackages: scfg.ShowInternalPackages,
+		vetStdlib:            newVetStdlib(scfg.VetStdlibVersions),
+	}
+	if s.canonicalURL == "" {
+		s.canonicalURL = defaultCanonicalURL
+	}
+	if s.importedByLimit <= 0 {
+		s.importedByLimit = defaultImportedByLimit
+	}
+	if s.apiImportedByLimit <= 0 {
+		s.apiImportedByLimit = defaultAPIImportedByLimit
 	}
-	errorPageBytes, err := s.renderErrorPage(context.Background(), http.StatusInternalServerError, "error.tmpl", nil)
+	errorPageBytes, err := s.renderErrorPage(context.Background(), http.StatusInternalServerError, "error.tmpl", preRenderedErrorPage())
 	if err != nil {
 		return nil, fmt.Errorf("s.renderErrorPage(http.StatusInternalServerError, nil): %v", err)
 	}
 	s.errorPage = errorPageBytes
+	go s.vetStdlib.preload(context.Background())
 	return s, nil
 }
 
@@ -109,14 +186,24 @@ func NewServer(scfg ServerConfig) (_ *Server, err error) {
 // authValues is the set of values that can be set on authHeader to bypass the
 // cache.
 func (s *Server) Install(handle func(string, http.Handler), redisClient *redis.Client, authValues []string) {
+	register := handle
+	handle = func(pattern string, h http.Handler) {
+		if pattern != "/" && strings.HasSuffix(pattern, "/") {
+			s.subtreeRoots = append(s.subtreeRoots, pattern)
+		}
+		register(pattern, h)
+	}
 	var (
-		detailHandler http.Handler = s.errorHandler(s.serveDetails)
-		fetchHandler  http.Handler = s.errorHandler(s.serveFetch)
-		searchHandler http.Handler = s.errorHandler(s.serveSearch)
+		detailHandler  http.Handler = s.errorHandler(s.serveDetails)
+		fetchHandler   http.Handler = s.errorHandler(s.serveFetch)
+		searchHandler  http.Handler = s.errorHandler(s.serveSearch)
+		sitemapHandler http.Handler = s.errorHandler(s.serveSitemap)
 	)
 	if redisClient != nil {
+		s.detailsCache = cache.New(redisClient)
 		detailHandler = middleware.Cache("details", redisClient, detailsTTL, authValues)(detailHandler)
 		searchHandler = middleware.Cache("search", redisClient, middleware.TTL(defaultTTL), authValues)(searchHandler)
+		sitemapHandler = middleware.Cache("sitemap", redisClient, middleware.TTL(sitemapTTL), authValues)(sitemapHandler)
 	}
 	// Each AppEngine instance is created in response to a start request, which
 	// is an empty HTTP GET request to /_ah/start when scaling is set to manual
@@ -145,14 +232,17 @@ func (s *Server) Install(handle func(string, http.Handler), redisClient *redis.C
 	handle("/search", searchHandler)
 	handle("/search-help", s.staticPageHandler("search_help.tmpl", "Search Help"))
 	handle("/license-policy", s.licensePolicyHandler())
+	handle("/licenses/", s.errorHandler(s.serveModulesByLicense))
 	handle("/about", http.RedirectHandler("https://go.dev/about", http.StatusFound))
 	handle("/badge/", http.HandlerFunc(s.badgeHandler))
+	handle("/sitemap.xml", sitemapContentTypeHandler(sitemapHandler))
 	handle("/C", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Package "C" is a special case: redirect to /cmd/cgo.
 		// (This is what golang.org/C does.)
 		http.Redirect(w, r, "/cmd/cgo", http.StatusMovedPermanently)
 	}))
 	handle("/", detailHandler)
+	s.installAPI(handle, redisClient)
 	if s.serveStats {
 		handle("/detail-stats/",
 			middleware.Stats()(http.StripPrefix("/detail-stats", s.errorHandler(s.serveDetails))))
@@ -166,6 +256,13 @@ Disallow: /fetch/*
 	}))
 }
 
+// SubtreeRoots returns the patterns registered by Install that match a whole
+// subtree of paths, like /badge/. An http.ServeMux redirects a request for
+// such a pattern without its trailing slash to the pattern.
+func (s *Server) SubtreeRoots() []string {
+	return s.subtreeRoots
+}
+
 const (
 	// defaultTTL is used when details tab contents are subject to change, or when
 	// there is a problem confirming that the details can be permanently cached.
@@ -265,6 +362,14 @@ type basePage struct {
 	// AllowWideContent indicates whether the content should be displayed in a
 	// way that’s amenable to wider viewports.
 	AllowWideContent bool
+
+	// MaintenanceMode indicates whether the server is in maintenance mode, in
+	// which case a banner says so.
+	MaintenanceMode bool
+
+	// Nonce is the content-security-policy nonce for the inline scripts of
+	// the page. See middleware.CSPNonce.
+	Nonce string
 }
 
 // licensePolicyPage is used to generate the static license policy page.
@@ -295,9 +400,18 @@ func (s *Server) newBasePage(r *http.Request, title string) basePage {
 		DevMode:            s.devMode,
 		AppVersionLabel:    s.appVersionLabel,
 		GoogleTagManagerID: s.googleTagManagerID,
+		MaintenanceMode:    s.maintenanceMode,
+		Nonce:              middleware.CSPNonce(r.Context()),
 	}
 }
 
+// preRenderedErrorPage returns the page for an error page that is rendered
+// once, when the server starts. Its nonce must be substituted when it is
+// served; see middleware.SubstituteCSPNonce.
+func preRenderedErrorPage() *errorPage {
+	return &errorPage{basePage: basePage{Nonce: middleware.CSPNoncePlaceholder}}
+}
+
 // errorPage contains fields for rendering a HTTP error page.
 type errorPage struct {
 	basePage
@@ -312,13 +426,13 @@ type errorPage struct {
 func (s *Server) PanicHandler() (_ http.HandlerFunc, err error) {
 	defer derrors.Wrap(&err, "PanicHandler")
 	status := http.StatusInternalServerError
-	buf, err := s.renderErrorPage(context.Background(), status, "error.tmpl", nil)
+	buf, err := s.renderErrorPage(context.Background(), status, "error.tmpl", preRenderedErrorPage())
 	if err != nil {
 		return nil, err
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(status)
-		if _, err := io.Copy(w, bytes.NewReader(buf)); err != nil {
+		if _, err := w.Write(middleware.SubstituteCSPNonce(r.Context(), buf)); err != nil {
 			log.Errorf(r.Context(), "Error copying panic template to ResponseWriter: %v", err)
 		}
 	}, nil
@@ -341,6 +455,13 @@ func (s *serverError) Unwrap() error {
 
 func (s *Server) errorHandler(f func(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if s.maintenanceMode {
+			// Don't touch the DataSource. Pages that were cached are served
+			// by the cache middleware before reaching this handler. This also
+			// keeps serveUnitPage from scheduling fetches.
+			s.serveMaintenancePage(w, r)
+			return
+		}
 		// Obtain a DataSource to use for this request.
 		ds := s.getDataSource(r.Context())
 		if err := f(w, r, ds); err != nil {
@@ -349,6 +470,20 @@ func (s *Server) errorHandler(f func(w http.ResponseWriter, r *http.Request, ds
 	}
 }
 
+// serveMaintenancePage responds with 503 Service Unavailable and a page
+// explaining that the site is undergoing maintenance.
+func (s *Server) serveMaintenancePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		http.Error(w, "pkg.go.dev is undergoing maintenance", http.StatusServiceUnavailable)
+		return
+	}
+	s.serveErrorPage(w, r, http.StatusServiceUnavailable, &errorPage{
+		messageTemplate: template.MakeTrustedTemplate(`
+			<h3 class="Error-message">pkg.go.dev is undergoing maintenance.</h3>
+			<p class="Error-message">This page is temporarily unavailable. Please try again later.</p>`),
+	})
+}
+
 func (s *Server) serveError(w http.ResponseWriter, r *http.Request, err error) {
 	ctx := r.Context()
 	var serr *serverError
@@ -410,7 +545,7 @@ func (s *Server) serveErrorPage(w http.ResponseWriter, r *http.Request, status i
 	buf, err := s.renderErrorPage(r.Context(), status, template, page)
 	if err != nil {
 		log.Errorf(r.Context(), "s.renderErrorPage(w, %d, %v): %v", status, page, err)
-		buf = s.errorPage
+		buf = middleware.SubstituteCSPNonce(r.Context(), s.errorPage)
 		status = http.StatusInternalServerError
 	}
 
@@ -463,7 +598,7 @@ func (s *Server) servePage(ctx context.Context, w http.ResponseWriter, templateN
 	if err != nil {
 		log.Errorf(ctx, "s.renderPage(%q, %+v): %v", templateName, page, err)
 		w.WriteHeader(http.StatusInternalServerError)
-		buf = s.errorPage
+		buf = middleware.SubstituteCSPNonce(ctx, s.errorPage)
 	}
 	if _, err := io.Copy(w, bytes.NewReader(buf)); err != nil {
 		log.Errorf(ctx, "Error copying template %q buffer to ResponseWriter: %v", templateName, err)
@@ -487,7 +622,7 @@ func (s *Server) findTemplate(templateName string) (*template.Template, error) {
 		s.mu.Lock()
 		defer s.mu.Unlock()
 		var err error
-		s.templates, err = parsePageTemplates(s.templateDir)
+		s.templates, err = parsePageTemplates(s.templateDir, s.staticCDNURL)
 		if err != nil {
 			return nil, fmt.Errorf("error parsing templates: %v", err)
 		}
@@ -519,6 +654,26 @@ var templateFuncs = template.FuncMap{
 	"commaseparate": func(s []string) string {
 		return strings.Join(s, ", ")
 	},
+	"staticURL": staticURLFunc(""),
+}
+
+// staticURLFunc returns a template function that maps a path relative to the
+// static directory to the URL it is served from. If cdnURL is non-empty, the
+// URL is on the CDN; otherwise it is a same-origin /static/ path.
+//
+// The function takes an optional version, which is added to the URL as a
+// query parameter to bust browser caches when the app is redeployed.
+func staticURLFunc(cdnURL string) func(string, ...string) safehtml.TrustedResourceURL {
+	prefix := strings.TrimSuffix(cdnURL, "/") + "/static/"
+	return func(p string, version ...string) safehtml.TrustedResourceURL {
+		// The prefix comes from server configuration, and templates only call
+		// staticURL with constant paths.
+		u := uncheckedconversions.TrustedResourceURLFromStringKnownToSatisfyTypeContract(prefix + p)
+		if len(version) > 0 {
+			u = safehtml.TrustedResourceURLWithParams(u, map[string]string{"version": version[0]})
+		}
+		return u
+	}
 }
 
 // parsePageTemplates parses html templates contained in the given base
@@ -526,16 +681,21 @@ var templateFuncs = template.FuncMap{
 //
 // Separate templates are used so that certain contextual functions (e.g.
 // templateName) can be bound independently for each page.
-func parsePageTemplates(base template.TrustedSource) (map[string]*template.Template, error) {
+//
+// Static asset URLs in the templates are prefixed with staticCDNURL, if it is
+// non-empty.
+func parsePageTemplates(base template.TrustedSource, staticCDNURL string) (map[string]*template.Template, error) {
 	tsc := template.TrustedSourceFromConstant
 	join := template.TrustedSourceJoin
 
 	htmlSets := [][]template.TrustedSource{
+		{tsc("api_diff.tmpl")},
 		{tsc("badge.tmpl")},
 		{tsc("error.tmpl")},
 		{tsc("fetch.tmpl")},
 		{tsc("index.tmpl")},
 		{tsc("license_policy.tmpl")},
+		{tsc("modules_by_license.tmpl")},
 		{tsc("search.tmpl")},
 		{tsc("search_help.tmpl")},
 		{tsc("unit_details.tmpl"), tsc("unit.tmpl")},
@@ -545,9 +705,10 @@ func parsePageTemplates(base template.TrustedSource) (map[string]*template.Templ
 		{tsc("unit_versions.tmpl"), tsc("unit.tmpl")},
 	}
 
+	funcs := template.FuncMap{"staticURL": staticURLFunc(staticCDNURL)}
 	templates := make(map[string]*template.Template)
 	for _, set := range htmlSets {
-		t, err := template.New("base.tmpl").Funcs(templateFuncs).ParseFilesFromTrustedSources(join(base, tsc("base.tmpl")))
+		t, err := template.New("base.tmpl").Funcs(templateFuncs).Funcs(funcs).ParseFilesFromTrustedSources(join(base, tsc("base.tmpl")))
 		if err != nil {
 			return nil, fmt.Errorf("ParseFiles: %v", err)
 		}
@@ -6,6 +6,7 @@ package frontend
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -19,18 +20,23 @@ import (
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/safehtml/template"
 	"github.com/jba/templatecheck"
 	"golang.org/x/net/html"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/cookie"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/godoc"
 	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/queue"
 	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/symbol"
 	"golang.org/x/pkgsite/internal/testing/htmlcheck"
 	"golang.org/x/pkgsite/internal/testing/pagecheck"
 	"golang.org/x/pkgsite/internal/testing/sample"
@@ -53,13 +59,17 @@ func TestHTMLInjection(t *testing.T) {
 	}
 }
 
-const pseudoVersion = "v0.0.0-20140414041502-123456789012"
+const (
+	pseudoVersion    = "v0.0.0-20140414041502-123456789012"
+	pseudoCommitHash = "1234567890123456789012345678901234567890"
+)
 
 type testModule struct {
 	path            string
 	redistributable bool
 	versions        []string
 	packages        []testPackage
+	commitHash      string
 }
 
 type testPackage struct {
@@ -68,6 +78,13 @@ type testPackage struct {
 	readmeContents string
 	readmeFilePath string
 	docs           []*internal.Documentation
+	// supportedBuildContexts, if non-nil, are the only build contexts the
+	// package builds in.
+	supportedBuildContexts []internal.BuildContext
+	usesCgo                bool
+	label                  string
+	// files, if non-nil, are the files recorded for the package.
+	files []string
 }
 
 type serverTestCase struct {
@@ -175,6 +192,7 @@ var testModules = []testModule{
 		path:            "github.com/pseudo",
 		redistributable: true,
 		versions:        []string{pseudoVersion},
+		commitHash:      pseudoCommitHash,
 		packages: []testPackage{
 			{
 				suffix: "dir/baz",
@@ -287,7 +305,8 @@ var testModules = []testModule{
 			{name: "blog", suffix: "blog"},
 		},
 	},
-	// A module with a package that has documentation for two build contexts.
+	// A module with a package that has documentation for two build contexts,
+	// only builds in those, has different imports in each, and uses cgo.
 	{
 		path:            "a.com/two",
 		redistributable: true,
@@ -297,9 +316,16 @@ var testModules = []testModule{
 				name:   "pkg",
 				suffix: "pkg",
 				docs: []*internal.Documentation{
-					sample.Documentation("linux", "amd64", `package p; var L int`),
-					sample.Documentation("windows", "amd64", `package p; var W int`),
+					sample.Documentation("linux", "amd64", `package p; import ("os"; "syscall"); var L int`),
+					sample.Documentation("windows", "amd64", `package p; import ("os"; "golang.org/x/sys/windows"); var W int`),
+				},
+				supportedBuildContexts: []internal.BuildContext{
+					internal.BuildContextLinux,
+					internal.BuildContextWindows,
 				},
+				usesCgo: true,
+				label:   "test label",
+				files:   []string{"pkg_linux.go", "pkg_windows.go"},
 			},
 		},
 	},
@@ -319,6 +345,7 @@ func insertTestModules(ctx context.Context, t *testing.T, mods []testModule) {
 			m := sample.Module(mod.path, ver, suffixes...)
 			m.SourceInfo = source.NewGitHubInfo(sample.RepositoryURL, "", ver)
 			m.IsRedistributable = mod.redistributable
+			m.CommitHash = mod.commitHash
 			if !m.IsRedistributable {
 				m.Licenses = nil
 			}
@@ -336,6 +363,10 @@ func insertTestModules(ctx context.Context, t *testing.T, mods []testModule) {
 					if pkg.docs != nil {
 						u.Documentation = pkg.docs
 					}
+					u.SupportedBuildContexts = pkg.supportedBuildContexts
+					u.UsesCgo = pkg.usesCgo
+					u.Label = pkg.label
+					u.Files = pkg.files
 				}
 				if !mod.redistributable {
 					u.IsRedistributable = false
@@ -914,7 +945,10 @@ func serverTestCases() []serverTestCase {
 			urlPath:        fmt.Sprintf("/%s@%s/%s", sample.ModulePath, pseudoVersion, sample.Suffix),
 			wantStatusCode: http.StatusOK,
 			want: in("",
-				pagecheck.UnitHeader(pkgPseudo, versioned, isPackage)),
+				pagecheck.UnitHeader(pkgPseudo, versioned, isPackage),
+				// No hash is stored, so the one in the version is shown.
+				in(`[data-test-id="UnitHeader-commitHash"]`,
+					htmlcheck.HasExactTextCollapsed("Commit 123456789012"))),
 		},
 		{
 			name:           "stdlib no shortcut (net/http)",
@@ -958,6 +992,8 @@ func serverTestCases() []serverTestCase {
 			wantStatusCode: http.StatusOK,
 			want: in("",
 				pagecheck.UnitHeader(dirPseudo, versioned, isDirectory),
+				in(`[data-test-id="UnitHeader-commitHash"]`,
+					htmlcheck.HasExactTextCollapsed("Commit "+pseudoCommitHash)),
 				pagecheck.UnitDirectories("/github.com/pseudo@"+pseudoVersion+"/dir/baz", "baz")),
 		},
 		{
@@ -1019,7 +1055,10 @@ func serverTestCases() []serverTestCase {
 			urlPath:        "/cloud.google.com/go/pubsublite@v0.4.0",
 			wantStatusCode: http.StatusOK,
 			want: in("",
-				pagecheck.UnitHeader(pubsubliteMod, versioned, isPackage)),
+				pagecheck.UnitHeader(pubsubliteMod, versioned, isPackage),
+				notIn(".UnitDoc-buildConstraints"),
+				notIn(".UnitDoc-cgo"),
+				notIn(".UnitDoc-label")),
 		},
 		{
 			name:           "pubsublite directory",
@@ -1041,7 +1080,8 @@ func serverTestCases() []serverTestCase {
 			wantStatusCode: http.StatusOK,
 			want: in("",
 				in(".Documentation-variables", hasText("var L")),
-				in(".UnitBuildContext-titleContext", hasText("linux/amd64"))),
+				in(".UnitBuildContext-titleContext", hasText("linux/amd64")),
+				pagecheck.CanonicalURLPath("/a.com/two@v1.2.3/pkg")),
 		},
 		{
 			name:           "two docs linux",
@@ -1049,7 +1089,15 @@ func serverTestCases() []serverTestCase {
 			wantStatusCode: http.StatusOK,
 			want: in("",
 				in(".Documentation-variables", hasText("var L")),
-				in(".UnitBuildContext-titleContext", hasText("linux/amd64"))),
+				in(".UnitBuildContext-titleContext", hasText("linux/amd64")),
+				in(".UnitDoc-buildConstraints",
+					htmlcheck.HasExactTextCollapsed("Of the platforms checked, this package only builds on: linux/amd64, windows/amd64.")),
+				in(".UnitDoc-cgo",
+					htmlcheck.HasExactTextCollapsed("This package uses cgo. Only its Go declarations are documented.")),
+				in(".UnitDoc-label", htmlcheck.HasExactText("test label")),
+				// The files for all build contexts are listed.
+				in(".UnitFiles-fileList",
+					htmlcheck.HasExactTextCollapsed("pkg_linux.go pkg_windows.go"))),
 		},
 		{
 			name:           "two docs windows",
@@ -1057,7 +1105,26 @@ func serverTestCases() []serverTestCase {
 			wantStatusCode: http.StatusOK,
 			want: in("",
 				in(".Documentation-variables", hasText("var W")),
-				in(".UnitBuildContext-titleContext", hasText("windows/amd64"))),
+				in(".UnitBuildContext-titleContext", hasText("windows/amd64")),
+				pagecheck.CanonicalURLPath("/a.com/two@v1.2.3/pkg?GOOS=windows")),
+		},
+		{
+			name:           "two platform imports",
+			urlPath:        "/a.com/two/pkg?tab=imports",
+			wantStatusCode: http.StatusOK,
+			want: in(".Imports-platformList",
+				in("li:nth-child(1)",
+					in("a", href("/golang.org/x/sys/windows")),
+					in(".Imports-buildContexts", htmlcheck.HasExactTextCollapsed("windows/amd64"))),
+				in("li:nth-child(2)",
+					in("a", href("/syscall")),
+					in(".Imports-buildContexts", htmlcheck.HasExactTextCollapsed("linux/amd64")))),
+		},
+		{
+			name:           "two docs windows path segment",
+			urlPath:        "/a.com/two/pkg/GOOS=windows",
+			wantStatusCode: http.StatusMovedPermanently,
+			wantLocation:   "/a.com/two/pkg?GOOS=windows",
 		},
 		{
 			name:           "two docs no match",
@@ -1205,6 +1272,59 @@ func testServer(t *testing.T, testCases []serverTestCase, experimentNames ...str
 	}
 }
 
+func TestServeModulesByLicense(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	// Modules are listed at their latest version, so it must be known.
+	postgres.MustInsertModule(ctx, t, testDB, sample.DefaultModule())
+	lmv, err := internal.NewLatestModuleVersions(sample.ModulePath, sample.VersionString, sample.VersionString, "",
+		[]byte("module "+sample.ModulePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.UpdateLatestModuleVersions(ctx, lmv); err != nil {
+		t.Fatal(err)
+	}
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	for _, test := range []struct {
+		name, urlPath string
+		want          htmlcheck.Checker
+	}{
+		{
+			name:    "modules by license",
+			urlPath: "/licenses/" + sample.LicenseType,
+			want: in("",
+				in(".Content-header", hasText("Modules licensed under "+sample.LicenseType)),
+				in(".ModulesByLicense-list", hasText(regexp.QuoteMeta(sample.ModulePath)))),
+		},
+		{
+			name:    "modules by license none",
+			urlPath: "/licenses/GPL-3.0",
+			want: in("",
+				notIn(".ModulesByLicense-list"),
+				in(".Content", hasText("There are no modules with this license."))),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.urlPath, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %q = %d, want %d", test.urlPath, w.Code, http.StatusOK)
+			}
+			doc, err := html.Parse(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := test.want(doc); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
 func isSubset(subset, set *experiment.Set) bool {
 	for _, e := range subset.Active() {
 		if !set.IsActive(e) {
@@ -1524,7 +1644,7 @@ func TestCheckTemplates(t *testing.T) {
 	// Perform additional checks on parsed templates.
 	staticPath := template.TrustedSourceFromConstant("../../content/static")
 	templateDir := template.TrustedSourceJoin(staticPath, template.TrustedSourceFromConstant("html"))
-	templates, err := parsePageTemplates(templateDir)
+	templates, err := parsePageTemplates(templateDir, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1533,6 +1653,7 @@ func TestCheckTemplates(t *testing.T) {
 		subs    []string
 		typeval interface{}
 	}{
+		{"api_diff", nil, APIDiffPage{}},
 		{"badge", nil, badgePage{}},
 		// error.tmpl omitted because relies on an associated "message" template
 		// that's parsed on demand; see renderErrorPage above.
@@ -1580,6 +1701,36 @@ func TestCheckTemplates(t *testing.T) {
 	}
 }
 
+func TestStaticCDNURL(t *testing.T) {
+	const cdn = "https://cdn.example.com"
+	staticPath := template.TrustedSourceFromConstant("../../content/static")
+	templateDir := template.TrustedSourceJoin(staticPath, template.TrustedSourceFromConstant("html"))
+	for _, test := range []struct {
+		cdnURL, want, notWant string
+	}{
+		{"", `href="/static/css/stylesheet.css?version=v1"`, cdn},
+		{cdn, `href="` + cdn + `/static/css/stylesheet.css`, `href="/static/`},
+		{cdn + "/", `src="` + cdn + `/static/img/go-logo-white.svg"`, `src="/static/`},
+	} {
+		t.Run(test.cdnURL, func(t *testing.T) {
+			templates, err := parsePageTemplates(templateDir, test.cdnURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := executeTemplate(context.Background(), "search_help.tmpl", templates["search_help.tmpl"], basePage{AppVersionLabel: "v1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(got), test.want) {
+				t.Errorf("page does not contain %q", test.want)
+			}
+			if strings.Contains(string(got), test.notWant) {
+				t.Errorf("page contains %q", test.notWant)
+			}
+		})
+	}
+}
+
 func TestEmptyDirectoryBetweenNestedModulesRedirect(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -1601,3 +1752,1038 @@ func TestEmptyDirectoryBetweenNestedModulesRedirect(t *testing.T) {
 		t.Errorf("got location = %q, want %q", got, wantURL)
 	}
 }
+
+func TestUnstableV0(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	const modulePath = "v0.test/mod"
+	for _, v := range []string{"v0.1.0", "v0.3.0", "v0.2.0"} {
+		postgres.MustInsertModule(ctx, t, testDB, sample.Module(modulePath, v, "pkg"))
+	}
+	s, handler, _ := newTestServer(t, nil, nil)
+	for _, test := range []struct {
+		name      string
+		label     bool
+		urlPath   string
+		want      []string
+		wantNotIn []string
+	}{
+		{
+			name:    "latest",
+			label:   true,
+			urlPath: "/" + modulePath + "/pkg",
+			want:    []string{pageLabelUnstableV0, "Version v0.3.0", "DetailsHeader-badge--latest"},
+		},
+		{
+			name:    "earlier version",
+			label:   true,
+			urlPath: "/" + modulePath + "@v0.1.0/pkg",
+			want:    []string{pageLabelUnstableV0, "Version v0.1.0", "DetailsHeader-badge--goToLatest"},
+		},
+		{
+			name:      "label disabled",
+			urlPath:   "/" + modulePath + "/pkg",
+			want:      []string{"Version v0.3.0"},
+			wantNotIn: []string{pageLabelUnstableV0},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s.labelUnstableV0 = test.label
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.urlPath, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %q = %d, want %d", test.urlPath, w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			for _, want := range test.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("page does not contain %q", want)
+				}
+			}
+			for _, notWant := range test.wantNotIn {
+				if strings.Contains(body, notWant) {
+					t.Errorf("page contains %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestServeAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	postgres.MustInsertModule(ctx, t, testDB, sample.Module(sample.ModulePath, sample.VersionString, "foo"))
+	s, handler, _ := newTestServer(t, nil, nil)
+	s.serveStats = true
+	unitPath := "/api/unit/" + sample.ModulePath + "/foo@" + sample.VersionString
+	for _, test := range []struct {
+		name     string
+		serveAPI bool
+		urlPath  string
+		wantCode int
+		wantJSON bool
+	}{
+		{"api disabled", false, unitPath, http.StatusNotFound, false},
+		{"api enabled", true, unitPath, http.StatusOK, true},
+		{"api enabled, unknown unit", true, "/api/unit/" + sample.ModulePath + "/bar", http.StatusNotFound, false},
+		{"debug json, api disabled", false, "/" + sample.ModulePath + "/foo?m=json", http.StatusOK, false},
+		{"debug json, api enabled", true, "/" + sample.ModulePath + "/foo?m=json", http.StatusOK, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s.serveAPI = test.serveAPI
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.urlPath, nil))
+			if w.Code != test.wantCode {
+				t.Fatalf("GET %q = %d, want %d", test.urlPath, w.Code, test.wantCode)
+			}
+			if got := json.Valid(w.Body.Bytes()); got != test.wantJSON {
+				t.Errorf("GET %q: got JSON %t, want %t", test.urlPath, got, test.wantJSON)
+			}
+		})
+	}
+
+	s.serveAPI = true
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", unitPath, nil))
+	var got apiUnit
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.CommitTime.Equal(sample.CommitTime) {
+		t.Errorf("got commit time %s, want %s", got.CommitTime, sample.CommitTime)
+	}
+	want := apiUnit{
+		Path:              sample.ModulePath + "/foo",
+		Name:              "foo",
+		ModulePath:        sample.ModulePath,
+		Version:           sample.VersionString,
+		CommitTime:        got.CommitTime,
+		IsRedistributable: true,
+		Licenses:          []string{sample.LicenseType},
+		License:           sample.LicenseType,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestServeAPISymbolHistory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+	ctx = experiment.NewContext(ctx, internal.ExperimentInsertSymbols)
+
+	rs, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	// Function is absent at v1.0.0, present at v1.1.0 and absent again at
+	// v1.2.0.
+	for _, v := range []struct {
+		version string
+		api     []*internal.Symbol
+	}{
+		{"v1.0.0", []*internal.Symbol{sample.Constant}},
+		{"v1.1.0", []*internal.Symbol{sample.Constant, sample.Function}},
+		{"v1.2.0", []*internal.Symbol{sample.Constant}},
+	} {
+		m := sample.Module(sample.ModulePath, v.version, "foo")
+		m.Packages()[0].Documentation[0].API = v.api
+		postgres.MustInsertModule(ctx, t, testDB, m)
+	}
+	s, handler, _ := newTestServer(t, nil, redis.NewClient(&redis.Options{Addr: rs.Addr()}))
+	s.serveAPI = true
+	get := func(urlPath string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+		return w
+	}
+
+	historyPath := "/api/symbol-history/" + sample.ModulePath + "/foo?name="
+	for _, test := range []struct {
+		name, urlPath string
+		wantCode      int
+	}{
+		{"missing name", historyPath, http.StatusBadRequest},
+		{"unknown symbol", historyPath + "Unknown", http.StatusNotFound},
+		{"unknown package", "/api/symbol-history/" + sample.ModulePath + "/bar?name=Function", http.StatusNotFound},
+	} {
+		if w := get(test.urlPath); w.Code != test.wantCode {
+			t.Errorf("%s: GET %q = %d, want %d", test.name, test.urlPath, w.Code, test.wantCode)
+		}
+	}
+
+	// The second request is served from the cache.
+	for i := 0; i < 2; i++ {
+		w := get(historyPath + "Function")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %q = %d, want %d", historyPath+"Function", w.Code, http.StatusOK)
+		}
+		var got apiSymbolHistory
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := apiSymbolHistory{
+			Path:       sample.ModulePath + "/foo",
+			ModulePath: sample.ModulePath,
+			Symbol:     "Function",
+			Introduced: "v1.1.0",
+			Removed:    "v1.2.0",
+			Changes: []symbol.Change{
+				{Version: "v1.1.0"},
+				{Version: "v1.2.0", Removed: true},
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	}
+	if keys := rs.Keys(); len(keys) != 1 {
+		t.Errorf("got cache keys %v, want one", keys)
+	}
+
+	// A new version of the module is not hidden by the cached history.
+	m := sample.Module(sample.ModulePath, "v1.3.0", "foo")
+	m.Packages()[0].Documentation[0].API = []*internal.Symbol{sample.Constant, sample.Function}
+	postgres.MustInsertModule(ctx, t, testDB, m)
+	w := get(historyPath + "Function")
+	var got apiSymbolHistory
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	wantChanges := []symbol.Change{
+		{Version: "v1.1.0"},
+		{Version: "v1.2.0", Removed: true},
+		{Version: "v1.3.0"},
+	}
+	if diff := cmp.Diff(wantChanges, got.Changes); diff != "" {
+		t.Errorf("after v1.3.0: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestServeAPIImportedBy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	postgres.MustInsertModule(ctx, t, testDB, sample.Module(sample.ModulePath, sample.VersionString, sample.PackageName))
+	var importers []string
+	for i := 0; i < 3; i++ {
+		m := sample.Module(fmt.Sprintf("importer%d.com/m", i), sample.VersionString, "p")
+		m.Units[1].Imports = []string{sample.PackagePath}
+		postgres.MustInsertModule(ctx, t, testDB, m)
+		importers = append(importers, m.Units[1].Path)
+	}
+	s, handler, _ := newTestServer(t, nil, nil)
+	s.serveAPI = true
+	s.apiImportedByLimit = 2
+
+	importedByPath := "/api/imported-by/" + sample.PackagePath
+	for _, test := range []struct {
+		name, urlPath string
+		wantCode      int
+		want          *apiImportedBy
+	}{
+		{"bad limit", importedByPath + "?limit=x", http.StatusBadRequest, nil},
+		{"zero limit", importedByPath + "?limit=0", http.StatusBadRequest, nil},
+		{"unknown package", "/api/imported-by/" + sample.ModulePath + "/unknown", http.StatusNotFound, nil},
+		{
+			"default limit", importedByPath, http.StatusOK,
+			&apiImportedBy{ImportedBy: importers[:2], Total: 3, Truncated: true},
+		},
+		{
+			"smaller limit", importedByPath + "?limit=1", http.StatusOK,
+			&apiImportedBy{ImportedBy: importers[:1], Total: 3, Truncated: true},
+		},
+		{
+			// The server never serves more than its own limit.
+			"larger limit", importedByPath + "?limit=10", http.StatusOK,
+			&apiImportedBy{ImportedBy: importers[:2], Total: 3, Truncated: true},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.urlPath, nil))
+			if w.Code != test.wantCode {
+				t.Fatalf("GET %q = %d, want %d", test.urlPath, w.Code, test.wantCode)
+			}
+			if test.want == nil {
+				return
+			}
+			var got apiImportedBy
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			test.want.Path = sample.PackagePath
+			test.want.ModulePath = sample.ModulePath
+			if diff := cmp.Diff(test.want, &got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServeAPISymbolUsers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	var users []string
+	for i := 0; i < 3; i++ {
+		m := sample.Module(fmt.Sprintf("user%d.com/m", i), sample.VersionString, "p")
+		m.Units[1].ImportedSymbols = []string{"context.WithTimeout"}
+		postgres.MustInsertModule(ctx, t, testDB, m)
+		users = append(users, m.Units[1].Path)
+	}
+	s, handler, _ := newTestServer(t, nil, nil)
+	s.serveAPI = true
+	s.apiImportedByLimit = 2
+
+	for _, test := range []struct {
+		name, urlPath string
+		wantCode      int
+		want          []string
+	}{
+		{"missing symbol", "/api/symbol-users/context", http.StatusBadRequest, nil},
+		{"unexported symbol", "/api/symbol-users/context?symbol=cancelCtx", http.StatusBadRequest, nil},
+		{"missing path", "/api/symbol-users/?symbol=WithTimeout", http.StatusBadRequest, nil},
+		{"bad limit", "/api/symbol-users/context?symbol=WithTimeout&limit=x", http.StatusBadRequest, nil},
+		{"default limit", "/api/symbol-users/context?symbol=WithTimeout", http.StatusOK, users[:2]},
+		{"smaller limit", "/api/symbol-users/context?symbol=WithTimeout&limit=1", http.StatusOK, users[:1]},
+		{"no users", "/api/symbol-users/context?symbol=WithCancel", http.StatusOK, []string{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.urlPath, nil))
+			if w.Code != test.wantCode {
+				t.Fatalf("GET %q = %d, want %d", test.urlPath, w.Code, test.wantCode)
+			}
+			if test.want == nil {
+				return
+			}
+			var got apiSymbolUsers
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got.Users); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServeAPIVersions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	const pseudo = "v0.0.0-20200101120000-000000000000"
+	for _, v := range []string{"v1.0.0", "v1.1.0", pseudo, "v1.2.0-pre"} {
+		postgres.MustInsertModule(ctx, t, testDB, sample.Module(sample.ModulePath, v, "foo"))
+	}
+	lmv, err := internal.NewLatestModuleVersions(sample.ModulePath, "v1.1.0", "v1.1.0", "", []byte(`
+		// Deprecated: use something else.
+		module `+sample.ModulePath+`
+		retract v1.0.0
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.UpdateLatestModuleVersions(ctx, lmv); err != nil {
+		t.Fatal(err)
+	}
+	s, handler, _ := newTestServer(t, nil, nil, internal.ExperimentRetractions)
+	s.serveAPI = true
+
+	urlPath := "/api/versions/" + sample.ModulePath
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %q = %d, want %d", urlPath, w.Code, http.StatusOK)
+	}
+	var got apiVersions
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := apiVersions{
+		ModulePath: sample.ModulePath,
+		Versions: []apiVersion{
+			{Version: "v1.2.0-pre", Type: "prerelease", Deprecated: true},
+			{Version: "v1.1.0", Type: "release", Deprecated: true},
+			{Version: "v1.0.0", Type: "release", Retracted: true, Deprecated: true},
+			{Version: pseudo, Type: "pseudo", Deprecated: true},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(apiVersion{}, "CommitTime")); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	urlPath = "/api/versions/example.com/unknown"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET %q = %d, want %d", urlPath, w.Code, http.StatusNotFound)
+	}
+}
+
+func TestServeUnitPage_DeprecationBanner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	for _, test := range []struct {
+		modulePath, goMod string
+	}{
+		{
+			modulePath: "example.com/deprecated",
+			goMod: `
+				// Deprecated: use example.com/other instead.
+				module example.com/deprecated
+			`,
+		},
+		{
+			modulePath: "example.com/nocomment",
+			goMod: `
+				// Deprecated:
+				module example.com/nocomment
+			`,
+		},
+	} {
+		postgres.MustInsertModule(ctx, t, testDB, sample.Module(test.modulePath, "v1.0.0", "foo"))
+		lmv, err := internal.NewLatestModuleVersions(test.modulePath, "v1.0.0", "v1.0.0", "", []byte(test.goMod))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := testDB.UpdateLatestModuleVersions(ctx, lmv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	postgres.MustInsertModule(ctx, t, testDB, sample.Module("example.com/current", "v1.0.0", "foo"))
+	_, handler, _ := newTestServer(t, nil, nil, internal.ExperimentRetractions)
+
+	for _, test := range []struct {
+		urlPath string
+		want    htmlcheck.Checker
+	}{
+		{
+			urlPath: "/example.com/deprecated/foo",
+			want:    in(".UnitHeader-deprecatedBanner", htmlcheck.HasExactTextCollapsed("Deprecated: use example.com/other instead.")),
+		},
+		{
+			urlPath: "/example.com/nocomment/foo",
+			want:    in(".UnitHeader-deprecatedBanner", htmlcheck.HasExactTextCollapsed("Deprecated")),
+		},
+		{
+			urlPath: "/example.com/current/foo",
+			want:    notIn(".UnitHeader-deprecatedBanner"),
+		},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", test.urlPath, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %q = %d, want %d", test.urlPath, w.Code, http.StatusOK)
+		}
+		if err := htmlcheck.Run(w.Body, test.want); err != nil {
+			t.Errorf("%s: %v", test.urlPath, err)
+		}
+	}
+}
+
+// scheduleRecorder is a queue.Queue that records the module versions it is
+// asked to fetch.
+type scheduleRecorder chan string
+
+func (q scheduleRecorder) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, disableProxyFetch bool, priority queue.Priority) (bool, error) {
+	q <- modulePath + "@" + version
+	return true, nil
+}
+
+func TestServeUnitPageConfiguredDefaultBranch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo")
+	postgres.MustInsertModule(ctx, t, testDB, m)
+	if err := testDB.UpsertVersionMap(ctx, &internal.VersionMap{
+		ModulePath:       m.ModulePath,
+		RequestedVersion: "trunk",
+		ResolvedVersion:  m.Version,
+		GoModPath:        m.ModulePath,
+		Status:           http.StatusOK,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s, handler, _ := newTestServer(t, nil, nil)
+	scheduled := make(scheduleRecorder, 1)
+	s.queue = scheduled
+	urlPath := "/" + sample.ModulePath + "@trunk/foo"
+
+	// Without configuration, trunk is not a supported version.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unconfigured: GET %q = %d, want %d", urlPath, w.Code, http.StatusBadRequest)
+	}
+
+	internal.AddDefaultBranches("trunk")
+	defer delete(internal.DefaultBranches, "trunk")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("configured: GET %q = %d, want %d", urlPath, w.Code, http.StatusOK)
+	}
+	select {
+	case got := <-scheduled:
+		if want := sample.ModulePath + "@trunk"; got != want {
+			t.Errorf("scheduled %q, want %q", got, want)
+		}
+	case <-ctx.Done():
+		t.Fatal("no fetch was scheduled")
+	}
+}
+
+func TestServeAPIDoc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo")
+	for _, u := range m.Units {
+		if u.Name == "foo" {
+			u.Documentation = []*internal.Documentation{sample.Documentation(internal.All, internal.All, `
+				// Package foo does things.
+				package foo
+
+				// F does a thing.
+				func F(x int) error { return nil }
+
+				// T is a thing.
+				type T int
+
+				// M is a method.
+				func (T) M() {}
+			`)}
+		}
+	}
+	postgres.MustInsertModule(ctx, t, testDB, m)
+	s, handler, _ := newTestServer(t, nil, nil)
+	s.serveAPI = true
+
+	urlPath := "/api/doc/" + sample.ModulePath + "/foo@" + sample.VersionString
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %q = %d, want %d", urlPath, w.Code, http.StatusOK)
+	}
+	var got apiDoc
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := apiDoc{
+		Path:       sample.ModulePath + "/foo",
+		Name:       "foo",
+		ModulePath: sample.ModulePath,
+		Version:    sample.VersionString,
+		GOOS:       internal.All,
+		GOARCH:     internal.All,
+		Synopsis:   "Package foo does things.",
+		Doc:        "Package foo does things.\n",
+		Symbols: []*godoc.SymbolText{
+			{Kind: internal.SymbolKindFunction, Name: "F", Signature: "func F(x int) error", Doc: "F does a thing.\n"},
+			{Kind: internal.SymbolKindType, Name: "T", Signature: "type T int", Doc: "T is a thing.\n"},
+			{Kind: internal.SymbolKindMethod, Name: "T.M", ParentName: "T", Signature: "func (T) M()", Doc: "M is a method.\n"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, urlPath := range []string{
+		// Not a package.
+		"/api/doc/" + sample.ModulePath + "@" + sample.VersionString,
+		"/api/doc/example.com/unknown",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %q = %d, want %d", urlPath, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestServeUnitPageSymbolRedirect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo")
+	for _, u := range m.Units {
+		if u.Name == "foo" {
+			u.Documentation = []*internal.Documentation{sample.Documentation(internal.All, internal.All, `
+				// Package foo does things.
+				package foo
+
+				// F does a thing.
+				func F() {}
+
+				// T is a thing.
+				type T int
+
+				// M is a method.
+				func (T) M() {}
+
+				func unexported() {}
+			`)}
+		}
+	}
+	postgres.MustInsertModule(ctx, t, testDB, m)
+
+	// The stored symbols of a package are used in preference to its
+	// documentation, which here has none.
+	const storedModulePath = "stored.com/m"
+	m = sample.Module(storedModulePath, sample.VersionString, "foo")
+	for _, u := range m.Units {
+		if u.Name == "foo" {
+			doc := sample.Documentation(internal.All, internal.All, "package foo")
+			doc.API = []*internal.Symbol{{
+				Name:     "S",
+				Synopsis: "func S()",
+				Section:  internal.SymbolSectionFunctions,
+				Kind:     internal.SymbolKindFunction,
+				GOOS:     internal.All,
+				GOARCH:   internal.All,
+			}}
+			u.Documentation = []*internal.Documentation{doc}
+		}
+	}
+	postgres.MustInsertModule(experiment.NewContext(ctx, internal.ExperimentInsertSymbols), t, testDB, m)
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	pkgPath := "/" + sample.ModulePath + "/foo"
+	for _, test := range []struct {
+		name, urlPath string
+		wantStatus    int
+		wantLocation  string
+	}{
+		{"func", pkgPath + "/F", http.StatusFound, pkgPath + "#F"},
+		{"method", pkgPath + "/T.M", http.StatusFound, pkgPath + "#T.M"},
+		{
+			"versioned",
+			"/" + sample.ModulePath + "@" + sample.VersionString + "/foo/T",
+			http.StatusFound,
+			"/" + sample.ModulePath + "@" + sample.VersionString + "/foo#T",
+		},
+		{"unknown", pkgPath + "/G", http.StatusNotFound, ""},
+		{"unexported", pkgPath + "/unexported", http.StatusNotFound, ""},
+		{"stored symbol", "/" + storedModulePath + "/foo/S", http.StatusFound, "/" + storedModulePath + "/foo#S"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.urlPath, nil))
+			if w.Code != test.wantStatus {
+				t.Fatalf("GET %q = %d, want %d", test.urlPath, w.Code, test.wantStatus)
+			}
+			if got := w.Header().Get("Location"); got != test.wantLocation {
+				t.Errorf("got Location %q, want %q", got, test.wantLocation)
+			}
+		})
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+	defer func(old bool) { middleware.TestMode = old }(middleware.TestMode)
+	middleware.TestMode = true // cache pages synchronously
+
+	rs, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	postgres.MustInsertModule(ctx, t, testDB, sample.Module(sample.ModulePath, sample.VersionString, "foo", "bar"))
+	s, handler, _ := newTestServer(t, nil, redis.NewClient(&redis.Options{Addr: rs.Addr()}))
+	get := func(urlPath string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+		return w
+	}
+
+	// Cache the page for foo, but not the one for bar.
+	cachedPath := "/" + sample.ModulePath + "/foo"
+	if w := get(cachedPath); w.Code != http.StatusOK {
+		t.Fatalf("GET %q = %d, want %d", cachedPath, w.Code, http.StatusOK)
+	}
+
+	s.maintenanceMode = true
+	for _, test := range []struct {
+		urlPath  string
+		wantCode int
+		want     string
+	}{
+		{cachedPath, http.StatusOK, "foo"},
+		{"/" + sample.ModulePath + "/bar", http.StatusServiceUnavailable, "undergoing maintenance"},
+		{"/search?q=foo", http.StatusServiceUnavailable, "undergoing maintenance"},
+		{"/search-help", http.StatusOK, "undergoing maintenance"}, // banner
+	} {
+		w := get(test.urlPath)
+		if w.Code != test.wantCode {
+			t.Errorf("GET %q = %d, want %d", test.urlPath, w.Code, test.wantCode)
+		}
+		if !strings.Contains(w.Body.String(), test.want) {
+			t.Errorf("GET %q: body does not contain %q", test.urlPath, test.want)
+		}
+	}
+}
+
+func TestServeAPIDiff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, internal.ExperimentInsertSymbols)
+	defer postgres.ResetTestDB(testDB, t)
+
+	v120, v130 := apiDiffSymbols()
+	for _, mv := range []struct {
+		version string
+		api     []*internal.Symbol
+	}{
+		{"v1.2.0", v120},
+		{"v1.3.0", v130},
+		{"v1.4.0-pre", v130},
+	} {
+		m := sample.Module(sample.ModulePath, mv.version, "foo")
+		for _, u := range m.Units {
+			if u.Name == "foo" {
+				u.Documentation[0].API = mv.api
+			}
+		}
+		postgres.MustInsertModule(ctx, t, testDB, m)
+	}
+	// Without the experiment, no symbols are stored for v1.0.0.
+	postgres.MustInsertModule(context.Background(), t, testDB, sample.Module(sample.ModulePath, "v1.0.0", "foo"))
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	pkgPath := sample.ModulePath + "/foo"
+	urlPath := "/" + sample.ModulePath + "@v1.3.0/foo?compare=v1.2.0"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %q = %d, want %d", urlPath, w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"API changes in " + pkgPath,
+		"func G(x int)",
+		"func (T) M()",
+		"func (T) M(ctx context.Context)",
+	} {
+		if !strings.Contains(body, html.EscapeString(want)) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	if strings.Contains(body, "func F() error") {
+		t.Error("page contains unchanged function F")
+	}
+
+	urlPath = "/" + sample.ModulePath + "@v1.3.0/foo?compare=v1.0.0"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %q = %d, want %d", urlPath, w.Code, http.StatusOK)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "is not available") || strings.Contains(body, "func G(x int)") {
+		t.Errorf("GET %q: want a page saying the comparison is not available, got\n%s", urlPath, body)
+	}
+
+	for _, test := range []struct {
+		urlPath    string
+		wantStatus int
+	}{
+		{"/" + sample.ModulePath + "@v1.3.0/foo?compare=v1.1.0", http.StatusNotFound},
+		{"/" + sample.ModulePath + "@v1.4.0-pre/foo?compare=v1.2.0", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", test.urlPath, nil))
+		if w.Code != test.wantStatus {
+			t.Errorf("GET %q = %d, want %d", test.urlPath, w.Code, test.wantStatus)
+		}
+	}
+}
+
+func TestServeUnitPageBuildContextCookie(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo", "foo/bar")
+	for _, u := range m.Units {
+		switch u.Name {
+		case "foo":
+			u.Documentation = []*internal.Documentation{
+				sample.Documentation("linux", "amd64", "package foo\nfunc OnLinux() {}"),
+				sample.Documentation("windows", "amd64", "package foo\nfunc OnWindows() {}"),
+			}
+		case "bar":
+			u.Documentation = []*internal.Documentation{
+				sample.Documentation("linux", "amd64", "package bar\nfunc OnlyLinux() {}"),
+			}
+		}
+	}
+	postgres.MustInsertModule(ctx, t, testDB, m)
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	get := func(urlPath string, cookies []*http.Cookie) (string, []*http.Cookie) {
+		t.Helper()
+		r := httptest.NewRequest("GET", urlPath, nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %q = %d, want %d", urlPath, w.Code, http.StatusOK)
+		}
+		return w.Body.String(), w.Result().Cookies()
+	}
+
+	pkgPath := "/" + sample.ModulePath + "/foo"
+	body, _ := get(pkgPath, nil)
+	if !strings.Contains(body, "OnLinux") {
+		t.Fatal("default page does not show the linux documentation")
+	}
+	body, cookies := get(pkgPath+"?GOOS=windows", nil)
+	if !strings.Contains(body, "OnWindows") {
+		t.Error("GOOS=windows page does not show the windows documentation")
+	}
+	if len(cookies) != 1 || cookies[0].Name != cookie.BuildContext {
+		t.Fatalf("GOOS=windows: got cookies %v, want one %q cookie", cookies, cookie.BuildContext)
+	}
+	// The selection is remembered without query parameters.
+	body, _ = get(pkgPath, cookies)
+	if !strings.Contains(body, "OnWindows") {
+		t.Error("page with cookie does not show the windows documentation")
+	}
+	// The remembered build context is not part of the canonical URL.
+	wantCanonical := fmt.Sprintf(`data-canonical-url-path="/%s@%s/foo"`, sample.ModulePath, sample.VersionString)
+	if !strings.Contains(body, wantCanonical) {
+		t.Errorf("page with cookie does not contain %s", wantCanonical)
+	}
+	// Query parameters take precedence.
+	body, _ = get(pkgPath+"?GOOS=linux", cookies)
+	if !strings.Contains(body, "OnLinux") {
+		t.Error("GOOS=linux page with cookie does not show the linux documentation")
+	}
+	// A unit without documentation for the remembered build context shows
+	// the documentation for the default one.
+	body, _ = get(pkgPath+"/bar", cookies)
+	if !strings.Contains(body, "OnlyLinux") {
+		t.Error("page of a unit without windows documentation, with cookie, does not show its linux documentation")
+	}
+}
+
+func TestServeUnitPage_MainDetailsCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	postgres.MustInsertModule(ctx, t, testDB, sample.DefaultModule())
+
+	rs, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	c := cache.New(redis.NewClient(&redis.Options{Addr: rs.Addr()}))
+	_, handler, _ := newTestServer(t, nil, redis.NewClient(&redis.Options{Addr: rs.Addr()}))
+
+	// Selecting a build context bypasses the page cache, so every request
+	// reaches serveUnitPage.
+	urlPath := fmt.Sprintf("/%s@%s?GOOS=linux&GOARCH=amd64", sample.PackagePath, sample.VersionString)
+	get := func() string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: got status %d, want %d", urlPath, w.Code, http.StatusOK)
+		}
+		return w.Body.String()
+	}
+
+	get()
+	key := cache.MainDetailsKey(sample.PackagePath, sample.ModulePath, sample.VersionString, "linux", "amd64")
+	data, err := c.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data == nil {
+		t.Fatalf("no cache entry for %q", key)
+	}
+
+	// Replace the cached documentation. If the second request serves it, the
+	// documentation was not rendered again.
+	const marker = "<p>cached-doc-marker</p>"
+	var cmd cachedMainDetails
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		t.Fatal(err)
+	}
+	cmd.DocBody = marker
+	data, err = json.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(ctx, key, data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if body := get(); !strings.Contains(body, marker) {
+		t.Error("cache hit: page does not contain the cached documentation")
+	}
+
+	// The worker deletes the entries for a module version when it fetches it
+	// again (see TestFetchAndUpdateState_InvalidatesMainDetails). After that,
+	// the documentation is rendered again.
+	if err := c.DeleteSet(ctx, cache.MainDetailsSetKey(sample.ModulePath, sample.VersionString)); err != nil {
+		t.Fatal(err)
+	}
+	if body := get(); strings.Contains(body, marker) {
+		t.Error("after invalidation: page still contains the cached documentation")
+	}
+}
+
+func TestServeUnitPage_ETag(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	postgres.MustInsertModule(ctx, t, testDB, sample.DefaultModule())
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	get := func(urlPath, ifNoneMatch string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("GET", urlPath, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	pinned := fmt.Sprintf("/%s@%s", sample.PackagePath, sample.VersionString)
+	w := get(pinned, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: got status %d, want %d", pinned, w.Code, http.StatusOK)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("GET %s: no ETag", pinned)
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		t.Errorf("GET %s: no Cache-Control", pinned)
+	}
+	if got, want := w.Header().Get("Vary"), "Cookie"; got != want {
+		t.Errorf("GET %s: got Vary %q, want %q", pinned, got, want)
+	}
+	if w := get(pinned, etag); w.Code != http.StatusNotModified {
+		t.Errorf("GET %s with matching ETag: got status %d, want %d", pinned, w.Code, http.StatusNotModified)
+	}
+	if w := get(pinned, `"other"`); w.Code != http.StatusOK {
+		t.Errorf("GET %s with different ETag: got status %d, want %d", pinned, w.Code, http.StatusOK)
+	}
+	if w := get(pinned+"?tab=licenses", etag); w.Code != http.StatusOK {
+		t.Errorf("GET %s?tab=licenses with main tab ETag: got status %d, want %d", pinned, w.Code, http.StatusOK)
+	}
+
+	latest := "/" + sample.PackagePath
+	w = get(latest, etag)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET %s: got status %d, want %d", latest, w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("ETag"); got != "" {
+		t.Errorf("GET %s: got ETag %s, want none", latest, got)
+	}
+}
+
+func TestServeUnitPage_Head(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	postgres.MustInsertModule(ctx, t, testDB, sample.DefaultModule())
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	for _, test := range []struct {
+		path     string
+		want     int
+		wantETag bool
+	}{
+		{"/" + sample.PackagePath, http.StatusOK, false},
+		{fmt.Sprintf("/%s@%s", sample.PackagePath, sample.VersionString), http.StatusOK, true},
+		{"/" + sample.ModulePath + "/does-not-exist", http.StatusNotFound, false},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("HEAD", test.path, nil))
+			if w.Code != test.want {
+				t.Errorf("HEAD %s: got status %d, want %d", test.path, w.Code, test.want)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("HEAD %s: got %d bytes of body, want none", test.path, w.Body.Len())
+			}
+			etag := w.Header().Get("ETag")
+			if got := etag != ""; got != test.wantETag {
+				t.Fatalf("HEAD %s: got ETag %q, want one: %t", test.path, etag, test.wantETag)
+			}
+			if !test.wantETag {
+				return
+			}
+			// The ETag of a HEAD response must match the one for GET.
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("GET %s: got ETag %q, want %q", test.path, got, etag)
+			}
+		})
+	}
+}
+
+func TestServeUnitPage_VanityRedirect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	postgres.MustInsertModule(ctx, t, testDB, sample.DefaultModule())
+	if err := testDB.InsertPathRedirect(ctx, "vanity.example.com/mod", sample.ModulePath); err != nil {
+		t.Fatal(err)
+	}
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	for _, test := range []struct {
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"/vanity.example.com/mod/foo", http.StatusMovedPermanently, "/" + sample.ModulePath + "/foo"},
+		{"/vanity.example.com/mod/foo@v1.0.0?tab=doc", http.StatusMovedPermanently, "/" + sample.ModulePath + "/foo@v1.0.0?tab=doc"},
+		{"/unmapped.example.com/mod/foo", http.StatusNotFound, ""},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != test.wantStatus {
+				t.Fatalf("GET %s: got status %d, want %d", test.path, w.Code, test.wantStatus)
+			}
+			if got := w.Header().Get("Location"); got != test.wantLocation {
+				t.Errorf("GET %s: got Location %q, want %q", test.path, got, test.wantLocation)
+			}
+		})
+	}
+}
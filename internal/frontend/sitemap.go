@@ -0,0 +1,226 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+const (
+	// sitemapBatchSize is the number of units read from the database at a
+	// time when generating a sitemap.
+	sitemapBatchSize = 1000
+
+	sitemapNamespace   = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapContentType = "application/xml; charset=utf-8"
+
+	// sitemapTTL is how long sitemaps are cached. Generating a sitemap reads
+	// every unit in the database, and crawlers don't need it to be fresher
+	// than this.
+	sitemapTTL = 24 * time.Hour
+)
+
+// maxSitemapURLs is the maximum number of URLs in a sitemap, set by the
+// sitemap protocol. It is a variable for testing.
+var maxSitemapURLs = 50000
+
+// sitemapContentTypeHandler sets the sitemap content type on successful
+// responses from h. The cache only stores response bodies, so cached sitemaps
+// would otherwise be served without it.
+func sitemapContentTypeHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&sitemapWriter{ResponseWriter: w}, r)
+	})
+}
+
+type sitemapWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *sitemapWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code == http.StatusOK && w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", sitemapContentType)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sitemapWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+type sitemapURL struct {
+	XMLName xml.Name `xml:"url"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndexEntry struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+}
+
+// serveSitemap serves a sitemap of the canonical URLs of the units in the
+// latest version of each module, other than excluded ones. The URLs are
+// relative to the configured canonical URL, not to the host of the request,
+// since sitemaps are cached for all hosts.
+//
+// If there are more units than fit in one sitemap, /sitemap.xml serves a
+// sitemap index instead, whose entries are of the form /sitemap.xml?n=<i>,
+// for i from 0 to one less than the number of sitemaps. Any other query
+// results in a 404, so that arbitrary queries can't each fill an entry of
+// the cache.
+func (s *Server) serveSitemap(w http.ResponseWriter, r *http.Request, ds internal.DataSource) error {
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return proxydatasourceNotSupportedErr()
+	}
+	ctx := r.Context()
+	baseURL := s.canonicalURL
+	// n is the number of the requested sitemap, or -1 for /sitemap.xml.
+	n := -1
+	if q := r.URL.RawQuery; q != "" {
+		i, err := strconv.Atoi(strings.TrimPrefix(q, "n="))
+		if err != nil || i < 0 || q != sitemapQuery(i) {
+			return &serverError{status: http.StatusNotFound}
+		}
+		n = i
+	}
+	starts, err := sitemapStarts(ctx, db)
+	if err != nil {
+		return err
+	}
+	switch {
+	case n < 0 && len(starts) == 1:
+		return writeSitemap(ctx, w, db, baseURL, "")
+	case n < 0:
+		return writeSitemapXML(w, "sitemapindex", func(enc *xml.Encoder) error {
+			for i := range starts {
+				e := sitemapIndexEntry{Loc: baseURL + "/sitemap.xml?" + sitemapQuery(i)}
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case len(starts) == 1 || n >= len(starts):
+		// There is no index, or it has no entry for n.
+		return &serverError{status: http.StatusNotFound}
+	default:
+		return writeSitemap(ctx, w, db, baseURL, starts[n])
+	}
+}
+
+// sitemapQuery returns the query of the URL of the sitemap numbered i in the
+// sitemap index.
+func sitemapQuery(i int) string {
+	return "n=" + strconv.Itoa(i)
+}
+
+// sitemapStarts returns the unit path that each sitemap starts after. The
+// units are read in batches and only those paths are kept, so memory use
+// grows with the number of sitemaps rather than the number of units.
+func sitemapStarts(ctx context.Context, db *postgres.DB) ([]string, error) {
+	starts := []string{""}
+	var (
+		n    int
+		last string
+	)
+	err := forEachSitemapUnit(ctx, db, "", func(um *internal.UnitMeta) (bool, error) {
+		if n > 0 && n%maxSitemapURLs == 0 {
+			starts = append(starts, last)
+		}
+		n++
+		last = um.Path
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return starts, nil
+}
+
+// writeSitemap writes a sitemap of at most maxSitemapURLs units whose paths
+// sort after the given one.
+func writeSitemap(ctx context.Context, w http.ResponseWriter, db *postgres.DB, baseURL, after string) error {
+	return writeSitemapXML(w, "urlset", func(enc *xml.Encoder) error {
+		var n int
+		return forEachSitemapUnit(ctx, db, after, func(um *internal.UnitMeta) (bool, error) {
+			u := sitemapURL{Loc: baseURL + "/" + um.Path}
+			if !um.CommitTime.IsZero() {
+				u.LastMod = um.CommitTime.UTC().Format(time.RFC3339)
+			}
+			if err := enc.Encode(u); err != nil {
+				return false, err
+			}
+			n++
+			return n < maxSitemapURLs, nil
+		})
+	})
+}
+
+// forEachSitemapUnit calls f, in path order, on each unit in the latest
+// version of a module whose path sorts after the given one, until f returns
+// false or an error. Units are read from db in batches. Excluded paths are
+// skipped.
+func forEachSitemapUnit(ctx context.Context, db *postgres.DB, after string, f func(*internal.UnitMeta) (bool, error)) error {
+	for {
+		ums, err := db.GetLatestUnitPaths(ctx, after, sitemapBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, um := range ums {
+			after = um.Path
+			excluded, err := db.IsExcluded(ctx, um.Path)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				continue
+			}
+			more, err := f(um)
+			if err != nil || !more {
+				return err
+			}
+		}
+		if len(ums) < sitemapBatchSize {
+			return nil
+		}
+	}
+}
+
+// writeSitemapXML writes an XML document whose root element is named root,
+// and whose contents are written by writeContents.
+func writeSitemapXML(w http.ResponseWriter, root string, writeContents func(*xml.Encoder) error) error {
+	w.Header().Set("Content-Type", sitemapContentType)
+	if _, err := fmt.Fprintf(w, "%s<%s xmlns=%q>\n", xml.Header, root, sitemapNamespace); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("  ", "  ")
+	if err := writeContents(enc); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n</%s>\n", root)
+	return err
+}
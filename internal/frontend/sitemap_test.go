@@ -0,0 +1,188 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestServeSitemap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	for _, mv := range []struct{ modulePath, version string }{
+		{"example.com/a", "v1.0.0"},
+		{"example.com/a", "v1.1.0"},
+		{"example.com/b", "v0.1.0"},
+		{"example.com/bad", "v1.0.0"},
+		{"example.com/c/v2", "v2.0.0"},
+	} {
+		postgres.MustInsertModule(ctx, t, testDB, sample.Module(mv.modulePath, mv.version, "pkg"))
+	}
+	for _, mv := range []struct{ modulePath, version string }{
+		{"example.com/a", "v1.1.0"},
+		{"example.com/b", "v0.1.0"},
+		{"example.com/bad", "v1.0.0"},
+		{"example.com/c/v2", "v2.0.0"},
+	} {
+		lmv, err := internal.NewLatestModuleVersions(mv.modulePath, mv.version, mv.version, "", []byte("module "+mv.modulePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := testDB.UpdateLatestModuleVersions(ctx, lmv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Excluded modules are left out of the sitemap.
+	if err := testDB.InsertExcludedPrefix(ctx, "example.com/bad", "someone", "for testing"); err != nil {
+		t.Fatal(err)
+	}
+	_, handler, _ := newTestServer(t, nil, nil)
+
+	get := func(urlPath string) []byte {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", urlPath, nil)
+		// The URLs in the sitemap don't depend on the host of the request.
+		r.Host = "forged.example.org"
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %q = %d, want %d", urlPath, w.Code, http.StatusOK)
+		}
+		if got, want := w.Header().Get("Content-Type"), "application/xml; charset=utf-8"; got != want {
+			t.Errorf("GET %q: got Content-Type %q, want %q", urlPath, got, want)
+		}
+		return w.Body.Bytes()
+	}
+	urls := func(body []byte) []string {
+		t.Helper()
+		var urlset struct {
+			XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+			URLs    []struct {
+				Loc string `xml:"loc"`
+			} `xml:"url"`
+		}
+		if err := xml.Unmarshal(body, &urlset); err != nil {
+			t.Fatalf("%v\n%s", err, body)
+		}
+		var locs []string
+		for _, u := range urlset.URLs {
+			locs = append(locs, u.Loc)
+		}
+		return locs
+	}
+
+	want := []string{
+		"https://pkg.go.dev/example.com/a",
+		"https://pkg.go.dev/example.com/a/pkg",
+		"https://pkg.go.dev/example.com/b",
+		"https://pkg.go.dev/example.com/b/pkg",
+		"https://pkg.go.dev/example.com/c/v2",
+		"https://pkg.go.dev/example.com/c/v2/pkg",
+	}
+	if diff := cmp.Diff(want, urls(get("/sitemap.xml"))); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	// With fewer URLs allowed per sitemap, /sitemap.xml serves an index.
+	defer func(n int) { maxSitemapURLs = n }(maxSitemapURLs)
+	maxSitemapURLs = 2
+	var index struct {
+		XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	if body := get("/sitemap.xml"); xml.Unmarshal(body, &index) != nil {
+		t.Fatalf("not a sitemap index:\n%s", body)
+	}
+	wantIndex := []string{
+		"https://pkg.go.dev/sitemap.xml?n=0",
+		"https://pkg.go.dev/sitemap.xml?n=1",
+		"https://pkg.go.dev/sitemap.xml?n=2",
+	}
+	var gotIndex, got []string
+	for _, sm := range index.Sitemaps {
+		gotIndex = append(gotIndex, sm.Loc)
+		got = append(got, urls(get(sm.Loc[len("https://pkg.go.dev"):]))...)
+	}
+	if diff := cmp.Diff(wantIndex, gotIndex); diff != "" {
+		t.Errorf("index mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("paged mismatch (-want +got):\n%s", diff)
+	}
+
+	// Only the sitemaps listed in the index are served.
+	for _, q := range []string{"n=3", "n=-1", "n=01", "n=1&n=2", "n=1&x=y", "n=", "after=example.com%2Fa%2Fpkg", "x"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/sitemap.xml?"+q, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET /sitemap.xml?%s = %d, want %d", q, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestServeSitemapCached(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+	defer func(old bool) { middleware.TestMode = old }(middleware.TestMode)
+	middleware.TestMode = true // cache pages synchronously
+
+	rs, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	insertLatest := func(modulePath, version string) {
+		t.Helper()
+		postgres.MustInsertModule(ctx, t, testDB, sample.Module(modulePath, version, "pkg"))
+		lmv, err := internal.NewLatestModuleVersions(modulePath, version, version, "", []byte("module "+modulePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := testDB.UpdateLatestModuleVersions(ctx, lmv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insertLatest("example.com/a", "v1.0.0")
+	_, handler, _ := newTestServer(t, nil, redis.NewClient(&redis.Options{Addr: rs.Addr()}))
+
+	get := func() string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/sitemap.xml", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /sitemap.xml = %d, want %d", w.Code, http.StatusOK)
+		}
+		if got, want := w.Header().Get("Content-Type"), sitemapContentType; got != want {
+			t.Errorf("got Content-Type %q, want %q", got, want)
+		}
+		return w.Body.String()
+	}
+
+	want := get()
+	// A module added after the sitemap is cached doesn't appear until the
+	// cache entry expires.
+	insertLatest("example.com/b", "v1.0.0")
+	if got := get(); got != want {
+		t.Errorf("got %s\nwant cached %s", got, want)
+	}
+}
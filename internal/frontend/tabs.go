@@ -74,19 +74,20 @@ func init() {
 }
 
 // fetchDetailsForPackage returns tab details by delegating to the correct detail
-// handler.
-func fetchDetailsForUnit(ctx context.Context, r *http.Request, tab string, ds internal.DataSource, um *internal.UnitMeta, bc internal.BuildContext) (_ interface{}, err error) {
+// handler. If remembered is true, bc is the build context the user selected on
+// another page, which the unit may have no documentation for.
+func (s *Server) fetchDetailsForUnit(ctx context.Context, r *http.Request, tab string, ds internal.DataSource, um *internal.UnitMeta, bc internal.BuildContext, remembered bool) (_ interface{}, err error) {
 	defer derrors.Wrap(&err, "fetchDetailsForUnit(r, %q, ds, um=%q,%q,%q)", tab, um.Path, um.ModulePath, um.Version)
 	switch tab {
 	case tabMain:
 		_, expandReadme := r.URL.Query()["readme"]
-		return fetchMainDetails(ctx, ds, um, expandReadme, bc)
+		return fetchMainDetails(ctx, ds, um, expandReadme, bc, remembered, s.showInternalPackages)
 	case tabVersions:
 		return fetchVersionsDetails(ctx, ds, um.Path, um.ModulePath)
 	case tabImports:
-		return fetchImportsDetails(ctx, ds, um.Path, um.ModulePath, um.Version)
+		return s.fetchImportsDetails(ctx, ds, um.Path, um.ModulePath, um.Version, bc)
 	case tabImportedBy:
-		return fetchImportedByDetails(ctx, ds, um.Path, um.ModulePath)
+		return fetchImportedByDetails(ctx, ds, um.Path, um.ModulePath, s.importedByLimit, s.showInternalPackages)
 	case tabLicenses:
 		return fetchLicensesDetails(ctx, ds, um)
 	}
@@ -6,22 +6,35 @@ package frontend
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go/token"
+	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/safehtml"
 	"github.com/google/safehtml/uncheckedconversions"
+	"go.opencensus.io/trace"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/cookie"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/queue"
 	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/pkgsite/internal/version"
 )
 
 // UnitPage contains data needed to render the unit template.
@@ -50,6 +63,11 @@ type UnitPage struct {
 	// The version string formatted for display.
 	DisplayVersion string
 
+	// CommitHash is the hash of the commit that a pseudo-version refers to,
+	// displayed next to the version, or its prefix in the pseudo-version if
+	// the full hash is not known. It is empty for other versions.
+	CommitHash string
+
 	// LinkVersion is version string suitable for links used to compute
 	// latest badges.
 	LinkVersion string
@@ -110,29 +128,89 @@ func (s *Server) serveUnitPage(ctx context.Context, w http.ResponseWriter, r *ht
 		if !errors.Is(err, derrors.NotFound) {
 			return err
 		}
+		u, err := s.symbolRedirectURL(ctx, r, ds, info)
+		if err != nil {
+			return err
+		}
+		if u != "" {
+			http.Redirect(w, r, u, http.StatusFound)
+			return nil
+		}
+		u, err = vanityRedirectURL(ctx, r, ds, info)
+		if err != nil {
+			return err
+		}
+		if u != "" {
+			http.Redirect(w, r, u, http.StatusMovedPermanently)
+			return nil
+		}
+		if r.Method == http.MethodHead {
+			// Don't render the 404 page or try to fetch the path.
+			w.WriteHeader(http.StatusNotFound)
+			return nil
+		}
 		return s.servePathNotFoundPage(w, r, ds, info.fullPath, info.modulePath, info.requestedVersion)
 	}
 
-	// Use GOOS and GOARCH query parameters to create a build context, which
-	// affects the documentation and synopsis. Omitting both results in an empty
-	// build context, which will match the first (and preferred) build context.
+	if compare := r.FormValue("compare"); compare != "" {
+		return s.serveAPIDiff(ctx, w, r, ds, um, compare)
+	}
+
+	// Use GOOS and GOARCH query parameters, or the build context the user
+	// selected previously, to create a build context, which affects the
+	// documentation and synopsis. Omitting both results in an empty build
+	// context, which will match the first (and preferred) build context.
 	// It's also okay to provide just one (e.g. GOOS=windows), which will select
-	// the first doc with that value, ignoring the other one.
-	bc := internal.BuildContext{GOOS: r.FormValue("GOOS"), GOARCH: r.FormValue("GOARCH")}
-	d, err := fetchDetailsForUnit(ctx, r, tab, ds, um, bc)
-	if err != nil {
-		return err
+	// the first doc with that value, ignoring the other one. A remembered build
+	// context that the unit has no documentation for is ignored.
+	bc, remembered := selectedBuildContext(w, r)
+	var etag string
+	if middleware.CSPNonce(ctx) == "" {
+		// A page carrying a CSP nonce differs on every request, so it can't
+		// be revalidated.
+		etag = s.unitPageETag(r, info, um, bc)
 	}
-	if s.serveStats && r.FormValue("m") == "json" {
-		data, err := json.Marshal(d)
-		if err != nil {
-			return fmt.Errorf("json.Marshal: %v", err)
+	if etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		setETag(w, etag)
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	if r.Method == http.MethodHead {
+		// The unit exists, so the headers are known. Skip fetching and
+		// rendering the details, which would be discarded.
+		if !isValidTabForUnit(tab, um) {
+			http.Redirect(w, r, r.URL.Path, http.StatusFound)
+			return nil
 		}
-		if _, err := w.Write(data); err != nil {
-			return fmt.Errorf("w.Write: %v", err)
+		if etag != "" {
+			setETag(w, etag)
 		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
 		return nil
 	}
+	var d interface{}
+	var md *MainDetails
+	if tab == tabMain {
+		md = s.getCachedMainDetails(ctx, um, bc)
+	}
+	if md != nil {
+		_, md.ExpandReadme = r.URL.Query()["readme"]
+		d = md
+	} else {
+		d, err = s.fetchDetailsForUnit(ctx, r, tab, ds, um, bc, remembered)
+		if err != nil {
+			return err
+		}
+		if md, ok := d.(*MainDetails); ok {
+			s.putCachedMainDetails(ctx, um, bc, md)
+		}
+	}
+	// The JSON form of the details is a debugging aid, so it is only served
+	// when both page statistics and the JSON API are enabled.
+	if s.serveStats && s.serveAPI && r.FormValue("m") == "json" {
+		return writeJSON(w, d)
+	}
 
 	recordVersionTypeMetric(ctx, info.requestedVersion)
 	if _, ok := internal.DefaultBranches[info.requestedVersion]; ok {
@@ -142,12 +220,14 @@ func (s *Server) serveUnitPage(ctx context.Context, w http.ResponseWriter, r *ht
 		// last time we tried to fetch this module version.
 		//
 		// Use a separate context here to prevent the context from being canceled
-		// elsewhere before a task is enqueued.
+		// elsewhere before a task is enqueued. Keep the request's span, so that
+		// the fetch is traced as part of this request.
+		span := trace.FromContext(ctx)
 		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+			ctx, cancel := context.WithTimeout(trace.NewContext(context.Background(), span), 1*time.Minute)
 			defer cancel()
 			log.Infof(ctx, "serveUnitPage: Scheduling %q@%q to be fetched", info.modulePath, info.requestedVersion)
-			if _, err := s.queue.ScheduleFetch(ctx, info.modulePath, info.requestedVersion, "", false); err != nil {
+			if _, err := s.queue.ScheduleFetch(ctx, info.modulePath, info.requestedVersion, "", false, queue.HighPriority); err != nil {
 				log.Errorf(ctx, "serveUnitPage(%q): %v", r.URL.Path, err)
 			}
 		}()
@@ -172,13 +252,16 @@ func (s *Server) serveUnitPage(ctx context.Context, w http.ResponseWriter, r *ht
 	basePage := s.newBasePage(r, title)
 	basePage.AllowWideContent = true
 	lv := linkVersion(um.Version, um.ModulePath)
-	_, majorVersion, _ := module.SplitPathVersion(um.ModulePath)
-	_, latestMajorVersion, ok := module.SplitPathVersion(latestInfo.MajorModulePath)
-	// Show the banner if there was no error getting the latest major version,
-	// and it is different from the major version of the current module path.
-	var latestMajorVersionNum string
-	if ok && majorVersion != latestMajorVersion && latestMajorVersion != "" {
-		latestMajorVersionNum = strings.TrimPrefix(latestMajorVersion, "/")
+	latestMajorVersionNum, latestMajorVersionURL := latestMajorVersionBanner(um, latestInfo, s.labelUnstableV0)
+	labels := pageLabels(um)
+	if s.labelUnstableV0 && unstableV0(um) {
+		labels = append(labels, pageLabelUnstableV0)
+	}
+	// Only a build context selected by the URL is part of the canonical URL.
+	// One remembered from an earlier page is a preference of the reader.
+	canonicalBC := bc
+	if remembered {
+		canonicalBC = internal.BuildContext{}
 	}
 	page := UnitPage{
 		basePage:              basePage,
@@ -187,27 +270,217 @@ func (s *Server) serveUnitPage(ctx context.Context, w http.ResponseWriter, r *ht
 		Title:                 title,
 		SelectedTab:           tabSettings,
 		URLPath:               constructUnitURL(um.Path, um.ModulePath, info.requestedVersion),
-		CanonicalURLPath:      canonicalURLPath(um),
+		CanonicalURLPath:      canonicalURLPath(um, canonicalBC),
 		DisplayVersion:        displayVersion(um.Version, um.ModulePath),
 		LinkVersion:           lv,
 		LatestURL:             constructUnitURL(um.Path, um.ModulePath, internal.LatestVersion),
 		LatestMinorClass:      latestMinorClass(lv, latestInfo),
 		LatestMajorVersion:    latestMajorVersionNum,
-		LatestMajorVersionURL: latestInfo.MajorUnitPath,
-		PageLabels:            pageLabels(um),
+		LatestMajorVersionURL: latestMajorVersionURL,
+		PageLabels:            labels,
 		PageType:              pageType(um),
 		RedirectedFromPath:    redirectPath,
 	}
+	if version.IsPseudo(um.Version) {
+		page.CommitHash = um.CommitHash
+		if page.CommitHash == "" {
+			// Modules fetched before the hash was stored, or from a proxy
+			// that doesn't report it, still have its prefix in the version.
+			page.CommitHash = version.PseudoVersionRev(um.Version)
+		}
+	}
 
 	page.Details = d
 	main, ok := d.(*MainDetails)
 	if ok {
 		page.MetaDescription = metaDescription(strconv.Itoa(main.ImportedByCount))
 	}
-	s.servePage(ctx, w, tabSettings.TemplateName, page)
+	buf, err := s.renderPage(ctx, tabSettings.TemplateName, page)
+	if err != nil {
+		return err
+	}
+	// Only a page that rendered successfully may be cached.
+	if etag != "" {
+		setETag(w, etag)
+	}
+	if _, err := w.Write(buf); err != nil {
+		log.Errorf(ctx, "Error writing %q page: %v", tabSettings.TemplateName, err)
+	}
 	return nil
 }
 
+// setETag sets the ETag header of a unit page response, and allows caching
+// it. The page depends on the build context and experiments cookies, so the
+// response varies with the Cookie header.
+func setETag(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(longTTL.Seconds())))
+	w.Header().Add("Vary", "Cookie")
+}
+
+// selectedBuildContext returns the build context given by the GOOS and
+// GOARCH query parameters of r, and remembers it in a cookie. If neither
+// parameter is present, it returns the build context in the cookie, and
+// reports that it was remembered. Selecting the empty build context, with
+// "?GOOS=", clears the cookie.
+func selectedBuildContext(w http.ResponseWriter, r *http.Request) (_ internal.BuildContext, remembered bool) {
+	q := r.URL.Query()
+	_, hasGOOS := q["GOOS"]
+	_, hasGOARCH := q["GOARCH"]
+	if hasGOOS || hasGOARCH {
+		bc := internal.BuildContext{GOOS: q.Get("GOOS"), GOARCH: q.Get("GOARCH")}
+		cookie.SetBuildContext(w, bc.GOOS, bc.GOARCH)
+		return bc, false
+	}
+	val, err := cookie.Get(r, cookie.BuildContext)
+	if err != nil {
+		// Don't fail; use the default build context.
+		log.Errorf(r.Context(), "reading build context cookie: %v", err)
+		return internal.BuildContext{}, false
+	}
+	// Ignore a cookie that does not hold a known build context, as the cache
+	// middleware does.
+	bc, ok := internal.ParseBuildContext(val)
+	return bc, ok
+}
+
+// symbolRedirectURL returns the URL of the documentation of a symbol, if the
+// last element of info.fullPath is the name of an exported symbol, like "Get"
+// or "Client.Do", in the package at the rest of the path. For example,
+// /net/http/Client.Do redirects to /net/http#Client.Do. Otherwise it returns
+// the empty string.
+func (s *Server) symbolRedirectURL(ctx context.Context, r *http.Request, ds internal.DataSource, info *urlPathInfo) (_ string, err error) {
+	defer derrors.Wrap(&err, "symbolRedirectURL(%v)", info)
+
+	i := strings.LastIndex(info.fullPath, "/")
+	if i < 0 {
+		return "", nil
+	}
+	pkgPath, name := info.fullPath[:i], info.fullPath[i+1:]
+	for _, id := range strings.SplitN(name, ".", 2) {
+		if !token.IsIdentifier(id) {
+			return "", nil
+		}
+	}
+	if !token.IsExported(name) {
+		return "", nil
+	}
+	um, err := ds.GetUnitMeta(ctx, pkgPath, info.modulePath, info.requestedVersion)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	if !um.IsPackage() {
+		return "", nil
+	}
+	names, err := s.exportedSymbolNames(ctx, ds, um)
+	if err != nil {
+		return "", err
+	}
+	if !names[name] {
+		return "", nil
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/"+name) + "#" + name, nil
+}
+
+// symbolNamesKey returns the cache key for the exported symbol names of the
+// package um.
+func symbolNamesKey(um *internal.UnitMeta) string {
+	return "symbol-names/" + um.ModulePath + "@" + um.Version + "/" + um.Path
+}
+
+// exportedSymbolNames returns the names of the exported symbols of the
+// package um, including those of methods and fields, like "Client.Do". It
+// uses the stored symbols of the package if there are any. Otherwise it
+// renders the documentation, which is expensive, so the result is cached
+// with the main tab details of the module version.
+func (s *Server) exportedSymbolNames(ctx context.Context, ds internal.DataSource, um *internal.UnitMeta) (_ map[string]bool, err error) {
+	bcSyms, err := ds.GetSymbols(ctx, um.Path, um.ModulePath, um.Version)
+	if err != nil && !errors.Is(err, derrors.NotFound) {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, syms := range bcSyms {
+		for name := range symbolsByName(syms) {
+			names[name] = true
+		}
+	}
+	if len(names) > 0 {
+		return names, nil
+	}
+
+	key := symbolNamesKey(um)
+	if s.detailsCache != nil {
+		data, err := s.detailsCache.Get(ctx, key)
+		if err != nil {
+			log.Warningf(ctx, "symbol names cache: %v", err)
+		} else if data != nil {
+			if err := json.Unmarshal(data, &names); err == nil {
+				return names, nil
+			}
+		}
+	}
+	u, err := ds.GetUnit(ctx, um, internal.WithMain)
+	if err != nil {
+		return nil, err
+	}
+	doc := internal.DocumentationForBuildContext(u.Documentation, internal.BuildContext{})
+	if doc != nil && len(doc.Source) > 0 {
+		dt, err := renderDocText(ctx, um, doc)
+		if err != nil {
+			return nil, err
+		}
+		for _, sym := range dt.Symbols {
+			names[sym.Name] = true
+		}
+	}
+	if s.detailsCache != nil {
+		data, err := json.Marshal(names)
+		if err != nil {
+			return nil, err
+		}
+		setKey := cache.MainDetailsSetKey(um.ModulePath, um.Version)
+		if err := s.detailsCache.PutInSet(ctx, setKey, key, data, mainDetailsTTL); err != nil {
+			log.Warningf(ctx, "symbol names cache: %v", err)
+		}
+	}
+	return names, nil
+}
+
+// latestMajorVersionBanner returns the major version and the unit path to
+// link to in the banner about the latest major version of um's module. It
+// returns empty strings if the banner should not be shown.
+//
+// The banner is shown when a later major version of the module path exists.
+// If labelV0 is true, it is also shown when um is at a v0 version and its
+// module has a v1 or later release, to help users move from v0 to v1.
+func latestMajorVersionBanner(um *internal.UnitMeta, latest internal.LatestInfo, labelV0 bool) (majorVersion, unitPath string) {
+	_, currentMajor, _ := module.SplitPathVersion(um.ModulePath)
+	_, latestMajor, ok := module.SplitPathVersion(latest.MajorModulePath)
+	// Show the banner if there was no error getting the latest major version,
+	// and it is different from the major version of the current module path.
+	if ok && currentMajor != latestMajor && latestMajor != "" {
+		return strings.TrimPrefix(latestMajor, "/"), latest.MajorUnitPath
+	}
+	if labelV0 && unstableV0(um) && latest.MinorModulePath == um.ModulePath &&
+		semver.Major(latest.MinorVersion) == "v1" {
+		if latest.UnitExistsAtMinor {
+			return "v1", um.Path
+		}
+		return "v1", um.ModulePath
+	}
+	return "", ""
+}
+
+// unstableV0 reports whether um is at a v0 version. Versions before v1.0.0
+// make no compatibility guarantees. The latest version of a module that has
+// only v0 versions is still its highest v0 version.
+func unstableV0(um *internal.UnitMeta) bool {
+	return um.ModulePath != stdlib.ModulePath && semver.Major(um.Version) == "v0"
+}
+
 func latestMinorClass(version string, latest internal.LatestInfo) string {
 	c := "DetailsHeader-badge"
 	switch {
@@ -263,8 +536,121 @@ func constructUnitURL(fullPath, modulePath, requestedVersion string) string {
 	return fmt.Sprintf("/%s@%s/%s", modulePath, v, strings.TrimPrefix(fullPath, modulePath+"/"))
 }
 
+// vanityRedirectURL returns the URL of the page for the path that the path
+// of info is served under, if it is a vanity import path that has been mapped
+// to a different module path. Otherwise it returns the empty string.
+func vanityRedirectURL(ctx context.Context, r *http.Request, ds internal.DataSource, info *urlPathInfo) (_ string, err error) {
+	defer derrors.Wrap(&err, "vanityRedirectURL(%v)", info)
+
+	target, err := ds.GetPathRedirect(ctx, info.fullPath)
+	if err != nil || target == "" || target == info.fullPath {
+		return "", err
+	}
+	u := "/" + target
+	if info.requestedVersion != internal.LatestVersion {
+		u += "@" + info.requestedVersion
+	}
+	if r.URL.RawQuery != "" {
+		u += "?" + r.URL.RawQuery
+	}
+	return u, nil
+}
+
+// unitPageETag returns a strong ETag for the unit page requested by r, or
+// the empty string if the page may change without the unit changing. Only
+// pages for a concrete version (not @latest or @master, for example) have an
+// ETag; their content is determined by the canonical URL, the query, the
+// selected build context, the active experiments, the time the module was
+// last stored, and the version of the app that renders them.
+func (s *Server) unitPageETag(r *http.Request, info *urlPathInfo, um *internal.UnitMeta, bc internal.BuildContext) string {
+	if info.requestedVersion != um.Version && info.requestedVersion != linkVersion(um.Version, um.ModulePath) {
+		return ""
+	}
+	if _, err := r.Cookie(cookie.AlternativeModuleFlash); err == nil {
+		// The page will display a one-time banner.
+		return ""
+	}
+	h := sha256.New()
+	experiments := experiment.FromContext(r.Context()).Active()
+	sort.Strings(experiments)
+	for _, v := range []string{
+		canonicalURLPath(um, bc), um.Version, r.URL.RawQuery, bc.GOOS, bc.GOARCH, s.appVersionLabel,
+		strings.Join(experiments, ","), um.ModuleUpdatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		io.WriteString(h, v)
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%q", hex.EncodeToString(h.Sum(nil))[:32])
+}
+
+// etagMatches reports whether the value of an If-None-Match header matches
+// etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // canonicalURLPath constructs a URL path to the unit that always includes the
-// resolved version.
-func canonicalURLPath(um *internal.UnitMeta) string {
-	return constructUnitURL(um.Path, um.ModulePath, linkVersion(um.Version, um.ModulePath))
+// resolved version. If bc is not empty, the path is followed by GOOS and
+// GOARCH query parameters that select it, so that the URL shows the same
+// documentation to everyone who follows it.
+func canonicalURLPath(um *internal.UnitMeta, bc internal.BuildContext) string {
+	u := constructUnitURL(um.Path, um.ModulePath, linkVersion(um.Version, um.ModulePath))
+	if q := buildContextQuery(bc).Encode(); q != "" {
+		u += "?" + q
+	}
+	return u
+}
+
+// buildContextQuery returns the GOOS and GOARCH query parameters that select
+// bc. Empty fields are omitted.
+func buildContextQuery(bc internal.BuildContext) url.Values {
+	q := url.Values{}
+	if bc.GOOS != "" {
+		q.Set("GOOS", bc.GOOS)
+	}
+	if bc.GOARCH != "" {
+		q.Set("GOARCH", bc.GOARCH)
+	}
+	return q
+}
+
+// buildContextSegmentRedirectURL returns the URL to redirect to if the last
+// element of u's path selects a build context, as in
+// /net/http/GOOS=windows or /net/http/GOOS=windows,GOARCH=amd64. The
+// element is removed from the path and replaced by the equivalent GOOS and
+// GOARCH query parameters. Since "=" cannot appear in an import path, such
+// an element never names a package. If the last element does not select a
+// build context, it returns the empty string.
+func buildContextSegmentRedirectURL(u *url.URL) string {
+	i := strings.LastIndex(u.Path, "/")
+	if i <= 0 {
+		return ""
+	}
+	var bc internal.BuildContext
+	for _, kv := range strings.Split(u.Path[i+1:], ",") {
+		j := strings.IndexByte(kv, '=')
+		if j < 0 || j == len(kv)-1 {
+			return ""
+		}
+		k, v := kv[:j], kv[j+1:]
+		switch k {
+		case "GOOS":
+			bc.GOOS = v
+		case "GOARCH":
+			bc.GOARCH = v
+		default:
+			return ""
+		}
+	}
+	q := u.Query()
+	for k, vs := range buildContextQuery(bc) {
+		q[k] = vs
+	}
+	return u.Path[:i] + "?" + q.Encode()
 }
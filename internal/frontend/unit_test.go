@@ -5,9 +5,16 @@
 package frontend
 
 import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cookie"
+	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
 
@@ -45,30 +52,63 @@ func TestUnitURLPath(t *testing.T) {
 
 func TestCanonicalURLPath(t *testing.T) {
 	for _, test := range []struct {
-		path, modpath, version, want string
+		path, modpath, version string
+		bc                     internal.BuildContext
+		want                   string
 	}{
 
 		{
-			"m.com/p", "m.com", "v1.2.3",
+			"m.com/p", "m.com", "v1.2.3", internal.BuildContext{},
 			"/m.com@v1.2.3/p",
 		},
 
 		{
-			"math", "std", "v1.2.3",
+			"math", "std", "v1.2.3", internal.BuildContext{},
 			"/math@go1.2.3",
 		},
 		{
-			"math", "std", "go1.2.3",
+			"math", "std", "go1.2.3", internal.BuildContext{},
 			"/math@go1.2.3",
 		},
+		{
+			"m.com/p", "m.com", "v1.2.3", internal.BuildContext{GOOS: "windows", GOARCH: "amd64"},
+			"/m.com@v1.2.3/p?GOARCH=amd64&GOOS=windows",
+		},
+		{
+			"math", "std", "v1.2.3", internal.BuildContext{GOOS: "js"},
+			"/math@go1.2.3?GOOS=js",
+		},
 	} {
 		um := &internal.UnitMeta{
 			Path:       test.path,
 			ModuleInfo: internal.ModuleInfo{ModulePath: test.modpath, Version: test.version},
 		}
-		got := canonicalURLPath(um)
+		got := canonicalURLPath(um, test.bc)
 		if got != test.want {
-			t.Errorf("canonicalURLPath(%q, %q, %q) = %q, want %q", test.path, test.modpath, test.version, got, test.want)
+			t.Errorf("canonicalURLPath(%q, %q, %q, %v) = %q, want %q", test.path, test.modpath, test.version, test.bc, got, test.want)
+		}
+	}
+}
+
+func TestBuildContextSegmentRedirectURL(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"/net/http", ""},
+		{"/net/http/GOOS=windows", "/net/http?GOOS=windows"},
+		{"/net/http@go1.16/GOOS=windows,GOARCH=386", "/net/http@go1.16?GOARCH=386&GOOS=windows"},
+		{"/m.com/p/GOARCH=arm64?tab=doc", "/m.com/p?GOARCH=arm64&tab=doc"},
+		{"/m.com/p/GOOS=linux?GOOS=windows", "/m.com/p?GOOS=linux"},
+		{"/m.com/p/GOOS=", ""},
+		{"/m.com/p/FOO=bar", ""},
+		{"/GOOS=linux", ""},
+	} {
+		u, err := url.Parse(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buildContextSegmentRedirectURL(u); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.in, got, test.want)
 		}
 	}
 }
@@ -151,3 +191,193 @@ func TestMetaDescription(t *testing.T) {
 		}
 	}
 }
+
+func TestLatestMajorVersionBanner(t *testing.T) {
+	const modulePath = "m.com"
+	for _, test := range []struct {
+		name                string
+		modulePath, version string
+		latest              internal.LatestInfo
+		labelV0             bool
+		wantMajor, wantPath string
+	}{
+		{
+			name:       "later major version",
+			modulePath: modulePath,
+			version:    "v1.2.0",
+			latest: internal.LatestInfo{
+				MinorVersion: "v1.2.0", MinorModulePath: modulePath,
+				MajorModulePath: modulePath + "/v3", MajorUnitPath: modulePath + "/v3/p",
+			},
+			wantMajor: "v3",
+			wantPath:  modulePath + "/v3/p",
+		},
+		{
+			name:       "v0 only",
+			modulePath: modulePath,
+			version:    "v0.3.0",
+			latest: internal.LatestInfo{
+				MinorVersion: "v0.3.0", MinorModulePath: modulePath, UnitExistsAtMinor: true,
+				MajorModulePath: modulePath, MajorUnitPath: modulePath + "/p",
+			},
+			labelV0: true,
+		},
+		{
+			name:       "v0 to v1",
+			modulePath: modulePath,
+			version:    "v0.3.0",
+			latest: internal.LatestInfo{
+				MinorVersion: "v1.0.0", MinorModulePath: modulePath, UnitExistsAtMinor: true,
+				MajorModulePath: modulePath, MajorUnitPath: modulePath + "/p",
+			},
+			labelV0:   true,
+			wantMajor: "v1",
+			wantPath:  modulePath + "/p",
+		},
+		{
+			name:       "v0 to v1, unit not at v1",
+			modulePath: modulePath,
+			version:    "v0.3.0",
+			latest: internal.LatestInfo{
+				MinorVersion: "v1.0.0", MinorModulePath: modulePath,
+				MajorModulePath: modulePath, MajorUnitPath: modulePath,
+			},
+			labelV0:   true,
+			wantMajor: "v1",
+			wantPath:  modulePath,
+		},
+		{
+			name:       "v0 to v1, label disabled",
+			modulePath: modulePath,
+			version:    "v0.3.0",
+			latest: internal.LatestInfo{
+				MinorVersion: "v1.0.0", MinorModulePath: modulePath, UnitExistsAtMinor: true,
+				MajorModulePath: modulePath, MajorUnitPath: modulePath + "/p",
+			},
+		},
+		{
+			name:       "v0 to v2",
+			modulePath: modulePath,
+			version:    "v0.3.0",
+			latest: internal.LatestInfo{
+				MinorVersion: "v1.0.0", MinorModulePath: modulePath, UnitExistsAtMinor: true,
+				MajorModulePath: modulePath + "/v2", MajorUnitPath: modulePath + "/v2/p",
+			},
+			labelV0:   true,
+			wantMajor: "v2",
+			wantPath:  modulePath + "/v2/p",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			um := &internal.UnitMeta{
+				Path:       test.modulePath + "/p",
+				ModuleInfo: internal.ModuleInfo{ModulePath: test.modulePath, Version: test.version},
+			}
+			gotMajor, gotPath := latestMajorVersionBanner(um, test.latest, test.labelV0)
+			if gotMajor != test.wantMajor || gotPath != test.wantPath {
+				t.Errorf("got (%q, %q), want (%q, %q)", gotMajor, gotPath, test.wantMajor, test.wantPath)
+			}
+		})
+	}
+}
+
+func TestSelectedBuildContext(t *testing.T) {
+	// Selecting a build context sets the cookie.
+	w := httptest.NewRecorder()
+	got, remembered := selectedBuildContext(w, httptest.NewRequest("GET", "/p?GOOS=windows", nil))
+	if want := (internal.BuildContext{GOOS: "windows"}); got != want || remembered {
+		t.Errorf("with query: got %v, %t; want %v, false", got, remembered, want)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != cookie.BuildContext {
+		t.Fatalf("got cookies %v, want one %q cookie", cookies, cookie.BuildContext)
+	}
+
+	// A later request without query parameters uses the cookie.
+	r := httptest.NewRequest("GET", "/q", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	got, remembered = selectedBuildContext(w, r)
+	if want := (internal.BuildContext{GOOS: "windows"}); got != want || !remembered {
+		t.Errorf("with cookie: got %v, %t; want %v, true", got, remembered, want)
+	}
+	if c := w.Result().Cookies(); len(c) != 0 {
+		t.Errorf("with cookie: got cookies %v, want none", c)
+	}
+
+	// A cookie that does not hold a known build context is ignored.
+	r = httptest.NewRequest("GET", "/q", nil)
+	r.AddCookie(&http.Cookie{Name: cookie.BuildContext, Value: base64.URLEncoding.EncodeToString([]byte("plan10/amd64"))})
+	got, remembered = selectedBuildContext(httptest.NewRecorder(), r)
+	if want := (internal.BuildContext{}); got != want || remembered {
+		t.Errorf("with unknown cookie: got %v, %t; want %v, false", got, remembered, want)
+	}
+
+	// Query parameters take precedence over the cookie.
+	r = httptest.NewRequest("GET", "/q?GOOS=linux&GOARCH=amd64", nil)
+	r.AddCookie(cookies[0])
+	got, remembered = selectedBuildContext(httptest.NewRecorder(), r)
+	if want := (internal.BuildContext{GOOS: "linux", GOARCH: "amd64"}); got != want || remembered {
+		t.Errorf("with query and cookie: got %v, %t; want %v, false", got, remembered, want)
+	}
+
+	// Selecting the default build context clears the cookie.
+	r = httptest.NewRequest("GET", "/q?GOOS=", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	got, _ = selectedBuildContext(w, r)
+	if want := (internal.BuildContext{}); got != want {
+		t.Errorf("with empty query: got %v, want %v", got, want)
+	}
+	cleared := w.Result().Cookies()
+	if len(cleared) != 1 || cleared[0].Name != cookie.BuildContext || cleared[0].Expires.After(time.Unix(1, 0)) {
+		t.Errorf("with empty query: got cookies %v, want %q deleted", cleared, cookie.BuildContext)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+	for _, test := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	} {
+		if got := etagMatches(test.header, etag); got != test.want {
+			t.Errorf("etagMatches(%q, %q) = %t, want %t", test.header, etag, got, test.want)
+		}
+	}
+}
+
+func TestUnitPageETag(t *testing.T) {
+	s := &Server{appVersionLabel: "1"}
+	info := &urlPathInfo{fullPath: sample.PackagePath, modulePath: sample.ModulePath, requestedVersion: sample.VersionString}
+	um := sample.UnitMeta(sample.PackagePath, sample.ModulePath, sample.VersionString, sample.PackageName, true)
+	um.ModuleUpdatedAt = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	etag := func(um *internal.UnitMeta, experiments ...string) string {
+		r := httptest.NewRequest("GET", "/"+sample.PackagePath+"@"+sample.VersionString, nil)
+		r = r.WithContext(experiment.NewContext(r.Context(), experiments...))
+		return s.unitPageETag(r, info, um, internal.BuildContext{})
+	}
+
+	base := etag(um)
+	if base == "" {
+		t.Fatal("no ETag")
+	}
+	if got := etag(um); got != base {
+		t.Errorf("same request: got ETag %s, want %s", got, base)
+	}
+	if got := etag(um, "exp"); got == base {
+		t.Error("ETag does not depend on the active experiments")
+	}
+	reprocessed := *um
+	reprocessed.ModuleUpdatedAt = um.ModuleUpdatedAt.Add(time.Hour)
+	if got := etag(&reprocessed); got == base {
+		t.Error("ETag does not depend on the time the module was stored")
+	}
+}
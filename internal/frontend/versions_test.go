@@ -184,6 +184,56 @@ func TestFetchPackageVersionsDetails(t *testing.T) {
 	}
 }
 
+func TestFetchVersionsDetailsRetractions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, internal.ExperimentRetractions)
+	defer postgres.ResetTestDB(testDB, t)
+
+	pkgPath := modulePath1 + "/" + sample.Suffix
+	for _, v := range []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0", "v1.4.0"} {
+		postgres.MustInsertModule(ctx, t, testDB, sample.Module(modulePath1, v, sample.Suffix))
+	}
+	lmv, err := internal.NewLatestModuleVersions(modulePath1, "v1.4.0", "v1.4.0", "", []byte(`
+		module test.com/module
+
+		retract v1.0.0 // bad release
+		retract [v1.2.0, v1.3.0] // broken API
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.UpdateLatestModuleVersions(ctx, lmv); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fetchVersionsDetails(ctx, testDB, pkgPath, modulePath1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.ThisModule) != 1 {
+		t.Fatalf("got %d version lists, want 1", len(got.ThisModule))
+	}
+	type retraction struct {
+		Retracted bool
+		Rationale string
+	}
+	gotRetractions := map[string]retraction{}
+	for _, vs := range got.ThisModule[0].Versions {
+		gotRetractions[vs.Version] = retraction{vs.Retracted, vs.RetractionRationale}
+	}
+	want := map[string]retraction{
+		"v1.4.0": {},
+		"v1.3.0": {true, "broken API"},
+		"v1.2.0": {true, "broken API"},
+		"v1.1.0": {},
+		"v1.0.0": {true, "bad release"},
+	}
+	if diff := cmp.Diff(want, gotRetractions); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestPathInVersion(t *testing.T) {
 	tests := []struct {
 		v1Path, modulePath, want string
@@ -0,0 +1,405 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/stdlib"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+)
+
+// vetAnalyzers are the vet checks run on a snippet by handleFmt. They are the
+// checks of "go vet" that only need the snippet itself, and not facts about
+// the packages it imports.
+var vetAnalyzers = []*analysis.Analyzer{
+	assign.Analyzer,
+	atomic.Analyzer,
+	bools.Analyzer,
+	copylock.Analyzer,
+	nilfunc.Analyzer,
+	printf.Analyzer,
+	shift.Analyzer,
+	stdmethods.Analyzer,
+	unreachable.Analyzer,
+	unusedresult.Analyzer,
+}
+
+const (
+	// maxVetSourceSize is the size of the largest snippet that vetSource
+	// checks.
+	maxVetSourceSize = 64 * 1024
+
+	// vetTimeout bounds the time spent checking a snippet, including any
+	// time spent waiting for another check to finish.
+	vetTimeout = 10 * time.Second
+)
+
+// vetSem limits the number of snippets that are checked at once.
+var vetSem = make(chan struct{}, 4)
+
+// errVetSourceTooLarge is returned by vetSource for a snippet larger than
+// maxVetSourceSize.
+var errVetSourceTooLarge = errors.New("program too large to vet")
+
+// stdlibZip returns the module zip of the standard library at a version. It
+// is a variable for testing.
+var stdlibZip = func(version string) (*zip.Reader, error) {
+	zr, _, _, err := stdlib.Zip(version)
+	return zr, err
+}
+
+// A vetStdlib holds the standard library importers used by vet, one for
+// each of a fixed set of versions. The standard library is read when the
+// server starts, by preload, and never while serving a request.
+type vetStdlib struct {
+	versions []string // resolved versions to preload
+
+	mu        sync.Mutex
+	importers map[string]*stdImporter // by resolved version, once loaded
+}
+
+// newVetStdlib returns a vetStdlib for the given Go versions, which may be
+// semantic versions or Go tags like "go1.16". Invalid versions are logged
+// and skipped.
+func newVetStdlib(versions []string) *vetStdlib {
+	v := &vetStdlib{importers: map[string]*stdImporter{}}
+	for _, version := range versions {
+		if strings.HasPrefix(version, "go") {
+			version = stdlib.VersionForTag(version)
+		}
+		if !semver.IsValid(version) {
+			log.Errorf(context.Background(), "vet: invalid standard library version %q", version)
+			continue
+		}
+		v.versions = append(v.versions, version)
+	}
+	return v
+}
+
+// preload reads the standard library at each version of v. Versions that
+// cannot be read are logged, and vet is unavailable for them.
+func (v *vetStdlib) preload(ctx context.Context) {
+	for _, version := range v.versions {
+		if ctx.Err() != nil {
+			return
+		}
+		zr, err := stdlibZip(version)
+		if err != nil {
+			log.Errorf(ctx, "vet: reading standard library at %s: %v", version, err)
+			continue
+		}
+		imp := newStdImporter(version, zr)
+		v.mu.Lock()
+		v.importers[version] = imp
+		v.mu.Unlock()
+		log.Infof(ctx, "vet: loaded standard library at %s", version)
+	}
+}
+
+// importer returns the importer for the standard library at the given
+// resolved version, or nil if that version has not been loaded.
+func (v *vetStdlib) importer(version string) *stdImporter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.importers[version]
+}
+
+// A stdImporter imports packages by type-checking them from the source files
+// in the standard library module zip at version. Only standard library
+// imports are supported. Packages are type-checked the first time they are
+// imported, and kept.
+type stdImporter struct {
+	version string
+	dirs    map[string][]*zip.File // Go files of each directory, by import path
+	bctx    build.Context          // selects the files that are part of a package
+
+	sem  chan struct{} // held while importing; guards the fields below
+	fset *token.FileSet
+	pkgs map[string]*types.Package
+}
+
+// newStdImporter returns an importer for the standard library in zr, which is
+// at the given version.
+func newStdImporter(version string, zr *zip.Reader) *stdImporter {
+	s := &stdImporter{
+		version: version,
+		dirs:    map[string][]*zip.File{},
+		sem:     make(chan struct{}, 1),
+		fset:    token.NewFileSet(),
+		pkgs:    map[string]*types.Package{},
+	}
+	prefix := stdlib.ModulePath + "@" + version + "/"
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		name := strings.TrimPrefix(f.Name, prefix)
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		dir := path.Dir(name)
+		s.dirs[dir] = append(s.dirs[dir], f)
+		files[name] = f
+	}
+	s.bctx = build.Default
+	s.bctx.GOOS = "linux"
+	s.bctx.GOARCH = "amd64"
+	s.bctx.CgoEnabled = false
+	s.bctx.JoinPath = path.Join
+	s.bctx.OpenFile = func(name string) (io.ReadCloser, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		return f.Open()
+	}
+	return s
+}
+
+// forContext returns a types.Importer that stops importing once ctx is done.
+func (s *stdImporter) forContext(ctx context.Context) types.Importer {
+	return importerFunc(func(path string) (*types.Package, error) {
+		if !stdlib.Contains(path) {
+			return nil, fmt.Errorf("cannot vet import of %q: only standard library imports are supported", path)
+		}
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-s.sem }()
+		return s.load(ctx, path)
+	})
+}
+
+// load returns the package with the given import path, type-checking it and
+// its imports if they have not been loaded already. Imports of packages
+// vendored into the standard library are resolved to their vendor
+// directory. Packages checked when ctx is done are not kept, since their
+// imports may be missing. s.sem must be held.
+func (s *stdImporter) load(ctx context.Context, importPath string) (*types.Package, error) {
+	if importPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if p, ok := s.pkgs[importPath]; ok {
+		return p, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, ok := s.dirs[importPath]; !ok {
+		if _, ok := s.dirs["vendor/"+importPath]; ok {
+			return s.load(ctx, "vendor/"+importPath)
+		}
+		return nil, fmt.Errorf("package %q not found in %s@%s", importPath, stdlib.ModulePath, s.version)
+	}
+	var files []*ast.File
+	for _, f := range s.dirs[importPath] {
+		if ok, err := s.bctx.MatchFile(importPath, path.Base(f.Name)); err != nil || !ok {
+			continue
+		}
+		src, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(s.fset, f.Name, src, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	conf := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			return s.load(ctx, path)
+		}),
+		IgnoreFuncBodies: true,
+		FakeImportC:      true,
+		// Keep going after errors, so that a construct the type checker
+		// does not support does not hide the rest of the package.
+		Error: func(error) {},
+	}
+	p, _ := conf.Check(importPath, s.fset, files, nil)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.pkgs[importPath] = p
+	return p, nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// readZipFile returns the contents of f.
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// vetDiagnostic is a problem reported by vet, or by the type checker if the
+// snippet does not type-check.
+type vetDiagnostic struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// vetSource type-checks the single Go file src against the standard library
+// of imp and runs vetAnalyzers on it. It returns the diagnostics sorted by
+// line. If src does not type-check, the type errors are returned instead,
+// since the analyzers assume well-typed code.
+//
+// It fails if src is larger than maxVetSourceSize, or if the check does not
+// finish within vetTimeout.
+func vetSource(ctx context.Context, src []byte, imp *stdImporter) ([]vetDiagnostic, error) {
+	if len(src) > maxVetSourceSize {
+		return nil, errVetSourceTooLarge
+	}
+	ctx, cancel := context.WithTimeout(ctx, vetTimeout)
+	defer cancel()
+	select {
+	case vetSem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("vet: %v", ctx.Err())
+	}
+	type result struct {
+		diags []vetDiagnostic
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		// The semaphore is held until the check finishes. An abandoned check
+		// stops importing once ctx is done, so it soon gives its slot up.
+		defer func() { <-vetSem }()
+		diags, err := vet(ctx, src, imp)
+		done <- result{diags, err}
+	}()
+	select {
+	case res := <-done:
+		return res.diags, res.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("vet: %v", ctx.Err())
+	}
+}
+
+// vet does the work of vetSource.
+func vet(ctx context.Context, src []byte, imp *stdImporter) ([]vetDiagnostic, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "prog.go", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var diags []vetDiagnostic
+	conf := &types.Config{
+		Importer: imp.forContext(ctx),
+		Error: func(err error) {
+			if terr, ok := err.(types.Error); ok {
+				diags = append(diags, vetDiagnostic{
+					Line:    fset.Position(terr.Pos).Line,
+					Message: terr.Msg,
+				})
+			}
+		},
+	}
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Scopes:     map[ast.Node]*types.Scope{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	pkg, _ := conf.Check(file.Name.Name, fset, []*ast.File{file}, info)
+	if err := ctx.Err(); err != nil {
+		// Imports failed because the check was abandoned, so the type
+		// errors are not the snippet's fault.
+		return nil, err
+	}
+	if len(diags) > 0 {
+		return diags, nil
+	}
+
+	results := map[*analysis.Analyzer]interface{}{}
+	var run func(a *analysis.Analyzer) error
+	run = func(a *analysis.Analyzer) error {
+		if _, ok := results[a]; ok {
+			return nil
+		}
+		resultOf := map[*analysis.Analyzer]interface{}{}
+		for _, req := range a.Requires {
+			if err := run(req); err != nil {
+				return err
+			}
+			resultOf[req] = results[req]
+		}
+		pass := &analysis.Pass{
+			Analyzer:   a,
+			Fset:       fset,
+			Files:      []*ast.File{file},
+			Pkg:        pkg,
+			TypesInfo:  info,
+			TypesSizes: types.SizesFor("gc", "amd64"),
+			ResultOf:   resultOf,
+			Report: func(d analysis.Diagnostic) {
+				diags = append(diags, vetDiagnostic{
+					Line:    fset.Position(d.Pos).Line,
+					Message: d.Message,
+				})
+			},
+			// There is only one package, so there are no facts to import,
+			// and facts exported by the analyzers can be dropped.
+			ImportObjectFact:  func(types.Object, analysis.Fact) bool { return false },
+			ImportPackageFact: func(*types.Package, analysis.Fact) bool { return false },
+			ExportObjectFact:  func(types.Object, analysis.Fact) {},
+			ExportPackageFact: func(analysis.Fact) {},
+			AllObjectFacts:    func() []analysis.ObjectFact { return nil },
+			AllPackageFacts:   func() []analysis.PackageFact { return nil },
+		}
+		res, err := a.Run(pass)
+		if err != nil {
+			return fmt.Errorf("%s: %v", a.Name, err)
+		}
+		results[a] = res
+		return nil
+	}
+	for _, a := range vetAnalyzers {
+		if err := run(a); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Line < diags[j].Line })
+	return diags, nil
+}
@@ -16,6 +16,7 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/printer"
 	"go/token"
 	"sort"
@@ -43,6 +44,10 @@ type ModuleInfo struct {
 	ResolvedVersion string
 	// ModulePackages is the set of all full package paths in the module.
 	ModulePackages map[string]bool
+	// NonImportablePackages is the set of full package paths in the module
+	// that cannot be imported from outside it, like internal packages.
+	// References to them are not linked.
+	NonImportablePackages map[string]bool
 }
 
 // RenderOptions are options for Render.
@@ -96,6 +101,29 @@ type Parts struct {
 	Outline       safehtml.HTML // outline for large screens
 	MobileOutline safehtml.HTML // outline for mobile
 	Links         []render.Link // "Links" section of package doc
+	Examples      []*Example    // examples, sorted by associated symbol
+}
+
+// Example summarizes a package example, for use outside of the rendered
+// documentation.
+type Example struct {
+	// Name is the name of the example function without the "Example"
+	// prefix, for example "Decoder_Decode_stream".
+	Name string
+	// Suffix is the optional suffix of the example name, for example
+	// "stream".
+	Suffix string
+	// AssociatedSymbol is the symbol the example is attached to, for example
+	// "Decoder.Decode". It is empty for package examples.
+	AssociatedSymbol string
+	// Code is the formatted source of the example. It is a complete program
+	// if the example is runnable, and the body of the example function
+	// otherwise.
+	Code string
+	// Output is the expected output of the example, if any.
+	Output string
+	// Runnable reports whether the example can be run in the Go playground.
+	Runnable bool
 }
 
 // Render renders package documentation HTML for the
@@ -138,7 +166,8 @@ func RenderParts(ctx context.Context, fset *token.FileSet, p *doc.Package, opt R
 		MobileOutline: exec("sidenav-mobile.tmpl"),
 		// links must be called after body, because the call to
 		// render_doc_extract_links in body.tmpl creates the links.
-		Links: links(),
+		Links:    links(),
+		Examples: exampleSummaries(fset, data.Examples),
 	}
 	if err != nil {
 		return nil, err
@@ -187,6 +216,9 @@ func renderInfo(ctx context.Context, fset *token.FileSet, p *doc.Package, opt Re
 			// the same module.
 			versionedPath := path
 			if opt.ModInfo != nil {
+				if opt.ModInfo.NonImportablePackages[path] {
+					return ""
+				}
 				versionedPath = versionedPkgPath(path, opt.ModInfo)
 			}
 			return "/" + versionedPath
@@ -325,6 +357,31 @@ func collectExamples(p *doc.Package) *examples {
 	return exs
 }
 
+// exampleSummaries returns an Example for each example in exs.
+func exampleSummaries(fset *token.FileSet, exs *examples) []*Example {
+	var summaries []*Example
+	for _, ex := range exs.List {
+		var node interface{} = ex.Code()
+		if ex.Play != nil {
+			node = ex.Play
+		}
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, node); err != nil {
+			// Leave the code empty rather than failing the whole page.
+			buf.Reset()
+		}
+		summaries = append(summaries, &Example{
+			Name:             ex.Name,
+			Suffix:           ex.Example.Suffix,
+			AssociatedSymbol: ex.ParentID,
+			Code:             buf.String(),
+			Output:           ex.Output,
+			Runnable:         ex.Play != nil,
+		})
+	}
+	return summaries
+}
+
 func exampleID(id, suffix string) safehtml.Identifier {
 	switch {
 	case id == "" && suffix == "":
@@ -235,6 +235,52 @@ func main() {
 	}
 }
 
+func TestExampleSummaries(t *testing.T) {
+	LoadTemplates(templateSource)
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"jsonlike.go", "jsonlike_example_test.go"} {
+		code, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parser.ParseFile(fset, name, code, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	d, err := doc.NewFromFiles(fset, files, "example.com/jsonlike", doc.AllDecls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, err := RenderParts(context.Background(), fset, d, RenderOptions{
+		FileLinkFunc:   func(string) string { return "file" },
+		SourceLinkFunc: func(ast.Node) string { return "src" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Example{
+		{Name: "", AssociatedSymbol: "", Output: "package example\n", Runnable: true},
+		{Name: "Decoder_Decode_stream", Suffix: "stream", AssociatedSymbol: "Decoder.Decode", Output: "<nil> map[a:1]\n", Runnable: true},
+		{Name: "Marshal", AssociatedSymbol: "Marshal", Output: "{\"a\":1} <nil>\n", Runnable: true},
+		{Name: "NewDecoder", AssociatedSymbol: "NewDecoder", Runnable: false},
+	}
+	if diff := cmp.Diff(want, parts.Examples, cmpopts.IgnoreFields(Example{}, "Code")); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	for _, ex := range parts.Examples {
+		if got := strings.HasPrefix(ex.Code, "package main\n"); got != ex.Runnable {
+			t.Errorf("%q: code is a complete program = %t, want %t:\n%s", ex.Name, got, ex.Runnable, ex.Code)
+		}
+		if !strings.Contains(ex.Code, "NewDecoder") && !strings.Contains(ex.Code, "Println") {
+			t.Errorf("%q: code does not contain the example body:\n%s", ex.Name, ex.Code)
+		}
+	}
+}
+
 func TestLinkHTML(t *testing.T) {
 	for _, test := range []struct {
 		name string
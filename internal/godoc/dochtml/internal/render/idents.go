@@ -201,11 +201,15 @@ type identifierResolver struct {
 // optionally a specific identifier in that package.
 // The pkgPath may be empty, indicating that this is an anchor only URL.
 // The id may be empty, indicating that this refers to the package itself.
+// It returns the empty string if the package is not to be linked.
 func (r identifierResolver) toURL(pkgPath, id string) (url string) {
 	if pkgPath != "" {
 		url = "/" + pkgPath
 		if r.packageURL != nil {
 			url = r.packageURL(pkgPath)
+			if url == "" {
+				return ""
+			}
 		}
 	}
 	if id != "" {
@@ -294,6 +298,11 @@ linkify:
 
 		path, name, _ := r.lookup(altWord[:i])
 		u := r.toURL(path, name)
+		if u == "" {
+			outs = append(outs, safehtml.HTMLEscaped(s))
+			outs = append(outs, safehtml.HTMLEscaped("."))
+			continue
+		}
 		html, err := LinkTemplate.ExecuteToHTML(Link{Href: u, Text: s})
 		if err != nil {
 			html = safehtml.HTMLEscaped("[" + err.Error() + "]")
@@ -53,6 +53,8 @@ type Options struct {
 
 	// PackageURL is a function that given a package path,
 	// returns a URL for navigating to the godoc for that package.
+	// If it returns the empty string, neither the package nor its
+	// identifiers are linked.
 	//
 	// Only relevant for HTML formatting.
 	PackageURL func(pkgPath string) (url string)
@@ -0,0 +1,22 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsonlike is a small package shaped like encoding/json, used to test
+// the collection of examples.
+package jsonlike
+
+import "io"
+
+// Marshal returns the JSON encoding of v.
+func Marshal(v interface{}) ([]byte, error) { return nil, nil }
+
+// A Decoder reads and decodes JSON values from an input stream.
+type Decoder struct{}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder { return nil }
+
+// Decode reads the next JSON-encoded value from its input and stores it in
+// the value pointed to by v.
+func (dec *Decoder) Decode(v interface{}) error { return nil }
@@ -0,0 +1,37 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonlike_test
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/jsonlike"
+)
+
+func Example() {
+	fmt.Println("package example")
+	// Output: package example
+}
+
+func ExampleMarshal() {
+	b, err := jsonlike.Marshal(map[string]int{"a": 1})
+	fmt.Println(string(b), err)
+	// Output: {"a":1} <nil>
+}
+
+func ExampleDecoder_Decode_stream() {
+	dec := jsonlike.NewDecoder(strings.NewReader(`{"a": 1}`))
+	var v map[string]int
+	fmt.Println(dec.Decode(&v), v)
+	// Output: <nil> map[a:1]
+}
+
+// The example refers to an identifier that is not declared anywhere, so it
+// cannot be run in the playground.
+func ExampleNewDecoder() {
+	dec := jsonlike.NewDecoder(input)
+	_ = dec
+}
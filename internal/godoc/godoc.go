@@ -8,6 +8,8 @@ package godoc
 import (
 	"go/ast"
 	"go/token"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/pkgsite/internal/godoc/dochtml"
@@ -93,3 +95,25 @@ func removeUnusedASTNodes(pf *ast.File) {
 	// Don't remove pf.Comments; they may contain Notes.
 	pf.Decls = decls
 }
+
+// Imports returns the sorted, distinct import paths of the package's files,
+// excluding test files.
+func (p *Package) Imports() []string {
+	seen := map[string]bool{}
+	var imports []string
+	for _, f := range p.Files {
+		if strings.HasSuffix(f.Name, "_test.go") {
+			continue
+		}
+		for _, is := range f.AST.Imports {
+			path, err := strconv.Unquote(is.Path.Value)
+			if err != nil || seen[path] {
+				continue
+			}
+			seen[path] = true
+			imports = append(imports, path)
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
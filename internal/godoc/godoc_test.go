@@ -110,3 +110,23 @@ func (t) U()
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
 	}
 }
+
+func TestPackageImports(t *testing.T) {
+	fset := token.NewFileSet()
+	p := NewPackage(fset, nil)
+	for name, src := range map[string]string{
+		"a.go":      `package p; import ("os"; "fmt")`,
+		"b.go":      `package p; import f "fmt"; import _ "embed"`,
+		"a_test.go": `package p; import "testing"`,
+	} {
+		pf, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.AddFile(pf, true)
+	}
+	want := []string{"embed", "fmt", "os"}
+	if diff := cmp.Diff(want, p.Imports()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -48,6 +48,14 @@ type Example struct {
 //     top-level function, type, variable, or constant declaration other
 //     than the example function.
 func Examples(fset *token.FileSet, testFiles ...*ast.File) []*Example {
+	return examples(fset, "", "", testFiles)
+}
+
+// examples is like Examples, but it is also given the import path and name of
+// the package the examples document, if known. Playable examples that refer
+// to that package import it by its actual name, which need not be the one
+// assumed from its import path.
+func examples(fset *token.FileSet, importPath, pkgName string, testFiles []*ast.File) []*Example {
 	var list []*Example
 	for _, file := range testFiles {
 		hasTests := false // file contains tests or benchmarks
@@ -86,7 +94,7 @@ func Examples(fset *token.FileSet, testFiles ...*ast.File) []*Example {
 				Name:        name[len("Example"):],
 				Doc:         doc,
 				Code:        f.Body,
-				Play:        playExample(fset, file, f),
+				Play:        playExample(fset, file, f, importPath, pkgName),
 				Comments:    file.Comments,
 				Output:      output,
 				Unordered:   unordered,
@@ -149,7 +157,7 @@ func isTest(name, prefix string) bool {
 
 // playExample synthesizes a new *ast.File based on the provided
 // file with the provided function body as the body of main.
-func playExample(fset *token.FileSet, file *ast.File, f *ast.FuncDecl) *ast.File {
+func playExample(fset *token.FileSet, file *ast.File, f *ast.FuncDecl, importPath, pkgName string) *ast.File {
 	body := f.Body
 	tokenFile := fset.File(file.Package)
 	if !strings.HasSuffix(file.Name.Name, "_test") {
@@ -199,8 +207,9 @@ func playExample(fset *token.FileSet, file *ast.File, f *ast.FuncDecl) *ast.File
 	}
 
 	// Use unresolved identifiers to determine the imports used by this
-	// example. The heuristic assumes package names match base import
-	// paths for imports w/o renames (should be good enough most of the time).
+	// example. The heuristic assumes package names can be derived from
+	// import paths for imports w/o renames (should be good enough most of
+	// the time), except for the documented package, whose name is known.
 	namedImports := make(map[string]string) // [name]path
 	var blankImports []ast.Spec             // _ imports
 	for _, s := range file.Imports {
@@ -213,7 +222,10 @@ func playExample(fset *token.FileSet, file *ast.File, f *ast.FuncDecl) *ast.File
 			// because the package syscall/js is not available in the playground.
 			return nil
 		}
-		n := path.Base(p)
+		n := assumedPackageName(p)
+		if p == importPath && pkgName != "" {
+			n = pkgName
+		}
 		if s.Name != nil {
 			n = s.Name.Name
 			switch n {
@@ -231,6 +243,13 @@ func playExample(fset *token.FileSet, file *ast.File, f *ast.FuncDecl) *ast.File
 		}
 	}
 
+	// Import the documented package if the example uses it without
+	// importing it.
+	if pkgName != "" && unresolved[pkgName] {
+		namedImports[pkgName] = importPath
+		delete(unresolved, pkgName)
+	}
+
 	// If there are other unresolved identifiers, give up because this
 	// synthesized file is not going to build.
 	if len(unresolved) > 0 {
@@ -484,7 +503,7 @@ func synthesizeImportDecl(namedImports map[string]string, blankImports []ast.Spe
 			Path:   &ast.BasicLit{Value: strconv.Quote(p), Kind: token.STRING, ValuePos: pos},
 			EndPos: pos,
 		}
-		if path.Base(p) != n {
+		if assumedPackageName(p) != n {
 			s.Name = ast.NewIdent(n)
 			s.Name.NamePos = pos
 		}
@@ -496,6 +515,34 @@ func synthesizeImportDecl(namedImports map[string]string, blankImports []ast.Spe
 	return importDecl
 }
 
+// assumedPackageName returns the assumed package name for an import path:
+// its last element, skipping a major version suffix like "v2" and stripping a
+// "go-" prefix and anything after the first character that is not allowed in
+// an identifier. This is the same heuristic that goimports uses.
+func assumedPackageName(importPath string) string {
+	notIdentifier := func(ch rune) bool {
+		return !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' ||
+			'0' <= ch && ch <= '9' ||
+			ch == '_' ||
+			ch >= utf8.RuneSelf && (unicode.IsLetter(ch) || unicode.IsDigit(ch)))
+	}
+
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil {
+			dir := path.Dir(importPath)
+			if dir != "." {
+				base = path.Base(dir)
+			}
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, notIdentifier); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
 // playExampleFile takes a whole file example and synthesizes a new *ast.File
 // such that the example is function main in package main.
 func playExampleFile(file *ast.File) *ast.File {
@@ -78,6 +78,47 @@ func TestExamples(t *testing.T) {
 	}
 }
 
+func TestExamplesDocumentedPackage(t *testing.T) {
+	// The name of the documented package cannot be derived from its import
+	// path, so the playable example must import it by name.
+	const src = `
+package pkgx
+
+func Greet(who string) {}
+`
+	const test = `
+package pkgx_test
+
+import "example.com/pkg-x"
+
+func ExampleGreet() {
+	pkgx.Greet("world")
+}
+`
+	fset := token.NewFileSet()
+	files := []*ast.File{
+		mustParse(fset, "src.go", src),
+		mustParse(fset, "src_test.go", test),
+	}
+	p, err := doc.NewFromFiles(fset, files, "example.com/pkg-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.TrimSpace(formatFile(t, fset, p.Funcs[0].Examples[0].Play))
+	want := `package main
+
+import (
+	pkgx "example.com/pkg-x"
+)
+
+func main() {
+	pkgx.Greet("world")
+}`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Play: mismatch (-want, +got):\n%s", diff)
+	}
+}
+
 func formatFile(t *testing.T, fset *token.FileSet, n *ast.File) string {
 	t.Helper()
 	if n == nil {
@@ -0,0 +1,17 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package foo_test
+
+import (
+	"fmt"
+
+	"example.com/go-yaml"
+	"example.com/mod/v2"
+	"gopkg.in/check.v1"
+)
+
+func ExampleImports() {
+	fmt.Println(yaml.Marshal(mod.Value), check.Equals)
+}
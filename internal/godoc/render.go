@@ -45,6 +45,13 @@ type Renderer struct {
 // Render renders the documentation for the package.
 // Rendering destroys p's AST; do not call any methods of p after it returns.
 func (p *Package) Render(ctx context.Context, innerPath string, sourceInfo *source.Info, modInfo *ModuleInfo) (
+	synopsis string, imports []string, html safehtml.HTML, api []*internal.Symbol, err error) {
+	return p.RenderWithLimit(ctx, innerPath, sourceInfo, modInfo, MaxDocumentationHTML)
+}
+
+// RenderWithLimit is like Render, but uses limit instead of
+// MaxDocumentationHTML as the maximum size of the documentation HTML.
+func (p *Package) RenderWithLimit(ctx context.Context, innerPath string, sourceInfo *source.Info, modInfo *ModuleInfo, limit int) (
 	synopsis string, imports []string, html safehtml.HTML, api []*internal.Symbol, err error) {
 	// This is mostly copied from internal/fetch/fetch.go.
 	defer derrors.Wrap(&err, "godoc.Package.Render(%q, %q, %q)", modInfo.ModulePath, modInfo.ResolvedVersion, innerPath)
@@ -61,7 +68,7 @@ func (p *Package) Render(ctx context.Context, innerPath string, sourceInfo *sour
 	}
 
 	// Render documentation HTML.
-	opts := p.renderOptions(innerPath, sourceInfo, modInfo)
+	opts := p.renderOptions(innerPath, sourceInfo, modInfo, limit)
 	docHTML, err := dochtml.Render(ctx, p.Fset, d, opts)
 	if errors.Is(err, ErrTooLarge) {
 		docHTML = template.MustParseAndExecuteToHTML(DocTooLargeReplacement)
@@ -71,6 +78,26 @@ func (p *Package) Render(ctx context.Context, innerPath string, sourceInfo *sour
 	return doc.Synopsis(d.Doc), d.Imports, docHTML, api, err
 }
 
+// RenderMetadata is like Render, but it does not render the documentation
+// HTML, which is the expensive part of rendering. Since the HTML is not
+// rendered, its size is not checked against a limit.
+// Like Render, it destroys p's AST.
+func (p *Package) RenderMetadata(ctx context.Context, innerPath string, modInfo *ModuleInfo) (
+	synopsis string, imports []string, api []*internal.Symbol, err error) {
+	defer derrors.Wrap(&err, "godoc.Package.RenderMetadata(%q, %q, %q)", modInfo.ModulePath, modInfo.ResolvedVersion, innerPath)
+
+	p.renderCalled = true
+	d, err := p.docPackage(innerPath, modInfo)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	api, err = dochtml.GetSymbols(d, p.Fset)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return doc.Synopsis(d.Doc), d.Imports, api, nil
+}
+
 // docPackage computes and returns a doc.Package.
 func (p *Package) docPackage(innerPath string, modInfo *ModuleInfo) (_ *doc.Package, err error) {
 	defer derrors.Wrap(&err, "docPackage(%q, %q, %q)", innerPath, modInfo.ModulePath, modInfo.ResolvedVersion)
@@ -125,8 +152,9 @@ func (p *Package) docPackage(innerPath string, modInfo *ModuleInfo) (_ *doc.Pack
 	return d, nil
 }
 
-// renderOptions returns a RenderOptions for p.
-func (p *Package) renderOptions(innerPath string, sourceInfo *source.Info, modInfo *ModuleInfo) dochtml.RenderOptions {
+// renderOptions returns a RenderOptions for p, limiting the size of the
+// rendered HTML to limit bytes.
+func (p *Package) renderOptions(innerPath string, sourceInfo *source.Info, modInfo *ModuleInfo, limit int) dochtml.RenderOptions {
 	sourceLinkFunc := func(n ast.Node) string {
 		if sourceInfo == nil {
 			return ""
@@ -148,7 +176,7 @@ func (p *Package) renderOptions(innerPath string, sourceInfo *source.Info, modIn
 		FileLinkFunc:   fileLinkFunc,
 		SourceLinkFunc: sourceLinkFunc,
 		ModInfo:        modInfo,
-		Limit:          int64(MaxDocumentationHTML),
+		Limit:          int64(limit),
 	}
 }
 
@@ -161,7 +189,7 @@ func (p *Package) RenderParts(ctx context.Context, innerPath string, sourceInfo
 	if err != nil {
 		return nil, err
 	}
-	opts := p.renderOptions(innerPath, sourceInfo, modInfo)
+	opts := p.renderOptions(innerPath, sourceInfo, modInfo, MaxDocumentationHTML)
 	parts, err := dochtml.RenderParts(ctx, p.Fset, d, opts)
 	if errors.Is(err, ErrTooLarge) {
 		return &dochtml.Parts{Body: template.MustParseAndExecuteToHTML(DocTooLargeReplacement)}, nil
@@ -6,12 +6,15 @@ package godoc
 
 import (
 	"context"
+	"go/parser"
+	"go/token"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/safehtml/template"
+	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/godoc/dochtml"
 	"golang.org/x/pkgsite/internal/source"
 )
@@ -84,3 +87,136 @@ func TestRender(t *testing.T) {
 	}
 	check(p2)
 }
+
+func TestRenderSourceLinks(t *testing.T) {
+	dochtml.LoadTemplates(templateSource)
+	const src = `// Package p is a package.
+package p
+
+// F does something.
+func F() {}
+
+// T is a type.
+type T struct {
+	// X is a field.
+	X int
+}
+`
+	fset := token.NewFileSet()
+	pf, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPackage(fset, nil)
+	p.AddFile(pf, true)
+	si := source.NewGitHubInfo("https://github.com/a/m", "", "v1.2.3")
+	_, _, doc, _, err := p.Render(context.Background(), "p", si, &ModuleInfo{ModulePath: "github.com/a/m", ResolvedVersion: "v1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`href="https://github.com/a/m/blob/v1.2.3/p/p.go#L5"`,
+		// A declaration on several lines links to its first line.
+		`href="https://github.com/a/m/blob/v1.2.3/p/p.go#L8"`,
+	} {
+		if !strings.Contains(doc.String(), want) {
+			t.Errorf("doc does not contain %s", want)
+		}
+	}
+}
+
+func TestRenderNonImportableLinks(t *testing.T) {
+	dochtml.LoadTemplates(templateSource)
+	const src = `// Package p is a package.
+package p
+
+import (
+	"github.com/a/m/internal/x"
+	"github.com/a/m/y"
+)
+
+// F returns an x.T. See also y.U.
+func F() x.T { return x.T{} }
+
+// G returns a y.U.
+func G() y.U { return y.U{} }
+`
+	fset := token.NewFileSet()
+	pf, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPackage(fset, nil)
+	p.AddFile(pf, true)
+	_, _, doc, _, err := p.Render(context.Background(), "p", nil, &ModuleInfo{
+		ModulePath:            "github.com/a/m",
+		ResolvedVersion:       "v1.2.3",
+		NonImportablePackages: map[string]bool{"github.com/a/m/internal/x": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.String(); strings.Contains(got, "/internal/x") {
+		t.Errorf("doc links to the internal package:\n%s", got)
+	}
+	if got := doc.String(); !strings.Contains(got, `href="/github.com/a/m/y#U"`) {
+		t.Errorf("doc does not link to the importable package:\n%s", got)
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	const src = `
+// Package p is a package.
+package p
+
+// Group of constants.
+const (
+	A = 1
+	B = 2
+)
+
+// F does something.
+func F() {}
+
+// T is a type.
+type T struct {
+	// X is a field.
+	X int
+}
+
+// NewT makes a T.
+func NewT() *T { return nil }
+
+// M is a method.
+func (T) M() {}
+
+func unexported() {}
+`
+	fset := token.NewFileSet()
+	pf, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPackage(fset, nil)
+	p.AddFile(pf, true)
+	got, err := p.RenderText(context.Background(), "p", &ModuleInfo{ModulePath: "a.com/M", ResolvedVersion: "v1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DocText{
+		Synopsis: "Package p is a package.",
+		Doc:      "Package p is a package.\n",
+		Symbols: []*SymbolText{
+			{Kind: internal.SymbolKindConstant, Name: "A", Signature: "const A", Doc: "Group of constants.\n"},
+			{Kind: internal.SymbolKindConstant, Name: "B", Signature: "const B", Doc: "Group of constants.\n"},
+			{Kind: internal.SymbolKindFunction, Name: "F", Signature: "func F()", Doc: "F does something.\n"},
+			{Kind: internal.SymbolKindType, Name: "T", Signature: "type T struct{ ... }", Doc: "T is a type.\n"},
+			{Kind: internal.SymbolKindFunction, Name: "NewT", ParentName: "T", Signature: "func NewT() *T", Doc: "NewT makes a T.\n"},
+			{Kind: internal.SymbolKindField, Name: "T.X", ParentName: "T", Signature: "type T struct, X int", Doc: "X is a field.\n"},
+			{Kind: internal.SymbolKindMethod, Name: "T.M", ParentName: "T", Signature: "func (T) M()", Doc: "M is a method.\n"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package godoc
+
+import (
+	"context"
+	"go/ast"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/godoc/dochtml"
+	"golang.org/x/pkgsite/internal/godoc/internal/doc"
+)
+
+// DocText is the documentation of a package as plain text, for clients that
+// want it in structured form instead of HTML.
+type DocText struct {
+	Synopsis string
+	// Doc is the package comment.
+	Doc string
+	// Symbols are the exported symbols of the package. The children of a
+	// type, such as its methods, follow the type.
+	Symbols []*SymbolText
+}
+
+// SymbolText is the documentation of a symbol as plain text.
+type SymbolText struct {
+	Kind internal.SymbolKind
+	// Name is the name of the symbol. The names of methods and fields are
+	// qualified by their type, as in "Type.Method".
+	Name string
+	// ParentName is the type that the symbol is associated with, if any.
+	ParentName string `json:",omitempty"`
+	// Signature is a one-line summary of the declaration of the symbol, like
+	// internal.Symbol.Synopsis.
+	Signature string
+	// Doc is the doc comment of the symbol. The symbols declared together in
+	// a group of constants or variables share the comment of the group.
+	Doc string
+}
+
+// RenderText returns the documentation for the package as plain text.
+// Like Render, it destroys p's AST.
+func (p *Package) RenderText(ctx context.Context, innerPath string, modInfo *ModuleInfo) (_ *DocText, err error) {
+	defer derrors.Wrap(&err, "godoc.Package.RenderText(%q, %q, %q)", modInfo.ModulePath, modInfo.ResolvedVersion, innerPath)
+
+	p.renderCalled = true
+	d, err := p.docPackage(innerPath, modInfo)
+	if err != nil {
+		return nil, err
+	}
+	api, err := dochtml.GetSymbols(d, p.Fset)
+	if err != nil {
+		return nil, err
+	}
+	docs := symbolDocs(d)
+	dt := &DocText{
+		Synopsis: doc.Synopsis(d.Doc),
+		Doc:      d.Doc,
+		Symbols:  []*SymbolText{},
+	}
+	add := func(s *internal.Symbol) {
+		dt.Symbols = append(dt.Symbols, &SymbolText{
+			Kind:       s.Kind,
+			Name:       s.Name,
+			ParentName: s.ParentName,
+			Signature:  s.Synopsis,
+			Doc:        docs[s.Name],
+		})
+	}
+	for _, s := range api {
+		add(s)
+		for _, c := range s.Children {
+			add(c)
+		}
+	}
+	return dt, nil
+}
+
+// symbolDocs returns the doc comments of the symbols of d, keyed by the
+// symbol names used by dochtml.GetSymbols.
+func symbolDocs(d *doc.Package) map[string]string {
+	docs := map[string]string{}
+	addValues := func(vals []*doc.Value) {
+		for _, v := range vals {
+			for _, n := range v.Names {
+				docs[n] = v.Doc
+			}
+		}
+	}
+	addFuncs := func(prefix string, funcs []*doc.Func) {
+		for _, f := range funcs {
+			docs[prefix+f.Name] = f.Doc
+		}
+	}
+	addValues(d.Consts)
+	addValues(d.Vars)
+	addFuncs("", d.Funcs)
+	for _, t := range d.Types {
+		docs[t.Name] = t.Doc
+		addValues(t.Consts)
+		addValues(t.Vars)
+		addFuncs("", t.Funcs)
+		addFuncs(t.Name+".", t.Methods)
+		// Add the fields of structs and the methods of interfaces.
+		for _, spec := range t.Decl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			var fields *ast.FieldList
+			switch st := ts.Type.(type) {
+			case *ast.StructType:
+				fields = st.Fields
+			case *ast.InterfaceType:
+				fields = st.Methods
+			}
+			if fields == nil {
+				continue
+			}
+			for _, f := range fields.List {
+				for _, n := range f.Names {
+					docs[t.Name+"."+n.Name] = f.Doc.Text()
+				}
+			}
+		}
+	}
+	return docs
+}
@@ -41,9 +41,10 @@ import (
 //go:generate go run gen_exceptions.go
 
 const (
-	// coverageThreshold is the minimum percentage of the file that must contain
-	// license text.
-	coverageThreshold = 75
+	// DefaultCoverageThreshold is the default minimum percentage of a file
+	// that must contain license text for the file to be classified as a known
+	// license.
+	DefaultCoverageThreshold = 75
 
 	// unknownLicenseType is for text in a license file that's not recognized.
 	unknownLicenseType = "UNKNOWN"
@@ -57,8 +58,21 @@ var maxLicenseSize uint64 = modzip.MaxLICENSE
 
 // Metadata holds information extracted from a license file.
 type Metadata struct {
-	// Types is the set of license types, as determined by the licensecheck package.
+	// Types is the set of license types, as determined by the licensecheck
+	// package, together with any other license types whose text the file
+	// matches with a confidence of at least the coverage threshold. A file
+	// that matches several similar licenses, such as BSD-2-Clause and
+	// BSD-3-Clause, lists all of them. Types are ordered by decreasing
+	// confidence.
 	Types []string
+	// Confidence holds the confidence, as a percentage, of each of Types, in
+	// the same order. It is nil if the types could not be determined.
+	Confidence []float64
+	// SPDXExpression is an SPDX license expression for the file, built from
+	// the license types that licensecheck detected in it, not the other
+	// types that it matches. See spdxExpression. Use
+	// JoinSPDXExpressions to combine the expressions of several files.
+	SPDXExpression string
 	// FilePath is the '/'-separated path to the license file in the module zip,
 	// relative to the contents directory.
 	FilePath string
@@ -76,7 +90,7 @@ type License struct {
 // RemoveNonRedistributableData methods removes the license contents
 // if the license is non-redistributable.
 func (l *License) RemoveNonRedistributableData() {
-	if !Redistributable(l.Types) {
+	if !redistributable([]*License{l}) {
 		l.Contents = nil
 	}
 }
@@ -233,6 +247,14 @@ var nonOSILicenses = map[string]bool{
 	"OpenSSL":            true,
 }
 
+// rootLicenseDirs are the directories, relative to the module root and
+// downcased, whose license files are treated as if they were at the module
+// root. Some modules keep their license there instead of at the root.
+var rootLicenseDirs = map[string]bool{
+	"licenses": true,
+	".github":  true,
+}
+
 // fileNamesLowercase has all the entries of FileNames, downcased and made a set
 // for fast case-insensitive matching.
 var fileNamesLowercase = map[string]bool{}
@@ -292,28 +314,36 @@ func scanner() *licensecheck.Scanner {
 
 // A Detector detects licenses in a module and its packages.
 type Detector struct {
-	modulePath     string
-	version        string
-	zr             *zip.Reader
-	logf           func(string, ...interface{})
-	moduleRedist   bool
-	moduleLicenses []*License // licenses at module root directory, or list from exceptions
-	allLicenses    []*License
-	licsByDir      map[string][]*License // from directory to list of licenses
+	modulePath        string
+	version           string
+	zr                *zip.Reader
+	coverageThreshold float64
+	logf              func(string, ...interface{})
+	moduleRedist      bool
+	moduleLicenses    []*License // licenses at module root directory, or list from exceptions
+	allLicenses       []*License
+	licsByDir         map[string][]*License // from directory to list of licenses
 }
 
 // NewDetector returns a Detector for the given module and version.
 // zr should be the zip file for that module and version.
+// coverageThreshold is the minimum percentage of a file that must contain
+// license text for the file to be classified as a known license; files below
+// it have type UNKNOWN. If it is zero, DefaultCoverageThreshold is used.
 // logf is for logging; if nil, no logging is done.
-func NewDetector(modulePath, version string, zr *zip.Reader, logf func(string, ...interface{})) *Detector {
+func NewDetector(modulePath, version string, zr *zip.Reader, coverageThreshold float64, logf func(string, ...interface{})) *Detector {
 	if logf == nil {
 		logf = func(string, ...interface{}) {}
 	}
+	if coverageThreshold == 0 {
+		coverageThreshold = DefaultCoverageThreshold
+	}
 	d := &Detector{
-		modulePath: modulePath,
-		version:    version,
-		zr:         zr,
-		logf:       logf,
+		modulePath:        modulePath,
+		version:           version,
+		zr:                zr,
+		coverageThreshold: coverageThreshold,
+		logf:              logf,
 	}
 	d.computeModuleInfo()
 	return d
@@ -361,8 +391,7 @@ func (d *Detector) PackageInfo(dir string) (isRedistributable bool, lics []*Lice
 	// as asking if the module licenses plus the package licenses are
 	// redistributable. A module that is granted an exception (see DetectFiles)
 	// may have licenses that are non-redistributable.
-	ltypes := types(lics)
-	isRedistributable = d.ModuleIsRedistributable() && (len(ltypes) == 0 || Redistributable(ltypes))
+	isRedistributable = d.ModuleIsRedistributable() && (len(lics) == 0 || redistributable(lics))
 	// A package's licenses include the ones we've already computed, as well
 	// as the module licenses.
 	return isRedistributable, append(lics, d.moduleLicenses...)
@@ -372,7 +401,7 @@ func (d *Detector) PackageInfo(dir string) (isRedistributable bool, lics []*Lice
 func (d *Detector) computeModuleInfo() {
 	// Check that all licenses in the contents directory are redistributable.
 	d.moduleLicenses = d.detectFiles(d.Files(RootFiles))
-	d.moduleRedist = Redistributable(types(d.moduleLicenses))
+	d.moduleRedist = redistributable(d.moduleLicenses)
 }
 
 // computeAllLicenseInfo collects all the detected licenses in the zip and
@@ -394,7 +423,8 @@ func (d *Detector) computeAllLicenseInfo() {
 type WhichFiles int
 
 const (
-	// Only files from the root (contents) directory.
+	// Only files from the root (contents) directory, or from one of the
+	// conventional license directories under it, like licenses/.
 	RootFiles WhichFiles = iota
 	// Only files that are not in the root directory.
 	NonRootFiles
@@ -420,11 +450,13 @@ func (d *Detector) Files(which WhichFiles) []*zip.File {
 		if ignoreFiles[d.modulePath+" "+strings.TrimPrefix(f.Name, prefix)] {
 			continue
 		}
-		if which == RootFiles && path.Dir(f.Name) != cdir {
+		atRoot := path.Dir(f.Name) == cdir ||
+			rootLicenseDirs[strings.ToLower(path.Dir(strings.TrimPrefix(f.Name, prefix)))]
+		if which == RootFiles && !atRoot {
 			// Skip f since it's not at root.
 			continue
 		}
-		if which == NonRootFiles && path.Dir(f.Name) == cdir {
+		if which == NonRootFiles && atRoot {
 			// Skip f since it is at root.
 			continue
 		}
@@ -473,18 +505,22 @@ func (d *Detector) detectFiles(files []*zip.File) []*License {
 			d.logf("reading zip file %s: %v", f.Name, err)
 			licenses = append(licenses, &License{
 				Metadata: &Metadata{
-					Types:    []string{unknownLicenseType},
-					FilePath: strings.TrimPrefix(f.Name, prefix),
+					Types:          []string{unknownLicenseType},
+					SPDXExpression: spdxExpression([]string{unknownLicenseType}, strings.TrimPrefix(f.Name, prefix)),
+					FilePath:       strings.TrimPrefix(f.Name, prefix),
 				},
 			})
 			continue
 		}
-		types, cov := DetectFile(bytes, f.Name, d.logf)
+		types, cov := detectFile(bytes, f.Name, d.coverageThreshold, d.logf)
+		matched, confidence := scoreTypes(bytes, types, cov.Percent, d.coverageThreshold)
 		licenses = append(licenses, &License{
 			Metadata: &Metadata{
-				Types:    types,
-				FilePath: strings.TrimPrefix(f.Name, prefix),
-				Coverage: cov,
+				Types:          matched,
+				Confidence:     confidence,
+				SPDXExpression: spdxExpression(types, strings.TrimPrefix(f.Name, prefix)),
+				FilePath:       strings.TrimPrefix(f.Name, prefix),
+				Coverage:       cov,
 			},
 			Contents: bytes,
 		})
@@ -496,11 +532,17 @@ func (d *Detector) detectFiles(files []*zip.File) []*License {
 // also returns the licensecheck coverage information. The filename is used
 // solely for logging.
 func DetectFile(contents []byte, filename string, logf func(string, ...interface{})) ([]string, licensecheck.Coverage) {
+	return detectFile(contents, filename, DefaultCoverageThreshold, logf)
+}
+
+// detectFile is like DetectFile, but classifies files whose coverage is below
+// coverageThreshold as unknown.
+func detectFile(contents []byte, filename string, coverageThreshold float64, logf func(string, ...interface{})) ([]string, licensecheck.Coverage) {
 	if logf == nil {
 		logf = func(string, ...interface{}) {}
 	}
 	cov := scanner().Scan(contents)
-	if cov.Percent < float64(coverageThreshold) {
+	if cov.Percent < coverageThreshold {
 		logf("%s license coverage too low (%+v), skipping", filename, cov)
 		return []string{unknownLicenseType}, cov
 	}
@@ -521,6 +563,199 @@ func DetectFile(contents []byte, filename string, logf func(string, ...interface
 	return setToSortedSlice(types), cov
 }
 
+var (
+	_candidateCheckers    map[string]candidateChecker
+	candidateCheckersOnce sync.Once
+)
+
+// oldLicenseTypes maps the names used by the old licensecheck package to
+// licensecheck identifiers, where they differ.
+var oldLicenseTypes = map[string]string{
+	"BSD-0-Clause": "0BSD",
+	"GPL2":         "GPL-2.0",
+	"GPL3":         "GPL-3.0",
+}
+
+// A candidateChecker scores a file against the text of a single license.
+type candidateChecker struct {
+	checker *oldlicensecheck.Checker
+	words   int // number of words in the license text
+}
+
+// candidateCheckers returns a checker for each license type known to both the
+// old and the current licensecheck packages, keyed by type. Each checker
+// recognizes only the text of its license, so that a file can be scored
+// against each type independently.
+func candidateCheckers() map[string]candidateChecker {
+	candidateCheckersOnce.Do(func() {
+		ids := map[string]bool{}
+		for _, l := range licensecheck.BuiltinLicenses() {
+			ids[l.ID] = true
+		}
+		_candidateCheckers = map[string]candidateChecker{}
+		for _, l := range oldlicensecheck.BuiltinLicenses() {
+			t := l.Name
+			if nt, ok := oldLicenseTypes[t]; ok {
+				t = nt
+			}
+			if !ids[t] || l.Text == "" {
+				continue
+			}
+			// Only the text counts towards a candidate, not a URL.
+			l.URL = ""
+			_candidateCheckers[t] = candidateChecker{
+				checker: oldlicensecheck.New([]oldlicensecheck.License{l}),
+				words:   len(strings.Fields(l.Text)),
+			}
+		}
+	})
+	return _candidateCheckers
+}
+
+// score returns the confidence, as a percentage, that contents is the text
+// of c's license. It is the smaller of the fraction of the file that matches
+// the license and the fraction of the license that matches the file, so that
+// neither a license that is a subset of the file nor one that contains it is
+// scored as highly as an exact match.
+func (c candidateChecker) score(contents []byte) float64 {
+	cov, ok := c.checker.Cover(contents, oldlicensecheck.Options{})
+	if !ok {
+		return 0
+	}
+	conf := cov.Percent
+	var best float64
+	for _, m := range cov.Match {
+		if m.Percent > best {
+			best = m.Percent
+		}
+	}
+	if best < conf {
+		conf = best
+	}
+	return conf
+}
+
+// scoreTypes returns the license types that the file with the given contents
+// matches, ordered by decreasing confidence, and the confidence of each. They
+// are the detected types, and any other type whose confidence is at least
+// coverageThreshold. Detected types that cannot be scored individually are
+// given the coverage of the whole file, as reported by licensecheck.
+//
+// Since a file and a license whose lengths differ too much cannot match with
+// enough confidence, only licenses of similar length are scored.
+func scoreTypes(contents []byte, detected []string, coverage, coverageThreshold float64) ([]string, []float64) {
+	if len(detected) == 1 && detected[0] == unknownLicenseType {
+		return detected, nil
+	}
+	checkers := candidateCheckers()
+	conf := map[string]float64{}
+	for _, t := range detected {
+		conf[t] = coverage
+		if c, ok := checkers[t]; ok {
+			conf[t] = c.score(contents)
+		}
+	}
+	words := len(strings.Fields(string(contents)))
+	for t, c := range checkers {
+		if _, ok := conf[t]; ok || 100*float64(minInt(words, c.words)) < coverageThreshold*float64(maxInt(words, c.words)) {
+			continue
+		}
+		if cf := c.score(contents); cf >= coverageThreshold {
+			conf[t] = cf
+		}
+	}
+	types := make([]string, 0, len(conf))
+	for t := range conf {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if conf[types[i]] != conf[types[j]] {
+			return conf[types[i]] > conf[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	confidence := make([]float64, len(types))
+	for i, t := range types {
+		confidence[i] = conf[t]
+	}
+	return types, confidence
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// spdxExpression returns the SPDX expression for the license file at
+// filePath, with the given license types. A file that contains several
+// licenses offers a choice between them, so their identifiers are joined with
+// OR. An unknown license is identified by a LicenseRef derived from filePath.
+func spdxExpression(types []string, filePath string) string {
+	var ids []string
+	for _, t := range types {
+		if t == unknownLicenseType {
+			t = spdxLicenseRef(filePath)
+		}
+		ids = append(ids, t)
+	}
+	if len(ids) == 0 {
+		ids = append(ids, spdxLicenseRef(filePath))
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, " OR ")
+}
+
+// spdxLicenseRef returns an SPDX LicenseRef for the license in the file at
+// filePath. The characters of filePath that may not appear in a LicenseRef are
+// replaced by '-'.
+func spdxLicenseRef(filePath string) string {
+	return "LicenseRef-" + strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+			return r
+		}
+		return '-'
+	}, filePath)
+}
+
+// JoinSPDXExpressions returns the SPDX expression for a set of co-located
+// license files, such as those that apply to a package. All of the files
+// apply, so their expressions are joined with AND. Duplicate expressions are
+// omitted.
+func JoinSPDXExpressions(lics []*Metadata) string {
+	seen := map[string]bool{}
+	var exprs []string
+	for _, l := range lics {
+		e := l.SPDXExpression
+		if e == "" {
+			e = spdxExpression(l.Types, l.FilePath)
+		}
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	sort.Strings(exprs)
+	for i, e := range exprs {
+		if strings.Contains(e, " OR ") {
+			exprs[i] = "(" + e + ")"
+		}
+	}
+	return strings.Join(exprs, " AND ")
+}
+
 // Redistributable reports whether the set of license types establishes that a
 // module or package is redistributable.
 // All the licenses we see that are relevant must be redistributable, and
@@ -539,12 +774,33 @@ func Redistributable(licenseTypes []string) bool {
 	return sawRedist
 }
 
-func types(lics []*License) []string {
-	var types []string
+// redistributable reports whether the given license files establish that a
+// module or package is redistributable. It is like Redistributable, except
+// that the types of a single file are alternatives: a file is redistributable
+// if any of its types is.
+func redistributable(lics []*License) bool {
+	sawRedist := false
 	for _, l := range lics {
-		types = append(types, l.Types...)
+		ignorable := true
+		redist := false
+		for _, t := range l.Types {
+			if ignorableLicenseTypes[t] {
+				continue
+			}
+			ignorable = false
+			if redistributableLicenseTypes[t] {
+				redist = true
+			}
+		}
+		if ignorable {
+			continue
+		}
+		if !redist {
+			return false
+		}
+		sawRedist = true
 	}
-	return types
+	return sawRedist
 }
 
 func setToSortedSlice(m map[string]bool) []string {
@@ -256,14 +256,14 @@ func TestModuleIsRedistributable(t *testing.T) {
 			module:    "golang.org/x/time",
 			version:   "v0.0.0-20191024005414-555d28b269f0",
 			want:      true,
-			wantMetas: []*Metadata{{Types: []string{"BSD-3-Clause"}, FilePath: "LICENSE"}},
+			wantMetas: []*Metadata{{Types: []string{"BSD-3-Clause", "BSD-3-Clause-Clear"}, SPDXExpression: "BSD-3-Clause", FilePath: "LICENSE"}},
 		},
 		{
 			filename:  "smasher",
 			module:    "github.com/smasher164/mem",
 			version:   "v0.0.0-20191114064341-4e07bd0f0d69",
 			want:      true,
-			wantMetas: []*Metadata{{Types: []string{"0BSD"}, FilePath: "LICENSE.md"}},
+			wantMetas: []*Metadata{{Types: []string{"0BSD", "ISC"}, SPDXExpression: "0BSD", FilePath: "LICENSE.md"}},
 		},
 		{
 			filename: "gioui",
@@ -271,8 +271,8 @@ func TestModuleIsRedistributable(t *testing.T) {
 			version:  "v0.0.0-20200103103112-ccbcbdbfbd4f",
 			want:     true,
 			wantMetas: []*Metadata{
-				{Types: []string{"MIT"}, FilePath: "LICENSE-MIT"},
-				{Types: []string{"Unlicense"}, FilePath: "UNLICENSE"},
+				{Types: []string{"MIT", "JSON"}, SPDXExpression: "MIT", FilePath: "LICENSE-MIT"},
+				{Types: []string{"Unlicense"}, SPDXExpression: "Unlicense", FilePath: "UNLICENSE"},
 			},
 		},
 		{
@@ -281,9 +281,9 @@ func TestModuleIsRedistributable(t *testing.T) {
 			version:  "v0.6.2",
 			want:     true,
 			wantMetas: []*Metadata{
-				{Types: []string{"BSD-3-Clause"}, FilePath: "LICENSE"},
-				{Types: []string{"MIT"}, FilePath: "graph/formats/cytoscapejs/testdata/LICENSE"},
-				{Types: []string{"MIT"}, FilePath: "graph/formats/sigmajs/testdata/LICENSE.txt"},
+				{Types: []string{"BSD-3-Clause", "BSD-3-Clause-Clear"}, SPDXExpression: "BSD-3-Clause", FilePath: "LICENSE"},
+				{Types: []string{"MIT", "JSON"}, SPDXExpression: "MIT", FilePath: "graph/formats/cytoscapejs/testdata/LICENSE"},
+				{Types: []string{"MIT", "JSON"}, SPDXExpression: "MIT", FilePath: "graph/formats/sigmajs/testdata/LICENSE.txt"},
 			},
 		},
 	} {
@@ -301,7 +301,7 @@ func TestModuleIsRedistributable(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			d := NewDetector(test.module, test.version, zr, nil)
+			d := NewDetector(test.module, test.version, zr, 0, nil)
 			got := d.ModuleIsRedistributable()
 			if got != test.want {
 				for _, l := range d.ModuleLicenses() {
@@ -314,7 +314,7 @@ func TestModuleIsRedistributable(t *testing.T) {
 				gotMetas = append(gotMetas, lic.Metadata)
 			}
 			opts := []cmp.Option{
-				cmpopts.IgnoreFields(Metadata{}, "Coverage"),
+				cmpopts.IgnoreFields(Metadata{}, "Coverage", "Confidence"),
 				cmpopts.SortSlices(func(m1, m2 *Metadata) bool { return m1.FilePath < m2.FilePath }),
 			}
 			if diff := cmp.Diff(test.wantMetas, gotMetas, opts...); diff != "" {
@@ -363,6 +363,9 @@ func TestFiles(t *testing.T) {
 		"foo/license":        "",
 		"vendor/pkg/LICENSE": "", // vendored files ignored
 		"pkg/vendor/LICENSE": "", // not a vendored file, but a package named "vendor"
+		"licenses/COPYING":   "", // conventional license directories are root
+		".github/UNLICENSE":  "",
+		"foo/licenses/MIT":   "", // not a license file name
 	})
 	for _, test := range []struct {
 		which WhichFiles
@@ -371,7 +374,7 @@ func TestFiles(t *testing.T) {
 		{
 			RootFiles,
 			[]string{"m@v1/LICENSE", "m@v1/LICENCE", "m@v1/License", "m@v1/COPYING", "m@v1/LICENSE.md",
-				"m@v1/liCeNse"},
+				"m@v1/liCeNse", "m@v1/licenses/COPYING", "m@v1/.github/UNLICENSE"},
 		},
 		{
 			NonRootFiles,
@@ -384,13 +387,13 @@ func TestFiles(t *testing.T) {
 			AllFiles,
 			[]string{
 				"m@v1/LICENSE", "m@v1/LICENCE", "m@v1/License", "m@v1/COPYING", "m@v1/LICENSE.md",
-				"m@v1/liCeNse", "m@v1/foo/LICENSE", "m@v1/foo/LICENSE.md", "m@v1/foo/LICENCE", "m@v1/foo/License",
+				"m@v1/liCeNse", "m@v1/licenses/COPYING", "m@v1/.github/UNLICENSE", "m@v1/foo/LICENSE", "m@v1/foo/LICENSE.md", "m@v1/foo/LICENCE", "m@v1/foo/License",
 				"m@v1/foo/license", "m@v1/foo/COPYING", "m@v1/pkg/vendor/LICENSE",
 			},
 		},
 	} {
 		t.Run(fmt.Sprintf("which=%d", test.which), func(t *testing.T) {
-			d := NewDetector("m", "v1", zr, nil)
+			d := NewDetector("m", "v1", zr, 0, nil)
 			gotFiles := d.Files(test.which)
 			var got []string
 			for _, f := range gotFiles {
@@ -474,7 +477,7 @@ func TestDetectFiles(t *testing.T) {
 			contents: map[string]string{
 				"foo/LICENSE": mitLicense,
 			},
-			want: []*Metadata{{Types: []string{"MIT"}, FilePath: "foo/LICENSE", Coverage: mitCoverage}},
+			want: []*Metadata{{Types: []string{"MIT", "JSON"}, SPDXExpression: "MIT", FilePath: "foo/LICENSE", Coverage: mitCoverage}},
 		},
 
 		{
@@ -485,12 +488,12 @@ func TestDetectFiles(t *testing.T) {
 				"COPYING":        bsd0License,
 			},
 			want: []*Metadata{
-				{Types: []string{"0BSD"}, FilePath: "COPYING", Coverage: lc.Coverage{
+				{Types: []string{"0BSD", "ISC"}, SPDXExpression: "0BSD", FilePath: "COPYING", Coverage: lc.Coverage{
 					Percent: 100,
 					Match:   []lc.Match{{ID: "0BSD"}},
 				}},
-				{Types: []string{"MIT"}, FilePath: "LICENSE", Coverage: mitCoverage},
-				{Types: []string{"MIT"}, FilePath: "foo/LICENSE.md", Coverage: mitCoverage},
+				{Types: []string{"MIT", "JSON"}, SPDXExpression: "MIT", FilePath: "LICENSE", Coverage: mitCoverage},
+				{Types: []string{"MIT", "JSON"}, SPDXExpression: "MIT", FilePath: "foo/LICENSE.md", Coverage: mitCoverage},
 			},
 		},
 		{
@@ -499,7 +502,7 @@ func TestDetectFiles(t *testing.T) {
 				"LICENSE": mitLicense + "\n" + bsd0License,
 			},
 			want: []*Metadata{
-				{Types: []string{"0BSD", "MIT"}, FilePath: "LICENSE", Coverage: lc.Coverage{
+				{Types: []string{"MIT", "0BSD"}, SPDXExpression: "0BSD OR MIT", FilePath: "LICENSE", Coverage: lc.Coverage{
 					Percent: 100,
 					Match: []lc.Match{
 						{ID: "MIT"},
@@ -514,7 +517,7 @@ func TestDetectFiles(t *testing.T) {
 				"LICENSE": unknownLicense,
 			},
 			want: []*Metadata{
-				{Types: []string{"UNKNOWN"}, FilePath: "LICENSE"},
+				{Types: []string{"UNKNOWN"}, SPDXExpression: "LicenseRef-LICENSE", FilePath: "LICENSE"},
 			},
 		},
 		{
@@ -531,8 +534,9 @@ func TestDetectFiles(t *testing.T) {
 			},
 			want: []*Metadata{
 				{
-					Types:    []string{"UNKNOWN"},
-					FilePath: "foo/LICENSE",
+					Types:          []string{"UNKNOWN"},
+					SPDXExpression: "LicenseRef-foo-LICENSE",
+					FilePath:       "foo/LICENSE",
 					Coverage: lc.Coverage{
 						Percent: 69.361,
 						Match:   []lc.Match{{ID: "MIT"}},
@@ -555,13 +559,15 @@ func TestDetectFiles(t *testing.T) {
 			},
 			want: []*Metadata{
 				{
-					Types:    []string{"UNKNOWN"},
-					FilePath: "COPYING",
+					Types:          []string{"UNKNOWN"},
+					SPDXExpression: "LicenseRef-COPYING",
+					FilePath:       "COPYING",
 				},
 				{
-					Types:    []string{"MIT"},
-					FilePath: "LICENSE",
-					Coverage: mitCoverage,
+					Types:          []string{"MIT", "JSON"},
+					SPDXExpression: "MIT",
+					FilePath:       "LICENSE",
+					Coverage:       mitCoverage,
 				},
 			},
 		},
@@ -572,8 +578,9 @@ func TestDetectFiles(t *testing.T) {
 			},
 			want: []*Metadata{
 				{
-					Types:    []string{"Apache-2.0"},
-					FilePath: "LICENSE",
+					Types:          []string{"Apache-2.0", "ECL-2.0"},
+					SPDXExpression: "Apache-2.0",
+					FilePath:       "LICENSE",
 					Coverage: lc.Coverage{
 						Percent: 100,
 						Match: []lc.Match{{
@@ -586,7 +593,7 @@ func TestDetectFiles(t *testing.T) {
 	}
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			d := NewDetector("m", "v1", newZipReader(t, "m@v1", test.contents), log.Printf)
+			d := NewDetector("m", "v1", newZipReader(t, "m@v1", test.contents), 0, log.Printf)
 			files := d.Files(AllFiles)
 			gotLics := d.detectFiles(files)
 			sort.Slice(gotLics, func(i, j int) bool {
@@ -599,6 +606,7 @@ func TestDetectFiles(t *testing.T) {
 
 			opts := []cmp.Option{
 				cmp.Comparer(coveragePercentEqual),
+				cmpopts.IgnoreFields(Metadata{}, "Confidence"),
 				cmpopts.IgnoreFields(lc.Match{}, "Start", "End"),
 			}
 			if diff := cmp.Diff(test.want, got, opts...); diff != "" {
@@ -608,13 +616,224 @@ func TestDetectFiles(t *testing.T) {
 	}
 }
 
+func TestLicenseDirectories(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		contents   map[string]string
+		wantRedist bool
+	}{
+		{
+			name:       "licenses directory",
+			contents:   map[string]string{"licenses/LICENSE": mitLicense, "foo.go": "package foo"},
+			wantRedist: true,
+		},
+		{
+			name:       "capitalized licenses directory",
+			contents:   map[string]string{"LICENSES/LICENSE.txt": mitLicense, "foo.go": "package foo"},
+			wantRedist: true,
+		},
+		{
+			name:       ".github directory",
+			contents:   map[string]string{".github/LICENSE.md": mitLicense, "foo.go": "package foo"},
+			wantRedist: true,
+		},
+		{
+			name:       "other directory",
+			contents:   map[string]string{"docs/LICENSE": mitLicense, "foo.go": "package foo"},
+			wantRedist: false,
+		},
+		{
+			name: "non-redistributable license in licenses directory",
+			contents: map[string]string{
+				"LICENSE":          mitLicense,
+				"licenses/LICENSE": unknownLicense,
+				"foo.go":           "package foo",
+			},
+			wantRedist: false,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewDetector("m", "v1", newZipReader(t, "m@v1", test.contents), 0, nil)
+			if got := d.ModuleIsRedistributable(); got != test.wantRedist {
+				t.Errorf("ModuleIsRedistributable: got %t, want %t", got, test.wantRedist)
+			}
+			// Licenses in the conventional directories apply to the whole
+			// module, not only to packages in those directories.
+			if got, _ := d.PackageInfo("bar"); got != test.wantRedist {
+				t.Errorf("PackageInfo: got %t, want %t", got, test.wantRedist)
+			}
+		})
+	}
+}
+
+func TestCoverageThreshold(t *testing.T) {
+	// An MIT license with an in-house addition, which lowers its coverage
+	// below the default threshold.
+	modifiedMIT := mitLicense + `
+	In addition, contributions to this software are accepted under the terms of
+	the Example Corp Contributor Agreement, which is available from the legal
+	department upon request. Please contact the legal department of Example
+	Corp with any questions about these terms, or about the use of this
+	software within Example Corp and its subsidiaries. Copies of this software
+	that are distributed outside of Example Corp must retain this notice.`
+	contents := map[string]string{"LICENSE": modifiedMIT}
+
+	for _, test := range []struct {
+		threshold float64
+		want      []string
+	}{
+		{0, []string{"UNKNOWN"}},
+		{DefaultCoverageThreshold, []string{"UNKNOWN"}},
+		// The JSON license is the MIT license with one more sentence, so it
+		// covers slightly more of the file.
+		{60, []string{"JSON", "MIT"}},
+	} {
+		d := NewDetector("m", "v1", newZipReader(t, "m@v1", contents), test.threshold, nil)
+		var got []string
+		for _, l := range d.AllLicenses() {
+			got = append(got, l.Types...)
+		}
+		if !cmp.Equal(got, test.want) {
+			t.Errorf("threshold %g: got %v, want %v", test.threshold, got, test.want)
+		}
+		if redist := d.ModuleIsRedistributable(); redist != (test.want[0] != "UNKNOWN") {
+			t.Errorf("threshold %g: got redistributable %t", test.threshold, redist)
+		}
+	}
+}
+
+func TestSPDXExpression(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		contents map[string]string
+		want     string
+	}{
+		{
+			name:     "single license",
+			contents: map[string]string{"LICENSE": mitLicense},
+			want:     "MIT",
+		},
+		{
+			name:     "dual licensing",
+			contents: map[string]string{"LICENSE": mitLicense + "\n" + bsd0License},
+			want:     "0BSD OR MIT",
+		},
+		{
+			name: "co-located files",
+			contents: map[string]string{
+				"LICENSE": mitLicense,
+				"COPYING": bsd0License,
+			},
+			want: "0BSD AND MIT",
+		},
+		{
+			name: "dual licensing and co-located file",
+			contents: map[string]string{
+				"LICENSE":    mitLicense + "\n" + bsd0License,
+				"LICENSE.md": apacheSansAppendix,
+			},
+			want: "(0BSD OR MIT) AND Apache-2.0",
+		},
+		{
+			name: "duplicate licenses",
+			contents: map[string]string{
+				"LICENSE": mitLicense,
+				"COPYING": mitLicense,
+			},
+			want: "MIT",
+		},
+		{
+			name:     "unknown license",
+			contents: map[string]string{"LICENSE": unknownLicense},
+			want:     "LicenseRef-LICENSE",
+		},
+		{
+			name: "unknown and known licenses",
+			contents: map[string]string{
+				"LICENSE": mitLicense,
+				"COPYING": unknownLicense,
+			},
+			want: "LicenseRef-COPYING AND MIT",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewDetector("m", "v1", newZipReader(t, "m@v1", test.contents), 0, log.Printf)
+			var mds []*Metadata
+			for _, l := range d.detectFiles(d.Files(RootFiles)) {
+				mds = append(mds, l.Metadata)
+			}
+			if got := JoinSPDXExpressions(mds); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	// Metadata without an expression, such as that read from the database,
+	// uses its types.
+	mds := []*Metadata{{Types: []string{"MIT"}}, {Types: []string{"Apache-2.0", "MIT"}}, {Types: []string{"UNKNOWN"}, FilePath: "x/COPYING"}}
+	if got, want := JoinSPDXExpressions(mds), "(Apache-2.0 OR MIT) AND LicenseRef-x-COPYING AND MIT"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMultipleTypes(t *testing.T) {
+	// The BSD 2-clause license is the BSD 3-clause license without its third
+	// clause, so its text matches both.
+	const bsd2License = `Copyright (c) 2021 Example Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.`
+
+	d := NewDetector("m", "v1", newZipReader(t, "m@v1", map[string]string{"LICENSE": bsd2License}), 0, nil)
+	lics := d.AllLicenses()
+	if len(lics) != 1 {
+		t.Fatalf("got %d licenses, want 1", len(lics))
+	}
+	got := lics[0].Metadata
+	want := []string{"BSD-2-Clause", "BSD-3-Clause", "BSD-3-Clause-Clear"}
+	if diff := cmp.Diff(want, got.Types); diff != "" {
+		t.Errorf("Types mismatch (-want +got):\n%s", diff)
+	}
+	if len(got.Confidence) != len(got.Types) {
+		t.Fatalf("got %d confidences for %d types", len(got.Confidence), len(got.Types))
+	}
+	if got.Confidence[0] != 100 {
+		t.Errorf("got confidence %g for BSD-2-Clause, want 100", got.Confidence[0])
+	}
+	for i, c := range got.Confidence {
+		if c < DefaultCoverageThreshold {
+			t.Errorf("%s has confidence %g, below the threshold", got.Types[i], c)
+		}
+		if i > 0 && c > got.Confidence[i-1] {
+			t.Errorf("confidences %v are not in decreasing order", got.Confidence)
+		}
+	}
+	// The SPDX expression is built from the detected type only.
+	if want := "BSD-2-Clause"; got.SPDXExpression != want {
+		t.Errorf("got SPDXExpression %q, want %q", got.SPDXExpression, want)
+	}
+	if !d.ModuleIsRedistributable() {
+		t.Error("got not redistributable, want redistributable")
+	}
+}
+
 func TestPackageInfo(t *testing.T) {
 	const (
 		module  = "mod"
 		version = "v1.2.3"
 	)
 	meta := func(typ, path string) *Metadata {
-		return &Metadata{Types: []string{typ}, FilePath: path}
+		types := []string{typ}
+		if typ == "MIT" {
+			// The JSON license is the MIT license with one more sentence.
+			types = append(types, "JSON")
+		}
+		return &Metadata{Types: types, SPDXExpression: spdxExpression([]string{typ}, path), FilePath: path}
 	}
 
 	for _, test := range []struct {
@@ -697,7 +916,7 @@ func TestPackageInfo(t *testing.T) {
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			zr := newZipReader(t, contentsDir(module, version), test.contents)
-			d := NewDetector(module, version, zr, nil)
+			d := NewDetector(module, version, zr, 0, nil)
 			gotRedist, gotLics := d.PackageInfo("dir/pkg")
 			if gotRedist != test.wantRedist {
 				t.Errorf("redist: got %t, want %t", gotRedist, test.wantRedist)
@@ -707,7 +926,7 @@ func TestPackageInfo(t *testing.T) {
 				gotMetas = append(gotMetas, l.Metadata)
 			}
 			opts := []cmp.Option{
-				cmpopts.IgnoreFields(Metadata{}, "Coverage"),
+				cmpopts.IgnoreFields(Metadata{}, "Coverage", "Confidence"),
 				cmpopts.SortSlices(func(m1, m2 *Metadata) bool { return m1.FilePath < m2.FilePath }),
 			}
 			if diff := cmp.Diff(test.wantMetas, gotMetas, opts...); diff != "" {
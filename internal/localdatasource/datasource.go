@@ -13,6 +13,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -183,12 +184,99 @@ func (ds *DataSource) GetLatestInfo(ctx context.Context, unitPath, modulePath st
 	return internal.LatestInfo{}, nil
 }
 
+// GetLatestInfoBatch is not implemented.
+func (ds *DataSource) GetLatestInfoBatch(ctx context.Context, paths []internal.UnitModulePath) (map[internal.UnitModulePath]internal.LatestInfo, error) {
+	return nil, nil
+}
+
 // GetNestedModules is not implemented.
 func (ds *DataSource) GetNestedModules(ctx context.Context, modulePath string) ([]*internal.ModuleInfo, error) {
 	return nil, nil
 }
 
+// GetSymbolUsers is not implemented.
+func (*DataSource) GetSymbolUsers(ctx context.Context, pkgPath, symbol string, limit int) ([]string, error) {
+	return nil, nil
+}
+
+// GetPathRedirect is not implemented.
+func (*DataSource) GetPathRedirect(ctx context.Context, fullPath string) (string, error) {
+	return "", nil
+}
+
+// GetModFile is not implemented.
+func (*DataSource) GetModFile(ctx context.Context, modulePath, resolvedVersion string) ([]byte, error) {
+	return nil, derrors.NotFound
+}
+
+// GetUnitFiles returns the names of the files of the package at fullPath.
+// Only one version of each module is loaded, so resolvedVersion is ignored.
+func (ds *DataSource) GetUnitFiles(ctx context.Context, fullPath, modulePath, resolvedVersion string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "GetUnitFiles(%q, %q)", fullPath, modulePath)
+
+	u, err := ds.GetUnit(ctx, &internal.UnitMeta{Path: fullPath, ModuleInfo: internal.ModuleInfo{ModulePath: modulePath}}, internal.AllFields)
+	if err != nil {
+		return nil, err
+	}
+	return u.Files, nil
+}
+
+// GetRequirements is not implemented.
+func (*DataSource) GetRequirements(ctx context.Context, modulePath, resolvedVersion string) ([]*internal.Requirement, error) {
+	return nil, nil
+}
+
+// GetModulesByLicenseType is not implemented.
+func (*DataSource) GetModulesByLicenseType(ctx context.Context, licenseType string, limit int, cursor string) ([]*internal.ModuleInfo, string, error) {
+	return nil, "", nil
+}
+
+// GetImportedByCount is not implemented.
+func (*DataSource) GetImportedByCount(ctx context.Context, pkgPath, modulePath string) (int, error) {
+	return 0, nil
+}
+
 // GetModuleReadme is not implemented.
 func (*DataSource) GetModuleReadme(ctx context.Context, modulePath, resolvedVersion string) (*internal.Readme, error) {
 	return nil, nil
 }
+
+// GetModuleBuildContexts is not implemented.
+func (*DataSource) GetModuleBuildContexts(ctx context.Context, modulePath, resolvedVersion string) ([]internal.BuildContext, error) {
+	return nil, nil
+}
+
+// GetUnitPaths returns the sorted paths of the packages in the given module
+// that can be imported from outside it.
+// Only one version of each module is loaded, so resolvedVersion is ignored.
+func (ds *DataSource) GetUnitPaths(ctx context.Context, modulePath, resolvedVersion string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "GetUnitPaths(%q)", modulePath)
+
+	ds.mu.Lock()
+	module := ds.loadedModules[modulePath]
+	ds.mu.Unlock()
+	if module == nil {
+		return nil, fmt.Errorf("%s not loaded: %w", modulePath, derrors.NotFound)
+	}
+	var paths []string
+	for _, p := range module.Packages() {
+		if p.IsImportable {
+			paths = append(paths, p.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// GetSymbols returns the exported symbols of the package at path, keyed by
+// build context. Only one version of each module is loaded, so
+// resolvedVersion is ignored.
+func (ds *DataSource) GetSymbols(ctx context.Context, path, modulePath, resolvedVersion string) (_ map[internal.BuildContext][]*internal.Symbol, err error) {
+	defer derrors.Wrap(&err, "GetSymbols(%q, %q)", path, modulePath)
+
+	u, err := ds.GetUnit(ctx, &internal.UnitMeta{Path: path, ModuleInfo: internal.ModuleInfo{ModulePath: modulePath}}, internal.AllFields)
+	if err != nil {
+		return nil, err
+	}
+	return internal.SymbolsByBuildContext(u.Documentation), nil
+}
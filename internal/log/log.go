@@ -7,14 +7,18 @@ package log
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/logging"
+	"go.opencensus.io/trace"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
@@ -46,6 +50,26 @@ func SetLevel(v string) {
 	currentLevel = toLevel(v)
 }
 
+// SetFormat sets the format of the logs that are not sent to Stackdriver.
+// Possible values are "json", for one JSON object per line on stdout, and ""
+// or "text", for the Go standard library logger. SetFormat has no effect after
+// UseStackdriver has been called.
+func SetFormat(v string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := logger.(*stackdriverLogger); ok {
+		return
+	}
+	switch strings.ToLower(v) {
+	case "json":
+		logger = &jsonLogger{w: os.Stdout}
+	case "", "text":
+		logger = stdlibLogger{}
+	default:
+		log.Printf("Error: %s is invalid LogFormat. Possible values are [json, text]", v)
+	}
+}
+
 func getLevel() logging.Severity {
 	mu.Lock()
 	defer mu.Unlock()
@@ -131,6 +155,68 @@ func (stdlibLogger) log(ctx context.Context, s logging.Severity, payload interfa
 
 }
 
+// jsonLogger writes each log entry to w as a line of JSON.
+type jsonLogger struct {
+	mu sync.Mutex // protects w
+	w  io.Writer
+}
+
+// jsonEntry is the JSON representation of a log entry written by jsonLogger.
+// The field names are understood by Cloud Logging, which can join entries
+// with the same trace and span IDs to their trace.
+type jsonEntry struct {
+	Severity string            `json:"severity"`
+	Message  interface{}       `json:"message"`
+	Trace    string            `json:"trace,omitempty"`
+	SpanID   string            `json:"spanId,omitempty"`
+	Time     time.Time         `json:"time"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+func (l *jsonLogger) log(ctx context.Context, s logging.Severity, payload interface{}) {
+	// Convert errors to strings, or they may serialize as the empty JSON object.
+	if err, ok := payload.(error); ok {
+		payload = err.Error()
+	}
+	e := jsonEntry{
+		// Cloud Logging expects upper-case severities, like "INFO".
+		Severity: strings.ToUpper(s.String()),
+		Message:  payload,
+		Time:     time.Now().UTC(),
+	}
+	if span := trace.FromContext(ctx); span != nil {
+		sc := span.SpanContext()
+		e.Trace = sc.TraceID.String()
+		e.SpanID = sc.SpanID.String()
+	} else {
+		e.Trace, _ = ctx.Value(traceIDKey{}).(string)
+	}
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	if es := experimentString(ctx); len(es) > 0 {
+		nl := map[string]string{}
+		for k, v := range labels {
+			nl[k] = v
+		}
+		nl["experiments"] = es
+		labels = nl
+	}
+	e.Labels = labels
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		// The payload cannot be represented as JSON; log it as a string.
+		e.Message = fmt.Sprintf("%+v", payload)
+		data, err = json.Marshal(e)
+		if err != nil {
+			log.Printf("%s: %+v (json.Marshal: %v)", s, payload, err)
+			return
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(data, '\n'))
+}
+
 func experimentString(ctx context.Context) string {
 	return strings.Join(experiment.FromContext(ctx).Active(), ", ")
 }
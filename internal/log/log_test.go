@@ -5,12 +5,18 @@
 package log
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/logging"
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/trace"
 )
 
 const (
@@ -114,6 +120,52 @@ func TestDefaultLogLevel(t *testing.T) {
 	}
 }
 
+// Do not run in parallel. It overrides logger.
+func TestJSONLogger(t *testing.T) {
+	oldLogger := logger
+	defer func() { logger = oldLogger }()
+	var buf bytes.Buffer
+	logger = &jsonLogger{w: &buf}
+	SetLevel("")
+
+	ctx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+	ctx = NewContextWithLabel(ctx, "k", "v")
+	Infof(ctx, "hello %d", 1)
+	Error(context.Background(), errors.New("failed"))
+
+	var got []jsonEntry
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e jsonEntry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("%v: zero time", e.Message)
+		}
+		e.Time = time.Time{}
+		got = append(got, e)
+	}
+	sc := span.SpanContext()
+	want := []jsonEntry{
+		{
+			Severity: "INFO",
+			Message:  "hello 1",
+			Trace:    sc.TraceID.String(),
+			SpanID:   sc.SpanID.String(),
+			Labels:   map[string]string{"k": "v"},
+		},
+		{
+			Severity: "ERROR",
+			Message:  "failed",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
 type mockLogger struct {
 	logs string
 }
@@ -0,0 +1,58 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequestIDHeader is the header that holds the ID of a request, both on
+// incoming requests and on the requests made while serving them.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the type of the context key for request IDs.
+type requestIDKey struct{}
+
+// NewContextWithRequestID creates a new context from ctx that adds the ID of
+// the request being served. The ID appears in log entries as the "requestID"
+// label.
+func NewContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = NewContextWithLabel(ctx, "requestID", requestID)
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID added to ctx by
+// NewContextWithRequestID, or the empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDTransport is an http.RoundTripper that sets the RequestIDHeader of
+// outgoing requests to the request ID of their context, if there is one. It
+// propagates request IDs to the services that are called while serving a
+// request.
+type RequestIDTransport struct {
+	// Base is the RoundTripper that makes the requests. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	id := RequestIDFromContext(req.Context())
+	if id == "" || req.Header.Get(RequestIDHeader) != "" {
+		return base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, id)
+	return base.RoundTrip(req)
+}
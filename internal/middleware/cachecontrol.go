@@ -0,0 +1,201 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/stdlib"
+)
+
+// A CacheControlPolicy is an entry in the table passed to CacheControl.
+type CacheControlPolicy struct {
+	// Name describes the class of routes the policy applies to.
+	Name string
+	// Match reports whether the policy applies to the request.
+	Match func(*http.Request) bool
+	// Value is the Cache-Control header value for matching requests.
+	Value string
+	// VaryCookie reports whether responses to matching requests depend on
+	// cookies, like the build context and experiments cookies, so that
+	// shared caches must key them on the Cookie header.
+	VaryCookie bool
+}
+
+// DefaultCacheControlPolicies returns the policies used by the frontend:
+// nothing is stored for POST requests and the playground, static assets whose
+// URL carries a version query parameter are cached for a long time, since
+// the version changes whenever the app is redeployed, as are pages for a fully
+// qualified version of a unit, like @v1.2.3, since the version never changes.
+// Other static assets and pages for a version prefix or the latest version of
+// a unit are cached briefly. Unit pages depend on cookies, so they vary by the Cookie header.
+func DefaultCacheControlPolicies() []CacheControlPolicy {
+	return []CacheControlPolicy{
+		{
+			Name: "post",
+			Match: func(r *http.Request) bool {
+				return r.Method == http.MethodPost || r.URL.Path == "/play" || strings.HasPrefix(r.URL.Path, "/play/")
+			},
+			Value: "no-store",
+		},
+		{
+			Name: "static-versioned",
+			Match: func(r *http.Request) bool {
+				return isStaticPath(r.URL.Path) && r.URL.Query().Get("version") != ""
+			},
+			Value: "public, max-age=31536000, immutable",
+		},
+		{
+			Name: "static",
+			Match: func(r *http.Request) bool {
+				return isStaticPath(r.URL.Path)
+			},
+			Value: "public, max-age=3600",
+		},
+		{
+			Name: "versioned-full",
+			Match: func(r *http.Request) bool {
+				return !isVolatileTab(r) && isFullVersion(requestedVersion(r.URL.Path))
+			},
+			// The contents of a module version never change.
+			Value:      "public, max-age=31536000, immutable",
+			VaryCookie: true,
+		},
+		{
+			Name: "versioned",
+			Match: func(r *http.Request) bool {
+				if isVolatileTab(r) {
+					return false
+				}
+				v := requestedVersion(r.URL.Path)
+				return semver.IsValid(v) || semver.IsValid(stdlib.VersionForTag(v))
+			},
+			// A page for a fixed version still changes when the site does,
+			// or when the module is reprocessed.
+			Value:      "public, max-age=3600",
+			VaryCookie: true,
+		},
+		{
+			Name: "latest",
+			Match: func(r *http.Request) bool {
+				v := requestedVersion(r.URL.Path)
+				return v == internal.LatestVersion || internal.DefaultBranches[v]
+			},
+			Value:      "public, max-age=600",
+			VaryCookie: true,
+		},
+	}
+}
+
+// isVolatileTab reports whether r is for a unit page tab that changes as
+// other modules are fetched, even for a fixed version: the imported-by and
+// versions tabs.
+func isVolatileTab(r *http.Request) bool {
+	tab := r.FormValue("tab")
+	return tab == "importedby" || tab == "versions"
+}
+
+// isFullVersion reports whether v names a single version: a complete semantic
+// version like v1.2.3 or a pseudo-version, or a Go release tag like go1.16.3,
+// but not a version prefix like v1.2.
+func isFullVersion(v string) bool {
+	if !semver.IsValid(v) {
+		return semver.IsValid(stdlib.VersionForTag(v))
+	}
+	core := v
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	return strings.Count(core, ".") == 2
+}
+
+// isStaticPath reports whether urlPath is that of a static asset.
+func isStaticPath(urlPath string) bool {
+	return strings.HasPrefix(urlPath, "/static/") || strings.HasPrefix(urlPath, "/third_party/")
+}
+
+// requestedVersion returns the version following the "@" in a URL path like
+// /github.com/a/b@v1.2.3/c, or the empty string if there is none.
+func requestedVersion(urlPath string) string {
+	i := strings.IndexByte(urlPath, '@')
+	if i < 0 {
+		return ""
+	}
+	v := urlPath[i+1:]
+	if j := strings.IndexByte(v, '/'); j >= 0 {
+		v = v[:j]
+	}
+	return v
+}
+
+// CacheControl sets the Cache-Control header of successful responses
+// according to the first policy that matches the request, replacing any
+// value set by the handler. Responses to requests that match no policy, and
+// error responses, are left alone.
+func CacheControl(policies ...CacheControlPolicy) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range policies {
+				if p.Match(r) {
+					w = &cacheControlWriter{ResponseWriter: w, value: p.Value, varyCookie: p.VaryCookie}
+					break
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// privateCacheControl is the Cache-Control value for responses that must not
+// be stored by shared caches, such as those that set a cookie.
+const privateCacheControl = "private, no-store"
+
+// cacheControlWriter is an http.ResponseWriter that sets the Cache-Control
+// header when the response status is written, if the status indicates
+// success. Responses that set a cookie are never made publicly cacheable.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	value       string
+	varyCookie  bool
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		switch {
+		case w.Header().Get("Set-Cookie") != "":
+			w.Header().Set("Cache-Control", privateCacheControl)
+		case (code >= 200 && code < 300) || code == http.StatusNotModified:
+			w.Header().Set("Cache-Control", w.value)
+			if w.varyCookie {
+				addVary(w.Header(), "Cookie")
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// addVary adds field to the Vary header of h, unless it is already there.
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestCacheControl(t *testing.T) {
+	internal.AddDefaultBranches("trunk")
+	defer delete(internal.DefaultBranches, "trunk")
+
+	var status int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=1")
+		w.WriteHeader(status)
+	})
+	h := CacheControl(DefaultCacheControlPolicies()...)(handler)
+
+	for _, test := range []struct {
+		method, url string
+		status      int
+		want        string
+	}{
+		{"GET", "/github.com/a/b@v1.2.3/c", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"GET", "/github.com/a/b@v1.2.3-pre.1+incompatible/c", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"GET", "/github.com/a/b@v0.0.0-20210101000000-abcdefabcdef", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"GET", "/std@go1.16.3", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"GET", "/github.com/a/b@v1.2.3/c", http.StatusNotModified, "public, max-age=31536000, immutable"},
+		{"GET", "/github.com/a/b@v1.2/c", http.StatusOK, "public, max-age=3600"},
+		{"GET", "/github.com/a/b@v1.2.3/c?tab=importedby", http.StatusOK, "public, max-age=1"},
+		{"GET", "/github.com/a/b@v1.2.3/c", http.StatusNotFound, "public, max-age=1"},
+		{"GET", "/github.com/a/b@latest/c", http.StatusOK, "public, max-age=600"},
+		{"GET", "/github.com/a/b@master", http.StatusOK, "public, max-age=600"},
+		{"GET", "/github.com/a/b@trunk/c", http.StatusOK, "public, max-age=600"},
+		{"GET", "/github.com/a/b@develop/c", http.StatusOK, "public, max-age=1"},
+		{"GET", "/static/css/main.css?version=abc", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"GET", "/third_party/dialog-polyfill/dialog-polyfill.css?version=abc", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"GET", "/static/css/main.css", http.StatusOK, "public, max-age=3600"},
+		{"POST", "/play/fmt", http.StatusOK, "no-store"},
+		{"GET", "/play", http.StatusOK, "no-store"},
+		{"GET", "/playground.example.com/a@v1.2.3", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"POST", "/fetch/github.com/a/b@v1.2.3", http.StatusOK, "no-store"},
+		{"GET", "/search?q=foo", http.StatusOK, "public, max-age=1"},
+	} {
+		t.Run(test.method+" "+test.url, func(t *testing.T) {
+			status = test.status
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(test.method, test.url, nil))
+			if got := w.Result().Header.Get("Cache-Control"); got != test.want {
+				t.Errorf("got Cache-Control %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCacheControlVary(t *testing.T) {
+	var vary string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if vary != "" {
+			w.Header().Set("Vary", vary)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	h := CacheControl(DefaultCacheControlPolicies()...)(handler)
+
+	for _, test := range []struct {
+		url, vary string
+		want      []string
+	}{
+		{"/github.com/a/b@v1.2.3/c", "", []string{"Cookie"}},
+		{"/github.com/a/b@latest/c", "", []string{"Cookie"}},
+		{"/github.com/a/b@v1.2.3/c", "Cookie", []string{"Cookie"}},
+		{"/github.com/a/b@v1.2.3/c", "Accept-Encoding, cookie", []string{"Accept-Encoding, cookie"}},
+		{"/github.com/a/b@v1.2.3/c", "Accept-Encoding", []string{"Accept-Encoding", "Cookie"}},
+		{"/static/css/main.css", "", nil},
+	} {
+		t.Run(test.url+" "+test.vary, func(t *testing.T) {
+			vary = test.vary
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+			if got := w.Result().Header.Values("Vary"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got Vary %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCacheControlSetCookie(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "c", Value: "v"})
+		w.WriteHeader(http.StatusOK)
+	})
+	h := CacheControl(DefaultCacheControlPolicies()...)(handler)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/github.com/a/b@v1.2.3/c", nil))
+	if got, want := w.Result().Header.Get("Cache-Control"), privateCacheControl; got != want {
+		t.Errorf("got Cache-Control %q, want %q", got, want)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal"
 	icache "golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/cookie"
@@ -125,6 +127,12 @@ func Cache(name string, client *redis.Client, expirer Expirer, authValues []stri
 }
 
 func (c *cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Only GET responses are cached. Handlers may answer a HEAD request
+	// without a body, which must not be served to a later GET.
+	if r.Method != http.MethodGet {
+		c.delegate.ServeHTTP(w, r)
+		return
+	}
 	// Check auth header to see if request should bypass cache.
 	authVal := r.Header.Get(config.BypassCacheAuthHeader)
 	for _, wantVal := range c.authValues {
@@ -139,13 +147,26 @@ func (c *cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ctx := r.Context()
-	key := r.URL.String()
+	key := cacheKey(r)
 	start := time.Now()
 	reader, hit := c.get(ctx, key)
 	recordCacheResult(ctx, c.name, hit, time.Since(start))
 	if hit {
-		if _, err := io.Copy(w, reader); err != nil {
-			log.Errorf(ctx, "error copying zip bytes: %v", err)
+		body, err := ioutil.ReadAll(reader)
+		if err != nil {
+			log.Errorf(ctx, "error reading zip bytes: %v", err)
+			recordCacheError(ctx, c.name, "UNZIP")
+			c.delegate.ServeHTTP(w, r)
+			return
+		}
+		// A request that selects a build context remembers it in a cookie.
+		if selectsBuildContext(r) {
+			q := r.URL.Query()
+			cookie.SetBuildContext(w, q.Get("GOOS"), q.Get("GOARCH"))
+		}
+		// The page was rendered with the nonce of another request.
+		if _, err := w.Write(SubstituteCSPNonce(ctx, body)); err != nil {
+			log.Errorf(ctx, "error writing cached response: %v", err)
 		}
 		return
 	}
@@ -190,23 +211,32 @@ func (c *cache) get(ctx context.Context, key string) (io.Reader, bool) {
 }
 
 func (c *cache) put(ctx context.Context, key string, rec *cacheRecorder, ttl time.Duration) {
-	if err := rec.zipWriter.Close(); err != nil {
+	body := rec.buf.Bytes()
+	// Don't store the nonce of this request; see SubstituteCSPNonce.
+	if nonce := peekCSPNonce(ctx); nonce != "" {
+		body = bytes.ReplaceAll(body, []byte(nonce), []byte(CSPNoncePlaceholder))
+	}
+	var zbuf bytes.Buffer
+	zw := gzip.NewWriter(&zbuf)
+	if _, err := zw.Write(body); err != nil {
+		log.Errorf(ctx, "cache: error zipping %q: %v", key, err)
+		return
+	}
+	if err := zw.Close(); err != nil {
 		log.Errorf(ctx, "cache: error closing zip for %q: %v", key, err)
 		return
 	}
-	log.Infof(ctx, "caching response of length %d for %s", rec.buf.Len(), key)
+	log.Infof(ctx, "caching response of length %d for %s", zbuf.Len(), key)
 	setCtx, cancelSet := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancelSet()
-	if err := c.cache.Put(setCtx, key, rec.buf.Bytes(), ttl); err != nil {
+	if err := c.cache.Put(setCtx, key, zbuf.Bytes(), ttl); err != nil {
 		recordCacheError(ctx, c.name, "SET")
 		log.Warningf(ctx, "cache set %q: %v", key, err)
 	}
 }
 
 func newRecorder(w http.ResponseWriter) *cacheRecorder {
-	buf := &bytes.Buffer{}
-	zw := gzip.NewWriter(buf)
-	return &cacheRecorder{ResponseWriter: w, buf: buf, zipWriter: zw}
+	return &cacheRecorder{ResponseWriter: w, buf: &bytes.Buffer{}}
 }
 
 // cacheRecorder is an http.ResponseWriter that collects http bytes for later
@@ -216,9 +246,8 @@ type cacheRecorder struct {
 	http.ResponseWriter
 	statusCode int
 
-	bufErr    error
-	buf       *bytes.Buffer
-	zipWriter *gzip.Writer
+	bufErr error
+	buf    *bytes.Buffer
 }
 
 func (r *cacheRecorder) Write(b []byte) (int, error) {
@@ -226,13 +255,8 @@ func (r *cacheRecorder) Write(b []byte) (int, error) {
 	// Only try writing to the buffer if we haven't yet encountered an error.
 	if r.bufErr == nil {
 		if err == nil {
-			zn, bufErr := r.zipWriter.Write(b)
-			if bufErr != nil {
-				r.bufErr = bufErr
-			}
-			if zn != n {
-				r.bufErr = fmt.Errorf("wrote %d to zip, but wanted %d", zn, n)
-			}
+			// Writes to a bytes.Buffer always succeed.
+			r.buf.Write(b[:n])
 		} else {
 			r.bufErr = fmt.Errorf("ResponseWriter.Write failed: %v", err)
 		}
@@ -248,3 +272,31 @@ func (r *cacheRecorder) WriteHeader(statusCode int) {
 	}
 	r.ResponseWriter.WriteHeader(statusCode)
 }
+
+// cacheKey returns the key under which the response to r is cached. Pages
+// depend on the build context, which a request selects with the GOOS and
+// GOARCH query parameters, or otherwise takes from the build context cookie.
+// A cookie that does not hold a known build context is ignored, as it is by
+// the frontend, so clients cannot create cache entries at will.
+func cacheKey(r *http.Request) string {
+	key := r.URL.String()
+	if selectsBuildContext(r) {
+		return key
+	}
+	val, err := cookie.Get(r, cookie.BuildContext)
+	if err != nil {
+		return key
+	}
+	if bc, ok := internal.ParseBuildContext(val); ok && bc != (internal.BuildContext{}) {
+		key += " " + cookie.BuildContext + "=" + bc.String()
+	}
+	return key
+}
+
+// selectsBuildContext reports whether r has a GOOS or GOARCH query parameter.
+func selectsBuildContext(r *http.Request) bool {
+	q := r.URL.Query()
+	_, hasGOOS := q["GOOS"]
+	_, hasGOARCH := q["GOARCH"]
+	return hasGOOS || hasGOARCH
+}
@@ -5,11 +5,13 @@
 package middleware
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"go.opencensus.io/stats/view"
 	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/cookie"
 )
 
 func TestCache(t *testing.T) {
@@ -57,7 +60,9 @@ func TestCache(t *testing.T) {
 		body          string
 		status        int
 		bypass        bool
+		buildContext  bool
 		wantHitCounts map[bool]int
+		wantCookie    bool // whether the response sets the build context cookie
 		wantBody      string
 		wantStatus    int
 	}{
@@ -130,6 +135,41 @@ func TestCache(t *testing.T) {
 			wantBody:      "6",
 			wantStatus:    http.StatusOK,
 		},
+		{
+			label:         "build context cookie is part of the key",
+			path:          "A",
+			body:          "7",
+			buildContext:  true,
+			wantHitCounts: map[bool]int{false: 4, true: 2},
+			wantBody:      "7",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			label:         "A with build context cookie is cached",
+			path:          "A",
+			body:          "8",
+			buildContext:  true,
+			wantHitCounts: map[bool]int{false: 4, true: 3},
+			wantBody:      "7",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			label:         "selecting a build context",
+			path:          "A?GOOS=windows",
+			body:          "9",
+			wantHitCounts: map[bool]int{false: 5, true: 3},
+			wantBody:      "9",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			label:         "selected build context is cached and sets the cookie",
+			path:          "A?GOOS=windows",
+			body:          "10",
+			wantHitCounts: map[bool]int{false: 5, true: 4},
+			wantBody:      "9",
+			wantStatus:    http.StatusOK,
+			wantCookie:    true,
+		},
 	}
 
 	for _, test := range tests {
@@ -143,6 +183,9 @@ func TestCache(t *testing.T) {
 		if test.bypass {
 			req.Header.Set(config.BypassCacheAuthHeader, "yes")
 		}
+		if test.buildContext {
+			req.AddCookie(&http.Cookie{Name: cookie.BuildContext, Value: "d2luZG93cy9hbWQ2NA=="})
+		}
 		resp, err := ts.Client().Do(req)
 		if err != nil {
 			t.Fatal(err)
@@ -150,6 +193,15 @@ func TestCache(t *testing.T) {
 		if resp.StatusCode != test.wantStatus {
 			t.Errorf("[%s] GET returned status %d, want %d", test.label, resp.StatusCode, test.wantStatus)
 		}
+		if test.wantCookie {
+			var found bool
+			for _, c := range resp.Cookies() {
+				found = found || c.Name == cookie.BuildContext
+			}
+			if !found {
+				t.Errorf("[%s] GET did not set the %s cookie", test.label, cookie.BuildContext)
+			}
+		}
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			t.Fatal(err)
@@ -178,3 +230,78 @@ func TestCache(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheKey(t *testing.T) {
+	encode := func(s string) string { return base64.URLEncoding.EncodeToString([]byte(s)) }
+	for _, test := range []struct {
+		url, cookie string // cookie is the raw value of the build context cookie
+		want        string
+	}{
+		{"/p", "", "/p"},
+		{"/p", encode("windows/amd64"), "/p build-context=windows/amd64"},
+		{"/p", encode("windows/"), "/p build-context=windows/"},
+		{"/p?GOOS=linux", encode("windows/amd64"), "/p?GOOS=linux"},
+		// Unknown or malformed build contexts are ignored.
+		{"/p", encode("/"), "/p"},
+		{"/p", encode("plan10/amd64"), "/p"},
+		{"/p", encode("windows/amd64 junk"), "/p"},
+		{"/p", "not base64!", "/p"},
+	} {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: cookie.BuildContext, Value: test.cookie})
+		}
+		if got := cacheKey(r); got != test.want {
+			t.Errorf("%s with cookie %q: got %q, want %q", test.url, test.cookie, got, test.want)
+		}
+	}
+}
+
+func TestCacheSkipsHead(t *testing.T) {
+	TestMode = true
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			fmt.Fprint(w, "body")
+		}
+	})
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	h := Cache("A", redis.NewClient(&redis.Options{Addr: s.Addr()}), TTL(time.Minute), nil)(handler)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("HEAD", "/A", nil))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/A", nil))
+	if got := w.Body.String(); got != "body" {
+		t.Errorf("GET after HEAD: got body %q, want %q", got, "body")
+	}
+}
+
+func TestCacheSubstitutesNonce(t *testing.T) {
+	TestMode = true
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<script nonce="%s"></script>`, CSPNonce(r.Context()))
+	})
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	h := SecureHeaders(true, "")(Cache("A", redis.NewClient(&redis.Options{Addr: s.Addr()}), TTL(time.Minute), nil)(handler))
+
+	for _, label := range []string{"miss", "hit"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/A", nil))
+		csp := w.Header().Get("Content-Security-Policy")
+		i := strings.Index(csp, "'nonce-")
+		if i < 0 {
+			t.Fatalf("%s: no nonce in Content-Security-Policy %q", label, csp)
+		}
+		nonce := strings.TrimSuffix(strings.Fields(csp[i+len("'nonce-"):])[0], "'")
+		if got, want := w.Body.String(), `<script nonce="`+nonce+`"></script>`; got != want {
+			t.Errorf("%s: got body %q, want %q", label, got, want)
+		}
+	}
+}
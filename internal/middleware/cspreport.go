@@ -0,0 +1,90 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// maxCSPReportSize is the maximum size of a CSP violation report body.
+const maxCSPReportSize = 64 * 1024
+
+// cspViolation holds the fields of a CSP violation report that we log.
+type cspViolation struct {
+	DocumentURL string
+	Directive   string
+	BlockedURL  string
+}
+
+// CSPReportHandler returns a handler that accepts the content-security-policy
+// violation reports that browsers send to the endpoint passed to
+// SecureHeaders, and logs them as warnings.
+//
+// It understands both the report-uri format (Content-Type
+// application/csp-report) and the Reporting API format used by report-to
+// (Content-Type application/reports+json).
+func CSPReportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportSize))
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		vs, err := parseCSPReports(body)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		for _, v := range vs {
+			log.Warningf(r.Context(), "CSP violation: document %q, directive %q, blocked %q",
+				v.DocumentURL, v.Directive, v.BlockedURL)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// parseCSPReports parses the body of a CSP violation report in either the
+// report-uri or the Reporting API format.
+func parseCSPReports(body []byte) ([]cspViolation, error) {
+	// The Reporting API sends a list of reports of various types.
+	var reports []struct {
+		Type string
+		Body struct {
+			DocumentURL        string `json:"documentURL"`
+			EffectiveDirective string `json:"effectiveDirective"`
+			BlockedURL         string `json:"blockedURL"`
+		}
+	}
+	if err := json.Unmarshal(body, &reports); err == nil {
+		var vs []cspViolation
+		for _, r := range reports {
+			if r.Type != "csp-violation" {
+				continue
+			}
+			vs = append(vs, cspViolation{r.Body.DocumentURL, r.Body.EffectiveDirective, r.Body.BlockedURL})
+		}
+		return vs, nil
+	}
+	var report struct {
+		CSPReport struct {
+			DocumentURI       string `json:"document-uri"`
+			ViolatedDirective string `json:"violated-directive"`
+			BlockedURI        string `json:"blocked-uri"`
+		} `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+	c := report.CSPReport
+	return []cspViolation{{c.DocumentURI, c.ViolatedDirective, c.BlockedURI}}, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bytes"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCSPReportHandler(t *testing.T) {
+	var logs bytes.Buffer
+	stdlog.SetOutput(&logs)
+	defer stdlog.SetOutput(os.Stderr)
+
+	for _, test := range []struct {
+		name, contentType, body string
+	}{
+		{
+			"report-uri",
+			"application/csp-report",
+			`{"csp-report": {"document-uri": "https://pkg.go.dev/a", "violated-directive": "script-src", "blocked-uri": "https://evil.test/x.js"}}`,
+		},
+		{
+			"report-to",
+			"application/reports+json",
+			`[{"type": "csp-violation", "body": {"documentURL": "https://pkg.go.dev/a", "effectiveDirective": "script-src", "blockedURL": "https://evil.test/x.js"}}]`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			logs.Reset()
+			r := httptest.NewRequest("POST", "/csp-report", strings.NewReader(test.body))
+			r.Header.Set("Content-Type", test.contentType)
+			w := httptest.NewRecorder()
+			CSPReportHandler().ServeHTTP(w, r)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("got status %d, want %d", w.Code, http.StatusNoContent)
+			}
+			got := logs.String()
+			for _, want := range []string{"Warning", "CSP violation", "https://pkg.go.dev/a", "script-src", "https://evil.test/x.js"} {
+				if !strings.Contains(got, want) {
+					t.Errorf("log %q does not contain %q", got, want)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	CSPReportHandler().ServeHTTP(w, httptest.NewRequest("GET", "/csp-report", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: got status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
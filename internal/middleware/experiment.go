@@ -9,10 +9,13 @@ import (
 	"fmt"
 	"hash/fnv"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/errorreporting"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cookie"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
@@ -68,9 +71,19 @@ func NewExperimenter(ctx context.Context, pollEvery time.Duration, getter Experi
 // Experiment returns a new Middleware that sets active experiments for each
 // incoming request.
 func Experiment(e *Experimenter) Middleware {
+	return StickyExperiment(e, nil)
+}
+
+// StickyExperiment is like Experiment, but remembers the experiments that a
+// request was assigned to by IP rollout in a cookie signed with cookieKey, and
+// uses that assignment instead of the IP on later requests, until the
+// experiment configuration changes. If cookieKey is empty, it is the same as
+// Experiment.
+func StickyExperiment(e *Experimenter, cookieKey []byte) Middleware {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			r2 := e.setExperimentsForRequest(r)
+			r2 := e.setExperimentsForRequest(w, r, cookieKey)
+			recordExperiments(r2)
 			h.ServeHTTP(w, r2)
 		})
 	}
@@ -92,26 +105,94 @@ func (e *Experimenter) Experiments() []*internal.Experiment {
 }
 
 // setExperimentsForRequest sets the experiments for a given request.
-// Experiments should be stable for a given IP address.
-func (e *Experimenter) setExperimentsForRequest(r *http.Request) *http.Request {
+// Experiments should be stable for a given IP address, or, if cookieKey is
+// non-empty, for a given experiments cookie. The cookie is only set for
+// requests that are enrolled in an experiment by IP rollout, never for static
+// assets, and a response that sets it is marked as uncacheable.
+func (e *Experimenter) setExperimentsForRequest(w http.ResponseWriter, r *http.Request, cookieKey []byte) *http.Request {
 	snapshot := e.p.Current().([]*internal.Experiment)
-	var exps []string
+	var (
+		fingerprint string
+		sticky      map[string]bool
+	)
+	if isStaticPath(r.URL.Path) {
+		cookieKey = nil
+	}
+	if len(cookieKey) > 0 {
+		fingerprint = experimentsFingerprint(snapshot)
+		sticky = stickyExperiments(r, cookieKey, fingerprint)
+	}
+	var exps, assigned []string
 	for _, exp := range snapshot {
-		if shouldSetExperiment(r, exp) {
+		var active bool
+		if sticky != nil {
+			active = sticky[exp.Name] || matchesPathPrefix(r.URL.Path, exp.PathPrefixes)
+		} else {
+			active = shouldSetExperiment(r, exp)
+			if len(cookieKey) > 0 && inRollout(r, exp) {
+				assigned = append(assigned, exp.Name)
+			}
+		}
+		if active {
 			exps = append(exps, exp.Name)
 		}
 	}
+	// Only remember an assignment to at least one experiment that was based
+	// on an IP address. Responses to other requests stay cacheable.
+	if len(assigned) > 0 && sticky == nil && ipKey(r.Header.Get("X-Forwarded-For")) != "" {
+		cookie.SetSigned(w, cookie.Experiments, fingerprint+":"+strings.Join(assigned, ","), "/", cookieKey)
+		w.Header().Set("Cache-Control", privateCacheControl)
+	}
 	exps = append(exps, r.URL.Query()[experimentQueryParamKey]...)
 	return r.WithContext(experiment.NewContext(r.Context(), exps...))
 }
 
+// stickyExperiments returns the set of experiment names in the experiments
+// cookie of r, or nil if there is no valid cookie for the experiment
+// configuration identified by fingerprint.
+func stickyExperiments(r *http.Request, cookieKey []byte, fingerprint string) map[string]bool {
+	val, err := cookie.GetSigned(r, cookie.Experiments, cookieKey)
+	if err != nil {
+		log.Warningf(r.Context(), "ignoring experiments cookie: %v", err)
+		return nil
+	}
+	i := strings.IndexByte(val, ':')
+	if i < 0 || val[:i] != fingerprint {
+		return nil
+	}
+	m := map[string]bool{}
+	for _, name := range strings.Split(val[i+1:], ",") {
+		if name != "" {
+			m[name] = true
+		}
+	}
+	return m
+}
+
+// experimentsFingerprint returns a string that changes whenever the IP
+// rollout of exps does.
+func experimentsFingerprint(exps []*internal.Experiment) string {
+	h := fnv.New32a()
+	for _, e := range exps {
+		fmt.Fprintf(h, "%s %d\n", e.Name, e.Rollout)
+	}
+	return strconv.FormatUint(uint64(h.Sum32()), 16)
+}
+
 // shouldSetExperiment reports whether a given request should be enrolled in
 // the experiment, based on the ip. e.Name, and e.Rollout.
 //
-// Requests from empty ip addresses are never enrolled.
-// All requests from the same IP will be enrolled in the same set of
+// Requests for paths matching one of e.PathPrefixes are always enrolled.
+// Otherwise, requests from empty ip addresses are never enrolled, and
+// all requests from the same IP will be enrolled in the same set of
 // experiments.
 func shouldSetExperiment(r *http.Request, e *internal.Experiment) bool {
+	return matchesPathPrefix(r.URL.Path, e.PathPrefixes) || inRollout(r, e)
+}
+
+// inRollout reports whether a request from the ip of r falls within
+// e.Rollout.
+func inRollout(r *http.Request, e *internal.Experiment) bool {
 	if e.Rollout == 0 {
 		return false
 	}
@@ -126,3 +207,26 @@ func shouldSetExperiment(r *http.Request, e *internal.Experiment) bool {
 	fmt.Fprintf(h, "%s %s", ip, e.Name)
 	return uint(h.Sum32())%100 < e.Rollout
 }
+
+// matchesPathPrefix reports whether urlPath, like /github.com/a/b@v1.2.3/c,
+// is for one of prefixes or a path beneath it. The version is ignored.
+func matchesPathPrefix(urlPath string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return false
+	}
+	p := strings.TrimPrefix(urlPath, "/")
+	if i := strings.IndexByte(p, '@'); i >= 0 {
+		rest := p[i+1:]
+		p = p[:i]
+		if j := strings.IndexByte(rest, '/'); j >= 0 {
+			p += rest[j:]
+		}
+	}
+	for _, prefix := range prefixes {
+		prefix = strings.Trim(prefix, "/")
+		if prefix != "" && (p == prefix || strings.HasPrefix(p, prefix+"/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -69,7 +69,7 @@ func TestSetAndLoadExperiments(t *testing.T) {
 	mu.Lock()
 	testExps = []*internal.Experiment{{Name: testFeature, Rollout: 0}}
 	mu.Unlock()
-	time.Sleep(100 * time.Millisecond)
+	experimenter.p.Poll(ctx)
 	makeRequest(t)
 	if featureIsOn {
 		t.Fatalf("experiment %q should not be active", testFeature)
@@ -123,3 +123,147 @@ func TestShouldSetExperiment(t *testing.T) {
 		})
 	}
 }
+
+func TestShouldSetExperimentPathPrefixes(t *testing.T) {
+	ctx := context.Background()
+	const testFeature = "test-feature"
+	exps := []*internal.Experiment{{
+		Name:         testFeature,
+		Rollout:      0,
+		PathPrefixes: []string{"github.com/a/b"},
+	}}
+	experimenter, err := NewExperimenter(ctx, time.Hour, func(context.Context) ([]*internal.Experiment, error) {
+		return exps, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var featureIsOn bool
+	h := Experiment(experimenter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		featureIsOn = experiment.IsActive(r.Context(), testFeature)
+	}))
+	for _, test := range []struct {
+		path string
+		want bool
+	}{
+		{"/github.com/a/b", true},
+		{"/github.com/a/b/c", true},
+		{"/github.com/a/b@v1.2.3/c", true},
+		{"/github.com/a/b@latest", true},
+		{"/github.com/a/bc", false},
+		{"/github.com/a", false},
+		{"/github.com/x/y@v1.2.3/github.com/a/b", false},
+		{"/", false},
+	} {
+		req := httptest.NewRequest("GET", test.path, nil)
+		req.Header.Set("X-Forwarded-For", "1.2.3.4")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if featureIsOn != test.want {
+			t.Errorf("%s: experiment active = %t, want %t", test.path, featureIsOn, test.want)
+		}
+	}
+}
+
+func TestStickyExperiment(t *testing.T) {
+	ctx := context.Background()
+	const testFeature = "test-feature"
+	var mu sync.Mutex
+	testExps := []*internal.Experiment{{Name: testFeature, Rollout: 50}}
+	experimenter, err := NewExperimenter(ctx, time.Hour, func(context.Context) ([]*internal.Experiment, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return testExps, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find an IP address in the experiment and one outside it.
+	var ipIn, ipOut string
+	for i := 0; i < 256 && (ipIn == "" || ipOut == ""); i++ {
+		ip := fmt.Sprintf("1.2.%d.4", i)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		if inRollout(req, testExps[0]) {
+			ipIn = ip
+		} else {
+			ipOut = ip
+		}
+	}
+
+	var featureIsOn bool
+	h := StickyExperiment(experimenter, []byte("0123456789abcdef"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		featureIsOn = experiment.IsActive(r.Context(), testFeature)
+	}))
+	serve := func(path, ip string, cookies []string) http.Header {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		req.Header["Cookie"] = cookies
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Header()
+	}
+	makeRequest := func(ip string, cookies []string) []string {
+		t.Helper()
+		return serve("/net/http", ip, cookies)["Set-Cookie"]
+	}
+
+	header := serve("/net/http", ipIn, nil)
+	cookies := header["Set-Cookie"]
+	if !featureIsOn {
+		t.Fatalf("%s: experiment should be active", ipIn)
+	}
+	if len(cookies) == 0 {
+		t.Fatal("no experiments cookie set")
+	}
+	if got, want := header.Get("Cache-Control"), privateCacheControl; got != want {
+		t.Errorf("with Set-Cookie: got Cache-Control %q, want %q", got, want)
+	}
+	// Static assets don't get the cookie, so they stay cacheable.
+	if got := serve("/static/css/main.css", ipIn, nil); len(got["Set-Cookie"]) != 0 || got.Get("Cache-Control") != "" {
+		t.Errorf("static asset: got Set-Cookie %q, Cache-Control %q; want neither", got["Set-Cookie"], got.Get("Cache-Control"))
+	}
+	// The cookie preserves the assignment when the IP changes.
+	if got := makeRequest(ipOut, cookies); len(got) != 0 {
+		t.Errorf("with valid cookie: got Set-Cookie %q, want none", got)
+	}
+	if !featureIsOn {
+		t.Errorf("%s with cookie: experiment should be active", ipOut)
+	}
+	// A request that is not enrolled gets no cookie and stays cacheable.
+	header = serve("/net/http", ipOut, nil)
+	if featureIsOn {
+		t.Errorf("%s without cookie: experiment should not be active", ipOut)
+	}
+	if len(header["Set-Cookie"]) != 0 || header.Get("Cache-Control") != "" {
+		t.Errorf("%s without cookie: got Set-Cookie %q, Cache-Control %q; want neither", ipOut, header["Set-Cookie"], header.Get("Cache-Control"))
+	}
+	// A tampered cookie is ignored.
+	makeRequest(ipOut, []string{"experiments=dGFtcGVyZWQ="})
+	if featureIsOn {
+		t.Errorf("%s with tampered cookie: experiment should not be active", ipOut)
+	}
+
+	// After the configuration changes, the assignment is made again.
+	mu.Lock()
+	testExps = []*internal.Experiment{{Name: testFeature, Rollout: 0}}
+	mu.Unlock()
+	experimenter.p.Poll(ctx)
+	if got := makeRequest(ipOut, cookies); len(got) != 0 {
+		t.Errorf("after config change: got Set-Cookie %q, want none", got)
+	}
+	if featureIsOn {
+		t.Errorf("%s after config change: experiment should not be active", ipOut)
+	}
+	mu.Lock()
+	testExps = []*internal.Experiment{{Name: testFeature, Rollout: 100}}
+	mu.Unlock()
+	experimenter.p.Poll(ctx)
+	if got := makeRequest(ipOut, cookies); len(got) == 0 {
+		t.Error("after config change: no new experiments cookie set")
+	}
+	if !featureIsOn {
+		t.Errorf("%s after config change to full rollout: experiment should be active", ipOut)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// A HealthCheck checks that a dependency of a server is available.
+type HealthCheck struct {
+	// Name identifies the dependency in the response.
+	Name string
+	// Required reports whether the server is unavailable when the check
+	// fails. Failures of other checks are reported, but do not change the
+	// response status.
+	Required bool
+	// Check returns an error if the dependency is not available.
+	Check func(context.Context) error
+}
+
+// DatabaseHealthCheck returns a required HealthCheck named "db" that runs a
+// trivial query on db.
+func DatabaseHealthCheck(db *database.DB) HealthCheck {
+	return HealthCheck{
+		Name:     "db",
+		Required: true,
+		Check: func(ctx context.Context) error {
+			var n int
+			return db.QueryRow(ctx, "SELECT 1").Scan(&n)
+		},
+	}
+}
+
+// RedisHealthCheck returns a HealthCheck that pings the redis client. It is
+// not required, because the servers can run without redis, though more
+// slowly.
+func RedisHealthCheck(name string, client *redis.Client) HealthCheck {
+	return HealthCheck{
+		Name: name,
+		Check: func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		},
+	}
+}
+
+// HealthStatus is the JSON body served by HealthHandler.
+type HealthStatus struct {
+	// OK reports whether all required checks passed.
+	OK bool
+	// Checks maps the name of each check to "ok" or "error". The errors are
+	// logged rather than served, since /healthz is public.
+	Checks map[string]string
+}
+
+// healthCheckTimeout bounds the time spent on each check.
+const healthCheckTimeout = 5 * time.Second
+
+// HealthHandler returns a handler that runs the checks concurrently and
+// responds with a HealthStatus: with status 200 if all required checks
+// passed, and 503 otherwise.
+func HealthHandler(checks ...HealthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		hs := HealthStatus{OK: true, Checks: map[string]string{}}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, c := range checks {
+			c := c
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := c.Check(ctx)
+				mu.Lock()
+				defer mu.Unlock()
+				if err == nil {
+					hs.Checks[c.Name] = "ok"
+					return
+				}
+				log.Warningf(ctx, "health check %s: %v", c.Name, err)
+				hs.Checks[c.Name] = "error"
+				if c.Required {
+					hs.OK = false
+				}
+			}()
+		}
+		wg.Wait()
+
+		data, err := json.Marshal(hs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !hs.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(data)
+	})
+}
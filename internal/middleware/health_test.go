@@ -0,0 +1,63 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/database"
+)
+
+func TestHealthHandler(t *testing.T) {
+	sqldb, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.Close()
+	closedDB := database.New(sqldb, "test")
+
+	ok := HealthCheck{Name: "ok", Required: true, Check: func(context.Context) error { return nil }}
+	optional := HealthCheck{Name: "optional", Check: func(context.Context) error { return errors.New("down") }}
+	for _, test := range []struct {
+		name       string
+		checks     []HealthCheck
+		wantStatus int
+		wantOK     map[string]bool
+	}{
+		{"all ok", []HealthCheck{ok}, http.StatusOK, map[string]bool{"ok": true}},
+		{"optional down", []HealthCheck{ok, optional}, http.StatusOK, map[string]bool{"ok": true, "optional": false}},
+		{"closed db", []HealthCheck{ok, DatabaseHealthCheck(closedDB)}, http.StatusServiceUnavailable, map[string]bool{"ok": true, "db": false}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HealthHandler(test.checks...).ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+			if w.Code != test.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, test.wantStatus)
+			}
+			var hs HealthStatus
+			if err := json.Unmarshal(w.Body.Bytes(), &hs); err != nil {
+				t.Fatal(err)
+			}
+			if hs.OK != (test.wantStatus == http.StatusOK) {
+				t.Errorf("got OK %t, want %t", hs.OK, !hs.OK)
+			}
+			for name, wantOK := range test.wantOK {
+				want := "error"
+				if wantOK {
+					want = "ok"
+				}
+				if got := hs.Checks[name]; got != want {
+					t.Errorf("check %s: got %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/pkgsite/internal/config"
+)
+
+// RateLimit implements an in-memory token-bucket rate limiter keyed on the
+// client IP, grouped in the same way as for Quota. Each IP block gets a
+// bucket of settings.Burst tokens that refills at settings.QPS tokens per
+// second, and each request takes one token.
+//
+// The client IP is the entry of the X-Forwarded-For header that the load
+// balancer appended, settings.ClientIPHop entries from its end, since the
+// entries before it are under the client's control. If ClientIPHop is zero,
+// the address of the connection is used instead. Requests whose client IP
+// cannot be determined share a single bucket.
+//
+// If a request is disallowed, a 429 (TooManyRequests) is served with a
+// Retry-After header. Requests for paths beginning with any of
+// exemptPrefixes are not limited.
+func RateLimit(settings config.RateLimitSettings, exemptPrefixes ...string) Middleware {
+	if !settings.Enable {
+		return Identity()
+	}
+	l := newIPLimiter(settings)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range exemptPrefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+			key := rateLimitKey(r, settings.ClientIPHop)
+			if ok, retryAfter := l.allow(key, time.Now()); !ok {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				const tmr = http.StatusTooManyRequests
+				http.Error(w, http.StatusText(tmr), tmr)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// unknownClientKey is the key of the bucket shared by requests whose client
+// IP cannot be determined.
+const unknownClientKey = "unknown"
+
+// rateLimitKey returns the key of the token bucket for r. See RateLimit.
+func rateLimitKey(r *http.Request, hop int) string {
+	if key := ipKey(clientIP(r, hop)); key != "" {
+		return key
+	}
+	return unknownClientKey
+}
+
+// clientIP returns the address of the client that made r: the entry of the
+// X-Forwarded-For header hop entries from its end, or the address of the
+// connection if hop is zero. It returns the empty string if there is no such
+// entry or address.
+func clientIP(r *http.Request, hop int) string {
+	if hop > 0 {
+		hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if i := len(hops) - hop; i >= 0 {
+			return strings.TrimSpace(hops[i])
+		}
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// An ipLimiter holds a token bucket for each IP block. When there are
+// maxEntries buckets, the least recently used one is evicted to make room
+// for a new one.
+type ipLimiter struct {
+	qps        float64
+	burst      float64
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List // of *tokenBucket, most recently used first
+	buckets map[string]*list.Element
+}
+
+type tokenBucket struct {
+	key    string
+	tokens float64   // tokens available at time last
+	last   time.Time // time of the last update
+}
+
+func newIPLimiter(settings config.RateLimitSettings) *ipLimiter {
+	return &ipLimiter{
+		qps:        float64(settings.QPS),
+		burst:      float64(settings.Burst),
+		maxEntries: settings.MaxEntries,
+		order:      list.New(),
+		buckets:    map[string]*list.Element{},
+	}
+}
+
+// allow reports whether a request at time now from the IP block key may
+// proceed. If not, it also returns how long until a token will be available.
+func (l *ipLimiter) allow(key string, now time.Time) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var b *tokenBucket
+	if el, ok := l.buckets[key]; ok {
+		l.order.MoveToFront(el)
+		b = el.Value.(*tokenBucket)
+	} else {
+		if l.maxEntries > 0 && len(l.buckets) >= l.maxEntries {
+			oldest := l.order.Back()
+			l.order.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*tokenBucket).key)
+		}
+		b = &tokenBucket{key: key, tokens: l.burst, last: now}
+		l.buckets[key] = l.order.PushFront(b)
+	}
+	b.tokens = l.tokensAt(b, now)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.qps <= 0 {
+		return false, time.Second
+	}
+	return false, time.Duration((1 - b.tokens) / l.qps * float64(time.Second))
+}
+
+// tokensAt returns the number of tokens in b at time now.
+func (l *ipLimiter) tokensAt(b *tokenBucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return b.tokens
+	}
+	return math.Min(l.burst, b.tokens+elapsed*l.qps)
+}
@@ -0,0 +1,129 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal/config"
+)
+
+func TestRateLimit(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	settings := config.RateLimitSettings{Enable: true, QPS: 1, Burst: 2, MaxEntries: 10, ClientIPHop: 1}
+	h := RateLimit(settings, "/static/")(handler)
+
+	get := func(path, xff string) *http.Response {
+		req := httptest.NewRequest("GET", path, nil)
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	for i := 0; i < settings.Burst; i++ {
+		if got := get("/net/http", "1.2.3.4").StatusCode; got != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want %d", i, got, http.StatusOK)
+		}
+	}
+	// A client-supplied first hop does not change the key.
+	resp := get("/net/http", "5.6.7.8, 1.2.3.4")
+	if got, want := resp.StatusCode, http.StatusTooManyRequests; got != want {
+		t.Errorf("over limit: got status %d, want %d", got, want)
+	}
+	if got, want := resp.Header.Get("Retry-After"), "1"; got != want {
+		t.Errorf("over limit: got Retry-After %q, want %q", got, want)
+	}
+	for _, test := range []struct {
+		name, path, xff string
+		want            int
+	}{
+		{"other IP", "/net/http", "9.8.7.6", http.StatusOK},
+		{"spoofed first hop", "/net/http", "1.2.3.4, 9.9.9.9", http.StatusOK},
+		{"static asset", "/static/css/main.css", "1.2.3.4", http.StatusOK},
+		{"no header", "/net/http", "", http.StatusOK},
+		{"no header again", "/net/http", "", http.StatusOK},
+		// Requests without a client IP share a single bucket.
+		{"no header over limit", "/net/http", "", http.StatusTooManyRequests},
+	} {
+		if got := get(test.path, test.xff).StatusCode; got != test.want {
+			t.Errorf("%s: got status %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		hop        int
+		xff        string
+		remoteAddr string
+		want       string
+	}{
+		{"last hop", 1, "1.2.3.4, 5.6.7.8", "", "5.6.7.0"},
+		{"second to last hop", 2, "1.2.3.4, 5.6.7.8, 10.0.0.1", "", "5.6.7.0"},
+		{"too few hops", 3, "1.2.3.4, 5.6.7.8", "", unknownClientKey},
+		{"no header", 1, "", "", unknownClientKey},
+		{"remote addr", 0, "1.2.3.4", "5.6.7.8:1234", "5.6.7.0"},
+		{"bad remote addr", 0, "", "nonsense", unknownClientKey},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.xff != "" {
+				req.Header.Set("X-Forwarded-For", test.xff)
+			}
+			if got := rateLimitKey(req, test.hop); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIPLimiterRefill(t *testing.T) {
+	l := newIPLimiter(config.RateLimitSettings{QPS: 2, Burst: 1, MaxEntries: 1})
+	now := time.Now()
+	if ok, _ := l.allow("a", now); !ok {
+		t.Fatal("first request: got disallowed")
+	}
+	ok, retryAfter := l.allow("a", now)
+	if ok {
+		t.Fatal("second request: got allowed")
+	}
+	if want := 500 * time.Millisecond; retryAfter != want {
+		t.Errorf("got retryAfter %s, want %s", retryAfter, want)
+	}
+	if ok, _ := l.allow("a", now.Add(retryAfter)); !ok {
+		t.Error("after refill: got disallowed")
+	}
+	// A new key beyond MaxEntries evicts the old one rather than growing.
+	if ok, _ := l.allow("b", now.Add(retryAfter)); !ok {
+		t.Error("new key: got disallowed")
+	}
+	if got := len(l.buckets); got != 1 {
+		t.Errorf("got %d buckets, want 1", got)
+	}
+}
+
+func TestIPLimiterEvictsLeastRecentlyUsed(t *testing.T) {
+	l := newIPLimiter(config.RateLimitSettings{QPS: 1, Burst: 1, MaxEntries: 2})
+	now := time.Now()
+	l.allow("a", now)
+	l.allow("b", now)
+	l.allow("a", now) // a is now more recently used than b
+	l.allow("c", now) // evicts b
+	if _, ok := l.buckets["b"]; ok {
+		t.Error("b was not evicted")
+	}
+	// a keeps its (empty) bucket.
+	if ok, _ := l.allow("a", now); ok {
+		t.Error("a: got allowed, want its bucket kept")
+	}
+}
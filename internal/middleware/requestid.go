@@ -0,0 +1,46 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// validRequestID matches the request IDs that are accepted from clients. It
+// keeps arbitrary client data out of the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID returns a middleware that gives each request an ID. If
+// trustHeader is true and the request has a valid log.RequestIDHeader, its
+// value is the ID; otherwise a random ID is generated. The ID is added to the
+// request context, so that it appears in all log lines for the request and is
+// propagated by log.RequestIDTransport. It is also set on the response.
+func RequestID(trustHeader bool) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(log.RequestIDHeader)
+			if !trustHeader || !validRequestID.MatchString(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(log.RequestIDHeader, id)
+			h.ServeHTTP(w, r.WithContext(log.NewContextWithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on the platforms we run on.
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
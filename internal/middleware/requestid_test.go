@@ -0,0 +1,92 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+func TestRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	stdlog.SetOutput(&logs)
+	defer stdlog.SetOutput(os.Stderr)
+
+	// The outbound server records the request ID it receives.
+	var gotOutbound string
+	outbound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotOutbound = r.Header.Get(log.RequestIDHeader)
+	}))
+	defer outbound.Close()
+	client := &http.Client{Transport: &log.RequestIDTransport{}}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		log.Infof(r.Context(), "serving %s", r.URL.Path)
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, outbound.URL, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		res.Body.Close()
+	}
+
+	for _, test := range []struct {
+		name        string
+		trustHeader bool
+		header      string
+		wantID      bool // whether the ID in the header is used
+	}{
+		{"trusted", true, "abc-123", true},
+		{"untrusted", false, "abc-123", false},
+		{"invalid", true, "abc 123", false},
+		{"missing", true, "", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			logs.Reset()
+			gotOutbound = ""
+			ts := httptest.NewServer(RequestID(test.trustHeader)(http.HandlerFunc(handler)))
+			defer ts.Close()
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL+"/p", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.header != "" {
+				req.Header.Set(log.RequestIDHeader, test.header)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			id := res.Header.Get(log.RequestIDHeader)
+			if id == "" {
+				t.Fatal("no request ID in response")
+			}
+			if got := id == test.header; got != test.wantID {
+				t.Errorf("got request ID %q, header was %q; want header used: %t", id, test.header, test.wantID)
+			}
+			if !strings.Contains(logs.String(), "requestID:"+id) {
+				t.Errorf("request ID %q not in logs:\n%s", id, logs.String())
+			}
+			if gotOutbound != id {
+				t.Errorf("outbound request ID: got %q, want %q", gotOutbound, id)
+			}
+		})
+	}
+}
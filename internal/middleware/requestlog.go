@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"cloud.google.com/go/logging"
+	"golang.org/x/pkgsite/internal/dcensus"
+	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
 )
 
@@ -43,17 +45,24 @@ func (l LocalLogger) Log(entry logging.Entry) {
 // which logged PII when behind IAP, in such a way that was impossible to turn
 // off.
 //
+// The log entry written after the request has been served also records the
+// response size, the client IP, the route if the request was served by a
+// dcensus.Router, and the experiments set for the request by the Experiment
+// middleware. The client IP is found in the same way as for RateLimit, using
+// clientIPHop.
+//
 // Logs may be viewed in Pantheon by selecting the log source corresponding to
 // the AppEngine service name (e.g. 'dev-worker').
-func RequestLog(lg Logger) Middleware {
+func RequestLog(lg Logger, clientIPHop int) Middleware {
 	return func(h http.Handler) http.Handler {
-		return &handler{delegate: h, logger: lg}
+		return &handler{delegate: h, logger: lg, clientIPHop: clientIPHop}
 	}
 }
 
 type handler struct {
-	delegate http.Handler
-	logger   Logger
+	delegate    http.Handler
+	logger      Logger
+	clientIPHop int
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -71,8 +80,11 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Severity: severity,
 		Trace:    traceID,
 	})
+	ctx, route := dcensus.WithRouteRecorder(log.NewContextWithTraceID(r.Context(), traceID))
+	var exps []string
+	ctx = context.WithValue(ctx, experimentsRecorderKey{}, &exps)
 	w2 := &responseWriter{ResponseWriter: w}
-	h.delegate.ServeHTTP(w2, r.WithContext(log.NewContextWithTraceID(r.Context(), traceID)))
+	h.delegate.ServeHTTP(w2, r.WithContext(ctx))
 	s := severity
 	if w2.status == http.StatusServiceUnavailable {
 		// load shedding is a warning, not an error
@@ -82,19 +94,35 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	h.logger.Log(logging.Entry{
 		HTTPRequest: &logging.HTTPRequest{
-			Request: r,
-			Status:  translateStatus(w2.status),
-			Latency: time.Since(start),
+			Request:      r,
+			Status:       translateStatus(w2.status),
+			ResponseSize: w2.bytes,
+			Latency:      time.Since(start),
+			RemoteIP:     clientIP(r, h.clientIPHop),
 		},
 		Payload: map[string]interface{}{
 			"requestType": "request end",
 			"isRobot":     isRobot(r.Header.Get("User-Agent")),
+			"route":       route(),
+			"experiments": strings.Join(exps, ","),
 		},
 		Severity: s,
 		Trace:    traceID,
 	})
 }
 
+// experimentsRecorderKey is the context key for the *[]string in which the
+// Experiment middleware records the experiments of a request for RequestLog.
+type experimentsRecorderKey struct{}
+
+// recordExperiments records the experiments in the context of r for the
+// RequestLog middleware, if it is logging r.
+func recordExperiments(r *http.Request) {
+	if p, ok := r.Context().Value(experimentsRecorderKey{}).(*[]string); ok {
+		*p = experiment.FromContext(r.Context()).Active()
+	}
+}
+
 var browserAgentPrefixes = []string{
 	"MobileSafari/",
 	"Mozilla/",
@@ -118,6 +146,7 @@ type responseWriter struct {
 	http.ResponseWriter
 
 	status int
+	bytes  int64
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -125,6 +154,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
 func translateStatus(code int) int {
 	if code == 0 {
 		return http.StatusOK
@@ -5,12 +5,17 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/logging"
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/dcensus"
 )
 
 func TestRequestLog(t *testing.T) {
@@ -36,7 +41,7 @@ func TestRequestLog(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.label, func(t *testing.T) {
 			lg := fakeLog{}
-			mw := RequestLog(&lg)
+			mw := RequestLog(&lg, 0)
 			ts := httptest.NewServer(mw(test.handler))
 			defer ts.Close()
 			resp, err := ts.Client().Get(ts.URL)
@@ -61,6 +66,59 @@ func (l *fakeLog) Log(entry logging.Entry) {
 	}
 }
 
+func TestRequestLogEndEntry(t *testing.T) {
+	ctx := context.Background()
+	experimenter, err := NewExperimenter(ctx, time.Hour, func(context.Context) ([]*internal.Experiment, error) {
+		return []*internal.Experiment{{Name: "teapot-feature", PathPrefixes: []string{"teapot"}}}, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := dcensus.NewRouter(nil)
+	router.HandleFunc("/teapot/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "short and stout")
+	})
+	var lg entryLog
+	h := Chain(RequestLog(&lg, 1), Experiment(experimenter))(router)
+
+	r := httptest.NewRequest(http.MethodGet, "/teapot/brew", nil)
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(lg.entries) != 2 {
+		t.Fatalf("got %d log entries, want 2", len(lg.entries))
+	}
+	end := lg.entries[1]
+	gotReq := *end.HTTPRequest
+	gotReq.Request = nil
+	gotReq.Latency = 0
+	wantReq := logging.HTTPRequest{
+		Status:       http.StatusTeapot,
+		ResponseSize: int64(len("short and stout")),
+		RemoteIP:     "5.6.7.8", // the entry appended by the load balancer
+	}
+	if diff := cmp.Diff(wantReq, gotReq); diff != "" {
+		t.Errorf("HTTPRequest mismatch (-want +got):\n%s", diff)
+	}
+	payload := end.Payload.(map[string]interface{})
+	if got, want := payload["route"], "teapot"; got != want {
+		t.Errorf("route = %v, want %q", got, want)
+	}
+	if got, want := payload["experiments"], "teapot-feature"; got != want {
+		t.Errorf("experiments = %v, want %q", got, want)
+	}
+}
+
+// entryLog is a Logger that remembers the entries it logs.
+type entryLog struct {
+	entries []logging.Entry
+}
+
+func (l *entryLog) Log(entry logging.Entry) {
+	l.entries = append(l.entries, entry)
+}
+
 func TestIsRobot(t *testing.T) {
 	for _, test := range []string{
 		"AHC/2.1",
@@ -5,16 +5,29 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
+
+	"golang.org/x/pkgsite/internal/log"
 )
 
+// cspReportGroup is the name of the Reporting API endpoint group for CSP
+// violation reports.
+const cspReportGroup = "csp-endpoint"
+
+// scriptHashes are the hashes of the inline scripts in the templates, used
+// instead of nonces when CSPSettings.ScriptHashes is set.
 var scriptHashes = []string{
 	// From content/static/html/base.tmpl
 	"'sha256-CgM7SjnSbDyuIteS+D1CQuSnzyKwL0qtXLU6ZW2hB+g='",
 	"'sha256-dwce5DnVX7uk6fdvvNxQyLTH/cJrTMDK6zzrdKwdwcg='",
-	"'sha256-M35cNZ8vPcaBGw5WTgh0Gn7DLsxkvPbdTFN1pELeevM='",
+	"'sha256-pZAyYydkgkzeVfpicPvVKqtgEcqI7g+Eb4AvWhqf1+8='",
 	// From content/static/html/pages/badge.tmpl
 	"'sha256-v9+UvX+P27rKraeTl7uAfOWdLmmQU39RskIoqUrU4wo='",
 	// From content/static/html/pages/fetch.tmpl
@@ -30,11 +43,137 @@ var scriptHashes = []string{
 	"'sha256-hb8VdkRSeBmkNlbshYmBnkYWC/BYHCPiz5s7liRcZNM='",
 }
 
+// CSPNoncePlaceholder stands in for the nonce of the current request in
+// responses that are rendered once and served many times, such as cached
+// pages. See SubstituteCSPNonce.
+const CSPNoncePlaceholder = "__PKGSITE_CSP_NONCE__"
+
+// nonceCacheControl is the Cache-Control value of responses that carry the
+// nonce of their request. The nonce in the body must match the one in the
+// header, so neither shared caches nor revalidation can be allowed to pair a
+// fresh header with a stored body.
+const nonceCacheControl = "private, no-cache"
+
+type cspNonceKey struct{}
+
+// cspNonce is the nonce of a request, stored in its context.
+type cspNonce struct {
+	value string
+	used  int32 // set atomically when the nonce is handed out
+}
+
+// CSPNonce returns the nonce that inline scripts in the response to the
+// request with ctx must carry, or the empty string if there is none.
+// Responses of requests that call CSPNonce are not cacheable, since the
+// nonce changes on every request.
+func CSPNonce(ctx context.Context) string {
+	n, _ := ctx.Value(cspNonceKey{}).(*cspNonce)
+	if n == nil {
+		return ""
+	}
+	atomic.StoreInt32(&n.used, 1)
+	return n.value
+}
+
+// peekCSPNonce is like CSPNonce, but does not make the response
+// uncacheable.
+func peekCSPNonce(ctx context.Context) string {
+	n, _ := ctx.Value(cspNonceKey{}).(*cspNonce)
+	if n == nil {
+		return ""
+	}
+	return n.value
+}
+
+// SubstituteCSPNonce replaces each CSPNoncePlaceholder in b with the nonce
+// of the request with ctx.
+func SubstituteCSPNonce(ctx context.Context, b []byte) []byte {
+	if !bytes.Contains(b, []byte(CSPNoncePlaceholder)) {
+		return b
+	}
+	return bytes.ReplaceAll(b, []byte(CSPNoncePlaceholder), []byte(CSPNonce(ctx)))
+}
+
+// CSPSettings configures the content-security-policy set by
+// SecureHeadersWithSettings.
+type CSPSettings struct {
+	// Enable determines whether a content-security-policy is set at all.
+	Enable bool
+
+	// ScriptHashes selects allowing inline scripts by the fixed list of
+	// their hashes, instead of by a nonce generated for each request. The
+	// list must be updated whenever an inline script changes.
+	ScriptHashes bool
+
+	// ReportURI, if non-empty, is where browsers report violations of the
+	// policy; see CSPReportHandler.
+	ReportURI string
+
+	// ReportOnly selects sending the policy in a
+	// Content-Security-Policy-Report-Only header instead of a
+	// Content-Security-Policy header, so that browsers report violations to
+	// ReportURI without blocking anything. It is useful for trying out a
+	// change to the policy.
+	ReportOnly bool
+
+	// StaticHosts are additional origins, such as a CDN serving static
+	// assets, from which scripts may be loaded.
+	StaticHosts []string
+}
+
 // SecureHeaders adds a content-security-policy and other security-related
 // headers to all responses.
-func SecureHeaders(enableCSP bool) Middleware {
+//
+// If reportURI is non-empty, the policy directs browsers to report
+// violations to it; see CSPReportHandler.
+//
+// staticHosts are additional origins, such as a CDN serving static assets,
+// from which scripts may be loaded.
+func SecureHeaders(enableCSP bool, reportURI string, staticHosts ...string) Middleware {
+	return SecureHeadersWithSettings(CSPSettings{
+		Enable:      enableCSP,
+		ReportURI:   reportURI,
+		StaticHosts: staticHosts,
+	})
+}
+
+// SecureHeadersWithSettings is like SecureHeaders, with the
+// content-security-policy configured by settings.
+//
+// Unless settings.ScriptHashes is set, each request gets a new nonce, which
+// is available to handlers from CSPNonce and must be stamped on every inline
+// script of the response. Responses that use the nonce are made private and
+// uncacheable, and lose any ETag, because a cached body would carry the
+// nonce of an earlier request. Pages that should be cacheable need
+// ScriptHashes.
+func SecureHeadersWithSettings(settings CSPSettings) Middleware {
+	sources := append([]string{}, settings.StaticHosts...)
+	if settings.ScriptHashes {
+		sources = append(sources, scriptHashes...)
+	}
+	scriptSources := strings.Join(sources, " ")
+	reportURI := settings.ReportURI
+	var reportTo string
+	if reportURI != "" {
+		// The Reporting API endpoint group for browsers that support
+		// report-to. Others fall back to report-uri.
+		reportTo = fmt.Sprintf(`{"group":%q,"max_age":10886400,"endpoints":[{"url":%q}]}`, cspReportGroup, reportURI)
+	}
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scriptSrc := scriptSources
+			if settings.Enable && !settings.ScriptHashes {
+				nonce, err := generateNonce()
+				if err != nil {
+					log.Errorf(r.Context(), "generateNonce: %v", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				scriptSrc = strings.TrimSpace(scriptSrc + " 'nonce-" + nonce + "'")
+				n := &cspNonce{value: nonce}
+				r = r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, n))
+				w = &cspNonceWriter{ResponseWriter: w, nonce: n}
+			}
 			csp := []string{
 				// Disallow plugin content: pkg.go.dev does not use it.
 				"object-src 'none'",
@@ -42,11 +181,20 @@ func SecureHeaders(enableCSP bool) Middleware {
 				// locations of scripts loaded from relative URLs. The site doesn’t have
 				// a <base> tag anyway.
 				"base-uri 'none'",
-				fmt.Sprintf("script-src 'unsafe-inline' 'strict-dynamic' https: http: %s",
-					strings.Join(scriptHashes, " ")),
+				fmt.Sprintf("script-src 'unsafe-inline' 'strict-dynamic' https: http: %s", scriptSrc),
+			}
+			if reportURI != "" {
+				csp = append(csp, "report-uri "+reportURI, "report-to "+cspReportGroup)
 			}
-			if enableCSP {
-				w.Header().Set("Content-Security-Policy", strings.Join(csp, "; "))
+			if settings.Enable {
+				header := "Content-Security-Policy"
+				if settings.ReportOnly {
+					header = "Content-Security-Policy-Report-Only"
+				}
+				w.Header().Set(header, strings.Join(csp, "; "))
+				if reportTo != "" {
+					w.Header().Set("Report-To", reportTo)
+				}
 			}
 			// Don't allow frame embedding.
 			w.Header().Set("X-Frame-Options", "deny")
@@ -57,3 +205,39 @@ func SecureHeaders(enableCSP bool) Middleware {
 		})
 	}
 }
+
+// cspNonceWriter is an http.ResponseWriter that makes the response
+// uncacheable when the status is written, if the handler used the nonce.
+type cspNonceWriter struct {
+	http.ResponseWriter
+	nonce       *cspNonce
+	wroteHeader bool
+}
+
+func (w *cspNonceWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if atomic.LoadInt32(&w.nonce.used) != 0 {
+			w.Header().Set("Cache-Control", nonceCacheControl)
+			w.Header().Del("ETag")
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cspNonceWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// generateNonce returns a random, base64-encoded nonce for a
+// content-security-policy.
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
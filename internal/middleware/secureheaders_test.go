@@ -5,15 +5,19 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestSecureHeaders(t *testing.T) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 	enableCSP := true
-	mw := SecureHeaders(enableCSP)
+	mw := SecureHeaders(enableCSP, "")
 	ts := httptest.NewServer(mw(handler))
 	defer ts.Close()
 	resp, err := ts.Client().Get(ts.URL)
@@ -33,3 +37,205 @@ func TestSecureHeaders(t *testing.T) {
 		}
 	}
 }
+
+func TestSecureHeadersStaticHosts(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	const cdn = "https://cdn.example.com"
+	ts := httptest.NewServer(SecureHeaders(true, "", cdn)(handler))
+	defer ts.Close()
+	resp, err := ts.Client().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	csp := resp.Header.Get("Content-Security-Policy")
+	var scriptSrc string
+	for _, d := range strings.Split(csp, ";") {
+		d = strings.TrimSpace(d)
+		if strings.HasPrefix(d, "script-src ") {
+			scriptSrc = d
+		}
+	}
+	if !strings.Contains(scriptSrc, " "+cdn+" ") {
+		t.Errorf("script-src directive %q does not allow %q", scriptSrc, cdn)
+	}
+}
+
+func TestSecureHeadersReportURI(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, test := range []struct {
+		reportURI string
+		want      []string
+	}{
+		{"", nil},
+		{"/csp-report", []string{"report-uri /csp-report", "report-to " + cspReportGroup}},
+	} {
+		w := httptest.NewRecorder()
+		SecureHeaders(true, test.reportURI)(handler).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		var got []string
+		for _, d := range strings.Split(w.Header().Get("Content-Security-Policy"), ";") {
+			d = strings.TrimSpace(d)
+			if strings.HasPrefix(d, "report-") {
+				got = append(got, d)
+			}
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("reportURI %q: report directives mismatch (-want +got):\n%s", test.reportURI, diff)
+		}
+		reportTo := w.Header().Get("Report-To")
+		if (test.reportURI == "") != (reportTo == "") {
+			t.Errorf("reportURI %q: got Report-To header %q", test.reportURI, reportTo)
+		}
+		if test.reportURI != "" && !strings.Contains(reportTo, `"url":"`+test.reportURI+`"`) {
+			t.Errorf("Report-To header %q does not contain %q", reportTo, test.reportURI)
+		}
+	}
+}
+
+func TestSecureHeadersNonce(t *testing.T) {
+	var gotNonce string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotNonce = CSPNonce(r.Context())
+	})
+	h := SecureHeaders(true, "")(handler)
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if gotNonce == "" {
+			t.Fatal("CSPNonce returned empty string")
+		}
+		if seen[gotNonce] {
+			t.Fatalf("nonce %q reused", gotNonce)
+		}
+		seen[gotNonce] = true
+		csp := w.Header().Get("Content-Security-Policy")
+		if want := "'nonce-" + gotNonce + "'"; !strings.Contains(csp, want) {
+			t.Errorf("Content-Security-Policy %q does not contain %q", csp, want)
+		}
+		if strings.Contains(csp, "'sha256-") {
+			t.Errorf("Content-Security-Policy %q contains script hashes", csp)
+		}
+	}
+}
+
+func TestSecureHeadersScriptHashes(t *testing.T) {
+	var gotNonce string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotNonce = CSPNonce(r.Context())
+	})
+	w := httptest.NewRecorder()
+	SecureHeadersWithSettings(CSPSettings{Enable: true, ScriptHashes: true})(handler).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if gotNonce != "" {
+		t.Errorf("CSPNonce = %q, want empty string", gotNonce)
+	}
+	csp := w.Header().Get("Content-Security-Policy")
+	if strings.Contains(csp, "'nonce-") || !strings.Contains(csp, scriptHashes[0]) {
+		t.Errorf("Content-Security-Policy %q: want script hashes and no nonce", csp)
+	}
+}
+
+func TestSecureHeadersReportOnly(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+	SecureHeadersWithSettings(CSPSettings{
+		Enable:     true,
+		ReportURI:  "/csp-report",
+		ReportOnly: true,
+	})(handler).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("got Content-Security-Policy %q, want none", got)
+	}
+	csp := w.Header().Get("Content-Security-Policy-Report-Only")
+	for _, want := range []string{"object-src 'none'", "report-uri /csp-report", "report-to " + cspReportGroup} {
+		if !strings.Contains(csp, want) {
+			t.Errorf("Content-Security-Policy-Report-Only %q does not contain %q", csp, want)
+		}
+	}
+	if w.Header().Get("Report-To") == "" {
+		t.Error("no Report-To header")
+	}
+}
+
+// TestSecureHeadersRevalidation checks that a browser that revalidates a page
+// it has seen never pairs the body of one response with the
+// content-security-policy of another.
+func TestSecureHeadersRevalidation(t *testing.T) {
+	const etag = `"v1"`
+	// page is like a unit page: it has an ETag unless it carries a nonce.
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce := CSPNonce(r.Context())
+		if nonce == "" {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		fmt.Fprintf(w, `<script nonce="%s"></script>`, nonce)
+	})
+	for _, test := range []struct {
+		name             string
+		settings         CSPSettings
+		wantCacheControl string
+		wantRevalidated  bool
+	}{
+		{"nonce", CSPSettings{Enable: true}, "private, no-cache", false},
+		{"script hashes", CSPSettings{Enable: true, ScriptHashes: true}, "public, max-age=31536000, immutable", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := SecureHeadersWithSettings(test.settings)(CacheControl(DefaultCacheControlPolicies()...)(page))
+			get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest("GET", "/example.com/m@v1.2.3/p", nil)
+				if ifNoneMatch != "" {
+					r.Header.Set("If-None-Match", ifNoneMatch)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				return w
+			}
+
+			first := get("")
+			if got := first.Header().Get("Cache-Control"); got != test.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, test.wantCacheControl)
+			}
+			// The browser revalidates with the ETag it has, if any, and
+			// shows its stored body if the page has not changed.
+			second := get(first.Header().Get("ETag"))
+			body := second.Body.String()
+			if second.Code == http.StatusNotModified {
+				body = first.Body.String()
+			}
+			if revalidated := second.Code == http.StatusNotModified; revalidated != test.wantRevalidated {
+				t.Errorf("revalidated = %t, want %t", revalidated, test.wantRevalidated)
+			}
+			csp := second.Header().Get("Content-Security-Policy")
+			if i := strings.Index(body, `nonce="`); i >= 0 {
+				nonce := body[i+len(`nonce="`):]
+				nonce = nonce[:strings.IndexByte(nonce, '"')]
+				if nonce != "" && !strings.Contains(csp, "'nonce-"+nonce+"'") {
+					t.Errorf("shown body has nonce %q, which the policy %q does not allow", nonce, csp)
+				}
+			}
+		})
+	}
+}
+
+func TestSecureHeadersNonceUnused(t *testing.T) {
+	// Responses that don't use the nonce, like static files, keep their
+	// caching headers.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"x"`)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		fmt.Fprint(w, "body")
+	})
+	w := httptest.NewRecorder()
+	SecureHeaders(true, "")(handler).ServeHTTP(w, httptest.NewRequest("GET", "/static/x.js", nil))
+	if got, want := w.Header().Get("Cache-Control"), "public, max-age=3600"; got != want {
+		t.Errorf("Cache-Control = %q, want %q", got, want)
+	}
+	if got := w.Header().Get("ETag"); got == "" {
+		t.Error("ETag was removed")
+	}
+}
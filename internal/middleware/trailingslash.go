@@ -0,0 +1,65 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TrailingSlash redirects GET and HEAD requests whose path ends in a slash,
+// like /net/http/ or /net/http@go1.16/, to the same path without the slash,
+// preserving the query string. The root path is left alone, as are paths
+// that begin with any of exemptPrefixes, such as /static/ or /play/, whose
+// handlers depend on the slash.
+//
+// Paths in subtreeRoots, such as /badge/, are also left alone: they are the
+// subtree patterns of an http.ServeMux, which would redirect the path
+// without the slash right back.
+func TrailingSlash(subtreeRoots []string, exemptPrefixes ...string) Middleware {
+	roots := map[string]bool{}
+	for _, r := range subtreeRoots {
+		roots[r] = true
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if target := trimTrailingSlash(r, roots, exemptPrefixes); target != "" {
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// trimTrailingSlash returns the URL that r should be redirected to, or the
+// empty string if r should be served as is.
+func trimTrailingSlash(r *http.Request, subtreeRoots map[string]bool, exemptPrefixes []string) string {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return ""
+	}
+	p := r.URL.EscapedPath()
+	if p == "/" || !strings.HasSuffix(p, "/") {
+		return ""
+	}
+	// A path like //example.com/ would become a protocol-relative URL,
+	// redirecting to another host.
+	if strings.HasPrefix(p, "//") {
+		return ""
+	}
+	for _, prefix := range exemptPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return ""
+		}
+	}
+	target := strings.TrimRight(p, "/")
+	if target == "" || subtreeRoots[target+"/"] {
+		return ""
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	return target
+}
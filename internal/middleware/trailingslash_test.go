@@ -0,0 +1,102 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrailingSlash(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := TrailingSlash([]string{"/badge/"}, "/play/", "/static/")(handler)
+
+	for _, test := range []struct {
+		method, url    string
+		wantStatusCode int
+		wantLocation   string
+	}{
+		{"GET", "/foo/", http.StatusMovedPermanently, "/foo"},
+		{"GET", "/foo@v1/", http.StatusMovedPermanently, "/foo@v1"},
+		{"GET", "/foo@v1/bar/?tab=doc", http.StatusMovedPermanently, "/foo@v1/bar?tab=doc"},
+		{"HEAD", "/foo//", http.StatusMovedPermanently, "/foo"},
+		{"GET", "/foo", http.StatusOK, ""},
+		{"GET", "/", http.StatusOK, ""},
+		{"GET", "//example.com/", http.StatusOK, ""},
+		{"GET", "/play/", http.StatusOK, ""},
+		{"GET", "/static/css/", http.StatusOK, ""},
+		{"GET", "/badge/", http.StatusOK, ""},
+		{"GET", "/badge/foo/", http.StatusMovedPermanently, "/badge/foo"},
+		{"POST", "/foo/", http.StatusOK, ""},
+	} {
+		t.Run(test.method+" "+test.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(test.method, test.url, nil))
+			resp := w.Result()
+			if got, want := resp.StatusCode, test.wantStatusCode; got != want {
+				t.Errorf("status code = %d, want %d", got, want)
+			}
+			if got, want := resp.Header.Get("Location"), test.wantLocation; got != want {
+				t.Errorf("Location = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTrailingSlashFollowRedirects(t *testing.T) {
+	roots := []string{"/badge/", "/licenses/", "/mod/", "/pkg/", "/fetch/", "/detail-stats/"}
+	mux := http.NewServeMux()
+	for _, root := range roots {
+		root := root
+		mux.HandleFunc(root, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, root)
+		})
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "/")
+	})
+	ts := httptest.NewServer(TrailingSlash(roots)(mux))
+	defer ts.Close()
+
+	for _, test := range []struct {
+		path, wantPath, wantBody string
+	}{
+		{"/badge/", "/badge/", "/badge/"},
+		{"/badge", "/badge/", "/badge/"},
+		{"/badge/foo/", "/badge/foo", "/badge/"},
+		{"/licenses/", "/licenses/", "/licenses/"},
+		{"/licenses", "/licenses/", "/licenses/"},
+		{"/mod/", "/mod/", "/mod/"},
+		{"/pkg/", "/pkg/", "/pkg/"},
+		{"/fetch/", "/fetch/", "/fetch/"},
+		{"/detail-stats/", "/detail-stats/", "/detail-stats/"},
+		{"/foo/", "/foo", "/"},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			// The client gives up after 10 redirects.
+			resp, err := ts.Client().Get(ts.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status code = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if got := resp.Request.URL.Path; got != test.wantPath {
+				t.Errorf("final path = %q, want %q", got, test.wantPath)
+			}
+			if got := string(body); got != test.wantBody {
+				t.Errorf("served by %q, want %q", got, test.wantBody)
+			}
+		})
+	}
+}
@@ -14,6 +14,9 @@ func (m *Module) RemoveNonRedistributableData() {
 	for _, d := range m.Units {
 		d.RemoveNonRedistributableData()
 	}
+	if !m.IsRedistributable {
+		m.Maintainers = nil
+	}
 }
 
 func (u *Unit) RemoveNonRedistributableData() {
@@ -6,6 +6,7 @@ package internal
 
 import (
 	"path"
+	"strings"
 
 	"golang.org/x/mod/module"
 	"golang.org/x/pkgsite/internal/stdlib"
@@ -47,3 +48,23 @@ func CandidateModulePaths(fullPath string) []string {
 	}
 	return r[:len(r)-2]
 }
+
+// IsImportable reports whether the package with the given import path and
+// package name can be imported by code outside of its own source tree. Main
+// packages cannot be imported, and neither can packages with an "internal"
+// path element, except by code rooted at the parent of that element.
+func IsImportable(pkgPath, pkgName string) bool {
+	return pkgName != "main" && !IsInternalPath(pkgPath)
+}
+
+// IsInternalPath reports whether path has an "internal" path element, so that
+// the package at path can only be imported from within the tree rooted at the
+// parent of that element.
+func IsInternalPath(path string) bool {
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
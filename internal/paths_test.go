@@ -46,3 +46,22 @@ func TestCandidateModulePaths(t *testing.T) {
 		}
 	}
 }
+
+func TestIsImportable(t *testing.T) {
+	for _, test := range []struct {
+		path, name string
+		want       bool
+	}{
+		{"example.com/a/b", "b", true},
+		{"example.com/a/internal", "internal", false},
+		{"example.com/a/internal/b", "b", false},
+		{"example.com/a/internalize", "internalize", true},
+		{"example.com/a/cmd/tool", "main", false},
+		{"internal/bytealg", "bytealg", false},
+		{"encoding/json", "json", true},
+	} {
+		if got := IsImportable(test.path, test.name); got != test.want {
+			t.Errorf("IsImportable(%q, %q) = %t, want %t", test.path, test.name, got, test.want)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"regexp"
 
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/middleware"
 )
@@ -32,7 +33,9 @@ func (db *DB) GetNestedModules(ctx context.Context, modulePath string) (_ []*int
 			m.redistributable,
 			m.has_go_mod,
 			m.deprecated_comment,
-			m.source_info
+			m.source_info,
+			m.commit_hash,
+			m.origin
 		FROM
 			modules m
 		WHERE
@@ -81,10 +84,27 @@ func (db *DB) GetImportedBy(ctx context.Context, pkgPath, modulePath string, lim
 	defer derrors.WrapStack(&err, "GetImportedBy(ctx, %q, %q)", pkgPath, modulePath)
 	defer middleware.ElapsedStat(ctx, "GetImportedBy")()
 
+	return db.getImportedBy(ctx, pkgPath, modulePath, "<>", limit)
+}
+
+// GetImportedByWithinModule is like GetImportedBy, but returns only the
+// packages in modulePath that import pkgPath, instead of those outside it.
+// It is meant for internal packages, which can only be imported from within
+// their module.
+func (db *DB) GetImportedByWithinModule(ctx context.Context, pkgPath, modulePath string, limit int) (paths []string, err error) {
+	defer derrors.WrapStack(&err, "GetImportedByWithinModule(ctx, %q, %q)", pkgPath, modulePath)
+	defer middleware.ElapsedStat(ctx, "GetImportedByWithinModule")()
+
+	return db.getImportedBy(ctx, pkgPath, modulePath, "=", limit)
+}
+
+// getImportedBy returns at most limit packages that import pkgPath, whose
+// module path compares to modulePath with the SQL operator op.
+func (db *DB) getImportedBy(ctx context.Context, pkgPath, modulePath, op string, limit int) ([]string, error) {
 	if pkgPath == "" {
 		return nil, fmt.Errorf("pkgPath cannot be empty: %w", derrors.InvalidArgument)
 	}
-	query := `
+	query := fmt.Sprintf(`
 		SELECT
 			DISTINCT from_path
 		FROM
@@ -92,39 +112,84 @@ func (db *DB) GetImportedBy(ctx context.Context, pkgPath, modulePath string, lim
 		WHERE
 			to_path = $1
 		AND
-			from_module_path <> $2
+			from_module_path %s $2
 		ORDER BY
 			from_path
-		LIMIT $3`
+		LIMIT $3`, op)
 
 	return collectStrings(ctx, db.db, query, pkgPath, modulePath, limit)
 }
 
-// GetImportedByCount returns the number of packages that import pkgPath.
+// GetImportedByCount returns the number of packages outside of modulePath
+// that import pkgPath. Unlike GetImportedBy, it has no limit.
 func (db *DB) GetImportedByCount(ctx context.Context, pkgPath, modulePath string) (_ int, err error) {
 	defer derrors.WrapStack(&err, "GetImportedByCount(ctx, %q, %q)", pkgPath, modulePath)
 	defer middleware.ElapsedStat(ctx, "GetImportedByCount")()
 
+	return db.getImportedByCount(ctx, pkgPath, modulePath, "<>")
+}
+
+// GetImportedByCountWithinModule returns the number of packages in
+// modulePath that import pkgPath. See GetImportedByWithinModule.
+func (db *DB) GetImportedByCountWithinModule(ctx context.Context, pkgPath, modulePath string) (_ int, err error) {
+	defer derrors.WrapStack(&err, "GetImportedByCountWithinModule(ctx, %q, %q)", pkgPath, modulePath)
+	defer middleware.ElapsedStat(ctx, "GetImportedByCountWithinModule")()
+
+	return db.getImportedByCount(ctx, pkgPath, modulePath, "=")
+}
+
+// getImportedByCount returns the number of packages that import pkgPath,
+// whose module path compares to modulePath with the SQL operator op.
+func (db *DB) getImportedByCount(ctx context.Context, pkgPath, modulePath, op string) (int, error) {
 	if pkgPath == "" {
 		return 0, fmt.Errorf("pkgPath cannot be empty: %w", derrors.InvalidArgument)
 	}
-	query := `
-		SELECT imported_by_count
+	query := fmt.Sprintf(`
+		SELECT
+			COUNT(DISTINCT from_path)
 		FROM
-			search_documents
+			imports_unique
 		WHERE
-			package_path = $1
-	`
+			to_path = $1
+		AND
+			from_module_path %s $2`, op)
 	var n int
-	err = db.db.QueryRow(ctx, query, pkgPath).Scan(&n)
-	switch err {
-	case sql.ErrNoRows:
-		return 0, nil
-	case nil:
-		return n, nil
-	default:
+	if err := db.db.QueryRow(ctx, query, pkgPath, modulePath).Scan(&n); err != nil {
 		return 0, err
 	}
+	return n, nil
+}
+
+// GetSymbolUsers returns the sorted paths of at most limit packages, in any
+// version, that refer to the exported symbol of the package pkgPath.
+func (db *DB) GetSymbolUsers(ctx context.Context, pkgPath, symbol string, limit int) (paths []string, err error) {
+	defer derrors.WrapStack(&err, "GetSymbolUsers(ctx, %q, %q, %d)", pkgPath, symbol, limit)
+	defer middleware.ElapsedStat(ctx, "GetSymbolUsers")()
+
+	if pkgPath == "" || symbol == "" {
+		return nil, fmt.Errorf("pkgPath and symbol cannot be empty: %w", derrors.InvalidArgument)
+	}
+	query := `
+		SELECT
+			DISTINCT p.path
+		FROM
+			package_symbol_uses s
+		INNER JOIN
+			units u
+		ON
+			u.id = s.unit_id
+		INNER JOIN
+			paths p
+		ON
+			p.id = u.path_id
+		WHERE
+			s.to_path = $1
+		AND
+			s.symbol = $2
+		ORDER BY
+			p.path
+		LIMIT $3`
+	return collectStrings(ctx, db.db, query, pkgPath, symbol, limit)
 }
 
 // GetModuleInfo fetches a module version from the database with the primary key
@@ -140,7 +205,9 @@ func (db *DB) GetModuleInfo(ctx context.Context, modulePath, resolvedVersion str
 			redistributable,
 			has_go_mod,
 			deprecated_comment,
-			source_info
+			source_info,
+			commit_hash,
+			origin
 		FROM
 			modules
 		WHERE
@@ -198,13 +265,18 @@ func scanModuleInfo(scan func(dest ...interface{}) error) (*internal.ModuleInfo,
 		depComment *string
 	)
 	if err := scan(&mi.ModulePath, &mi.Version, &mi.CommitTime,
-		&mi.IsRedistributable, &mi.HasGoMod, &depComment, jsonbScanner{&mi.SourceInfo}); err != nil {
+		&mi.IsRedistributable, &mi.HasGoMod, &depComment, jsonbScanner{&mi.SourceInfo},
+		database.NullIsEmpty(&mi.CommitHash), jsonbScanner{&mi.Origin}); err != nil {
 		return nil, err
 	}
 	if depComment != nil {
 		mi.Deprecated = true
 		mi.DeprecationComment = *depComment
 	}
+	if mi.Origin == nil {
+		// Versions fetched before the origin was recorded.
+		mi.Origin = mi.SourceInfo.InferOrigin(mi.Version)
+	}
 	return &mi, nil
 }
 
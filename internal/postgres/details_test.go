@@ -201,8 +201,10 @@ func TestPostgres_GetModuleInfo(t *testing.T) {
 			if test.wantIndex >= len(test.modules) {
 				t.Fatal("wantIndex too large")
 			}
-			wantVI := &test.modules[test.wantIndex].ModuleInfo
-			if diff := cmp.Diff(wantVI, gotVI, cmpopts.EquateEmpty(), cmp.AllowUnexported(source.Info{})); diff != "" {
+			wantVI := test.modules[test.wantIndex].ModuleInfo
+			// The modules have no recorded origin, so it is inferred.
+			wantVI.Origin = wantVI.SourceInfo.InferOrigin(wantVI.Version)
+			if diff := cmp.Diff(&wantVI, gotVI, cmpopts.EquateEmpty(), cmp.AllowUnexported(source.Info{})); diff != "" {
 				t.Errorf("mismatch (-want +got):\n%s", diff)
 			}
 		})
@@ -284,6 +286,96 @@ func TestGetImportedBy(t *testing.T) {
 	}
 }
 
+func TestGetImportedByCount(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const target = "example.com/target/p"
+	MustInsertModule(ctx, t, testDB, sample.Module("example.com/target", "v1.0.0", "p", "q"))
+	// Packages in other modules that import target.
+	for _, modulePath := range []string{"example.com/a", "example.com/b", "example.com/c"} {
+		m := sample.Module(modulePath, "v1.0.0", "x", "y")
+		for _, pkg := range m.Packages() {
+			pkg.Imports = []string{target}
+		}
+		MustInsertModule(ctx, t, testDB, m)
+	}
+	// A package in a module that does not import target.
+	MustInsertModule(ctx, t, testDB, sample.Module("example.com/d", "v1.0.0", "z"))
+	// A package in target's own module, which is not counted.
+	m := sample.Module("example.com/target", "v1.1.0", "p", "q")
+	for _, pkg := range m.Packages() {
+		if pkg.Path != target {
+			pkg.Imports = []string{target}
+		}
+	}
+	MustInsertModule(ctx, t, testDB, m)
+
+	for _, test := range []struct {
+		path, modulePath string
+		want             int
+	}{
+		{target, "example.com/target", 6},
+		{target, "example.com/a", 5}, // excludes the importers in example.com/a
+		{"example.com/target/q", "example.com/target", 0},
+	} {
+		got, err := testDB.GetImportedByCount(ctx, test.path, test.modulePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("GetImportedByCount(%q, %q) = %d, want %d", test.path, test.modulePath, got, test.want)
+		}
+	}
+}
+
+func TestGetSymbolUsers(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	m := sample.Module("example.com/a", "v1.0.0", "x", "y")
+	for _, pkg := range m.Packages() {
+		pkg.ImportedSymbols = []string{"context.Context", "net/http.Get"}
+	}
+	MustInsertModule(ctx, t, testDB, m)
+	m = sample.Module("example.com/b", "v1.0.0", "z")
+	m.Packages()[0].ImportedSymbols = []string{"net/http.Get", "net/http.Handler"}
+	MustInsertModule(ctx, t, testDB, m)
+	// A later version of example.com/a still refers to context.Context.
+	m = sample.Module("example.com/a", "v1.1.0", "x")
+	m.Packages()[0].ImportedSymbols = []string{"context.Context"}
+	MustInsertModule(ctx, t, testDB, m)
+
+	for _, test := range []struct {
+		pkgPath, symbol string
+		limit           int
+		want            []string
+	}{
+		{"context", "Context", 10, []string{"example.com/a/x", "example.com/a/y"}},
+		{"net/http", "Get", 10, []string{"example.com/a/x", "example.com/a/y", "example.com/b/z"}},
+		{"net/http", "Get", 2, []string{"example.com/a/x", "example.com/a/y"}},
+		{"net/http", "Handler", 10, []string{"example.com/b/z"}},
+		{"net/http", "Client", 10, nil},
+	} {
+		got, err := testDB.GetSymbolUsers(ctx, test.pkgPath, test.symbol, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetSymbolUsers(%q, %q, %d) mismatch (-want +got):\n%s", test.pkgPath, test.symbol, test.limit, diff)
+		}
+	}
+	if _, err := testDB.GetSymbolUsers(ctx, "context", "", 10); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("GetSymbolUsers with empty symbol: got %v, want InvalidArgument", err)
+	}
+}
+
 func TestJSONBScanner(t *testing.T) {
 	t.Parallel()
 	type S struct{ A int }
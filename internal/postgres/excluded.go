@@ -6,6 +6,8 @@ package postgres
 
 import (
 	"context"
+	"fmt"
+	"regexp"
 	"strings"
 
 	"golang.org/x/pkgsite/internal/database"
@@ -13,16 +15,26 @@ import (
 	"golang.org/x/pkgsite/internal/log"
 )
 
+// exclusions are the contents of the excluded_prefixes and excluded_patterns
+// tables. They are the state of DB.expoller, so the patterns are compiled
+// once per poll rather than on every call to IsExcluded.
+type exclusions struct {
+	prefixes []string
+	patterns []*regexp.Regexp
+}
+
 // IsExcluded reports whether the path matches the excluded list.
 // A path matches an entry on the excluded list if it equals the entry, or
 // is a component-wise suffix of the entry.
 // So path "bad/ness" matches entries "bad" and "bad/", but path "badness"
 // matches neither of those.
+// A path also matches the excluded list if one of the excluded patterns
+// matches all of it.
 func (db *DB) IsExcluded(ctx context.Context, path string) (_ bool, err error) {
 	defer derrors.Wrap(&err, "DB.IsExcluded(ctx, %q)", path)
 
-	eps := db.expoller.Current().([]string)
-	for _, prefix := range eps {
+	ex := db.expoller.Current().(*exclusions)
+	for _, prefix := range ex.prefixes {
 		prefixSlash := prefix
 		if !strings.HasSuffix(prefix, "/") {
 			prefixSlash += "/"
@@ -32,6 +44,12 @@ func (db *DB) IsExcluded(ctx context.Context, path string) (_ bool, err error) {
 			return true, nil
 		}
 	}
+	for _, re := range ex.patterns {
+		if re.MatchString(path) {
+			log.Infof(ctx, "path %q matched excluded pattern %q", path, re)
+			return true, nil
+		}
+	}
 	return false, nil
 }
 
@@ -51,6 +69,26 @@ func (db *DB) InsertExcludedPrefix(ctx context.Context, prefix, user, reason str
 	return err
 }
 
+// InsertExcludedPattern inserts pattern into the excluded_patterns table.
+// Paths that pattern matches entirely, like "example.com/a" for the pattern
+// "example\.com/.*", are excluded. A pattern that is not a valid regular
+// expression is an InvalidArgument error.
+//
+// See InsertExcludedPrefix for how to administer exclusions.
+func (db *DB) InsertExcludedPattern(ctx context.Context, pattern, user, reason string) (err error) {
+	defer derrors.Wrap(&err, "DB.InsertExcludedPattern(ctx, %q, %q)", pattern, reason)
+
+	if _, err := compileExcludedPattern(pattern); err != nil {
+		return fmt.Errorf("%v: %w", err, derrors.InvalidArgument)
+	}
+	_, err = db.db.Exec(ctx, "INSERT INTO excluded_patterns (pattern, created_by, reason) VALUES ($1, $2, $3)",
+		pattern, user, reason)
+	if err == nil {
+		db.expoller.Poll(ctx)
+	}
+	return err
+}
+
 // GetExcludedPrefixes reads all the excluded prefixes from the database.
 func (db *DB) GetExcludedPrefixes(ctx context.Context) ([]string, error) {
 	return getExcludedPrefixes(ctx, db.db)
@@ -59,3 +97,33 @@ func (db *DB) GetExcludedPrefixes(ctx context.Context) ([]string, error) {
 func getExcludedPrefixes(ctx context.Context, db *database.DB) ([]string, error) {
 	return collectStrings(ctx, db, `SELECT prefix FROM excluded_prefixes`)
 }
+
+// getExclusions reads the excluded prefixes and patterns from the database,
+// and compiles the patterns. Patterns that do not compile are logged and
+// skipped, so that they do not prevent the other exclusions from applying.
+func getExclusions(ctx context.Context, db *database.DB) (*exclusions, error) {
+	prefixes, err := getExcludedPrefixes(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := collectStrings(ctx, db, `SELECT pattern FROM excluded_patterns`)
+	if err != nil {
+		return nil, err
+	}
+	ex := &exclusions{prefixes: prefixes}
+	for _, p := range patterns {
+		re, err := compileExcludedPattern(p)
+		if err != nil {
+			log.Errorf(ctx, "excluded pattern: %v", err)
+			continue
+		}
+		ex.patterns = append(ex.patterns, re)
+	}
+	return ex, nil
+}
+
+// compileExcludedPattern compiles pattern so that it only matches entire
+// paths.
+func compileExcludedPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
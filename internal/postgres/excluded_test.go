@@ -6,7 +6,10 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"testing"
+
+	"golang.org/x/pkgsite/internal/derrors"
 )
 
 func TestIsExcluded(t *testing.T) {
@@ -22,6 +25,12 @@ func TestIsExcluded(t *testing.T) {
 	if err := testDB.InsertExcludedPrefix(ctx, "badslash/", "someone", "because"); err != nil {
 		t.Fatal(err)
 	}
+	if err := testDB.InsertExcludedPattern(ctx, ".*/internal-tools/.*", "someone", "because"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.InsertExcludedPattern(ctx, "(", "someone", "because"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("InsertExcludedPattern with a bad pattern: got %v, want InvalidArgument", err)
+	}
 	for _, test := range []struct {
 		path string
 		want bool
@@ -34,6 +43,9 @@ func TestIsExcluded(t *testing.T) {
 		{"bad.com/foo", false},
 		{"badslash", false},
 		{"badslash/more", true},
+		{"example.com/internal-tools/x", true},
+		{"example.com/internal-tools", false},
+		{"example.com/internal-toolsx/x", false},
 	} {
 		got, err := testDB.IsExcluded(ctx, test.path)
 		if err != nil {
@@ -43,5 +55,4 @@ func TestIsExcluded(t *testing.T) {
 			t.Errorf("%q: got %t, want %t", test.path, got, test.want)
 		}
 	}
-
 }
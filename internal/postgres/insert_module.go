@@ -108,6 +108,9 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (isLatest bool
 		if err := insertLicenses(ctx, tx, m, moduleID); err != nil {
 			return err
 		}
+		if err := insertRequirements(ctx, tx, m, moduleID); err != nil {
+			return err
+		}
 		if err := db.insertUnits(ctx, tx, m, moduleID, pathToID); err != nil {
 			return err
 		}
@@ -182,17 +185,35 @@ func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ i
 	if err != nil {
 		return 0, err
 	}
+	originJSON, err := json.Marshal(m.Origin)
+	if err != nil {
+		return 0, err
+	}
 	versionType, err := version.ParseType(m.Version)
 	if err != nil {
 		return 0, err
 	}
 	var (
-		moduleID   int
-		depComment *string
+		moduleID        int
+		depComment      *string
+		goVersion       *string
+		commitHash      *string
+		maintainersFile *string
+		maintainers     []string
 	)
 	if m.Deprecated {
 		depComment = &m.DeprecationComment
 	}
+	if m.GoVersion != "" {
+		goVersion = &m.GoVersion
+	}
+	if m.CommitHash != "" {
+		commitHash = &m.CommitHash
+	}
+	if m.Maintainers != nil {
+		maintainersFile = &m.Maintainers.Filepath
+		maintainers = m.Maintainers.Names
+	}
 	err = db.QueryRow(ctx,
 		`INSERT INTO modules(
 			module_path,
@@ -205,13 +226,25 @@ func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ i
 			redistributable,
 			has_go_mod,
 			deprecated_comment,
-			incompatible)
-		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+			incompatible,
+			go_version,
+			maintainers_file,
+			maintainers,
+			go_mod_file,
+			commit_hash,
+			origin)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17)
 		ON CONFLICT
 			(module_path, version)
 		DO UPDATE SET
 			source_info=excluded.source_info,
-			redistributable=excluded.redistributable
+			redistributable=excluded.redistributable,
+			go_version=excluded.go_version,
+			maintainers_file=excluded.maintainers_file,
+			maintainers=excluded.maintainers,
+			go_mod_file=excluded.go_mod_file,
+			commit_hash=excluded.commit_hash,
+			origin=excluded.origin
 		RETURNING id`,
 		m.ModulePath,
 		m.Version,
@@ -224,6 +257,12 @@ func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ i
 		m.HasGoMod,
 		depComment,
 		version.IsIncompatible(m.Version),
+		goVersion,
+		maintainersFile,
+		pq.Array(maintainers),
+		m.GoModFile,
+		commitHash,
+		originJSON,
 	).Scan(&moduleID)
 	if err != nil {
 		return 0, err
@@ -251,7 +290,7 @@ func insertLicenses(ctx context.Context, db *database.DB, m *internal.Module, mo
 		}
 		licenseValues = append(licenseValues, l.FilePath,
 			makeValidUnicode(string(l.Contents)), pq.Array(l.Types), covJSON,
-			moduleID)
+			pq.Array(l.Confidence), moduleID)
 	}
 	if len(licenseValues) > 0 {
 		licenseCols := []string{
@@ -259,6 +298,7 @@ func insertLicenses(ctx context.Context, db *database.DB, m *internal.Module, mo
 			"contents",
 			"types",
 			"coverage",
+			"confidence",
 			"module_id",
 		}
 		return db.BulkUpsert(ctx, "licenses", licenseCols, licenseValues,
@@ -267,6 +307,27 @@ func insertLicenses(ctx context.Context, db *database.DB, m *internal.Module, mo
 	return nil
 }
 
+// insertRequirements replaces the rows of the requirements table for the
+// module with its current requirements.
+func insertRequirements(ctx context.Context, db *database.DB, m *internal.Module, moduleID int) (err error) {
+	ctx, span := trace.StartSpan(ctx, "insertRequirements")
+	defer span.End()
+	defer derrors.WrapStack(&err, "insertRequirements(ctx, %q, %q)", m.ModulePath, m.Version)
+
+	if _, err := db.Exec(ctx, `DELETE FROM requirements WHERE module_id = $1`, moduleID); err != nil {
+		return err
+	}
+	var values []interface{}
+	for _, r := range m.Requirements {
+		values = append(values, moduleID, r.ModulePath, r.Version)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	cols := []string{"module_id", "required_path", "required_version"}
+	return db.BulkUpsert(ctx, "requirements", cols, values, []string{"module_id", "required_path"})
+}
+
 // insertImportsUnique inserts and removes rows from the imports_unique table. It should only
 // be called if the given module's version is the latest.
 func insertImportsUnique(ctx context.Context, tx *database.DB, m *internal.Module) (err error) {
@@ -312,6 +373,7 @@ func (pdb *DB) insertUnits(ctx context.Context, db *database.DB, m *internal.Mod
 	})
 	for _, u := range m.Units {
 		sort.Strings(u.Imports)
+		sort.Strings(u.ImportedSymbols)
 	}
 	var (
 		paths         []string
@@ -319,6 +381,7 @@ func (pdb *DB) insertUnits(ctx context.Context, db *database.DB, m *internal.Mod
 		pathToReadme  = map[string]*internal.Readme{}
 		pathToDocs    = map[string][]*internal.Documentation{}
 		pathToImports = map[string][]string{}
+		pathToSymbols = map[string][]string{}
 		pathIDToPath  = map[int]string{}
 	)
 	for _, u := range m.Units {
@@ -344,6 +407,10 @@ func (pdb *DB) insertUnits(ctx context.Context, db *database.DB, m *internal.Mod
 			return fmt.Errorf("no entry in paths table for %q; should be impossible", u.Path)
 		}
 		pathIDToPath[pathID] = u.Path
+		var supportedBuildContexts []string
+		for _, bc := range u.SupportedBuildContexts {
+			supportedBuildContexts = append(supportedBuildContexts, bc.String())
+		}
 		unitValues = append(unitValues,
 			pathID,
 			moduleID,
@@ -352,6 +419,11 @@ func (pdb *DB) insertUnits(ctx context.Context, db *database.DB, m *internal.Mod
 			pq.Array(licenseTypes),
 			pq.Array(licensePaths),
 			u.IsRedistributable,
+			pq.Array(supportedBuildContexts),
+			u.UsesCgo,
+			pq.Array(u.Files),
+			u.IsImportable,
+			u.Label,
 		)
 		if u.Readme != nil {
 			pathToReadme[u.Path] = u.Readme
@@ -365,6 +437,9 @@ func (pdb *DB) insertUnits(ctx context.Context, db *database.DB, m *internal.Mod
 		if len(u.Imports) > 0 {
 			pathToImports[u.Path] = u.Imports
 		}
+		if len(u.ImportedSymbols) > 0 {
+			pathToSymbols[u.Path] = u.ImportedSymbols
+		}
 		paths = append(paths, u.Path)
 	}
 	pathIDToUnitID, err := insertUnits(ctx, db, unitValues)
@@ -385,6 +460,9 @@ func (pdb *DB) insertUnits(ctx context.Context, db *database.DB, m *internal.Mod
 	if err := insertImports(ctx, db, paths, pathToUnitID, pathToImports); err != nil {
 		return err
 	}
+	if err := insertImportedSymbols(ctx, db, paths, pathToUnitID, pathToSymbols); err != nil {
+		return err
+	}
 
 	// Only update symbols if the version type is release.
 	versionType, err := version.ParseType(m.Version)
@@ -429,6 +507,11 @@ func insertUnits(ctx context.Context, db *database.DB, unitValues []interface{})
 		"license_types",
 		"license_paths",
 		"redistributable",
+		"supported_build_contexts",
+		"uses_cgo",
+		"files",
+		"is_importable",
+		"label",
 	}
 	uniqueUnitCols := []string{"path_id", "module_id"}
 	returningUnitCols := []string{"id", "path_id"}
@@ -554,6 +637,34 @@ func insertImports(ctx context.Context, db *database.DB,
 	return db.BulkUpsert(ctx, "package_imports", importCols, importValues, importCols)
 }
 
+// insertImportedSymbols inserts the symbols of imported packages that each
+// package uses into the package_symbol_uses table. The symbols have the form
+// "<import path>.<symbol>".
+func insertImportedSymbols(ctx context.Context, db *database.DB,
+	paths []string,
+	pathToUnitID map[string]int,
+	pathToSymbols map[string][]string) (err error) {
+	defer derrors.WrapStack(&err, "insertImportedSymbols")
+
+	var values []interface{}
+	for _, pkgPath := range paths {
+		syms, ok := pathToSymbols[pkgPath]
+		if !ok {
+			continue
+		}
+		unitID := pathToUnitID[pkgPath]
+		for _, s := range syms {
+			i := strings.LastIndexByte(s, '.')
+			if i < 0 {
+				return fmt.Errorf("bad imported symbol %q for %q", s, pkgPath)
+			}
+			values = append(values, unitID, s[:i], s[i+1:])
+		}
+	}
+	cols := []string{"unit_id", "to_path", "symbol"}
+	return db.BulkUpsert(ctx, "package_symbol_uses", cols, values, cols)
+}
+
 func insertReadmes(ctx context.Context, db *database.DB,
 	paths []string,
 	pathToUnitID map[string]int,
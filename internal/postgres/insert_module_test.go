@@ -74,6 +74,20 @@ func TestInsertModule(t *testing.T) {
 				return m
 			}(),
 		},
+		{
+			name: "origin",
+			module: func() *internal.Module {
+				m := sample.DefaultModule()
+				m.CommitHash = "d50f0e9b25068a1e5b8c3f6f14e8a0c2b7a5e3f1"
+				m.Origin = &source.Origin{
+					VCS:    "git",
+					URL:    sample.RepositoryURL,
+					Ref:    "refs/tags/" + sample.VersionString,
+					Subdir: "sub",
+				}
+				return m
+			}(),
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			testDB, release := acquire(t)
@@ -92,7 +106,12 @@ func checkModule(ctx context.Context, t *testing.T, db *DB, want *internal.Modul
 	if err != nil {
 		t.Fatal(err)
 	}
-	if diff := cmp.Diff(want.ModuleInfo, *got, cmp.AllowUnexported(source.Info{})); diff != "" {
+	wantInfo := want.ModuleInfo
+	if wantInfo.Origin == nil {
+		// GetModuleInfo infers an origin that was not recorded.
+		wantInfo.Origin = wantInfo.SourceInfo.InferOrigin(wantInfo.Version)
+	}
+	if diff := cmp.Diff(wantInfo, *got, cmp.AllowUnexported(source.Info{})); diff != "" {
 		t.Fatalf("testDB.GetModuleInfo(%q, %q) mismatch (-want +got):\n%s", want.ModulePath, want.Version, diff)
 	}
 
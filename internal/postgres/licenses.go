@@ -16,6 +16,7 @@ import (
 
 	"github.com/google/licensecheck"
 	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/middleware"
@@ -31,7 +32,8 @@ func (db *DB) getLicenses(ctx context.Context, fullPath, modulePath string, unit
 			l.types,
 			l.file_path,
 			l.contents,
-			l.coverage
+			l.coverage,
+			l.confidence
 		FROM
 			licenses l
 		INNER JOIN
@@ -66,7 +68,7 @@ func (db *DB) getLicenses(ctx context.Context, fullPath, modulePath string, unit
 			lics = append(lics, l)
 		} else {
 			licensePath := path.Join(modulePath, path.Dir(l.FilePath))
-			if strings.HasPrefix(fullPath, licensePath) {
+			if fullPath == licensePath || strings.HasPrefix(fullPath, licensePath+"/") {
 				lics = append(lics, l)
 			}
 		}
@@ -87,7 +89,7 @@ func (db *DB) getModuleLicenses(ctx context.Context, moduleID int) (_ []*license
 
 	query := `
 	SELECT
-		types, file_path, contents, coverage
+		types, file_path, contents, coverage, confidence
 	FROM
 		licenses
 	WHERE
@@ -101,10 +103,88 @@ func (db *DB) getModuleLicenses(ctx context.Context, moduleID int) (_ []*license
 	return collectLicenses(rows, db.bypassLicenseCheck)
 }
 
+// GetModulesByLicenseType returns modules whose latest version has a license
+// file of the given type, such as "MIT" or "GPL-3.0", in the order of their
+// paths. Each module appears once, with its latest good version, as recorded
+// in latest_module_versions.
+//
+// At most limit modules are returned, starting after the given cursor, which
+// is either empty for the first page or the nextCursor returned with the
+// previous page. nextCursor is empty if there are no more modules.
+func (db *DB) GetModulesByLicenseType(ctx context.Context, licenseType string, limit int, cursor string) (_ []*internal.ModuleInfo, nextCursor string, err error) {
+	defer derrors.WrapStack(&err, "GetModulesByLicenseType(ctx, %q, %d, %q)", licenseType, limit, cursor)
+	defer middleware.ElapsedStat(ctx, "GetModulesByLicenseType")()
+
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("%w: limit must be positive, got %d", derrors.InvalidArgument, limit)
+	}
+	// One more module than the limit is read, to learn whether there is
+	// another page. The containment operator @>, unlike ANY, can use the
+	// index on licenses.types.
+	query := `
+		SELECT
+			m.module_path,
+			m.version,
+			m.commit_time,
+			m.redistributable,
+			m.has_go_mod,
+			m.deprecated_comment,
+			m.source_info,
+			m.commit_hash,
+			m.origin
+		FROM
+			latest_module_versions r
+		INNER JOIN paths p ON p.id = r.module_path_id
+		INNER JOIN modules m ON m.module_path = p.path AND m.version = r.good_version
+		WHERE
+			r.status = 200
+			AND p.path > $2
+			AND EXISTS (
+				SELECT 1
+				FROM licenses l
+				WHERE l.module_id = m.id AND l.types @> ARRAY[$1]::text[]
+			)
+		ORDER BY
+			p.path
+		LIMIT $3;
+	`
+	var (
+		modules []*internal.ModuleInfo
+		n       int
+		last    string
+	)
+	collect := func(rows *sql.Rows) error {
+		mi, err := scanModuleInfo(rows.Scan)
+		if err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		n++
+		if n > limit {
+			return nil
+		}
+		last = mi.ModulePath
+		isExcluded, err := db.IsExcluded(ctx, mi.ModulePath)
+		if err != nil {
+			return err
+		}
+		if !isExcluded {
+			modules = append(modules, mi)
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, licenseType, cursor, limit+1); err != nil {
+		return nil, "", err
+	}
+	if n > limit {
+		nextCursor = last
+	}
+	return modules, nextCursor, nil
+}
+
 // collectLicenses converts the sql rows to a list of licenses. The columns
-// must be types, file_path and contents, in that order.
+// must be types, file_path, contents, coverage and confidence, in that order.
 func collectLicenses(rows *sql.Rows, bypassLicenseCheck bool) ([]*licenses.License, error) {
-	mustHaveColumns(rows, "types", "file_path", "contents", "coverage")
+	mustHaveColumns(rows, "types", "file_path", "contents", "coverage", "confidence")
 	var lics []*licenses.License
 	for rows.Next() {
 		var (
@@ -112,7 +192,7 @@ func collectLicenses(rows *sql.Rows, bypassLicenseCheck bool) ([]*licenses.Licen
 			licenseTypes []string
 			covBytes     []byte
 		)
-		if err := rows.Scan(pq.Array(&licenseTypes), &lic.FilePath, &lic.Contents, &covBytes); err != nil {
+		if err := rows.Scan(pq.Array(&licenseTypes), &lic.FilePath, &lic.Contents, &covBytes, pq.Array(&lic.Confidence)); err != nil {
 			return nil, fmt.Errorf("row.Scan(): %v", err)
 		}
 		// The coverage column is JSON for either the new or old
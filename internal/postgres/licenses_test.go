@@ -13,6 +13,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/testing/sample"
@@ -232,3 +233,113 @@ func makeModuleNonRedistributable(m *internal.Module) {
 		m.Units[i].IsRedistributable = false
 	}
 }
+
+func TestGetModulesByLicenseType(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	withLicense := func(modulePath, version, licenseType string) *internal.Module {
+		m := sample.Module(modulePath, version, "p")
+		m.Licenses = []*licenses.License{{
+			Metadata: &licenses.Metadata{Types: []string{licenseType}, FilePath: "LICENSE"},
+			Contents: []byte("license"),
+		}}
+		for _, u := range m.Units {
+			u.Licenses = []*licenses.Metadata{m.Licenses[0].Metadata}
+		}
+		return m
+	}
+	for _, m := range []*internal.Module{
+		withLicense("a.com/mit1", "v1.0.0", "MIT"),
+		withLicense("a.com/mit1", "v1.1.0", "MIT"),
+		withLicense("b.com/gpl", "v1.0.0", "GPL-3.0"),
+		withLicense("c.com/mit2", "v0.1.0", "MIT"),
+		withLicense("d.com/mit3", "v1.2.0", "MIT"),
+		// The latest version of this module is no longer MIT-licensed.
+		withLicense("e.com/relicensed", "v1.0.0", "MIT"),
+		withLicense("e.com/relicensed", "v1.1.0", "GPL-3.0"),
+	} {
+		MustInsertModule(ctx, t, testDB, m)
+	}
+	for _, l := range []struct{ module, version string }{
+		{"a.com/mit1", "v1.1.0"},
+		{"b.com/gpl", "v1.0.0"},
+		{"c.com/mit2", "v0.1.0"},
+		{"d.com/mit3", "v1.2.0"},
+		{"e.com/relicensed", "v1.1.0"},
+	} {
+		addLatest(ctx, t, testDB, l.module, l.version, "module "+l.module)
+	}
+
+	type mv struct{ Path, Version string }
+	get := func(licenseType string, limit int, cursor string) ([]mv, string) {
+		t.Helper()
+		mods, next, err := testDB.GetModulesByLicenseType(ctx, licenseType, limit, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []mv
+		for _, m := range mods {
+			got = append(got, mv{m.ModulePath, m.Version})
+		}
+		return got, next
+	}
+
+	for _, test := range []struct {
+		name        string
+		licenseType string
+		limit       int
+		cursor      string
+		want        []mv
+		wantNext    string
+	}{
+		{
+			name:        "MIT",
+			licenseType: "MIT",
+			limit:       10,
+			want:        []mv{{"a.com/mit1", "v1.1.0"}, {"c.com/mit2", "v0.1.0"}, {"d.com/mit3", "v1.2.0"}},
+		},
+		{
+			name:        "GPL",
+			licenseType: "GPL-3.0",
+			limit:       10,
+			want:        []mv{{"b.com/gpl", "v1.0.0"}, {"e.com/relicensed", "v1.1.0"}},
+		},
+		{
+			name:        "first page",
+			licenseType: "MIT",
+			limit:       2,
+			want:        []mv{{"a.com/mit1", "v1.1.0"}, {"c.com/mit2", "v0.1.0"}},
+			wantNext:    "c.com/mit2",
+		},
+		{
+			name:        "second page",
+			licenseType: "MIT",
+			limit:       2,
+			cursor:      "c.com/mit2",
+			want:        []mv{{"d.com/mit3", "v1.2.0"}},
+		},
+		{
+			name:        "unknown",
+			licenseType: "Unknown",
+			limit:       10,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, next := get(test.licenseType, test.limit, test.cursor)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+			if next != test.wantNext {
+				t.Errorf("next cursor = %q, want %q", next, test.wantNext)
+			}
+		})
+	}
+
+	if _, _, err := testDB.GetModulesByLicenseType(ctx, "MIT", 0, ""); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("limit 0: got error %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetModuleMaintainers returns the summary of the maintainers file of the
+// given module version, or nil if the module has none.
+//
+// If the module version is not in the database, GetModuleMaintainers returns
+// an error that wraps derrors.NotFound.
+func (db *DB) GetModuleMaintainers(ctx context.Context, modulePath, resolvedVersion string) (_ *internal.Maintainers, err error) {
+	defer derrors.WrapStack(&err, "GetModuleMaintainers(ctx, %q, %q)", modulePath, resolvedVersion)
+
+	var (
+		file            string
+		names           []string
+		redistributable bool
+	)
+	err = db.db.QueryRow(ctx, `
+		SELECT maintainers_file, maintainers, redistributable
+		FROM modules
+		WHERE module_path = $1 AND version = $2`,
+		modulePath, resolvedVersion).Scan(database.NullIsEmpty(&file), pq.Array(&names), &redistributable)
+	if err == sql.ErrNoRows {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file == "" || (!redistributable && !db.bypassLicenseCheck) {
+		return nil, nil
+	}
+	return &internal.Maintainers{Filepath: file, Names: names}, nil
+}
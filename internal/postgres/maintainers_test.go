@@ -0,0 +1,59 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetModuleMaintainers(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	maintainers := &internal.Maintainers{
+		Filepath: "AUTHORS",
+		Names:    []string{"Jane Doe", "@bob"},
+	}
+	m := sample.Module("example.com/m", "v1.0.0", "")
+	m.Maintainers = maintainers
+	nonRedist := sample.Module("example.com/nonredist", "v1.0.0", "")
+	nonRedist.IsRedistributable = false
+	nonRedist.Maintainers = maintainers
+	none := sample.Module("example.com/none", "v1.0.0", "")
+	for _, mod := range []*internal.Module{m, nonRedist, none} {
+		MustInsertModule(ctx, t, testDB, mod)
+	}
+
+	for _, test := range []struct {
+		modulePath string
+		want       *internal.Maintainers
+	}{
+		{"example.com/m", maintainers},
+		{"example.com/nonredist", nil},
+		{"example.com/none", nil},
+	} {
+		got, err := testDB.GetModuleMaintainers(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+
+	if _, err := testDB.GetModuleMaintainers(ctx, "example.com/unknown", "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("unknown module: got error %v, want NotFound", err)
+	}
+}
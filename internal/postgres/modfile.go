@@ -0,0 +1,37 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetModFile returns the raw contents of the go.mod file of the given module
+// version. If the module version is not in the database, or no go.mod file
+// was stored for it, GetModFile returns an error that wraps
+// derrors.NotFound.
+func (db *DB) GetModFile(ctx context.Context, modulePath, resolvedVersion string) (_ []byte, err error) {
+	defer derrors.WrapStack(&err, "GetModFile(ctx, %q, %q)", modulePath, resolvedVersion)
+
+	var contents []byte
+	err = db.db.QueryRow(ctx, `
+		SELECT go_mod_file
+		FROM modules
+		WHERE module_path = $1 AND version = $2`,
+		modulePath, resolvedVersion).Scan(&contents)
+	if err == sql.ErrNoRows {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if contents == nil {
+		return nil, derrors.NotFound
+	}
+	return contents, nil
+}
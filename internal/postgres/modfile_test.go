@@ -0,0 +1,49 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetModFile(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const goMod = "module example.com/m\n\ngo 1.16\n"
+	m := sample.Module("example.com/m", "v1.0.0", "")
+	m.GoModFile = []byte(goMod)
+	MustInsertModule(ctx, t, testDB, m)
+	noMod := sample.Module("example.com/nomod", "v1.0.0", "")
+	MustInsertModule(ctx, t, testDB, noMod)
+
+	got, err := testDB.GetModFile(ctx, "example.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(goMod, string(got)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, test := range []struct {
+		modulePath, version string
+	}{
+		{"example.com/m", "v1.1.0"},
+		{"example.com/nomod", "v1.0.0"},
+	} {
+		if _, err := testDB.GetModFile(ctx, test.modulePath, test.version); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("GetModFile(%q, %q): got %v, want NotFound", test.modulePath, test.version, err)
+		}
+	}
+}
@@ -41,12 +41,12 @@ var startPoller = true
 
 func newdb(db *database.DB, bypass bool) *DB {
 	p := poller.New(
-		[]string(nil),
+		&exclusions{},
 		func(ctx context.Context) (interface{}, error) {
-			return getExcludedPrefixes(ctx, db)
+			return getExclusions(ctx, db)
 		},
 		func(err error) {
-			log.Errorf(context.Background(), "getting excluded prefixes: %v", err)
+			log.Errorf(context.Background(), "getting exclusions: %v", err)
 		})
 	ctx, cancel := context.WithCancel(context.Background())
 	if startPoller {
@@ -0,0 +1,59 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"path"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetPathRedirect returns the path that fullPath is served under, according
+// to the path_redirects table, or the empty string if there is none. If
+// several entries of the table are component-wise prefixes of fullPath, the
+// longest one is used.
+func (db *DB) GetPathRedirect(ctx context.Context, fullPath string) (_ string, err error) {
+	defer derrors.WrapStack(&err, "DB.GetPathRedirect(ctx, %q)", fullPath)
+
+	candidates := internal.CandidateModulePaths(fullPath)
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	var from, to string
+	err = db.db.QueryRow(ctx, `
+		SELECT from_path, to_path
+		FROM path_redirects
+		WHERE from_path = ANY($1)
+		ORDER BY length(from_path) DESC
+		LIMIT 1`, pq.Array(candidates)).Scan(&from, &to)
+	switch err {
+	case nil:
+		return path.Join(to, internal.Suffix(fullPath, from)), nil
+	case sql.ErrNoRows:
+		return "", nil
+	default:
+		return "", err
+	}
+}
+
+// InsertPathRedirect records that fromPath and the paths below it are served
+// under toPath. It replaces any existing redirect for fromPath.
+func (db *DB) InsertPathRedirect(ctx context.Context, fromPath, toPath string) (err error) {
+	defer derrors.WrapStack(&err, "DB.InsertPathRedirect(ctx, %q, %q)", fromPath, toPath)
+
+	if fromPath == "" || toPath == "" || fromPath == toPath {
+		return derrors.InvalidArgument
+	}
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO path_redirects (from_path, to_path)
+		VALUES ($1, $2)
+		ON CONFLICT (from_path) DO UPDATE SET to_path = excluded.to_path`,
+		fromPath, toPath)
+	return err
+}
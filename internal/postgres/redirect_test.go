@@ -0,0 +1,44 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetPathRedirect(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for from, to := range map[string]string{
+		"vanity.example.com/mod":     "github.com/owner/mod",
+		"vanity.example.com/mod/sub": "github.com/owner/sub",
+	} {
+		if err := testDB.InsertPathRedirect(ctx, from, to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		path, want string
+	}{
+		{"vanity.example.com/mod", "github.com/owner/mod"},
+		{"vanity.example.com/mod/pkg", "github.com/owner/mod/pkg"},
+		{"vanity.example.com/mod/sub/pkg", "github.com/owner/sub/pkg"},
+		{"vanity.example.com/module", ""},
+		{"other.example.com/mod", ""},
+	} {
+		got, err := testDB.GetPathRedirect(ctx, test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("GetPathRedirect(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
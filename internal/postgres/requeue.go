@@ -88,6 +88,25 @@ func (db *DB) UpdateModuleVersionStatesWithStatus(ctx context.Context, status in
 	return nil
 }
 
+// GetStaleModuleVersions returns the module versions that were last processed
+// by an app version earlier than appVersion, ordered by module path and
+// version. Module versions that have never been processed are not included.
+// At most limit module versions are returned.
+func (db *DB) GetStaleModuleVersions(ctx context.Context, appVersion string, limit int) (_ []*internal.ModuleVersionState, err error) {
+	defer derrors.WrapStack(&err, "GetStaleModuleVersions(ctx, %q, %d)", appVersion, limit)
+
+	queryFormat := `
+		SELECT %s
+		FROM
+			module_version_states
+		WHERE
+			app_version <> ''
+			AND app_version < $1
+		ORDER BY module_path, sort_version
+		LIMIT $2`
+	return db.queryModuleVersionStates(ctx, queryFormat, appVersion, limit)
+}
+
 // largeModulePackageThresold represents the package threshold at which it
 // becomes difficult to process packages. Modules with more than this number
 // of packages are generally different versions or forks of kubernetes,
@@ -281,3 +281,52 @@ func compareModules(t *testing.T, got, want []*internal.ModuleVersionState) {
 		t.Fatalf("mismatch (-want, +got):\n%s", diff)
 	}
 }
+
+func TestGetStaleModuleVersions(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, mvs := range []*ModuleVersionStateForUpsert{
+		{ModulePath: "a.com/m", Version: "v1.0.0", AppVersion: "20210101t000000", Status: http.StatusOK},
+		{ModulePath: "a.com/m", Version: "v1.1.0", AppVersion: "20210301t000000", Status: http.StatusOK},
+		{ModulePath: "b.com/m", Version: "v0.1.0", AppVersion: "20210201t000000", Status: http.StatusNotFound},
+		{ModulePath: "c.com/m", Version: "v2.0.0+incompatible", AppVersion: "20210102t000000", Status: http.StatusOK},
+	} {
+		mvs.Timestamp = time.Now()
+		if err := testDB.UpsertModuleVersionState(ctx, mvs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A module version that has not been processed is not stale.
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{
+		{Path: "d.com/m", Version: "v1.0.0", Timestamp: time.Now()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		before string
+		limit  int
+		want   []string
+	}{
+		{"20210101t000000", 10, nil},
+		{"20210201t000000", 10, []string{"a.com/m@v1.0.0", "c.com/m@v2.0.0+incompatible"}},
+		{"20210401t000000", 10, []string{"a.com/m@v1.0.0", "a.com/m@v1.1.0", "b.com/m@v0.1.0", "c.com/m@v2.0.0+incompatible"}},
+		{"20210401t000000", 2, []string{"a.com/m@v1.0.0", "a.com/m@v1.1.0"}},
+	} {
+		mvss, err := testDB.GetStaleModuleVersions(ctx, test.before, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, mvs := range mvss {
+			got = append(got, mvs.ModulePath+"@"+mvs.Version)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetStaleModuleVersions(%q, %d) mismatch (-want, +got):\n%s", test.before, test.limit, diff)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/stdlib"
+)
+
+// GetRequirements returns the direct requirements listed in the go.mod file
+// of the given module version, sorted by module path. Requirements marked
+// "// indirect" are not stored, so they are not returned.
+//
+// If the module version is not in the database, GetRequirements returns an
+// error that wraps derrors.NotFound.
+func (db *DB) GetRequirements(ctx context.Context, modulePath, resolvedVersion string) (_ []*internal.Requirement, err error) {
+	defer derrors.WrapStack(&err, "GetRequirements(ctx, %q, %q)", modulePath, resolvedVersion)
+
+	var moduleID int
+	err = db.db.QueryRow(ctx, `
+		SELECT id
+		FROM modules
+		WHERE module_path = $1 AND version = $2`,
+		modulePath, resolvedVersion).Scan(&moduleID)
+	if err == sql.ErrNoRows {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reqs []*internal.Requirement
+	collect := func(rows *sql.Rows) error {
+		var r internal.Requirement
+		if err := rows.Scan(&r.ModulePath, &r.Version); err != nil {
+			return err
+		}
+		reqs = append(reqs, &r)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, `
+		SELECT required_path, required_version
+		FROM requirements
+		WHERE module_id = $1
+		ORDER BY required_path`, collect, moduleID); err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// GetImpliedGoVersion returns the minimum Go version implied by the go
+// directive of the given module version and the go directives of its direct
+// dependencies. Dependencies that have not been processed are listed in the
+// Unindexed field of the result.
+//
+// If the module version is not in the database, GetImpliedGoVersion returns
+// an error that wraps derrors.NotFound.
+func (db *DB) GetImpliedGoVersion(ctx context.Context, modulePath, resolvedVersion string) (_ *internal.ImpliedGoVersion, err error) {
+	defer derrors.WrapStack(&err, "GetImpliedGoVersion(ctx, %q, %q)", modulePath, resolvedVersion)
+
+	var (
+		moduleID  int
+		goVersion string
+	)
+	err = db.db.QueryRow(ctx, `
+		SELECT id, go_version
+		FROM modules
+		WHERE module_path = $1 AND version = $2`,
+		modulePath, resolvedVersion).Scan(&moduleID, database.NullIsEmpty(&goVersion))
+	if err == sql.ErrNoRows {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	igv := &internal.ImpliedGoVersion{GoVersion: goVersion, MinGoVersion: goVersion}
+	query := `
+		SELECT
+			r.required_path,
+			r.required_version,
+			d.id IS NOT NULL,
+			d.go_version
+		FROM requirements r
+		LEFT JOIN modules d
+		ON d.module_path = r.required_path AND d.version = r.required_version
+		WHERE r.module_id = $1
+		ORDER BY r.required_path`
+	collect := func(rows *sql.Rows) error {
+		var (
+			req     internal.Requirement
+			indexed bool
+			depGo   string
+		)
+		if err := rows.Scan(&req.ModulePath, &req.Version, &indexed, database.NullIsEmpty(&depGo)); err != nil {
+			return err
+		}
+		if !indexed {
+			igv.Unindexed = append(igv.Unindexed, &req)
+			return nil
+		}
+		if compareGoVersions(depGo, igv.MinGoVersion) > 0 {
+			igv.MinGoVersion = depGo
+			igv.RequiredBy = req.ModulePath
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, moduleID); err != nil {
+		return nil, err
+	}
+	return igv, nil
+}
+
+// compareGoVersions compares two versions from go directives, like "1.16",
+// and returns -1, 0 or 1, as semver.Compare does. An empty or invalid version
+// is considered less than any valid one.
+func compareGoVersions(v, w string) int {
+	return semver.Compare(stdlib.VersionForTag("go"+v), stdlib.VersionForTag("go"+w))
+}
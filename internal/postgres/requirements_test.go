@@ -0,0 +1,132 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetImpliedGoVersion(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	dep := sample.Module("example.com/dep", "v1.2.0", "")
+	dep.GoVersion = "1.16"
+	old := sample.Module("example.com/old", "v1.0.0", "")
+	old.GoVersion = "1.9"
+	m := sample.Module("example.com/m", "v1.0.0", "")
+	m.GoVersion = "1.13"
+	m.Requirements = []*internal.Requirement{
+		{ModulePath: "example.com/dep", Version: "v1.2.0"},
+		{ModulePath: "example.com/old", Version: "v1.0.0"},
+		{ModulePath: "example.com/unindexed", Version: "v0.1.0"},
+	}
+	for _, mod := range []*internal.Module{dep, old, m} {
+		MustInsertModule(ctx, t, testDB, mod)
+	}
+
+	for _, test := range []struct {
+		modulePath string
+		want       *internal.ImpliedGoVersion
+	}{
+		{
+			modulePath: "example.com/m",
+			want: &internal.ImpliedGoVersion{
+				GoVersion:    "1.13",
+				MinGoVersion: "1.16",
+				RequiredBy:   "example.com/dep",
+				Unindexed:    []*internal.Requirement{{ModulePath: "example.com/unindexed", Version: "v0.1.0"}},
+			},
+		},
+		{
+			modulePath: "example.com/old",
+			want: &internal.ImpliedGoVersion{
+				GoVersion:    "1.9",
+				MinGoVersion: "1.9",
+			},
+		},
+	} {
+		t.Run(test.modulePath, func(t *testing.T) {
+			got, err := testDB.GetImpliedGoVersion(ctx, test.modulePath, "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := testDB.GetImpliedGoVersion(ctx, "example.com/m", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want NotFound", err)
+	}
+}
+
+func TestGetRequirements(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	m := sample.Module("example.com/m", "v1.0.0", "")
+	m.Requirements = []*internal.Requirement{
+		{ModulePath: "example.com/b", Version: "v0.1.0"},
+		{ModulePath: "example.com/a", Version: "v1.2.3"},
+	}
+	MustInsertModule(ctx, t, testDB, m)
+	MustInsertModule(ctx, t, testDB, sample.Module("example.com/none", "v1.0.0", ""))
+
+	got, err := testDB.GetRequirements(ctx, "example.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.Requirement{
+		{ModulePath: "example.com/a", Version: "v1.2.3"},
+		{ModulePath: "example.com/b", Version: "v0.1.0"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = testDB.GetRequirements(ctx, "example.com/none", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d requirements, want none", len(got))
+	}
+
+	if _, err := testDB.GetRequirements(ctx, "example.com/m", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want NotFound", err)
+	}
+}
+
+func TestCompareGoVersions(t *testing.T) {
+	for _, test := range []struct {
+		v, w string
+		want int
+	}{
+		{"1.16", "1.9", 1},
+		{"1.9", "1.16", -1},
+		{"1.16", "1.16", 0},
+		{"", "1.11", -1},
+		{"1.11", "", 1},
+	} {
+		if got := compareGoVersions(test.v, test.w); got != test.want {
+			t.Errorf("compareGoVersions(%q, %q) = %d, want %d", test.v, test.w, got, test.want)
+		}
+	}
+}
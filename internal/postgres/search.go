@@ -404,6 +404,7 @@ var upsertSearchStatement = fmt.Sprintf(`
 		commit_time,
 		has_go_mod,
 		tsv_search_tokens,
+		tsv_doc_tokens,
 		hll_register,
 		hll_leading_zeros
 	)
@@ -424,6 +425,11 @@ var upsertSearchStatement = fmt.Sprintf(`
 			SETWEIGHT(TO_TSVECTOR($6), 'C') ||
 			SETWEIGHT(TO_TSVECTOR($7), 'D')
 		),
+		(
+			SETWEIGHT(TO_TSVECTOR($5), 'B') ||
+			SETWEIGHT(TO_TSVECTOR($6), 'C') ||
+			SETWEIGHT(TO_TSVECTOR($7), 'D')
+		),
 		hll_hash(p.path) & (%d - 1),
 		hll_zeros(hll_hash(p.path))
 	FROM
@@ -459,6 +465,7 @@ var upsertSearchStatement = fmt.Sprintf(`
 		commit_time=excluded.commit_time,
 		has_go_mod=excluded.has_go_mod,
 		tsv_search_tokens=excluded.tsv_search_tokens,
+		tsv_doc_tokens=excluded.tsv_doc_tokens,
 		-- the hll fields are functions of path, so they don't change
 		version_updated_at=(
 			CASE WHEN excluded.version = search_documents.version
@@ -477,6 +484,16 @@ func upsertSearchDocuments(ctx context.Context, ddb *database.DB, mod *internal.
 		if isInternalPackage(pkg.Path) {
 			continue
 		}
+		if pkg.ExcludeFromSearch {
+			// An earlier version of the package may have been added.
+			if _, err := ddb.Exec(ctx, `
+				DELETE FROM search_documents
+				WHERE package_path = $1 AND module_path = $2`,
+				pkg.Path, mod.ModulePath); err != nil {
+				return err
+			}
+			continue
+		}
 		args := UpsertSearchDocumentArgs{
 			PackagePath: pkg.Path,
 			ModulePath:  mod.ModulePath,
@@ -528,6 +545,12 @@ func UpsertSearchDocument(ctx context.Context, ddb *database.DB, args UpsertSear
 func (db *DB) GetPackagesForSearchDocumentUpsert(ctx context.Context, before time.Time, limit int) (argsList []UpsertSearchDocumentArgs, err error) {
 	defer derrors.WrapStack(&err, "GetPackagesForSearchDocumentUpsert(ctx, %s, %d)", before, limit)
 
+	return db.getPackagesForSearchDocumentUpsert(ctx, `sd.updated_at < $1 LIMIT $2`, before, limit)
+}
+
+// getPackagesForSearchDocumentUpsert fetches search information for packages
+// in search_documents that satisfy the given WHERE clause.
+func (db *DB) getPackagesForSearchDocumentUpsert(ctx context.Context, where string, args ...interface{}) (argsList []UpsertSearchDocumentArgs, err error) {
 	query := `
 		SELECT
 			sd.package_path,
@@ -548,8 +571,7 @@ func (db *DB) GetPackagesForSearchDocumentUpsert(ctx context.Context, before tim
 		ON sd.package_path = p.path
 		    AND sd.module_path = m.module_path
 		    AND sd.version = m.version
-		WHERE sd.updated_at < $1
-		LIMIT $2`
+		WHERE ` + where
 
 	collect := func(rows *sql.Rows) error {
 		var (
@@ -568,7 +590,7 @@ func (db *DB) GetPackagesForSearchDocumentUpsert(ctx context.Context, before tim
 		argsList = append(argsList, a)
 		return nil
 	}
-	if err := db.db.RunQuery(ctx, query, collect, before, limit); err != nil {
+	if err := db.db.RunQuery(ctx, query, collect, args...); err != nil {
 		return nil, err
 	}
 	return argsList, nil
@@ -0,0 +1,226 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// The delimiters that ts_headline places around the words of a snippet that
+// match the query. They are characters that cannot appear in a synopsis or a
+// README, so that the snippet can be split unambiguously.
+const (
+	snippetMatchStart = "\x02"
+	snippetMatchStop  = "\x03"
+)
+
+// snippetOptions are the options passed to ts_headline.
+var snippetOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=" … "`,
+	snippetMatchStart, snippetMatchStop)
+
+// DocSearchResults is a page of results from SearchDocumentation.
+type DocSearchResults struct {
+	Results []*internal.SearchResult
+	// NextCursor and PrevCursor are the cursors for the pages after and
+	// before this one. They are empty if there is no such page.
+	NextCursor, PrevCursor string
+}
+
+// A searchCursor is a position in the results of SearchDocumentation. It
+// identifies a result by its rank and package path, which together order the
+// results, so that pages do not shift when documents are inserted or removed
+// between requests.
+type searchCursor struct {
+	Score float64 `json:"s"`
+	Path  string  `json:"p"`
+	// Before reports whether the cursor selects the results before the
+	// position, rather than those after it.
+	Before bool `json:"b,omitempty"`
+}
+
+func (c searchCursor) encode() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		// Marshaling a searchCursor cannot fail.
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(s string) (*searchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad cursor %q", derrors.InvalidArgument, s)
+	}
+	var c searchCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: bad cursor %q", derrors.InvalidArgument, s)
+	}
+	return &c, nil
+}
+
+// SearchDocumentation returns the packages whose synopses or READMEs match
+// the query q, ordered by relevance. Unlike Search, it does not consider
+// package paths or popularity. Each result has a snippet of the text that
+// matched.
+//
+// At most limit results are returned, starting at the given cursor, which is
+// either empty for the first page or one of the cursors of a previous result.
+// A cursor that cannot be decoded is an InvalidArgument error.
+func (db *DB) SearchDocumentation(ctx context.Context, q string, limit int, cursor string) (_ *DocSearchResults, err error) {
+	defer derrors.WrapStack(&err, "DB.SearchDocumentation(ctx, %q, %d, %q)", q, limit, cursor)
+
+	var (
+		cur   *searchCursor
+		where = "TRUE"
+		order = "score DESC, package_path"
+		args  = []interface{}{q, limit + 1, snippetOptions}
+	)
+	if cursor != "" {
+		cur, err = decodeSearchCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, cur.Score, cur.Path)
+		// ts_rank returns a real, so the score in the cursor is compared as one.
+		if cur.Before {
+			where = "score > $4::real OR (score = $4::real AND package_path < $5)"
+			order = "score, package_path DESC"
+		} else {
+			where = "score < $4::real OR (score = $4::real AND package_path > $5)"
+		}
+	}
+	// One more result than the limit is read, to learn whether there is
+	// another page. The snippet is computed in the outer query, so that
+	// ts_headline, which is expensive, only runs on the results of this page.
+	query := fmt.Sprintf(`
+		WITH matches AS (
+			SELECT
+				package_path,
+				version,
+				module_path,
+				name,
+				synopsis,
+				license_types,
+				commit_time,
+				imported_by_count,
+				ts_rank(tsv_doc_tokens, websearch_to_tsquery($1)) AS score
+			FROM search_documents
+			WHERE tsv_doc_tokens @@ websearch_to_tsquery($1)
+		)
+		SELECT
+			sd.package_path,
+			sd.version,
+			sd.module_path,
+			sd.name,
+			sd.synopsis,
+			sd.license_types,
+			sd.commit_time,
+			sd.imported_by_count,
+			sd.score,
+			(SELECT COUNT(*) FROM matches),
+			ts_headline(
+				CONCAT_WS(' ', sd.synopsis, r.contents),
+				websearch_to_tsquery($1),
+				$3)
+		FROM (
+			SELECT *
+			FROM matches
+			WHERE %[1]s
+			ORDER BY %[2]s
+			LIMIT $2
+		) sd
+		INNER JOIN paths p ON p.path = sd.package_path
+		INNER JOIN modules m ON m.module_path = sd.module_path AND m.version = sd.version
+		INNER JOIN units u ON u.path_id = p.id AND u.module_id = m.id
+		LEFT JOIN readmes r ON r.unit_id = u.id
+		ORDER BY %[2]s`, where, order)
+	var results []*internal.SearchResult
+	collect := func(rows *sql.Rows) error {
+		var (
+			r        internal.SearchResult
+			synopsis sql.NullString
+			headline string
+		)
+		if err := rows.Scan(&r.PackagePath, &r.Version, &r.ModulePath, &r.Name, &synopsis,
+			pq.Array(&r.Licenses), &r.CommitTime, &r.NumImportedBy, &r.Score, &r.NumResults,
+			&headline); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		r.Synopsis = synopsis.String
+		r.Licenses = sortAndDedup(r.Licenses)
+		r.Snippet = parseSnippet(headline)
+		results = append(results, &r)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, args...); err != nil {
+		return nil, err
+	}
+
+	more := len(results) > limit
+	if more {
+		results = results[:limit]
+	}
+	if cur != nil && cur.Before {
+		// The results were read in reverse order.
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+	}
+	sr := &DocSearchResults{Results: results}
+	if len(results) == 0 {
+		return sr, nil
+	}
+	first, last := results[0], results[len(results)-1]
+	// Going forward, there is a previous page if we started at a cursor, and a
+	// next page if there were more results. Going backward, it is the reverse.
+	hasPrev, hasNext := cur != nil, more
+	if cur != nil && cur.Before {
+		hasPrev, hasNext = more, true
+	}
+	if hasPrev {
+		sr.PrevCursor = searchCursor{Score: first.Score, Path: first.PackagePath, Before: true}.encode()
+	}
+	if hasNext {
+		sr.NextCursor = searchCursor{Score: last.Score, Path: last.PackagePath}.encode()
+	}
+	return sr, nil
+}
+
+// parseSnippet splits a headline returned by ts_headline into segments,
+// marking the segments that matched the query.
+func parseSnippet(headline string) []*internal.SnippetSegment {
+	var segs []*internal.SnippetSegment
+	add := func(text string, match bool) {
+		if text != "" {
+			segs = append(segs, &internal.SnippetSegment{Text: text, Match: match})
+		}
+	}
+	for headline != "" {
+		i := strings.Index(headline, snippetMatchStart)
+		if i < 0 {
+			break
+		}
+		add(headline[:i], false)
+		headline = headline[i+len(snippetMatchStart):]
+		j := strings.Index(headline, snippetMatchStop)
+		if j < 0 {
+			j = len(headline)
+		}
+		add(headline[:j], true)
+		headline = strings.TrimPrefix(headline[j:], snippetMatchStop)
+	}
+	add(headline, false)
+	return segs
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"sort"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetSearchPackagePaths returns the paths of all packages in the
+// search_documents table, in sorted order.
+func (db *DB) GetSearchPackagePaths(ctx context.Context) (_ []string, err error) {
+	defer derrors.WrapStack(&err, "GetSearchPackagePaths(ctx)")
+
+	set, err := db.getSearchPackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(set))
+	for p := range set {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ComputeImportedByCounts computes the imported-by counts of the packages in
+// search_documents from the imports_unique table, without writing them.
+func (db *DB) ComputeImportedByCounts(ctx context.Context) (_ map[string]int, err error) {
+	defer derrors.WrapStack(&err, "ComputeImportedByCounts(ctx)")
+
+	searchPackages, err := db.getSearchPackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.computeImportedByCounts(ctx, searchPackages)
+}
+
+// ReindexSearchDocuments refreshes the rows of search_documents for the given
+// package paths from the current data in the units, modules and readmes tables,
+// and sets their imported-by counts from counts. Paths that are missing from
+// counts get an imported-by count of zero.
+//
+// Unlike UpdateSearchDocumentsImportedByCount, ReindexSearchDocuments does not
+// lock the search_documents table, only the rows that it updates, so it can
+// be run in batches while the table is in use.
+func (db *DB) ReindexSearchDocuments(ctx context.Context, paths []string, counts map[string]int) (err error) {
+	defer derrors.WrapStack(&err, "ReindexSearchDocuments(ctx, %d paths)", len(paths))
+
+	argsList, err := db.getPackagesForSearchDocumentUpsert(ctx, `sd.package_path = ANY($1)`, pq.Array(paths))
+	if err != nil {
+		return err
+	}
+	for _, args := range argsList {
+		if err := UpsertSearchDocument(ctx, db.db, args); err != nil {
+			return err
+		}
+	}
+
+	batchCounts := make([]int, len(paths))
+	for i, p := range paths {
+		batchCounts[i] = counts[p]
+	}
+	_, err = db.db.Exec(ctx, `
+		UPDATE search_documents s
+		SET
+			imported_by_count = c.imported_by_count,
+			imported_by_count_updated_at = CURRENT_TIMESTAMP
+		FROM (
+			SELECT UNNEST($1::TEXT[]) AS package_path,
+			       UNNEST($2::INTEGER[]) AS imported_by_count
+		) c
+		WHERE s.package_path = c.package_path`,
+		pq.Array(paths), pq.Array(batchCounts))
+	return err
+}
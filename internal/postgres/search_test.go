@@ -849,6 +849,32 @@ func TestUpsertSearchDocumentVersionHasGoMod(t *testing.T) {
 	}
 }
 
+func TestUpsertSearchDocumentExcluded(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	packagePath := sample.ModulePath + "/A"
+	MustInsertModule(ctx, t, testDB, sample.Module(sample.ModulePath, "v1.0.0", "A"))
+	if _, err := getSearchDocument(ctx, testDB, packagePath); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later version that excludes the package removes it from search.
+	m := sample.Module(sample.ModulePath, "v1.1.0", "A")
+	m.Packages()[0].ExcludeFromSearch = true
+	MustInsertModule(ctx, t, testDB, m)
+	var n int
+	if err := testDB.db.QueryRow(ctx, `SELECT COUNT(*) FROM search_documents WHERE package_path = $1`, packagePath).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d search_documents rows for %q, want 0", n, packagePath)
+	}
+}
+
 func TestUpdateSearchDocumentsImportedByCount(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -1181,3 +1207,150 @@ func TestDeleteOlderVersionFromSearch(t *testing.T) {
 	insert(mod)
 	check(mod)
 }
+
+func TestSearchDocumentation(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, m := range []struct{ path, synopsis string }{
+		{"example.com/one", "Package one is a streaming parser, and a parser generator."},
+		{"example.com/two", "Package two adds a parser for dates to package time."},
+		{"example.com/three", "Package three formats durations."},
+	} {
+		mod := sample.Module(m.path, sample.VersionString, "pkg")
+		for _, u := range mod.Units {
+			for _, d := range u.Documentation {
+				d.Synopsis = m.synopsis
+			}
+		}
+		MustInsertModule(ctx, t, testDB, mod)
+	}
+
+	sr, err := testDB.SearchDocumentation(ctx, "parser", 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := sr.Results
+	var got []string
+	for _, r := range results {
+		got = append(got, r.PackagePath)
+	}
+	want := []string{"example.com/one/pkg", "example.com/two/pkg"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("mismatch (-want +got):\n%s", diff)
+	}
+	if results[0].NumResults != 2 {
+		t.Errorf("got NumResults %d, want 2", results[0].NumResults)
+	}
+	var matched []string
+	for _, s := range results[0].Snippet {
+		if s.Match {
+			matched = append(matched, s.Text)
+		}
+	}
+	if diff := cmp.Diff([]string{"parser", "parser"}, matched); diff != "" {
+		t.Errorf("snippet matches mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearchDocumentationCursor(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// Some synopses mention the term more often than others, so that results
+	// have both distinct and equal scores.
+	for i := 0; i < 25; i++ {
+		mod := sample.Module(fmt.Sprintf("example.com/m%02d", i), sample.VersionString, "pkg")
+		synopsis := "Package pkg is a" + strings.Repeat(" parser", 1+i%3) + "."
+		for _, u := range mod.Units {
+			for _, d := range u.Documentation {
+				d.Synopsis = synopsis
+			}
+		}
+		MustInsertModule(ctx, t, testDB, mod)
+	}
+
+	search := func(limit int, cursor string) *DocSearchResults {
+		t.Helper()
+		sr, err := testDB.SearchDocumentation(ctx, "parser", limit, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sr
+	}
+	paths := func(sr *DocSearchResults) []string {
+		var ps []string
+		for _, r := range sr.Results {
+			ps = append(ps, r.PackagePath)
+		}
+		return ps
+	}
+
+	all := paths(search(25, ""))
+	if len(all) != 25 {
+		t.Fatalf("got %d results, want 25", len(all))
+	}
+	page1 := search(10, "")
+	if page1.PrevCursor != "" || page1.NextCursor == "" {
+		t.Fatalf("first page: got cursors prev=%q, next=%q", page1.PrevCursor, page1.NextCursor)
+	}
+	page2 := search(10, page1.NextCursor)
+	if page2.PrevCursor == "" || page2.NextCursor == "" {
+		t.Fatalf("second page: got cursors prev=%q, next=%q", page2.PrevCursor, page2.NextCursor)
+	}
+	got := append(paths(page1), paths(page2)...)
+	if diff := cmp.Diff(all[:20], got); diff != "" {
+		t.Errorf("first two pages mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(paths(page1), paths(search(10, page2.PrevCursor))); diff != "" {
+		t.Errorf("previous page mismatch (-want +got):\n%s", diff)
+	}
+	page3 := search(10, page2.NextCursor)
+	if diff := cmp.Diff(all[20:], paths(page3)); diff != "" {
+		t.Errorf("last page mismatch (-want +got):\n%s", diff)
+	}
+	if page3.NextCursor != "" {
+		t.Errorf("last page: got next cursor %q, want none", page3.NextCursor)
+	}
+
+	if _, err := testDB.SearchDocumentation(ctx, "parser", 10, "not a cursor"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("bad cursor: got error %v, want InvalidArgument", err)
+	}
+}
+
+func TestParseSnippet(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []*internal.SnippetSegment
+	}{
+		{"", nil},
+		{"no match", []*internal.SnippetSegment{{Text: "no match"}}},
+		{
+			"a \x02parser\x03 and \x02parsers\x03",
+			[]*internal.SnippetSegment{
+				{Text: "a "},
+				{Text: "parser", Match: true},
+				{Text: " and "},
+				{Text: "parsers", Match: true},
+			},
+		},
+		{
+			"unterminated \x02match",
+			[]*internal.SnippetSegment{
+				{Text: "unterminated "},
+				{Text: "match", Match: true},
+			},
+		},
+	} {
+		got := parseSnippet(test.in)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("parseSnippet(%q) mismatch (-want +got):\n%s", test.in, diff)
+		}
+	}
+}
@@ -265,6 +265,20 @@ func upsertSymbolNamesReturningIDs(ctx context.Context, db *database.DB,
 	return nameToID, nil
 }
 
+// GetSymbols returns the exported symbols of the package at path in the given
+// module version, keyed by build context. Symbols are only stored for release
+// versions, and only when the insert-symbols experiment is active, so
+// otherwise it returns no symbols.
+func (db *DB) GetSymbols(ctx context.Context, path, modulePath, resolvedVersion string) (_ map[internal.BuildContext][]*internal.Symbol, err error) {
+	defer derrors.WrapStack(&err, "GetSymbols(ctx, %q, %q, %q)", path, modulePath, resolvedVersion)
+
+	unitID, err := db.getUnitID(ctx, path, modulePath, resolvedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return getUnitSymbols(ctx, db.db, unitID)
+}
+
 // getUnitSymbols returns all of the symbols for the given unitID.
 func getUnitSymbols(ctx context.Context, db *database.DB, unitID int) (_ map[internal.BuildContext][]*internal.Symbol, err error) {
 	defer derrors.Wrap(&err, "getUnitSymbols(ctx, db, %d)", unitID)
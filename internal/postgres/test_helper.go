@@ -114,20 +114,21 @@ func ResetTestDB(db *DB, t *testing.T) {
 			TRUNCATE paths CASCADE;
 			TRUNCATE symbol_names CASCADE;
 			TRUNCATE imports_unique;
-			TRUNCATE raw_latest_versions;`); err != nil {
+			TRUNCATE raw_latest_versions;
+			TRUNCATE path_redirects;`); err != nil {
 			return err
 		}
 		if _, err := tx.Exec(ctx, `TRUNCATE module_version_states CASCADE;`); err != nil {
 			return err
 		}
-		if _, err := tx.Exec(ctx, `TRUNCATE excluded_prefixes;`); err != nil {
+		if _, err := tx.Exec(ctx, `TRUNCATE excluded_prefixes; TRUNCATE excluded_patterns;`); err != nil {
 			return err
 		}
 		return nil
 	}); err != nil {
 		t.Fatalf("error resetting test DB: %v", err)
 	}
-	db.expoller.Poll(ctx) // clear excluded prefixes
+	db.expoller.Poll(ctx) // clear exclusions
 }
 
 // RunDBTests is a wrapper that runs the given testing suite in a test database
@@ -64,6 +64,8 @@ func (db *DB) getUnitMetaWithKnownLatestVersion(ctx context.Context, fullPath, m
 		"m.source_info",
 		"m.has_go_mod",
 		"m.redistributable",
+		"m.commit_hash",
+		"m.updated_at",
 		"u.name",
 		"u.redistributable",
 		"u.license_types",
@@ -103,6 +105,8 @@ func (db *DB) getUnitMetaWithKnownLatestVersion(ctx context.Context, fullPath, m
 		jsonbScanner{&um.SourceInfo},
 		&um.HasGoMod,
 		&um.ModuleInfo.IsRedistributable,
+		database.NullIsEmpty(&um.CommitHash),
+		&um.ModuleUpdatedAt,
 		&um.Name,
 		&um.IsRedistributable,
 		pq.Array(&licenseTypes),
@@ -250,6 +254,8 @@ func (db *DB) legacyGetUnitMeta(ctx context.Context, fullPath, requestedModulePa
 		jsonbScanner{&um.SourceInfo},
 		&um.HasGoMod,
 		&um.ModuleInfo.IsRedistributable,
+		database.NullIsEmpty(&um.CommitHash),
+		&um.ModuleUpdatedAt,
 		&um.Name,
 		&um.IsRedistributable,
 		pq.Array(&licenseTypes),
@@ -285,6 +291,8 @@ func getUnitMetaQuery(fullPath, requestedModulePath, requestedVersion string) sq
 		"m.source_info",
 		"m.has_go_mod",
 		"m.redistributable",
+		"m.commit_hash",
+		"m.updated_at",
 		"u.name",
 		"u.redistributable",
 		"u.license_types",
@@ -317,6 +325,8 @@ func getUnitMetaQuery(fullPath, requestedModulePath, requestedVersion string) sq
 		"m.source_info",
 		"m.has_go_mod",
 		"m.redistributable",
+		"m.commit_hash",
+		"m.updated_at",
 		"u.id AS unit_id",
 	).From("modules m").
 		Join("units u ON u.module_id = m.id").
@@ -532,6 +542,56 @@ func (db *DB) getPackagesInUnit(ctx context.Context, fullPath, modulePath, resol
 	return packages, nil
 }
 
+// unitIsImportable returns the value of the is_importable column of the unit
+// with the given path and package name. Units fetched before is_importable was
+// added have NULL for it, so it is computed for them. Directories, which have
+// no package name, are never importable.
+func unitIsImportable(isImportable sql.NullBool, unitPath, name string) bool {
+	if isImportable.Valid {
+		return isImportable.Bool
+	}
+	return name != "" && internal.IsImportable(unitPath, name)
+}
+
+// GetNonImportablePackages returns the set of paths in pkgPaths that are
+// packages of the given module version that cannot be imported from outside
+// it, like main and internal packages. Paths that are not packages of the
+// module version are ignored.
+func (db *DB) GetNonImportablePackages(ctx context.Context, modulePath, resolvedVersion string, pkgPaths []string) (_ map[string]bool, err error) {
+	defer derrors.WrapStack(&err, "GetNonImportablePackages(ctx, %q, %q, %d paths)", modulePath, resolvedVersion, len(pkgPaths))
+
+	query := `
+		SELECT p.path, u.name, u.is_importable
+		FROM units u
+		INNER JOIN paths p
+		ON p.id = u.path_id
+		INNER JOIN modules m
+		ON u.module_id = m.id
+		WHERE
+			m.module_path = $1
+			AND m.version = $2
+			AND p.path = ANY($3)
+			AND u.name != '';`
+	paths := map[string]bool{}
+	collect := func(rows *sql.Rows) error {
+		var (
+			path, name   string
+			isImportable sql.NullBool
+		)
+		if err := rows.Scan(&path, &name, &isImportable); err != nil {
+			return err
+		}
+		if !unitIsImportable(isImportable, path, name) {
+			paths[path] = true
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, resolvedVersion, pq.Array(pkgPaths)); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
 func (db *DB) getUnitWithAllFields(ctx context.Context, um *internal.UnitMeta) (_ *internal.Unit, err error) {
 	defer derrors.WrapStack(&err, "getUnitWithAllFields(ctx, %q, %q, %q)", um.Path, um.ModulePath, um.Version)
 	defer middleware.ElapsedStat(ctx, "getUnitWithAllFields")()
@@ -554,7 +614,12 @@ func (db *DB) getUnitWithAllFields(ctx context.Context, um *internal.UnitMeta) (
 				-- Only package_path is needed b/c it is the PK for
 				-- search_documents.
 				WHERE package_path = $1
-				), 0) AS num_imported_by
+				), 0) AS num_imported_by,
+			u.supported_build_contexts,
+			u.uses_cgo,
+			u.is_importable,
+			u.label,
+			u.files
 		FROM units u
 		INNER JOIN paths p
 		ON p.id = u.path_id
@@ -568,9 +633,11 @@ func (db *DB) getUnitWithAllFields(ctx context.Context, um *internal.UnitMeta) (
 			AND m.version = $3;`
 
 	var (
-		unitID int
-		r      internal.Readme
-		u      internal.Unit
+		unitID                 int
+		r                      internal.Readme
+		u                      internal.Unit
+		supportedBuildContexts []string
+		isImportable           sql.NullBool
 	)
 	err = db.db.QueryRow(ctx, query, um.Path, um.ModulePath, um.Version).Scan(
 		&unitID,
@@ -578,6 +645,11 @@ func (db *DB) getUnitWithAllFields(ctx context.Context, um *internal.UnitMeta) (
 		database.NullIsEmpty(&r.Contents),
 		&u.NumImports,
 		&u.NumImportedBy,
+		pq.Array(&supportedBuildContexts),
+		&u.UsesCgo,
+		&isImportable,
+		database.NullIsEmpty(&u.Label),
+		pq.Array(&u.Files),
 	)
 	switch err {
 	case sql.ErrNoRows:
@@ -586,6 +658,14 @@ func (db *DB) getUnitWithAllFields(ctx context.Context, um *internal.UnitMeta) (
 		if r.Filepath != "" && um.ModulePath != stdlib.ModulePath {
 			u.Readme = &r
 		}
+		for _, s := range supportedBuildContexts {
+			var bc internal.BuildContext
+			if i := strings.IndexByte(s, '/'); i >= 0 {
+				bc.GOOS, bc.GOARCH = s[:i], s[i+1:]
+			}
+			u.SupportedBuildContexts = append(u.SupportedBuildContexts, bc)
+		}
+		u.IsImportable = unitIsImportable(isImportable, um.Path, um.Name)
 	default:
 		return nil, err
 	}
@@ -702,3 +782,95 @@ func (db *DB) GetModuleReadme(ctx context.Context, modulePath, resolvedVersion s
 		return nil, err
 	}
 }
+
+// GetUnitPaths returns the sorted paths of the packages in the given module
+// version that can be imported from outside it, omitting main and internal
+// packages. If the module version is not in the database, it returns an error
+// that wraps derrors.NotFound.
+func (db *DB) GetUnitPaths(ctx context.Context, modulePath, resolvedVersion string) (_ []string, err error) {
+	defer derrors.WrapStack(&err, "GetUnitPaths(ctx, %q, %q)", modulePath, resolvedVersion)
+
+	query := `
+		SELECT p.path, u.name, u.is_importable
+		FROM units u
+		INNER JOIN paths p ON (p.id = u.path_id)
+		INNER JOIN modules m ON (u.module_id = m.id)
+		WHERE
+			m.module_path = $1
+			AND m.version = $2
+			AND u.name != ''
+		ORDER BY p.path`
+	var (
+		paths []string
+		n     int
+	)
+	collect := func(rows *sql.Rows) error {
+		var (
+			p, name      string
+			isImportable sql.NullBool
+		)
+		if err := rows.Scan(&p, &name, &isImportable); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		n++
+		if unitIsImportable(isImportable, p, name) {
+			paths = append(paths, p)
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, resolvedVersion); err != nil {
+		return nil, err
+	}
+	// Every module version in the database has at least one package, though
+	// it may not be importable.
+	if n == 0 {
+		return nil, derrors.NotFound
+	}
+	return paths, nil
+}
+
+// GetModuleBuildContexts returns the distinct build contexts of the
+// documentation of the packages in the given module version, sorted by
+// internal.SortBuildContexts. A module whose packages all build on every
+// platform, like a pure Go module, has the single build context
+// internal.BuildContextAll. A module without packages has none.
+//
+// If the module version is not in the database, GetModuleBuildContexts
+// returns an error that wraps derrors.NotFound.
+func (db *DB) GetModuleBuildContexts(ctx context.Context, modulePath, resolvedVersion string) (_ []internal.BuildContext, err error) {
+	defer derrors.WrapStack(&err, "GetModuleBuildContexts(ctx, %q, %q)", modulePath, resolvedVersion)
+
+	var moduleID int
+	err = db.db.QueryRow(ctx, `
+		SELECT id
+		FROM modules
+		WHERE module_path = $1 AND version = $2`,
+		modulePath, resolvedVersion).Scan(&moduleID)
+	if err == sql.ErrNoRows {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT DISTINCT d.goos, d.goarch
+		FROM documentation d
+		INNER JOIN units u
+		ON d.unit_id = u.id
+		WHERE u.module_id = $1`
+	var bcs []internal.BuildContext
+	collect := func(rows *sql.Rows) error {
+		var bc internal.BuildContext
+		if err := rows.Scan(&bc.GOOS, &bc.GOARCH); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		bcs = append(bcs, bc)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, moduleID); err != nil {
+		return nil, err
+	}
+	internal.SortBuildContexts(bcs)
+	return bcs, nil
+}
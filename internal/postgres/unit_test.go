@@ -6,6 +6,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path"
 	"strings"
@@ -15,6 +16,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/safehtml"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/source"
@@ -89,7 +91,7 @@ func testGetUnitMeta(t *testing.T, ctx context.Context) {
 		}
 		opts := []cmp.Option{
 			cmpopts.IgnoreFields(licenses.Metadata{}, "Coverage", "OldCoverage"),
-			cmpopts.IgnoreFields(internal.UnitMeta{}, "HasGoMod"),
+			cmpopts.IgnoreFields(internal.UnitMeta{}, "HasGoMod", "ModuleUpdatedAt"),
 			cmp.AllowUnexported(source.Info{}, safehtml.HTML{}),
 		}
 		if diff := cmp.Diff(test.want, got, opts...); diff != "" {
@@ -460,7 +462,7 @@ func TestGetUnitMetaBypass(t *testing.T) {
 				}
 				opts := []cmp.Option{
 					cmpopts.IgnoreFields(licenses.Metadata{}, "Coverage", "OldCoverage"),
-					cmpopts.IgnoreFields(internal.UnitMeta{}, "HasGoMod"),
+					cmpopts.IgnoreFields(internal.UnitMeta{}, "HasGoMod", "ModuleUpdatedAt"),
 					cmp.AllowUnexported(source.Info{}, safehtml.HTML{}),
 				}
 				if diff := cmp.Diff(test.want, got, opts...); diff != "" {
@@ -1084,3 +1086,264 @@ func newUnitMeta(path, modulePath, version string) *internal.UnitMeta {
 		},
 	}
 }
+
+func TestGetModuleBuildContexts(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// A module with a pure Go package and two packages that only build on
+	// some platforms.
+	m := sample.Module("a.com/mixed", "v1.0.0", "pure", "unix", "sys")
+	for _, u := range m.Units {
+		switch u.Path {
+		case "a.com/mixed/unix":
+			u.Documentation = []*internal.Documentation{
+				sample.Documentation("linux", "amd64", `package unix; var L int`),
+				sample.Documentation("darwin", "amd64", `package unix; var D int`),
+			}
+		case "a.com/mixed/sys":
+			u.Documentation = []*internal.Documentation{
+				sample.Documentation("linux", "amd64", `package sys; var L int`),
+				sample.Documentation("windows", "amd64", `package sys; var W int`),
+			}
+		}
+	}
+	MustInsertModule(ctx, t, testDB, m)
+	MustInsertModule(ctx, t, testDB, sample.Module("a.com/pure", "v1.0.0", "p", "q"))
+
+	for _, test := range []struct {
+		modulePath string
+		want       []internal.BuildContext
+	}{
+		{
+			modulePath: "a.com/mixed",
+			want: []internal.BuildContext{
+				internal.BuildContextAll,
+				internal.BuildContextLinux,
+				internal.BuildContextWindows,
+				internal.BuildContextDarwin,
+			},
+		},
+		{
+			modulePath: "a.com/pure",
+			want:       []internal.BuildContext{internal.BuildContextAll},
+		},
+	} {
+		t.Run(test.modulePath, func(t *testing.T) {
+			got, err := testDB.GetModuleBuildContexts(ctx, test.modulePath, "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := testDB.GetModuleBuildContexts(ctx, "a.com/mixed", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want NotFound", err)
+	}
+}
+
+func TestGetUnitSupportedBuildContexts(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	m := sample.Module("a.com/m", "v1.0.0", "arm", "pure")
+	for _, u := range m.Units {
+		if u.Path == "a.com/m/arm" {
+			u.Documentation = []*internal.Documentation{
+				sample.Documentation("linux", "arm", `package arm; var A int`),
+			}
+			u.SupportedBuildContexts = []internal.BuildContext{{GOOS: "linux", GOARCH: "arm"}}
+		}
+	}
+	MustInsertModule(ctx, t, testDB, m)
+
+	for _, test := range []struct {
+		path string
+		want []internal.BuildContext
+	}{
+		{"a.com/m/arm", []internal.BuildContext{{GOOS: "linux", GOARCH: "arm"}}},
+		{"a.com/m/pure", nil},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			um, err := testDB.GetUnitMeta(ctx, test.path, "a.com/m", "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := testDB.GetUnit(ctx, um, internal.WithMain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, u.SupportedBuildContexts); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetUnitWithFiles(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	m := sample.Module("a.com/m", "v1.0.0", "foo")
+	for _, u := range m.Units {
+		if u.Path == "a.com/m/foo" {
+			u.Files = []string{"foo.go", "foo_linux.go"}
+		}
+	}
+	MustInsertModule(ctx, t, testDB, m)
+
+	for _, test := range []struct {
+		path string
+		want []string
+	}{
+		{"a.com/m/foo", []string{"foo.go", "foo_linux.go"}},
+		{"a.com/m", nil},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			um, err := testDB.GetUnitMeta(ctx, test.path, "a.com/m", "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := testDB.GetUnit(ctx, um, internal.AllFields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, u.Files); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetUnitIsImportable(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	m := sample.Module("a.com/m", "v1.0.0", "foo", "internal/bar")
+	for _, u := range m.Units {
+		u.IsImportable = u.Name != "" && internal.IsImportable(u.Path, u.Name)
+	}
+	MustInsertModule(ctx, t, testDB, m)
+
+	check := func(t *testing.T) {
+		t.Helper()
+		for _, test := range []struct {
+			path string
+			want bool
+		}{
+			{"a.com/m/foo", true},
+			{"a.com/m/internal/bar", false},
+			// The module root is a directory.
+			{"a.com/m", false},
+		} {
+			um, err := testDB.GetUnitMeta(ctx, test.path, "a.com/m", "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := testDB.GetUnit(ctx, um, internal.AllFields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.IsImportable != test.want {
+				t.Errorf("%s: got IsImportable %t, want %t", test.path, u.IsImportable, test.want)
+			}
+		}
+	}
+	t.Run("stored", check)
+	// Units fetched before is_importable was added have NULL for it.
+	if _, err := testDB.db.Exec(ctx, `UPDATE units SET is_importable = NULL`); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("null", check)
+}
+
+func TestGetUnitPaths(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// The module root is a directory, not a package, and another version of
+	// the module has different packages.
+	// Main and internal packages are omitted.
+	m := sample.Module("a.com/multi", "v1.0.0", "z", "b/c", "a", "b", "internal/i", "cmd/tool")
+	for _, u := range m.Units {
+		if u.Path == "a.com/multi/cmd/tool" {
+			u.Name = "main"
+		}
+		u.IsImportable = u.Name != "" && internal.IsImportable(u.Path, u.Name)
+	}
+	MustInsertModule(ctx, t, testDB, m)
+	MustInsertModule(ctx, t, testDB, sample.Module("a.com/multi", "v1.1.0", "a", "d"))
+	MustInsertModule(ctx, t, testDB, sample.Module("a.com/multi/b/c", "v1.0.0", "e"))
+
+	got, err := testDB.GetUnitPaths(ctx, "a.com/multi", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.com/multi/a", "a.com/multi/b", "a.com/multi/b/c", "a.com/multi/z"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := testDB.GetUnitPaths(ctx, "a.com/multi", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want NotFound", err)
+	}
+}
+
+func TestGetModuleReadme(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	withReadme := sample.Module("a.com/readme", "v1.0.0", "pkg")
+	MustInsertModule(ctx, t, testDB, withReadme)
+
+	// A module whose only README is in a package directory has no module
+	// README.
+	withoutReadme := sample.Module("a.com/noreadme", "v1.0.0", "pkg")
+	withoutReadme.Units[0].Readme = nil
+	withoutReadme.Units[1].Readme = &internal.Readme{
+		Filepath: "pkg/README.md",
+		Contents: "package readme",
+	}
+	MustInsertModule(ctx, t, testDB, withoutReadme)
+
+	got, err := testDB.GetModuleReadme(ctx, "a.com/readme", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.Readme{Filepath: sample.ReadmeFilePath, Contents: sample.ReadmeContents}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, test := range []struct {
+		modulePath, version string
+	}{
+		{"a.com/noreadme", "v1.0.0"},
+		{"a.com/readme", "v9.9.9"},
+	} {
+		if _, err := testDB.GetModuleReadme(ctx, test.modulePath, test.version); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("GetModuleReadme(%q, %q): got error %v, want NotFound", test.modulePath, test.version, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetUnitFiles returns the sorted names, relative to the unit's directory,
+// of the non-test .go files that make up the package at fullPath in the given
+// module version, as recorded when it was fetched. Units that are not
+// packages, and packages fetched before the files were recorded, have none.
+//
+// If the unit is not in the database, GetUnitFiles returns an error that
+// wraps derrors.NotFound.
+func (db *DB) GetUnitFiles(ctx context.Context, fullPath, modulePath, resolvedVersion string) (_ []string, err error) {
+	defer derrors.WrapStack(&err, "GetUnitFiles(ctx, %q, %q, %q)", fullPath, modulePath, resolvedVersion)
+
+	var files []string
+	err = db.db.QueryRow(ctx, `
+		SELECT u.files
+		FROM units u
+		INNER JOIN paths p ON (p.id = u.path_id)
+		INNER JOIN modules m ON (m.id = u.module_id)
+		WHERE
+			p.path = $1
+			AND m.module_path = $2
+			AND m.version = $3`,
+		fullPath, modulePath, resolvedVersion).Scan(pq.Array(&files))
+	if err == sql.ErrNoRows {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
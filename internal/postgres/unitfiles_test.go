@@ -0,0 +1,58 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetUnitFiles(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	m := sample.Module("a.com/m", "v1.0.0", "foo")
+	for _, u := range m.Units {
+		if u.Path == "a.com/m/foo" {
+			u.Files = []string{"foo.go", "foo_linux.go"}
+		}
+	}
+	MustInsertModule(ctx, t, testDB, m)
+
+	for _, test := range []struct {
+		path string
+		want []string
+	}{
+		{"a.com/m/foo", []string{"foo.go", "foo_linux.go"}},
+		{"a.com/m", nil},
+	} {
+		got, err := testDB.GetUnitFiles(ctx, test.path, "a.com/m", "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.path, diff)
+		}
+	}
+
+	for _, test := range []struct {
+		path, version string
+	}{
+		{"a.com/m/bar", "v1.0.0"},
+		{"a.com/m/foo", "v1.1.0"},
+	} {
+		if _, err := testDB.GetUnitFiles(ctx, test.path, "a.com/m", test.version); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("GetUnitFiles(%q, %q): got %v, want NotFound", test.path, test.version, err)
+		}
+	}
+}
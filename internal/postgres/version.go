@@ -7,17 +7,21 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/lib/pq"
+	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/version"
 	"golang.org/x/sync/errgroup"
 )
@@ -42,6 +46,29 @@ func (db *DB) GetVersionsForPath(ctx context.Context, path string) (_ []*interna
 	return versions, nil
 }
 
+// GetModuleVersions returns all the versions of the module, both tagged and
+// pseudo-versions, sorted in descending semver order.
+func (db *DB) GetModuleVersions(ctx context.Context, modulePath string) (_ []*internal.ModuleInfo, err error) {
+	defer derrors.WrapStack(&err, "GetModuleVersions(ctx, %q)", modulePath)
+
+	versions, err := getPathVersions(ctx, db, modulePath, version.TypeRelease, version.TypePrerelease, version.TypePseudo)
+	if err != nil {
+		return nil, err
+	}
+	// getPathVersions returns the versions of every module in the series of
+	// modulePath, with incompatible versions last.
+	var mis []*internal.ModuleInfo
+	for _, mi := range versions {
+		if mi.ModulePath == modulePath {
+			mis = append(mis, mi)
+		}
+	}
+	sort.SliceStable(mis, func(i, j int) bool {
+		return semver.Compare(mis[i].Version, mis[j].Version) > 0
+	})
+	return mis, nil
+}
+
 // getPathVersions returns a list of versions sorted in descending semver
 // order. The version types included in the list are specified by a list of
 // VersionTypes.
@@ -56,7 +83,9 @@ func getPathVersions(ctx context.Context, db *DB, path string, versionTypes ...v
 		m.redistributable,
 		m.has_go_mod,
 		m.deprecated_comment,
-		m.source_info
+		m.source_info,
+		m.commit_hash,
+		m.origin
 	FROM modules m
 	INNER JOIN units u
 		ON u.module_id = m.id
@@ -187,6 +216,137 @@ func (db *DB) GetLatestInfo(ctx context.Context, unitPath, modulePath string) (l
 	return latest, nil
 }
 
+// GetLatestInfoBatch returns the latest information about each of the units
+// in paths. Units that are not in the database, and units whose module series
+// has no versions, are missing from the result.
+func (db *DB) GetLatestInfoBatch(ctx context.Context, paths []internal.UnitModulePath) (_ map[internal.UnitModulePath]internal.LatestInfo, err error) {
+	defer derrors.WrapStack(&err, "DB.GetLatestInfoBatch(ctx, %d paths)", len(paths))
+	defer middleware.ElapsedStat(ctx, "GetLatestInfoBatch")()
+
+	if !experiment.IsActive(ctx, internal.ExperimentUnitMetaWithLatest) {
+		return db.getLatestInfos(ctx, paths)
+	}
+	// The single query does not determine the minor version from the
+	// latest_module_versions table, as GetUnitMeta does with the experiment.
+	infos := map[internal.UnitModulePath]internal.LatestInfo{}
+	for _, p := range paths {
+		latest, err := db.GetLatestInfo(ctx, p.Path, p.ModulePath)
+		if err != nil {
+			if errors.Is(err, derrors.NotFound) || errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return nil, err
+		}
+		infos[p] = latest
+	}
+	return infos, nil
+}
+
+// getLatestInfos computes the latest information for all of paths in a single
+// query. The minor version is determined by the go command's ordering of
+// versions, like GetUnitMeta does without the unit-meta-with-latest
+// experiment.
+func (db *DB) getLatestInfos(ctx context.Context, paths []internal.UnitModulePath) (map[internal.UnitModulePath]internal.LatestInfo, error) {
+	infos := map[internal.UnitModulePath]internal.LatestInfo{}
+	if len(paths) == 0 {
+		return infos, nil
+	}
+
+	var unitPaths, modulePaths, seriesPaths, v1Paths []string
+	for _, p := range paths {
+		unitPaths = append(unitPaths, p.Path)
+		modulePaths = append(modulePaths, p.ModulePath)
+		seriesPaths = append(seriesPaths, internal.SeriesPathForModule(p.ModulePath))
+		v1Paths = append(v1Paths, internal.V1Path(p.Path, p.ModulePath))
+	}
+	// Each lateral subquery computes one part of the LatestInfo, as
+	// GetUnitMeta, getLatestMajorVersion and unitExistsAtLatest do for a
+	// single unit.
+	query := `
+		SELECT
+			i.unit_path,
+			i.module_path,
+			minor.module_path,
+			minor.version,
+			major.module_path,
+			COALESCE(major_unit.path, major.module_path),
+			COALESCE(good.version = '' OR EXISTS (
+				SELECT 1
+				FROM units u
+				INNER JOIN paths p ON p.id = u.path_id
+				INNER JOIN modules m ON m.id = u.module_id
+				WHERE p.path = i.unit_path AND m.module_path = i.module_path AND m.version = good.version
+			), FALSE)
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[])
+			AS i(unit_path, module_path, series_path, v1_path)
+		INNER JOIN LATERAL (
+			SELECT m.module_path, m.version
+			FROM modules m
+			INNER JOIN units u ON u.module_id = m.id
+			INNER JOIN paths p ON p.id = u.path_id
+			WHERE p.path = i.unit_path
+			ORDER BY
+				CASE
+					WHEN m.version_type = 'release' AND NOT m.incompatible THEN 1
+					WHEN m.version_type = 'prerelease' AND NOT m.incompatible THEN 2
+					WHEN m.version_type = 'release' THEN 3
+					WHEN m.version_type = 'prerelease' THEN 4
+					ELSE 5
+				END,
+				m.series_path DESC,
+				m.sort_version DESC
+			LIMIT 1
+		) minor ON TRUE
+		INNER JOIN LATERAL (
+			SELECT m.id, m.module_path
+			FROM modules m
+			WHERE m.series_path = i.series_path
+			ORDER BY m.incompatible, m.sort_version DESC
+			LIMIT 1
+		) major ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT p.path
+			FROM units u
+			INNER JOIN paths p ON p.id = u.path_id
+			INNER JOIN paths p2 ON p2.id = u.v1path_id
+			WHERE p2.path = i.v1_path AND u.module_id = major.id
+			LIMIT 1
+		) major_unit ON TRUE
+		LEFT JOIN LATERAL (
+			-- The latest good version of the module, if there is latest-version
+			-- information for it, or else its latest version, preferring
+			-- releases.
+			SELECT COALESCE(
+				(SELECT r.good_version
+				 FROM latest_module_versions r
+				 INNER JOIN paths p ON p.id = r.module_path_id
+				 WHERE p.path = i.module_path AND r.status = 200),
+				(SELECT m.version
+				 FROM modules m
+				 WHERE m.module_path = i.module_path
+				 ORDER BY m.version_type = 'release' DESC, m.sort_version DESC
+				 LIMIT 1)
+			) AS version
+		) good ON TRUE`
+	collect := func(rows *sql.Rows) error {
+		var (
+			p      internal.UnitModulePath
+			latest internal.LatestInfo
+		)
+		if err := rows.Scan(&p.Path, &p.ModulePath, &latest.MinorModulePath, &latest.MinorVersion,
+			&latest.MajorModulePath, &latest.MajorUnitPath, &latest.UnitExistsAtMinor); err != nil {
+			return err
+		}
+		infos[p] = latest
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect,
+		pq.Array(unitPaths), pq.Array(modulePaths), pq.Array(seriesPaths), pq.Array(v1Paths)); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
 // getLatestMajorVersion returns the latest module path and the full package path
 // of the latest version found, given the fullPath and the modulePath.
 // For example, in the module path "github.com/casbin/casbin", there
@@ -337,6 +497,44 @@ func (db *DB) getMultiLatestModuleVersions(ctx context.Context, modulePaths []st
 	return lmvs, nil
 }
 
+// GetLatestUnitPaths returns the units in the latest good version of each
+// module whose paths sort after the given one, ordered by path. It returns at
+// most limit units, so callers can page through all of them in batches by
+// passing the last path of one batch as after for the next. A path that is a
+// unit of more than one of those module versions is returned once, for the
+// module with the longest path, as the frontend resolves it.
+//
+// Only Path, ModulePath, Version and CommitTime are populated.
+func (db *DB) GetLatestUnitPaths(ctx context.Context, after string, limit int) (_ []*internal.UnitMeta, err error) {
+	defer derrors.WrapStack(&err, "GetLatestUnitPaths(%q, %d)", after, limit)
+
+	var ums []*internal.UnitMeta
+	collect := func(rows *sql.Rows) error {
+		um := &internal.UnitMeta{}
+		if err := rows.Scan(&um.Path, &um.ModulePath, &um.Version, &um.CommitTime); err != nil {
+			return err
+		}
+		ums = append(ums, um)
+		return nil
+	}
+	err = db.db.RunQuery(ctx, `
+		SELECT DISTINCT ON (up.path) up.path, mp.path, r.good_version, m.commit_time
+		FROM latest_module_versions r
+		INNER JOIN paths mp ON mp.id = r.module_path_id
+		INNER JOIN modules m ON m.module_path = mp.path AND m.version = r.good_version
+		INNER JOIN units u ON u.module_id = m.id
+		INNER JOIN paths up ON up.id = u.path_id
+		WHERE r.status = 200
+		AND up.path > $1
+		ORDER BY up.path, length(mp.path) DESC
+		LIMIT $2
+	`, collect, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	return ums, nil
+}
+
 // GetLatestModuleVersions returns the row of the latest_module_versions table for modulePath.
 // If the module path is not found, it returns nil, nil.
 func (db *DB) GetLatestModuleVersions(ctx context.Context, modulePath string) (_ *internal.LatestModuleVersions, err error) {
@@ -65,6 +65,85 @@ func (db *DB) UpsertVersionMap(ctx context.Context, vm *internal.VersionMap) (er
 	return err
 }
 
+// UpsertVersionMaps is like UpsertVersionMap, but inserts many version_map
+// entries with a single statement, which takes each column of the entries as
+// an array parameter. If vms has several entries for the same module path and
+// requested version, the last one is inserted.
+func (db *DB) UpsertVersionMaps(ctx context.Context, vms []*internal.VersionMap) (err error) {
+	defer derrors.WrapStack(&err, "DB.UpsertVersionMaps(ctx, %d version maps)", len(vms))
+
+	// A single INSERT ... ON CONFLICT cannot update a row twice, so keep only
+	// the last entry for each key, as consecutive calls to UpsertVersionMap
+	// would.
+	type key struct{ modulePath, requestedVersion string }
+	last := map[key]int{}
+	for i, vm := range vms {
+		last[key{vm.ModulePath, vm.RequestedVersion}] = i
+	}
+	var (
+		modulePaths, requestedVersions, resolvedVersions []string
+		goModPaths, errs, sortVersions                   []string
+		statuses                                         []int64
+	)
+	for i, vm := range vms {
+		if last[key{vm.ModulePath, vm.RequestedVersion}] != i {
+			continue
+		}
+		var sortVersion string
+		if vm.ResolvedVersion != "" {
+			sortVersion = version.ForSorting(vm.ResolvedVersion)
+		}
+		modulePaths = append(modulePaths, vm.ModulePath)
+		requestedVersions = append(requestedVersions, vm.RequestedVersion)
+		resolvedVersions = append(resolvedVersions, vm.ResolvedVersion)
+		goModPaths = append(goModPaths, vm.GoModPath)
+		statuses = append(statuses, int64(vm.Status))
+		errs = append(errs, vm.Error)
+		sortVersions = append(sortVersions, sortVersion)
+	}
+	if len(modulePaths) == 0 {
+		return nil
+	}
+	// As in UpsertVersionMap, the module ID is zero if the resolved version is
+	// empty or not in the modules table.
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO version_map(
+			module_path,
+			requested_version,
+			resolved_version,
+			go_mod_path,
+			status,
+			error,
+			sort_version,
+			module_id)
+		SELECT
+			v.module_path,
+			v.requested_version,
+			v.resolved_version,
+			v.go_mod_path,
+			v.status,
+			v.error,
+			v.sort_version,
+			COALESCE(m.id, 0)
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::integer[], $6::text[], $7::text[])
+			AS v(module_path, requested_version, resolved_version, go_mod_path, status, error, sort_version)
+		LEFT JOIN modules m
+		ON m.module_path = v.module_path AND m.version = v.resolved_version
+		ON CONFLICT (module_path, requested_version)
+		DO UPDATE SET
+			module_path=excluded.module_path,
+			go_mod_path=excluded.go_mod_path,
+			requested_version=excluded.requested_version,
+			resolved_version=excluded.resolved_version,
+			status=excluded.status,
+			error=excluded.error,
+			sort_version=excluded.sort_version,
+			module_id=excluded.module_id`,
+		pq.Array(modulePaths), pq.Array(requestedVersions), pq.Array(resolvedVersions),
+		pq.Array(goModPaths), pq.Array(statuses), pq.Array(errs), pq.Array(sortVersions))
+	return err
+}
+
 // GetVersionMap fetches a version_map entry corresponding to the given
 // modulePath and requestedVersion.
 func (db *DB) GetVersionMap(ctx context.Context, modulePath, requestedVersion string) (_ *internal.VersionMap, err error) {
@@ -7,6 +7,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -79,6 +80,64 @@ func TestUpsertVersionMap(t *testing.T) {
 	upsertAndVerifyVersionMap(vm)
 }
 
+func TestUpsertVersionMaps(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// Some of the resolved versions are in the modules table.
+	MustInsertModule(ctx, t, testDB, sample.Module("example.com/m0", "v1.0.0", "p"))
+	MustInsertModule(ctx, t, testDB, sample.Module("example.com/m1", "v1.0.0", "p"))
+
+	var vms []*internal.VersionMap
+	for i := 0; i < 200; i++ {
+		vm := &internal.VersionMap{
+			ModulePath:       fmt.Sprintf("example.com/m%d", i%50),
+			RequestedVersion: fmt.Sprintf("v1.%d.0", i/50),
+		}
+		if i%3 == 0 {
+			vm.Status = http.StatusNotFound
+			vm.Error = "not found"
+		} else {
+			vm.RequestedVersion = fmt.Sprintf("branch%d", i/50)
+			vm.ResolvedVersion = "v1.0.0"
+			vm.GoModPath = vm.ModulePath
+			vm.Status = http.StatusOK
+		}
+		vms = append(vms, vm)
+	}
+	if err := testDB.UpsertVersionMaps(ctx, vms); err != nil {
+		t.Fatal(err)
+	}
+	// A second call updates the existing rows, and the last of several
+	// entries for the same row wins.
+	updated := *vms[0]
+	updated.Status = http.StatusInternalServerError
+	updated.Error = "server error"
+	vms[0] = &updated
+	if err := testDB.UpsertVersionMaps(ctx, []*internal.VersionMap{vms[1], vms[0]}); err != nil {
+		t.Fatal(err)
+	}
+	dup := updated
+	dup.Error = "overridden"
+	if err := testDB.UpsertVersionMaps(ctx, []*internal.VersionMap{&dup, &updated}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vm := range vms {
+		got, err := testDB.GetVersionMap(ctx, vm.ModulePath, vm.RequestedVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(vm, got, cmpopts.IgnoreFields(internal.VersionMap{}, "UpdatedAt")); diff != "" {
+			t.Errorf("GetVersionMap(ctx, %q, %q) mismatch (-want +got):\n%s",
+				vm.ModulePath, vm.RequestedVersion, diff)
+		}
+	}
+}
+
 func TestGetVersionMapsWithNon2xxStatus(t *testing.T) {
 	t.Parallel()
 	testDB, release := acquire(t)
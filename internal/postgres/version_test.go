@@ -238,6 +238,7 @@ func TestGetVersions(t *testing.T) {
 				w.IsRedistributable = mod.IsRedistributable
 				w.HasGoMod = mod.HasGoMod
 				w.SourceInfo = mod.SourceInfo
+				w.Origin = mod.SourceInfo.InferOrigin(w.Version)
 			}
 
 			got, err := testDB.GetVersionsForPath(ctx, test.path)
@@ -371,6 +372,61 @@ func TestGetLatestInfo(t *testing.T) {
 	}
 }
 
+func TestGetLatestInfoBatch(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, m := range []*internal.Module{
+		sample.Module("a.com/M", "v1.1.1", "all", "most", "some", "one", "D/other"),
+		sample.Module("a.com/M", "v1.2.0", "all", "most"),
+		sample.Module("a.com/M", "v99.0.0+incompatible", "all", "most"),
+		sample.Module("a.com/M/v2", "v2.0.5", "all", "most"),
+		sample.Module("a.com/M/v2", "v2.1.0", "all"),
+		sample.Module("a.com/M/v3", "v3.0.1", "all", "some"),
+		sample.Module("a.com/M/D", "v1.3.0", "other"),
+		sample.Module("b.com/M/v9", "v9.0.0", ""),
+		sample.Module("b.com/M/v10", "v10.0.0", ""),
+	} {
+		MustInsertModule(ctx, t, testDB, m)
+	}
+	// Latest-version information for a.com/M/v2 determines its latest good
+	// version.
+	addLatest(ctx, t, testDB, "a.com/M/v2", "v2.1.0", "module a.com/M/v2")
+
+	paths := []internal.UnitModulePath{
+		{Path: "a.com/M", ModulePath: "a.com/M"},
+		{Path: "a.com/M/all", ModulePath: "a.com/M"},
+		{Path: "a.com/M/most", ModulePath: "a.com/M"},
+		{Path: "a.com/M/some", ModulePath: "a.com/M"},
+		{Path: "a.com/M/one", ModulePath: "a.com/M"},
+		{Path: "a.com/M/D/other", ModulePath: "a.com/M"},
+		{Path: "a.com/M/v2/all", ModulePath: "a.com/M/v2"},
+		{Path: "a.com/M/v2/most", ModulePath: "a.com/M/v2"},
+		{Path: "b.com/M/v9", ModulePath: "b.com/M/v9"},
+	}
+	want := map[internal.UnitModulePath]internal.LatestInfo{}
+	for _, p := range paths {
+		latest, err := testDB.GetLatestInfo(ctx, p.Path, p.ModulePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[p] = latest
+	}
+	// A unit that does not exist is missing from the result.
+	paths = append(paths, internal.UnitModulePath{Path: "c.com/M/none", ModulePath: "c.com/M"})
+
+	got, err := testDB.GetLatestInfoBatch(ctx, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
 func TestShouldUpdateRawLatest(t *testing.T) {
 	for _, test := range []struct {
 		new, cur string
@@ -455,6 +511,59 @@ func TestGetLatestGoodVersion(t *testing.T) {
 	}
 }
 
+func TestGetLatestUnitPaths(t *testing.T) {
+	t.Parallel()
+	testDB, release := acquire(t)
+	defer release()
+	ctx := context.Background()
+
+	for _, mv := range []struct{ modulePath, version string }{
+		{"example.com/a", "v1.0.0"},
+		{"example.com/a", "v1.1.0"},
+		{"example.com/b", "v0.1.0"},
+		{"example.com/c", "v1.0.0"},
+		{"example.com/d", "v1.0.0"},
+		{"example.com/d/pkg", "v1.0.0"},
+	} {
+		MustInsertModule(ctx, t, testDB, sample.Module(mv.modulePath, mv.version, "pkg"))
+	}
+	addLatest(ctx, t, testDB, "example.com/a", "v1.1.0", "module example.com/a")
+	addLatest(ctx, t, testDB, "example.com/b", "v0.1.0", "module example.com/b")
+	addLatest(ctx, t, testDB, "example.com/d", "v1.0.0", "module example.com/d")
+	addLatest(ctx, t, testDB, "example.com/d/pkg", "v1.0.0", "module example.com/d/pkg")
+	// example.com/c has no latest-version information.
+
+	var got []string
+	after := ""
+	for {
+		ums, err := testDB.GetLatestUnitPaths(ctx, after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ums) == 0 {
+			break
+		}
+		for _, um := range ums {
+			got = append(got, um.Path+" "+um.ModulePath+"@"+um.Version)
+		}
+		after = ums[len(ums)-1].Path
+	}
+	want := []string{
+		"example.com/a example.com/a@v1.1.0",
+		"example.com/a/pkg example.com/a@v1.1.0",
+		"example.com/b example.com/b@v0.1.0",
+		"example.com/b/pkg example.com/b@v0.1.0",
+		"example.com/d example.com/d@v1.0.0",
+		// example.com/d/pkg is a package of example.com/d and the root of the
+		// example.com/d/pkg module, which wins.
+		"example.com/d/pkg example.com/d/pkg@v1.0.0",
+		"example.com/d/pkg/pkg example.com/d/pkg@v1.0.0",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestLatestModuleVersions(t *testing.T) {
 	t.Parallel()
 	testDB, release := acquire(t)
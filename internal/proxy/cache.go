@@ -0,0 +1,108 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal"
+)
+
+// A ResponseCache stores the bodies of proxy responses. A *cache.Cache is a
+// ResponseCache; NewMemoryCache returns one that lives in memory.
+type ResponseCache interface {
+	// Get returns the data for key, or nil if there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put stores data for key for the duration of ttl.
+	Put(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+// cacheTTL is how long responses are kept in a ResponseCache. The responses
+// that are cached never change, so this only bounds the size of the cache.
+const cacheTTL = 24 * time.Hour
+
+// cacheKey returns the key under which the response for the given module
+// version and suffix is cached.
+func cacheKey(modulePath, version, suffix string) string {
+	return "proxy/" + suffix + "/" + modulePath + "@" + version
+}
+
+// isResolvedVersion reports whether v is a canonical semantic version, like
+// one returned by the proxy's .info endpoint. The responses for such versions
+// never change, so they can be cached. Queries like internal.LatestVersion or
+// branch names can resolve to different versions over time.
+func isResolvedVersion(v string) bool {
+	if v == internal.LatestVersion || !semver.IsValid(v) {
+		return false
+	}
+	build := semver.Build(v)
+	if build != "" && build != "+incompatible" {
+		return false
+	}
+	return semver.Canonical(v)+build == v
+}
+
+// NewMemoryCache returns a ResponseCache that holds up to size entries in
+// memory, evicting the least recently used one when it is full.
+func NewMemoryCache(size int) ResponseCache {
+	return &memoryCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+type memoryCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List // of *memoryEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if time.Now().After(e.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, nil
+	}
+	c.order.MoveToFront(el)
+	return e.data, nil
+}
+
+func (c *memoryCache) Put(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &memoryEntry{key: key, data: data, expires: time.Now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*memoryEntry).key)
+	}
+	return nil
+}
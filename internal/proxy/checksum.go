@@ -0,0 +1,146 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.opencensus.io/plugin/ochttp"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// A ChecksumDB looks up the hashes of module versions recorded in a checksum
+// database, like sum.golang.org.
+type ChecksumDB interface {
+	// ZipHash returns the hash of the zip of the given module version, in
+	// the "h1:" form used in go.sum files. If the database has no record of
+	// the module version, the error wraps derrors.NotFound.
+	ZipHash(ctx context.Context, modulePath, version string) (string, error)
+}
+
+// NewSumDB returns a ChecksumDB that reads records from the lookup endpoint
+// of the checksum database served at url, such as https://sum.golang.org.
+// It trusts the records it is sent; it does not check them against the
+// signed tree of the database.
+func NewSumDB(url string) ChecksumDB {
+	return &sumDB{
+		url:        strings.TrimRight(url, "/"),
+		httpClient: &http.Client{Transport: &log.RequestIDTransport{Base: &ochttp.Transport{}}},
+	}
+}
+
+type sumDB struct {
+	url        string
+	httpClient *http.Client
+}
+
+// ZipHash implements ChecksumDB.ZipHash.
+func (db *sumDB) ZipHash(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "sumDB.ZipHash(%q, %q)", modulePath, version)
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", fmt.Errorf("path: %v: %w", err, derrors.InvalidArgument)
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("version: %v: %w", err, derrors.InvalidArgument)
+	}
+	u := fmt.Sprintf("%s/lookup/%s@%s", db.url, escapedPath, escapedVersion)
+	r, err := ctxhttp.Get(ctx, db.httpClient, u)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+	if err := responseError(r, false); err != nil {
+		return "", err
+	}
+	// The response starts with the go.sum lines for the module version,
+	// followed by a blank line and the signed tree head.
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			break
+		}
+		if len(fields) == 3 && fields[0] == modulePath && fields[1] == version {
+			return fields[2], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no zip hash in response from %s: %w", u, derrors.NotFound)
+}
+
+// WithChecksumDB returns a new client that verifies each module zip it
+// downloads against db, except for modules whose paths match one of the
+// glob patterns in noSumCheck. The patterns match path prefixes, as for
+// GONOSUMDB (see "go help module-private").
+//
+// If the hash of a zip differs from the one in db, or db has no record of
+// the module version, Zip fails with an error wrapping derrors.BadModule.
+func (c *Client) WithChecksumDB(db ChecksumDB, noSumCheck []string) *Client {
+	c2 := *c
+	c2.checksumDB = db
+	c2.noSumCheck = strings.Join(noSumCheck, ",")
+	return &c2
+}
+
+// SkipsChecksum reports whether zips for modulePath are used without being
+// verified against a checksum database.
+func (c *Client) SkipsChecksum(modulePath string) bool {
+	return c.checksumDB == nil || module.MatchPrefixPatterns(c.noSumCheck, modulePath)
+}
+
+// verifyZip checks the hash of zr against c.checksumDB, unless the module is
+// exempt from verification.
+func (c *Client) verifyZip(ctx context.Context, modulePath, resolvedVersion string, zr *zip.Reader) (err error) {
+	defer derrors.Wrap(&err, "verifyZip(%q, %q)", modulePath, resolvedVersion)
+
+	if c.SkipsChecksum(modulePath) {
+		return nil
+	}
+	want, err := c.checksumDB.ZipHash(ctx, modulePath, resolvedVersion)
+	if errors.Is(err, derrors.NotFound) {
+		return fmt.Errorf("not in checksum database: %v: %w", err, derrors.BadModule)
+	}
+	if err != nil {
+		return err
+	}
+	got, err := hashZip(zr)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, derrors.BadModule)
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch: downloaded %s, checksum database has %s: %w", got, want, derrors.BadModule)
+	}
+	return nil
+}
+
+// hashZip returns the "h1:" hash of the files in zr, as computed by
+// dirhash.HashZip for a zip file on disk.
+func hashZip(zr *zip.Reader) (string, error) {
+	files := make([]string, 0, len(zr.File))
+	byName := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files = append(files, f.Name)
+		byName[f.Name] = f
+	}
+	return dirhash.Hash1(files, func(name string) (io.ReadCloser, error) {
+		return byName[name].Open()
+	})
+}
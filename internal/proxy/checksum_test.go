@@ -0,0 +1,196 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+// fakeChecksumDB is a ChecksumDB that serves hashes from a map keyed by
+// "path@version", and records the lookups made.
+type fakeChecksumDB struct {
+	hashes  map[string]string
+	lookups []string
+}
+
+func (db *fakeChecksumDB) ZipHash(ctx context.Context, modulePath, version string) (string, error) {
+	key := modulePath + "@" + version
+	db.lookups = append(db.lookups, key)
+	h, ok := db.hashes[key]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", key, derrors.NotFound)
+	}
+	return h, nil
+}
+
+func TestZipChecksum(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	client, teardownProxy := SetupTestClient(t, []*Module{testModule})
+	defer teardownProxy()
+
+	zr, err := client.Zip(ctx, sample.ModulePath, sample.VersionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goodHash, err := hashZip(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := sample.ModulePath + "@" + sample.VersionString
+
+	for _, test := range []struct {
+		name       string
+		hashes     map[string]string
+		noSumCheck []string
+		wantErr    error
+		wantLookup bool
+	}{
+		{
+			name:       "match",
+			hashes:     map[string]string{key: goodHash},
+			wantLookup: true,
+		},
+		{
+			name:       "mismatch",
+			hashes:     map[string]string{key: "h1:bad"},
+			wantErr:    derrors.BadModule,
+			wantLookup: true,
+		},
+		{
+			name:       "not recorded",
+			wantErr:    derrors.BadModule,
+			wantLookup: true,
+		},
+		{
+			name:       "other prefix exempt",
+			hashes:     map[string]string{key: "h1:bad"},
+			noSumCheck: []string{"example.com/private", "github.com/other"},
+			wantErr:    derrors.BadModule,
+			wantLookup: true,
+		},
+		{
+			name:       "exempt mismatch",
+			hashes:     map[string]string{key: "h1:bad"},
+			noSumCheck: []string{"example.com/private", "github.com/valid"},
+		},
+		{
+			name:       "exempt not recorded",
+			noSumCheck: []string{"github.com/*/module_name"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			db := &fakeChecksumDB{hashes: test.hashes}
+			c := client.WithChecksumDB(db, test.noSumCheck)
+			_, err := c.Zip(ctx, sample.ModulePath, sample.VersionString)
+			if !errors.Is(err, test.wantErr) || (err != nil && test.wantErr == nil) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+			if got := len(db.lookups) > 0; got != test.wantLookup {
+				t.Errorf("looked up checksum: got %t, want %t", got, test.wantLookup)
+			}
+		})
+	}
+}
+
+func TestSkipsChecksum(t *testing.T) {
+	client, err := New([]string{"https://proxy.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.SkipsChecksum("github.com/my/module") {
+		t.Error("client without a checksum database verifies zips")
+	}
+	client = client.WithChecksumDB(&fakeChecksumDB{}, []string{"corp.example.com", "*.internal"})
+	for _, test := range []struct {
+		modulePath string
+		want       bool
+	}{
+		{"github.com/my/module", false},
+		{"corp.example.com", true},
+		{"corp.example.com/a/b", true},
+		{"corp.example.community/a", false},
+		{"git.internal/a", true},
+		{"example.com/corp.example.com", false},
+	} {
+		if got := client.SkipsChecksum(test.modulePath); got != test.want {
+			t.Errorf("SkipsChecksum(%q) = %t, want %t", test.modulePath, got, test.want)
+		}
+	}
+}
+
+func TestHashZip(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	mux := NewServer([]*Module{testModule}).mux
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/"+sample.ModulePath+"/@v/"+sample.VersionString+".zip", nil))
+	filename := filepath.Join(t.TempDir(), "m.zip")
+	if err := ioutil.WriteFile(filename, w.Body.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := dirhash.HashZip(filename, dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, teardownProxy := SetupTestClient(t, []*Module{testModule})
+	defer teardownProxy()
+	zr, err := client.Zip(ctx, sample.ModulePath, sample.VersionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := hashZip(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSumDBZipHash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lookup/github.com/!my/module@v1.0.0":
+			fmt.Fprint(w, "1234\n"+
+				"github.com/My/module v1.0.0 h1:zip=\n"+
+				"github.com/My/module v1.0.0/go.mod h1:mod=\n"+
+				"\n"+
+				"go.sum database tree\n")
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	db := NewSumDB(server.URL + "/")
+	got, err := db.ZipHash(ctx, "github.com/My/module", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "h1:zip="; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := db.ZipHash(ctx, "github.com/My/module", "v1.1.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want %v", err, derrors.NotFound)
+	}
+}
@@ -13,51 +13,115 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	"go.opencensus.io/plugin/ochttp"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/stdlib"
 )
 
 // A Client is used by the fetch service to communicate with a module
 // proxy. It handles all methods defined by go help goproxy.
 type Client struct {
-	// URL of the module proxy web server
-	url string
+	// URLs of the module proxy web servers, in the order they are tried.
+	urls []string
 
 	// Client used for HTTP requests. It is mutable for testing purposes.
 	httpClient *http.Client
 
 	// Whether fetch should be disabled.
 	disableFetch bool
+
+	// The number of times to retry Zip and ZipSize requests that fail with a
+	// transient error, and the delay before the first retry. See
+	// WithZipRetries.
+	zipRetries    int
+	zipRetryDelay time.Duration
+
+	// If non-nil, the cache for the responses to Info and Mod requests for
+	// resolved versions. See WithCache.
+	cache ResponseCache
+
+	// If non-nil, the checksum database that zips are verified against, and
+	// the comma-separated patterns for module paths that are exempt from
+	// verification. See WithChecksumDB.
+	checksumDB ChecksumDB
+	noSumCheck string
 }
 
+// Defaults for the retries of Zip and ZipSize requests.
+const (
+	defaultZipRetries    = 2
+	defaultZipRetryDelay = time.Second
+)
+
 // A VersionInfo contains metadata about a given version of a module.
 type VersionInfo struct {
 	Version string
 	Time    time.Time
+	// Origin describes where the version came from. Only some proxies
+	// report it.
+	Origin *Origin `json:",omitempty"`
+}
+
+// Origin describes the source of a module version, as reported in the Origin
+// field of a proxy .info response.
+type Origin struct {
+	VCS    string `json:",omitempty"` // version control system, like "git"
+	URL    string `json:",omitempty"` // URL of the repository
+	Subdir string `json:",omitempty"` // directory of the module in the repository
+	Ref    string `json:",omitempty"` // reference the version was resolved from, like "refs/tags/v1.2.3"
+	Hash   string `json:",omitempty"` // hash of the commit the version refers to
 }
 
 // Setting this header to true prevents the proxy from fetching uncached
 // modules.
 const disableFetchHeader = "Disable-Module-Fetch"
 
-// New constructs a *Client using the provided url, which is expected to
-// be an absolute URI that can be directly passed to http.Get.
-func New(u string) (_ *Client, err error) {
-	defer derrors.WrapStack(&err, "proxy.New(%q)", u)
-	return &Client{
-		url:          strings.TrimRight(u, "/"),
-		httpClient:   &http.Client{Transport: &ochttp.Transport{}},
-		disableFetch: false,
-	}, nil
+// New constructs a *Client using the provided urls, which are expected to be
+// absolute URIs that can be directly passed to http.Get.
+//
+// Requests are sent to the proxies in order. The next proxy is tried only if
+// a proxy cannot be reached or responds with a 5xx status. A 404 or 410
+// response is authoritative and is returned without trying the remaining
+// proxies. This differs from the go command, which moves on to the next proxy
+// in a comma-separated GOPROXY list after a 404 or 410.
+func New(urls []string) (_ *Client, err error) {
+	defer derrors.WrapStack(&err, "proxy.New(%q)", urls)
+	if len(urls) == 0 {
+		return nil, errors.New("no proxy URLs")
+	}
+	c := &Client{
+		httpClient:    &http.Client{Transport: &log.RequestIDTransport{Base: &ochttp.Transport{}}},
+		disableFetch:  false,
+		zipRetries:    defaultZipRetries,
+		zipRetryDelay: defaultZipRetryDelay,
+	}
+	for _, u := range urls {
+		c.urls = append(c.urls, strings.TrimRight(u, "/"))
+	}
+	return c, nil
+}
+
+// SplitURLs splits a comma-separated list of proxy URLs, like the value of
+// GOPROXY, into its elements, ignoring empty ones.
+func SplitURLs(list string) []string {
+	var urls []string
+	for _, u := range strings.Split(list, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
 }
 
 // WithFetchDisabled returns a new client that sets the Disable-Module-Fetch
@@ -69,6 +133,27 @@ func (c *Client) WithFetchDisabled() *Client {
 	return &c2
 }
 
+// WithZipRetries returns a new client that retries Zip and ZipSize requests
+// up to n times if they fail with a transient error, like a network error or
+// a 5xx response. It waits baseDelay before the first retry, and twice as long
+// before each subsequent one.
+func (c *Client) WithZipRetries(n int, baseDelay time.Duration) *Client {
+	c2 := *c
+	c2.zipRetries = n
+	c2.zipRetryDelay = baseDelay
+	return &c2
+}
+
+// WithCache returns a new client that stores the responses to Info and Mod
+// requests in rc, and serves them from rc when they are requested again.
+// Only requests for resolved versions are cached, since the responses for
+// queries like "latest" or "master" change over time.
+func (c *Client) WithCache(rc ResponseCache) *Client {
+	c2 := *c
+	c2.cache = rc
+	return &c2
+}
+
 // FetchDisabled reports whether proxy fetch is disabled.
 func (c *Client) FetchDisabled() bool {
 	return c.disableFetch
@@ -86,7 +171,7 @@ func (c *Client) Info(ctx context.Context, modulePath, requestedVersion string)
 		}
 		wrap(&err, "proxy.Client.Info(%q, %q)", modulePath, requestedVersion)
 	}()
-	data, err := c.readBody(ctx, modulePath, requestedVersion, "info")
+	data, err := c.readCachedBody(ctx, modulePath, requestedVersion, "info")
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +185,7 @@ func (c *Client) Info(ctx context.Context, modulePath, requestedVersion string)
 // Mod makes a request to $GOPROXY/<module>/@v/<resolvedVersion>.mod and returns the raw data.
 func (c *Client) Mod(ctx context.Context, modulePath, resolvedVersion string) (_ []byte, err error) {
 	defer derrors.WrapStack(&err, "proxy.Client.Mod(%q, %q)", modulePath, resolvedVersion)
-	return c.readBody(ctx, modulePath, resolvedVersion, "mod")
+	return c.readCachedBody(ctx, modulePath, resolvedVersion, "mod")
 }
 
 // Zip makes a request to $GOPROXY/<modulePath>/@v/<resolvedVersion>.zip and
@@ -109,17 +194,44 @@ func (c *Client) Mod(ctx context.Context, modulePath, resolvedVersion string) (_
 // $GOPROXY/<modulePath>/@v/<requestedVersion>.info to obtained the valid
 // semantic version.
 func (c *Client) Zip(ctx context.Context, modulePath, resolvedVersion string) (_ *zip.Reader, err error) {
+	zipReader, _, err := c.ZipWithProxyURL(ctx, modulePath, resolvedVersion)
+	return zipReader, err
+}
+
+// ZipWithProxyURL is like Zip, but also returns the URL of the proxy that
+// served the zip.
+func (c *Client) ZipWithProxyURL(ctx context.Context, modulePath, resolvedVersion string) (_ *zip.Reader, proxyURL string, err error) {
 	defer derrors.WrapStack(&err, "proxy.Client.Zip(ctx, %q, %q)", modulePath, resolvedVersion)
 
-	bodyBytes, err := c.readBody(ctx, modulePath, resolvedVersion, "zip")
+	var zipReader *zip.Reader
+	err = c.retry(ctx, "Zip", modulePath, resolvedVersion, func(attempt int) error {
+		var (
+			bodyBytes []byte
+			err       error
+		)
+		bodyBytes, proxyURL, err = c.readBody(ctx, modulePath, resolvedVersion, "zip")
+		if err != nil {
+			return err
+		}
+		zipReader, err = zip.NewReader(bytes.NewReader(bodyBytes), int64(len(bodyBytes)))
+		if err != nil {
+			err = fmt.Errorf("zip.NewReader: %v: %w", err, derrors.BadModule)
+			if attempt == 0 {
+				// The zip may have been truncated in transit, so try again,
+				// but only once.
+				err = &transientError{err}
+			}
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	zipReader, err := zip.NewReader(bytes.NewReader(bodyBytes), int64(len(bodyBytes)))
-	if err != nil {
-		return nil, fmt.Errorf("zip.NewReader: %v: %w", err, derrors.BadModule)
+	if err := c.verifyZip(ctx, modulePath, resolvedVersion, zipReader); err != nil {
+		return nil, "", err
 	}
-	return zipReader, nil
+	return zipReader, proxyURL, nil
 }
 
 // ZipSize gets the size in bytes of the zip from the proxy, without downloading it.
@@ -127,26 +239,31 @@ func (c *Client) Zip(ctx context.Context, modulePath, resolvedVersion string) (_
 func (c *Client) ZipSize(ctx context.Context, modulePath, resolvedVersion string) (_ int64, err error) {
 	defer derrors.WrapStack(&err, "proxy.Client.ZipSize(ctx, %q, %q)", modulePath, resolvedVersion)
 
-	url, err := c.escapedURL(modulePath, resolvedVersion, "zip")
+	p, err := c.escapedPath(modulePath, resolvedVersion, "zip")
 	if err != nil {
 		return 0, err
 	}
-	res, err := ctxhttp.Head(ctx, c.httpClient, url)
+	var size int64
+	err = c.retry(ctx, "ZipSize", modulePath, resolvedVersion, func(int) error {
+		_, err := c.executeRequest(ctx, http.MethodHead, p, false, func(res *http.Response) error {
+			if res.ContentLength < 0 {
+				return errors.New("unknown content length")
+			}
+			size = res.ContentLength
+			return nil
+		})
+		return err
+	})
 	if err != nil {
-		return 0, fmt.Errorf("ctxhttp.Head(ctx, client, %q): %v", url, err)
-	}
-	defer res.Body.Close()
-	if err := responseError(res, false); err != nil {
 		return 0, err
 	}
-	if res.ContentLength < 0 {
-		return 0, errors.New("unknown content length")
-	}
-	return res.ContentLength, nil
+	return size, nil
 }
 
-func (c *Client) escapedURL(modulePath, requestedVersion, suffix string) (_ string, err error) {
-	defer derrors.WrapStack(&err, "Client.escapedURL(%q, %q, %q)", modulePath, requestedVersion, suffix)
+// escapedPath returns the path of the proxy endpoint for the given module
+// version and suffix, relative to the root of the proxy.
+func (c *Client) escapedPath(modulePath, requestedVersion, suffix string) (_ string, err error) {
+	defer derrors.WrapStack(&err, "Client.escapedPath(%q, %q, %q)", modulePath, requestedVersion, suffix)
 
 	if suffix != "info" && suffix != "mod" && suffix != "zip" {
 		return "", errors.New(`suffix must be "info", "mod" or "zip"`)
@@ -159,31 +276,62 @@ func (c *Client) escapedURL(modulePath, requestedVersion, suffix string) (_ stri
 		if suffix != "info" {
 			return "", fmt.Errorf("cannot ask for latest with suffix %q", suffix)
 		}
-		return fmt.Sprintf("%s/%s/@latest", c.url, escapedPath), nil
+		return fmt.Sprintf("%s/@latest", escapedPath), nil
 	}
 	escapedVersion, err := module.EscapeVersion(requestedVersion)
 	if err != nil {
 		return "", fmt.Errorf("version: %v: %w", err, derrors.InvalidArgument)
 	}
-	return fmt.Sprintf("%s/%s/@v/%s.%s", c.url, escapedPath, escapedVersion, suffix), nil
+	return fmt.Sprintf("%s/@v/%s.%s", escapedPath, escapedVersion, suffix), nil
 }
 
-func (c *Client) readBody(ctx context.Context, modulePath, requestedVersion, suffix string) (_ []byte, err error) {
+// readBody returns the body of the response for the given module version and
+// suffix, along with the URL of the proxy that served it.
+func (c *Client) readBody(ctx context.Context, modulePath, requestedVersion, suffix string) (_ []byte, proxyURL string, err error) {
 	defer derrors.WrapStack(&err, "Client.readBody(%q, %q, %q)", modulePath, requestedVersion, suffix)
 
-	u, err := c.escapedURL(modulePath, requestedVersion, suffix)
+	p, err := c.escapedPath(modulePath, requestedVersion, suffix)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	var data []byte
-	err = c.executeRequest(ctx, u, func(body io.Reader) error {
+	proxyURL, err = c.executeRequest(ctx, http.MethodGet, p, c.disableFetch, func(r *http.Response) error {
 		var err error
-		data, err = ioutil.ReadAll(body)
-		return err
+		data, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			// The connection may have been interrupted.
+			return &transientError{err}
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, "", err
+	}
+	return data, proxyURL, nil
+}
+
+// readCachedBody is like readBody, but uses c.cache, if there is one, for
+// resolved versions. Errors from the cache are logged and otherwise ignored.
+func (c *Client) readCachedBody(ctx context.Context, modulePath, requestedVersion, suffix string) (_ []byte, err error) {
+	if c.cache == nil || !isResolvedVersion(requestedVersion) {
+		data, _, err := c.readBody(ctx, modulePath, requestedVersion, suffix)
+		return data, err
+	}
+	key := cacheKey(modulePath, requestedVersion, suffix)
+	data, err := c.cache.Get(ctx, key)
+	if err != nil {
+		log.Warningf(ctx, "proxy cache: %v", err)
+	}
+	if data != nil {
+		return data, nil
+	}
+	data, _, err = c.readBody(ctx, modulePath, requestedVersion, suffix)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.cache.Put(ctx, key, data, cacheTTL); err != nil {
+		log.Warningf(ctx, "proxy cache: %v", err)
+	}
 	return data, nil
 }
 
@@ -194,47 +342,179 @@ func (c *Client) Versions(ctx context.Context, modulePath string) (_ []string, e
 	if err != nil {
 		return nil, fmt.Errorf("module.EscapePath(%q): %w", modulePath, derrors.InvalidArgument)
 	}
-	u := fmt.Sprintf("%s/%s/@v/list", c.url, escapedPath)
 	var versions []string
-	collect := func(body io.Reader) error {
-		scanner := bufio.NewScanner(body)
+	collect := func(r *http.Response) error {
+		scanner := bufio.NewScanner(r.Body)
 		for scanner.Scan() {
 			versions = append(versions, scanner.Text())
 		}
 		return scanner.Err()
 	}
-	if err := c.executeRequest(ctx, u, collect); err != nil {
+	if _, err := c.executeRequest(ctx, http.MethodGet, escapedPath+"/@v/list", c.disableFetch, collect); err != nil {
 		return nil, err
 	}
 	return versions, nil
 }
 
-// executeRequest executes an HTTP GET request for u, then calls the bodyFunc
-// on the response body, if no error occurred.
-func (c *Client) executeRequest(ctx context.Context, u string, bodyFunc func(body io.Reader) error) (err error) {
+// AllVersions returns every known version of the module, sorted by semver.
+// Unlike Versions, it includes the version served by the @latest endpoint,
+// which is the only one available for a module that has no tagged versions.
+// If the proxy does not know the module, AllVersions returns an error that
+// wraps derrors.NotFound. For the standard library, it returns the Go
+// versions from the Go repo.
+func (c *Client) AllVersions(ctx context.Context, modulePath string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "AllVersions(%q)", modulePath)
+
+	if modulePath == stdlib.ModulePath {
+		versions, err := stdlib.Versions()
+		if err != nil {
+			return nil, err
+		}
+		sortVersions(versions)
+		return versions, nil
+	}
+	versions, err := c.Versions(ctx, modulePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.Info(ctx, modulePath, internal.LatestVersion)
+	switch {
+	case errors.Is(err, derrors.NotFound) || errors.Is(err, derrors.NotFetched):
+		// No @latest information; the list is all there is.
+	case err != nil:
+		return nil, err
+	default:
+		versions = append(versions, info.Version)
+	}
+	seen := map[string]bool{}
+	var all []string
+	for _, v := range versions {
+		if !seen[v] {
+			seen[v] = true
+			all = append(all, v)
+		}
+	}
+	sortVersions(all)
+	return all, nil
+}
+
+// sortVersions sorts vs in increasing semver order.
+func sortVersions(vs []string) {
+	sort.Slice(vs, func(i, j int) bool { return semver.Compare(vs[i], vs[j]) < 0 })
+}
+
+// Ping reports whether one of the proxies can be reached. A proxy counts as
+// reachable if it responds to a request for its root with any status below
+// 500.
+func (c *Client) Ping(ctx context.Context) (err error) {
+	defer derrors.Wrap(&err, "Ping(ctx)")
+
+	for _, base := range c.urls {
+		if err = c.pingURL(ctx, base+"/"); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *Client) pingURL(ctx context.Context, u string) error {
+	req, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		return err
+	}
+	r, err := ctxhttp.Do(ctx, c.httpClient, req)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	if r.StatusCode >= 500 {
+		return fmt.Errorf("%q: %s", u, r.Status)
+	}
+	return nil
+}
+
+// executeRequest sends an HTTP request with the given method for the path p
+// to each proxy in turn, until one of them can be reached and does not respond
+// with a 5xx status. If that response is successful, executeRequest calls
+// respFunc on it. It returns the URL of the proxy that produced the final
+// response.
+//
+// If setDisableFetch is true, the Disable-Module-Fetch header is set on the
+// request.
+func (c *Client) executeRequest(ctx context.Context, method, p string, setDisableFetch bool, respFunc func(*http.Response) error) (proxyURL string, err error) {
 	defer func() {
 		if ctx.Err() != nil {
 			err = fmt.Errorf("%v: %w", err, derrors.ProxyTimedOut)
 		}
-		derrors.WrapStack(&err, "executeRequest(ctx, %q)", u)
+		derrors.WrapStack(&err, "executeRequest(ctx, %q, %q)", method, p)
 	}()
 
-	req, err := http.NewRequest("GET", u, nil)
+	for _, base := range c.urls {
+		var retry bool
+		retry, err = c.executeRequestTo(ctx, method, base+"/"+p, setDisableFetch, respFunc)
+		if !retry || ctx.Err() != nil {
+			return base, err
+		}
+	}
+	return "", err
+}
+
+// executeRequestTo sends an HTTP request for u and calls respFunc on the
+// response, if no error occurred. It reports whether the error, if any, means
+// that the request should be sent to the next proxy.
+func (c *Client) executeRequestTo(ctx context.Context, method, u string, setDisableFetch bool, respFunc func(*http.Response) error) (retry bool, err error) {
+	req, err := http.NewRequest(method, u, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
-	if c.disableFetch {
+	if setDisableFetch {
 		req.Header.Set(disableFetchHeader, "true")
 	}
 	r, err := ctxhttp.Do(ctx, c.httpClient, req)
 	if err != nil {
-		return fmt.Errorf("ctxhttp.Do(ctx, client, %q): %v", u, err)
+		return true, &transientError{fmt.Errorf("ctxhttp.Do(ctx, client, %q): %v", u, err)}
 	}
 	defer r.Body.Close()
-	if err := responseError(r, c.disableFetch); err != nil {
-		return err
+	if r.StatusCode >= 500 {
+		return true, &transientError{fmt.Errorf("%q: %w", u, responseError(r, setDisableFetch))}
+	}
+	if err := responseError(r, setDisableFetch); err != nil {
+		return false, err
+	}
+	return false, respFunc(r)
+}
+
+// A transientError is an error from a request to the proxy that may not
+// recur if the request is sent again.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// retry calls f until it succeeds, fails with an error that is not a
+// transientError, or has been retried c.zipRetries times, and returns the
+// last error. It backs off exponentially between calls. f is passed the
+// number of the attempt, starting at 0. The name, modulePath and version are
+// used for logging.
+func (c *Client) retry(ctx context.Context, name, modulePath, version string, f func(attempt int) error) error {
+	delay := c.zipRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := f(attempt)
+		var terr *transientError
+		if err == nil || attempt >= c.zipRetries || !errors.As(err, &terr) || ctx.Err() != nil {
+			return err
+		}
+		log.Infof(ctx, "proxy.Client.%s(%q, %q): retrying in %s (retry %d of %d): %v",
+			name, modulePath, version, delay, attempt+1, c.zipRetries, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
 	}
-	return bodyFunc(r.Body)
 }
 
 // responseError translates the response status code to an appropriate error.
@@ -9,12 +9,17 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/testing/sample"
 	"golang.org/x/pkgsite/internal/testing/testhelper"
 )
@@ -126,6 +131,78 @@ func TestListVersions(t *testing.T) {
 	}
 }
 
+func TestAllVersions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const (
+		tagged     = "example.com/tagged"
+		pseudoOnly = "example.com/pseudo"
+		pseudo     = "v0.0.0-20200101120000-000000000000"
+	)
+	var testModules []*Module
+	for _, v := range []string{"v1.2.0", "v1.10.0", "v1.1.0"} {
+		testModules = append(testModules, &Module{
+			ModulePath: tagged,
+			Version:    v,
+			Files:      map[string]string{"bar.go": "package bar"},
+		})
+	}
+	testModules = append(testModules, &Module{
+		ModulePath: pseudoOnly,
+		Version:    pseudo,
+		Files:      map[string]string{"bar.go": "package bar"},
+	})
+	client, teardownProxy := SetupTestClient(t, testModules)
+	defer teardownProxy()
+
+	for _, test := range []struct {
+		modulePath string
+		want       []string
+	}{
+		{tagged, []string{"v1.1.0", "v1.2.0", "v1.10.0"}},
+		{pseudoOnly, []string{pseudo}},
+	} {
+		got, err := client.AllVersions(ctx, test.modulePath)
+		if err != nil {
+			t.Fatalf("AllVersions(%q): %v", test.modulePath, err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("AllVersions(%q) mismatch (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+	if _, err := client.AllVersions(ctx, "example.com/unknown"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want NotFound", err)
+	}
+}
+
+func TestAllVersionsStdlib(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer func(old bool) { stdlib.UseTestData = old }(stdlib.UseTestData)
+	stdlib.UseTestData = true
+
+	client, teardownProxy := SetupTestClient(t, nil)
+	defer teardownProxy()
+	got, err := client.AllVersions(ctx, stdlib.ModulePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := stdlib.Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d versions, want %d", len(got), len(want))
+	}
+	for i := 1; i < len(got); i++ {
+		if semver.Compare(got[i-1], got[i]) >= 0 {
+			t.Errorf("versions not sorted: %q before %q", got[i-1], got[i])
+		}
+	}
+}
+
 func TestInfo(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -284,15 +361,15 @@ func TestZipSize(t *testing.T) {
 	})
 }
 
-func TestEncodedURL(t *testing.T) {
-	c := &Client{url: "u"}
+func TestEscapedPath(t *testing.T) {
+	c := &Client{urls: []string{"u"}}
 	for _, test := range []struct {
 		path, version, suffix string
 		want                  string // empty => error
 	}{
 		{
 			"mod.com", "v1.0.0", "info",
-			"u/mod.com/@v/v1.0.0.info",
+			"mod.com/@v/v1.0.0.info",
 		},
 		{
 			"mod", "v1.0.0", "info",
@@ -300,11 +377,11 @@ func TestEncodedURL(t *testing.T) {
 		},
 		{
 			"mod.com", "v1.0.0-rc1", "info",
-			"u/mod.com/@v/v1.0.0-rc1.info",
+			"mod.com/@v/v1.0.0-rc1.info",
 		},
 		{
 			"mod.com/Foo", "v1.0.0-RC1", "info",
-			"u/mod.com/!foo/@v/v1.0.0-!r!c1.info",
+			"mod.com/!foo/@v/v1.0.0-!r!c1.info",
 		},
 		{
 			"mod.com", ".", "info",
@@ -312,7 +389,7 @@ func TestEncodedURL(t *testing.T) {
 		},
 		{
 			"mod.com", "v1.0.0", "zip",
-			"u/mod.com/@v/v1.0.0.zip",
+			"mod.com/@v/v1.0.0.zip",
 		},
 		{
 			"mod", "v1.0.0", "zip",
@@ -320,11 +397,11 @@ func TestEncodedURL(t *testing.T) {
 		},
 		{
 			"mod.com", "v1.0.0-rc1", "zip",
-			"u/mod.com/@v/v1.0.0-rc1.zip",
+			"mod.com/@v/v1.0.0-rc1.zip",
 		},
 		{
 			"mod.com/Foo", "v1.0.0-RC1", "zip",
-			"u/mod.com/!foo/@v/v1.0.0-!r!c1.zip",
+			"mod.com/!foo/@v/v1.0.0-!r!c1.zip",
 		},
 		{
 			"mod.com", ".", "zip",
@@ -332,7 +409,7 @@ func TestEncodedURL(t *testing.T) {
 		},
 		{
 			"mod.com", internal.LatestVersion, "info",
-			"u/mod.com/@latest",
+			"mod.com/@latest",
 		},
 		{
 			"mod.com", internal.LatestVersion, "zip",
@@ -343,9 +420,249 @@ func TestEncodedURL(t *testing.T) {
 			"", // only "info" or "zip"
 		},
 	} {
-		got, err := c.escapedURL(test.path, test.version, test.suffix)
+		got, err := c.escapedPath(test.path, test.version, test.suffix)
 		if got != test.want || (err != nil) != (test.want == "") {
 			t.Errorf("%s, %s, %s: got (%q, %v), want %q", test.path, test.version, test.suffix, got, err, test.want)
 		}
 	}
 }
+
+func TestFallbackProxies(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, test := range []struct {
+		name          string
+		firstStatus   int
+		wantErr       error
+		wantSecondHit bool
+	}{
+		{"fall through on 500", http.StatusInternalServerError, nil, true},
+		{"short-circuit on 410", http.StatusGone, derrors.NotFound, false},
+		{"short-circuit on 404", http.StatusNotFound, derrors.NotFound, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, http.StatusText(test.firstStatus), test.firstStatus)
+			}))
+			defer first.Close()
+			secondHit := false
+			mux := NewServer([]*Module{testModule}).mux
+			second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				secondHit = true
+				mux.ServeHTTP(w, r)
+			}))
+			defer second.Close()
+
+			client, err := New([]string{first.URL, second.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, proxyURL, err := client.ZipWithProxyURL(ctx, sample.ModulePath, sample.VersionString)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("got error %v, want %v", err, test.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if proxyURL != second.URL {
+					t.Errorf("got proxy URL %q, want %q", proxyURL, second.URL)
+				}
+			}
+			if secondHit != test.wantSecondHit {
+				t.Errorf("second proxy hit: got %t, want %t", secondHit, test.wantSecondHit)
+			}
+		})
+	}
+}
+
+func TestZipRetries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, test := range []struct {
+		name     string
+		fails    int // number of requests that fail before the proxy recovers
+		status   int // status of failed requests; 0 means a truncated zip
+		zipSize  bool
+		wantErr  bool
+		wantHits int
+	}{
+		{name: "fails twice", fails: 2, status: http.StatusBadGateway, wantHits: 3},
+		{name: "fails three times", fails: 3, status: http.StatusBadGateway, wantErr: true, wantHits: 3},
+		{name: "not found", fails: 3, status: http.StatusNotFound, wantErr: true, wantHits: 1},
+		{name: "truncated once", fails: 1, wantHits: 2},
+		{name: "truncated twice", fails: 2, wantErr: true, wantHits: 2},
+		{name: "zip size", fails: 2, status: http.StatusServiceUnavailable, zipSize: true, wantHits: 3},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			mux := NewServer([]*Module{testModule}).mux
+			hits := 0
+			flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, ".zip") {
+					mux.ServeHTTP(w, r)
+					return
+				}
+				hits++
+				switch {
+				case hits > test.fails:
+					mux.ServeHTTP(w, r)
+				case test.status != 0:
+					http.Error(w, http.StatusText(test.status), test.status)
+				default:
+					rec := httptest.NewRecorder()
+					mux.ServeHTTP(rec, r)
+					w.Write(rec.Body.Bytes()[:rec.Body.Len()/2])
+				}
+			}))
+			defer flaky.Close()
+
+			client, err := New([]string{flaky.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client = client.WithZipRetries(2, time.Millisecond)
+			if test.zipSize {
+				_, err = client.ZipSize(ctx, sample.ModulePath, sample.VersionString)
+			} else {
+				_, err = client.Zip(ctx, sample.ModulePath, sample.VersionString)
+			}
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if hits != test.wantHits {
+				t.Errorf("got %d requests, want %d", hits, test.wantHits)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	mux := NewServer([]*Module{testModule}).mux
+	hits := map[string]int{}
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[path.Base(r.URL.Path)]++
+		mux.ServeHTTP(w, r)
+	}))
+	defer counting.Close()
+
+	client, err := New([]string{counting.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = client.WithCache(NewMemoryCache(10))
+	for i := 0; i < 2; i++ {
+		info, err := client.Info(ctx, sample.ModulePath, sample.VersionString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Version != sample.VersionString {
+			t.Errorf("got version %q, want %q", info.Version, sample.VersionString)
+		}
+		if _, err := client.Mod(ctx, sample.ModulePath, sample.VersionString); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Info(ctx, sample.ModulePath, internal.LatestVersion); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]int{
+		sample.VersionString + ".info": 1,
+		sample.VersionString + ".mod":  1,
+		"@latest":                      2,
+	}
+	if diff := cmp.Diff(want, hits); diff != "" {
+		t.Errorf("requests mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIsResolvedVersion(t *testing.T) {
+	for _, test := range []struct {
+		version string
+		want    bool
+	}{
+		{"v1.2.3", true},
+		{"v2.0.0+incompatible", true},
+		{"v0.0.0-20210101000000-abcdefabcdef", true},
+		{"v1.2", false},
+		{"v1.2.3+meta", false},
+		{"master", false},
+		{internal.LatestVersion, false},
+	} {
+		if got := isResolvedVersion(test.version); got != test.want {
+			t.Errorf("isResolvedVersion(%q) = %t, want %t", test.version, got, test.want)
+		}
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2)
+	put := func(key string) {
+		if err := c.Put(ctx, key, []byte(key), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("a")
+	put("b")
+	if _, err := c.Get(ctx, "a"); err != nil { // a is now more recently used than b
+		t.Fatal(err)
+	}
+	put("c")
+	for _, test := range []struct {
+		key  string
+		want bool
+	}{{"a", true}, {"b", false}, {"c", true}} {
+		got, err := c.Get(ctx, test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got != nil) != test.want {
+			t.Errorf("Get(%q) = %q, want present: %t", test.key, got, test.want)
+		}
+	}
+}
+
+func TestSplitURLs(t *testing.T) {
+	got := SplitURLs("https://a.example.com, https://b.example.com/,,")
+	want := []string{"https://a.example.com", "https://b.example.com/"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	// A proxy that has no page at its root is still reachable.
+	up := httptest.NewServer(http.NotFoundHandler())
+	defer up.Close()
+
+	for _, test := range []struct {
+		name    string
+		urls    []string
+		wantErr bool
+	}{
+		{"up", []string{up.URL}, false},
+		{"down", []string{down.URL}, true},
+		{"fallback", []string{down.URL, up.URL}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := New(test.urls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Ping(ctx); (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/version"
+)
+
+// NewLocalClient returns a Client that reads modules from dir instead of
+// a proxy. The directory must be laid out like a module cache (see
+// "go help modules"): the files for a module version are read from
+// $dir/cache/download/<module>/@v/<version>.{info,mod,zip}, with the module
+// path and version escaped as described by "go help goproxy".
+//
+// The latest version of a module is the latest version in its @v/list file,
+// or, if there is no list file, the latest version that has an .info file.
+func NewLocalClient(dir string) (_ *Client, err error) {
+	defer derrors.Wrap(&err, "proxy.NewLocalClient(%q)", dir)
+
+	root, err := filepath.Abs(filepath.Join(dir, "cache", "download"))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	c, err := New([]string{"file://" + filepath.ToSlash(root)})
+	if err != nil {
+		return nil, err
+	}
+	c.httpClient = &http.Client{Transport: localTransport{}}
+	return c, nil
+}
+
+// localTransport is an http.RoundTripper that serves the module proxy
+// protocol from file URLs pointing into the download directory of a module
+// cache.
+type localTransport struct{}
+
+func (localTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "file" {
+		return nil, fmt.Errorf("unsupported URL scheme %q", req.URL.Scheme)
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return localResponse(req, http.StatusMethodNotAllowed, nil), nil
+	}
+	p := req.URL.Path
+	if strings.HasSuffix(p, "/@latest") {
+		v, err := localLatestVersion(path.Join(path.Dir(p), "@v"))
+		if err != nil {
+			return nil, err
+		}
+		if v == "" {
+			return localResponse(req, http.StatusNotFound, []byte("not found: no versions")), nil
+		}
+		p = path.Join(path.Dir(p), "@v", v+".info")
+	}
+	data, err := ioutil.ReadFile(filepath.FromSlash(p))
+	if errors.Is(err, os.ErrNotExist) {
+		return localResponse(req, http.StatusNotFound, []byte("not found: "+path.Base(p))), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return localResponse(req, http.StatusOK, data), nil
+}
+
+// localLatestVersion returns the escaped latest version of the module whose
+// @v directory is dir, or the empty string if there are no versions.
+func localLatestVersion(dir string) (_ string, err error) {
+	var versions []string
+	data, err := ioutil.ReadFile(filepath.Join(filepath.FromSlash(dir), "list"))
+	switch {
+	case err == nil:
+		versions = strings.Fields(string(data))
+	case errors.Is(err, os.ErrNotExist):
+		infos, err := filepath.Glob(filepath.Join(filepath.FromSlash(dir), "*.info"))
+		if err != nil {
+			return "", err
+		}
+		for _, f := range infos {
+			v, err := module.UnescapeVersion(strings.TrimSuffix(filepath.Base(f), ".info"))
+			if err != nil {
+				return "", err
+			}
+			versions = append(versions, v)
+		}
+	default:
+		return "", err
+	}
+	latest := version.LatestOf(versions)
+	if latest == "" {
+		return "", nil
+	}
+	return module.EscapeVersion(latest)
+}
+
+// localResponse returns a response to req with the given status and body.
+// The body is omitted for HEAD requests, but the content length is still set.
+func localResponse(req *http.Request, status int, body []byte) *http.Response {
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if req.Method == http.MethodHead {
+		body = nil
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return res
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestLocalClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	dir := t.TempDir()
+	const upperPath = "github.com/Upper/mod"
+	modules := []*Module{
+		{ModulePath: testModule.ModulePath, Version: "v1.1.0", Files: testModule.Files},
+		{ModulePath: testModule.ModulePath, Version: "v1.0.0", Files: testModule.Files},
+		{ModulePath: testModule.ModulePath, Version: "v1.2.0-pre", Files: testModule.Files},
+		{ModulePath: upperPath, Version: "v0.1.0", Files: map[string]string{"p.go": "package p"}},
+	}
+	if err := WriteModuleCache(dir, modules); err != nil {
+		t.Fatal(err)
+	}
+	// Remove the list file of the second module, so its latest version is
+	// computed from its .info files.
+	if err := os.Remove(filepath.Join(dir, "cache", "download", "github.com", "!upper", "mod", "@v", "list")); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewLocalClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("info", func(t *testing.T) {
+		for _, test := range []struct {
+			modulePath, version, want string
+		}{
+			{testModule.ModulePath, "v1.0.0", "v1.0.0"},
+			{testModule.ModulePath, internal.LatestVersion, "v1.1.0"},
+			{upperPath, internal.LatestVersion, "v0.1.0"},
+		} {
+			info, err := client.Info(ctx, test.modulePath, test.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Version != test.want {
+				t.Errorf("Info(%q, %q): got version %q, want %q", test.modulePath, test.version, info.Version, test.want)
+			}
+		}
+	})
+	t.Run("mod", func(t *testing.T) {
+		got, err := client.Mod(ctx, testModule.ModulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := testModule.Files["go.mod"]; string(got) != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+	t.Run("zip", func(t *testing.T) {
+		zr, err := client.Zip(ctx, upperPath, "v0.1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(zr.File) != 1 || zr.File[0].Name != upperPath+"@v0.1.0/p.go" {
+			t.Errorf("got unexpected zip contents %v", zr.File)
+		}
+		size, err := client.ZipSize(ctx, upperPath, "v0.1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(filepath.Join(dir, "cache", "download", "github.com", "!upper", "mod", "@v", "v0.1.0.zip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != fi.Size() {
+			t.Errorf("ZipSize: got %d, want %d", size, fi.Size())
+		}
+	})
+	t.Run("versions", func(t *testing.T) {
+		got, err := client.Versions(ctx, testModule.ModulePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"v1.1.0", "v1.0.0", "v1.2.0-pre"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("not found", func(t *testing.T) {
+		if _, err := client.Info(ctx, testModule.ModulePath, "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("Info: got %v, want NotFound", err)
+		}
+		if _, err := client.Info(ctx, "example.com/missing", internal.LatestVersion); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("Info latest: got %v, want NotFound", err)
+		}
+		if _, err := client.ZipSize(ctx, testModule.ModulePath, "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("ZipSize: got %v, want NotFound", err)
+		}
+	})
+
+	if _, err := NewLocalClient(filepath.Join(dir, "missing")); err == nil {
+		t.Error("NewLocalClient with missing directory: got nil error")
+	}
+}
@@ -11,7 +11,8 @@ type Module struct {
 	ModulePath string
 	Version    string
 	Files      map[string]string
-	NotCached  bool // if true, behaves like it's uncached
+	NotCached  bool    // if true, behaves like it's uncached
+	Origin     *Origin // if non-nil, served in the .info response
 	zip        []byte
 }
 
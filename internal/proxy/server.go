@@ -6,6 +6,7 @@ package proxy
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"sort"
@@ -38,14 +39,14 @@ func NewServer(modules []*Module) *Server {
 }
 
 // handleInfo creates an info endpoint for the specified module version.
-func (s *Server) handleInfo(modulePath, resolvedVersion string, uncached bool) {
-	urlPath := fmt.Sprintf("/%s/@v/%s.info", modulePath, resolvedVersion)
+func (s *Server) handleInfo(m *Module) {
+	urlPath := fmt.Sprintf("/%s/@v/%s.info", m.ModulePath, m.Version)
 	s.mux.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
-		if uncached && r.Header.Get(disableFetchHeader) == "true" {
+		if m.NotCached && r.Header.Get(disableFetchHeader) == "true" {
 			http.Error(w, "not found: temporarily unavailable", http.StatusGone)
 			return
 		}
-		http.ServeContent(w, r, modulePath, time.Now(), defaultInfo(resolvedVersion))
+		http.ServeContent(w, r, m.ModulePath, time.Now(), m.info())
 	})
 }
 
@@ -54,21 +55,13 @@ func (s *Server) handleInfo(modulePath, resolvedVersion string, uncached bool) {
 func (s *Server) handleLatest(modulePath, urlPath string) {
 	s.mux.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
 		modules := s.modules[modulePath]
-		resolvedVersion := modules[len(modules)-1].Version
-		http.ServeContent(w, r, modulePath, time.Now(), defaultInfo(resolvedVersion))
+		http.ServeContent(w, r, modulePath, time.Now(), modules[len(modules)-1].info())
 	})
 }
 
 // handleMod creates a mod endpoint for the specified module version.
 func (s *Server) handleMod(m *Module) {
-	defaultGoMod := func(modulePath string) string {
-		// defaultGoMod creates a bare-bones go.mod contents.
-		return fmt.Sprintf("module %s\n\ngo 1.12", modulePath)
-	}
-	goMod := m.Files["go.mod"]
-	if goMod == "" {
-		goMod = defaultGoMod(m.ModulePath)
-	}
+	goMod := m.goMod()
 	s.mux.HandleFunc(fmt.Sprintf("/%s/@v/%s.mod", m.ModulePath, m.Version),
 		func(w http.ResponseWriter, r *http.Request) {
 			http.ServeContent(w, r, m.ModulePath, time.Now(), strings.NewReader(goMod))
@@ -145,7 +138,7 @@ func (s *Server) addModule(m *Module, hasVersions bool) {
 			})
 		}
 	}
-	s.handleInfo(m.ModulePath, m.Version, m.NotCached)
+	s.handleInfo(m)
 	s.handleMod(m)
 	s.handleZip(m)
 
@@ -176,6 +169,32 @@ func cleanModule(m *Module) *Module {
 	return m
 }
 
+// goMod returns the contents of m's go.mod file, or bare-bones contents if m
+// has none.
+func (m *Module) goMod() string {
+	if goMod := m.Files["go.mod"]; goMod != "" {
+		return goMod
+	}
+	return fmt.Sprintf("module %s\n\ngo 1.12", m.ModulePath)
+}
+
 func defaultInfo(resolvedVersion string) *strings.Reader {
 	return strings.NewReader(fmt.Sprintf("{\n\t\"Version\": %q,\n\t\"Time\": %q\n}", resolvedVersion, versionTime))
 }
+
+// info returns the contents of m's .info file, which includes m.Origin if
+// it is set.
+func (m *Module) info() *strings.Reader {
+	if m.Origin == nil {
+		return defaultInfo(m.Version)
+	}
+	t, err := time.Parse(time.RFC3339, versionTime)
+	if err != nil {
+		panic(err)
+	}
+	data, err := json.MarshalIndent(&VersionInfo{Version: m.Version, Time: t, Origin: m.Origin}, "", "\t")
+	if err != nil {
+		panic(err)
+	}
+	return strings.NewReader(string(data))
+}
@@ -6,10 +6,13 @@ package proxy
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"golang.org/x/mod/module"
 	"golang.org/x/pkgsite/internal/testing/testhelper"
 	"golang.org/x/tools/txtar"
 )
@@ -34,7 +37,7 @@ func SetupTestClient(t *testing.T, modules []*Module) (*Client, func()) {
 func NewClientForServer(s *Server) (*Client, func(), error) {
 	// override client.httpClient to skip TLS verification
 	httpClient, proxy, serverClose := testhelper.SetupTestClientAndServer(s.mux)
-	client, err := New(proxy.URL)
+	client, err := New([]string{proxy.URL})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -42,6 +45,50 @@ func NewClientForServer(s *Server) (*Client, func(), error) {
 	return client, serverClose, nil
 }
 
+// WriteModuleCache writes the given modules to dir in the layout of a module
+// cache, so that they can be read by a Client returned from
+// NewLocalClient(dir). Each module is given a @v/list file listing all its
+// versions.
+func WriteModuleCache(dir string, modules []*Module) error {
+	lists := map[string][]string{}
+	for _, m := range modules {
+		m = cleanModule(m)
+		escPath, err := module.EscapePath(m.ModulePath)
+		if err != nil {
+			return err
+		}
+		escVersion, err := module.EscapeVersion(m.Version)
+		if err != nil {
+			return err
+		}
+		vdir := filepath.Join(dir, "cache", "download", filepath.FromSlash(escPath), "@v")
+		if err := os.MkdirAll(vdir, 0755); err != nil {
+			return err
+		}
+		info, err := ioutil.ReadAll(m.info())
+		if err != nil {
+			return err
+		}
+		for ext, data := range map[string][]byte{
+			".info": info,
+			".mod":  []byte(m.goMod()),
+			".zip":  m.zip,
+		} {
+			if err := ioutil.WriteFile(filepath.Join(vdir, escVersion+ext), data, 0644); err != nil {
+				return err
+			}
+		}
+		lists[vdir] = append(lists[vdir], m.Version)
+	}
+	for vdir, versions := range lists {
+		list := strings.Join(versions, "\n") + "\n"
+		if err := ioutil.WriteFile(filepath.Join(vdir, "list"), []byte(list), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // LoadTestModules reads the modules in the given directory. Each file in that
 // directory with a .txtar extension should be named "path@version" and should
 // be in txtar format (golang.org/x/tools/txtar). The path part of the filename
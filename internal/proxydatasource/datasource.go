@@ -212,6 +212,24 @@ func (ds *DataSource) GetLatestInfo(ctx context.Context, unitPath, modulePath st
 	return latest, nil
 }
 
+// GetLatestInfoBatch calls GetLatestInfo for each of paths.
+func (ds *DataSource) GetLatestInfoBatch(ctx context.Context, paths []internal.UnitModulePath) (_ map[internal.UnitModulePath]internal.LatestInfo, err error) {
+	defer derrors.Wrap(&err, "GetLatestInfoBatch(ctx, %d paths)", len(paths))
+
+	infos := map[internal.UnitModulePath]internal.LatestInfo{}
+	for _, p := range paths {
+		latest, err := ds.GetLatestInfo(ctx, p.Path, p.ModulePath)
+		if err != nil {
+			if errors.Is(err, derrors.NotFound) {
+				continue
+			}
+			return nil, err
+		}
+		infos[p] = latest
+	}
+	return infos, nil
+}
+
 // getLatestMajorVersion returns the latest module path and the full package path
 // of the latest version found in the proxy by iterating through vN versions.
 // This function does not attempt to find whether the full path exists
@@ -20,6 +20,7 @@ import (
 	"golang.org/x/pkgsite/internal/godoc/dochtml"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
 
@@ -89,6 +90,11 @@ func TestGetModuleInfo(t *testing.T) {
 		CommitTime:        time.Date(2019, 1, 30, 0, 0, 0, 0, time.UTC),
 		IsRedistributable: true,
 		HasGoMod:          true,
+		Origin: &source.Origin{
+			VCS: "git",
+			URL: "https://example.com/basic",
+			Ref: "refs/tags/v1.1.0",
+		},
 	}
 	got := modinfo("example.com/basic", "v1.1.0")
 	if diff := cmp.Diff(&wantModuleInfo, got, cmpOpts...); diff != "" {
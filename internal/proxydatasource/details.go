@@ -8,6 +8,7 @@ package proxydatasource
 
 import (
 	"context"
+	"sort"
 
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
@@ -75,7 +76,114 @@ func (ds *DataSource) GetNestedModules(ctx context.Context, modulePath string) (
 	return nil, nil
 }
 
+// GetSymbolUsers is unimplemented.
+func (ds *DataSource) GetSymbolUsers(ctx context.Context, pkgPath, symbol string, limit int) ([]string, error) {
+	return nil, nil
+}
+
+// GetPathRedirect is unimplemented.
+func (ds *DataSource) GetPathRedirect(ctx context.Context, fullPath string) (string, error) {
+	return "", nil
+}
+
+// GetModFile returns the go.mod file of the module version as fetched from
+// the proxy.
+func (ds *DataSource) GetModFile(ctx context.Context, modulePath, resolvedVersion string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "GetModFile(%q, %q)", modulePath, resolvedVersion)
+	m, err := ds.getModule(ctx, modulePath, resolvedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if m.GoModFile == nil {
+		return nil, derrors.NotFound
+	}
+	return m.GoModFile, nil
+}
+
+// GetUnitFiles returns the names of the files of the package at fullPath, as
+// recorded when the module version was fetched from the proxy.
+func (ds *DataSource) GetUnitFiles(ctx context.Context, fullPath, modulePath, resolvedVersion string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "GetUnitFiles(%q, %q, %q)", fullPath, modulePath, resolvedVersion)
+	u, err := ds.getUnit(ctx, fullPath, modulePath, resolvedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return u.Files, nil
+}
+
+// GetModulesByLicenseType is unimplemented.
+func (ds *DataSource) GetModulesByLicenseType(ctx context.Context, licenseType string, limit int, cursor string) ([]*internal.ModuleInfo, string, error) {
+	return nil, "", nil
+}
+
+// GetImportedByCount is unimplemented.
+func (ds *DataSource) GetImportedByCount(ctx context.Context, pkgPath, modulePath string) (int, error) {
+	return 0, nil
+}
+
 // GetModuleReadme is unimplemented.
 func (ds *DataSource) GetModuleReadme(ctx context.Context, modulePath, resolvedVersion string) (*internal.Readme, error) {
 	return nil, nil
 }
+
+// GetModuleBuildContexts returns the union of the build contexts of the
+// packages in the module.
+func (ds *DataSource) GetModuleBuildContexts(ctx context.Context, modulePath, resolvedVersion string) (_ []internal.BuildContext, err error) {
+	defer derrors.Wrap(&err, "GetModuleBuildContexts(%q, %q)", modulePath, resolvedVersion)
+	m, err := ds.getModule(ctx, modulePath, resolvedVersion)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[internal.BuildContext]bool{}
+	var bcs []internal.BuildContext
+	for _, u := range m.Units {
+		for _, d := range u.Documentation {
+			if bc := d.BuildContext(); !seen[bc] {
+				seen[bc] = true
+				bcs = append(bcs, bc)
+			}
+		}
+	}
+	internal.SortBuildContexts(bcs)
+	return bcs, nil
+}
+
+// GetUnitPaths returns the sorted paths of the packages in the given module
+// version that can be imported from outside it.
+func (ds *DataSource) GetUnitPaths(ctx context.Context, modulePath, resolvedVersion string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "GetUnitPaths(%q, %q)", modulePath, resolvedVersion)
+	m, err := ds.getModule(ctx, modulePath, resolvedVersion)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range m.Packages() {
+		if p.IsImportable {
+			paths = append(paths, p.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// GetSymbols returns the exported symbols of the package at path in the given
+// module version, keyed by build context.
+func (ds *DataSource) GetSymbols(ctx context.Context, path, modulePath, resolvedVersion string) (_ map[internal.BuildContext][]*internal.Symbol, err error) {
+	defer derrors.Wrap(&err, "GetSymbols(%q, %q, %q)", path, modulePath, resolvedVersion)
+	u, err := ds.getUnit(ctx, path, modulePath, resolvedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return internal.SymbolsByBuildContext(u.Documentation), nil
+}
+
+// GetRequirements returns the direct requirements of the module version as
+// listed in the go.mod file fetched from the proxy.
+func (ds *DataSource) GetRequirements(ctx context.Context, modulePath, resolvedVersion string) (_ []*internal.Requirement, err error) {
+	defer derrors.Wrap(&err, "GetRequirements(%q, %q)", modulePath, resolvedVersion)
+	m, err := ds.getModule(ctx, modulePath, resolvedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return m.Requirements, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+var (
+	queueDepth = stats.Int64(
+		"go-discovery/queue_depth",
+		"The number of tasks waiting for a worker in an in-memory queue.",
+		stats.UnitDimensionless,
+	)
+	// QueueDepth is the number of tasks waiting in an InMemory queue. The
+	// depth of a GCP queue is monitored by Cloud Tasks.
+	QueueDepth = &view.View{
+		Name:        "go-discovery/queue/depth",
+		Measure:     queueDepth,
+		Aggregation: view.LastValue(),
+		Description: "in-memory queue depth",
+	}
+
+	refetches = stats.Int64(
+		"go-discovery/worker_refetch_count",
+		"Fetches of a module version that the task queue is retrying.",
+		stats.UnitDimensionless,
+	)
+	// RefetchCount counts fetches that are retries of earlier ones, by
+	// either a GCP or an InMemory queue.
+	RefetchCount = &view.View{
+		Name:        "go-discovery/worker_refetch/count",
+		Measure:     refetches,
+		Aggregation: view.Count(),
+		Description: "fetch requests retried by the task queue",
+	}
+)
+
+func recordQueueDepth(ctx context.Context, depth int) {
+	stats.Record(ctx, queueDepth.M(int64(depth)))
+}
+
+// RecordRefetch records a fetch that is a retry of an earlier one.
+func RecordRefetch(ctx context.Context) {
+	stats.Record(ctx, refetches.M(1))
+}
@@ -7,17 +7,21 @@
 package queue
 
 import (
+	"container/heap"
 	"context"
 	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
 	"math"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
 	"github.com/golang/protobuf/ptypes"
+	"go.opencensus.io/trace"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
@@ -30,9 +34,31 @@ import (
 
 // A Queue provides an interface for asynchronous scheduling of fetch actions.
 type Queue interface {
-	ScheduleFetch(ctx context.Context, modulePath, version, suffix string, disableProxyFetch bool) (bool, error)
+	ScheduleFetch(ctx context.Context, modulePath, version, suffix string, disableProxyFetch bool, priority Priority) (bool, error)
 }
 
+// A Drainer is a Queue that can be told to stop dispatching tasks, so that
+// the process running them can shut down.
+type Drainer interface {
+	// Drain stops the queue from starting tasks. Tasks that have started are
+	// not affected, and tasks that have not are left in the queue.
+	Drain()
+}
+
+// Priority is the priority of a fetch task. Tasks with a higher priority are
+// processed before those with a lower one.
+type Priority int
+
+const (
+	// LowPriority is for bulk work, such as processing the module index or
+	// reprocessing modules.
+	LowPriority Priority = iota
+
+	// HighPriority is for fetches that a user is waiting on, such as those
+	// requested from the frontend.
+	HighPriority
+)
+
 // New creates a new Queue with name queueName based on the configuration
 // in cfg. When running locally, Queue uses numWorkers concurrent workers.
 func New(ctx context.Context, cfg *config.Config, queueName string, numWorkers int, expGetter middleware.ExperimentGetter, processFunc inMemoryProcessFunc) (Queue, error) {
@@ -67,7 +93,12 @@ func New(ctx context.Context, cfg *config.Config, queueName string, numWorkers i
 type GCP struct {
 	client    *cloudtasks.Client
 	queueName string // full GCP name of the queue
-	queueURL  string // non-AppEngine URL to post tasks to
+	// highPriorityQueueName is the full GCP name of the queue for
+	// HighPriority tasks. Cloud Tasks has no notion of task priority, so
+	// those tasks are put on a separate queue. If it is empty, they are put
+	// on the same queue as other tasks.
+	highPriorityQueueName string
+	queueURL              string // non-AppEngine URL to post tasks to
 	// token holds information that lets the task queue construct an authorized request to the worker.
 	// Since the worker sits behind the IAP, the queue needs an identity token that includes the
 	// identity of a service account that has access, and the client ID for the IAP.
@@ -98,10 +129,15 @@ func newGCP(cfg *config.Config, client *cloudtasks.Client, queueID string) (_ *G
 	if cfg.QueueAudience == "" {
 		return nil, errors.New("empty QueueAudience")
 	}
+	var highPriorityQueueName string
+	if cfg.HighPriorityQueueID != "" {
+		highPriorityQueueName = fmt.Sprintf("projects/%s/locations/%s/queues/%s", cfg.ProjectID, cfg.LocationID, cfg.HighPriorityQueueID)
+	}
 	return &GCP{
-		client:    client,
-		queueName: fmt.Sprintf("projects/%s/locations/%s/queues/%s", cfg.ProjectID, cfg.LocationID, queueID),
-		queueURL:  cfg.QueueURL,
+		client:                client,
+		queueName:             fmt.Sprintf("projects/%s/locations/%s/queues/%s", cfg.ProjectID, cfg.LocationID, queueID),
+		highPriorityQueueName: highPriorityQueueName,
+		queueURL:              cfg.QueueURL,
 		token: &taskspb.HttpRequest_OidcToken{
 			OidcToken: &taskspb.OidcToken{
 				ServiceAccountEmail: cfg.ServiceAccount,
@@ -114,7 +150,7 @@ func newGCP(cfg *config.Config, client *cloudtasks.Client, queueID string) (_ *G
 // ScheduleFetch enqueues a task on GCP to fetch the given modulePath and
 // version. It returns an error if there was an error hashing the task name, or
 // an error pushing the task to GCP. If the task was a duplicate, it returns (false, nil).
-func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, disableProxyFetch bool) (enqueued bool, err error) {
+func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, disableProxyFetch bool, priority Priority) (enqueued bool, err error) {
 	defer derrors.WrapStack(&err, "queue.ScheduleFetch(%q, %q, %q)", modulePath, version, suffix)
 
 	// Cloud Tasks enforces an RPC timeout of at most 30s. I couldn't find this
@@ -124,7 +160,7 @@ func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix str
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	req := q.newTaskRequest(modulePath, version, suffix, disableProxyFetch)
+	req := q.newTaskRequest(ctx, modulePath, version, suffix, disableProxyFetch, priority)
 	enqueued = true
 	if _, err := q.client.CreateTask(ctx, req); err != nil {
 		if status.Code(err) == codes.AlreadyExists {
@@ -146,25 +182,33 @@ const (
 	DisableProxyFetchValue = "off"
 )
 
-func (q *GCP) newTaskRequest(modulePath, version, suffix string, disableProxyFetch bool) *taskspb.CreateTaskRequest {
+// newTaskRequest returns a request to create a task that fetches modulePath
+// at version. The trace context of ctx, if any, is passed on to the worker in
+// the task's HTTP headers.
+func (q *GCP) newTaskRequest(ctx context.Context, modulePath, version, suffix string, disableProxyFetch bool, priority Priority) *taskspb.CreateTaskRequest {
 	taskID := newTaskID(modulePath, version)
 	relativeURI := fmt.Sprintf("/fetch/%s/@v/%s", modulePath, version)
 	if disableProxyFetch {
 		relativeURI += fmt.Sprintf("?%s=%s", DisableProxyFetchParam, DisableProxyFetchValue)
 	}
+	queueName := q.queueName
+	if priority >= HighPriority && q.highPriorityQueueName != "" {
+		queueName = q.highPriorityQueueName
+	}
 	task := &taskspb.Task{
-		Name:             fmt.Sprintf("%s/tasks/%s", q.queueName, taskID),
+		Name:             fmt.Sprintf("%s/tasks/%s", queueName, taskID),
 		DispatchDeadline: ptypes.DurationProto(maxCloudTasksTimeout),
 	}
 	task.MessageType = &taskspb.Task_HttpRequest{
 		HttpRequest: &taskspb.HttpRequest{
 			HttpMethod:          taskspb.HttpMethod_POST,
 			Url:                 q.queueURL + relativeURI,
+			Headers:             traceHeaders(ctx),
 			AuthorizationHeader: q.token,
 		},
 	}
 	req := &taskspb.CreateTaskRequest{
-		Parent: q.queueName,
+		Parent: queueName,
 		Task:   task,
 	}
 	// If suffix is non-empty, append it to the task name. This lets us force reprocessing
@@ -210,15 +254,133 @@ type moduleVersion struct {
 	modulePath, version string
 }
 
+// inMemoryTask is a task waiting in an InMemory queue.
+type inMemoryTask struct {
+	moduleVersion
+	priority Priority
+	seq      int // order of scheduling, to keep tasks of equal priority FIFO
+	attempt  int // number of times the task has been run before
+	// parent is the trace context of the request that scheduled the task,
+	// or nil if it wasn't traced.
+	parent *trace.SpanContext
+}
+
+// taskHeap is a heap of tasks, ordered by decreasing priority and then by
+// scheduling order. It implements heap.Interface.
+type taskHeap []*inMemoryTask
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *taskHeap) Push(x interface{}) { *h = append(*h, x.(*inMemoryTask)) }
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// DefaultMaxInMemoryPending is the maximum number of tasks waiting in an
+// InMemory queue, if InMemoryOptions.MaxPending is not set.
+const DefaultMaxInMemoryPending = 1000
+
+// An OverflowPolicy says what InMemory.ScheduleFetch does when the queue
+// already holds the maximum number of pending tasks.
+type OverflowPolicy int
+
+const (
+	// Block makes ScheduleFetch wait until a pending task is dispatched to a
+	// worker, or its context is done.
+	Block OverflowPolicy = iota
+	// Reject makes ScheduleFetch fail immediately with an error wrapping
+	// ErrQueueFull.
+	Reject
+)
+
+// ErrQueueFull is returned by InMemory.ScheduleFetch when the queue is full
+// and its OverflowPolicy is Reject.
+var ErrQueueFull = errors.New("queue is full")
+
+// DefaultMaxInMemoryAttempts is the number of times an InMemory queue runs a
+// task whose fetch fails with a retriable status, if
+// InMemoryOptions.MaxAttempts is not set.
+const DefaultMaxInMemoryAttempts = 3
+
+// DefaultInMemoryRetryDelay is how long an InMemory queue waits before
+// running a failed task again for the first time, if
+// InMemoryOptions.RetryDelay is not set. The delay doubles with each
+// attempt.
+const DefaultInMemoryRetryDelay = time.Second
+
+// InMemoryOptions configure an InMemory queue.
+type InMemoryOptions struct {
+	// MaxPending is the maximum number of tasks waiting for a worker. If it
+	// is zero, DefaultMaxInMemoryPending is used.
+	MaxPending int
+	// Overflow says what happens when MaxPending tasks are waiting.
+	Overflow OverflowPolicy
+	// MaxAttempts is the maximum number of times a task is run if its fetch
+	// keeps failing with a retriable status. If it is zero,
+	// DefaultMaxInMemoryAttempts is used. Use 1 to disable retries.
+	MaxAttempts int
+	// RetryDelay is the delay before the first retry of a task. If it is
+	// zero, DefaultInMemoryRetryDelay is used.
+	RetryDelay time.Duration
+}
+
+// isRetriable reports whether a fetch that finished with the given status
+// is worth trying again: the failure was transient, like a timeout or the
+// worker shedding load. Other statuses, like 404, 410 or those for bad and
+// alternative modules, will not change on retry.
+func isRetriable(status int) bool {
+	switch status {
+	case http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		derrors.ToStatus(derrors.ProxyTimedOut):
+		return true
+	}
+	return false
+}
+
 // InMemory is a Queue implementation that schedules in-process fetch
-// operations. Unlike the GCP task queue, it will not automatically retry tasks
-// on failure.
+// operations.
+//
+// Whenever a worker is free, it runs the pending task with the highest
+// priority, or the earliest scheduled one among those of equal priority.
+// Tasks whose fetch fails with a retriable status are scheduled again with
+// exponential backoff, up to a maximum number of attempts.
 //
 // This should only be used for local development.
 type InMemory struct {
-	queue       chan moduleVersion
+	mu    sync.Mutex
+	tasks taskHeap
+	seq   int
+
+	pending     chan struct{} // one value per task in tasks, bounding their number
+	ready       chan struct{} // signaled when a task is added
+	done        chan struct{} // closed by WaitForTesting
+	draining    chan struct{} // closed by Drain
+	drainOnce   sync.Once
+	overflow    OverflowPolicy
+	maxAttempts int
+	retryDelay  time.Duration
 	sem         chan struct{}
 	experiments []string
+
+	// active counts the tasks that are waiting, running, or waiting to be
+	// retried, so that WaitForTesting can wait for them.
+	active sync.WaitGroup
 }
 
 type inMemoryProcessFunc func(context.Context, string, string) (int, error)
@@ -227,49 +389,217 @@ type inMemoryProcessFunc func(context.Context, string, string) (int, error)
 // from proxyClient and stores in db. It uses workerCount parallelism to
 // execute these fetches.
 func NewInMemory(ctx context.Context, workerCount int, experiments []string, processFunc inMemoryProcessFunc) *InMemory {
+	return NewInMemoryWithOptions(ctx, workerCount, experiments, processFunc, InMemoryOptions{})
+}
+
+// NewInMemoryWithOptions is like NewInMemory, but bounds the number of pending
+// tasks and handles a full queue as described by opts.
+func NewInMemoryWithOptions(ctx context.Context, workerCount int, experiments []string, processFunc inMemoryProcessFunc, opts InMemoryOptions) *InMemory {
+	maxPending := opts.MaxPending
+	if maxPending <= 0 {
+		maxPending = DefaultMaxInMemoryPending
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxInMemoryAttempts
+	}
+	retryDelay := opts.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = DefaultInMemoryRetryDelay
+	}
 	q := &InMemory{
-		queue:       make(chan moduleVersion, 1000),
+		pending:     make(chan struct{}, maxPending),
+		overflow:    opts.Overflow,
+		maxAttempts: maxAttempts,
+		retryDelay:  retryDelay,
+		ready:       make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		draining:    make(chan struct{}),
 		sem:         make(chan struct{}, workerCount),
 		experiments: experiments,
 	}
 	go func() {
-		for v := range q.queue {
+		for {
+			// Wait for a task before waiting for a worker, so that idle
+			// workers are not held.
+			for q.len() == 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-q.done:
+					return
+				case <-q.draining:
+					return
+				case <-q.ready:
+				}
+			}
 			select {
 			case <-ctx.Done():
 				return
+			case <-q.done:
+				return
+			case <-q.draining:
+				return
 			case q.sem <- struct{}{}:
 			}
+			// Drain may have been called while waiting for a worker.
+			select {
+			case <-q.draining:
+				<-q.sem
+				return
+			default:
+			}
+			// Choose the task only once a worker is available, so that it is
+			// the highest-priority one at that time.
+			t := q.pop()
 
 			// If a worker is available, make a request to the fetch service inside a
 			// goroutine and wait for it to finish.
-			go func(v moduleVersion) {
+			go func(t *inMemoryTask) {
 				defer func() { <-q.sem }()
 
-				log.Infof(ctx, "Fetch requested: %q %q (workerCount = %d)", v.modulePath, v.version, cap(q.sem))
+				log.Infof(ctx, "Fetch requested: %q %q (workerCount = %d, attempt = %d)", t.modulePath, t.version, cap(q.sem), t.attempt+1)
 
 				fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 				fetchCtx = experiment.NewContext(fetchCtx, experiments...)
 				defer cancel()
+				if t.parent != nil {
+					var span *trace.Span
+					fetchCtx, span = trace.StartSpanWithRemoteParent(fetchCtx, "queue.InMemory.fetch", *t.parent)
+					defer span.End()
+				}
 
-				if _, err := processFunc(fetchCtx, v.modulePath, v.version); err != nil {
+				if t.attempt > 0 {
+					RecordRefetch(fetchCtx)
+				}
+				status, err := processFunc(fetchCtx, t.modulePath, t.version)
+				if err != nil {
 					log.Error(fetchCtx, err)
 				}
-			}(v)
+				if !isRetriable(status) || !q.retry(ctx, t) {
+					q.active.Done()
+				}
+			}(t)
 		}
 	}()
 	return q
 }
 
 // ScheduleFetch pushes a fetch task into the local queue to be processed
-// asynchronously.
-func (q *InMemory) ScheduleFetch(ctx context.Context, modulePath, version, _ string, _ bool) (bool, error) {
-	q.queue <- moduleVersion{modulePath, version}
+// asynchronously. If the queue is full, it blocks or fails according to the
+// queue's OverflowPolicy.
+func (q *InMemory) ScheduleFetch(ctx context.Context, modulePath, version, _ string, _ bool, priority Priority) (bool, error) {
+	if q.overflow == Reject {
+		select {
+		case q.pending <- struct{}{}:
+		default:
+			return false, fmt.Errorf("scheduling %s@%s: %w", modulePath, version, ErrQueueFull)
+		}
+	} else {
+		select {
+		case q.pending <- struct{}{}:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	t := &inMemoryTask{
+		moduleVersion: moduleVersion{modulePath, version},
+		priority:      priority,
+	}
+	if span := trace.FromContext(ctx); span != nil {
+		sc := span.SpanContext()
+		t.parent = &sc
+	}
+	q.active.Add(1)
+	q.push(t)
 	return true, nil
 }
 
-// WaitForTesting waits for all queued requests to finish. It should only be
-// used by test code.
-func (q InMemory) WaitForTesting(ctx context.Context) {
+// push adds t to the tasks and wakes the dispatcher. The caller must have
+// reserved a slot in q.pending.
+func (q *InMemory) push(t *inMemoryTask) {
+	q.mu.Lock()
+	t.seq = q.seq
+	q.seq++
+	heap.Push(&q.tasks, t)
+	depth := q.tasks.Len()
+	q.mu.Unlock()
+	recordQueueDepth(context.Background(), depth)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// retry schedules t to run again after a delay that doubles with each
+// attempt, unless it has been run the maximum number of times. It reports
+// whether t will be retried, in which case t stays active until the retry is
+// done. Retries are dropped if the queue stops in the meantime.
+func (q *InMemory) retry(ctx context.Context, t *inMemoryTask) bool {
+	t.attempt++
+	if t.attempt >= q.maxAttempts {
+		log.Errorf(ctx, "giving up on %s@%s after %d attempts", t.modulePath, t.version, t.attempt)
+		return false
+	}
+	delay := q.retryDelay << (t.attempt - 1)
+	log.Infof(ctx, "retrying %s@%s in %s (attempt %d of %d)", t.modulePath, t.version, delay, t.attempt+1, q.maxAttempts)
+	time.AfterFunc(delay, func() {
+		select {
+		case q.pending <- struct{}{}:
+			q.push(t)
+		case <-ctx.Done():
+			q.active.Done()
+		case <-q.done:
+			q.active.Done()
+		case <-q.draining:
+			q.active.Done()
+		}
+	})
+	return true
+}
+
+// Drain implements Drainer. Fetches that are running are not waited for.
+func (q *InMemory) Drain() {
+	q.drainOnce.Do(func() { close(q.draining) })
+}
+
+// Depth returns the number of tasks waiting for a worker.
+func (q *InMemory) Depth() int {
+	return q.len()
+}
+
+func (q *InMemory) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tasks.Len()
+}
+
+// pop removes and returns the next task to run. The queue must not be empty.
+func (q *InMemory) pop() *inMemoryTask {
+	q.mu.Lock()
+	t := heap.Pop(&q.tasks).(*inMemoryTask)
+	depth := q.tasks.Len()
+	q.mu.Unlock()
+	recordQueueDepth(context.Background(), depth)
+	<-q.pending
+	return t
+}
+
+// WaitForTesting waits for all queued requests to finish, including their
+// retries, and then stops the queue. It stops waiting if the queue is
+// drained, since drained tasks never run. It should only be used by test code.
+func (q *InMemory) WaitForTesting(ctx context.Context) {
+	idle := make(chan struct{})
+	go func() {
+		q.active.Wait()
+		close(idle)
+	}()
+	select {
+	case <-ctx.Done():
+		return
+	case <-q.draining:
+	case <-idle:
+	}
 	for i := 0; i < cap(q.sem); i++ {
 		select {
 		case <-ctx.Done():
@@ -277,5 +607,5 @@ func (q InMemory) WaitForTesting(ctx context.Context) {
 		case q.sem <- struct{}{}:
 		}
 	}
-	close(q.queue)
+	close(q.done)
 }
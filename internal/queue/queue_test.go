@@ -5,10 +5,17 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
 	"golang.org/x/pkgsite/internal/config"
 	taskspb "google.golang.org/genproto/googleapis/cloud/tasks/v2"
 	"google.golang.org/protobuf/proto"
@@ -60,17 +67,237 @@ func TestNewTaskRequest(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	got := gcp.newTaskRequest("mod", "v1.2.3", "suf", false)
+	got := gcp.newTaskRequest(context.Background(), "mod", "v1.2.3", "suf", false, LowPriority)
 	want.Task.Name = got.Task.Name
 	if diff := cmp.Diff(want, got, cmp.Comparer(proto.Equal)); diff != "" {
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
 	}
 
 	want.Task.MessageType.(*taskspb.Task_HttpRequest).HttpRequest.Url += "?proxyfetch=off"
-	got = gcp.newTaskRequest("mod", "v1.2.3", "suf", true)
+	got = gcp.newTaskRequest(context.Background(), "mod", "v1.2.3", "suf", true, LowPriority)
 	want.Task.Name = got.Task.Name
 	if diff := cmp.Diff(want, got, cmp.Comparer(proto.Equal)); diff != "" {
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
 	}
 
+	// Without a high-priority queue, high-priority tasks use the same queue.
+	got = gcp.newTaskRequest(context.Background(), "mod", "v1.2.3", "suf", true, HighPriority)
+	want.Task.Name = got.Task.Name
+	if diff := cmp.Diff(want, got, cmp.Comparer(proto.Equal)); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	cfg.HighPriorityQueueID = "highID"
+	gcp, err = newGCP(&cfg, nil, "queueID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = gcp.newTaskRequest(context.Background(), "mod", "v1.2.3", "suf", true, HighPriority)
+	want.Parent = "projects/Project/locations/us-central1/queues/highID"
+	if !strings.HasPrefix(got.Task.Name, want.Parent+"/tasks/") {
+		t.Errorf("got task name %q, want it in queue %q", got.Task.Name, want.Parent)
+	}
+	want.Task.Name = got.Task.Name
+	if diff := cmp.Diff(want, got, cmp.Comparer(proto.Equal)); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestInMemoryPriority(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		got     []string
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	q := NewInMemory(ctx, 1, nil, func(ctx context.Context, modulePath, version string) (int, error) {
+		if modulePath == "first" {
+			// Keep the only worker busy while the other tasks are scheduled.
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		got = append(got, modulePath)
+		mu.Unlock()
+		return 200, nil
+	})
+	schedule := func(modulePath string, p Priority) {
+		t.Helper()
+		if _, err := q.ScheduleFetch(ctx, modulePath, "v1.0.0", "", false, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schedule("first", LowPriority)
+	<-started
+	schedule("low1", LowPriority)
+	schedule("low2", LowPriority)
+	schedule("high1", HighPriority)
+	schedule("high2", HighPriority)
+	close(release)
+
+	// Wait for all tasks to be processed before checking the order.
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 5 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out; processed %v", got)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	q.WaitForTesting(ctx)
+	want := []string{"first", "high1", "high2", "low1", "low2"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestInMemoryOverflow(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy OverflowPolicy
+	}{
+		{"block", Block},
+		{"reject", Reject},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			q := NewInMemoryWithOptions(ctx, 1, nil, func(ctx context.Context, modulePath, version string) (int, error) {
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+				<-release
+				return 200, nil
+			}, InMemoryOptions{MaxPending: 2, Overflow: test.policy})
+
+			schedule := func(ctx context.Context, modulePath string) error {
+				_, err := q.ScheduleFetch(ctx, modulePath, "v1.0.0", "", false, LowPriority)
+				return err
+			}
+			// Occupy the only worker, then fill the queue.
+			if err := schedule(ctx, "running"); err != nil {
+				t.Fatal(err)
+			}
+			<-started
+			for _, m := range []string{"pending1", "pending2"} {
+				if err := schedule(ctx, m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got, want := q.Depth(), 2; got != want {
+				t.Fatalf("Depth() = %d, want %d", got, want)
+			}
+
+			switch test.policy {
+			case Reject:
+				if err := schedule(ctx, "overflow"); !errors.Is(err, ErrQueueFull) {
+					t.Errorf("got error %v, want ErrQueueFull", err)
+				}
+			case Block:
+				shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+				defer shortCancel()
+				if err := schedule(shortCtx, "overflow"); !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("got error %v, want context.DeadlineExceeded", err)
+				}
+				// Once the worker is free, a blocked ScheduleFetch succeeds.
+				errc := make(chan error, 1)
+				go func() { errc <- schedule(ctx, "overflow") }()
+				close(release)
+				if err := <-errc; err != nil {
+					t.Errorf("got error %v after the queue drained, want nil", err)
+				}
+				q.WaitForTesting(ctx)
+				return
+			}
+			close(release)
+			q.WaitForTesting(ctx)
+		})
+	}
+}
+
+func TestInMemoryRetry(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		statuses  []int // returned by successive attempts
+		wantCalls int
+	}{
+		{"retriable then success", []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK}, 3},
+		{"terminal", []int{http.StatusNotFound}, 1},
+		{"alternative module", []int{491}, 1},
+		{"too many attempts", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}, 3},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := view.Register(RefetchCount); err != nil {
+				t.Fatal(err)
+			}
+			defer view.Unregister(RefetchCount)
+
+			var (
+				mu    sync.Mutex
+				calls int
+			)
+			q := NewInMemoryWithOptions(ctx, 1, nil, func(ctx context.Context, modulePath, version string) (int, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				status := test.statuses[calls]
+				calls++
+				return status, nil
+			}, InMemoryOptions{MaxAttempts: 3, RetryDelay: time.Millisecond})
+			if _, err := q.ScheduleFetch(ctx, "m", "v1.0.0", "", false, LowPriority); err != nil {
+				t.Fatal(err)
+			}
+			// WaitForTesting waits for the retries too.
+			q.WaitForTesting(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if calls != test.wantCalls {
+				t.Errorf("got %d calls, want %d", calls, test.wantCalls)
+			}
+			var refetches int64
+			rows, err := view.RetrieveData(RefetchCount.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range rows {
+				refetches += row.Data.(*view.CountData).Value
+			}
+			if want := int64(test.wantCalls - 1); refetches != want {
+				t.Errorf("got %d refetches, want %d", refetches, want)
+			}
+		})
+	}
+}
+
+func TestIsRetriable(t *testing.T) {
+	for _, test := range []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, false},
+		{http.StatusGone, false},
+		{491, false}, // alternative module
+		{492, false}, // module too large
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+		{550, true}, // proxy timed out
+	} {
+		if got := isRetriable(test.status); got != test.want {
+			t.Errorf("isRetriable(%d) = %t, want %t", test.status, got, test.want)
+		}
+	}
 }
@@ -0,0 +1,41 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// traceFormat is the format in which the trace context of the request that
+// scheduled a fetch is passed to the worker. It is the default format of
+// ochttp.Handler, which serves the worker's requests.
+var traceFormat propagation.HTTPFormat = &b3.HTTPFormat{}
+
+// traceHeaders returns the HTTP headers that carry the trace context of the
+// span in ctx, or nil if ctx has no span.
+func traceHeaders(ctx context.Context) map[string]string {
+	span := trace.FromContext(ctx)
+	if span == nil {
+		return nil
+	}
+	r := &http.Request{Header: http.Header{}}
+	traceFormat.SpanContextToRequest(span.SpanContext(), r)
+	headers := map[string]string{}
+	for k := range r.Header {
+		headers[k] = r.Header.Get(k)
+	}
+	return headers
+}
+
+// SpanContextFromRequest returns the trace context of the request that
+// scheduled the fetch in r, if there is one.
+func SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	return traceFormat.SpanContextFromRequest(r)
+}
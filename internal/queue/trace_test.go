@@ -0,0 +1,92 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+	taskspb "google.golang.org/genproto/googleapis/cloud/tasks/v2"
+)
+
+func TestNewTaskRequestTraceContext(t *testing.T) {
+	cfg := config.Config{
+		ProjectID:      "Project",
+		LocationID:     "us-central1",
+		QueueURL:       "http://1.2.3.4:8000",
+		ServiceAccount: "sa",
+		QueueAudience:  "qa",
+	}
+	gcp, err := newGCP(&cfg, nil, "queueID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, span := trace.StartSpan(context.Background(), "enqueue", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+	req := gcp.newTaskRequest(ctx, "mod", "v1.2.3", "", false, LowPriority)
+
+	// Dispatch the task's headers as Cloud Tasks would.
+	r, err := http.NewRequest(http.MethodPost, "http://worker/fetch/mod/@v/v1.2.3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range req.Task.MessageType.(*taskspb.Task_HttpRequest).HttpRequest.Headers {
+		r.Header.Set(k, v)
+	}
+	got, ok := SpanContextFromRequest(r)
+	if !ok {
+		t.Fatalf("no trace context in task headers %v", r.Header)
+	}
+	if want := span.SpanContext(); got.TraceID != want.TraceID || got.SpanID != want.SpanID || !got.IsSampled() {
+		t.Errorf("got span context %+v, want %+v", got, want)
+	}
+}
+
+func TestInMemoryTraceContext(t *testing.T) {
+	rec := &testhelper.SpanRecorder{}
+	trace.RegisterExporter(rec)
+	defer trace.UnregisterExporter(rec)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fetched := make(chan struct{})
+	q := NewInMemory(ctx, 1, nil, func(ctx context.Context, modulePath, version string) (int, error) {
+		_, span := trace.StartSpan(ctx, "fetch")
+		span.End()
+		close(fetched)
+		return http.StatusOK, nil
+	})
+	enqueueCtx, enqueueSpan := trace.StartSpan(ctx, "enqueue", trace.WithSampler(trace.AlwaysSample()))
+	if _, err := q.ScheduleFetch(enqueueCtx, "m", "v1.0.0", "", false, HighPriority); err != nil {
+		t.Fatal(err)
+	}
+	enqueueSpan.End()
+	select {
+	case <-fetched:
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+	q.WaitForTesting(ctx)
+
+	dispatch := rec.Find("queue.InMemory.fetch")
+	fetch := rec.Find("fetch")
+	if dispatch == nil || fetch == nil {
+		t.Fatalf("missing spans; got %d spans", len(rec.Spans()))
+	}
+	want := enqueueSpan.SpanContext()
+	if dispatch.TraceID != want.TraceID || dispatch.ParentSpanID != want.SpanID {
+		t.Errorf("dispatch span has trace %s and parent %s, want %s and %s",
+			dispatch.TraceID, dispatch.ParentSpanID, want.TraceID, want.SpanID)
+	}
+	if fetch.ParentSpanID != dispatch.SpanID {
+		t.Errorf("fetch span has parent %s, want %s", fetch.ParentSpanID, dispatch.SpanID)
+	}
+}
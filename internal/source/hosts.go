@@ -0,0 +1,171 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A HostTemplate describes how to build source URLs for the modules on a code
+// host that this package does not otherwise know about, such as a self-hosted
+// Gitea or GitLab instance.
+//
+// The URL templates use the variables described at urlTemplates. If Kind is
+// set, the templates of that kind of host are used for the ones that are
+// empty.
+type HostTemplate struct {
+	// Prefix is a host, like "git.example.com", optionally followed by a
+	// path, like "git.example.com/team". A module path matches if it is
+	// Prefix or begins with Prefix followed by a slash.
+	Prefix string
+
+	// RepoPathElements is the number of path elements after Prefix that name
+	// a repository. The default is 2, for hosts whose repositories are named
+	// OWNER/REPO.
+	RepoPathElements int `json:",omitempty"`
+
+	// Kind is the kind of code host: one of "github", "gitlab", "gitea" or
+	// "bitbucket". It is optional.
+	Kind string `json:",omitempty"`
+
+	Repo      string `json:",omitempty"`
+	Directory string `json:",omitempty"`
+	File      string `json:",omitempty"`
+	Line      string `json:",omitempty"`
+	LineRange string `json:",omitempty"`
+	Raw       string `json:",omitempty"`
+}
+
+// hostKinds are the values of HostTemplate.Kind.
+var hostKinds = map[string]struct {
+	templates       urlTemplates
+	transformCommit transformCommitFunc
+}{
+	"github":    {templates: githubURLTemplates},
+	"gitlab":    {templates: gitlabURLTemplates},
+	"gitea":     {giteaURLTemplates, giteaTransformCommit},
+	"bitbucket": {templates: bitbucketURLTemplates},
+}
+
+// hostTemplate is a validated HostTemplate.
+type hostTemplate struct {
+	prefix          string
+	repoElems       int
+	templates       urlTemplates
+	transformCommit transformCommitFunc
+}
+
+var (
+	hostTemplatesMu sync.RWMutex
+	// hostTemplates are the registered templates, longest prefix first.
+	hostTemplates []*hostTemplate
+)
+
+// RegisterHostTemplates makes ModuleInfo use ts for the modules they match,
+// in preference to the hosts it knows about. It replaces any previously
+// registered templates. If more than one template matches a module path,
+// the one with the longest prefix is used.
+func RegisterHostTemplates(ts []*HostTemplate) (err error) {
+	defer derrors.Wrap(&err, "RegisterHostTemplates")
+
+	var hts []*hostTemplate
+	for _, t := range ts {
+		ht, err := t.validate()
+		if err != nil {
+			return err
+		}
+		hts = append(hts, ht)
+	}
+	sort.SliceStable(hts, func(i, j int) bool {
+		return len(hts[i].prefix) > len(hts[j].prefix)
+	})
+	hostTemplatesMu.Lock()
+	defer hostTemplatesMu.Unlock()
+	hostTemplates = hts
+	return nil
+}
+
+// ReadHostTemplates reads a list of HostTemplates from a YAML or JSON file.
+func ReadHostTemplates(filename string) (_ []*HostTemplate, err error) {
+	defer derrors.Wrap(&err, "ReadHostTemplates(%q)", filename)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var ts []*HostTemplate
+	if err := yaml.Unmarshal(data, &ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func (t *HostTemplate) validate() (*hostTemplate, error) {
+	prefix := strings.TrimSuffix(t.Prefix, "/")
+	if prefix == "" || strings.Contains(prefix, "://") {
+		return nil, fmt.Errorf("%w: bad prefix %q", derrors.InvalidArgument, t.Prefix)
+	}
+	ht := &hostTemplate{prefix: prefix, repoElems: t.RepoPathElements}
+	if ht.repoElems == 0 {
+		ht.repoElems = 2
+	}
+	if ht.repoElems < 0 {
+		return nil, fmt.Errorf("%w: %s: negative RepoPathElements", derrors.InvalidArgument, prefix)
+	}
+	if t.Kind != "" {
+		k, ok := hostKinds[t.Kind]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s: unknown kind %q", derrors.InvalidArgument, prefix, t.Kind)
+		}
+		ht.templates = k.templates
+		ht.transformCommit = k.transformCommit
+	}
+	for _, f := range []struct {
+		field *string
+		val   string
+	}{
+		{&ht.templates.Repo, t.Repo},
+		{&ht.templates.Directory, t.Directory},
+		{&ht.templates.File, t.File},
+		{&ht.templates.Line, t.Line},
+		{&ht.templates.LineRange, t.LineRange},
+		{&ht.templates.Raw, t.Raw},
+	} {
+		if f.val != "" {
+			*f.field = f.val
+		}
+	}
+	if ht.templates.Directory == "" || ht.templates.File == "" || ht.templates.Line == "" {
+		return nil, fmt.Errorf("%w: %s: missing Kind or Directory, File and Line templates", derrors.InvalidArgument, prefix)
+	}
+	return ht, nil
+}
+
+// matchHostTemplates matches modulePath against the registered HostTemplates.
+// It returns the same values as matchStatic.
+func matchHostTemplates(modulePath string) (repo, relativeModulePath string, _ urlTemplates, transformCommit transformCommitFunc, _ error) {
+	hostTemplatesMu.RLock()
+	defer hostTemplatesMu.RUnlock()
+	for _, ht := range hostTemplates {
+		if modulePath != ht.prefix && !strings.HasPrefix(modulePath, ht.prefix+"/") {
+			continue
+		}
+		elems := strings.Split(strings.TrimPrefix(modulePath, ht.prefix), "/")[1:]
+		if len(elems) < ht.repoElems {
+			continue
+		}
+		repo = strings.Join(append([]string{ht.prefix}, elems[:ht.repoElems]...), "/")
+		relativeModulePath = strings.Join(elems[ht.repoElems:], "/")
+		return repo, relativeModulePath, ht.templates, ht.transformCommit, nil
+	}
+	return "", "", urlTemplates{}, nil, derrors.NotFound
+}
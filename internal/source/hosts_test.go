@@ -0,0 +1,151 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestHostTemplates(t *testing.T) {
+	const config = `
+- prefix: code.fake.test
+  directory: "{repo}/browse/{commit}/{dir}"
+  file: "{repo}/browse/{commit}/{file}"
+  line: "{repo}/browse/{commit}/{file}#line{line}"
+  raw: "{repo}/download/{commit}/{file}"
+- prefix: gitea.fake.test
+  kind: gitea
+- prefix: gitea.fake.test/mono
+  repoPathElements: 0
+  kind: gitlab
+- prefix: github.com/corp
+  repoPathElements: 1
+  kind: gitlab
+`
+	filename := filepath.Join(t.TempDir(), "templates.yaml")
+	if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := ReadHostTemplates(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterHostTemplates(ts); err != nil {
+		t.Fatal(err)
+	}
+	defer RegisterHostTemplates(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	client := NewClientForTesting()
+
+	type urls struct {
+		Repo, Dir, File, Line, Raw string
+	}
+	for _, test := range []struct {
+		modulePath, version string
+		want                urls
+	}{
+		{
+			"code.fake.test/team/repo/sub", "v1.2.3",
+			urls{
+				Repo: "https://code.fake.test/team/repo",
+				Dir:  "https://code.fake.test/team/repo/browse/sub/v1.2.3/sub/dir",
+				File: "https://code.fake.test/team/repo/browse/sub/v1.2.3/sub/dir/f.go",
+				Line: "https://code.fake.test/team/repo/browse/sub/v1.2.3/sub/dir/f.go#line7",
+				Raw:  "https://code.fake.test/team/repo/download/sub/v1.2.3/sub/dir/f.go",
+			},
+		},
+		{
+			"gitea.fake.test/team/repo", "v0.0.0-20210101000000-0123456789ab",
+			urls{
+				Repo: "https://gitea.fake.test/team/repo",
+				Dir:  "https://gitea.fake.test/team/repo/src/commit/0123456789ab/dir",
+				File: "https://gitea.fake.test/team/repo/src/commit/0123456789ab/dir/f.go",
+				Line: "https://gitea.fake.test/team/repo/src/commit/0123456789ab/dir/f.go#L7",
+				Raw:  "https://gitea.fake.test/team/repo/raw/commit/0123456789ab/dir/f.go",
+			},
+		},
+		{
+			// The longest matching prefix wins. RepoPathElements of 0 means
+			// the default, 2.
+			"gitea.fake.test/mono/a/b", "v1.0.0",
+			urls{
+				Repo: "https://gitea.fake.test/mono/a/b",
+				Dir:  "https://gitea.fake.test/mono/a/b/-/tree/v1.0.0/dir",
+				File: "https://gitea.fake.test/mono/a/b/-/blob/v1.0.0/dir/f.go",
+				Line: "https://gitea.fake.test/mono/a/b/-/blob/v1.0.0/dir/f.go#L7",
+				Raw:  "https://gitea.fake.test/mono/a/b/-/raw/v1.0.0/dir/f.go",
+			},
+		},
+		{
+			// Registered templates take precedence over the built-in ones.
+			"github.com/corp/repo/sub", "v1.0.0",
+			urls{
+				Repo: "https://github.com/corp/repo",
+				Dir:  "https://github.com/corp/repo/-/tree/sub/v1.0.0/sub/dir",
+				File: "https://github.com/corp/repo/-/blob/sub/v1.0.0/sub/dir/f.go",
+				Line: "https://github.com/corp/repo/-/blob/sub/v1.0.0/sub/dir/f.go#L7",
+				Raw:  "https://github.com/corp/repo/-/raw/sub/v1.0.0/sub/dir/f.go",
+			},
+		},
+		{
+			// Other paths are unaffected.
+			"github.com/other/repo", "v1.0.0",
+			urls{
+				Repo: "https://github.com/other/repo",
+				Dir:  "https://github.com/other/repo/tree/v1.0.0/dir",
+				File: "https://github.com/other/repo/blob/v1.0.0/dir/f.go",
+				Line: "https://github.com/other/repo/blob/v1.0.0/dir/f.go#L7",
+				Raw:  "https://github.com/other/repo/raw/v1.0.0/dir/f.go",
+			},
+		},
+	} {
+		t.Run(test.modulePath, func(t *testing.T) {
+			info, err := ModuleInfo(ctx, client, test.modulePath, test.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := urls{
+				Repo: info.RepoURL(),
+				Dir:  info.DirectoryURL("dir"),
+				File: info.FileURL("dir/f.go"),
+				Line: info.LineURL("dir/f.go", 7),
+				Raw:  info.RawURL("dir/f.go"),
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegisterHostTemplatesErrors(t *testing.T) {
+	defer RegisterHostTemplates(nil)
+	for _, test := range []struct {
+		name string
+		t    *HostTemplate
+	}{
+		{"no prefix", &HostTemplate{Kind: "github"}},
+		{"scheme", &HostTemplate{Prefix: "https://git.fake.test", Kind: "github"}},
+		{"unknown kind", &HostTemplate{Prefix: "git.fake.test", Kind: "svn"}},
+		{"no templates", &HostTemplate{Prefix: "git.fake.test", Raw: "{repo}/raw/{commit}/{file}"}},
+		{"negative elements", &HostTemplate{Prefix: "git.fake.test", Kind: "github", RepoPathElements: -1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := RegisterHostTemplates([]*HostTemplate{test.t})
+			if !errors.Is(err, derrors.InvalidArgument) {
+				t.Errorf("got %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
@@ -63,6 +63,54 @@ func (i *Info) RepoURL() string {
 	})
 }
 
+// Origin describes the version control repository that a module version
+// comes from.
+type Origin struct {
+	VCS    string // version control system, like "git" or "hg"
+	URL    string // URL of the repository root
+	Ref    string // reference the version is at, like "refs/tags/v1.2.3"
+	Subdir string // directory of the module relative to the repository root
+}
+
+// gitURLTemplates are the URL templates of hosting sites that only serve git
+// repositories.
+var gitURLTemplates = []urlTemplates{
+	githubURLTemplates,
+	bitbucketURLTemplates,
+	giteaURLTemplates,
+	googlesourceURLTemplates,
+	gitlabURLTemplates,
+	fdioURLTemplates,
+}
+
+// InferOrigin returns the origin of the given version of the module, as far
+// as it can be inferred from i. The VCS is only known for repositories on
+// hosting sites that serve git alone, and the Ref only for versions that
+// correspond to a tag in such a repository, since refs/tags is a git
+// convention. InferOrigin returns nil if i is nil.
+func (i *Info) InferOrigin(version string) *Origin {
+	if i == nil {
+		return nil
+	}
+	o := &Origin{URL: i.repoURL, Subdir: i.moduleDir}
+	for _, t := range gitURLTemplates {
+		if i.templates.Directory == t.Directory {
+			o.VCS = "git"
+			break
+		}
+	}
+	if o.VCS == "" {
+		return o
+	}
+	if i.repoURL == stdlib.GoSourceRepoURL {
+		// The commit of the standard library is always a tag.
+		o.Ref = "refs/tags/" + i.commit
+	} else if commit, isHash := commitFromVersion(version, i.moduleDir); !isHash {
+		o.Ref = "refs/tags/" + commit
+	}
+	return o
+}
+
 // ModuleURL returns a URL for the home page of the module.
 func (i *Info) ModuleURL() string {
 	return i.DirectoryURL("")
@@ -112,6 +160,29 @@ func (i *Info) LineURL(pathname string, line int) string {
 	})
 }
 
+// LineRangeURL returns a URL referring to the lines start through end of a
+// file relative to the module's home directory. If the code host has no
+// format for ranges of lines, or end is not after start, it returns the URL of
+// the start line.
+func (i *Info) LineRangeURL(pathname string, start, end int) string {
+	if i == nil {
+		return ""
+	}
+	if i.templates.LineRange == "" || end <= start {
+		return i.LineURL(pathname, start)
+	}
+	dir, base := path.Split(pathname)
+	return expand(i.templates.LineRange, map[string]string{
+		"repo":       i.repoURL,
+		"importPath": path.Join(strings.TrimPrefix(i.repoURL, "https://"), dir),
+		"commit":     i.commit,
+		"file":       path.Join(i.moduleDir, pathname),
+		"base":       base,
+		"line":       strconv.Itoa(start),
+		"endLine":    strconv.Itoa(end),
+	})
+}
+
 // RawURL returns a URL referring to the raw contents of a file relative to the
 // module's home directory.
 func (i *Info) RawURL(pathname string) string {
@@ -142,7 +213,7 @@ func (i *Info) RawURL(pathname string) string {
 // map of common urlTemplates
 var urlTemplatesByKind = map[string]urlTemplates{
 	"github":    githubURLTemplates,
-	"gitlab":    githubURLTemplates, // preserved for backwards compatibility (DB still has source_info->Kind = "gitlab")
+	"gitlab":    gitlabURLTemplates,
 	"bitbucket": bitbucketURLTemplates,
 }
 
@@ -173,12 +244,6 @@ func (i *Info) MarshalJSON() (_ []byte, err error) {
 			break
 		}
 	}
-	// We used to use different templates for GitHub and GitLab. Now that
-	// they're the same, prefer "github" for consistency (map random iteration
-	// order means we could get either here).
-	if ji.Kind == "gitlab" {
-		ji.Kind = "github"
-	}
 	if ji.Kind == "" && i.templates != (urlTemplates{}) {
 		ji.Templates = &i.templates
 	}
@@ -213,7 +278,7 @@ type Client struct {
 func NewClient(timeout time.Duration) *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Transport: &ochttp.Transport{},
+			Transport: &log.RequestIDTransport{Base: &ochttp.Transport{}},
 			Timeout:   timeout,
 		},
 	}
@@ -252,7 +317,8 @@ func (c *Client) doURL(ctx context.Context, method, url string, only200 bool) (_
 
 // ModuleInfo determines the repository corresponding to the module path. It
 // returns a URL to that repo, as well as the directory of the module relative
-// to the repo root.
+// to the repo root. Templates registered with RegisterHostTemplates take
+// precedence over the hosts that are known to this package.
 //
 // ModuleInfo may fetch from arbitrary URLs, so it can be slow.
 func ModuleInfo(ctx context.Context, client *Client, modulePath, version string) (info *Info, err error) {
@@ -270,7 +336,10 @@ func ModuleInfo(ctx context.Context, client *Client, modulePath, version string)
 	if modulePath == stdlib.ModulePath {
 		return newStdlibInfo(version)
 	}
-	repo, relativeModulePath, templates, transformCommit, err := matchStatic(modulePath)
+	repo, relativeModulePath, templates, transformCommit, err := matchHostTemplates(modulePath)
+	if err != nil {
+		repo, relativeModulePath, templates, transformCommit, err = matchStatic(modulePath)
+	}
 	if err != nil {
 		info, err = moduleInfoDynamic(ctx, client, modulePath, version)
 		if err != nil {
@@ -443,7 +512,7 @@ var legacyTemplateMatches = []struct {
 	},
 	{
 		regexp.MustCompile(`/-/blob/\w+\{/dir\}/\{file\}#L\{line\}$`),
-		gitlab2URLTemplates, nil,
+		gitlabURLTemplates, nil,
 	},
 	{
 		regexp.MustCompile(`/tree\{/dir\}/\{file\}#n\{line\}$`),
@@ -554,12 +623,12 @@ var patterns = []struct {
 	},
 	{
 		pattern:   `^(?P<repo>gitlab\.com/[a-z0-9A-Z_.\-]+/[a-z0-9A-Z_.\-]+)`,
-		templates: githubURLTemplates,
+		templates: gitlabURLTemplates,
 	},
 	{
 		// Assume that any site beginning with "gitlab." works like gitlab.com.
 		pattern:   `^(?P<repo>gitlab\.[a-z0-9A-Z.-]+/[a-z0-9A-Z_.\-]+/[a-z0-9A-Z_.\-]+)(\.git|$)`,
-		templates: githubURLTemplates,
+		templates: gitlabURLTemplates,
 	},
 	{
 		pattern:   `^(?P<repo>gitee\.com/[a-z0-9A-Z_.\-]+/[a-z0-9A-Z_.\-]+)(\.git|$)`,
@@ -581,7 +650,7 @@ var patterns = []struct {
 	},
 	{
 		pattern:   `^(?P<repo>git\.pirl\.io/[a-z0-9A-Z_.\-]+/[a-z0-9A-Z_.\-]+)`,
-		templates: gitlab2URLTemplates,
+		templates: gitlabURLTemplates,
 	},
 	{
 		pattern:         `^(?P<repo>gitea\.com/[a-z0-9A-Z_.\-]+/[a-z0-9A-Z_.\-]+)(\.git|$)`,
@@ -692,12 +761,14 @@ func fdioTransformCommit(commit string, isHash bool) string {
 // 	• {file}       - Path to file containing the identifier, relative to repo root ("mypkg/file.go").
 // 	• {base}       - Base name of file containing the identifier, including file extension ("file.go").
 // 	• {line}       - Line number for the identifier ("41").
+// 	• {endLine}    - Last line number of a range of lines ("45").
 //
 type urlTemplates struct {
 	Repo      string `json:",omitempty"` // Optional URL template for the repository home page, with {repo}. If left empty, a default template "{repo}" is used.
 	Directory string // URL template for a directory, with {repo}, {importPath}, {commit}, {dir}.
 	File      string // URL template for a file, with {repo}, {importPath}, {commit}, {file}, {base}.
 	Line      string // URL template for a line, with {repo}, {importPath}, {commit}, {file}, {base}, {line}.
+	LineRange string `json:",omitempty"` // Optional URL template for a range of lines, with the variables of Line and {endLine}.
 	Raw       string // Optional URL template for the raw contents of a file, with {repo}, {commit}, {file}.
 }
 
@@ -706,6 +777,7 @@ var (
 		Directory: "{repo}/tree/{commit}/{dir}",
 		File:      "{repo}/blob/{commit}/{file}",
 		Line:      "{repo}/blob/{commit}/{file}#L{line}",
+		LineRange: "{repo}/blob/{commit}/{file}#L{line}-L{endLine}",
 		Raw:       "{repo}/raw/{commit}/{file}",
 	}
 
@@ -713,6 +785,7 @@ var (
 		Directory: "{repo}/src/{commit}/{dir}",
 		File:      "{repo}/src/{commit}/{file}",
 		Line:      "{repo}/src/{commit}/{file}#lines-{line}",
+		LineRange: "{repo}/src/{commit}/{file}#lines-{line}:{endLine}",
 		Raw:       "{repo}/raw/{commit}/{file}",
 	}
 	giteaURLTemplates = urlTemplates{
@@ -727,10 +800,12 @@ var (
 		Line:      "{repo}/+/{commit}/{file}#{line}",
 		// Gitiles has no support for serving raw content at this time.
 	}
-	gitlab2URLTemplates = urlTemplates{
+	gitlabURLTemplates = urlTemplates{
 		Directory: "{repo}/-/tree/{commit}/{dir}",
 		File:      "{repo}/-/blob/{commit}/{file}",
 		Line:      "{repo}/-/blob/{commit}/{file}#L{line}",
+		// Unlike GitHub, GitLab does not repeat the "L" in a range.
+		LineRange: "{repo}/-/blob/{commit}/{file}#L{line}-{endLine}",
 		Raw:       "{repo}/-/raw/{commit}/{file}",
 	}
 	fdioURLTemplates = urlTemplates{
@@ -19,6 +19,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-replayers/httpreplay"
+	"golang.org/x/pkgsite/internal/stdlib"
 )
 
 var (
@@ -198,20 +199,20 @@ func TestModuleInfo(t *testing.T) {
 			"gitlab.com/akita/akita", "v1.4.1", "event.go",
 
 			"https://gitlab.com/akita/akita",
-			"https://gitlab.com/akita/akita/tree/v1.4.1",
-			"https://gitlab.com/akita/akita/blob/v1.4.1/event.go",
-			"https://gitlab.com/akita/akita/blob/v1.4.1/event.go#L1",
-			"https://gitlab.com/akita/akita/raw/v1.4.1/event.go",
+			"https://gitlab.com/akita/akita/-/tree/v1.4.1",
+			"https://gitlab.com/akita/akita/-/blob/v1.4.1/event.go",
+			"https://gitlab.com/akita/akita/-/blob/v1.4.1/event.go#L1",
+			"https://gitlab.com/akita/akita/-/raw/v1.4.1/event.go",
 		},
 		{
 			"other gitlab",
 			"gitlab.66xue.com/daihao/logkit", "v0.1.18", "color.go",
 
 			"https://gitlab.66xue.com/daihao/logkit",
-			"https://gitlab.66xue.com/daihao/logkit/tree/v0.1.18",
-			"https://gitlab.66xue.com/daihao/logkit/blob/v0.1.18/color.go",
-			"https://gitlab.66xue.com/daihao/logkit/blob/v0.1.18/color.go#L1",
-			"https://gitlab.66xue.com/daihao/logkit/raw/v0.1.18/color.go",
+			"https://gitlab.66xue.com/daihao/logkit/-/tree/v0.1.18",
+			"https://gitlab.66xue.com/daihao/logkit/-/blob/v0.1.18/color.go",
+			"https://gitlab.66xue.com/daihao/logkit/-/blob/v0.1.18/color.go#L1",
+			"https://gitlab.66xue.com/daihao/logkit/-/raw/v0.1.18/color.go",
 		},
 		{
 			"gitee.com",
@@ -308,10 +309,10 @@ func TestModuleInfo(t *testing.T) {
 			"gitlab.com/akita/akita/v2", "v2.0.0-rc.2", "event.go",
 
 			"https://gitlab.com/akita/akita",
-			"https://gitlab.com/akita/akita/tree/v2.0.0-rc.2/v2",
-			"https://gitlab.com/akita/akita/blob/v2.0.0-rc.2/v2/event.go",
-			"https://gitlab.com/akita/akita/blob/v2.0.0-rc.2/v2/event.go#L1",
-			"https://gitlab.com/akita/akita/raw/v2.0.0-rc.2/v2/event.go",
+			"https://gitlab.com/akita/akita/-/tree/v2.0.0-rc.2/v2",
+			"https://gitlab.com/akita/akita/-/blob/v2.0.0-rc.2/v2/event.go",
+			"https://gitlab.com/akita/akita/-/blob/v2.0.0-rc.2/v2/event.go#L1",
+			"https://gitlab.com/akita/akita/-/raw/v2.0.0-rc.2/v2/event.go",
 		},
 		{
 			"gopkg.in, one element",
@@ -430,6 +431,75 @@ func TestModuleInfo(t *testing.T) {
 	})
 }
 
+func TestModuleInfoGitLab(t *testing.T) {
+	client := &Client{
+		httpClient: &http.Client{
+			Transport: testTransport(map[string]string{
+				// The v2 module is in a subdirectory of the repo.
+				"https://gitlab.com/akita/akita/-/blob/v2.0.0-rc.2/v2/go.mod": "",
+			}),
+			Timeout: testTimeout,
+		},
+	}
+	for _, test := range []struct {
+		modulePath, version, file                                string
+		wantRepo, wantModule, wantFile, wantLine, wantLineRange string
+		wantRaw                                                  string
+	}{
+		{
+			"gitlab.com/akita/akita", "v1.4.1", "event.go",
+
+			"https://gitlab.com/akita/akita",
+			"https://gitlab.com/akita/akita/-/tree/v1.4.1",
+			"https://gitlab.com/akita/akita/-/blob/v1.4.1/event.go",
+			"https://gitlab.com/akita/akita/-/blob/v1.4.1/event.go#L1",
+			"https://gitlab.com/akita/akita/-/blob/v1.4.1/event.go#L1-3",
+			"https://gitlab.com/akita/akita/-/raw/v1.4.1/event.go",
+		},
+		{
+			"gitlab.66xue.com/daihao/logkit", "v0.1.18", "color.go",
+
+			"https://gitlab.66xue.com/daihao/logkit",
+			"https://gitlab.66xue.com/daihao/logkit/-/tree/v0.1.18",
+			"https://gitlab.66xue.com/daihao/logkit/-/blob/v0.1.18/color.go",
+			"https://gitlab.66xue.com/daihao/logkit/-/blob/v0.1.18/color.go#L1",
+			"https://gitlab.66xue.com/daihao/logkit/-/blob/v0.1.18/color.go#L1-3",
+			"https://gitlab.66xue.com/daihao/logkit/-/raw/v0.1.18/color.go",
+		},
+		{
+			"gitlab.com/akita/akita/v2", "v2.0.0-rc.2", "event.go",
+
+			"https://gitlab.com/akita/akita",
+			"https://gitlab.com/akita/akita/-/tree/v2.0.0-rc.2/v2",
+			"https://gitlab.com/akita/akita/-/blob/v2.0.0-rc.2/v2/event.go",
+			"https://gitlab.com/akita/akita/-/blob/v2.0.0-rc.2/v2/event.go#L1",
+			"https://gitlab.com/akita/akita/-/blob/v2.0.0-rc.2/v2/event.go#L1-3",
+			"https://gitlab.com/akita/akita/-/raw/v2.0.0-rc.2/v2/event.go",
+		},
+	} {
+		t.Run(test.modulePath, func(t *testing.T) {
+			info, err := ModuleInfo(context.Background(), client, test.modulePath, test.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				name, got, want string
+			}{
+				{"repo", info.RepoURL(), test.wantRepo},
+				{"module", info.ModuleURL(), test.wantModule},
+				{"file", info.FileURL(test.file), test.wantFile},
+				{"line", info.LineURL(test.file, 1), test.wantLine},
+				{"line range", info.LineRangeURL(test.file, 1, 3), test.wantLineRange},
+				{"raw", info.RawURL(test.file), test.wantRaw},
+			} {
+				if c.got != c.want {
+					t.Errorf("%s:\ngot  %s\nwant %s", c.name, c.got, c.want)
+				}
+			}
+		})
+	}
+}
+
 func newReplayClient(t *testing.T, record bool) (*http.Client, func()) {
 	replayFilePath := filepath.Join("testdata", t.Name()+".replay")
 	if record {
@@ -808,6 +878,59 @@ func TestCommitFromVersion(t *testing.T) {
 	}
 }
 
+func TestInferOrigin(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		info    *Info
+		version string
+		want    *Origin
+	}{
+		{
+			name:    "nil",
+			info:    nil,
+			version: "v1.2.3",
+			want:    nil,
+		},
+		{
+			name:    "tag",
+			info:    NewGitHubInfo("https://github.com/a/b", "", "v1.2.3"),
+			version: "v1.2.3",
+			want:    &Origin{VCS: "git", URL: "https://github.com/a/b", Ref: "refs/tags/v1.2.3"},
+		},
+		{
+			name:    "nested module",
+			info:    NewGitHubInfo("https://github.com/a/b", "c", "c/v1.2.3"),
+			version: "v1.2.3",
+			want:    &Origin{VCS: "git", URL: "https://github.com/a/b", Ref: "refs/tags/c/v1.2.3", Subdir: "c"},
+		},
+		{
+			name:    "pseudo-version",
+			info:    NewGitHubInfo("https://github.com/a/b", "", "3a9541ec9974"),
+			version: "v0.0.0-20190615154606-3a9541ec9974",
+			want:    &Origin{VCS: "git", URL: "https://github.com/a/b"},
+		},
+		{
+			name:    "unknown host",
+			info:    &Info{repoURL: "https://example.org/a/b.hg", commit: "v1.2.3"},
+			version: "v1.2.3",
+			want:    &Origin{URL: "https://example.org/a/b.hg"},
+		},
+		{
+			name:    "stdlib",
+			info:    NewStdlibInfo("v1.16.0"),
+			version: "v1.16.0",
+			want:    &Origin{VCS: "git", URL: stdlib.GoSourceRepoURL, Ref: "refs/tags/go1.16", Subdir: "src"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.info.InferOrigin(test.version)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 type testTransport map[string]string
 
 func (t testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -909,6 +1032,10 @@ func TestJSON(t *testing.T) {
 			&Info{repoURL: "r", moduleDir: "m", commit: "c", templates: githubURLTemplates},
 			`{"RepoURL":"r","ModuleDir":"m","Commit":"c","Kind":"github"}`,
 		},
+		{
+			&Info{repoURL: "r", moduleDir: "m", commit: "c", templates: gitlabURLTemplates},
+			`{"RepoURL":"r","ModuleDir":"m","Commit":"c","Kind":"gitlab"}`,
+		},
 		{
 			&Info{repoURL: "r", moduleDir: "m", commit: "c", templates: urlTemplates{File: "f"}},
 			`{"RepoURL":"r","ModuleDir":"m","Commit":"c","Templates":{"Directory":"","File":"f","Line":"","Raw":""}}`,
@@ -960,10 +1087,57 @@ func TestURLTemplates(t *testing.T) {
 		check(p.templates.Directory, "commit")
 		check(p.templates.File, "commit")
 		check(p.templates.Line, "commit", "line")
+		check(p.templates.LineRange, "commit", "line", "endLine")
 		check(p.templates.Raw, "commit", "file")
 	}
 }
 
+func TestLineRangeURL(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		info       *Info
+		start, end int
+		want       string
+	}{
+		{
+			"github range",
+			&Info{repoURL: "https://github.com/a/b", moduleDir: "m", commit: "v1.0.0", templates: githubURLTemplates},
+			10, 20,
+			"https://github.com/a/b/blob/v1.0.0/m/f.go#L10-L20",
+		},
+		{
+			"github single line",
+			&Info{repoURL: "https://github.com/a/b", moduleDir: "m", commit: "v1.0.0", templates: githubURLTemplates},
+			10, 10,
+			"https://github.com/a/b/blob/v1.0.0/m/f.go#L10",
+		},
+		{
+			"gitlab range",
+			&Info{repoURL: "https://gitlab.com/a/b", moduleDir: "m", commit: "v1.0.0", templates: gitlabURLTemplates},
+			10, 20,
+			"https://gitlab.com/a/b/-/blob/v1.0.0/m/f.go#L10-20",
+		},
+		{
+			"gitlab single line",
+			&Info{repoURL: "https://gitlab.com/a/b", moduleDir: "m", commit: "v1.0.0", templates: gitlabURLTemplates},
+			10, 10,
+			"https://gitlab.com/a/b/-/blob/v1.0.0/m/f.go#L10",
+		},
+		{
+			"no range template",
+			&Info{repoURL: "https://a.googlesource.com/b", commit: "v1.0.0", templates: googlesourceURLTemplates},
+			10, 20,
+			"https://a.googlesource.com/b/+/v1.0.0/f.go#10",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.info.LineRangeURL("f.go", test.start, test.end); got != test.want {
+				t.Errorf("got  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
 func TestMatchLegacyTemplates(t *testing.T) {
 	for _, test := range []struct {
 		sm                     sourceMeta
@@ -977,7 +1151,7 @@ func TestMatchLegacyTemplates(t *testing.T) {
 		},
 		{
 			sm:                     sourceMeta{"", "", "", "https://git.lastassault.de/sup/networkoverlap/-/blob/master{/dir}/{file}#L{line}"},
-			wantTemplates:          gitlab2URLTemplates,
+			wantTemplates:          gitlabURLTemplates,
 			wantTransformCommitNil: true,
 		},
 		{
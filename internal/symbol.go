@@ -123,3 +123,13 @@ func (us *UnitSymbol) SupportsBuild(build BuildContext) bool {
 func (us *UnitSymbol) InAll() bool {
 	return len(us.builds) == len(BuildContexts)
 }
+
+// SymbolsByBuildContext returns the API of each of docs, keyed by its build
+// context.
+func SymbolsByBuildContext(docs []*Documentation) map[BuildContext][]*Symbol {
+	m := map[BuildContext][]*Symbol{}
+	for _, d := range docs {
+		m[d.BuildContext()] = d.API
+	}
+	return m
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package symbol
+
+import (
+	"sort"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal"
+)
+
+// A Change records that a symbol was added to or removed from the API of a
+// package at a version.
+type Change struct {
+	Version string
+	Removed bool
+}
+
+// Changes returns the changes to the API of a package, by symbol name. The
+// changes for each name are in increasing semver order, beginning with the
+// version where the symbol first appeared.
+//
+// versions are all the versions of the package, and versionToNameToUnitSymbol
+// holds the symbols of each version, as returned by
+// postgres.GetPackageSymbols. A version that is missing from
+// versionToNameToUnitSymbol has no symbols. A symbol is present at a version
+// if it is present in any of its build contexts.
+func Changes(versions []string, versionToNameToUnitSymbol map[string]map[string]*internal.UnitSymbol) map[string][]Change {
+	vs := append([]string(nil), versions...)
+	sort.Slice(vs, func(i, j int) bool {
+		return semver.Compare(vs[i], vs[j]) < 0
+	})
+
+	changes := map[string][]Change{}
+	present := map[string]bool{}
+	for _, v := range vs {
+		nameToUnitSymbol := versionToNameToUnitSymbol[v]
+		for name := range nameToUnitSymbol {
+			if !present[name] {
+				present[name] = true
+				changes[name] = append(changes[name], Change{Version: v})
+			}
+		}
+		for name := range present {
+			if _, ok := nameToUnitSymbol[name]; !ok {
+				delete(present, name)
+				changes[name] = append(changes[name], Change{Version: v, Removed: true})
+			}
+		}
+	}
+	return changes
+}
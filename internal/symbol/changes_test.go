@@ -0,0 +1,47 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package symbol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestChanges(t *testing.T) {
+	input := map[string]map[string]*internal.UnitSymbol{}
+	for _, s := range []struct {
+		name, version string
+	}{
+		{"Foo", "v1.0.0"},
+		{"Foo", "v1.1.0"},
+		{"Bar", "v1.1.0"},
+		{"Bar", "v1.3.0"},
+	} {
+		if _, ok := input[s.version]; !ok {
+			input[s.version] = map[string]*internal.UnitSymbol{}
+		}
+		input[s.version][s.name] = &internal.UnitSymbol{Name: s.name}
+	}
+	// v1.2.0 has no symbols, so it is missing from input. The versions are
+	// not sorted.
+	versions := []string{"v1.3.0", "v1.0.0", "v1.2.0", "v1.1.0"}
+	want := map[string][]Change{
+		"Foo": {
+			{Version: "v1.0.0"},
+			{Version: "v1.2.0", Removed: true},
+		},
+		"Bar": {
+			{Version: "v1.1.0"},
+			{Version: "v1.2.0", Removed: true},
+			{Version: "v1.3.0"},
+		},
+	}
+	got := Changes(versions, input)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
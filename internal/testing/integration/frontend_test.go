@@ -64,7 +64,7 @@ func setupFrontend(ctx context.Context, t *testing.T, q queue.Queue, rc *redis.C
 	enableCSP := true
 	mw := middleware.Chain(
 		middleware.AcceptRequests(http.MethodGet, http.MethodPost),
-		middleware.SecureHeaders(enableCSP),
+		middleware.SecureHeaders(enableCSP, ""),
 		middleware.Experiment(experimenter),
 	)
 	return httptest.NewServer(mw(mux))
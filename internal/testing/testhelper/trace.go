@@ -0,0 +1,52 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testhelper
+
+import (
+	"sync"
+
+	"go.opencensus.io/trace"
+)
+
+// SpanRecorder is a trace.Exporter that records the spans it is given.
+// It is safe for concurrent use.
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+// ExportSpan implements trace.Exporter.
+func (r *SpanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// Spans returns the spans recorded so far, in the order they ended.
+func (r *SpanRecorder) Spans() []*trace.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*trace.SpanData(nil), r.spans...)
+}
+
+// Find returns the first recorded span with the given name, or nil if there
+// is none.
+func (r *SpanRecorder) Find(name string) *trace.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Reset discards the recorded spans.
+func (r *SpanRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
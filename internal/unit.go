@@ -5,6 +5,8 @@
 package internal
 
 import (
+	"time"
+
 	"golang.org/x/pkgsite/internal/licenses"
 )
 
@@ -21,6 +23,10 @@ type UnitMeta struct {
 	// Note: IsRedistributable (above) applies to the unit;
 	// ModuleInfo.IsRedistributable applies to the module.
 	ModuleInfo
+
+	// ModuleUpdatedAt is the time the module was last stored, or the zero
+	// time if it is not known. It changes when the module is reprocessed.
+	ModuleUpdatedAt time.Time
 }
 
 // IsPackage reports whether the path represents a package path.
@@ -52,6 +58,36 @@ type Unit struct {
 	Symbols         map[BuildContext][]*Symbol
 	NumImports      int
 	NumImportedBy   int
+
+	// IsImportable reports whether the unit is a package that can be imported
+	// from outside its module. See the IsImportable function.
+	IsImportable bool
+
+	// Label is a short note shown with the unit, such as "no exported API"
+	// for a package that has a package comment but exports nothing.
+	Label string
+
+	// ExcludeFromSearch reports whether the unit should be left out of
+	// search. It is set only when the unit is fetched.
+	ExcludeFromSearch bool
+
+	// ImportedSymbols are the exported symbols of imported packages that the
+	// package refers to, as "<import path>.<symbol>". It is set only when
+	// the unit is fetched.
+	ImportedSymbols []string
+
+	// SupportedBuildContexts are the build contexts, among those tried
+	// during fetch (see BuildContexts and Ports), that the package builds in.
+	// It is nil if the package builds in all of them. Contexts that were not
+	// tried are never listed, even if the package builds in them.
+	SupportedBuildContexts []BuildContext
+
+	// UsesCgo reports whether the package imports "C". The documentation of
+	// such a package covers only its Go declarations.
+	UsesCgo bool
+	// Files are the sorted names of the non-test .go files of the package
+	// in any build context, relative to its directory.
+	Files []string
 }
 
 // Documentation is the rendered documentation for a given package
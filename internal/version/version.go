@@ -41,6 +41,17 @@ func IsPseudo(v string) bool {
 	return strings.Count(v, "-") >= 2 && pseudoVersionRE.MatchString(v)
 }
 
+// PseudoVersionRev returns the revision identifier of the pseudo-version v,
+// which is a prefix of the commit hash. It returns the empty string if v is
+// not a pseudo-version.
+func PseudoVersionRev(v string) string {
+	if !IsPseudo(v) {
+		return ""
+	}
+	v = strings.TrimSuffix(v, "+incompatible")
+	return v[strings.LastIndex(v, "-")+1:]
+}
+
 // IsIncompatible reports whether a valid version v is an incompatible version.
 func IsIncompatible(v string) bool {
 	return strings.HasSuffix(v, "+incompatible")
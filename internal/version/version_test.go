@@ -152,6 +152,22 @@ func TestParseVersionType(t *testing.T) {
 	}
 }
 
+func TestPseudoVersionRev(t *testing.T) {
+	for _, test := range []struct {
+		version, want string
+	}{
+		{"v1.0.0-20190311183353-d8887717615a", "d8887717615a"},
+		{"v1.2.3-pre.0.20190311183353-d8887717615a", "d8887717615a"},
+		{"v2.0.1-0.20190311183353-d8887717615a+incompatible", "d8887717615a"},
+		{"v1.2.3-20190311183353-d8887717615a", ""},
+		{"v1.0.0", ""},
+	} {
+		if got := PseudoVersionRev(test.version); got != test.want {
+			t.Errorf("PseudoVersionRev(%q) = %q, want %q", test.version, got, test.want)
+		}
+	}
+}
+
 func TestLatestOf(t *testing.T) {
 	for _, test := range []struct {
 		name     string
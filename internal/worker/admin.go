@@ -0,0 +1,90 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// adminAuth wraps a handler so that it is only served to requests whose
+// config.AdminAuthHeader is one of the configured AuthValues. If no
+// AuthValues are configured, every request is refused.
+func (s *Server) adminAuth(h func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		authVal := r.Header.Get(config.AdminAuthHeader)
+		if authVal != "" {
+			for _, wantVal := range s.cfg.AuthValues {
+				if authVal == wantVal {
+					return h(w, r)
+				}
+			}
+		}
+		return &serverError{http.StatusForbidden, errors.New("missing or invalid admin authorization")}
+	}
+}
+
+// handleAdminDelete deletes the module version given by the "module" and
+// "version" query parameters from the database, in the same way as when the
+// proxy reports that it no longer has the module version, and removes the
+// pages that depend on it from the redis cache. The next fetch of the module
+// version will then start from a clean slate.
+func (s *Server) handleAdminDelete(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return &serverError{http.StatusMethodNotAllowed, errors.New("use POST")}
+	}
+	ctx := r.Context()
+	modulePath := r.FormValue("module")
+	version := r.FormValue("version")
+	if modulePath == "" {
+		return &serverError{http.StatusBadRequest, errors.New("missing module")}
+	}
+	if !semver.IsValid(version) {
+		return &serverError{http.StatusBadRequest, fmt.Errorf("invalid version %q", version)}
+	}
+	if err := s.db.DeleteModule(ctx, modulePath, version); err != nil {
+		return &serverError{http.StatusInternalServerError, err}
+	}
+	if s.cache != nil {
+		if err := s.cache.DeleteSet(ctx, cache.MainDetailsSetKey(modulePath, version)); err != nil {
+			return &serverError{http.StatusInternalServerError, err}
+		}
+		if err := invalidateCache(ctx, s.cache, modulePath); err != nil {
+			return &serverError{http.StatusInternalServerError, err}
+		}
+	}
+	log.Infof(ctx, "admin: deleted %s@%s", modulePath, version)
+	fmt.Fprintf(w, "Deleted %s@%s", modulePath, version)
+	return nil
+}
+
+// handleAdminPathRedirect records that the path given by the "from" query
+// parameter, and the paths below it, are served under the path given by "to".
+// The frontend redirects pages for paths it has no units for using these
+// records.
+func (s *Server) handleAdminPathRedirect(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return &serverError{http.StatusMethodNotAllowed, errors.New("use POST")}
+	}
+	ctx := r.Context()
+	from := r.FormValue("from")
+	to := r.FormValue("to")
+	if err := s.db.InsertPathRedirect(ctx, from, to); err != nil {
+		if errors.Is(err, derrors.InvalidArgument) {
+			return &serverError{http.StatusBadRequest, err}
+		}
+		return &serverError{http.StatusInternalServerError, err}
+	}
+	log.Infof(ctx, "admin: redirected %s to %s", from, to)
+	fmt.Fprintf(w, "Redirected %s to %s", from, to)
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/testing/sample"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+)
+
+func TestAdminDelete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	proxyClient, teardown := proxy.SetupTestClient(t, []*proxy.Module{
+		{
+			ModulePath: sample.ModulePath,
+			Version:    sample.VersionString,
+			Files: map[string]string{
+				"foo/foo.go": "// Package foo\npackage foo\n\nconst Foo = 42",
+				"README.md":  "This is a readme",
+				"LICENSE":    testhelper.MITLicense,
+			},
+		},
+	})
+	defer teardown()
+	fetchAndCheckStatus(ctx, t, proxyClient, sample.ModulePath, sample.VersionString, http.StatusOK)
+
+	rs, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	redisClient := redis.NewClient(&redis.Options{Addr: rs.Addr()})
+	c := cache.New(redisClient)
+	keys := []string{
+		cache.MainDetailsKey(sample.ModulePath+"/foo", sample.ModulePath, sample.VersionString, "", ""),
+		"/" + sample.ModulePath + "/foo",
+	}
+	for _, key := range keys {
+		if err := c.Put(ctx, key, []byte("{}"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const authValue = "secret"
+	s, err := NewServer(&config.Config{AuthValues: []string{authValue}}, ServerConfig{
+		DB:               testDB,
+		ProxyClient:      proxyClient,
+		RedisCacheClient: redisClient,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.Install(mux.Handle)
+	target := "/admin/delete?module=" + sample.ModulePath + "&version=" + sample.VersionString
+
+	for _, test := range []struct {
+		name, method, auth string
+		wantCode           int
+	}{
+		{"no auth", "POST", "", http.StatusForbidden},
+		{"wrong auth", "POST", "wrong", http.StatusForbidden},
+		{"GET", "GET", authValue, http.StatusMethodNotAllowed},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, target, nil)
+			if test.auth != "" {
+				r.Header.Set(config.AdminAuthHeader, test.auth)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != test.wantCode {
+				t.Fatalf("code = %d, want %d", w.Code, test.wantCode)
+			}
+			if _, err := testDB.GetModuleInfo(ctx, sample.ModulePath, sample.VersionString); err != nil {
+				t.Fatalf("GetModuleInfo: %v; want module to remain", err)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("POST", target, nil)
+	r.Header.Set(config.AdminAuthHeader, authValue)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d; body: %s", w.Code, http.StatusOK, w.Body)
+	}
+	checkModuleDeleted(ctx, t, sample.ModulePath, sample.VersionString)
+	for _, key := range keys {
+		if got, err := c.Get(ctx, key); err != nil || got != nil {
+			t.Errorf("Get(%q) = %q, %v; want nil, nil", key, got, err)
+		}
+	}
+}
+
+func TestAdminPathRedirect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	const authValue = "secret"
+	s, err := NewServer(&config.Config{AuthValues: []string{authValue}}, ServerConfig{DB: testDB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.Install(mux.Handle)
+
+	for _, test := range []struct {
+		name, method, auth, target string
+		wantCode                   int
+	}{
+		{"no auth", "POST", "", "/admin/path-redirect?from=vanity.com/m&to=github.com/a/m", http.StatusForbidden},
+		{"GET", "GET", authValue, "/admin/path-redirect?from=vanity.com/m&to=github.com/a/m", http.StatusMethodNotAllowed},
+		{"missing to", "POST", authValue, "/admin/path-redirect?from=vanity.com/m", http.StatusBadRequest},
+		{"ok", "POST", authValue, "/admin/path-redirect?from=vanity.com/m&to=github.com/a/m", http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.target, nil)
+			if test.auth != "" {
+				r.Header.Set(config.AdminAuthHeader, test.auth)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != test.wantCode {
+				t.Fatalf("code = %d, want %d; body: %s", w.Code, test.wantCode, w.Body)
+			}
+		})
+	}
+	got, err := testDB.GetPathRedirect(ctx, "vanity.com/m/pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "github.com/a/m/pkg"; got != want {
+		t.Errorf("GetPathRedirect = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/queue"
+)
+
+// DrainTimeout is the longest time that the worker waits for fetches in
+// progress when it is drained.
+const DrainTimeout = 5 * time.Minute
+
+// drainPollInterval is how often Drain checks whether fetches are still in
+// progress. It is a variable for testing.
+var drainPollInterval = time.Second
+
+// Drain puts the worker in a state where it no longer starts fetches, and
+// waits for the fetches in progress to finish or for ctx to be done. Requests
+// to /fetch fail with 503 Service Unavailable, so that the task queue retries
+// them on another instance, and an in-process queue stops dispatching tasks.
+// Drain cannot be undone.
+func (s *Server) Drain(ctx context.Context) (err error) {
+	defer derrors.Wrap(&err, "Drain")
+
+	atomic.StoreInt32(&s.draining, 1)
+	if d, ok := s.queue.(queue.Drainer); ok {
+		d.Drain()
+	}
+	prev := -1
+	for {
+		n := numFetchesInProgress()
+		if n == 0 {
+			return nil
+		}
+		if n != prev {
+			log.Infof(ctx, "draining: waiting for %d fetches", n)
+			prev = n
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d fetches still in progress: %w", n, ctx.Err())
+		case <-time.After(drainPollInterval):
+		}
+	}
+}
+
+// isDraining reports whether Drain has been called.
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) != 0
+}
+
+// numFetchesInProgress returns the number of fetches that have not finished.
+func numFetchesInProgress() int {
+	n := 0
+	for _, fi := range fetchInfos() {
+		if fi.Finish.IsZero() {
+			n++
+		}
+	}
+	return n
+}
+
+// handleDrain drains the worker, responding when the fetches in progress have
+// finished or DrainTimeout has passed.
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), DrainTimeout)
+	defer cancel()
+	if err := s.Drain(ctx); err != nil {
+		return &serverError{http.StatusServiceUnavailable, err}
+	}
+	fmt.Fprintln(w, "drained")
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/fetch"
+	"golang.org/x/pkgsite/internal/queue"
+)
+
+func TestDrain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	defer func(f func() []*fetch.FetchInfo) { fetchInfos = f }(fetchInfos)
+	defer func(d time.Duration) { drainPollInterval = d }(drainPollInterval)
+	drainPollInterval = 10 * time.Millisecond
+
+	var (
+		mu       sync.Mutex
+		infos    = map[string]*fetch.FetchInfo{}
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		finished []string
+	)
+	fetchInfos = func() []*fetch.FetchInfo {
+		mu.Lock()
+		defer mu.Unlock()
+		var fis []*fetch.FetchInfo
+		for _, fi := range infos {
+			cfi := *fi
+			fis = append(fis, &cfi)
+		}
+		return fis
+	}
+	q := queue.NewInMemory(ctx, 2, nil, func(ctx context.Context, modulePath, version string) (int, error) {
+		mu.Lock()
+		fi := &fetch.FetchInfo{ModulePath: modulePath, Version: version, Start: time.Now()}
+		infos[modulePath] = fi
+		mu.Unlock()
+		if modulePath == "first" {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		fi.Finish = time.Now()
+		finished = append(finished, modulePath)
+		mu.Unlock()
+		return http.StatusOK, nil
+	})
+	s := &Server{queue: q}
+
+	if _, err := q.ScheduleFetch(ctx, "first", "v1.0.0", "", false, queue.HighPriority); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	drained := make(chan error, 1)
+	go func() { drained <- s.Drain(ctx) }()
+	for !s.isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := q.ScheduleFetch(ctx, "second", "v1.0.0", "", false, queue.HighPriority); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.handleFetch(w, httptest.NewRequest("GET", "/example.com/mod/@v/v1.0.0", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("fetch while draining: got status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned %v while a fetch was in progress", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+	// Give the queue a chance to dispatch the second task, if it wrongly
+	// would.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff([]string{"first"}, finished); diff != "" {
+		t.Errorf("finished fetches mismatch (-want, +got):\n%s", diff)
+	}
+	if _, ok := infos["second"]; ok {
+		t.Error("second fetch was dispatched after Drain")
+	}
+}
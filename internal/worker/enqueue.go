@@ -0,0 +1,98 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/queue"
+)
+
+// defaultEnqueueModuleMax is the number of versions of a module that
+// handleEnqueueModule enqueues if the "max" query parameter is not provided.
+const defaultEnqueueModuleMax = 100
+
+// handleEnqueueOrModule serves /enqueue. It enqueues the versions of a
+// module with handleEnqueueModule if the "module" query parameter is present,
+// and the next batch of module versions to process with handleEnqueue
+// otherwise.
+func (s *Server) handleEnqueueOrModule(w http.ResponseWriter, r *http.Request) error {
+	if r.FormValue("module") != "" {
+		return s.handleEnqueueModule(w, r)
+	}
+	return s.handleEnqueue(w, r)
+}
+
+// handleEnqueueModule enqueues the known versions of a module, newest first
+// (see proxy.Client.AllVersions). It is served by /enqueue when the "module"
+// query parameter is present. The "versions" query parameter must be "all".
+// At most "max" versions are enqueued. Versions that have already been
+// fetched successfully are skipped, unless "force" is "true".
+func (s *Server) handleEnqueueModule(w http.ResponseWriter, r *http.Request) (err error) {
+	ctx := r.Context()
+	modulePath := r.FormValue("module")
+	if v := r.FormValue("versions"); v != "all" {
+		return &serverError{http.StatusBadRequest, fmt.Errorf(`versions must be "all", got %q`, v)}
+	}
+	max := defaultEnqueueModuleMax
+	if m := r.FormValue("max"); m != "" {
+		max, err = strconv.Atoi(m)
+		if err != nil || max <= 0 {
+			return &serverError{http.StatusBadRequest, fmt.Errorf("invalid max %q", m)}
+		}
+	}
+	force := r.FormValue("force") == "true"
+	suffixParam := r.FormValue("suffix") // append to task name to avoid deduplication
+
+	versions, err := s.proxyClient.AllVersions(ctx, modulePath)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) || errors.Is(err, derrors.InvalidArgument) {
+			return &serverError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	var valid []string
+	for _, v := range versions {
+		if semver.IsValid(v) {
+			valid = append(valid, v)
+		}
+	}
+	sort.Slice(valid, func(i, j int) bool { return semver.Compare(valid[i], valid[j]) > 0 })
+
+	var nEnqueued, nSkipped int
+	for _, v := range valid {
+		if nEnqueued >= max {
+			break
+		}
+		if !force {
+			mvs, err := s.db.GetModuleVersionState(ctx, modulePath, v)
+			if err != nil && !errors.Is(err, derrors.NotFound) {
+				return err
+			}
+			if mvs != nil && mvs.Status == http.StatusOK {
+				nSkipped++
+				continue
+			}
+		}
+		enqueued, err := s.queue.ScheduleFetch(ctx, modulePath, v, suffixParam, false, queue.LowPriority)
+		if err != nil {
+			return err
+		}
+		if enqueued {
+			nEnqueued++
+		}
+	}
+	log.Infof(ctx, "enqueued %d versions of %s, skipped %d already fetched", nEnqueued, modulePath, nSkipped)
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "enqueued %d of %d versions of %s; skipped %d already fetched\n", nEnqueued, len(valid), modulePath, nSkipped)
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/queue"
+)
+
+// recordingQueue is a queue.Queue that records the module versions it is
+// asked to fetch.
+type recordingQueue struct {
+	mu       sync.Mutex
+	versions []string
+}
+
+func (q *recordingQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, disableProxyFetch bool, priority queue.Priority) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.versions = append(q.versions, modulePath+"@"+version)
+	return true, nil
+}
+
+func TestEnqueueModule(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	const modulePath = "example.com/three"
+	var modules []*proxy.Module
+	for _, v := range []string{"v1.0.0", "v1.1.0", "v1.2.0"} {
+		modules = append(modules, &proxy.Module{
+			ModulePath: modulePath,
+			Version:    v,
+			Files:      map[string]string{"foo.go": "package foo"},
+		})
+	}
+	proxyClient, teardownProxy := proxy.SetupTestClient(t, modules)
+	defer teardownProxy()
+
+	// v1.1.0 has already been fetched.
+	if err := testDB.UpsertModuleVersionState(ctx, &postgres.ModuleVersionStateForUpsert{
+		ModulePath: modulePath,
+		Version:    "v1.1.0",
+		AppVersion: "app",
+		Timestamp:  time.Now(),
+		Status:     http.StatusOK,
+		HasGoMod:   true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name       string
+		path       string // defaults to /enqueue
+		query      string
+		wantCode   int
+		wantQueued []string
+	}{
+		{
+			name:       "skip fetched",
+			query:      "module=" + modulePath + "&versions=all",
+			wantCode:   http.StatusOK,
+			wantQueued: []string{modulePath + "@v1.0.0", modulePath + "@v1.2.0"},
+		},
+		{
+			name:     "force",
+			query:    "module=" + modulePath + "&versions=all&force=true",
+			wantCode: http.StatusOK,
+			wantQueued: []string{
+				modulePath + "@v1.0.0", modulePath + "@v1.1.0", modulePath + "@v1.2.0",
+			},
+		},
+		{
+			name:       "max",
+			query:      "module=" + modulePath + "&versions=all&force=true&max=2",
+			wantCode:   http.StatusOK,
+			wantQueued: []string{modulePath + "@v1.1.0", modulePath + "@v1.2.0"},
+		},
+		{
+			name:     "bad versions",
+			query:    "module=" + modulePath + "&versions=v1.0.0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "bad max",
+			query:    "module=" + modulePath + "&versions=all&max=x",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unknown module",
+			query:    "module=example.com/unknown&versions=all",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			// /requeue only enqueues the next batch of module versions to
+			// process, none of which need processing.
+			name:     "requeue ignores module",
+			path:     "/requeue",
+			query:    "module=" + modulePath + "&versions=all",
+			wantCode: http.StatusOK,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q := &recordingQueue{}
+			s, err := NewServer(&config.Config{}, ServerConfig{
+				DB:          testDB,
+				ProxyClient: proxyClient,
+				Queue:       q,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mux := http.NewServeMux()
+			s.Install(mux.Handle)
+
+			path := test.path
+			if path == "" {
+				path = "/enqueue"
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("POST", path+"?"+test.query, nil))
+			if w.Code != test.wantCode {
+				t.Fatalf("code = %d, want %d; body: %s", w.Code, test.wantCode, w.Body)
+			}
+			sort.Strings(q.versions)
+			if diff := cmp.Diff(test.wantQueued, q.versions); diff != "" {
+				t.Errorf("enqueued mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
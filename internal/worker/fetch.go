@@ -45,14 +45,17 @@ type Fetcher struct {
 	SourceClient *source.Client
 	DB           *postgres.DB
 	Cache        *cache.Cache
+	// Options overrides the default limits used when processing modules.
+	Options fetch.FetchOptions
 }
 
 // FetchAndUpdateState fetches and processes a module version, and then updates
 // the module_version_states table according to the result. It returns an HTTP
 // status code representing the result of the fetch operation, and a non-nil
 // error if this status code is not 200.
-func (f *Fetcher) FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion, appVersionLabel string) (_ int, resolvedVersion string, err error) {
+func (f *Fetcher) FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion, appVersionLabel string) (status int, resolvedVersion string, err error) {
 	defer derrors.Wrap(&err, "FetchAndUpdateState(%q, %q, %q)", modulePath, requestedVersion, appVersionLabel)
+	defer func() { recordFetchOutcome(ctx, status) }()
 	tctx, span := trace.StartSpan(ctx, "FetchAndUpdateState")
 	ctx = experiment.NewContext(tctx, experiment.FromContext(ctx).Active()...)
 	ctx = log.NewContextWithLabel(ctx, "fetch", modulePath+"@"+requestedVersion)
@@ -196,7 +199,7 @@ func (f *Fetcher) fetchAndInsertModule(ctx context.Context, modulePath, requeste
 	go func() {
 		defer wg.Done()
 		start := time.Now()
-		fr := fetch.FetchModule(ctx, modulePath, requestedVersion, f.ProxyClient, f.SourceClient)
+		fr := fetch.FetchModuleWithOptions(ctx, modulePath, requestedVersion, f.ProxyClient, f.SourceClient, f.Options)
 		if fr == nil {
 			panic("fetch.FetchModule should never return a nil FetchResult")
 		}
@@ -250,9 +253,16 @@ func (f *Fetcher) fetchAndInsertModule(ctx context.Context, modulePath, requeste
 		return ft
 	}
 	log.Infof(ctx, "db.InsertModule succeeded for %s@%s", ft.ModulePath, ft.RequestedVersion)
+	// The rendered details of this module version are stale now, whether or
+	// not it is the latest.
+	if f.Cache != nil {
+		if err := f.Cache.DeleteSet(ctx, cache.MainDetailsSetKey(ft.ModulePath, ft.ResolvedVersion)); err != nil {
+			log.Errorf(ctx, "failed to invalidate main details for %s@%s: %v", ft.ModulePath, ft.ResolvedVersion, err)
+		}
+	}
 	// Invalidate the cache if we just processed the latest version of a module.
 	if isLatest {
-		if err := f.invalidateCache(ctx, ft.ModulePath); err != nil {
+		if err := invalidateCache(ctx, f.Cache, ft.ModulePath); err != nil {
 			// Failure to invalidate the cache is not that serious; at worst it means some pages will be stale.
 			// (Cache TTLs for details pages configured in internal/frontend/server.go must not be too long,
 			// to account for this possibility.)
@@ -275,19 +285,19 @@ func (f *Fetcher) fetchAndInsertModule(ctx context.Context, modulePath, requeste
 // currently affect v2 pages, that could change some day (for instance, if we
 // decide to provide history). So it's better to be safe and delete all paths in
 // the series.
-func (f *Fetcher) invalidateCache(ctx context.Context, modulePath string) error {
-	if f.Cache == nil {
+func invalidateCache(ctx context.Context, c *cache.Cache, modulePath string) error {
+	if c == nil {
 		return nil
 	}
 	var errs []error
 	seriesPath := internal.SeriesPathForModule(modulePath)
 	// All cache keys are request URLs, so they begin with "/".
-	if err := f.Cache.Delete(ctx, "/"+seriesPath); err != nil {
+	if err := c.Delete(ctx, "/"+seriesPath); err != nil {
 		errs = append(errs, err)
 	}
 	// Delete all suffixes of the series path followed by a character that marks its end.
 	for _, end := range "/@?#" {
-		if err := f.Cache.DeletePrefix(ctx, fmt.Sprintf("/%s%c", seriesPath, end)); err != nil {
+		if err := c.DeletePrefix(ctx, fmt.Sprintf("/%s%c", seriesPath, end)); err != nil {
 			errs = append(errs, err)
 		}
 	}
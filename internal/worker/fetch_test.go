@@ -6,14 +6,18 @@ package worker
 
 import (
 	"context"
+	"net/http"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/godoc"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/postgres"
@@ -21,6 +25,7 @@ import (
 	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/testing/sample"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
 )
 
 const (
@@ -62,8 +67,8 @@ func TestFetchAndUpdateState(t *testing.T) {
 			Path:              "example.com/multi/bar",
 			Name:              "bar",
 			Licenses: []*licenses.Metadata{
-				{Types: []string{"0BSD"}, FilePath: "LICENSE"},
-				{Types: []string{"MIT"}, FilePath: "bar/LICENSE"},
+				{Types: []string{"0BSD", "ISC"}, FilePath: "LICENSE"},
+				{Types: []string{"MIT", "JSON"}, FilePath: "bar/LICENSE"},
 			},
 		},
 		Documentation: []*internal.Documentation{{
@@ -118,9 +123,9 @@ func TestFetchAndUpdateState(t *testing.T) {
 					Path:              "example.com/nonredist/bar/baz",
 					Name:              "baz",
 					Licenses: []*licenses.Metadata{
-						{Types: []string{"0BSD"}, FilePath: "LICENSE"},
-						{Types: []string{"MIT"}, FilePath: "bar/LICENSE"},
-						{Types: []string{"MIT"}, FilePath: "bar/baz/COPYING"},
+						{Types: []string{"0BSD", "ISC"}, FilePath: "LICENSE"},
+						{Types: []string{"MIT", "JSON"}, FilePath: "bar/LICENSE"},
+						{Types: []string{"MIT", "JSON"}, FilePath: "bar/baz/COPYING"},
 					},
 				},
 				Documentation: []*internal.Documentation{{
@@ -148,11 +153,12 @@ func TestFetchAndUpdateState(t *testing.T) {
 					Path:              "example.com/nonredist/unk",
 					Name:              "unk",
 					Licenses: []*licenses.Metadata{
-						{Types: []string{"0BSD"}, FilePath: "LICENSE"},
+						{Types: []string{"0BSD", "ISC"}, FilePath: "LICENSE"},
 						{Types: []string{"UNKNOWN"}, FilePath: "unk/LICENSE.md"},
 					},
 				},
-				NumImports: 2,
+				IsImportable: true,
+				NumImports:   2,
 			},
 		}, {
 			modulePath: "std",
@@ -173,12 +179,13 @@ func TestFetchAndUpdateState(t *testing.T) {
 					Name:              "context",
 					Licenses: []*licenses.Metadata{
 						{
-							Types:    []string{"BSD-3-Clause"},
+							Types:    []string{"BSD-3-Clause", "BSD-3-Clause-Clear"},
 							FilePath: "LICENSE",
 						},
 					},
 				},
-				NumImports: 5,
+				IsImportable: true,
+				NumImports:   5,
 				Documentation: []*internal.Documentation{{
 					Synopsis: "Package context defines the Context type, which carries deadlines, cancelation signals, and other request-scoped values across API boundaries and between processes.",
 					GOOS:     "linux",
@@ -205,11 +212,12 @@ func TestFetchAndUpdateState(t *testing.T) {
 					Name:              "builtin",
 					Licenses: []*licenses.Metadata{
 						{
-							Types:    []string{"BSD-3-Clause"},
+							Types:    []string{"BSD-3-Clause", "BSD-3-Clause-Clear"},
 							FilePath: "LICENSE",
 						},
 					},
 				},
+				IsImportable: true,
 				Documentation: []*internal.Documentation{{
 					Synopsis: "Package builtin provides documentation for Go's predeclared identifiers.",
 					GOOS:     "linux",
@@ -236,12 +244,13 @@ func TestFetchAndUpdateState(t *testing.T) {
 					Name:              "json",
 					Licenses: []*licenses.Metadata{
 						{
-							Types:    []string{"BSD-3-Clause"},
+							Types:    []string{"BSD-3-Clause", "BSD-3-Clause-Clear"},
 							FilePath: "LICENSE",
 						},
 					},
 				},
-				NumImports: 15,
+				IsImportable: true,
+				NumImports:   15,
 				Documentation: []*internal.Documentation{{
 					Synopsis: "Package json implements encoding and decoding of JSON as defined in RFC 7159.",
 					GOOS:     "linux",
@@ -280,9 +289,10 @@ func TestFetchAndUpdateState(t *testing.T) {
 					Path:              buildConstraintsModulePath + "/cpu",
 					Name:              "cpu",
 					Licenses: []*licenses.Metadata{
-						{Types: []string{"0BSD"}, FilePath: "LICENSE"},
+						{Types: []string{"0BSD", "ISC"}, FilePath: "LICENSE"},
 					},
 				},
+				IsImportable: true,
 				Documentation: []*internal.Documentation{{
 					Synopsis: "Package cpu implements processor feature detection used by the Go standard library.",
 					GOOS:     "linux",
@@ -298,7 +308,7 @@ func TestFetchAndUpdateState(t *testing.T) {
 	}
 
 	sourceClient := source.NewClient(sourceTimeout)
-	f := &Fetcher{proxyClient, sourceClient, testDB, nil}
+	f := &Fetcher{ProxyClient: proxyClient, SourceClient: sourceClient, DB: testDB}
 	for _, test := range testCases {
 		t.Run(strings.ReplaceAll(test.pkg+"@"+test.version, "/", " "), func(t *testing.T) {
 			defer postgres.ResetTestDB(testDB, t)
@@ -364,6 +374,37 @@ func TestFetchAndUpdateState(t *testing.T) {
 	}
 }
 
+func TestFetchAndUpdateState_LocalClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	dir := t.TempDir()
+	const modulePath = "example.com/local"
+	err := proxy.WriteModuleCache(dir, []*proxy.Module{
+		{ModulePath: modulePath, Version: "v1.0.0", Files: map[string]string{"LICENSE": testhelper.MITLicense, "a/a.go": "package a"}},
+		{ModulePath: modulePath, Version: "v1.1.0", Files: map[string]string{"LICENSE": testhelper.MITLicense, "a/a.go": "package a"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyClient, err := proxy.NewLocalClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Fetcher{ProxyClient: proxyClient, SourceClient: source.NewClient(sourceTimeout), DB: testDB}
+	code, resolvedVersion, err := f.FetchAndUpdateState(ctx, modulePath, internal.LatestVersion, testAppVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusOK || resolvedVersion != "v1.1.0" {
+		t.Fatalf("got (%d, %q), want (%d, %q)", code, resolvedVersion, http.StatusOK, "v1.1.0")
+	}
+	if _, err := testDB.GetUnitMeta(ctx, modulePath+"/a", modulePath, "v1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestFetchAndUpdateLatest(t *testing.T) {
 	ctx := context.Background()
 	prox, teardown := proxy.SetupTestClient(t, testModules)
@@ -392,3 +433,47 @@ func TestFetchAndUpdateLatest(t *testing.T) {
 			modulePath, wantRaw, wantCooked)
 	}
 }
+
+func TestFetchAndUpdateState_InvalidatesMainDetails(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	dir := t.TempDir()
+	const modulePath = "example.com/cached"
+	err := proxy.WriteModuleCache(dir, []*proxy.Module{
+		{ModulePath: modulePath, Version: "v1.0.0", Files: map[string]string{"LICENSE": testhelper.MITLicense, "a/a.go": "package a"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyClient, err := proxy.NewLocalClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	c := cache.New(redis.NewClient(&redis.Options{Addr: rs.Addr()}))
+
+	staleKey := cache.MainDetailsKey(modulePath+"/a", modulePath, "v1.0.0", "", "")
+	otherKey := cache.MainDetailsKey(modulePath+"/a", modulePath, "v1.1.0", "", "")
+	for key, version := range map[string]string{staleKey: "v1.0.0", otherKey: "v1.1.0"} {
+		if err := c.PutInSet(ctx, cache.MainDetailsSetKey(modulePath, version), key, []byte("{}"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := &Fetcher{ProxyClient: proxyClient, SourceClient: source.NewClient(sourceTimeout), DB: testDB, Cache: c}
+	if _, _, err := f.FetchAndUpdateState(ctx, modulePath, "v1.0.0", testAppVersion); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Get(ctx, staleKey); err != nil || got != nil {
+		t.Errorf("Get(%q) = %q, %v; want nil, nil", staleKey, got, err)
+	}
+	if got, err := c.Get(ctx, otherKey); err != nil || got == nil {
+		t.Errorf("Get(%q) = %q, %v; want entry for a different version to remain", otherKey, got, err)
+	}
+}
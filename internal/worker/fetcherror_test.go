@@ -19,7 +19,6 @@ import (
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/fetch"
-	"golang.org/x/pkgsite/internal/godoc"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/source"
@@ -70,17 +69,23 @@ func TestFetchAndUpdateState_NotFound(t *testing.T) {
 		},
 	})
 
-	// The module should no longer be in the database:
-	// - It shouldn't be in the modules table. That also covers licenses, packages and paths tables
-	//   via foreign key constraints with ON DELETE CASCADE.
-	// - It shouldn't be in other tables like search_documents and the various imports tables.
-	if _, err := testDB.GetModuleInfo(ctx, sample.ModulePath, sample.VersionString); !errors.Is(err, derrors.NotFound) {
+	checkModuleDeleted(ctx, t, sample.ModulePath, sample.VersionString)
+}
+
+// checkModuleDeleted checks that modulePath@version is no longer in the
+// database. It shouldn't be in the modules table, which also covers the
+// licenses, packages and paths tables via foreign key constraints with ON
+// DELETE CASCADE. It also shouldn't be in other tables like search_documents
+// and the various imports tables.
+func checkModuleDeleted(ctx context.Context, t *testing.T, modulePath, version string) {
+	t.Helper()
+	if _, err := testDB.GetModuleInfo(ctx, modulePath, version); !errors.Is(err, derrors.NotFound) {
 		t.Fatalf("GetModuleInfo: got %v, want NotFound", err)
 	}
 	checkNotInTable := func(table, column string) {
 		q := fmt.Sprintf("SELECT 1 FROM %s WHERE %s = $1 LIMIT 1", table, column)
 		var x int
-		err := testDB.Underlying().QueryRow(ctx, q, sample.ModulePath).Scan(&x)
+		err := testDB.Underlying().QueryRow(ctx, q, modulePath).Scan(&x)
 		if err != sql.ErrNoRows {
 			t.Errorf("table %s: got %v, want ErrNoRows", table, err)
 		}
@@ -106,6 +111,24 @@ func TestFetchAndUpdateState_Excluded(t *testing.T) {
 	fetchAndCheckStatus(ctx, t, proxyClient, sample.ModulePath, sample.VersionString, http.StatusForbidden)
 }
 
+func TestFetchAndUpdateState_ExcludedPattern(t *testing.T) {
+	// Check that a module matching an excluded pattern is not processed, and
+	// is marked excluded in module_version_states.
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer postgres.ResetTestDB(testDB, t)
+
+	proxyClient, teardownProxy := proxy.SetupTestClient(t, nil)
+	defer teardownProxy()
+
+	if err := testDB.InsertExcludedPattern(ctx, `.*\.com/valid/.*`, "user", "for testing"); err != nil {
+		t.Fatal(err)
+	}
+
+	fetchAndCheckStatus(ctx, t, proxyClient, sample.ModulePath, sample.VersionString, http.StatusForbidden)
+}
+
 func TestFetchAndUpdateState_BadRequestedVersion(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -290,6 +313,8 @@ func TestTrimLargeCode(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout*3)
 	defer cancel()
 	defer postgres.ResetTestDB(testDB, t)
+	// Use a smaller limit than the default, so the test runs faster.
+	opts := fetch.FetchOptions{MaxDocumentationHTML: 1000 * 1000}
 	trimmedModule := map[string]string{
 		"foo/foo.go": "// Package foo\npackage foo\n\nconst Foo = 42",
 		"LICENSE":    testhelper.MITLicense,
@@ -299,7 +324,7 @@ func TestTrimLargeCode(t *testing.T) {
 		var b strings.Builder
 		b.WriteString("package bar\n\n")
 		b.WriteString("const Bar = `\n")
-		for b.Len() <= godoc.MaxDocumentationHTML {
+		for b.Len() <= opts.MaxDocumentationHTML {
 			b.WriteString("All work and no play makes Jack a dull boy.\n")
 		}
 		b.WriteString("`\n")
@@ -310,7 +335,7 @@ func TestTrimLargeCode(t *testing.T) {
 		var b strings.Builder
 		b.WriteString("package baz\n\n")
 		b.WriteString("var Baz = []string{\n")
-		for b.Len() <= godoc.MaxDocumentationHTML {
+		for b.Len() <= opts.MaxDocumentationHTML {
 			b.WriteString("`All work and no play makes Jack a dull boy.`,\n")
 		}
 		b.WriteString("}\n")
@@ -325,7 +350,10 @@ func TestTrimLargeCode(t *testing.T) {
 	})
 	defer teardownProxy()
 
-	fetchAndCheckStatus(ctx, t, proxyClient, sample.ModulePath, sample.VersionString, http.StatusOK)
+	f := Fetcher{ProxyClient: proxyClient, SourceClient: source.NewClient(sourceTimeout), DB: testDB, Options: opts}
+	if code, _, err := f.FetchAndUpdateState(ctx, sample.ModulePath, sample.VersionString, testAppVersion); code != http.StatusOK {
+		t.Fatalf("FetchAndUpdateState: got code %d, want %d (error: %v)", code, http.StatusOK, err)
+	}
 	checkPackage(ctx, t, sample.ModulePath+"/foo")
 	checkPackage(ctx, t, sample.ModulePath+"/bar")
 	checkPackage(ctx, t, sample.ModulePath+"/baz")
@@ -333,7 +361,7 @@ func TestTrimLargeCode(t *testing.T) {
 
 func fetchAndCheckStatus(ctx context.Context, t *testing.T, proxyClient *proxy.Client, modulePath, version string, wantCode int) {
 	t.Helper()
-	f := Fetcher{proxyClient, source.NewClient(sourceTimeout), testDB, nil}
+	f := Fetcher{ProxyClient: proxyClient, SourceClient: source.NewClient(sourceTimeout), DB: testDB}
 	code, _, err := f.FetchAndUpdateState(ctx, modulePath, version, testAppVersion)
 	switch code {
 	case http.StatusOK:
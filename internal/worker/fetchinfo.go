@@ -0,0 +1,224 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/fetch"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// fetchInfos returns the fetches to report. It is a variable for testing.
+var fetchInfos = fetch.FetchInfos
+
+// loadShedStats returns the statistics of the load shedder. It is a variable
+// for testing.
+var loadShedStats = fetch.ZipLoadShedStats
+
+// fetchInfoJSON is the JSON representation of a fetch.FetchInfo.
+type fetchInfoJSON struct {
+	ModulePath string
+	Version    string
+	ZipSize    uint64
+	Start      time.Time
+	// Finish is nil and Status is zero while the fetch is in progress.
+	Finish *time.Time `json:",omitempty"`
+	Status int
+	Error  string `json:",omitempty"`
+}
+
+// handleFetchInfos serves the fetches that are in progress or have recently
+// finished as a JSON array, in the order of fetch.FetchInfos: in-progress
+// fetches first, then by start time. It serves the same information as the
+// home page, for use by programs.
+func (s *Server) handleFetchInfos(w http.ResponseWriter, r *http.Request) error {
+	fis := []fetchInfoJSON{}
+	for _, fi := range fetchInfos() {
+		j := fetchInfoJSON{
+			ModulePath: fi.ModulePath,
+			Version:    fi.Version,
+			ZipSize:    fi.ZipSize,
+			Start:      fi.Start,
+			Status:     fi.Status,
+		}
+		if !fi.Finish.IsZero() {
+			finish := fi.Finish
+			j.Finish = &finish
+		}
+		if fi.Error != nil {
+			j.Error = fi.Error.Error()
+		}
+		fis = append(fis, j)
+	}
+	return writeJSON(w, fis)
+}
+
+// statusJSON is the JSON representation of the status of the worker.
+type statusJSON struct {
+	InFlight []inFlightFetchJSON
+	LoadShed loadShedJSON
+	// RecentStatusCounts is the number of recently finished fetches, by
+	// status.
+	RecentStatusCounts map[int]int
+}
+
+// inFlightFetchJSON is the JSON representation of a fetch in progress.
+type inFlightFetchJSON struct {
+	ModulePath string
+	Version    string
+	ZipSize    uint64
+	Start      time.Time
+	// ElapsedSeconds is the time since the fetch started.
+	ElapsedSeconds float64
+	// Status is zero until the fetch finishes.
+	Status int
+}
+
+// loadShedJSON is the JSON representation of fetch.LoadShedStats.
+type loadShedJSON struct {
+	fetch.LoadShedStats
+	// Utilization is SizeInFlight as a fraction of MaxSizeInFlight, or zero
+	// if there is no maximum.
+	Utilization float64
+}
+
+// handleStatus serves the fetches in progress, the state of the load shedder
+// and the number of recently finished fetches by status as JSON, for
+// monitoring dashboards. It is served by StatusHandler.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) error {
+	st := statusJSON{
+		InFlight:           []inFlightFetchJSON{},
+		LoadShed:           loadShedJSON{LoadShedStats: loadShedStats()},
+		RecentStatusCounts: map[int]int{},
+	}
+	if max := st.LoadShed.MaxSizeInFlight; max > 0 {
+		st.LoadShed.Utilization = float64(st.LoadShed.SizeInFlight) / float64(max)
+	}
+	for _, fi := range fetchInfos() {
+		if fi.Finish.IsZero() {
+			st.InFlight = append(st.InFlight, inFlightFetchJSON{
+				ModulePath:     fi.ModulePath,
+				Version:        fi.Version,
+				ZipSize:        fi.ZipSize,
+				Start:          fi.Start,
+				ElapsedSeconds: time.Since(fi.Start).Seconds(),
+				Status:         fi.Status,
+			})
+		} else {
+			st.RecentStatusCounts[fi.Status]++
+		}
+	}
+	return writeJSON(w, st)
+}
+
+// StatusHandler returns a handler that serves the status of the worker as
+// JSON. It is not installed by Install, so that it can be served only on an
+// internal listener.
+func (s *Server) StatusHandler() http.Handler {
+	return s.errorHandler(s.handleStatus)
+}
+
+// handleZipLimits serves the limits on the sizes of module zips as JSON. If
+// the max_in_flight_mi or max_module_mi query parameters are provided, which
+// requires a POST, it first sets the corresponding limit, in mebibytes; zero
+// removes it.
+func (s *Server) handleZipLimits(w http.ResponseWriter, r *http.Request) error {
+	limits := fetch.CurrentZipLimits()
+	changed := false
+	for _, p := range []struct {
+		name string
+		dst  *uint64
+	}{
+		{"max_in_flight_mi", &limits.MaxInFlightMi},
+		{"max_module_mi", &limits.MaxModuleMi},
+	} {
+		v := r.FormValue(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return &serverError{http.StatusBadRequest, fmt.Errorf("invalid %s %q", p.name, v)}
+		}
+		*p.dst = n
+		changed = true
+	}
+	if changed {
+		if r.Method != http.MethodPost {
+			return &serverError{http.StatusMethodNotAllowed, errors.New("use POST to change the zip limits")}
+		}
+		if err := fetch.SetZipLimits(limits); err != nil {
+			return &serverError{http.StatusBadRequest, err}
+		}
+		log.Infof(r.Context(), "zip limits set to %+v", limits)
+	}
+	return writeJSON(w, fetch.CurrentZipLimits())
+}
+
+// fetchResultJSON is the JSON representation of a fetch.FetchResult.
+type fetchResultJSON struct {
+	ModulePath           string
+	RequestedVersion     string
+	ResolvedVersion      string
+	HasGoMod             bool
+	GoModPath            string
+	Status               int
+	Error                string `json:",omitempty"`
+	PackageVersionStates []*internal.PackageVersionState
+}
+
+// handleFetchOne fetches the module version given by the "module" and
+// "version" query parameters synchronously, without going through the queue,
+// and serves the result as JSON. Nothing is written to the database. It is
+// for debugging fetches of a problematic module.
+func (s *Server) handleFetchOne(w http.ResponseWriter, r *http.Request) error {
+	if s.isDraining() {
+		return &serverError{http.StatusServiceUnavailable, errors.New("worker is draining")}
+	}
+	modulePath := r.FormValue("module")
+	if modulePath == "" {
+		return &serverError{http.StatusBadRequest, errors.New("missing module")}
+	}
+	version := r.FormValue("version")
+	if version == "" {
+		version = internal.LatestVersion
+	}
+	log.Infof(r.Context(), "fetching %s@%s for debugging", modulePath, version)
+	fr := fetch.FetchModuleWithOptions(r.Context(), modulePath, version, s.proxyClient, s.sourceClient, s.fetchOptions())
+	defer fr.Defer()
+	j := fetchResultJSON{
+		ModulePath:           fr.ModulePath,
+		RequestedVersion:     fr.RequestedVersion,
+		ResolvedVersion:      fr.ResolvedVersion,
+		HasGoMod:             fr.HasGoMod,
+		GoModPath:            fr.GoModPath,
+		Status:               fr.Status,
+		PackageVersionStates: fr.PackageVersionStates,
+	}
+	if fr.Error != nil {
+		j.Error = fr.Error.Error()
+	}
+	return writeJSON(w, j)
+}
+
+// writeJSON writes v to w as JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("w.Write: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/fetch"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+)
+
+func TestHandleFetchInfos(t *testing.T) {
+	defer func(f func() []*fetch.FetchInfo) { fetchInfos = f }(fetchInfos)
+	start := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	fetchInfos = func() []*fetch.FetchInfo {
+		return []*fetch.FetchInfo{
+			{ModulePath: "m.com/a", Version: "v1.0.0", ZipSize: 100, Start: start.Add(time.Minute)},
+			{
+				ModulePath: "m.com/b",
+				Version:    "v1.2.3",
+				ZipSize:    200,
+				Start:      start,
+				Finish:     start.Add(time.Second),
+				Status:     http.StatusNotFound,
+				Error:      errors.New("not found"),
+			},
+		}
+	}
+
+	s := &Server{}
+	w := httptest.NewRecorder()
+	if err := s.handleFetchInfos(w, httptest.NewRequest("GET", "/fetches", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.Header().Get("Content-Type"), "application/json; charset=utf-8"; got != want {
+		t.Errorf("got Content-Type %q, want %q", got, want)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{
+			"ModulePath": "m.com/a",
+			"Version":    "v1.0.0",
+			"ZipSize":    float64(100),
+			"Start":      "2021-01-02T03:05:05Z",
+			"Status":     float64(0),
+		},
+		{
+			"ModulePath": "m.com/b",
+			"Version":    "v1.2.3",
+			"ZipSize":    float64(200),
+			"Start":      "2021-01-02T03:04:05Z",
+			"Finish":     "2021-01-02T03:04:06Z",
+			"Status":     float64(http.StatusNotFound),
+			"Error":      "not found",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandleStatus(t *testing.T) {
+	defer func(f func() []*fetch.FetchInfo) { fetchInfos = f }(fetchInfos)
+	defer func(f func() fetch.LoadShedStats) { loadShedStats = f }(loadShedStats)
+	start := time.Now().Add(-time.Minute)
+	fetchInfos = func() []*fetch.FetchInfo {
+		return []*fetch.FetchInfo{
+			{ModulePath: "m.com/a", Version: "v1.0.0", ZipSize: 100, Start: start},
+			{ModulePath: "m.com/b", Version: "v1.0.0", Start: start, Finish: start.Add(time.Second), Status: http.StatusOK},
+			{ModulePath: "m.com/c", Version: "v1.0.0", Start: start, Finish: start.Add(time.Second), Status: http.StatusOK},
+			{ModulePath: "m.com/d", Version: "v1.0.0", Start: start, Finish: start.Add(time.Second), Status: http.StatusNotFound},
+		}
+	}
+	loadShedStats = func() fetch.LoadShedStats {
+		return fetch.LoadShedStats{SizeInFlight: 100, MaxSizeInFlight: 400, RequestsInFlight: 1, RequestsTotal: 4}
+	}
+
+	s := &Server{}
+	w := httptest.NewRecorder()
+	s.StatusHandler().ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	var got statusJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.InFlight) != 1 {
+		t.Fatalf("got %d in-flight fetches, want 1", len(got.InFlight))
+	}
+	if e := got.InFlight[0].ElapsedSeconds; e < 60 || e > 60+testTimeout.Seconds() {
+		t.Errorf("got elapsed seconds %f, want about 60", e)
+	}
+	got.InFlight[0].ElapsedSeconds = 0
+	want := statusJSON{
+		InFlight: []inFlightFetchJSON{
+			{ModulePath: "m.com/a", Version: "v1.0.0", ZipSize: 100, Start: start},
+		},
+		LoadShed: loadShedJSON{
+			LoadShedStats: fetch.LoadShedStats{SizeInFlight: 100, MaxSizeInFlight: 400, RequestsInFlight: 1, RequestsTotal: 4},
+			Utilization:   0.25,
+		},
+		RecentStatusCounts: map[int]int{http.StatusOK: 2, http.StatusNotFound: 1},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(time.Time.Equal)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandleZipLimits(t *testing.T) {
+	defer fetch.SetZipLimits(fetch.CurrentZipLimits())
+	if err := fetch.SetZipLimits(fetch.ZipLimits{MaxInFlightMi: 100, MaxModuleMi: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	const authValue = "secret"
+	s := &Server{cfg: &config.Config{AuthValues: []string{authValue}}}
+	mux := http.NewServeMux()
+	s.Install(mux.Handle)
+
+	// Requests without the admin auth header are rejected, and change nothing.
+	for _, auth := range []string{"", "wrong"} {
+		r := httptest.NewRequest("POST", "/admin/zip-limits?max_in_flight_mi=1", nil)
+		if auth != "" {
+			r.Header.Set(config.AdminAuthHeader, auth)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("auth %q: got status %d, want %d", auth, w.Code, http.StatusForbidden)
+		}
+		if got, want := fetch.CurrentZipLimits(), (fetch.ZipLimits{MaxInFlightMi: 100, MaxModuleMi: 50}); got != want {
+			t.Errorf("auth %q: limits = %+v, want %+v", auth, got, want)
+		}
+	}
+
+	for _, test := range []struct {
+		method, query string
+		wantCode      int
+		want          fetch.ZipLimits
+	}{
+		{"GET", "", http.StatusOK, fetch.ZipLimits{MaxInFlightMi: 100, MaxModuleMi: 50}},
+		{"GET", "max_in_flight_mi=200", http.StatusMethodNotAllowed, fetch.ZipLimits{MaxInFlightMi: 100, MaxModuleMi: 50}},
+		{"POST", "max_in_flight_mi=200", http.StatusOK, fetch.ZipLimits{MaxInFlightMi: 200, MaxModuleMi: 50}},
+		{"POST", "max_module_mi=0", http.StatusOK, fetch.ZipLimits{MaxInFlightMi: 200}},
+		{"POST", "max_in_flight_mi=-1", http.StatusBadRequest, fetch.ZipLimits{MaxInFlightMi: 200}},
+		// 2^44 Mi overflows an int64 number of bytes.
+		{"POST", "max_module_mi=17592186044416", http.StatusBadRequest, fetch.ZipLimits{MaxInFlightMi: 200}},
+	} {
+		r := httptest.NewRequest(test.method, "/admin/zip-limits?"+test.query, nil)
+		r.Header.Set(config.AdminAuthHeader, authValue)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Fatalf("%q: got status %d, want %d", test.query, w.Code, test.wantCode)
+		}
+		if got := fetch.CurrentZipLimits(); got != test.want {
+			t.Errorf("%q: limits = %+v, want %+v", test.query, got, test.want)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var got fetch.ZipLimits
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%q: served %+v, want %+v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestHandleFetchOne(t *testing.T) {
+	proxyClient, teardown := proxy.SetupTestClient(t, []*proxy.Module{
+		{
+			ModulePath: "bad.mod/module",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE":           testhelper.BSD0License,
+				"good/good.go":      "// Package good is good.\npackage good\n\nconst Good = true",
+				"multiplepkgs/a.go": "package a",
+				"multiplepkgs/b.go": "package b",
+			},
+		},
+	})
+	defer teardown()
+	s := &Server{
+		cfg:          &config.Config{},
+		proxyClient:  proxyClient,
+		sourceClient: source.NewClientForTesting(),
+	}
+
+	w := httptest.NewRecorder()
+	s.errorHandler(s.handleFetchOne).ServeHTTP(w, httptest.NewRequest("GET", "/debug/fetch-one?module=bad.mod/module&version=v1.0.0", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	var got fetchResultJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != derrors.ToStatus(derrors.HasIncompletePackages) || got.ResolvedVersion != "v1.0.0" || got.GoModPath != "bad.mod/module" {
+		t.Errorf("got status %d, version %q and go.mod path %q; want %d, %q and %q",
+			got.Status, got.ResolvedVersion, got.GoModPath, derrors.ToStatus(derrors.HasIncompletePackages), "v1.0.0", "bad.mod/module")
+	}
+	wantStates := []*internal.PackageVersionState{
+		{PackagePath: "bad.mod/module/good", ModulePath: "bad.mod/module", Version: "v1.0.0", Status: 200},
+		{PackagePath: "bad.mod/module/multiplepkgs", ModulePath: "bad.mod/module", Version: "v1.0.0", Status: 600},
+	}
+	if diff := cmp.Diff(wantStates, got.PackageVersionStates, cmpopts.IgnoreFields(internal.PackageVersionState{}, "Error")); diff != "" {
+		t.Errorf("package states mismatch (-want +got):\n%s", diff)
+	}
+
+	w = httptest.NewRecorder()
+	s.errorHandler(s.handleFetchOne).ServeHTTP(w, httptest.NewRequest("GET", "/debug/fetch-one", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("without a module: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	s.draining = 1
+	w = httptest.NewRecorder()
+	s.errorHandler(s.handleFetchOne).ServeHTTP(w, httptest.NewRequest("GET", "/debug/fetch-one?module=bad.mod/module&version=v1.0.0", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("while draining: got status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
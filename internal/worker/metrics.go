@@ -6,12 +6,14 @@ package worker
 
 import (
 	"context"
+	"net/http"
 	"strconv"
 	"time"
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/derrors"
 )
 
 var (
@@ -43,6 +45,23 @@ var (
 		Aggregation: view.LastValue(),
 		Description: "worker processing lag",
 	}
+
+	// keyFetchOutcome is a census tag for the class of the status of a
+	// fetch; see fetchOutcome.
+	keyFetchOutcome = tag.MustNewKey("fetch.outcome")
+	fetchOutcomes   = stats.Int64(
+		"go-discovery/worker_fetch_outcome_count",
+		"The outcome of fetching a module version.",
+		stats.UnitDimensionless,
+	)
+	// FetchOutcomeCount counts fetches of module versions by outcome.
+	FetchOutcomeCount = &view.View{
+		Name:        "go-discovery/worker_fetch_outcome/count",
+		Measure:     fetchOutcomes,
+		Aggregation: view.Count(),
+		Description: "fetch outcomes, by ok, notfound, alternative, shed or error",
+		TagKeys:     []tag.Key{keyFetchOutcome},
+	}
 )
 
 func recordEnqueue(ctx context.Context, status int) {
@@ -54,3 +73,25 @@ func recordEnqueue(ctx context.Context, status int) {
 func recordProcessingLag(ctx context.Context, d time.Duration) {
 	stats.Record(ctx, processingLag.M(d.Milliseconds()/1000))
 }
+
+// fetchOutcome returns a coarse class for the status of a fetch.
+func fetchOutcome(status int) string {
+	switch status {
+	case http.StatusOK, derrors.ToStatus(derrors.HasIncompletePackages):
+		return "ok"
+	case http.StatusNotFound, http.StatusGone:
+		return "notfound"
+	case derrors.ToStatus(derrors.AlternativeModule):
+		return "alternative"
+	case derrors.ToStatus(derrors.SheddingLoad):
+		return "shed"
+	default:
+		return "error"
+	}
+}
+
+func recordFetchOutcome(ctx context.Context, status int) {
+	stats.RecordWithTags(ctx,
+		[]tag.Mutator{tag.Upsert(keyFetchOutcome, fetchOutcome(status))},
+		fetchOutcomes.M(1))
+}
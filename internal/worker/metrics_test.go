@@ -0,0 +1,58 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestRecordFetchOutcome(t *testing.T) {
+	if err := view.Register(FetchOutcomeCount); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(FetchOutcomeCount)
+
+	ctx := context.Background()
+	for _, status := range []int{
+		http.StatusOK,
+		derrors.ToStatus(derrors.HasIncompletePackages),
+		http.StatusNotFound,
+		derrors.ToStatus(derrors.AlternativeModule),
+		derrors.ToStatus(derrors.SheddingLoad),
+		http.StatusInternalServerError,
+		derrors.ToStatus(derrors.BadModule),
+	} {
+		recordFetchOutcome(ctx, status)
+	}
+
+	rows, err := view.RetrieveData(FetchOutcomeCount.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	for _, row := range rows {
+		for _, tg := range row.Tags {
+			if tg.Key == keyFetchOutcome {
+				got[tg.Value] = row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	want := map[string]int64{
+		"ok":          2,
+		"notfound":    1,
+		"alternative": 1,
+		"shed":        1,
+		"error":       2,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
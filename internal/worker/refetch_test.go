@@ -60,7 +60,7 @@ func TestReFetch(t *testing.T) {
 	})
 	defer teardownProxy()
 	sourceClient := source.NewClient(sourceTimeout)
-	f := &Fetcher{proxyClient, sourceClient, testDB, nil}
+	f := &Fetcher{ProxyClient: proxyClient, SourceClient: sourceClient, DB: testDB}
 	if _, _, err := f.FetchAndUpdateState(ctx, sample.ModulePath, version, testAppVersion); err != nil {
 		t.Fatalf("FetchAndUpdateState(%q, %q): %v", sample.ModulePath, version, err)
 	}
@@ -79,7 +79,7 @@ func TestReFetch(t *testing.T) {
 	})
 	defer teardownProxy()
 
-	f = &Fetcher{proxyClient, sourceClient, testDB, nil}
+	f = &Fetcher{ProxyClient: proxyClient, SourceClient: sourceClient, DB: testDB}
 	if _, _, err := f.FetchAndUpdateState(ctx, sample.ModulePath, version, testAppVersion); err != nil {
 		t.Fatalf("FetchAndUpdateState(%q, %q): %v", modulePath, version, err)
 	}
@@ -96,9 +96,10 @@ func TestReFetch(t *testing.T) {
 			Path:              sample.ModulePath + "/bar",
 			Name:              "bar",
 			Licenses: []*licenses.Metadata{
-				{Types: []string{"MIT"}, FilePath: "LICENSE"},
+				{Types: []string{"MIT", "JSON"}, FilePath: "LICENSE"},
 			},
 		},
+		IsImportable: true,
 		Readme: &internal.Readme{
 			Filepath: "bar/README.md",
 			Contents: "This is a readme",
@@ -156,7 +157,7 @@ func TestReFetch(t *testing.T) {
 		},
 	})
 	defer teardownProxy()
-	f = &Fetcher{proxyClient, sourceClient, testDB, nil}
+	f = &Fetcher{ProxyClient: proxyClient, SourceClient: sourceClient, DB: testDB}
 	if _, _, err := f.FetchAndUpdateState(ctx, modulePath, version, testAppVersion); !errors.Is(err, derrors.DBModuleInsertInvalid) {
 		t.Fatalf("FetchAndUpdateState(%q, %q): %v", modulePath, version, err)
 	}
@@ -0,0 +1,177 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// reindexBatchSize is the number of search_documents rows that are refreshed
+// in each batch of a search reindex.
+const reindexBatchSize = 1000
+
+// A searchReindexer runs a full reindex of the search_documents table in the
+// background. At most one reindex runs at a time.
+type searchReindexer struct {
+	db        *postgres.DB
+	batchSize int
+
+	mu     sync.Mutex
+	status reindexStatus
+	cancel context.CancelFunc
+}
+
+// reindexStatus describes the progress of the most recent search reindex.
+type reindexStatus struct {
+	Running      bool
+	Canceled     bool
+	BatchesDone  int
+	BatchesTotal int
+	Started      time.Time
+	Finished     time.Time
+	Err          error
+}
+
+func (s reindexStatus) String() string {
+	switch {
+	case s.Started.IsZero():
+		return "No search reindex has been started."
+	case s.Running:
+		return fmt.Sprintf("Search reindex running since %s: %d of %d batches done.",
+			s.Started.Format(time.RFC3339), s.BatchesDone, s.BatchesTotal)
+	case s.Canceled:
+		return fmt.Sprintf("Search reindex canceled at %s: %d of %d batches done.",
+			s.Finished.Format(time.RFC3339), s.BatchesDone, s.BatchesTotal)
+	case s.Err != nil:
+		return fmt.Sprintf("Search reindex failed at %s: %d of %d batches done: %v",
+			s.Finished.Format(time.RFC3339), s.BatchesDone, s.BatchesTotal, s.Err)
+	default:
+		return fmt.Sprintf("Search reindex completed at %s: %d of %d batches done.",
+			s.Finished.Format(time.RFC3339), s.BatchesDone, s.BatchesTotal)
+	}
+}
+
+func newSearchReindexer(db *postgres.DB) *searchReindexer {
+	return &searchReindexer{db: db, batchSize: reindexBatchSize}
+}
+
+// errReindexRunning is returned by start when a reindex is already running.
+var errReindexRunning = errors.New("search reindex already running")
+
+// start begins a reindex in a new goroutine. The reindex is not tied to ctx,
+// which is only used for logging; call cancel to stop it.
+func (r *searchReindexer) start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status.Running {
+		return errReindexRunning
+	}
+	rctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.status = reindexStatus{Running: true, Started: time.Now()}
+	go func() {
+		defer cancel()
+		err := r.run(rctx)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.status.Running = false
+		r.status.Finished = time.Now()
+		if errors.Is(err, context.Canceled) {
+			r.status.Canceled = true
+		} else {
+			r.status.Err = err
+		}
+		r.cancel = nil
+		if err != nil {
+			log.Errorf(ctx, "search reindex: %v", err)
+		} else {
+			log.Infof(ctx, "search reindex: completed %d batches", r.status.BatchesDone)
+		}
+	}()
+	return nil
+}
+
+// run recomputes imported-by counts and refreshes search_documents, one batch
+// of packages at a time.
+func (r *searchReindexer) run(ctx context.Context) (err error) {
+	defer derrors.Wrap(&err, "searchReindexer.run")
+
+	paths, err := r.db.GetSearchPackagePaths(ctx)
+	if err != nil {
+		return err
+	}
+	counts, err := r.db.ComputeImportedByCounts(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.status.BatchesTotal = (len(paths) + r.batchSize - 1) / r.batchSize
+	r.mu.Unlock()
+	for len(paths) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := r.batchSize
+		if n > len(paths) {
+			n = len(paths)
+		}
+		if err := r.db.ReindexSearchDocuments(ctx, paths[:n], counts); err != nil {
+			return err
+		}
+		paths = paths[n:]
+		r.mu.Lock()
+		r.status.BatchesDone++
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// stop cancels the running reindex, if any. It reports whether there was one.
+func (r *searchReindexer) stop() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel == nil {
+		return false
+	}
+	r.cancel()
+	return true
+}
+
+// currentStatus returns the status of the most recent reindex.
+func (r *searchReindexer) currentStatus() reindexStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// handleReindexSearch starts, cancels or reports on a full reindex of the
+// search_documents table, depending on the "action" query parameter, which
+// may be "start", "cancel" or empty.
+func (s *Server) handleReindexSearch(w http.ResponseWriter, r *http.Request) error {
+	switch action := r.FormValue("action"); action {
+	case "start":
+		if err := s.reindexer.start(r.Context()); err != nil {
+			return &serverError{http.StatusConflict, err}
+		}
+	case "cancel":
+		if !s.reindexer.stop() {
+			return &serverError{http.StatusBadRequest, errors.New("no search reindex is running")}
+		}
+	case "":
+	default:
+		return &serverError{http.StatusBadRequest, fmt.Errorf("unknown action %q", action)}
+	}
+	fmt.Fprintln(w, s.reindexer.currentStatus())
+	return nil
+}
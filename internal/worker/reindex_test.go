@@ -0,0 +1,88 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestReindexSearch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer postgres.ResetTestDB(testDB, t)
+
+	// Insert modules A, B and C, where the packages in B and C import the
+	// package in A.
+	for _, test := range []struct {
+		name    string
+		imports []string
+	}{
+		{"A", nil},
+		{"B", []string{"mod.com/A/A"}},
+		{"C", []string{"mod.com/A/A"}},
+	} {
+		m := sample.Module("mod.com/"+test.name, sample.VersionString, test.name)
+		m.Units[1].Imports = test.imports
+		postgres.MustInsertModule(ctx, t, testDB, m)
+	}
+
+	s, err := NewServer(&config.Config{}, ServerConfig{DB: testDB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.reindexer.batchSize = 1
+	mux := http.NewServeMux()
+	s.Install(mux.Handle)
+	serve := func(action string) (int, string) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/reindex-search?action="+action, nil))
+		return w.Code, w.Body.String()
+	}
+
+	if code, body := serve("start"); code != http.StatusOK {
+		t.Fatalf("start: got code %d, body %q", code, body)
+	}
+	for s.reindexer.currentStatus().Running {
+		if ctx.Err() != nil {
+			t.Fatal("timed out waiting for reindex to finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	st := s.reindexer.currentStatus()
+	if st.Err != nil || st.Canceled {
+		t.Fatalf("reindex did not complete: %s", st)
+	}
+	if st.BatchesTotal != 3 || st.BatchesDone != st.BatchesTotal {
+		t.Errorf("got %d of %d batches done, want 3 of 3", st.BatchesDone, st.BatchesTotal)
+	}
+	code, body := serve("")
+	if code != http.StatusOK || !strings.Contains(body, "completed") {
+		t.Errorf("status: got code %d, body %q; want completed", code, body)
+	}
+	if code, _ := serve("cancel"); code != http.StatusBadRequest {
+		t.Errorf("cancel after completion: got code %d, want %d", code, http.StatusBadRequest)
+	}
+
+	var count int
+	err = testDB.Underlying().QueryRow(ctx,
+		`SELECT imported_by_count FROM search_documents WHERE package_path = $1`,
+		"mod.com/A/A").Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("imported_by_count for mod.com/A/A = %d, want 2", count)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/godoc/dochtml"
 	"golang.org/x/pkgsite/internal/index"
 	"golang.org/x/pkgsite/internal/log"
@@ -45,6 +46,7 @@ type Server struct {
 	proxyClient     *proxy.Client
 	sourceClient    *source.Client
 	redisHAClient   *redis.Client
+	redisCache      *redis.Client
 	cache           *cache.Cache
 	db              *postgres.DB
 	queue           queue.Queue
@@ -52,6 +54,8 @@ type Server struct {
 	templates       map[string]*template.Template
 	staticPath      template.TrustedSource
 	getExperiments  func() []*internal.Experiment
+	reindexer       *searchReindexer
+	draining        int32 // set by Drain; accessed atomically
 }
 
 // ServerConfig contains everything needed by a Server.
@@ -66,6 +70,9 @@ type ServerConfig struct {
 	ReportingClient  *errorreporting.Client
 	StaticPath       template.TrustedSource
 	GetExperiments   func() []*internal.Experiment
+	// ZipLimits, if non-nil, sets the limits on the sizes of module zips.
+	// They can be changed later through the /admin/zip-limits endpoint.
+	ZipLimits *fetch.ZipLimits
 }
 
 const (
@@ -93,6 +100,11 @@ func NewServer(cfg *config.Config, scfg ServerConfig) (_ *Server, err error) {
 	if scfg.RedisCacheClient != nil {
 		c = cache.New(scfg.RedisCacheClient)
 	}
+	if scfg.ZipLimits != nil {
+		if err := fetch.SetZipLimits(*scfg.ZipLimits); err != nil {
+			return nil, err
+		}
+	}
 	return &Server{
 		cfg:             cfg,
 		db:              scfg.DB,
@@ -100,12 +112,14 @@ func NewServer(cfg *config.Config, scfg ServerConfig) (_ *Server, err error) {
 		proxyClient:     scfg.ProxyClient,
 		sourceClient:    scfg.SourceClient,
 		redisHAClient:   scfg.RedisHAClient,
+		redisCache:      scfg.RedisCacheClient,
 		cache:           c,
 		queue:           scfg.Queue,
 		reportingClient: scfg.ReportingClient,
 		templates:       templates,
 		staticPath:      scfg.StaticPath,
 		getExperiments:  scfg.GetExperiments,
+		reindexer:       newSearchReindexer(scfg.DB),
 	}, nil
 }
 
@@ -165,7 +179,11 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 	// https://cloud.google.com/tasks/docs/reference/rpc/google.cloud.tasks.v2#createtaskrequest,
 	// under "Task De-duplication"). If you cannot wait, you can force
 	// duplicate tasks by providing any string as the "suffix" query parameter.
-	handle("/enqueue", rmw(s.errorHandler(s.handleEnqueue)))
+	//
+	// manual: enqueue?module=<path>&versions=all instead enqueues the versions
+	// of the module listed by the proxy, at most "max" of them, skipping
+	// those already fetched successfully unless "force" is "true".
+	handle("/enqueue", rmw(s.errorHandler(s.handleEnqueueOrModule)))
 
 	// TODO: remove after /queue is in production and the scheduler jobs have been changed.
 	// scheduled: requeue queries the module_version_states table for the next
@@ -186,6 +204,13 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 	// be reprocessed.
 	handle("/reprocess", rmw(s.errorHandler(s.handleReprocess)))
 
+	// manual: stale lists, as JSON, the module versions in the
+	// module_version_states table that were last processed by an app_version
+	// earlier than the "before" query parameter, that is, those that have not
+	// been reprocessed since that version. At most "limit" module versions
+	// are listed.
+	handle("/stale", s.errorHandler(s.handleStale))
+
 	// manual: populate-stdlib inserts all modules of the Go standard
 	// library into the tasks queue to be processed and inserted into the
 	// database. handlePopulateStdLib should be updated whenever a new
@@ -198,19 +223,60 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 	// "before" query parameter.
 	handle("/repopulate-search-documents", rmw(s.errorHandler(s.handleRepopulateSearchDocuments)))
 
+	// manual: reindex-search recomputes imported-by counts and refreshes
+	// every row of the search_documents table in the background, in batches
+	// that do not lock the whole table. The "action" query parameter can be
+	// "start" to begin a reindex or "cancel" to stop the running one. The
+	// response reports the progress of the most recent reindex.
+	handle("/reindex-search", rmw(s.errorHandler(s.handleReindexSearch)))
+
 	// manual: clear-cache clears the redis cache.
 	handle("/clear-cache", rmw(s.errorHandler(s.clearCache)))
 
 	// manual: delete the specified module version.
 	handle("/delete/", http.StripPrefix("/delete", rmw(s.errorHandler(s.handleDelete))))
 
+	// manual: admin/delete deletes, in response to a POST, the module version
+	// given by the "module" and "version" query parameters from the
+	// database, including its search documents and imports, and evicts the
+	// pages that depend on it from the redis cache, so that it can be
+	// re-fetched cleanly. The request must carry the admin auth header (see
+	// config.AdminAuthHeader).
+	handle("/admin/delete", rmw(s.errorHandler(s.adminAuth(s.handleAdminDelete))))
+
+	// manual: admin/path-redirect records, in response to a POST, that the
+	// path given by the "from" query parameter and the paths below it are
+	// served under the path given by "to", so that the frontend redirects
+	// them. The request must carry the admin auth header.
+	handle("/admin/path-redirect", rmw(s.errorHandler(s.adminAuth(s.handleAdminPathRedirect))))
+
 	handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.staticPath.String()))))
 
 	// returns an HTML page displaying information about recent versions that were processed.
 	handle("/versions", http.HandlerFunc(s.handleHTMLPage(s.doVersionsPage)))
 
+	// manual: drain stops the worker from starting fetches and waits for
+	// the fetches in progress to finish. It is used before shutting down an
+	// instance.
+	handle("/drain", s.errorHandler(s.handleDrain))
+
+	// returns the fetches that are in progress or recently finished as JSON.
+	handle("/fetches", s.errorHandler(s.handleFetchInfos))
+
+	// manual: debug/fetch-one fetches the module version given by the
+	// "module" and "version" query parameters synchronously and returns the
+	// result, including the state of each package, as JSON. It does not
+	// write to the database.
+	handle("/debug/fetch-one", s.errorHandler(s.handleFetchOne))
+
+	// manual: admin/zip-limits returns the limits on the sizes of module zips
+	// as JSON. The max_in_flight_mi and max_module_mi query parameters of a
+	// POST change them without a restart. The request must carry the admin
+	// auth header.
+	handle("/admin/zip-limits", s.errorHandler(s.adminAuth(s.handleZipLimits)))
+
 	// Health check.
-	handle("/healthz", http.HandlerFunc(s.handleHealthCheck))
+	handle("/healthz", middleware.HealthHandler(s.healthChecks()...))
 
 	handle("/favicon.ico", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "content/static/img/worker-favicon.ico")
@@ -271,6 +337,15 @@ func (s *Server) handleFetch(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, `<p><a href="/fetch/rsc.io/quote/@v/v1.0.0">Fetch an example module</a></p>`)
 		return
 	}
+	if s.isDraining() {
+		log.Infof(r.Context(), "draining; returning %d for %s", http.StatusServiceUnavailable, r.URL.Path)
+		http.Error(w, "worker is draining", http.StatusServiceUnavailable)
+		return
+	}
+	// Cloud Tasks counts the retries of a task in this header.
+	if n, _ := strconv.Atoi(r.Header.Get("X-CloudTasks-TaskRetryCount")); n > 0 {
+		queue.RecordRefetch(r.Context())
+	}
 	msg, code := s.doFetch(w, r)
 	if code == http.StatusInternalServerError || code == http.StatusServiceUnavailable {
 		log.Infof(r.Context(), "doFetch of %s returned %d; returning that code to retry task", r.URL.Path, code)
@@ -288,9 +363,36 @@ func (s *Server) handleFetch(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, http.StatusText(code))
 }
 
+// startFetchSpan starts the span for the fetch in r. If the fetch was
+// scheduled by a traced request, the span is a child of that request's span,
+// so that a fetch can be traced back to the page view that caused it.
+func startFetchSpan(r *http.Request) (context.Context, *trace.Span) {
+	ctx := r.Context()
+	if sc, ok := queue.SpanContextFromRequest(r); ok {
+		// ochttp.Handler continues the trace of the request itself, if it
+		// serves it. Otherwise, continue it here.
+		if parent := trace.FromContext(ctx); parent == nil || parent.SpanContext().TraceID != sc.TraceID {
+			return trace.StartSpanWithRemoteParent(ctx, "worker.fetch", sc)
+		}
+	}
+	return trace.StartSpan(ctx, "worker.fetch")
+}
+
+// fetchOptions returns the options for the fetches of the worker, from its
+// configuration.
+func (s *Server) fetchOptions() fetch.FetchOptions {
+	return fetch.FetchOptions{
+		AllowMajorVersionMismatch:      s.cfg.AllowMajorVersionMismatch,
+		NoExportedAPILabel:             s.cfg.NoExportedAPILabel,
+		ExcludeNoExportedAPIFromSearch: s.cfg.ExcludeNoExportedAPIFromSearch,
+		LicenseCoverageThreshold:       s.cfg.LicenseCoverageThreshold,
+	}
+}
+
 // doFetch executes a fetch request and returns the msg and status.
 func (s *Server) doFetch(w http.ResponseWriter, r *http.Request) (string, int) {
-	ctx := r.Context()
+	ctx, span := startFetchSpan(r)
+	defer span.End()
 	modulePath, requestedVersion, err := parseModulePathAndVersion(r.URL.Path)
 	if err != nil {
 		return err.Error(), http.StatusBadRequest
@@ -301,6 +403,7 @@ func (s *Server) doFetch(w http.ResponseWriter, r *http.Request) (string, int) {
 		SourceClient: s.sourceClient,
 		DB:           s.db,
 		Cache:        s.cache,
+		Options:      s.fetchOptions(),
 	}
 	if r.FormValue(queue.DisableProxyFetchParam) == queue.DisableProxyFetchValue {
 		f.ProxyClient = f.ProxyClient.WithFetchDisabled()
@@ -426,7 +529,7 @@ func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) (err erro
 		go func() {
 			defer func() { <-sem }()
 			enqueued, err := s.queue.ScheduleFetch(ctx, m.ModulePath, m.Version, suffixParam,
-				shouldDisableProxyFetch(m))
+				shouldDisableProxyFetch(m), queue.LowPriority)
 			mu.Lock()
 			if err != nil {
 				log.Errorf(ctx, "enqueuing: %v", err)
@@ -472,7 +575,7 @@ func (s *Server) handleFetchStdMaster(w http.ResponseWriter, r *http.Request) er
 		return err
 	}
 	if vm.ResolvedVersion != resolvedVersion {
-		if _, err := s.queue.ScheduleFetch(r.Context(), stdlib.ModulePath, "master", "", false); err != nil {
+		if _, err := s.queue.ScheduleFetch(r.Context(), stdlib.ModulePath, "master", "", false, queue.LowPriority); err != nil {
 			return fmt.Errorf("error scheduling fetch for %s: %w", "master", err)
 		}
 	}
@@ -496,7 +599,7 @@ func (s *Server) doPopulateStdLib(ctx context.Context, suffix string) (string, e
 		return "", err
 	}
 	for _, v := range versions {
-		if _, err := s.queue.ScheduleFetch(ctx, stdlib.ModulePath, v, suffix, false); err != nil {
+		if _, err := s.queue.ScheduleFetch(ctx, stdlib.ModulePath, v, suffix, false, queue.LowPriority); err != nil {
 			return "", fmt.Errorf("error scheduling fetch for %s: %w", v, err)
 		}
 	}
@@ -545,6 +648,29 @@ func (s *Server) handleReprocess(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// handleStale serves the module versions that were last processed by an app
+// version earlier than the one in the "before" query parameter, as a JSON
+// list of "module@version" strings.
+func (s *Server) handleStale(w http.ResponseWriter, r *http.Request) error {
+	before := r.FormValue("before")
+	if before == "" {
+		return &serverError{http.StatusBadRequest, errors.New("before was not specified")}
+	}
+	if err := config.ValidateAppVersion(before); err != nil {
+		return &serverError{http.StatusBadRequest, fmt.Errorf("config.ValidateAppVersion(%q): %v", before, err)}
+	}
+	limit := parseLimitParam(r, 1000)
+	mvss, err := s.db.GetStaleModuleVersions(r.Context(), before, limit)
+	if err != nil {
+		return err
+	}
+	stale := []string{}
+	for _, mvs := range mvss {
+		stale = append(stale, mvs.ModulePath+"@"+mvs.Version)
+	}
+	return writeJSON(w, stale)
+}
+
 func (s *Server) clearCache(w http.ResponseWriter, r *http.Request) error {
 	if s.cache == nil {
 		return errors.New("redis cache client is not configured")
@@ -569,12 +695,23 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	if err := s.db.Underlying().Ping(); err != nil {
-		http.Error(w, fmt.Sprintf("DB ping failed: %v", err), http.StatusInternalServerError)
-		return
+// healthChecks returns the checks of the dependencies of the worker that are
+// served by /healthz.
+func (s *Server) healthChecks() []middleware.HealthCheck {
+	var checks []middleware.HealthCheck
+	if s.db != nil {
+		checks = append(checks, middleware.DatabaseHealthCheck(s.db.Underlying()))
+	}
+	if s.redisHAClient != nil {
+		checks = append(checks, middleware.RedisHealthCheck("redis-ha", s.redisHAClient))
+	}
+	if s.redisCache != nil {
+		checks = append(checks, middleware.RedisHealthCheck("redis-cache", s.redisCache))
+	}
+	if s.cfg.HealthCheckProxy && s.proxyClient != nil {
+		checks = append(checks, middleware.HealthCheck{Name: "proxy", Required: true, Check: s.proxyClient.Ping})
 	}
-	fmt.Fprintln(w, "OK")
+	return checks
 }
 
 // Parse the template for the status page.
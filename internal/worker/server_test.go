@@ -17,6 +17,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/safehtml/template"
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
 	"go.opencensus.io/trace"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/config"
@@ -28,6 +29,7 @@ import (
 	"golang.org/x/pkgsite/internal/queue"
 	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/testing/sample"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
 )
 
 const testTimeout = 120 * time.Second
@@ -179,7 +181,7 @@ func TestWorker(t *testing.T) {
 			proxyClient, teardownProxy := proxy.SetupTestClient(t, test.proxy)
 			defer teardownProxy()
 			defer postgres.ResetTestDB(testDB, t)
-			f := &Fetcher{proxyClient, source.NewClient(sourceTimeout), testDB, nil}
+			f := &Fetcher{ProxyClient: proxyClient, SourceClient: source.NewClient(sourceTimeout), DB: testDB}
 
 			// Use 10 workers to have parallelism consistent with the worker binary.
 			q := queue.NewInMemory(ctx, 10, nil, func(ctx context.Context, mpath, version string) (int, error) {
@@ -353,6 +355,35 @@ func TestShouldDisableProxyFetch(t *testing.T) {
 	}
 }
 
+func TestStartFetchSpan(t *testing.T) {
+	rec := &testhelper.SpanRecorder{}
+	trace.RegisterExporter(rec)
+	defer trace.UnregisterExporter(rec)
+
+	// The span of the frontend request that scheduled the fetch.
+	_, enqueueSpan := trace.StartSpan(context.Background(), "enqueue", trace.WithSampler(trace.AlwaysSample()))
+	enqueueSpan.End()
+	want := enqueueSpan.SpanContext()
+
+	r := httptest.NewRequest(http.MethodPost, "/fetch/m/@v/v1.0.0", nil)
+	(&b3.HTTPFormat{}).SpanContextToRequest(want, r)
+	if _, ok := queue.SpanContextFromRequest(r); !ok {
+		t.Fatal("queue.SpanContextFromRequest: no span context")
+	}
+	_, span := startFetchSpan(r)
+	span.End()
+
+	spans := rec.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	got := spans[1]
+	if got.Name != "worker.fetch" || got.TraceID != want.TraceID || got.ParentSpanID != want.SpanID {
+		t.Errorf("got span %q with trace %s and parent %s, want %q with trace %s and parent %s",
+			got.Name, got.TraceID, got.ParentSpanID, "worker.fetch", want.TraceID, want.SpanID)
+	}
+}
+
 type fakeTransport struct{}
 
 func (fakeTransport) RoundTrip(*http.Request) (*http.Response, error) {
@@ -0,0 +1,22 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN go_version TEXT;
+
+COMMENT ON COLUMN modules.go_version IS
+'COLUMN go_version holds the version from the go directive of the module''s go.mod file, if any.';
+
+CREATE TABLE requirements (
+    module_id        INTEGER NOT NULL REFERENCES modules (id) ON DELETE CASCADE,
+    required_path    TEXT NOT NULL,
+    required_version TEXT NOT NULL,
+
+    PRIMARY KEY (module_id, required_path)
+);
+COMMENT ON TABLE requirements IS
+'TABLE requirements contains the direct requirements listed in the go.mod file of each module version.';
+
+END;
@@ -0,0 +1,16 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules
+    ADD COLUMN maintainers_file TEXT,
+    ADD COLUMN maintainers TEXT[];
+
+COMMENT ON COLUMN modules.maintainers_file IS
+'COLUMN maintainers_file is the path of the AUTHORS, MAINTAINERS, OWNERS or CODEOWNERS file of the module, relative to the module root, if any.';
+COMMENT ON COLUMN modules.maintainers IS
+'COLUMN maintainers holds the names and handles listed in maintainers_file.';
+
+END;
@@ -0,0 +1,10 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+DROP INDEX idx_search_documents_tsv_doc_tokens;
+ALTER TABLE search_documents DROP COLUMN tsv_doc_tokens;
+
+END;
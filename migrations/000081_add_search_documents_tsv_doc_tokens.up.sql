@@ -0,0 +1,16 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE search_documents ADD COLUMN tsv_doc_tokens tsvector;
+
+COMMENT ON COLUMN search_documents.tsv_doc_tokens IS
+'COLUMN tsv_doc_tokens holds the words of the synopsis and README of the package, weighted like the B, C and D sections of tsv_search_tokens. Unlike tsv_search_tokens, it does not include the package path.';
+
+CREATE INDEX idx_search_documents_tsv_doc_tokens ON search_documents USING gin (tsv_doc_tokens);
+COMMENT ON INDEX idx_search_documents_tsv_doc_tokens IS
+'INDEX idx_search_documents_tsv_doc_tokens improves performance for full-text search of documentation.';
+
+END;
@@ -0,0 +1,19 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE package_symbol_uses (
+    unit_id INTEGER NOT NULL REFERENCES units(id) ON DELETE CASCADE,
+    to_path text NOT NULL,
+    symbol text NOT NULL,
+    PRIMARY KEY (unit_id, to_path, symbol)
+);
+CREATE INDEX idx_package_symbol_uses_to_path_symbol ON package_symbol_uses (to_path, symbol);
+COMMENT ON TABLE package_symbol_uses IS
+'TABLE package_symbol_uses contains the exported symbols of imported packages that a package refers to. The package represented by unit_id refers to symbol of the package to_path, which it imports.';
+COMMENT ON INDEX idx_package_symbol_uses_to_path_symbol IS
+'INDEX idx_package_symbol_uses_to_path_symbol is used to find the packages that use a symbol.';
+
+END;
@@ -0,0 +1,20 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE excluded_patterns (
+    pattern text NOT NULL,
+    created_by text NOT NULL,
+    reason text NOT NULL,
+    created_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT excluded_patterns_created_by_check CHECK ((created_by <> ''::text)),
+    CONSTRAINT excluded_patterns_pattern_check CHECK ((pattern <> ''::text)),
+    CONSTRAINT excluded_patterns_reason_check CHECK ((reason <> ''::text)),
+    PRIMARY KEY (pattern)
+);
+COMMENT ON TABLE excluded_patterns IS
+'TABLE excluded_patterns contains regular expressions matching the paths of modules or groups of modules we exclude from serving and processing. A path is excluded if a pattern matches all of it. It complements excluded_prefixes.';
+
+END;
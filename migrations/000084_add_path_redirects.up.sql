@@ -0,0 +1,18 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE path_redirects (
+    from_path text NOT NULL,
+    to_path text NOT NULL,
+    created_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT path_redirects_from_path_check CHECK ((from_path <> ''::text)),
+    CONSTRAINT path_redirects_to_path_check CHECK ((to_path <> ''::text)),
+    PRIMARY KEY (from_path)
+);
+COMMENT ON TABLE path_redirects IS
+'TABLE path_redirects maps vanity import paths to the module paths they are served under. A request for from_path, or for a path below it, is redirected to the corresponding path below to_path.';
+
+END;
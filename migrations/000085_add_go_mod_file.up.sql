@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN go_mod_file BYTEA;
+
+COMMENT ON COLUMN modules.go_mod_file IS
+'COLUMN go_mod_file holds the raw contents of the module''s go.mod file, as served by the proxy.';
+
+END;
@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE units ADD COLUMN supported_build_contexts TEXT[];
+
+COMMENT ON COLUMN units.supported_build_contexts IS
+'COLUMN supported_build_contexts holds the build contexts, as "GOOS/GOARCH", among those tried during fetch, that the package builds in. It is NULL if the package builds in all of them.';
+
+END;
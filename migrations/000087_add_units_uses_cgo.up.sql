@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE units ADD COLUMN uses_cgo BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN units.uses_cgo IS
+'COLUMN uses_cgo reports whether the package imports "C". The documentation of such a package covers only its Go declarations.';
+
+END;
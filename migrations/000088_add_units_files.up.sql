@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE units ADD COLUMN files TEXT[];
+
+COMMENT ON COLUMN units.files IS
+'COLUMN files holds the source file names of a package in all build contexts. It is NULL for non-packages and for packages fetched before it was added.';
+
+END;
@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN commit_hash TEXT;
+
+COMMENT ON COLUMN modules.commit_hash IS
+'COLUMN commit_hash is the hash of the commit the version refers to, as reported in the Origin of the proxy .info response. It is NULL if the proxy did not report it.';
+
+END;
@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN origin JSONB;
+
+COMMENT ON COLUMN modules.origin IS
+'COLUMN origin describes the repository the version comes from: its VCS, URL, the ref of the version and the subdirectory of the module. It is reported by the proxy when available, and otherwise inferred from the source info.';
+
+END;
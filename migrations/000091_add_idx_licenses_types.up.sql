@@ -0,0 +1,11 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+--
+-- BEGIN and END are omitted because CREATE INDEX CONCURRENTLY cannot run
+-- inside a transaction block.
+--
+-- The index serves the "types @> ARRAY[...]" condition of
+-- GetModulesByLicenseType.
+
+CREATE INDEX CONCURRENTLY idx_licenses_types ON licenses USING GIN (types);
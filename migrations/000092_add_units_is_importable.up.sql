@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE units ADD COLUMN is_importable BOOLEAN;
+
+COMMENT ON COLUMN units.is_importable IS
+'COLUMN is_importable reports whether the unit is a package that can be imported from outside its module. It is NULL for units fetched before it was added.';
+
+END;
@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE units ADD COLUMN label TEXT;
+
+COMMENT ON COLUMN units.label IS
+'COLUMN label holds a short note shown with the unit, such as "no exported API" for a package that has a package comment but exports nothing. It is empty or NULL for units without a label.';
+
+END;
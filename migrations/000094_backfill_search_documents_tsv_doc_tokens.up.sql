@@ -0,0 +1,14 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+-- Rows inserted before tsv_doc_tokens was added have NULL for it, so they
+-- never match a documentation search. Backfilling them in one UPDATE would
+-- lock search_documents for as long as it runs, so this migration makes no
+-- change. Instead, start a search reindex on the worker with
+-- /reindex-search?action=start; it rewrites every row, including
+-- tsv_doc_tokens, in batches that lock only the rows they update.
+
+BEGIN;
+
+END;
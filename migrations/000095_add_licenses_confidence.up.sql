@@ -0,0 +1,12 @@
+-- Copyright 2021 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE licenses ADD COLUMN confidence DOUBLE PRECISION[];
+
+COMMENT ON COLUMN licenses.confidence IS
+'COLUMN confidence holds the confidence, as a percentage, of each of the license types in the types column, in the same order. It is NULL for licenses whose types could not be determined, and for licenses detected before it was added.';
+
+END;